@@ -22,7 +22,12 @@
 
 package client
 
-import "context"
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"time"
+)
 
 // API is the interface implemented by the starter's HTTP API's.
 type API interface {
@@ -32,9 +37,272 @@ type API interface {
 	// Processes loads information of all the server processes launched by the starter.
 	Processes(ctx context.Context) (ProcessList, error)
 
+	// CommandLine loads the command line the starter used to launch the server of given type.
+	CommandLine(ctx context.Context, serverType ServerType) (ServerCommandLine, error)
+
+	// Config loads the effective configuration of the starter (with secrets redacted).
+	Config(ctx context.Context) (ConfigInfo, error)
+
+	// Status loads the status of the starter, its servers and its peers.
+	Status(ctx context.Context) (StatusInfo, error)
+
+	// PeerProcesses loads information of all the server processes launched by the starter of the peer
+	// with given ID, through the starter this client is connected to.
+	PeerProcesses(ctx context.Context, peerID string) (ProcessList, error)
+
+	// PeerHealth loads the health of the starter of the peer with given ID and the servers started by it,
+	// through the starter this client is connected to.
+	// A starter with a failed server responds with status 503, which is not returned as an error.
+	PeerHealth(ctx context.Context, peerID string) (HealthResponse, error)
+
 	// Shutdown will shutdown a starter (and all its started servers).
 	// With goodbye set, it will remove the peer slot for the starter.
 	Shutdown(ctx context.Context, goodbye bool) error
+
+	// ShutdownWithOptions will shutdown a starter (and all its started servers)
+	// using the given options.
+	ShutdownWithOptions(ctx context.Context, options ShutdownOptions) error
+
+	// StartUpgrade starts a rolling upgrade of all servers of the deployment.
+	StartUpgrade(ctx context.Context) (UpgradeStatus, error)
+
+	// StartUpgradeWithOptions starts a rolling upgrade of all servers of the deployment
+	// using the given options.
+	StartUpgradeWithOptions(ctx context.Context, options UpgradeOptions) (UpgradeStatus, error)
+
+	// UpgradeStatus loads the status of the current (or last) rolling upgrade.
+	UpgradeStatus(ctx context.Context) (UpgradeStatus, error)
+
+	// ResumeUpgrade proceeds with a canary upgrade that waits for confirmation after its canary phase.
+	ResumeUpgrade(ctx context.Context) (UpgradeStatus, error)
+
+	// AbortUpgrade stops a canary upgrade that waits for confirmation after its canary phase.
+	AbortUpgrade(ctx context.Context) (UpgradeStatus, error)
+
+	// Operation loads the state of the long-running operation (e.g. rolling upgrade, shutdown or hot backup)
+	// with given ID, as returned in the X-Arangodb-Operation-Id header of the request that started it.
+	Operation(ctx context.Context, id string) (Operation, error)
+
+	// ReplaceDBServer starts the replacement of a failed dbserver by a fresh dbserver on another peer.
+	ReplaceDBServer(ctx context.Context, req ReplaceDBServerRequest) (ReplaceDBServerStatus, error)
+
+	// ReplaceDBServerStatus loads the status of the current (or last) replacement of a failed dbserver.
+	ReplaceDBServerStatus(ctx context.Context) (ReplaceDBServerStatus, error)
+
+	// MoveData starts moving the data directory of the starter (and its local slaves) to a new directory.
+	MoveData(ctx context.Context, req MoveDataRequest) (MoveDataStatus, error)
+
+	// MoveDataStatus loads the status of the current (or last) move of the data directory of the starter.
+	MoveDataStatus(ctx context.Context) (MoveDataStatus, error)
+
+	// ScaleLocalSlaves changes the number of local slaves of a starter started with `--starter.local`.
+	ScaleLocalSlaves(ctx context.Context, req LocalSlavesRequest) (LocalSlavesStatus, error)
+
+	// LocalSlavesStatus loads the status of the current (or last) change of the number of local slaves.
+	LocalSlavesStatus(ctx context.Context) (LocalSlavesStatus, error)
+
+	// LogLevels loads the log levels of the starter and the servers started by it.
+	LogLevels(ctx context.Context) (LogLevels, error)
+
+	// SetLogLevels changes the log levels of the starter and/or the servers started by it.
+	SetLogLevels(ctx context.Context, req LogLevelRequest) (LogLevels, error)
+
+	// RotateLogs rotates the log files of the servers started by the starter, or asks these servers
+	// to reopen their log files when the starter does not rotate them itself.
+	RotateLogs(ctx context.Context) (LogRotation, error)
+
+	// RestartServer restarts the server of given type, started by the starter, regardless of the restart policy.
+	// It returns once the server is up again.
+	RestartServer(ctx context.Context, serverType ServerType) error
+
+	// ServerLog writes the log of the server of given type, started by the starter, to the given writer.
+	// With stream set to OutputStreamStdout or OutputStreamStderr, the most recently captured
+	// output of the server is written instead.
+	ServerLog(ctx context.Context, serverType ServerType, stream string, w io.Writer) error
+
+	// Diagnostics creates a diagnostics bundle (tar.gz) of the starter and writes it to the given writer.
+	Diagnostics(ctx context.Context, w io.Writer) error
+
+	// AgencyDump loads the entire content of the agency, from the agent started by the starter.
+	AgencyDump(ctx context.Context) (json.RawMessage, error)
+
+	// AgencyDumpWithPath loads the content of the agency at the given path prefix (e.g. /arango/Plan),
+	// from the agent started by the starter.
+	AgencyDumpWithPath(ctx context.Context, path string) (json.RawMessage, error)
+
+	// BackupStatus loads the status of the scheduled backups of the starter, including all existing backups.
+	BackupStatus(ctx context.Context) (BackupStatus, error)
+
+	// HotBackupStatus loads the status of the current (or last) hot backup operation of the starter,
+	// including all hot backups of the deployment.
+	HotBackupStatus(ctx context.Context) (HotBackupStatus, error)
+
+	// CreateHotBackup starts the creation of a hot backup of the deployment.
+	CreateHotBackup(ctx context.Context, req HotBackupCreateRequest) (HotBackupStatus, error)
+
+	// UploadHotBackup starts the upload of a hot backup to a remote repository.
+	UploadHotBackup(ctx context.Context, req HotBackupTransferRequest) (HotBackupStatus, error)
+
+	// DownloadHotBackup starts the download of a hot backup from a remote repository.
+	DownloadHotBackup(ctx context.Context, req HotBackupTransferRequest) (HotBackupStatus, error)
+
+	// Health loads the health of the starter and the servers started by it.
+	// A starter with a failed server responds with status 503, which is not returned as an error.
+	Health(ctx context.Context) (HealthResponse, error)
+
+	// AuditLog loads the most recent entries (all entries if limit <= 0) of the audit log of the starter.
+	AuditLog(ctx context.Context, limit int) ([]AuditLogEntry, error)
+
+	// Watch subscribes to the event stream of the starter, returning a channel that receives
+	// an event whenever one of its servers comes up, terminates, fails or is restarted, or a peer
+	// joins or leaves the deployment.
+	// The channel is closed when the given context is cancelled or the stream ends (e.g. because
+	// the starter has stopped), after which Watch must be called again to receive more events.
+	Watch(ctx context.Context) (<-chan ProcessEvent, error)
+}
+
+const (
+	ProcessEventServerUp      = "server-up"      // A server has started responding to requests
+	ProcessEventServerDown    = "server-down"    // A server has terminated
+	ProcessEventServerFailed  = "server-failed"  // A server has failed and is no longer restarted
+	ProcessEventServerRestart = "server-restart" // A terminated server is restarted
+	ProcessEventServerCrash   = "server-crash"   // A server has terminated unexpectedly (with a non-zero exit code)
+	ProcessEventCrashLoop     = "crash-loop"     // A server has entered a crash loop
+	ProcessEventClusterReady  = "cluster-ready"  // All servers started by the starter are up
+	ProcessEventUpgradeStart  = "upgrade-start"  // A rolling upgrade has been started (by this starter)
+	ProcessEventUpgradeFinish = "upgrade-finish" // A rolling upgrade (started by this starter) has finished or failed
+	ProcessEventUpgradePaused = "upgrade-paused" // A canary upgrade (started by this starter) waits for confirmation to proceed
+	ProcessEventPeerAdded     = "peer-added"     // A peer has joined the deployment
+	ProcessEventPeerRemoved   = "peer-removed"   // A peer has left the deployment
+	ProcessEventPeerChanged   = "peer-changed"   // The address of a peer has changed
+	ProcessEventMasterChanged = "master-changed" // Another peer has become the master
+	ProcessEventDiskSpaceLow  = "disk-space-low" // The free disk space of a directory has dropped below the minimum
+	ProcessEventDiskSpaceOK   = "disk-space-ok"  // The free disk space of a directory has recovered
+	ProcessEventClockSkew     = "clock-skew"     // The clock of a peer is skewed, relative to the clock of the master
+)
+
+// ProcessEvent is a single event of the `/events` stream.
+type ProcessEvent struct {
+	Type       string     `json:"type"`                  // One of the ProcessEvent... constants (e.g. server-up)
+	Time       time.Time  `json:"time"`                  // Time the event occurred
+	ServerType ServerType `json:"server-type,omitempty"` // Type of the server (server events only)
+	Version    string     `json:"version,omitempty"`     // Version of the server (server-up only)
+	Reason     string     `json:"reason,omitempty"`      // Reason of a failure (server-failed, server-crash, crash-loop, upgrade-finish, disk-space-low & clock-skew only)
+	PeerID     string     `json:"peer-id,omitempty"`     // ID of the peer (peer events only)
+	Address    string     `json:"address,omitempty"`     // Address of the peer (peer events only)
+	Port       int        `json:"port,omitempty"`        // Port of the starter on the peer (peer events only)
+	Path       string     `json:"path,omitempty"`        // Directory (disk space events only)
+}
+
+// AuditLogEntry holds a single mutating API call, as recorded in the audit log.
+type AuditLogEntry struct {
+	Time              time.Time `json:"time"`                         // Time the call was received
+	Operation         string    `json:"operation"`                    // Name of the operation (e.g. shutdown)
+	Method            string    `json:"method"`                       // HTTP method of the call
+	Path              string    `json:"path"`                         // Path (including query) of the call
+	RemoteAddress     string    `json:"remote-address"`               // Address the call was received from
+	ForwardedFor      string    `json:"forwarded-for,omitempty"`      // Content of the X-Forwarded-For header (if any)
+	UserAgent         string    `json:"user-agent,omitempty"`         // User agent of the caller (if any)
+	ClientCertificate string    `json:"client-certificate,omitempty"` // Common name of the TLS client certificate of the caller (if any)
+	Status            int       `json:"status"`                       // HTTP status of the response
+	Outcome           string    `json:"outcome"`                      // success | redirect | failure
+	Error             string    `json:"error,omitempty"`              // Error of a failed call
+	Duration          string    `json:"duration"`                     // Time it took to handle the call
+}
+
+// BackupInfo describes a single logical backup created by the backup scheduler of a starter.
+type BackupInfo struct {
+	Name      string    `json:"name"`                // Name of the directory of the backup
+	Started   time.Time `json:"started"`             // Time the backup was started
+	Finished  time.Time `json:"finished"`            // Time the backup has finished
+	Databases []string  `json:"databases,omitempty"` // Names of the databases in the backup
+	Success   bool      `json:"success"`             // If set, all databases have been dumped
+	Error     string    `json:"error,omitempty"`     // Reason of the failure (if any)
+}
+
+// BackupStatus is the JSON response of a `/backup` request.
+type BackupStatus struct {
+	Schedule  string       `json:"schedule,omitempty"`  // Schedule of the backups (cron expression)
+	Directory string       `json:"directory,omitempty"` // Directory holding all backups
+	Keep      int          `json:"keep,omitempty"`      // Number of backups that are kept
+	Running   bool         `json:"running,omitempty"`   // If set, a backup is being created
+	Next      *time.Time   `json:"next,omitempty"`      // Time of the next backup
+	Last      *BackupInfo  `json:"last,omitempty"`      // Last backup created by the starter (if any)
+	Backups   []BackupInfo `json:"backups,omitempty"`   // All backups in the backup directory, oldest first
+}
+
+// HotBackupCreateRequest is the JSON body of a POST `/hotbackup` request.
+type HotBackupCreateRequest struct {
+	Label             string  `json:"label,omitempty"`             // Label added to the ID of the backup
+	Timeout           float64 `json:"timeout,omitempty"`           // Time (in seconds) to wait for the global lock
+	AllowInconsistent bool    `json:"allowInconsistent,omitempty"` // If set, a backup is created even if the global lock cannot be obtained
+}
+
+// HotBackupTransferRequest is the JSON body of a POST `/hotbackup/upload` or `/hotbackup/download` request.
+type HotBackupTransferRequest struct {
+	ID               string                 `json:"id"`               // ID of the hot backup to transfer
+	RemoteRepository string                 `json:"remoteRepository"` // Remote repository (e.g. S3://bucket/path)
+	Config           map[string]interface{} `json:"config"`           // Configuration of the remote repository (rclone)
+}
+
+// HotBackup holds the information of a single hot backup, as listed by the deployment.
+type HotBackup struct {
+	ID                      string `json:"id"`
+	Version                 string `json:"version,omitempty"`
+	DateTime                string `json:"datetime,omitempty"`
+	SizeInBytes             int64  `json:"sizeInBytes,omitempty"`
+	NrDBServers             int    `json:"nrDBServers,omitempty"`
+	NrFiles                 int    `json:"nrFiles,omitempty"`
+	Available               bool   `json:"available"`
+	PotentiallyInconsistent bool   `json:"potentiallyInconsistent,omitempty"`
+}
+
+// HotBackupStatus is the JSON response of a `/hotbackup` request.
+type HotBackupStatus struct {
+	Operation  string            `json:"operation,omitempty"`   // create | upload | download
+	Running    bool              `json:"running,omitempty"`     // If set, the operation is in progress
+	Ready      bool              `json:"ready,omitempty"`       // If set, the operation has finished successfully
+	Failed     bool              `json:"failed,omitempty"`      // If set, the operation has failed
+	Reason     string            `json:"reason,omitempty"`      // Reason of the failure (if any)
+	ID         string            `json:"id,omitempty"`          // ID of the hot backup
+	TransferID string            `json:"transfer-id,omitempty"` // ID of the upload/download job
+	Progress   map[string]string `json:"progress,omitempty"`    // Status of the upload/download per dbserver
+	Started    *time.Time        `json:"started,omitempty"`     // Time the operation was started
+	Finished   *time.Time        `json:"finished,omitempty"`    // Time the operation has finished
+	Backups    []HotBackup       `json:"backups,omitempty"`     // All hot backups of the deployment (GET only)
+	ListError  string            `json:"list-error,omitempty"`  // Reason the hot backups could not be listed (if any)
+}
+
+const (
+	OutputStreamStdout = "stdout" // Standard output of a server
+	OutputStreamStderr = "stderr" // Standard error of a server
+)
+
+// LogLevelRequest is the JSON body of a PUT `/logs/level` request.
+type LogLevelRequest struct {
+	Starter string            `json:"starter,omitempty"` // New log levels of the starter, using the syntax of `--log.level`
+	Servers map[string]string `json:"servers,omitempty"` // New log levels (per topic) of all servers started by the starter
+}
+
+// LogLevels is the JSON response of a `/logs/level` request.
+type LogLevels struct {
+	Starter map[string]string                `json:"starter"`           // Log level per component of the starter
+	Servers map[ServerType]map[string]string `json:"servers,omitempty"` // Log level per topic, per server type
+	Errors  map[ServerType]string            `json:"errors,omitempty"`  // Errors per server type
+}
+
+// LogRotation is the JSON response of a POST `/logs/rotate` request.
+type LogRotation struct {
+	Rotated  []ServerType          `json:"rotated,omitempty"`  // Servers whose log file has been rotated by the starter
+	Reopened []ServerType          `json:"reopened,omitempty"` // Servers that have been asked to reopen their log file
+	Errors   map[ServerType]string `json:"errors,omitempty"`   // Errors per server type
+}
+
+// ShutdownOptions holds the options of a `/shutdown` request.
+type ShutdownOptions struct {
+	Goodbye        bool   // If set, the starter will remove its peer slot at the master
+	RemoveData     bool   // If set, the starter will remove all its data after its servers have stopped
+	IdempotencyKey string // If set, a retry of the request with the same key does not initiate another shutdown
 }
 
 // VersionInfo is the JSON response of a `/version` request.
@@ -49,6 +317,224 @@ type ProcessList struct {
 	Servers        []ServerProcess `json:"servers,omitempty"`         // List of servers started by the starter
 }
 
+// ServerCommandLine is the JSON response of a `/processes/<server-type>/commandline` request.
+type ServerCommandLine struct {
+	Type      ServerType        `json:"type"`                 // agent | coordinator | dbserver | single
+	Args      []string          `json:"args"`                 // Exact argv of the server (inside its container when using docker)
+	Env       map[string]string `json:"env,omitempty"`        // Environment variables set in addition to those of the starter (process) or image (docker)
+	DockerRun []string          `json:"docker-run,omitempty"` // Equivalent `docker run` command line (docker only)
+	Started   time.Time         `json:"started"`              // Time the server was started with this command line
+}
+
+// ConfigInfo is the JSON response of a `/config` request.
+type ConfigInfo struct {
+	ConfigFile string         `json:"config-file,omitempty"` // Path of the configuration file that has been loaded (if any)
+	Options    []ConfigOption `json:"options"`               // All options of the starter, sorted by name
+}
+
+// ConfigOption holds the effective value of a single option of the starter.
+type ConfigOption struct {
+	Name            string `json:"name"`                       // Name of the option (without leading dashes)
+	Value           string `json:"value"`                      // Effective value of the option
+	Default         string `json:"default,omitempty"`          // Default value of the option
+	Source          string `json:"source"`                     // command-line | config-file | default
+	Redacted        bool   `json:"redacted,omitempty"`         // If set, (part of) the value has been hidden, because it holds a secret
+	Passthrough     bool   `json:"passthrough,omitempty"`      // If set, the option is passed through to the servers
+	RestartRequired bool   `json:"restart-required,omitempty"` // If set, a change of the option only takes effect after a restart
+}
+
+// StatusInfo is the JSON response of a `/status` request.
+type StatusInfo struct {
+	ID      string         `json:"id"`                // Unique ID of the starter
+	Mode    string         `json:"mode"`              // Starter mode (cluster | single)
+	Version string         `json:"version"`           // Version of the starter
+	Build   string         `json:"build"`             // Build of the starter
+	Servers []ServerStatus `json:"servers,omitempty"` // Servers started by the starter
+	Peers   []PeerStatus   `json:"peers,omitempty"`   // All peers known by the starter
+	Startup []StartupPhase `json:"startup,omitempty"` // Phases of the startup of the starter
+}
+
+// StartupPhase holds the progress of a single phase of the startup of a starter.
+type StartupPhase struct {
+	Name     string `json:"name"`               // Name of the phase (e.g. agency-ready)
+	Done     bool   `json:"done,omitempty"`     // If set, the phase has finished
+	Duration string `json:"duration,omitempty"` // Time between the end of the previous phase and the end of this phase
+	Elapsed  string `json:"elapsed,omitempty"`  // Time between the start of the starter and the end of this phase
+}
+
+// ServerStatus holds the runtime status of a single server started by the starter.
+type ServerStatus struct {
+	ServerProcess
+	Up         bool   `json:"up"`                    // If set, the server is responding to requests
+	Version    string `json:"version,omitempty"`     // Version of the server (if known)
+	Restarts   int    `json:"restarts,omitempty"`    // Number of times the server has been restarted
+	Failed     bool   `json:"failed,omitempty"`      // If set, the server has failed and is no longer restarted (see `--server.restart-policy`)
+	FailReason string `json:"fail-reason,omitempty"` // Reason the server has failed
+}
+
+// HealthResponse is the JSON response of a `/health` request.
+type HealthResponse struct {
+	Status    string           `json:"status"`              // ok | degraded | failed
+	Servers   []ServerHealth   `json:"servers,omitempty"`   // Health of every server started by the starter
+	License   *LicenseHealth   `json:"license,omitempty"`   // Health of the license key passed to the servers (if any)
+	Disks     []DiskHealth     `json:"disks,omitempty"`     // Free disk space of the directories used by the servers (if monitored)
+	Bootstrap *BootstrapHealth `json:"bootstrap,omitempty"` // State of the bootstrap tasks of a new deployment (if any, on the master)
+}
+
+// BootstrapHealth holds the state of the bootstrap tasks (manifest, Foxx services, scripts, data import) of a new deployment.
+type BootstrapHealth struct {
+	Status string          `json:"status"`           // running | done | failed
+	Reason string          `json:"reason,omitempty"` // Reason of a failed status
+	Import *ImportProgress `json:"import,omitempty"` // Progress of the initial data import (if any)
+}
+
+// ImportProgress holds the progress of the initial data import.
+type ImportProgress struct {
+	Total    int      `json:"total"`             // Number of files to import
+	Imported int      `json:"imported"`          // Number of files that have been imported
+	Running  []string `json:"running,omitempty"` // Files that are being imported
+}
+
+// DiskHealth holds the free disk space of a directory used by the servers.
+type DiskHealth struct {
+	Path    string `json:"path"`     // Directory (the data directory or a --<type>s.dir)
+	Status  string `json:"status"`   // ok | low
+	Free    uint64 `json:"free"`     // Free disk space in bytes
+	MinFree uint64 `json:"min-free"` // Minimum free disk space in bytes (--disk.min-free)
+}
+
+// LicenseHealth holds the health of the license key passed to the servers.
+type LicenseHealth struct {
+	Status  string     `json:"status"`            // ok | expiring | expired | unknown
+	Expires *time.Time `json:"expires,omitempty"` // Time the license expires (if known)
+}
+
+// ServerHealth holds the health of a single server started by the starter.
+type ServerHealth struct {
+	Type     string `json:"type"`               // agent | coordinator | dbserver | single
+	Status   string `json:"status"`             // ok | down | degraded | failed
+	Restarts int    `json:"restarts,omitempty"` // Number of restarts within the crash loop window
+	Reason   string `json:"reason,omitempty"`   // Reason of a degraded or failed status
+}
+
+// PeerStatus holds the information of a single peer known by the starter.
+type PeerStatus struct {
+	ID        string `json:"id"`                   // Unique ID of the peer
+	Address   string `json:"address"`              // IP address of the peer
+	Port      int    `json:"port"`                 // Port of the starter on the peer
+	HasAgent  bool   `json:"has-agent,omitempty"`  // If set, the peer is running an agent
+	IsMaster  bool   `json:"is-master,omitempty"`  // If set, the peer is the master
+	IsSecure  bool   `json:"is-secure,omitempty"`  // If set, servers started by the peer are using an SSL connection
+	IsStandby bool   `json:"is-standby,omitempty"` // If set, the peer is a standby
+	IsPassive bool   `json:"is-passive,omitempty"` // If set, the peer is passive, it never runs servers
+}
+
+// UpgradeOptions holds the options of a POST `/upgrade` request.
+type UpgradeOptions struct {
+	Canary        bool   `json:"canary,omitempty"`         // If set, the upgrade pauses for confirmation after upgrading one dbserver & one coordinator
+	DockerImage   string `json:"docker-image,omitempty"`   // Docker image to upgrade the servers to (when running servers in docker)
+	ArangodPath   string `json:"arangod,omitempty"`        // Path of the arangod executable to upgrade the servers to (when running servers as processes)
+	JSPath        string `json:"js-dir,omitempty"`         // Path of the JS startup directory of that arangod executable
+	TargetVersion string `json:"target-version,omitempty"` // Version the servers are upgraded to. If empty, it is detected from the binary
+	Force         bool   `json:"force,omitempty"`          // If set, the upgrade starts even when the checks before the upgrade fail
+}
+
+// UpgradeStatus is the JSON response of a `/upgrade` request.
+type UpgradeStatus struct {
+	Running bool           `json:"running,omitempty"` // If set, the upgrade is in progress
+	Ready   bool           `json:"ready,omitempty"`   // If set, the upgrade has finished successfully
+	Failed  bool           `json:"failed,omitempty"`  // If set, the upgrade has failed
+	Reason  string         `json:"reason,omitempty"`  // Reason of the failure (if any)
+	Canary  bool           `json:"canary,omitempty"`  // If set, this is a canary upgrade
+	Paused  bool           `json:"paused,omitempty"`  // If set, the canary phase has passed and the upgrade waits for confirmation
+	Checks  []UpgradeCheck `json:"checks,omitempty"`  // Results of the checks before the upgrade and after the canary phase
+	Steps   []UpgradeStep  `json:"steps,omitempty"`   // All steps of the upgrade, in order of execution
+}
+
+// UpgradeCheck holds the result of a single check run after the canary phase of an upgrade.
+type UpgradeCheck struct {
+	Name    string `json:"name"`              // version-jump | deprecated-options | storage-engine | replication-factor | canary-servers | deployment-health | canary-check
+	Passed  bool   `json:"passed"`            // If set, the check has passed
+	Message string `json:"message,omitempty"` // Details of the result (if any)
+}
+
+// Operation is the JSON response of a GET `/operations/<id>` request.
+type Operation struct {
+	ID             string          `json:"id"`                        // Unique ID of the operation
+	Type           string          `json:"type"`                      // upgrade | shutdown | hotbackup-create | hotbackup-upload | hotbackup-download
+	State          string          `json:"state"`                     // running | succeeded | failed
+	IdempotencyKey string          `json:"idempotency-key,omitempty"` // Idempotency-Key of the request that started the operation (if any)
+	Progress       string          `json:"progress,omitempty"`        // Human readable progress of a running operation
+	Result         json.RawMessage `json:"result,omitempty"`          // Result of the operation (e.g. the upgrade status)
+	Error          string          `json:"error,omitempty"`           // Reason of the failure (if any)
+	Started        time.Time       `json:"started"`                   // Time the operation was started
+	Finished       *time.Time      `json:"finished,omitempty"`        // Time the operation has finished
+}
+
+// UpgradeStep holds the upgrade of a single server.
+type UpgradeStep struct {
+	PeerID     string     `json:"peer-id"`           // ID of the peer running the server
+	ServerType ServerType `json:"server-type"`       // Type of the server
+	State      string     `json:"state"`             // pending | running | done | skipped | failed | rolled-back
+	Message    string     `json:"message,omitempty"` // Details of the state (if any)
+	Canary     bool       `json:"canary,omitempty"`  // If set, the server is upgraded in the canary phase
+	Logs       []string   `json:"logs,omitempty"`    // Most recent log lines of a server that failed to upgrade
+}
+
+// ReplaceDBServerRequest is the JSON body of a POST `/dbserver/replace` request.
+type ReplaceDBServerRequest struct {
+	From   string `json:"from"`              // Cluster ID of the failed dbserver (e.g. PRMR-1234)
+	PeerID string `json:"peer-id,omitempty"` // ID of the peer to start the new dbserver on. If empty, the first peer without a dbserver is used.
+}
+
+// ReplaceDBServerStatus is the JSON response of a `/dbserver/replace` request.
+type ReplaceDBServerStatus struct {
+	Running       bool   `json:"running,omitempty"`        // If set, the replacement is in progress
+	Ready         bool   `json:"ready,omitempty"`          // If set, the replacement has finished successfully
+	Failed        bool   `json:"failed,omitempty"`         // If set, the replacement has failed
+	Reason        string `json:"reason,omitempty"`         // Reason of the failure (if any)
+	From          string `json:"from,omitempty"`           // Cluster ID of the failed dbserver
+	PeerID        string `json:"peer-id,omitempty"`        // ID of the peer running the new dbserver
+	NewServerID   string `json:"new-server-id,omitempty"`  // Cluster ID of the new dbserver (once it has joined)
+	Phase         string `json:"phase,omitempty"`          // start-dbserver | resync | remove-server
+	PendingShards int    `json:"pending-shards,omitempty"` // Number of shards that still use the failed dbserver or are not in sync
+}
+
+// MoveDataRequest is the JSON body of a POST `/data/move` request.
+type MoveDataRequest struct {
+	NewDir string `json:"new-dir"`        // New data directory of the starter (absolute path)
+	Copy   bool   `json:"copy,omitempty"` // If set, the data is copied and the old data directory is left untouched
+}
+
+// MoveDataStatus is the JSON response of a `/data/move` request.
+type MoveDataStatus struct {
+	Running bool   `json:"running,omitempty"` // If set, the data directory is being moved
+	Ready   bool   `json:"ready,omitempty"`   // If set, the data directory has been moved successfully
+	Failed  bool   `json:"failed,omitempty"`  // If set, moving the data directory has failed
+	Reason  string `json:"reason,omitempty"`  // Reason of the failure (if any)
+	OldDir  string `json:"old-dir,omitempty"` // Data directory before the move
+	NewDir  string `json:"new-dir,omitempty"` // Data directory after the move
+	Copy    bool   `json:"copy,omitempty"`    // If set, the data is copied instead of moved
+	Phase   string `json:"phase,omitempty"`   // stop-servers | move | start-servers
+}
+
+// LocalSlavesRequest is the JSON body of a POST `/local-slaves` request.
+type LocalSlavesRequest struct {
+	Count int `json:"count"` // Number of local slaves to run (besides the starter itself)
+}
+
+// LocalSlavesStatus is the JSON response of a `/local-slaves` request.
+type LocalSlavesStatus struct {
+	Running       bool   `json:"running,omitempty"`        // If set, local slaves are being added or retired
+	Ready         bool   `json:"ready,omitempty"`          // If set, the local slaves have been scaled successfully
+	Failed        bool   `json:"failed,omitempty"`         // If set, scaling the local slaves has failed
+	Reason        string `json:"reason,omitempty"`         // Reason of the failure (if any)
+	Count         int    `json:"count"`                    // Number of local slaves that are currently running
+	Target        int    `json:"target,omitempty"`         // Number of local slaves requested
+	Retiring      string `json:"retiring,omitempty"`       // ID of the local slave that is being retired
+	PendingShards int    `json:"pending-shards,omitempty"` // Number of shards that still have to be moved off the retiring local slave
+}
+
 // ServerType holds a type of (arangod) server
 type ServerType string
 
@@ -68,6 +554,10 @@ type ServerProcess struct {
 	ContainerID string     `json:"container-id,omitempty"` // ID of docker container running the server
 	ContainerIP string     `json:"container-ip,omitempty"` // IP address of docker container running the server
 	IsSecure    bool       `json:"is-secure,omitempty"`    // If set, this server is using an SSL connection
+	CPUPercent  float64    `json:"cpu-percent,omitempty"`  // CPU usage of the server (100 = 1 core)
+	RSS         uint64     `json:"rss,omitempty"`          // Resident set size (in bytes) of the server
+	OpenFiles   int        `json:"open-files,omitempty"`   // Number of open file descriptors of the server (not available in docker)
+	DiskUsage   int64      `json:"disk-usage,omitempty"`   // Size (in bytes) of the directory of the server
 }
 
 // ServerByType returns the server of given type.
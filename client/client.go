@@ -23,22 +23,45 @@
 package client
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"net/url"
+	"strconv"
 
 	"github.com/pkg/errors"
 )
 
 // NewArangoStarterClient creates a new client implementation.
-func NewArangoStarterClient(endpoint url.URL) (API, error) {
+// The endpoint is an HTTP(S) URL (e.g. `http://localhost:8528`) or the URL of
+// a unix socket (e.g. `unix:///run/arangodb/starter.sock`).
+// Use options (e.g. WithTLSConfig) to configure the HTTP client used for requests.
+func NewArangoStarterClient(endpoint url.URL, options ...ClientOption) (API, error) {
+	var opts clientOptions
+	for _, o := range options {
+		o(&opts)
+	}
+	defaultClient := DefaultHTTPClient
+	if endpoint.Scheme == "unix" {
+		if endpoint.Path == "" {
+			return nil, maskAny(fmt.Errorf("Missing path of unix socket in endpoint '%s'", endpoint.String()))
+		}
+		socketPath := endpoint.Path
+		defaultClient = func() *http.Client { return UnixSocketHTTPClient(socketPath) }
+		endpoint = url.URL{Scheme: "http", Host: "localhost"}
+	}
+	httpClient, err := opts.createHTTPClient(defaultClient)
+	if err != nil {
+		return nil, maskAny(err)
+	}
 	endpoint.Path = ""
 	return &client{
 		endpoint: endpoint,
-		client:   DefaultHTTPClient(),
+		client:   httpClient,
 	}, nil
 }
 
@@ -76,7 +99,18 @@ func (c *client) Version(ctx context.Context) (VersionInfo, error) {
 
 // Processes loads information of all the server processes launched by a specific arangodb.
 func (c *client) Processes(ctx context.Context) (ProcessList, error) {
-	url := c.createURL("/process", nil)
+	return c.processes(ctx, "/process")
+}
+
+// PeerProcesses loads information of all the server processes launched by the starter of the peer
+// with given ID, through the starter this client is connected to.
+func (c *client) PeerProcesses(ctx context.Context, peerID string) (ProcessList, error) {
+	return c.processes(ctx, "/peers/"+peerID+"/processes")
+}
+
+// processes loads a process list from the given path.
+func (c *client) processes(ctx context.Context, path string) (ProcessList, error) {
+	url := c.createURL(path, nil)
 
 	var result ProcessList
 	req, err := http.NewRequest("GET", url, nil)
@@ -97,15 +131,263 @@ func (c *client) Processes(ctx context.Context) (ProcessList, error) {
 	return result, nil
 }
 
+// CommandLine loads the command line the starter used to launch the server of given type.
+func (c *client) CommandLine(ctx context.Context, serverType ServerType) (ServerCommandLine, error) {
+	url := c.createURL("/processes/"+string(serverType)+"/commandline", nil)
+
+	var result ServerCommandLine
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return ServerCommandLine{}, maskAny(err)
+	}
+	if ctx != nil {
+		req = req.WithContext(ctx)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return ServerCommandLine{}, maskAny(err)
+	}
+	if err := c.handleResponse(resp, "GET", url, &result); err != nil {
+		return ServerCommandLine{}, maskAny(err)
+	}
+
+	return result, nil
+}
+
+// Config loads the effective configuration of the starter (with secrets redacted).
+func (c *client) Config(ctx context.Context) (ConfigInfo, error) {
+	url := c.createURL("/config", nil)
+
+	var result ConfigInfo
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return ConfigInfo{}, maskAny(err)
+	}
+	if ctx != nil {
+		req = req.WithContext(ctx)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return ConfigInfo{}, maskAny(err)
+	}
+	if err := c.handleResponse(resp, "GET", url, &result); err != nil {
+		return ConfigInfo{}, maskAny(err)
+	}
+
+	return result, nil
+}
+
+// Status loads the status of the starter, its servers and its peers.
+func (c *client) Status(ctx context.Context) (StatusInfo, error) {
+	url := c.createURL("/status", nil)
+
+	var result StatusInfo
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return StatusInfo{}, maskAny(err)
+	}
+	if ctx != nil {
+		req = req.WithContext(ctx)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return StatusInfo{}, maskAny(err)
+	}
+	if err := c.handleResponse(resp, "GET", url, &result); err != nil {
+		return StatusInfo{}, maskAny(err)
+	}
+
+	return result, nil
+}
+
 // Shutdown will shutdown a starter (and all its started servers).
 // With goodbye set, it will remove the peer slot for the starter.
 func (c *client) Shutdown(ctx context.Context, goodbye bool) error {
+	return maskAny(c.ShutdownWithOptions(ctx, ShutdownOptions{Goodbye: goodbye}))
+}
+
+// ShutdownWithOptions will shutdown a starter (and all its started servers)
+// using the given options.
+func (c *client) ShutdownWithOptions(ctx context.Context, options ShutdownOptions) error {
 	q := url.Values{}
-	if goodbye {
+	if options.Goodbye {
 		q.Set("mode", "goodbye")
 	}
+	if options.RemoveData {
+		q.Set("remove-data", "true")
+	}
 	url := c.createURL("/shutdown", q)
 
+	req, err := http.NewRequest("POST", url, nil)
+	if err != nil {
+		return maskAny(err)
+	}
+	if options.IdempotencyKey != "" {
+		req.Header.Set("Idempotency-Key", options.IdempotencyKey)
+	}
+	if ctx != nil {
+		req = req.WithContext(ctx)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return maskAny(err)
+	}
+	if err := c.handleResponse(resp, "POST", url, nil); err != nil {
+		return maskAny(err)
+	}
+
+	return nil
+}
+
+// StartUpgrade starts a rolling upgrade of all servers of the deployment.
+func (c *client) StartUpgrade(ctx context.Context) (UpgradeStatus, error) {
+	result, err := c.upgrade(ctx, "POST", "/upgrade", nil)
+	if err != nil {
+		return UpgradeStatus{}, maskAny(err)
+	}
+	return result, nil
+}
+
+// StartUpgradeWithOptions starts a rolling upgrade of all servers of the deployment
+// using the given options.
+func (c *client) StartUpgradeWithOptions(ctx context.Context, options UpgradeOptions) (UpgradeStatus, error) {
+	body, err := json.Marshal(options)
+	if err != nil {
+		return UpgradeStatus{}, maskAny(err)
+	}
+	result, err := c.upgrade(ctx, "POST", "/upgrade", body)
+	if err != nil {
+		return UpgradeStatus{}, maskAny(err)
+	}
+	return result, nil
+}
+
+// UpgradeStatus loads the status of the current (or last) rolling upgrade.
+func (c *client) UpgradeStatus(ctx context.Context) (UpgradeStatus, error) {
+	result, err := c.upgrade(ctx, "GET", "/upgrade", nil)
+	if err != nil {
+		return UpgradeStatus{}, maskAny(err)
+	}
+	return result, nil
+}
+
+// ResumeUpgrade proceeds with a canary upgrade that waits for confirmation after its canary phase.
+func (c *client) ResumeUpgrade(ctx context.Context) (UpgradeStatus, error) {
+	result, err := c.upgrade(ctx, "POST", "/upgrade/resume", nil)
+	if err != nil {
+		return UpgradeStatus{}, maskAny(err)
+	}
+	return result, nil
+}
+
+// AbortUpgrade stops a canary upgrade that waits for confirmation after its canary phase.
+func (c *client) AbortUpgrade(ctx context.Context) (UpgradeStatus, error) {
+	result, err := c.upgrade(ctx, "POST", "/upgrade/abort", nil)
+	if err != nil {
+		return UpgradeStatus{}, maskAny(err)
+	}
+	return result, nil
+}
+
+// Operation loads the state of the long-running operation (e.g. rolling upgrade, shutdown or hot backup)
+// with given ID, as returned in the X-Arangodb-Operation-Id header of the request that started it.
+func (c *client) Operation(ctx context.Context, id string) (Operation, error) {
+	url := c.createURL("/operations/"+id, nil)
+
+	var result Operation
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return Operation{}, maskAny(err)
+	}
+	if ctx != nil {
+		req = req.WithContext(ctx)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return Operation{}, maskAny(err)
+	}
+	if err := c.handleResponse(resp, "GET", url, &result); err != nil {
+		return Operation{}, maskAny(err)
+	}
+
+	return result, nil
+}
+
+// ReplaceDBServer starts the replacement of a failed dbserver by a fresh dbserver on another peer.
+func (c *client) ReplaceDBServer(ctx context.Context, req ReplaceDBServerRequest) (ReplaceDBServerStatus, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return ReplaceDBServerStatus{}, maskAny(err)
+	}
+	result, err := c.replaceDBServer(ctx, "POST", body)
+	if err != nil {
+		return ReplaceDBServerStatus{}, maskAny(err)
+	}
+	return result, nil
+}
+
+// ReplaceDBServerStatus loads the status of the current (or last) replacement of a failed dbserver.
+func (c *client) ReplaceDBServerStatus(ctx context.Context) (ReplaceDBServerStatus, error) {
+	result, err := c.replaceDBServer(ctx, "GET", nil)
+	if err != nil {
+		return ReplaceDBServerStatus{}, maskAny(err)
+	}
+	return result, nil
+}
+
+// LogLevels loads the log levels of the starter and the servers started by it.
+func (c *client) LogLevels(ctx context.Context) (LogLevels, error) {
+	result, err := c.logLevels(ctx, "GET", nil)
+	if err != nil {
+		return LogLevels{}, maskAny(err)
+	}
+	return result, nil
+}
+
+// SetLogLevels changes the log levels of the starter and/or the servers started by it.
+func (c *client) SetLogLevels(ctx context.Context, req LogLevelRequest) (LogLevels, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return LogLevels{}, maskAny(err)
+	}
+	result, err := c.logLevels(ctx, "PUT", body)
+	if err != nil {
+		return LogLevels{}, maskAny(err)
+	}
+	return result, nil
+}
+
+// RotateLogs rotates the log files of the servers started by the starter, or asks these servers
+// to reopen their log files when the starter does not rotate them itself.
+func (c *client) RotateLogs(ctx context.Context) (LogRotation, error) {
+	url := c.createURL("/logs/rotate", nil)
+
+	var result LogRotation
+	req, err := http.NewRequest("POST", url, nil)
+	if err != nil {
+		return LogRotation{}, maskAny(err)
+	}
+	if ctx != nil {
+		req = req.WithContext(ctx)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return LogRotation{}, maskAny(err)
+	}
+	if err := c.handleResponse(resp, "POST", url, &result); err != nil {
+		return LogRotation{}, maskAny(err)
+	}
+
+	return result, nil
+}
+
+// RestartServer restarts the server of given type, started by the starter, regardless of the restart policy.
+// It returns once the server is up again.
+func (c *client) RestartServer(ctx context.Context, serverType ServerType) error {
+	q := url.Values{}
+	q.Set("type", string(serverType))
+	url := c.createURL("/server/restart", q)
+
 	req, err := http.NewRequest("POST", url, nil)
 	if err != nil {
 		return maskAny(err)
@@ -124,6 +406,470 @@ func (c *client) Shutdown(ctx context.Context, goodbye bool) error {
 	return nil
 }
 
+// Diagnostics creates a diagnostics bundle (tar.gz) of the starter and writes it to the given writer.
+func (c *client) Diagnostics(ctx context.Context, w io.Writer) error {
+	url := c.createURL("/diagnostics", nil)
+
+	req, err := http.NewRequest("POST", url, nil)
+	if err != nil {
+		return maskAny(err)
+	}
+	if ctx != nil {
+		req = req.WithContext(ctx)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return maskAny(err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return maskAny(c.handleResponse(resp, "POST", url, nil))
+	}
+	defer resp.Body.Close()
+	if _, err := io.Copy(w, resp.Body); err != nil {
+		return maskAny(errors.Wrapf(err, "Failed reading response data from POST request to %s: %v", url, err))
+	}
+
+	return nil
+}
+
+// ServerLog writes the log of the server of given type, started by the starter, to the given writer.
+// With stream set to OutputStreamStdout or OutputStreamStderr, the most recently captured
+// output of the server is written instead.
+func (c *client) ServerLog(ctx context.Context, serverType ServerType, stream string, w io.Writer) error {
+	q := url.Values{}
+	if stream != "" {
+		q.Set("stream", stream)
+	}
+	url := c.createURL("/logs/"+string(serverType), q)
+
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return maskAny(err)
+	}
+	if ctx != nil {
+		req = req.WithContext(ctx)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return maskAny(err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return maskAny(c.handleResponse(resp, "GET", url, nil))
+	}
+	defer resp.Body.Close()
+	if _, err := io.Copy(w, resp.Body); err != nil {
+		return maskAny(errors.Wrapf(err, "Failed reading response data from GET request to %s: %v", url, err))
+	}
+
+	return nil
+}
+
+// AgencyDump loads the entire content of the agency, from the agent started by the starter.
+func (c *client) AgencyDump(ctx context.Context) (json.RawMessage, error) {
+	result, err := c.AgencyDumpWithPath(ctx, "")
+	if err != nil {
+		return nil, maskAny(err)
+	}
+	return result, nil
+}
+
+// AgencyDumpWithPath loads the content of the agency at the given path prefix (e.g. /arango/Plan),
+// from the agent started by the starter.
+func (c *client) AgencyDumpWithPath(ctx context.Context, path string) (json.RawMessage, error) {
+	var q url.Values
+	if path != "" {
+		q = url.Values{}
+		q.Set("path", path)
+	}
+	url := c.createURL("/agency/dump", q)
+
+	var result json.RawMessage
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, maskAny(err)
+	}
+	if ctx != nil {
+		req = req.WithContext(ctx)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, maskAny(err)
+	}
+	if err := c.handleResponse(resp, "GET", url, &result); err != nil {
+		return nil, maskAny(err)
+	}
+
+	return result, nil
+}
+
+// BackupStatus loads the status of the scheduled backups of the starter, including all existing backups.
+func (c *client) BackupStatus(ctx context.Context) (BackupStatus, error) {
+	url := c.createURL("/backup", nil)
+
+	var result BackupStatus
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return BackupStatus{}, maskAny(err)
+	}
+	if ctx != nil {
+		req = req.WithContext(ctx)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return BackupStatus{}, maskAny(err)
+	}
+	if err := c.handleResponse(resp, "GET", url, &result); err != nil {
+		return BackupStatus{}, maskAny(err)
+	}
+
+	return result, nil
+}
+
+// AuditLog loads the most recent entries (all entries if limit <= 0) of the audit log of the starter.
+func (c *client) AuditLog(ctx context.Context, limit int) ([]AuditLogEntry, error) {
+	var q url.Values
+	if limit > 0 {
+		q = url.Values{}
+		q.Set("limit", strconv.Itoa(limit))
+	}
+	url := c.createURL("/auditlog", q)
+
+	var result []AuditLogEntry
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, maskAny(err)
+	}
+	if ctx != nil {
+		req = req.WithContext(ctx)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, maskAny(err)
+	}
+	if err := c.handleResponse(resp, "GET", url, &result); err != nil {
+		return nil, maskAny(err)
+	}
+
+	return result, nil
+}
+
+// Watch subscribes to the event stream of the starter.
+// The channel is closed when the given context is cancelled or the stream ends.
+func (c *client) Watch(ctx context.Context) (<-chan ProcessEvent, error) {
+	url := c.createURL("/events", nil)
+
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, maskAny(err)
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	req = req.WithContext(ctx)
+	// The stream stays open for as long as the context, so the request timeout does not apply
+	streamClient := *c.client
+	streamClient.Timeout = 0
+	resp, err := streamClient.Do(req)
+	if err != nil {
+		return nil, maskAny(err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, maskAny(c.handleResponse(resp, "GET", url, nil))
+	}
+
+	events := make(chan ProcessEvent)
+	go func() {
+		defer close(events)
+		defer resp.Body.Close()
+		decoder := json.NewDecoder(resp.Body)
+		for {
+			var e ProcessEvent
+			if err := decoder.Decode(&e); err != nil {
+				return
+			}
+			select {
+			case events <- e:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return events, nil
+}
+
+// logLevels performs a `/logs/level` request with given method & body.
+func (c *client) logLevels(ctx context.Context, method string, body []byte) (LogLevels, error) {
+	url := c.createURL("/logs/level", nil)
+
+	var result LogLevels
+	req, err := http.NewRequest(method, url, bytes.NewReader(body))
+	if err != nil {
+		return LogLevels{}, maskAny(err)
+	}
+	if ctx != nil {
+		req = req.WithContext(ctx)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return LogLevels{}, maskAny(err)
+	}
+	if err := c.handleResponse(resp, method, url, &result); err != nil {
+		return LogLevels{}, maskAny(err)
+	}
+
+	return result, nil
+}
+
+// upgrade performs an upgrade request (`/upgrade` or one of its sub paths) with given method & body.
+func (c *client) upgrade(ctx context.Context, method, path string, body []byte) (UpgradeStatus, error) {
+	url := c.createURL(path, nil)
+
+	var result UpgradeStatus
+	req, err := http.NewRequest(method, url, bytes.NewReader(body))
+	if err != nil {
+		return UpgradeStatus{}, maskAny(err)
+	}
+	if ctx != nil {
+		req = req.WithContext(ctx)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return UpgradeStatus{}, maskAny(err)
+	}
+	if err := c.handleResponse(resp, method, url, &result); err != nil {
+		return UpgradeStatus{}, maskAny(err)
+	}
+
+	return result, nil
+}
+
+// replaceDBServer performs a `/dbserver/replace` request with given method & body.
+func (c *client) replaceDBServer(ctx context.Context, method string, body []byte) (ReplaceDBServerStatus, error) {
+	url := c.createURL("/dbserver/replace", nil)
+
+	var result ReplaceDBServerStatus
+	req, err := http.NewRequest(method, url, bytes.NewReader(body))
+	if err != nil {
+		return ReplaceDBServerStatus{}, maskAny(err)
+	}
+	if ctx != nil {
+		req = req.WithContext(ctx)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return ReplaceDBServerStatus{}, maskAny(err)
+	}
+	if err := c.handleResponse(resp, method, url, &result); err != nil {
+		return ReplaceDBServerStatus{}, maskAny(err)
+	}
+
+	return result, nil
+}
+
+// HotBackupStatus loads the status of the current (or last) hot backup operation of the starter,
+// including all hot backups of the deployment.
+func (c *client) HotBackupStatus(ctx context.Context) (HotBackupStatus, error) {
+	result, err := c.hotBackup(ctx, "GET", "/hotbackup", nil)
+	if err != nil {
+		return HotBackupStatus{}, maskAny(err)
+	}
+	return result, nil
+}
+
+// CreateHotBackup starts the creation of a hot backup of the deployment.
+func (c *client) CreateHotBackup(ctx context.Context, req HotBackupCreateRequest) (HotBackupStatus, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return HotBackupStatus{}, maskAny(err)
+	}
+	result, err := c.hotBackup(ctx, "POST", "/hotbackup", body)
+	if err != nil {
+		return HotBackupStatus{}, maskAny(err)
+	}
+	return result, nil
+}
+
+// UploadHotBackup starts the upload of a hot backup to a remote repository.
+func (c *client) UploadHotBackup(ctx context.Context, req HotBackupTransferRequest) (HotBackupStatus, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return HotBackupStatus{}, maskAny(err)
+	}
+	result, err := c.hotBackup(ctx, "POST", "/hotbackup/upload", body)
+	if err != nil {
+		return HotBackupStatus{}, maskAny(err)
+	}
+	return result, nil
+}
+
+// DownloadHotBackup starts the download of a hot backup from a remote repository.
+func (c *client) DownloadHotBackup(ctx context.Context, req HotBackupTransferRequest) (HotBackupStatus, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return HotBackupStatus{}, maskAny(err)
+	}
+	result, err := c.hotBackup(ctx, "POST", "/hotbackup/download", body)
+	if err != nil {
+		return HotBackupStatus{}, maskAny(err)
+	}
+	return result, nil
+}
+
+// hotBackup performs a request with given method & body to the given hot backup path.
+func (c *client) hotBackup(ctx context.Context, method, path string, body []byte) (HotBackupStatus, error) {
+	url := c.createURL(path, nil)
+
+	var result HotBackupStatus
+	req, err := http.NewRequest(method, url, bytes.NewReader(body))
+	if err != nil {
+		return HotBackupStatus{}, maskAny(err)
+	}
+	if ctx != nil {
+		req = req.WithContext(ctx)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return HotBackupStatus{}, maskAny(err)
+	}
+	if err := c.handleResponse(resp, method, url, &result); err != nil {
+		return HotBackupStatus{}, maskAny(err)
+	}
+
+	return result, nil
+}
+
+// MoveData starts moving the data directory of the starter (and its local slaves) to a new directory.
+func (c *client) MoveData(ctx context.Context, req MoveDataRequest) (MoveDataStatus, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return MoveDataStatus{}, maskAny(err)
+	}
+	result, err := c.moveData(ctx, "POST", body)
+	if err != nil {
+		return MoveDataStatus{}, maskAny(err)
+	}
+	return result, nil
+}
+
+// MoveDataStatus loads the status of the current (or last) move of the data directory of the starter.
+func (c *client) MoveDataStatus(ctx context.Context) (MoveDataStatus, error) {
+	result, err := c.moveData(ctx, "GET", nil)
+	if err != nil {
+		return MoveDataStatus{}, maskAny(err)
+	}
+	return result, nil
+}
+
+// moveData performs a `/data/move` request with given method & body.
+func (c *client) moveData(ctx context.Context, method string, body []byte) (MoveDataStatus, error) {
+	url := c.createURL("/data/move", nil)
+
+	var result MoveDataStatus
+	req, err := http.NewRequest(method, url, bytes.NewReader(body))
+	if err != nil {
+		return MoveDataStatus{}, maskAny(err)
+	}
+	if ctx != nil {
+		req = req.WithContext(ctx)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return MoveDataStatus{}, maskAny(err)
+	}
+	if err := c.handleResponse(resp, method, url, &result); err != nil {
+		return MoveDataStatus{}, maskAny(err)
+	}
+
+	return result, nil
+}
+
+// ScaleLocalSlaves changes the number of local slaves of a starter started with `--starter.local`.
+func (c *client) ScaleLocalSlaves(ctx context.Context, req LocalSlavesRequest) (LocalSlavesStatus, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return LocalSlavesStatus{}, maskAny(err)
+	}
+	result, err := c.localSlaves(ctx, "POST", body)
+	if err != nil {
+		return LocalSlavesStatus{}, maskAny(err)
+	}
+	return result, nil
+}
+
+// LocalSlavesStatus loads the status of the current (or last) change of the number of local slaves.
+func (c *client) LocalSlavesStatus(ctx context.Context) (LocalSlavesStatus, error) {
+	result, err := c.localSlaves(ctx, "GET", nil)
+	if err != nil {
+		return LocalSlavesStatus{}, maskAny(err)
+	}
+	return result, nil
+}
+
+// localSlaves performs a `/local-slaves` request with given method & body.
+func (c *client) localSlaves(ctx context.Context, method string, body []byte) (LocalSlavesStatus, error) {
+	url := c.createURL("/local-slaves", nil)
+
+	var result LocalSlavesStatus
+	req, err := http.NewRequest(method, url, bytes.NewReader(body))
+	if err != nil {
+		return LocalSlavesStatus{}, maskAny(err)
+	}
+	if ctx != nil {
+		req = req.WithContext(ctx)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return LocalSlavesStatus{}, maskAny(err)
+	}
+	if err := c.handleResponse(resp, method, url, &result); err != nil {
+		return LocalSlavesStatus{}, maskAny(err)
+	}
+
+	return result, nil
+}
+
+// Health loads the health of the starter and the servers started by it.
+// A starter with a failed server responds with status 503, which is not returned as an error.
+func (c *client) Health(ctx context.Context) (HealthResponse, error) {
+	return c.health(ctx, "/health")
+}
+
+// PeerHealth loads the health of the starter of the peer with given ID and the servers started by it,
+// through the starter this client is connected to.
+func (c *client) PeerHealth(ctx context.Context, peerID string) (HealthResponse, error) {
+	return c.health(ctx, "/peers/"+peerID+"/health")
+}
+
+// health loads a health response from the given path.
+func (c *client) health(ctx context.Context, path string) (HealthResponse, error) {
+	url := c.createURL(path, nil)
+
+	var result HealthResponse
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return HealthResponse{}, maskAny(err)
+	}
+	if ctx != nil {
+		req = req.WithContext(ctx)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return HealthResponse{}, maskAny(err)
+	}
+	if resp.StatusCode == http.StatusServiceUnavailable {
+		defer resp.Body.Close()
+		if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+			return HealthResponse{}, maskAny(errors.Wrapf(err, "Failed decoding response data from GET request to %s: %v", url, err))
+		}
+		return result, nil
+	}
+	if err := c.handleResponse(resp, "GET", url, &result); err != nil {
+		return HealthResponse{}, maskAny(err)
+	}
+
+	return result, nil
+}
+
 // handleResponse checks the given response status and decodes any JSON result.
 func (c *client) handleResponse(resp *http.Response, method, url string, result interface{}) error {
 	// Read response body into memory
@@ -134,11 +880,19 @@ func (c *client) handleResponse(resp *http.Response, method, url string, result
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		/*var er ErrorResponse
-		if err := json.Unmarshal(body, &er); err == nil {
-			return &er
-		}*/
-		return maskAny(fmt.Errorf("Invalid status %d", resp.StatusCode))
+		statusErr := &StatusError{StatusCode: resp.StatusCode, Method: method, URL: url}
+		var er struct {
+			Error        string `json:"error"`
+			ErrorMessage string `json:"errorMessage"`
+			ErrorNum     int    `json:"errorNum"`
+		}
+		// Fields of a different type (e.g. `"error": true` of arangod) are skipped
+		json.Unmarshal(body, &er)
+		statusErr.Message, statusErr.ErrorNum = er.Error, er.ErrorNum
+		if er.ErrorMessage != "" {
+			statusErr.Message = er.ErrorMessage
+		}
+		return maskAny(statusErr)
 	}
 
 	// Got a success status
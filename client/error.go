@@ -1,9 +1,105 @@
 package client
 
 import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+
 	"github.com/pkg/errors"
 )
 
 var (
-	maskAny = errors.WithStack
+	// ErrBadRequest matches (IsStatus) errors of requests the starter rejected as invalid (400).
+	ErrBadRequest = &StatusError{StatusCode: http.StatusBadRequest}
+	// ErrUnauthorized matches (IsStatus) errors of requests that lack valid authentication (401).
+	ErrUnauthorized = &StatusError{StatusCode: http.StatusUnauthorized}
+	// ErrForbidden matches (IsStatus) errors of requests the starter does not allow (403).
+	ErrForbidden = &StatusError{StatusCode: http.StatusForbidden}
+	// ErrNotFound matches (IsStatus) errors of requests for something that does not exist (404).
+	ErrNotFound = &StatusError{StatusCode: http.StatusNotFound}
+	// ErrMethodNotAllowed matches (IsStatus) errors of requests using the wrong HTTP method (405).
+	ErrMethodNotAllowed = &StatusError{StatusCode: http.StatusMethodNotAllowed}
+	// ErrPreconditionFailed matches (IsStatus) errors of requests that are not possible in the current state (412).
+	ErrPreconditionFailed = &StatusError{StatusCode: http.StatusPreconditionFailed}
+	// ErrServiceUnavailable matches (IsStatus) errors of requests the starter cannot serve yet (503).
+	ErrServiceUnavailable = &StatusError{StatusCode: http.StatusServiceUnavailable}
 )
+
+// StatusError is returned by API methods when the starter responds with an unexpected status.
+// Use AsStatusError (or errors.Cause) to inspect it, or IsStatus with one of the Err... variables to check its status.
+type StatusError struct {
+	StatusCode int    // HTTP status code of the response
+	ErrorNum   int    // ArangoDB error number of the response (0 if not given)
+	Message    string // Error message of the response (if any)
+	Method     string // Method of the request
+	URL        string // URL of the request
+}
+
+// Error returns a human readable description of the error.
+func (e *StatusError) Error() string {
+	msg := fmt.Sprintf("Invalid status %d", e.StatusCode)
+	if e.Method != "" {
+		msg = fmt.Sprintf("%s from %s request to %s", msg, e.Method, e.URL)
+	}
+	if e.Message != "" {
+		msg = fmt.Sprintf("%s: %s", msg, e.Message)
+	}
+	return msg
+}
+
+// Is returns true if the target is a StatusError with the same status code.
+func (e *StatusError) Is(target error) bool {
+	t, ok := target.(*StatusError)
+	return ok && t.StatusCode == e.StatusCode
+}
+
+// AsStatusError returns the StatusError that caused the given error, if any.
+func AsStatusError(err error) (*StatusError, bool) {
+	statusErr, ok := errors.Cause(err).(*StatusError)
+	return statusErr, ok
+}
+
+// IsStatus returns true if the given error is caused by a StatusError with the same status code as the target
+// (e.g. ErrNotFound).
+func IsStatus(err error, target *StatusError) bool {
+	statusErr, ok := AsStatusError(err)
+	return ok && statusErr.Is(target)
+}
+
+// IsConnectionError returns true if the given error is caused by a failure to connect to the starter,
+// e.g. because it is not (yet) running.
+func IsConnectionError(err error) bool {
+	cause := errors.Cause(err)
+	if urlErr, ok := cause.(*url.Error); ok {
+		cause = urlErr.Err
+	}
+	opErr, ok := cause.(*net.OpError)
+	return ok && opErr.Op == "dial"
+}
+
+// maskAny adds a stack trace to the given error.
+// The original error can still be retrieved using errors.Cause.
+func maskAny(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &maskedError{error: errors.WithStack(err), cause: err}
+}
+
+// maskedError is an error with a stack trace.
+type maskedError struct {
+	error       // The error with stack trace
+	cause error // The original error
+}
+
+// Cause returns the original error (used by errors.Cause).
+func (e *maskedError) Cause() error { return e.cause }
+
+// Unwrap returns the original error.
+func (e *maskedError) Unwrap() error { return e.cause }
+
+// Format formats the error, including its stack trace with `%+v`.
+func (e *maskedError) Format(s fmt.State, verb rune) {
+	e.error.(fmt.Formatter).Format(s, verb)
+}
@@ -0,0 +1,139 @@
+//
+// DISCLAIMER
+//
+// Copyright 2017 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+// Author Ewout Prangsma
+//
+
+package client
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+)
+
+const (
+	// failoverHost is the host in the URLs of requests made by a client with several endpoints.
+	// It is replaced by the host of the endpoint the request is sent to.
+	failoverHost = "arangodb-starter"
+)
+
+// NewArangoStarterClientWithFailover creates a new client implementation that sends requests to
+// the first of the given HTTP(S) endpoints that can be reached.
+// When a starter cannot be reached, the request is retried on the next endpoint, which is then
+// used for subsequent requests. Redirects (e.g. to the master starter) are followed.
+func NewArangoStarterClientWithFailover(endpoints []url.URL, options ...ClientOption) (API, error) {
+	if len(endpoints) == 0 {
+		return nil, maskAny(fmt.Errorf("No endpoints given"))
+	}
+	for _, ep := range endpoints[1:] {
+		if ep.Scheme != endpoints[0].Scheme {
+			return nil, maskAny(fmt.Errorf("All endpoints must use the same scheme (%s), got '%s'", endpoints[0].Scheme, ep.String()))
+		}
+	}
+	if endpoints[0].Scheme != "http" && endpoints[0].Scheme != "https" {
+		return nil, maskAny(fmt.Errorf("Failover is only supported for http & https endpoints, got '%s'", endpoints[0].String()))
+	}
+	var opts clientOptions
+	for _, o := range options {
+		o(&opts)
+	}
+	httpClient, err := opts.createHTTPClient(DefaultHTTPClient)
+	if err != nil {
+		return nil, maskAny(err)
+	}
+	copy := *httpClient
+	transport := copy.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	copy.Transport = &failoverTransport{
+		transport: transport,
+		endpoints: endpoints,
+	}
+	return &client{
+		endpoint: url.URL{Scheme: endpoints[0].Scheme, Host: failoverHost},
+		client:   &copy,
+	}, nil
+}
+
+// failoverTransport sends requests for the failover host to the first endpoint that can be reached.
+type failoverTransport struct {
+	transport http.RoundTripper
+	mutex     sync.Mutex
+	endpoints []url.URL
+	current   int
+}
+
+// cloneRequest returns a copy of the given request, with its own URL & headers.
+// The body is shared with the given request.
+func cloneRequest(req *http.Request) *http.Request {
+	r := req.WithContext(req.Context())
+	u := *req.URL
+	r.URL = &u
+	r.Header = make(http.Header, len(req.Header))
+	for k, v := range req.Header {
+		r.Header[k] = append([]string(nil), v...)
+	}
+	return r
+}
+
+// RoundTrip sends the given request to the current endpoint, trying the other endpoints
+// when it cannot be reached.
+// Requests for other hosts (e.g. after a redirect) are sent unchanged.
+func (t *failoverTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Host != failoverHost {
+		return t.transport.RoundTrip(req)
+	}
+	t.mutex.Lock()
+	current := t.current
+	t.mutex.Unlock()
+
+	var lastErr error
+	for i := 0; i < len(t.endpoints); i++ {
+		index := (current + i) % len(t.endpoints)
+		ep := t.endpoints[index]
+		r := cloneRequest(req)
+		r.URL.Host, r.Host = ep.Host, ep.Host
+		if i > 0 && req.Body != nil {
+			if req.GetBody == nil {
+				// The body cannot be sent again
+				break
+			}
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, maskAny(err)
+			}
+			r.Body = body
+		}
+		resp, err := t.transport.RoundTrip(r)
+		if err == nil {
+			t.mutex.Lock()
+			t.current = index
+			t.mutex.Unlock()
+			return resp, nil
+		}
+		lastErr = err
+		if !IsConnectionError(err) || req.Context().Err() != nil {
+			break
+		}
+	}
+	return nil, lastErr
+}
@@ -23,6 +23,7 @@
 package client
 
 import (
+	"context"
 	"crypto/tls"
 	"net"
 	"net/http"
@@ -51,3 +52,16 @@ func DefaultHTTPClient() *http.Client {
 		},
 	}
 }
+
+// UnixSocketHTTPClient creates a new HTTP client that sends all requests to the unix socket
+// at the given path, regardless of the host in their URL.
+func UnixSocketHTTPClient(socketPath string) *http.Client {
+	c := DefaultHTTPClient()
+	transport := c.Transport.(*http.Transport)
+	transport.Proxy = nil
+	transport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		var d net.Dialer
+		return d.DialContext(ctx, "unix", socketPath)
+	}
+	return c
+}
@@ -0,0 +1,162 @@
+//
+// DISCLAIMER
+//
+// Copyright 2017 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+// Author Ewout Prangsma
+//
+
+package client
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+)
+
+// ClientOption configures a client created by NewArangoStarterClient.
+type ClientOption func(*clientOptions)
+
+// clientOptions holds the configuration set by ClientOption's.
+type clientOptions struct {
+	httpClient        *http.Client
+	tlsConfig         *tls.Config
+	requestTimeout    time.Duration
+	hasRequestTimeout bool
+	jwtSecret         string
+}
+
+// WithHTTPClient sets the HTTP client used for all requests to the starter.
+// By default a client created by DefaultHTTPClient (or UnixSocketHTTPClient) is used.
+func WithHTTPClient(c *http.Client) ClientOption {
+	return func(o *clientOptions) {
+		o.httpClient = c
+	}
+}
+
+// WithTLSConfig sets the TLS configuration used for connections to the starter,
+// e.g. to verify its certificate using a custom CA instead of skipping verification.
+func WithTLSConfig(config *tls.Config) ClientOption {
+	return func(o *clientOptions) {
+		o.tlsConfig = config
+	}
+}
+
+// WithRequestTimeout sets the time every request to the starter may take (0 means no timeout).
+func WithRequestTimeout(timeout time.Duration) ClientOption {
+	return func(o *clientOptions) {
+		o.requestTimeout = timeout
+		o.hasRequestTimeout = true
+	}
+}
+
+// WithJWTSecret makes the client authenticate all requests to the starter with a JWT signed with the given secret.
+// This is required for endpoints that act on the deployment using the JWT secret (e.g. `/agency/dump`)
+// or that destroy data, when the starter runs with a JWT secret.
+func WithJWTSecret(secret string) ClientOption {
+	return func(o *clientOptions) {
+		o.jwtSecret = secret
+	}
+}
+
+// createHTTPClient creates the HTTP client configured by the given options,
+// based on the given default client.
+func (o clientOptions) createHTTPClient(defaultClient func() *http.Client) (*http.Client, error) {
+	c := o.httpClient
+	if c == nil {
+		c = defaultClient()
+	} else if o.tlsConfig != nil || o.hasRequestTimeout || o.jwtSecret != "" {
+		// Do not modify the given client
+		copy := *c
+		c = &copy
+	}
+	if o.tlsConfig != nil {
+		var transport *http.Transport
+		switch t := c.Transport.(type) {
+		case nil:
+			transport = cloneTransport(http.DefaultTransport.(*http.Transport))
+		case *http.Transport:
+			transport = cloneTransport(t)
+		default:
+			return nil, maskAny(fmt.Errorf("Cannot set TLS config of HTTP client with transport of type %T", t))
+		}
+		transport.TLSClientConfig = o.tlsConfig
+		c.Transport = transport
+	}
+	if o.hasRequestTimeout {
+		c.Timeout = o.requestTimeout
+	}
+	if o.jwtSecret != "" {
+		token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+			"iss":       "arangodb",
+			"server_id": "starter-client",
+		}).SignedString([]byte(o.jwtSecret))
+		if err != nil {
+			return nil, maskAny(err)
+		}
+		transport := c.Transport
+		if transport == nil {
+			transport = http.DefaultTransport
+		}
+		c.Transport = &jwtTransport{token: token, transport: transport}
+	}
+	return c, nil
+}
+
+// jwtTransport adds a JWT authorization header to all requests, including those that follow a redirect
+// (e.g. to the master starter).
+type jwtTransport struct {
+	token     string
+	transport http.RoundTripper
+}
+
+// RoundTrip adds the JWT authorization header to a copy of the given request and sends it.
+func (t *jwtTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	r := new(http.Request)
+	*r = *req
+	r.Header = make(http.Header, len(req.Header)+1)
+	for k, v := range req.Header {
+		r.Header[k] = v
+	}
+	r.Header.Set("Authorization", "bearer "+t.token)
+	return t.transport.RoundTrip(r)
+}
+
+// cloneTransport returns a new transport with the settings of the given transport (but without its connections).
+func cloneTransport(t *http.Transport) *http.Transport {
+	return &http.Transport{
+		Proxy:                  t.Proxy,
+		DialContext:            t.DialContext,
+		Dial:                   t.Dial,
+		DialTLS:                t.DialTLS,
+		TLSClientConfig:        t.TLSClientConfig,
+		TLSHandshakeTimeout:    t.TLSHandshakeTimeout,
+		DisableKeepAlives:      t.DisableKeepAlives,
+		DisableCompression:     t.DisableCompression,
+		MaxIdleConns:           t.MaxIdleConns,
+		MaxIdleConnsPerHost:    t.MaxIdleConnsPerHost,
+		IdleConnTimeout:        t.IdleConnTimeout,
+		ResponseHeaderTimeout:  t.ResponseHeaderTimeout,
+		ExpectContinueTimeout:  t.ExpectContinueTimeout,
+		TLSNextProto:           t.TLSNextProto,
+		ProxyConnectHeader:     t.ProxyConnectHeader,
+		MaxResponseHeaderBytes: t.MaxResponseHeaderBytes,
+	}
+}
@@ -0,0 +1,326 @@
+//
+// DISCLAIMER
+//
+// Copyright 2017 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+// Author Ewout Prangsma
+//
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	service "github.com/arangodb-helper/arangodb/service"
+	"github.com/spf13/pflag"
+)
+
+var (
+	configFile         string
+	configFileMutex    sync.Mutex
+	configFlags        *pflag.FlagSet    // Flags the configuration file is applied to
+	configFileOptions  map[string]string // Options from the configuration file, as they have been applied
+	usedConfigFile     string            // Path of the configuration file that has been loaded (if any)
+	commandLineOptions map[string]bool   // Names of all options (including passthrough options) that have been set on the command line
+	// fileOptions holds the options with a name that suggests a secret, while holding the path of a file.
+	fileOptions = map[string]bool{
+		"auth.jwt-secret":            true,
+		"notify.webhook-secret-file": true,
+		"auth.root-password-file":    true,
+	}
+	// liveOptions holds all options that can be changed by a reload, with the function that applies the new value.
+	liveOptions = map[string]func(){
+		"log.verbose": applyLogLevel,
+		"log.level":   applyLogLevel,
+	}
+)
+
+// readConfigFile parses the configuration file at given path.
+// The file uses the same format as arangod.conf. The name of a section
+// combined with the name of a key in that section forms the option name.
+// E.g. `verbose = true` in section `[log]` sets `--log.verbose=true`.
+func readConfigFile(path string) (map[string]string, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, maskAny(err)
+	}
+	result := make(map[string]string)
+	section := ""
+	for i, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section = strings.TrimSpace(line[1 : len(line)-1])
+			continue
+		}
+		parts := strings.SplitN(line, "=", 2)
+		if len(parts) != 2 {
+			return nil, maskAny(fmt.Errorf("%s:%d: expected 'key = value', got '%s'", path, i+1, line))
+		}
+		key := strings.TrimSpace(parts[0])
+		if section != "" {
+			key = section + "." + key
+		}
+		value := strings.Trim(strings.TrimSpace(parts[1]), `"`)
+		result[string(normalizeOptionNames(nil, key))] = value
+	}
+	return result, nil
+}
+
+// loadConfigFile applies all options from the configuration file that
+// have not been set on the command line.
+// Nothing is loaded when no configuration file has been specified.
+func loadConfigFile(f *pflag.FlagSet) error {
+	configFileMutex.Lock()
+	defer configFileMutex.Unlock()
+
+	configFlags = f
+	commandLineOptions = make(map[string]bool)
+	f.Visit(func(flag *pflag.Flag) {
+		commandLineOptions[flag.Name] = true
+	})
+	for _, o := range passthroughOptions {
+		commandLineOptions[o.Prefix+"."+o.Name] = true
+	}
+	if configFile == "" {
+		return nil
+	}
+	path := mustExpand(configFile)
+	options, err := readConfigFile(path)
+	if err != nil {
+		return maskAny(fmt.Errorf("Failed to read configuration file '%s': %v", path, err))
+	}
+	addConfigFilePassthroughOptions(options)
+	for name, value := range options {
+		if commandLineOptions[name] || isPassthroughOptionName(name) {
+			// Command line takes precedence, passthrough options are handled separately
+			continue
+		}
+		flag := f.Lookup(name)
+		if flag == nil {
+			return maskAny(fmt.Errorf("Unknown option '%s' in configuration file '%s'", name, path))
+		}
+		if err := flag.Value.Set(value); err != nil {
+			return maskAny(fmt.Errorf("Invalid value for option '%s' in configuration file '%s': %v", name, path, err))
+		}
+	}
+	configFileOptions = options
+	usedConfigFile, _ = filepath.Abs(path)
+	log.Infof("Using configuration file %s", path)
+	return nil
+}
+
+// isOptionSet returns true if the option with given name has been set
+// on the command line or in the configuration file.
+func isOptionSet(name string) bool {
+	configFileMutex.Lock()
+	defer configFileMutex.Unlock()
+	if commandLineOptions[name] {
+		return true
+	}
+	_, found := configFileOptions[name]
+	return found
+}
+
+// reloadConfigFile re-reads the configuration file and applies all changed
+// options that can be changed at runtime.
+// Changed options that only take effect after a restart are reported, but not applied,
+// so they are reported again on the next reload.
+func reloadConfigFile() (service.ReloadResponse, error) {
+	configFileMutex.Lock()
+	defer configFileMutex.Unlock()
+
+	if configFile == "" {
+		return service.ReloadResponse{}, maskAny(fmt.Errorf("No configuration file has been specified"))
+	}
+	f := configFlags
+	path := mustExpand(configFile)
+	options, err := readConfigFile(path)
+	if err != nil {
+		return service.ReloadResponse{}, maskAny(err)
+	}
+	applied := make(map[string]string, len(options))
+	for name, value := range options {
+		applied[name] = value
+	}
+	// Passthrough options are only used when starting servers
+	var result service.ReloadResponse
+	for _, name := range changedPassthroughOptions(configFileOptions, options) {
+		log.Warningf("Option '%s' has changed, this requires a restart to take effect", name)
+		result.RestartRequired = append(result.RestartRequired, name)
+	}
+
+	// Options that have been removed from the file fall back to their default value.
+	for name := range configFileOptions {
+		if _, found := options[name]; !found {
+			if flag := f.Lookup(name); flag != nil {
+				options[name] = flag.DefValue
+			}
+		}
+	}
+	names := make([]string, 0, len(options))
+	for name := range options {
+		if isPassthroughOptionName(name) {
+			continue
+		}
+		if f.Lookup(name) == nil {
+			return service.ReloadResponse{}, maskAny(fmt.Errorf("Unknown option '%s' in configuration file '%s'", name, path))
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		flag := f.Lookup(name)
+		value := options[name]
+		if commandLineOptions[name] || flag.Value.String() == value {
+			continue
+		}
+		if apply, found := liveOptions[name]; found {
+			if err := flag.Value.Set(value); err != nil {
+				return result, maskAny(fmt.Errorf("Invalid value for option '%s': %v", name, err))
+			}
+			apply()
+			log.Infof("Changed option '%s' to '%s'", name, value)
+			result.Applied = append(result.Applied, name)
+		} else {
+			log.Warningf("Option '%s' has changed, this requires a restart to take effect", name)
+			result.RestartRequired = append(result.RestartRequired, name)
+		}
+	}
+	// Remember the old value of options that have not been applied
+	for _, name := range result.RestartRequired {
+		if value, found := configFileOptions[name]; found {
+			applied[name] = value
+		} else {
+			delete(applied, name)
+		}
+	}
+	configFileOptions = applied
+	return result, nil
+}
+
+// effectiveConfig returns the current value & source of all options of the starter,
+// with the values of secrets redacted.
+func effectiveConfig() (service.ConfigResponse, error) {
+	configFileMutex.Lock()
+	defer configFileMutex.Unlock()
+
+	source := func(name string) string {
+		if commandLineOptions[name] {
+			return service.ConfigSourceCommandLine
+		}
+		if _, found := configFileOptions[name]; found {
+			return service.ConfigSourceConfigFile
+		}
+		return service.ConfigSourceDefault
+	}
+	result := service.ConfigResponse{ConfigFile: usedConfigFile}
+	configFlags.VisitAll(func(flag *pflag.Flag) {
+		if flag.Hidden || flag.Deprecated != "" {
+			return
+		}
+		_, live := liveOptions[flag.Name]
+		value, redacted := redactOptionValue(flag.Name, flag.Value.String())
+		defValue, _ := redactOptionValue(flag.Name, flag.DefValue)
+		result.Options = append(result.Options, service.ConfigOption{
+			Name:            flag.Name,
+			Value:           value,
+			Default:         defValue,
+			Source:          source(flag.Name),
+			Redacted:        redacted,
+			RestartRequired: !live,
+		})
+	})
+	for _, o := range passthroughOptions {
+		name := o.Prefix + "." + o.Name
+		value, redacted := redactOptionValue(name, o.Value)
+		result.Options = append(result.Options, service.ConfigOption{
+			Name:            name,
+			Value:           value,
+			Source:          source(name),
+			Redacted:        redacted,
+			Passthrough:     true,
+			RestartRequired: true,
+		})
+	}
+	sort.Slice(result.Options, func(i, j int) bool { return result.Options[i].Name < result.Options[j].Name })
+	return result, nil
+}
+
+// starterExecutable returns the path of the executable of the starter, with symbolic links resolved.
+func starterExecutable() (string, error) {
+	executable, err := os.Executable()
+	if err != nil {
+		return "", maskAny(fmt.Errorf("Cannot find path of %s executable: %v", projectName, err))
+	}
+	if resolved, err := filepath.EvalSymlinks(executable); err == nil {
+		executable = resolved
+	}
+	return executable, nil
+}
+
+// starterArguments returns the command line arguments that run the starter with all options
+// given on the command line and the given passthrough options, for use by a service manager.
+// The data directory & configuration file are passed with an absolute path.
+func starterArguments(passthrough []service.PassthroughOption) ([]string, error) {
+	absDataDir, err := filepath.Abs(mustExpand(dataDir))
+	if err != nil {
+		return nil, maskAny(fmt.Errorf("Cannot get absolute path of %s: %v", dataDir, err))
+	}
+	args := []string{"--data.dir=" + absDataDir}
+	if usedConfigFile != "" {
+		args = append(args, "--configuration="+usedConfigFile)
+	}
+	cmdMain.Flags().VisitAll(func(flag *pflag.Flag) {
+		if commandLineOptions[flag.Name] && flag.Name != "data.dir" && flag.Name != "configuration" {
+			args = append(args, fmt.Sprintf("--%s=%s", flag.Name, flag.Value.String()))
+		}
+	})
+	for _, o := range passthrough {
+		args = append(args, fmt.Sprintf("--%s.%s=%s", o.Prefix, o.Name, o.Value))
+	}
+	return args, nil
+}
+
+// redactOptionValue hides the value of the option with given name when it holds a secret
+// (e.g. a password), or hides the password & secret query parameters of a URL in the value.
+// Returns the (redacted) value and true if anything was hidden.
+func redactOptionValue(name, value string) (string, bool) {
+	if fileOptions[name] {
+		return service.RedactURL(value)
+	}
+	return service.RedactValue(name, value)
+}
+
+// handleReloadSignal reloads the configuration file each time a SIGHUP is received.
+func handleReloadSignal(hupChannel chan os.Signal) {
+	for range hupChannel {
+		log.Info("Received SIGHUP, reloading configuration file")
+		if _, err := reloadConfigFile(); err != nil {
+			log.Errorf("Failed to reload configuration file: %v", err)
+		}
+	}
+}
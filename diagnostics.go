@@ -0,0 +1,74 @@
+//
+// DISCLAIMER
+//
+// Copyright 2017 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+// Author Ewout Prangsma
+//
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	cmdDiagnostics = &cobra.Command{
+		Use:   "diagnostics",
+		Short: "Create a diagnostics bundle of a running starter, to attach to support tickets",
+		Run:   cmdDiagnosticsRun,
+	}
+	diagnosticsOptions struct {
+		endpoint string
+		output   string
+	}
+)
+
+func init() {
+	f := cmdDiagnostics.Flags()
+	addStarterEndpointFlag(f, &diagnosticsOptions.endpoint)
+	f.StringVar(&diagnosticsOptions.output, "output", "", "Path of the diagnostics bundle to create (default arangodb-diagnostics-<time>.tar.gz)")
+	cmdMain.AddCommand(cmdDiagnostics)
+}
+
+func cmdDiagnosticsRun(cmd *cobra.Command, args []string) {
+	output := diagnosticsOptions.output
+	if output == "" {
+		output = fmt.Sprintf("arangodb-diagnostics-%s.tar.gz", time.Now().Format("20060102-150405"))
+	}
+	c := mustCreateStarterClient(diagnosticsOptions.endpoint)
+	f, err := os.Create(output)
+	if err != nil {
+		log.Fatalf("Failed to create %s: %v", output, err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	if err := c.Diagnostics(ctx, f); err != nil {
+		f.Close()
+		os.Remove(output)
+		log.Fatalf("Failed to get diagnostics of starter at %s: %v", diagnosticsOptions.endpoint, err)
+	}
+	if err := f.Close(); err != nil {
+		log.Fatalf("Failed to write %s: %v", output, err)
+	}
+	log.Infof("Diagnostics bundle written to %s", output)
+}
@@ -0,0 +1,76 @@
+//
+// DISCLAIMER
+//
+// Copyright 2017 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+// Author Ewout Prangsma
+//
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/arangodb-helper/arangodb/fleet"
+	"github.com/spf13/cobra"
+)
+
+var (
+	cmdFleet = &cobra.Command{
+		Use:   "fleet",
+		Short: "Run a fleet controller that aggregates the API's of many deployments",
+		Run:   cmdFleetRun,
+	}
+	fleetOptions struct {
+		configFile string
+		port       int
+	}
+)
+
+func init() {
+	f := cmdFleet.Flags()
+	f.StringVar(&fleetOptions.configFile, "fleet.config", "fleet.json", "Path of a JSON file listing all deployments of the fleet")
+	f.IntVar(&fleetOptions.port, "fleet.port", 8520, "Port to listen on for fleet API requests")
+	cmdMain.AddCommand(cmdFleet)
+}
+
+func cmdFleetRun(cmd *cobra.Command, args []string) {
+	applyLogLevel()
+	log.Infof("Starting %s fleet controller version %s, build %s", projectName, projectVersion, projectBuild)
+
+	cfg, err := fleet.ReadConfig(mustExpand(fleetOptions.configFile))
+	if err != nil {
+		log.Fatalf("Failed to read fleet configuration: %v", err)
+	}
+	controller, err := fleet.NewController(log, cfg)
+	if err != nil {
+		log.Fatalf("Invalid fleet configuration: %v", err)
+	}
+
+	sigChannel := make(chan os.Signal, 1)
+	rootCtx, cancel := context.WithCancel(context.Background())
+	signal.Notify(sigChannel, os.Interrupt, syscall.SIGTERM)
+	go handleSignal(sigChannel, cancel)
+
+	if err := controller.Run(rootCtx, fmt.Sprintf("0.0.0.0:%d", fleetOptions.port)); err != nil {
+		log.Fatalf("Fleet controller failed: %v", err)
+	}
+}
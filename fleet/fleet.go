@@ -0,0 +1,456 @@
+//
+// DISCLAIMER
+//
+// Copyright 2017 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+// Author Ewout Prangsma
+//
+
+package fleet
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/arangodb-helper/arangodb/client"
+	logging "github.com/op/go-logging"
+	"github.com/pkg/errors"
+)
+
+var (
+	maskAny = errors.WithStack
+)
+
+const (
+	requestTimeout = time.Second * 15 // Timeout of a single request to a starter
+)
+
+// Config holds the list of deployments managed by a fleet controller.
+type Config struct {
+	Deployments []Deployment `json:"deployments"`
+}
+
+// Deployment holds the configuration of a single deployment in the fleet.
+type Deployment struct {
+	Name      string   `json:"name"`           // Unique name of the deployment
+	Tags      []string `json:"tags,omitempty"` // Tags used to select deployments in bulk operations (e.g. "dev")
+	Endpoints []string `json:"endpoints"`      // Endpoints of all starters of the deployment
+}
+
+// DeploymentStatus is the combined status of all starters of a deployment.
+type DeploymentStatus struct {
+	Name     string          `json:"name"`
+	Tags     []string        `json:"tags,omitempty"`
+	Healthy  bool            `json:"healthy"` // True if all starters are reachable and have started all their servers
+	Starters []StarterStatus `json:"starters"`
+}
+
+// StarterStatus is the status of a single starter in a deployment.
+type StarterStatus struct {
+	Endpoint       string   `json:"endpoint"`
+	Reachable      bool     `json:"reachable"`
+	Version        string   `json:"version,omitempty"`
+	Build          string   `json:"build,omitempty"`
+	ServersStarted bool     `json:"servers-started,omitempty"`
+	ServerVersions []string `json:"server-versions,omitempty"` // Versions of the servers started by the starter (if known)
+	Error          string   `json:"error,omitempty"`
+}
+
+// VersionInventory maps each starter & server version to the names of the deployments running it.
+type VersionInventory struct {
+	Starters map[string][]string `json:"starters"`
+	Servers  map[string][]string `json:"servers"`
+}
+
+type errorResponse struct {
+	Error string
+}
+
+// OperationResult is the result of a bulk operation on a single starter.
+type OperationResult struct {
+	Deployment string `json:"deployment"`
+	Endpoint   string `json:"endpoint"`
+	Error      string `json:"error,omitempty"`
+}
+
+// UpgradeResult is the state of the rolling upgrade of a single deployment.
+type UpgradeResult struct {
+	Deployment string                `json:"deployment"`
+	Endpoint   string                `json:"endpoint,omitempty"` // Starter the upgrade was started or inspected through
+	Status     *client.UpgradeStatus `json:"status,omitempty"`
+	Error      string                `json:"error,omitempty"`
+}
+
+// ReadConfig reads & parses a fleet configuration file (JSON).
+func ReadConfig(path string) (Config, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return Config{}, maskAny(err)
+	}
+	var cfg Config
+	if err := json.Unmarshal(content, &cfg); err != nil {
+		return Config{}, maskAny(errors.Wrapf(err, "Cannot parse %s", path))
+	}
+	return cfg, nil
+}
+
+// Controller aggregates the API's of all starters in a fleet of deployments.
+type Controller struct {
+	log         *logging.Logger
+	deployments []deployment
+}
+
+type deployment struct {
+	Deployment
+	clients []client.API
+}
+
+// NewController creates a new fleet controller from the given configuration.
+func NewController(log *logging.Logger, cfg Config) (*Controller, error) {
+	names := make(map[string]bool)
+	c := &Controller{log: log}
+	for _, d := range cfg.Deployments {
+		if d.Name == "" {
+			return nil, maskAny(fmt.Errorf("Deployment without a name"))
+		}
+		if names[d.Name] {
+			return nil, maskAny(fmt.Errorf("Duplicate deployment name '%s'", d.Name))
+		}
+		names[d.Name] = true
+		if len(d.Endpoints) == 0 {
+			return nil, maskAny(fmt.Errorf("Deployment '%s' has no endpoints", d.Name))
+		}
+		dep := deployment{Deployment: d}
+		for _, ep := range d.Endpoints {
+			u, err := url.Parse(ep)
+			if err != nil {
+				return nil, maskAny(errors.Wrapf(err, "Invalid endpoint '%s' of deployment '%s'", ep, d.Name))
+			}
+			api, err := client.NewArangoStarterClient(*u)
+			if err != nil {
+				return nil, maskAny(err)
+			}
+			dep.clients = append(dep.clients, api)
+		}
+		c.deployments = append(c.deployments, dep)
+	}
+	return c, nil
+}
+
+// Run serves the fleet API on the given address until the given context is canceled.
+func (c *Controller) Run(ctx context.Context, addr string) error {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/fleet/health", c.healthHandler)
+	mux.HandleFunc("/fleet/versions", c.versionsHandler)
+	mux.HandleFunc("/fleet/shutdown", c.shutdownHandler)
+	mux.HandleFunc("/fleet/upgrade", c.upgradeHandler)
+
+	server := &http.Server{
+		Addr:    addr,
+		Handler: mux,
+	}
+	go func() {
+		<-ctx.Done()
+		server.Close()
+	}()
+	c.log.Infof("Fleet controller listening on %s, managing %d deployments", addr, len(c.deployments))
+	if err := server.ListenAndServe(); err != nil && ctx.Err() == nil {
+		return maskAny(err)
+	}
+	return nil
+}
+
+// selectDeployments returns all deployments matching the `tag` and `name` query parameters of the given request.
+func (c *Controller) selectDeployments(r *http.Request) []deployment {
+	tag := r.URL.Query().Get("tag")
+	name := r.URL.Query().Get("name")
+	var result []deployment
+	for _, d := range c.deployments {
+		if name != "" && d.Name != name {
+			continue
+		}
+		if tag != "" && !hasTag(d.Tags, tag) {
+			continue
+		}
+		result = append(result, d)
+	}
+	return result
+}
+
+// selectOperationDeployments returns all deployments selected for a bulk operation by the given request.
+// An operation requires a `tag` or `name` query parameter, or `all=true` to select all deployments.
+// Returns false (after writing an error response) if none is given.
+func (c *Controller) selectOperationDeployments(w http.ResponseWriter, r *http.Request) ([]deployment, bool) {
+	q := r.URL.Query()
+	if q.Get("tag") == "" && q.Get("name") == "" && q.Get("all") != "true" {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: "Select deployments using a tag or name query, or all=true"})
+		return nil, false
+	}
+	return c.selectDeployments(r), true
+}
+
+// hasTag returns true if the given tag is in the given list.
+func hasTag(tags []string, tag string) bool {
+	for _, t := range tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// status gathers the status of all starters of the given deployments (in parallel).
+func (c *Controller) status(deployments []deployment) []DeploymentStatus {
+	result := make([]DeploymentStatus, len(deployments))
+	wg := sync.WaitGroup{}
+	for i, d := range deployments {
+		result[i] = DeploymentStatus{
+			Name:     d.Name,
+			Tags:     d.Tags,
+			Starters: make([]StarterStatus, len(d.clients)),
+		}
+		for j, api := range d.clients {
+			wg.Add(1)
+			go func(status *StarterStatus, endpoint string, api client.API) {
+				defer wg.Done()
+				*status = starterStatus(endpoint, api)
+			}(&result[i].Starters[j], d.Endpoints[j], api)
+		}
+	}
+	wg.Wait()
+	for i := range result {
+		result[i].Healthy = true
+		for _, s := range result[i].Starters {
+			if !s.Reachable || !s.ServersStarted {
+				result[i].Healthy = false
+			}
+		}
+	}
+	return result
+}
+
+// starterStatus fetches the status of a single starter.
+func starterStatus(endpoint string, api client.API) StarterStatus {
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+	status := StarterStatus{Endpoint: endpoint}
+	version, err := api.Version(ctx)
+	if err != nil {
+		status.Error = err.Error()
+		return status
+	}
+	status.Reachable = true
+	status.Version = version.Version
+	status.Build = version.Build
+	processes, err := api.Processes(ctx)
+	if err != nil {
+		status.Error = err.Error()
+		return status
+	}
+	status.ServersStarted = processes.ServersStarted
+	info, err := api.Status(ctx)
+	if err != nil {
+		status.Error = err.Error()
+		return status
+	}
+	versions := make(map[string]bool)
+	for _, s := range info.Servers {
+		if s.Version != "" && !versions[s.Version] {
+			versions[s.Version] = true
+			status.ServerVersions = append(status.ServerVersions, s.Version)
+		}
+	}
+	sort.Strings(status.ServerVersions)
+	return status
+}
+
+// healthHandler returns the combined health of all selected deployments.
+func (c *Controller) healthHandler(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, c.status(c.selectDeployments(r)))
+}
+
+// versionsHandler returns an inventory of starter & server versions, mapping each version
+// to the names of the deployments that run it.
+func (c *Controller) versionsHandler(w http.ResponseWriter, r *http.Request) {
+	inventory := VersionInventory{
+		Starters: make(map[string][]string),
+		Servers:  make(map[string][]string),
+	}
+	for _, d := range c.status(c.selectDeployments(r)) {
+		starterVersions := make(map[string]bool)
+		serverVersions := make(map[string]bool)
+		for _, s := range d.Starters {
+			if s.Reachable {
+				starterVersions[s.Version] = true
+			}
+			for _, v := range s.ServerVersions {
+				serverVersions[v] = true
+			}
+		}
+		for v := range starterVersions {
+			inventory.Starters[v] = append(inventory.Starters[v], d.Name)
+		}
+		for v := range serverVersions {
+			inventory.Servers[v] = append(inventory.Servers[v], d.Name)
+		}
+	}
+	for _, m := range []map[string][]string{inventory.Starters, inventory.Servers} {
+		for v := range m {
+			sort.Strings(m[v])
+		}
+	}
+	writeJSON(w, http.StatusOK, inventory)
+}
+
+// shutdownHandler shuts down all starters of the selected deployments.
+func (c *Controller) shutdownHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		writeJSON(w, http.StatusMethodNotAllowed, errorResponse{Error: "POST required"})
+		return
+	}
+	deployments, ok := c.selectOperationDeployments(w, r)
+	if !ok {
+		return
+	}
+	goodbye := r.URL.Query().Get("mode") == "goodbye"
+	results := c.forEachStarter(deployments, func(ctx context.Context, api client.API) error {
+		return api.Shutdown(ctx, goodbye)
+	})
+	writeJSON(w, http.StatusOK, results)
+}
+
+// upgradeHandler starts (POST) or inspects (GET) a rolling upgrade of all selected deployments.
+// The body of a POST request holds the options of the upgrades (see client.UpgradeOptions), it may be empty.
+func (c *Controller) upgradeHandler(w http.ResponseWriter, r *http.Request) {
+	var op func(context.Context, client.API) (client.UpgradeStatus, error)
+	var deployments []deployment
+	switch r.Method {
+	case "GET":
+		deployments = c.selectDeployments(r)
+		op = func(ctx context.Context, api client.API) (client.UpgradeStatus, error) {
+			return api.UpgradeStatus(ctx)
+		}
+	case "POST":
+		var ok bool
+		if deployments, ok = c.selectOperationDeployments(w, r); !ok {
+			return
+		}
+		var options client.UpgradeOptions
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+			return
+		}
+		if len(body) > 0 {
+			if err := json.Unmarshal(body, &options); err != nil {
+				writeJSON(w, http.StatusBadRequest, errorResponse{Error: fmt.Sprintf("Cannot parse request body: %v", err)})
+				return
+			}
+		}
+		op = func(ctx context.Context, api client.API) (client.UpgradeStatus, error) {
+			return api.StartUpgradeWithOptions(ctx, options)
+		}
+	default:
+		writeJSON(w, http.StatusMethodNotAllowed, errorResponse{Error: "GET or POST required"})
+		return
+	}
+	writeJSON(w, http.StatusOK, c.forEachDeployment(deployments, op))
+}
+
+// forEachDeployment invokes the given upgrade operation on every given deployment (in parallel),
+// through the first of its starters that can be reached.
+func (c *Controller) forEachDeployment(deployments []deployment, op func(context.Context, client.API) (client.UpgradeStatus, error)) []UpgradeResult {
+	results := make([]UpgradeResult, len(deployments))
+	wg := sync.WaitGroup{}
+	for i, d := range deployments {
+		wg.Add(1)
+		go func(result *UpgradeResult, d deployment) {
+			defer wg.Done()
+			result.Deployment = d.Name
+			for j, api := range d.clients {
+				ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+				status, err := op(ctx, api)
+				cancel()
+				result.Endpoint = d.Endpoints[j]
+				if err == nil {
+					result.Status, result.Error = &status, ""
+					return
+				}
+				result.Error = err.Error()
+				if !client.IsConnectionError(err) {
+					break
+				}
+			}
+			c.log.Warningf("Upgrade operation on %s failed: %s", d.Name, result.Error)
+		}(&results[i], d)
+	}
+	wg.Wait()
+	return results
+}
+
+// forEachStarter invokes the given operation on all starters of the given deployments (in parallel).
+func (c *Controller) forEachStarter(deployments []deployment, op func(context.Context, client.API) error) []OperationResult {
+	var results []OperationResult
+	mutex := sync.Mutex{}
+	wg := sync.WaitGroup{}
+	for _, d := range deployments {
+		for j, api := range d.clients {
+			wg.Add(1)
+			go func(name, endpoint string, api client.API) {
+				defer wg.Done()
+				ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+				defer cancel()
+				result := OperationResult{Deployment: name, Endpoint: endpoint}
+				if err := op(ctx, api); err != nil {
+					c.log.Warningf("Operation on %s (%s) failed: %v", endpoint, name, err)
+					result.Error = err.Error()
+				}
+				mutex.Lock()
+				results = append(results, result)
+				mutex.Unlock()
+			}(d.Name, d.Endpoints[j], api)
+		}
+	}
+	wg.Wait()
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Deployment != results[j].Deployment {
+			return results[i].Deployment < results[j].Deployment
+		}
+		return results[i].Endpoint < results[j].Endpoint
+	})
+	return results
+}
+
+// writeJSON writes the given object as JSON response with given status.
+func writeJSON(w http.ResponseWriter, status int, value interface{}) {
+	b, err := json.Marshal(value)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(b)
+}
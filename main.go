@@ -24,6 +24,7 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"os"
@@ -31,6 +32,7 @@ import (
 	"path/filepath"
 	"runtime"
 	"sort"
+	"strconv"
 	"strings"
 	"syscall"
 	"time"
@@ -54,71 +56,299 @@ const (
 var (
 	projectVersion = "dev"
 	projectBuild   = "dev"
+	runContext     = context.Background() // Context of the starter, canceled to stop it (e.g. when running as Windows service)
 	cmdMain        = cobra.Command{
 		Use:   projectName,
 		Short: "Start ArangoDB clusters & single servers with ease",
 		Run:   cmdMainRun,
 	}
-	log                  = logging.MustGetLogger(projectName)
-	id                   string
-	agencySize           int
-	arangodPath          string
-	arangodJSPath        string
-	masterPort           int
-	rrPath               string
-	startCoordinator     bool
-	startDBserver        bool
-	startLocalSlaves     bool
-	mode                 string
-	dataDir              string
-	ownAddress           string
-	masterAddress        string
-	verbose              bool
-	serverThreads        int
-	serverStorageEngine  string
-	allPortOffsetsUnique bool
-	jwtSecretFile        string
-	sslKeyFile           string
-	sslAutoKeyFile       bool
-	sslAutoServerName    string
-	sslAutoOrganization  string
-	sslCAFile            string
-	dockerEndpoint       string
-	dockerImage          string
-	dockerUser           string
-	dockerContainerName  string
-	dockerGCDelay        time.Duration
-	dockerNetHost        bool // Deprecated
-	dockerNetworkMode    string
-	dockerPrivileged     bool
+	log                       = logging.MustGetLogger(projectName)
+	id                        string
+	agencySize                int
+	arangodPath               string
+	arangodJSPath             string
+	masterPort                int
+	rrPath                    string
+	serverDownload            bool
+	serverVersion             string
+	serverDownloadURL         string
+	serverDownloadSHA256      string
+	serverDownloadDir         string
+	startCoordinator          bool
+	startDBserver             bool
+	startLocalSlaves          bool
+	mode                      string
+	dataDir                   string
+	ownAddress                string
+	bindAddress               string
+	starterInterface          string
+	discovery                 string
+	masterAddress             string
+	verbose                   bool
+	logLevels                 string
+	logFormat                 string
+	logRotateSize             string
+	logRotateFiles            int
+	logForward                string
+	tracingEndpoint           string
+	coreDirectory             string
+	coreLogLines              int
+	backupSchedule            string
+	backupDir                 string
+	backupKeep                int
+	recoveryFromBackup        string
+	restartPolicy             string
+	restartMaxRetries         int
+	restartBackoffInitial     time.Duration
+	restartBackoffMax         time.Duration
+	crashLoopRestarts         int
+	crashLoopWindow           time.Duration
+	startSequential           bool
+	offline                   bool
+	coordinatorDBServers      int
+	agentFailoverDelay        time.Duration
+	crashLoopWebhook          string
+	notifyWebhookURL          string
+	notifyWebhookSecretFile   string
+	apiRateLimit              float64
+	apiRateBurst              int
+	apiMaxBodySize            string
+	apiCORSAllowedOrigins     string
+	apiReadOnly               bool
+	metricsStatsd             string
+	metricsStatsdPrefix       string
+	metricsStatsdTags         bool
+	metricsStatsdInterval     time.Duration
+	livenessInterval          time.Duration
+	proxyPort                 int
+	proxyHealthInterval       time.Duration
+	livenessTimeout           time.Duration
+	livenessFailures          int
+	agentStartupTimeout       time.Duration
+	agentPort                 int
+	agentPortRange            string
+	dbserverPort              int
+	dbserverPortRange         string
+	coordinatorPort           int
+	coordinatorPortRange      string
+	dbserverStartupTimeout    time.Duration
+	coordinatorStartupTimeout time.Duration
+	singleStartupTimeout      time.Duration
+	maxOpenFiles              uint64
+	maxProcesses              uint64
+	serverNice                int
+	serverIONice              string
+	agentsNice                int
+	agentsIONice              string
+	dbserversNice             int
+	dbserversIONice           string
+	coordinatorsNice          int
+	coordinatorsIONice        string
+	dbserversNumactl          string
+	dbserversCpuset           string
+	serverDirTemplate         string
+	agentsDir                 string
+	dbserversDir              string
+	coordinatorsDir           string
+	starterListen             string
+	serverListen              string
+	memoryTotal               string
+	hooksDir                  string
+	hooksPreStart             string
+	hooksPostReady            string
+	hooksPreStop              string
+	hooksCanaryCheck          string
+	hooksTimeout              time.Duration
+	diskMinFree               string
+	diskCheckInterval         time.Duration
+	recoveryRemoteConfig      string
+	bootstrapManifest         string
+	bootstrapScripts          []string
+	bootstrapFoxx             []string
+	bootstrapImportDir        string
+	bootstrapImportJobs       int
+	clockSkewWarning          time.Duration
+	clockSkewLimit            time.Duration
+	serverThreads             int
+	serverStorageEngine       string
+	rocksdbPreset             string
+	allPortOffsetsUnique      bool
+	preferHostnames           bool
+	localPortOffset           int
+	localPortIncrement        int
+	localAgents               int
+	localDBServers            int
+	localCoordinators         int
+	strictReproducibility     bool
+	acceptInputChanges        bool
+	standby                   bool
+	passive                   bool
+	standbyFailoverDelay      time.Duration
+	masterFailoverDelay       time.Duration
+	dryRun                    bool
+	jwtSecretFile             string
+	rootPassword              string
+	rootPasswordFile          string
+	joinToken                 string
+	licenseFile               string
+	licenseEnv                string
+	sslKeyFile                string
+	sslAutoKeyFile            bool
+	sslAutoServerName         string
+	sslAutoOrganization       string
+	sslCAFile                 string
+	dockerEndpoint            string
+	dockerImage               string
+	dockerUser                string
+	dockerContainerName       string
+	dockerGCDelay             time.Duration
+	dockerNetHost             bool // Deprecated
+	dockerNetworkMode         string
+	dockerPrivileged          bool
 
 	maskAny = errors.WithStack
 )
 
 func init() {
 	f := cmdMain.Flags()
+	starterFlags = f
 
+	f.StringVarP(&configFile, "configuration", "c", "", "Configuration file to use")
 	f.StringVar(&masterAddress, "starter.join", "", "join a cluster with master at given address")
 	f.StringVar(&mode, "starter.mode", "cluster", "Set the mode of operation to use (cluster|single)")
 	f.BoolVar(&startLocalSlaves, "starter.local", false, "If set, local slaves will be started to create a machine local (test) cluster")
-	f.StringVar(&ownAddress, "starter.address", "", "address under which this server is reachable, needed for running in docker or in single mode")
+	f.IntVar(&localPortOffset, "starter.local.port-offset", service.DefaultPortLayout.FirstOffset, "Port offset of the first local slave (see --starter.local)")
+	f.IntVar(&localAgents, "starter.local.agents", 0, "Number of agents of a local test cluster (default --cluster.agency-size, see --starter.local)")
+	f.IntVar(&localDBServers, "starter.local.dbservers", 0, "Number of dbservers of a local test cluster (default one per agent, see --starter.local)")
+	f.IntVar(&localCoordinators, "starter.local.coordinators", 0, "Number of coordinators of a local test cluster (default one per agent, see --starter.local)")
+	f.IntVar(&localPortIncrement, "starter.local.port-increment", service.DefaultPortLayout.Increment, "Difference between the port offsets of subsequent local slaves (see --starter.local)")
+	f.StringVar(&ownAddress, "starter.address", "", "address under which this server is reachable, needed for running in docker or in single mode (auto-aws|auto-gcp|auto-azure[-public] detects it using the instance metadata service)")
+	f.StringVar(&discovery, "starter.discovery", "", "If set (etcd://host:port/prefix or consul://host:port/prefix), the master is elected using this coordination store instead of --starter.join")
+	f.StringVar(&starterInterface, "starter.interface", "", "If set, the address under which this server is reachable is taken from this network interface (e.g. eth1)")
+	f.StringVar(&bindAddress, "starter.bind-address", "", "IP address the starter and its servers listen on (default all interfaces). Use --starter.address to set the address advertised to peers")
 	f.StringVar(&id, "starter.id", "", "Unique identifier of this peer")
 	f.IntVar(&masterPort, "starter.port", service.DefaultMasterPort, "Port to listen on for other arangodb's to join")
+	f.Float64Var(&apiRateLimit, "starter.api.rate-limit", 0, "Requests per second every client (IP address) may send to the starter API, excess requests get status 429 (0 disables rate limiting, peers are never limited)")
+	f.IntVar(&apiRateBurst, "starter.api.rate-burst", 20, "Number of requests a client may send at once to the starter API before it is rate limited")
+	f.StringVar(&apiMaxBodySize, "starter.api.max-body-size", "1MiB", "Maximum size of request bodies of the starter API, larger requests get status 413 (0 means unlimited)")
+	f.StringVar(&apiCORSAllowedOrigins, "starter.api.cors-allowed-origins", "", "Comma separated origins (e.g. https://dashboard.example.com, or *) of browser-based dashboards that may call the starter API (CORS)")
+	f.BoolVar(&apiReadOnly, "starter.api.read-only", false, "If set, all mutating requests (e.g. shutdown, restart, upgrade) without a JWT signed with the JWT secret get status 403, inspection endpoints stay available")
+	f.StringVar(&starterListen, "starter.listen", "", "If set (unix:///path), the starter API is served on this unix socket. In single server mode no TCP port is opened for it")
 	f.BoolVar(&allPortOffsetsUnique, "starter.unique-port-offsets", false, "If set, all peers will get a unique port offset. If false (default) only portOffset+peerAddress pairs will be unique.")
+	f.BoolVar(&preferHostnames, "starter.prefer-hostnames", false, "If set, peers register & advertise DNS host names instead of IP addresses (for floating IPs or CNAME-based failover)")
+	f.BoolVar(&strictReproducibility, "starter.strict-reproducibility", false, "If set, digests of all external inputs are recorded in setup.json and the starter refuses to start when they have changed")
+	f.BoolVar(&acceptInputChanges, "starter.accept-changes", false, "If set, changed inputs are accepted and recorded (see --starter.strict-reproducibility)")
+	f.BoolVar(&standby, "starter.standby", false, "If set, this starter joins as a standby that runs no servers until it is activated")
+	f.StringVar(&joinToken, "starter.join-token", "", "Token that starters must know to join the cluster (default is the JWT secret, if any)")
+	f.BoolVar(&passive, "starter.passive", false, "If set, this starter joins as a passive peer that never runs servers, it only serves the starter API (for dashboards & automation)")
+	f.DurationVar(&standbyFailoverDelay, "starter.standby-failover-delay", 0, "If set, the master activates a standby once a peer has been unreachable for this long")
+	f.DurationVar(&masterFailoverDelay, "starter.master-failover-delay", time.Second*30, "Time after which another starter becomes master when the master is unreachable (0 disables master re-election)")
+	f.DurationVar(&clockSkewWarning, "starter.clock-skew-warning", time.Second, "Skew of the clock of a peer (relative to the master) that is logged as a warning (0 disables clock checks)")
+	f.DurationVar(&clockSkewLimit, "starter.clock-skew-limit", 0, "If set, a new peer whose clock is skewed more than this (relative to the master) cannot join")
+	f.BoolVar(&dryRun, "starter.dry-run", false, "If set, the servers that would be started are printed as JSON, without starting anything")
+	f.BoolVar(&offline, "starter.offline", false, "If set, the starter makes no calls to external networks: docker images are not pulled and arangod is not downloaded, they must be available locally")
 
 	f.StringVar(&dataDir, "data.dir", getEnvVar("DATA_DIR", "."), "directory to store all data")
 
 	f.BoolVar(&verbose, "log.verbose", false, "Turn on debug logging")
+	f.StringVar(&logLevels, "log.level", "", "Comma separated log levels of all components (<level>) or of a single component (<component>=<level>), with component service|runner|peers|api")
+	f.StringVar(&logRotateSize, "log.rotate-size", "0", "If set, the log files of the servers are rotated once they reach this size (e.g. 100MB, 0 disables rotation)")
+	f.IntVar(&logRotateFiles, "log.rotate-files", 5, "Number of compressed archives of rotated server log files to keep")
+	f.StringVar(&logForward, "log.forward", "", "If set, the logs of the servers are forwarded to this target (syslog|journald|tcp://host:port)")
+	f.StringVar(&logFormat, "log.format", service.LogFormatText, "Format of the log output (text|json)")
+
+	f.StringVar(&tracingEndpoint, "tracing.endpoint", "", "If set, spans of the bootstrap phases are exported to this OpenTelemetry collector (OTLP over HTTP, e.g. http://localhost:4318)")
+
+	f.StringVar(&coreDirectory, "core.directory", "", "If set, the servers run in this directory with core dumps enabled, cores are collected into crash bundles in the data directory")
+	f.IntVar(&coreLogLines, "core.log-lines", 100, "Number of most recent log lines of a crashed server added to its crash bundle")
+
+	f.StringVar(&backupSchedule, "backup.schedule", "", "If set, logical backups (arangodump) of all databases are created using this schedule (cron expression, e.g. \"0 2 * * *\")")
+	f.StringVar(&backupDir, "backup.dir", "", "Directory in which backups are created (default is backups in the data directory)")
+	f.IntVar(&backupKeep, "backup.keep", 7, "Number of successful backups to keep (0 keeps all backups)")
+
+	f.StringVar(&recoveryFromBackup, "recovery.from-backup", "", "If set, a new deployment is recovered from this backup (arangodump directory or <repository>/<id> of a hot backup) before it is reported ready")
+	f.StringVar(&recoveryRemoteConfig, "recovery.remote-config", "", "Path of a JSON file with the configuration of the remote repository of --recovery.from-backup")
+
+	f.StringVar(&bootstrapManifest, "bootstrap.manifest", "", "Path of a manifest (JSON) of databases, collections, users and permissions to create once a new deployment is up")
+	f.StringArrayVar(&bootstrapScripts, "bootstrap.js-script", nil, "Path of a JavaScript file executed using arangosh once a new deployment is up (can be repeated)")
+	f.StringArrayVar(&bootstrapFoxx, "bootstrap.foxx", nil, "Foxx service (<path of zip file>:<mountpoint>) installed once a new deployment is up (can be repeated)")
+	f.StringVar(&bootstrapImportDir, "bootstrap.import-dir", "", "Directory of JSONL, JSON, CSV or TSV files (and an optional import.json) imported using arangoimport once a new deployment is up")
+	f.IntVar(&bootstrapImportJobs, "bootstrap.import-parallelism", 2, "Number of files of --bootstrap.import-dir imported at the same time")
 
 	f.IntVar(&agencySize, "cluster.agency-size", 3, "Number of agents in the cluster")
 	f.BoolVar(&startCoordinator, "cluster.start-coordinator", true, "should a coordinator instance be started")
 	f.BoolVar(&startDBserver, "cluster.start-dbserver", true, "should a dbserver instance be started")
+	f.DurationVar(&agentStartupTimeout, "cluster.agent-startup-timeout", time.Minute*5, "Time an agent has to become ready after it has been started")
+	f.DurationVar(&dbserverStartupTimeout, "cluster.dbserver-startup-timeout", time.Minute*5, "Time a dbserver has to become ready after it has been started")
+	f.DurationVar(&coordinatorStartupTimeout, "cluster.coordinator-startup-timeout", time.Minute*5, "Time a coordinator has to become ready after it has been started")
+	f.BoolVar(&startSequential, "cluster.start-sequential", false, "If set, the agent, dbserver & coordinator are started one after another, each once the previous one is up")
+	f.DurationVar(&agentFailoverDelay, "cluster.agent-failover-delay", 0, "If set, the master moves the agent of a peer to another peer once the peer has been unreachable for this long")
+	f.IntVar(&coordinatorDBServers, "cluster.coordinator-wait-for-dbservers", 0, "Number of dbservers in the cluster that must be up before the coordinator is started (0 does not wait)")
+	f.IntVar(&agentPort, "cluster.agent-port", 0, "If set, agents listen on this port instead of the base port + offset")
+	f.StringVar(&agentPortRange, "cluster.agent-port-range", "", "If set, agents listen on a free port in this range (e.g. 5001-5005)")
+	f.IntVar(&dbserverPort, "cluster.dbserver-port", 0, "If set, dbservers listen on this port instead of the base port + offset")
+	f.StringVar(&dbserverPortRange, "cluster.dbserver-port-range", "", "If set, dbservers listen on a free port in this range (e.g. 6000-6010)")
+	f.IntVar(&coordinatorPort, "cluster.coordinator-port", 0, "If set, coordinators listen on this port instead of the base port + offset")
+	f.StringVar(&coordinatorPortRange, "cluster.coordinator-port-range", "", "If set, coordinators listen on a free port in this range (e.g. 7000-7010)")
 
 	f.StringVar(&arangodPath, "server.arangod", "/usr/sbin/arangod", "Path of arangod")
 	f.StringVar(&arangodJSPath, "server.js-dir", "/usr/share/arangodb3/js", "Path of arango JS folder")
 	f.StringVar(&rrPath, "server.rr", "", "Path of rr")
+	f.BoolVar(&serverDownload, "server.download", false, "If set, the official arangod binary of --server.version is downloaded (and cached) and used instead of --server.arangod")
+	f.StringVar(&serverVersion, "server.version", "", "Version of ArangoDB to download (e.g. 3.2.4). See --server.download")
+	f.StringVar(&serverDownloadURL, "server.download-url", service.DefaultDownloadURL, "Base URL of the server to download ArangoDB from")
+	f.StringVar(&serverDownloadSHA256, "server.download-sha256", "", "Expected SHA256 digest of the downloaded archive (default is the digest published next to the archive)")
+	f.StringVar(&serverDownloadDir, "server.download-dir", "~/.arangodb/downloads", "Directory in which downloaded versions of ArangoDB are cached")
 	f.IntVar(&serverThreads, "server.threads", 0, "Adjust server.threads of each server")
+	f.StringVar(&serverListen, "server.listen", "", "If set (unix:///path), the single server listens on this unix socket instead of a TCP port")
 	f.StringVar(&serverStorageEngine, "server.storage-engine", "mmfiles", "Type of storage engine to use (mmfiles|rocksdb) (3.2 and up)")
+	f.StringVar(&rocksdbPreset, "rocksdb.preset", "", "If set, RocksDB of dbservers & single servers is tuned for the detected memory & disk space using this preset (auto|small|large|write-heavy)")
+	f.StringVar(&licenseFile, "server.license-file", "", "If set, the (enterprise) license key in this file is passed to all servers")
+	f.StringVar(&licenseEnv, "server.license-env", "", "If set, the (enterprise) license key in this environment variable is passed to all servers")
+	f.StringVar(&restartPolicy, "server.restart-policy", service.RestartPolicyAlways, "When to restart servers that have terminated (always|on-failure|never)")
+	f.IntVar(&restartMaxRetries, "server.restart-max-retries", 100, "Maximum number of consecutive quick restarts of a server before it is marked as failed (0 is unlimited)")
+	f.DurationVar(&restartBackoffInitial, "server.restart-backoff", time.Second, "Time to wait before restarting a server that has terminated quickly, doubled on every consecutive quick failure")
+	f.DurationVar(&restartBackoffMax, "server.restart-backoff-max", time.Minute, "Maximum time to wait before restarting a server")
+	f.IntVar(&crashLoopRestarts, "server.crash-loop-restarts", 5, "Number of restarts within --server.crash-loop-window after which a server is in a crash loop (0 disables detection)")
+	f.DurationVar(&crashLoopWindow, "server.crash-loop-window", time.Minute*10, "Window of the crash loop detection")
+	f.StringVar(&crashLoopWebhook, "server.crash-loop-webhook", "", "If set, this URL is called (POST with a JSON event) when a server enters a crash loop")
+	f.StringVar(&notifyWebhookURL, "notify.webhook-url", "", "If set, lifecycle & health events (cluster-ready, server-crash, crash-loop, upgrade-start/finish, peer-added/removed) are posted as JSON to this URL")
+	f.StringVar(&metricsStatsd, "metrics.statsd", "", "If set (host:port), the metrics of the starter (health, restarts, startup phases, ...) are pushed to this statsd endpoint")
+	f.StringVar(&metricsStatsdPrefix, "metrics.statsd-prefix", service.DefaultStatsdPrefix, "Prefix of the names of the metrics pushed to statsd")
+	f.BoolVar(&metricsStatsdTags, "metrics.statsd-tags", false, "If set, labels are pushed as DogStatsD tags (e.g. for Datadog) instead of as part of the metric names")
+	f.DurationVar(&metricsStatsdInterval, "metrics.statsd-interval", time.Second*10, "Interval between pushes of the metrics to statsd")
+	f.StringVar(&notifyWebhookSecretFile, "notify.webhook-secret-file", "", "If set, notifications are signed (HMAC-SHA256 in the X-Arangodb-Signature header) using the secret in this file")
+	f.DurationVar(&livenessInterval, "server.liveness-interval", time.Second*30, "Interval at which servers are probed for liveness (0 disables probing)")
+	f.DurationVar(&livenessTimeout, "server.liveness-timeout", time.Second*10, "Time a server has to respond to a liveness probe")
+	f.IntVar(&livenessFailures, "server.liveness-failures", 3, "Number of consecutive liveness probes a server does not respond to, after which it is restarted")
+	f.DurationVar(&singleStartupTimeout, "server.single-startup-timeout", time.Minute*5, "Time a single server has to become ready after it has been started")
+	f.Uint64Var(&maxOpenFiles, "server.max-open-files", 0, "Limit of open files of servers (0 raises the limit to the hard limit)")
+	f.Uint64Var(&maxProcesses, "server.max-processes", 0, "Limit of processes of servers (0 raises the limit to the hard limit)")
+	f.IntVar(&serverNice, "server.nice", 0, "Nice value of all servers (0 leaves it unchanged)")
+	f.StringVar(&serverIONice, "server.ionice", "", "I/O scheduling priority (realtime|best-effort|idle[:level]) of all servers")
+	f.IntVar(&agentsNice, "agents.nice", 0, "Nice value of agents (overrides --server.nice)")
+	f.StringVar(&agentsIONice, "agents.ionice", "", "I/O scheduling priority of agents (overrides --server.ionice)")
+	f.IntVar(&dbserversNice, "dbservers.nice", 0, "Nice value of dbservers (overrides --server.nice)")
+	f.StringVar(&dbserversIONice, "dbservers.ionice", "", "I/O scheduling priority of dbservers (overrides --server.ionice)")
+	f.IntVar(&coordinatorsNice, "coordinators.nice", 0, "Nice value of coordinators (overrides --server.nice)")
+	f.StringVar(&coordinatorsIONice, "coordinators.ionice", "", "I/O scheduling priority of coordinators (overrides --server.ionice)")
+	f.StringVar(&dbserversNumactl, "dbservers.numactl", "", "If set, dbservers are started with numactl using these arguments (e.g. '--cpunodebind=1 --membind=1')")
+	f.StringVar(&dbserversCpuset, "dbservers.cpuset", "", "If set, dbservers are restricted to these CPUs (e.g. '0-7,16-23')")
+	f.StringVar(&serverDirTemplate, "server.dir-template", service.DefaultServerDirTemplate, "Name of the directory of every server, {type}, {port} & {id} (of the starter) are replaced")
+	f.StringVar(&agentsDir, "agents.dir", "", "If set, the directories of agents are created in this directory instead of the data directory")
+	f.StringVar(&dbserversDir, "dbservers.dir", "", "If set, the directories of dbservers are created in this directory instead of the data directory (e.g. on a dedicated disk)")
+	f.StringVar(&coordinatorsDir, "coordinators.dir", "", "If set, the directories of coordinators are created in this directory instead of the data directory")
+	f.IntVar(&proxyPort, "proxy.port", 0, "If set, the starter serves a proxy on this port that load-balances requests over the healthy coordinators of all peers")
+	f.DurationVar(&proxyHealthInterval, "proxy.health-interval", time.Second*5, "Interval at which the proxy checks the health of the coordinators")
+	f.StringVar(&hooksDir, "hooks.dir", "", "If set, scripts in this directory named pre-start, post-ready, pre-stop & canary-check are executed at these lifecycle points of the starter")
+	f.StringVar(&hooksPreStart, "hooks.pre-start", "", "Script executed before the servers are started (the servers are not started when it fails)")
+	f.StringVar(&hooksPostReady, "hooks.post-ready", "", "Script executed once all servers of the starter are up")
+	f.StringVar(&hooksPreStop, "hooks.pre-stop", "", "Script executed before the servers are stopped")
+	f.StringVar(&hooksCanaryCheck, "hooks.canary-check", "", "Script executed (on the master) to validate the deployment after the canary phase of a canary upgrade")
+	f.DurationVar(&hooksTimeout, "hooks.timeout", time.Minute, "Time a hook script may run before it is killed")
+	f.StringVar(&diskMinFree, "disk.min-free", "1GiB", "Minimum free disk space of the data directory (and --<type>s.dir), checked before the servers are started and periodically afterwards (0 disables the checks)")
+	f.DurationVar(&diskCheckInterval, "disk.check-interval", time.Minute, "Interval between checks of the free disk space (0 only checks before the servers are started)")
+	f.StringVar(&memoryTotal, "memory.total", "auto", "Total memory available to all servers on this machine (e.g. 64GiB), divided amongst the servers (auto detects it, 0 lets every server detect it)")
 
 	f.StringVar(&dockerEndpoint, "docker.endpoint", "unix:///var/run/docker.sock", "Endpoint used to reach the docker daemon")
 	f.StringVar(&dockerImage, "docker.image", getEnvVar("DOCKER_IMAGE", ""), "name of the Docker image to use to launch arangod instances (leave empty to avoid using docker)")
@@ -131,6 +361,8 @@ func init() {
 	f.BoolVar(&dockerPrivileged, "docker.privileged", false, "Run containers with --privileged")
 
 	f.StringVar(&jwtSecretFile, "auth.jwt-secret", "", "name of a plain text file containing a JWT secret used for server authentication")
+	f.StringVar(&rootPassword, "auth.root-password", "", "Password of the root user, set when the deployment is bootstrapped (never on relaunch)")
+	f.StringVar(&rootPasswordFile, "auth.root-password-file", "", "name of a plain text file containing the password of the root user, see --auth.root-password")
 
 	f.StringVar(&sslKeyFile, "ssl.keyfile", "", "path of a PEM encoded file containing a server certificate + private key")
 	f.StringVar(&sslCAFile, "ssl.cafile", "", "path of a PEM encoded file containing a CA certificate used for client authentication")
@@ -188,8 +420,15 @@ func handleSignal(sigChannel chan os.Signal, cancel context.CancelFunc) {
 	signalCount := 0
 	for s := range sigChannel {
 		signalCount++
-		fmt.Println("Received signal:", s)
+		log.Infof("Received signal: %s", s)
 		if signalCount > 1 {
+			if os.Getpid() == 1 {
+				// Exiting the init process of a container kills all its processes at once,
+				// so pass the signal on to the servers instead.
+				log.Infof("Forwarding signal %s to all servers", s)
+				service.SignalManagedProcesses(s)
+				continue
+			}
 			os.Exit(1)
 		}
 		cancel()
@@ -259,23 +498,29 @@ func main() {
 	// Find executable and jsdir default in a platform dependent way:
 	findExecutable()
 
+	// Passthrough options cannot be parsed by cobra, extract them first
+	args, options := extractPassthroughOptions(os.Args[1:])
+	passthroughOptions = options
+	cmdMain.SetArgs(args)
+
 	cmdMain.Execute()
 }
 
 func cmdMainRun(cmd *cobra.Command, args []string) {
-	log.Infof("Starting %s version %s, build %s", projectName, projectVersion, projectBuild)
-
 	if len(args) > 0 {
 		log.Fatalf("Expected no arguments, got %q", args)
 	}
 
-	// Setup log level
-	if verbose {
-		logging.SetLevel(logging.DEBUG, projectName)
-	} else {
-		logging.SetLevel(logging.INFO, projectName)
+	// Load configuration file (if any)
+	if err := loadConfigFile(cmd.Flags()); err != nil {
+		log.Fatal(err.Error())
 	}
 
+	// Setup log format & level
+	applyLogFormat()
+	applyLogLevel()
+	log.Infof("Starting %s version %s, build %s", projectName, projectVersion, projectBuild)
+
 	// Auto detect docker container ID (if needed)
 	if isRunningInDocker() && dockerContainerName == "" {
 		id, err := findDockerContainerName(dockerEndpoint)
@@ -286,21 +531,40 @@ func cmdMainRun(cmd *cobra.Command, args []string) {
 	}
 
 	// Some plausibility checks:
-	if agencySize%2 == 0 || agencySize <= 0 {
-		log.Fatal("Error: cluster.agency-size needs to be a positive, odd number.")
+	for _, p := range checkOptions() {
+		if p.Severity == severityError {
+			log.Fatal("Error: " + p.Message)
+		}
+		log.Warningf("--%s %s", p.Option, p.Message)
 	}
-	if agencySize == 1 && ownAddress == "" {
-		log.Fatal("Error: if cluster.agency-size==1, starter.address must be given.")
+	if dockerNetHost && dockerNetworkMode == "" {
+		dockerNetworkMode = "host"
 	}
-	if dockerImage != "" && rrPath != "" {
-		log.Fatal("Error: using --docker.image and --server.rr is not possible.")
+	if size := localClusterSize(); size.IsSet() {
+		// The agency of a local test cluster is sized by --starter.local.agents
+		agencySize = size.Agents
 	}
-	if dockerNetHost {
-		if dockerNetworkMode == "" {
-			dockerNetworkMode = "host"
-		} else if dockerNetworkMode != "host" {
-			log.Fatal("Error: cannot set --docker.net-host and --docker.net-mode at the same time")
+	if service.IsCloudAddress(ownAddress) {
+		addr, err := service.DetectCloudAddress(ownAddress)
+		if err != nil {
+			log.Fatalf("Cannot detect address using --starter.address=%s: %v", ownAddress, err)
+		}
+		log.Infof("Detected address %s using --starter.address=%s", addr, ownAddress)
+		ownAddress = addr
+	} else if ownAddress == "" && preferHostnames {
+		name, err := service.OwnHostName()
+		if err != nil {
+			log.Fatalf("Cannot detect host name using --starter.prefer-hostnames: %v", err)
+		}
+		log.Infof("Using host name %s as address", name)
+		ownAddress = name
+	} else if ownAddress == "" && starterInterface != "" {
+		addr, err := service.InterfaceAddress(starterInterface)
+		if err != nil {
+			log.Fatalf("Cannot detect address using --starter.interface=%s: %v", starterInterface, err)
 		}
+		log.Infof("Using address %s of network interface %s", addr, starterInterface)
+		ownAddress = addr
 	}
 	log.Debugf("Using %s as default arangod executable.", arangodPath)
 	log.Debugf("Using %s as default JS dir.", arangodJSPath)
@@ -311,18 +575,108 @@ func cmdMainRun(cmd *cobra.Command, args []string) {
 	rrPath = mustExpand(rrPath)
 	dataDir = mustExpand(dataDir)
 	jwtSecretFile = mustExpand(jwtSecretFile)
+	licenseFile = mustExpand(licenseFile)
+	notifyWebhookSecretFile = mustExpand(notifyWebhookSecretFile)
+	rootPasswordFile = mustExpand(rootPasswordFile)
 	sslKeyFile = mustExpand(sslKeyFile)
 	sslCAFile = mustExpand(sslCAFile)
+	coreDirectory = mustExpand(coreDirectory)
+	serverDownloadDir = mustExpand(serverDownloadDir)
+	backupDir = mustExpand(backupDir)
+	recoveryRemoteConfig = mustExpand(recoveryRemoteConfig)
+	agentsDir = mustExpand(agentsDir)
+	dbserversDir = mustExpand(dbserversDir)
+	coordinatorsDir = mustExpand(coordinatorsDir)
+	hooksDir = mustExpand(hooksDir)
+	hooksPreStart = mustExpand(hooksPreStart)
+	hooksPostReady = mustExpand(hooksPostReady)
+	hooksPreStop = mustExpand(hooksPreStop)
+	hooksCanaryCheck = mustExpand(hooksCanaryCheck)
 
 	// Sort out work directory:
 	if len(dataDir) == 0 {
 		dataDir = "."
 	}
 	dataDir, _ = filepath.Abs(dataDir)
-	if err := os.MkdirAll(dataDir, 0755); err != nil {
+	if coreDirectory != "" {
+		coreDirectory, _ = filepath.Abs(coreDirectory)
+	}
+	if dockerImage == "" {
+		// Servers are started in their core directory, so relative paths must be resolved first.
+		// Executables given without a directory are still looked up in the PATH.
+		for _, path := range []*string{&arangodPath, &rrPath} {
+			if strings.ContainsRune(*path, filepath.Separator) || strings.ContainsRune(*path, '/') {
+				*path, _ = filepath.Abs(*path)
+			}
+		}
+		if arangodJSPath != "" {
+			arangodJSPath, _ = filepath.Abs(arangodJSPath)
+		}
+	}
+	if backupDir != "" {
+		backupDir, _ = filepath.Abs(backupDir)
+	}
+	for _, path := range []*string{&hooksDir, &hooksPreStart, &hooksPostReady, &hooksPreStop, &hooksCanaryCheck} {
+		if *path != "" {
+			*path, _ = filepath.Abs(*path)
+		}
+	}
+	if recoveryFromBackup != "" && !strings.Contains(recoveryFromBackup, "://") {
+		recoveryFromBackup, _ = filepath.Abs(mustExpand(recoveryFromBackup))
+	}
+	if bootstrapManifest != "" {
+		bootstrapManifest, _ = filepath.Abs(mustExpand(bootstrapManifest))
+	}
+	for i, script := range bootstrapScripts {
+		bootstrapScripts[i], _ = filepath.Abs(mustExpand(script))
+	}
+	if bootstrapImportDir != "" {
+		bootstrapImportDir, _ = filepath.Abs(mustExpand(bootstrapImportDir))
+	}
+	var foxxServices []service.FoxxService
+	for _, spec := range bootstrapFoxx {
+		if f, err := service.ParseFoxxService(spec); err == nil {
+			f.Source, _ = filepath.Abs(mustExpand(f.Source))
+			foxxServices = append(foxxServices, f)
+		}
+	}
+	if dryRun {
+		// Do not change anything on disk
+	} else if err := os.MkdirAll(dataDir, 0755); err != nil {
 		log.Fatalf("Cannot create data directory %s because %v, giving up.", dataDir, err)
 	}
 
+	// Download arangod (if needed)
+	if serverDownload {
+		options := service.DownloadArangodOptions{
+			Version:  serverVersion,
+			URL:      serverDownloadURL,
+			SHA256:   serverDownloadSHA256,
+			CacheDir: serverDownloadDir,
+		}
+		if p, jsPath, found := downloadArangod(options); found {
+			arangodPath, arangodJSPath = p, jsPath
+		}
+	}
+	serverBinaries := resolveServerBinaries()
+
+	// Fail fast when an artifact is missing in offline mode
+	if offline {
+		if dockerImage != "" {
+			if err := service.CheckOfflineDockerImage(dockerEndpoint, dockerImage); err != nil {
+				log.Fatal(err.Error())
+			}
+			for _, b := range serverBinaries {
+				if b.DockerImage != "" {
+					if err := service.CheckOfflineDockerImage(dockerEndpoint, b.DockerImage); err != nil {
+						log.Fatal(err.Error())
+					}
+				}
+			}
+		}
+		logOfflineEndpoints()
+	}
+
 	// Read jwtSecret (if any)
 	var jwtSecret string
 	if jwtSecretFile != "" {
@@ -333,23 +687,56 @@ func cmdMainRun(cmd *cobra.Command, args []string) {
 		jwtSecret = strings.TrimSpace(string(content))
 	}
 
+	// Read webhook secret (if any)
+	var notifyWebhookSecret string
+	if notifyWebhookSecretFile != "" {
+		content, err := ioutil.ReadFile(notifyWebhookSecretFile)
+		if err != nil {
+			log.Fatalf("Failed to read webhook secret file '%s': %v", notifyWebhookSecretFile, err)
+		}
+		notifyWebhookSecret = strings.TrimSpace(string(content))
+	}
+
+	// Read root password (if any)
+	bootstrapRootPassword := rootPassword
+	if rootPasswordFile != "" {
+		content, err := ioutil.ReadFile(rootPasswordFile)
+		if err != nil {
+			log.Fatalf("Failed to read root password file '%s': %v", rootPasswordFile, err)
+		}
+		bootstrapRootPassword = strings.TrimRight(string(content), "\r\n")
+	}
+
+	// Read license key (if any)
+	licenseKey, err := readLicenseKey()
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+
 	// Auto create key file (if needed)
 	if sslAutoKeyFile {
-		if sslKeyFile != "" {
-			log.Fatalf("Cannot specify both --ssl.auto-key and --ssl.keyfile")
-		}
 		hosts := []string{"arangod.server"}
 		if sslAutoServerName != "" {
 			hosts = []string{sslAutoServerName}
 		}
 		if ownAddress != "" {
-			hosts = append(hosts, ownAddress)
+			hosts = append(hosts, strings.Trim(ownAddress, "[]"))
+		}
+		keyFileDir := dataDir
+		if dryRun {
+			// Do not change anything in the data directory
+			tmpDir, err := ioutil.TempDir("", "arangodb-dry-run-")
+			if err != nil {
+				log.Fatalf("Failed to create temporary directory: %v", err)
+			}
+			defer os.RemoveAll(tmpDir)
+			keyFileDir = tmpDir
 		}
 		keyFile, err := service.CreateCertificate(service.CreateCertificateOptions{
 			Hosts:        hosts,
 			RSABits:      2048,
 			Organization: sslAutoOrganization,
-		}, dataDir)
+		}, keyFileDir)
 		if err != nil {
 			log.Fatalf("Failed to create keyfile: %v", err)
 		}
@@ -358,52 +745,467 @@ func cmdMainRun(cmd *cobra.Command, args []string) {
 	}
 
 	// Interrupt signal:
-	sigChannel := make(chan os.Signal)
-	rootCtx, cancel := context.WithCancel(context.Background())
+	sigChannel := make(chan os.Signal, 1)
+	rootCtx, cancel := context.WithCancel(runContext)
 	signal.Notify(sigChannel, os.Interrupt, syscall.SIGTERM)
 	go handleSignal(sigChannel, cancel)
 
+	// Reap orphaned processes when running as init process (e.g. as entrypoint of a container)
+	if os.Getpid() == 1 {
+		log.Info("Running as init process, orphaned processes are reaped")
+		service.ReapOrphans(rootCtx, log)
+	}
+
+	// Reload signal (handled once the service has been created, since a reload changes the options read below):
+	hupChannel := make(chan os.Signal, 1)
+	signal.Notify(hupChannel, syscall.SIGHUP)
+
 	// Create service
-	service, err := service.NewService(log, service.Config{
-		ID:                   id,
-		Mode:                 mode,
-		AgencySize:           agencySize,
-		ArangodPath:          arangodPath,
-		ArangodJSPath:        arangodJSPath,
-		MasterPort:           masterPort,
-		RrPath:               rrPath,
-		StartCoordinator:     startCoordinator,
-		StartDBserver:        startDBserver,
-		StartLocalSlaves:     startLocalSlaves,
-		DataDir:              dataDir,
-		OwnAddress:           ownAddress,
-		MasterAddress:        masterAddress,
-		Verbose:              verbose,
-		ServerThreads:        serverThreads,
-		ServerStorageEngine:  serverStorageEngine,
-		AllPortOffsetsUnique: allPortOffsetsUnique,
-		JwtSecret:            jwtSecret,
-		SslKeyFile:           sslKeyFile,
-		SslCAFile:            sslCAFile,
-		RunningInDocker:      isRunningInDocker(),
-		DockerContainerName:  dockerContainerName,
-		DockerEndpoint:       dockerEndpoint,
-		DockerImage:          dockerImage,
-		DockerUser:           dockerUser,
-		DockerGCDelay:        dockerGCDelay,
-		DockerNetworkMode:    dockerNetworkMode,
-		DockerPrivileged:     dockerPrivileged,
-		ProjectVersion:       projectVersion,
-		ProjectBuild:         projectBuild,
-	}, false)
+	service, err := service.NewService(service.Config{
+		ID:                        id,
+		Mode:                      mode,
+		AgencySize:                agencySize,
+		ArangodPath:               arangodPath,
+		ArangodJSPath:             arangodJSPath,
+		ServerBinaries:            serverBinaries,
+		MasterPort:                masterPort,
+		RrPath:                    rrPath,
+		StartCoordinator:          startCoordinator,
+		StartDBserver:             startDBserver,
+		StartLocalSlaves:          startLocalSlaves,
+		DataDir:                   dataDir,
+		OwnAddress:                ownAddress,
+		BindAddress:               bindAddress,
+		Discovery:                 discovery,
+		MasterAddress:             masterAddress,
+		Verbose:                   verbose,
+		LogFormat:                 logFormat,
+		LogRotateSize:             mustParseByteSize(logRotateSize),
+		LogRotateFiles:            logRotateFiles,
+		LogForward:                logForward,
+		TracingEndpoint:           tracingEndpoint,
+		CoreDirectory:             coreDirectory,
+		CoreLogLines:              coreLogLines,
+		BackupSchedule:            backupSchedule,
+		BackupDir:                 backupDir,
+		BackupKeep:                backupKeep,
+		RecoveryFromBackup:        recoveryFromBackup,
+		RestartPolicy:             restartPolicy,
+		RestartMaxRetries:         restartMaxRetries,
+		RestartBackoffInitial:     restartBackoffInitial,
+		RestartBackoffMax:         restartBackoffMax,
+		CrashLoopRestarts:         crashLoopRestarts,
+		CrashLoopWindow:           crashLoopWindow,
+		CrashLoopWebhook:          crashLoopWebhook,
+		NotifyWebhookURL:          notifyWebhookURL,
+		NotifyWebhookSecret:       notifyWebhookSecret,
+		APIRateLimit:              apiRateLimit,
+		APIRateBurst:              apiRateBurst,
+		APIMaxBodySize:            mustParseByteSize(apiMaxBodySize),
+		APICORSAllowedOrigins:     corsAllowedOrigins(),
+		APIReadOnly:               apiReadOnly,
+		MetricsStatsd:             metricsStatsd,
+		MetricsStatsdPrefix:       metricsStatsdPrefix,
+		MetricsStatsdTags:         metricsStatsdTags,
+		MetricsStatsdInterval:     metricsStatsdInterval,
+		LivenessInterval:          livenessInterval,
+		ProxyPort:                 proxyPort,
+		ProxyHealthInterval:       proxyHealthInterval,
+		LivenessTimeout:           livenessTimeout,
+		LivenessFailures:          livenessFailures,
+		AgentStartupTimeout:       agentStartupTimeout,
+		ServerPortRanges:          serverPortRanges(),
+		LocalPortLayout:           localPortLayout(),
+		LocalServers:              localClusterSize(),
+		ServerDirTemplate:         serverDirTemplate,
+		ServerDirs:                serverDirs(),
+		DBServerStartupTimeout:    dbserverStartupTimeout,
+		CoordinatorStartupTimeout: coordinatorStartupTimeout,
+		StartSequential:           startSequential,
+		Offline:                   offline,
+		LicenseKey:                licenseKey,
+		RootPassword:              bootstrapRootPassword,
+		CoordinatorDBServers:      coordinatorDBServers,
+		SingleStartupTimeout:      singleStartupTimeout,
+		MaxOpenFiles:              maxOpenFiles,
+		MaxProcesses:              maxProcesses,
+		Nice:                      serverNiceValues(),
+		IONice:                    serverIONiceValues(),
+		DBServerNumactl:           dbserversNumactl,
+		DBServerCpuset:            dbserversCpuset,
+		StarterListen:             starterListen,
+		ServerListen:              serverListen,
+		MemoryTotal:               totalMemory(),
+		Hooks:                     hooks(),
+		HooksDir:                  hooksDir,
+		HookTimeout:               hooksTimeout,
+		DiskMinFree:               uint64(mustParseByteSize(diskMinFree)),
+		DiskCheckInterval:         diskCheckInterval,
+		RecoveryRemoteConfig:      recoveryRemoteConfig,
+		BootstrapManifest:         bootstrapManifest,
+		BootstrapScripts:          bootstrapScripts,
+		BootstrapFoxx:             foxxServices,
+		BootstrapImportDir:        bootstrapImportDir,
+		BootstrapImportJobs:       bootstrapImportJobs,
+		ServerThreads:             serverThreads,
+		ServerStorageEngine:       serverStorageEngine,
+		RocksDBPreset:             rocksdbPreset,
+		AllPortOffsetsUnique:      allPortOffsetsUnique,
+		PreferHostnames:           preferHostnames,
+		Standby:                   standby,
+		Passive:                   passive,
+		StandbyFailoverDelay:      standbyFailoverDelay,
+		MasterFailoverDelay:       masterFailoverDelay,
+		ClockSkewWarning:          clockSkewWarning,
+		ClockSkewLimit:            clockSkewLimit,
+		AgentFailoverDelay:        agentFailoverDelay,
+		PassthroughOptions:        passthroughOptions,
+		JwtSecret:                 jwtSecret,
+		JoinToken:                 joinToken,
+		SslKeyFile:                sslKeyFile,
+		SslCAFile:                 sslCAFile,
+		SslAutoKeyFile:            sslAutoKeyFile,
+		ConfigFile:                usedConfigFile,
+		StrictReproducibility:     strictReproducibility,
+		AcceptInputChanges:        acceptInputChanges,
+		RunningInDocker:           isRunningInDocker(),
+		DockerContainerName:       dockerContainerName,
+		DockerEndpoint:            dockerEndpoint,
+		DockerImage:               dockerImage,
+		DockerUser:                dockerUser,
+		DockerGCDelay:             dockerGCDelay,
+		DockerNetworkMode:         dockerNetworkMode,
+		DockerPrivileged:          dockerPrivileged,
+		ProjectVersion:            projectVersion,
+		ProjectBuild:              projectBuild,
+	}, service.WithReloader(reloadConfigFile), service.WithConfigProvider(effectiveConfig), service.WithLogLevelSetter(setLogLevels))
 	if err != nil {
 		log.Fatalf("Failed to create service: %#v", err)
 	}
+	go handleReloadSignal(hupChannel)
+
+	// Only show what would be started (if requested)
+	if dryRun {
+		plan, err := service.DryRun()
+		if err != nil {
+			log.Fatalf("Dry run failed: %v", err)
+		}
+		encoded, err := json.MarshalIndent(plan, "", "  ")
+		if err != nil {
+			log.Fatalf("Failed to encode dry run plan: %v", err)
+		}
+		fmt.Println(string(encoded))
+		return
+	}
 
 	// Run the service
 	service.Run(rootCtx)
 }
 
+// applyLogFormat sets the backend of the log according to the log format option.
+func applyLogFormat() {
+	if logFormat == service.LogFormatJSON {
+		logging.SetBackend(service.NewJSONLogBackend(os.Stderr, nil))
+	}
+}
+
+// applyLogLevel sets the log level of all components according to the verbose & log level options.
+func applyLogLevel() {
+	level := logging.INFO
+	if verbose {
+		level = logging.DEBUG
+	}
+	levels, err := parseLogLevels(logLevels)
+	if err != nil {
+		log.Warningf("Ignoring log levels: %v", err)
+	}
+	if l, found := levels[""]; found {
+		level = l
+	}
+	logging.SetLevel(level, projectName)
+	for _, component := range service.LogComponents {
+		if l, found := levels[component]; found {
+			logging.SetLevel(l, component)
+		} else {
+			logging.SetLevel(level, component)
+		}
+	}
+}
+
+// setLogLevels changes the log levels of all components to the given comma separated list of levels.
+func setLogLevels(levels string) error {
+	if _, err := parseLogLevels(levels); err != nil {
+		return maskAny(err)
+	}
+	configFileMutex.Lock()
+	defer configFileMutex.Unlock()
+	logLevels = levels
+	applyLogLevel()
+	return nil
+}
+
+// parseLogLevels parses the given comma separated list of log levels (<level> or <component>=<level>).
+// The returned map contains the level per component, levels for all components use
+// an empty component name.
+func parseLogLevels(list string) (map[string]logging.Level, error) {
+	result := make(map[string]logging.Level)
+	for _, value := range strings.Split(list, ",") {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+		component := ""
+		levelName := value
+		if parts := strings.SplitN(value, "=", 2); len(parts) == 2 {
+			component, levelName = parts[0], parts[1]
+			found := false
+			for _, c := range service.LogComponents {
+				found = found || c == component
+			}
+			if !found {
+				return nil, maskAny(fmt.Errorf("Unknown log component '%s'", component))
+			}
+		}
+		level, err := logging.LogLevel(levelName)
+		if err != nil {
+			return nil, maskAny(fmt.Errorf("Unknown log level '%s'", levelName))
+		}
+		result[component] = level
+	}
+	return result, nil
+}
+
+// parseByteSize parses a size in bytes with an optional unit suffix (KB, MB, GB, TB or KiB, MiB, GiB, TiB).
+func parseByteSize(s string) (int64, error) {
+	value := strings.ToUpper(strings.TrimSpace(s))
+	multiplier := int64(1)
+	for _, unit := range []struct {
+		suffix     string
+		multiplier int64
+	}{{"TIB", 1 << 40}, {"GIB", 1 << 30}, {"MIB", 1 << 20}, {"KIB", 1 << 10},
+		{"TB", 1 << 40}, {"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10},
+		{"T", 1 << 40}, {"G", 1 << 30}, {"M", 1 << 20}, {"K", 1 << 10}, {"B", 1}} {
+		if strings.HasSuffix(value, unit.suffix) {
+			value = strings.TrimSpace(strings.TrimSuffix(value, unit.suffix))
+			multiplier = unit.multiplier
+			break
+		}
+	}
+	size, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || size < 0 {
+		return 0, maskAny(fmt.Errorf("Invalid size '%s'", s))
+	}
+	return size * multiplier, nil
+}
+
+// mustParseByteSize performs a parseByteSize and fails on errors.
+func mustParseByteSize(s string) int64 {
+	size, err := parseByteSize(s)
+	if err != nil {
+		log.Fatalf("Cannot parse size: %v", err)
+	}
+	return size
+}
+
+// serverNiceValues returns the nice value of each server type, built from --server.nice
+// and the server type specific overrides.
+func serverNiceValues() map[service.ServerType]int {
+	result := map[service.ServerType]int{
+		service.ServerTypeAgent:       serverNice,
+		service.ServerTypeDBServer:    serverNice,
+		service.ServerTypeCoordinator: serverNice,
+		service.ServerTypeSingle:      serverNice,
+	}
+	for serverType, nice := range map[service.ServerType]int{
+		service.ServerTypeAgent:       agentsNice,
+		service.ServerTypeDBServer:    dbserversNice,
+		service.ServerTypeCoordinator: coordinatorsNice,
+	} {
+		if nice != 0 {
+			result[serverType] = nice
+		}
+	}
+	return result
+}
+
+// serverIONiceValues returns the I/O scheduling priority of each server type, built from --server.ionice
+// and the server type specific overrides.
+func serverIONiceValues() map[service.ServerType]string {
+	result := map[service.ServerType]string{
+		service.ServerTypeAgent:       serverIONice,
+		service.ServerTypeDBServer:    serverIONice,
+		service.ServerTypeCoordinator: serverIONice,
+		service.ServerTypeSingle:      serverIONice,
+	}
+	for serverType, ionice := range map[service.ServerType]string{
+		service.ServerTypeAgent:       agentsIONice,
+		service.ServerTypeDBServer:    dbserversIONice,
+		service.ServerTypeCoordinator: coordinatorsIONice,
+	} {
+		if ionice != "" {
+			result[serverType] = ionice
+		}
+	}
+	return result
+}
+
+// serverPortRangeOptions returns the value of --cluster.<type>-port-range (or --cluster.<type>-port)
+// of each server type, empty when neither is set.
+func serverPortRangeOptions() map[service.ServerType]string {
+	value := func(port int, portRange string) string {
+		if portRange == "" && port != 0 {
+			return strconv.Itoa(port)
+		}
+		return portRange
+	}
+	return map[service.ServerType]string{
+		service.ServerTypeAgent:       value(agentPort, agentPortRange),
+		service.ServerTypeDBServer:    value(dbserverPort, dbserverPortRange),
+		service.ServerTypeCoordinator: value(coordinatorPort, coordinatorPortRange),
+	}
+}
+
+// serverPortRanges returns the configured ports of each server type that does not use
+// the base port + offset scheme.
+func serverPortRanges() map[service.ServerType]service.PortRange {
+	result := make(map[service.ServerType]service.PortRange)
+	for serverType, value := range serverPortRangeOptions() {
+		if value == "" {
+			continue
+		}
+		r, err := service.ParsePortRange(value)
+		if err != nil {
+			log.Fatalf("Invalid ports for %s servers: %v", serverType, err)
+		}
+		result[serverType] = r
+	}
+	return result
+}
+
+// localPortLayout returns the layout of the port offsets of local slaves, as given by
+// --starter.local.port-offset & --starter.local.port-increment.
+// It returns the zero layout when no local slaves are started.
+func localPortLayout() service.PortLayout {
+	if !startLocalSlaves {
+		return service.PortLayout{}
+	}
+	return service.PortLayout{
+		FirstOffset: localPortOffset,
+		Increment:   localPortIncrement,
+	}
+}
+
+// hooks returns the scripts executed at the lifecycle points of the starter,
+// as given by --hooks.pre-start, --hooks.post-ready, --hooks.pre-stop & --hooks.canary-check.
+func hooks() map[service.Hook]string {
+	result := make(map[service.Hook]string)
+	for hook, script := range map[service.Hook]string{
+		service.HookPreStart:    hooksPreStart,
+		service.HookPostReady:   hooksPostReady,
+		service.HookPreStop:     hooksPreStop,
+		service.HookCanaryCheck: hooksCanaryCheck,
+	} {
+		if script != "" {
+			result[hook] = script
+		}
+	}
+	return result
+}
+
+// corsAllowedOrigins returns the origins that may call the starter API, as given by --starter.api.cors-allowed-origins.
+func corsAllowedOrigins() []string {
+	var result []string
+	for _, origin := range strings.Split(apiCORSAllowedOrigins, ",") {
+		if origin = strings.TrimSpace(origin); origin != "" {
+			result = append(result, origin)
+		}
+	}
+	return result
+}
+
+// serverDirs returns the directory containing the directories of the servers of each type,
+// as given by --agents.dir, --dbservers.dir & --coordinators.dir.
+// Server types without such a directory use the data directory.
+func serverDirs() map[service.ServerType]string {
+	result := make(map[service.ServerType]string)
+	for serverType, dir := range map[service.ServerType]string{
+		service.ServerTypeAgent:       agentsDir,
+		service.ServerTypeDBServer:    dbserversDir,
+		service.ServerTypeCoordinator: coordinatorsDir,
+	} {
+		if dir != "" {
+			dir, _ = filepath.Abs(dir)
+			result[serverType] = dir
+		}
+	}
+	return result
+}
+
+// localClusterSize returns the number of servers of each type of a local test cluster, as given by
+// --starter.local.agents, --starter.local.dbservers & --starter.local.coordinators.
+// It returns the zero value when none of these options is set (or no local slaves are started).
+func localClusterSize() service.LocalClusterSize {
+	if !startLocalSlaves || (localAgents == 0 && localDBServers == 0 && localCoordinators == 0) {
+		return service.LocalClusterSize{}
+	}
+	size := service.LocalClusterSize{
+		Agents:       localAgents,
+		DBServers:    localDBServers,
+		Coordinators: localCoordinators,
+	}
+	if size.Agents == 0 {
+		size.Agents = agencySize
+	}
+	if size.DBServers == 0 {
+		size.DBServers = size.Agents
+	}
+	if size.Coordinators == 0 {
+		size.Coordinators = size.Agents
+	}
+	return size
+}
+
+// totalMemory returns the memory available to all servers on this machine, as given by --memory.total.
+// It returns 0 when every server has to detect the total memory itself.
+func totalMemory() uint64 {
+	if memoryTotal == "auto" {
+		total, err := service.DetectTotalMemory()
+		if err != nil {
+			log.Warningf("Cannot detect total memory, every server detects it itself: %v", err)
+			return 0
+		}
+		log.Infof("Detected %d MB of memory", total>>20)
+		return total
+	}
+	return uint64(mustParseByteSize(memoryTotal))
+}
+
+// readLicenseKey reads & validates the license key given by --server.license-file or --server.license-env.
+// Returns an empty key if neither is set.
+func readLicenseKey() (string, error) {
+	var key, source string
+	if licenseFile != "" {
+		content, err := ioutil.ReadFile(mustExpand(licenseFile))
+		if err != nil {
+			return "", maskAny(fmt.Errorf("Failed to read license file '%s': %v", licenseFile, err))
+		}
+		key, source = string(content), licenseFile
+	} else if licenseEnv != "" {
+		value, found := os.LookupEnv(licenseEnv)
+		if !found {
+			return "", maskAny(fmt.Errorf("Environment variable %s (of --server.license-env) is not set", licenseEnv))
+		}
+		key, source = value, "environment variable "+licenseEnv
+	} else {
+		return "", nil
+	}
+	key = strings.TrimSpace(key)
+	if _, err := service.ParseLicenseKey(key); err != nil {
+		return "", maskAny(fmt.Errorf("Invalid license key in %s: %v", source, err))
+	}
+	return key, nil
+}
+
 // getEnvVar returns the value of the environment variable with given key of the given default
 // value of no such variable exist or is empty.
 func getEnvVar(key, defaultValue string) string {
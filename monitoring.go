@@ -0,0 +1,266 @@
+//
+// DISCLAIMER
+//
+// Copyright 2017 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+// Author Ewout Prangsma
+//
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/arangodb-helper/arangodb/client"
+	"github.com/arangodb-helper/arangodb/service"
+	"github.com/spf13/cobra"
+)
+
+var (
+	cmdCreateMonitoring = &cobra.Command{
+		Use:   "monitoring",
+		Short: "Create a Prometheus scrape configuration & Grafana dashboard for a running deployment",
+		Run:   cmdCreateMonitoringRun,
+	}
+	monitoringOptions struct {
+		endpoint          string
+		output            string
+		deployment        string
+		serverMetricsPath string
+		bearerTokenFile   string
+		scrapeInterval    time.Duration
+	}
+)
+
+func init() {
+	f := cmdCreateMonitoring.Flags()
+	addStarterEndpointFlag(f, &monitoringOptions.endpoint)
+	f.StringVar(&monitoringOptions.output, "output", ".", "Directory the scrape configuration & dashboard are written to")
+	f.StringVar(&monitoringOptions.deployment, "deployment", projectName, "Name of the deployment, added as deployment label to all scraped metrics")
+	f.StringVar(&monitoringOptions.serverMetricsPath, "server.metrics-path", "/_admin/metrics/v2", "Path of the metrics of the servers")
+	f.StringVar(&monitoringOptions.bearerTokenFile, "bearer-token-file", "", "File (on the Prometheus machine) holding a JWT token used to scrape the metrics of the servers")
+	f.DurationVar(&monitoringOptions.scrapeInterval, "scrape-interval", time.Second*30, "Interval at which Prometheus scrapes the metrics")
+	cmdCreate.AddCommand(cmdCreateMonitoring)
+}
+
+// deploymentEndpoints returns the URLs of the starters of all peers (`starter`) and of the servers they run
+// (by server type), using the status of the given starter and the processes of all peers.
+func deploymentEndpoints(ctx context.Context, c client.API) (map[string][]string, error) {
+	status, err := c.Status(ctx)
+	if err != nil {
+		return nil, maskAny(err)
+	}
+	endpoints := make(map[string][]string)
+	endpointURL := func(isSecure bool, host string, port int) string {
+		scheme := "http"
+		if isSecure {
+			scheme = "https"
+		}
+		return scheme + "://" + net.JoinHostPort(host, strconv.Itoa(port))
+	}
+	for _, p := range status.Peers {
+		endpoints["starter"] = append(endpoints["starter"], endpointURL(p.IsSecure, p.Address, p.Port))
+		if p.IsStandby || p.IsPassive {
+			// Runs no servers
+			continue
+		}
+		processes, err := c.PeerProcesses(ctx, p.ID)
+		if err != nil {
+			log.Warningf("Cannot get the servers of peer '%s', they are not scraped: %v", p.ID, err)
+			continue
+		}
+		for _, sp := range processes.Servers {
+			t := string(sp.Type)
+			endpoints[t] = append(endpoints[t], endpointURL(sp.IsSecure, sp.IP, sp.Port))
+		}
+	}
+	return endpoints, nil
+}
+
+func cmdCreateMonitoringRun(cmd *cobra.Command, args []string) {
+	if len(args) > 0 {
+		log.Fatalf("Expected no arguments, got %q", args)
+	}
+	if monitoringOptions.deployment == "" {
+		log.Fatal("--deployment must not be empty")
+	}
+	if monitoringOptions.scrapeInterval < time.Second {
+		log.Fatal("--scrape-interval must be at least 1s")
+	}
+	c := mustCreateStarterClient(monitoringOptions.endpoint)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
+	defer cancel()
+	endpoints, err := deploymentEndpoints(ctx, c)
+	if err != nil {
+		log.Fatalf("Failed to get endpoints of starter at %s: %v", monitoringOptions.endpoint, err)
+	}
+
+	scrapeConfig, err := createPrometheusScrapeConfig(endpoints)
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+	dashboard, err := json.MarshalIndent(createGrafanaDashboard(), "", "  ")
+	if err != nil {
+		log.Fatalf("Failed to encode dashboard: %v", err)
+	}
+
+	output := mustExpand(monitoringOptions.output)
+	if err := os.MkdirAll(output, 0755); err != nil {
+		log.Fatalf("Failed to create %s: %v", output, err)
+	}
+	for name, content := range map[string][]byte{
+		"prometheus-" + monitoringOptions.deployment + ".yml":         []byte(scrapeConfig),
+		"grafana-" + monitoringOptions.deployment + "-dashboard.json": dashboard,
+	} {
+		path := filepath.Join(output, name)
+		if err := ioutil.WriteFile(path, content, 0644); err != nil {
+			log.Fatalf("Failed to write %s: %v", path, err)
+		}
+		log.Infof("Written %s", path)
+	}
+}
+
+// monitoringRoles lists the roles scraped by Prometheus, in the order of the scrape jobs.
+var monitoringRoles = []string{"starter", "agent", "dbserver", "coordinator", "single"}
+
+// createPrometheusScrapeConfig creates the scrape jobs of Prometheus for the given URLs, per role.
+// Every role gets its own job, so the metrics of starters & servers can be told apart.
+func createPrometheusScrapeConfig(urls map[string][]string) (string, error) {
+	b := &bytes.Buffer{}
+	fmt.Fprintf(b, "# Created by `%s create monitoring` (version %s)\n", projectName, projectVersion)
+	fmt.Fprintf(b, "# Add these jobs to the scrape_configs of your Prometheus configuration.\n")
+	fmt.Fprintf(b, "scrape_configs:\n")
+	for _, role := range monitoringRoles {
+		if len(urls[role]) == 0 {
+			continue
+		}
+		var targets []string
+		scheme := ""
+		for _, x := range urls[role] {
+			u, err := url.Parse(x)
+			if err != nil || u.Host == "" {
+				return "", maskAny(fmt.Errorf("Invalid URL '%s' of %s", x, role))
+			}
+			if scheme == "" {
+				scheme = u.Scheme
+			} else if scheme != u.Scheme {
+				return "", maskAny(fmt.Errorf("The %ss use both %s & %s", role, scheme, u.Scheme))
+			}
+			targets = append(targets, fmt.Sprintf("'%s'", u.Host))
+		}
+		metricsPath := monitoringOptions.serverMetricsPath
+		if role == "starter" {
+			metricsPath = "/metrics"
+		}
+		fmt.Fprintf(b, "  - job_name: '%s-%s'\n", monitoringOptions.deployment, role)
+		fmt.Fprintf(b, "    scrape_interval: %s\n", monitoringOptions.scrapeInterval)
+		fmt.Fprintf(b, "    scheme: %s\n", scheme)
+		fmt.Fprintf(b, "    metrics_path: '%s'\n", metricsPath)
+		if role != "starter" && monitoringOptions.bearerTokenFile != "" {
+			fmt.Fprintf(b, "    authorization:\n")
+			fmt.Fprintf(b, "      credentials_file: '%s'\n", monitoringOptions.bearerTokenFile)
+		}
+		if scheme == "https" {
+			fmt.Fprintf(b, "    # tls_config:\n")
+			fmt.Fprintf(b, "    #   ca_file: '/path/to/ca.crt'\n")
+		}
+		fmt.Fprintf(b, "    static_configs:\n")
+		fmt.Fprintf(b, "      - targets: [%s]\n", strings.Join(targets, ", "))
+		fmt.Fprintf(b, "        labels:\n")
+		fmt.Fprintf(b, "          deployment: '%s'\n", monitoringOptions.deployment)
+		fmt.Fprintf(b, "          role: '%s'\n", role)
+	}
+	return b.String(), nil
+}
+
+// grafanaPanel creates a Grafana panel of given type showing the given PromQL expression.
+func grafanaPanel(id int, title, panelType, expr, legend, unit string, x, y, w, h int) map[string]interface{} {
+	return map[string]interface{}{
+		"id":         id,
+		"title":      title,
+		"type":       panelType,
+		"datasource": "$datasource",
+		"gridPos":    map[string]int{"x": x, "y": y, "w": w, "h": h},
+		"fieldConfig": map[string]interface{}{
+			"defaults":  map[string]interface{}{"unit": unit},
+			"overrides": []interface{}{},
+		},
+		"targets": []map[string]interface{}{
+			{"refId": "A", "expr": expr, "legendFormat": legend},
+		},
+	}
+}
+
+// createGrafanaDashboard creates a Grafana dashboard showing the metrics of the starters of a deployment.
+func createGrafanaDashboard() map[string]interface{} {
+	sel := `{deployment="$deployment"}`
+	panels := []map[string]interface{}{
+		grafanaPanel(1, "Health (0 ok, 1 degraded, 2 failed)", "stat", "max by (instance) ("+service.MetricStarterHealth+sel+")", "{{instance}}", "none", 0, 0, 8, 5),
+		grafanaPanel(2, "Servers up", "stat", "sum("+service.MetricServerUp+sel+")", "up", "none", 8, 0, 4, 5),
+		grafanaPanel(3, "Peers", "stat", "max("+service.MetricStarterPeers+sel+")", "peers", "none", 12, 0, 4, 5),
+		grafanaPanel(4, "Restarts (1h)", "stat", "sum(increase("+service.MetricServerRestarts+sel+"[1h]))", "restarts", "none", 16, 0, 4, 5),
+		grafanaPanel(5, "Servers in crash loop", "stat", "sum("+service.MetricServerCrashLoop+sel+") + sum("+service.MetricServerFailed+sel+")", "servers", "none", 20, 0, 4, 5),
+		grafanaPanel(6, "Server up", "timeseries", service.MetricServerUp+sel, "{{instance}} {{type}}", "none", 0, 5, 12, 8),
+		grafanaPanel(7, "Restarts", "timeseries", "increase("+service.MetricServerRestarts+sel+"[5m])", "{{instance}} {{type}}", "none", 12, 5, 12, 8),
+		grafanaPanel(8, "CPU usage", "timeseries", service.MetricServerCPUPercent+sel, "{{instance}} {{type}}", "percent", 0, 13, 12, 8),
+		grafanaPanel(9, "Memory (RSS)", "timeseries", service.MetricServerRSSBytes+sel, "{{instance}} {{type}}", "bytes", 12, 13, 12, 8),
+		grafanaPanel(10, "Open files", "timeseries", service.MetricServerOpenFiles+sel, "{{instance}} {{type}}", "none", 0, 21, 8, 8),
+		grafanaPanel(11, "Disk usage of servers", "timeseries", service.MetricServerDiskUsageBytes+sel, "{{instance}} {{type}}", "bytes", 8, 21, 8, 8),
+		grafanaPanel(12, "Free disk space", "timeseries", service.MetricDiskFreeBytes+sel, "{{instance}} {{path}}", "bytes", 16, 21, 8, 8),
+		grafanaPanel(13, "Startup phases (time since start)", "bargauge", service.MetricStartupPhaseSeconds+sel, "{{instance}} {{phase}}", "s", 0, 29, 24, 8),
+	}
+	return map[string]interface{}{
+		"title":         "ArangoDB starter (" + monitoringOptions.deployment + ")",
+		"uid":           "arangodb-starter-" + monitoringOptions.deployment,
+		"tags":          []string{"arangodb", "starter"},
+		"editable":      true,
+		"schemaVersion": 27,
+		"refresh":       "30s",
+		"time":          map[string]string{"from": "now-6h", "to": "now"},
+		"panels":        panels,
+		"templating": map[string]interface{}{
+			"list": []map[string]interface{}{
+				{
+					"name":  "datasource",
+					"label": "Data source",
+					"type":  "datasource",
+					"query": "prometheus",
+				},
+				{
+					"name":       "deployment",
+					"label":      "Deployment",
+					"type":       "query",
+					"datasource": "$datasource",
+					"query":      "label_values(" + service.MetricStarterInfo + ", deployment)",
+					"refresh":    1,
+					"current":    map[string]string{"text": monitoringOptions.deployment, "value": monitoringOptions.deployment},
+				},
+			},
+		},
+	}
+}
@@ -0,0 +1,121 @@
+//
+// DISCLAIMER
+//
+// Copyright 2017 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+// Author Ewout Prangsma
+//
+
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"time"
+
+	"github.com/arangodb-helper/arangodb/client"
+	"github.com/spf13/cobra"
+)
+
+var (
+	cmdMoveData = &cobra.Command{
+		Use:   "move-data",
+		Short: "Move the data directory of a running starter (and the servers started by it) to a new directory",
+		Run:   cmdMoveDataRun,
+	}
+	moveDataOptions struct {
+		endpoint string
+		newDir   string
+		copy     bool
+		timeout  time.Duration
+	}
+)
+
+func init() {
+	f := cmdMoveData.Flags()
+	addStarterEndpointFlag(f, &moveDataOptions.endpoint)
+	f.StringVar(&moveDataOptions.newDir, "new-dir", "", "New data directory of the starter")
+	f.BoolVar(&moveDataOptions.copy, "copy", false, "If set, the data is copied and the old data directory is left untouched")
+	f.DurationVar(&moveDataOptions.timeout, "timeout", time.Hour, "Time to wait for the move to finish")
+	cmdMain.AddCommand(cmdMoveData)
+}
+
+func cmdMoveDataRun(cmd *cobra.Command, args []string) {
+	if moveDataOptions.newDir == "" {
+		log.Fatal("--new-dir must be set")
+	}
+	newDir, err := filepath.Abs(mustExpand(moveDataOptions.newDir))
+	if err != nil {
+		log.Fatalf("Invalid --new-dir: %v", err)
+	}
+	c := mustCreateStarterClient(moveDataOptions.endpoint)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	status, err := c.MoveData(ctx, client.MoveDataRequest{
+		NewDir: newDir,
+		Copy:   moveDataOptions.copy,
+	})
+	cancel()
+	if err != nil {
+		log.Fatalf("Failed to start moving data using starter at %s: %v", moveDataOptions.endpoint, err)
+	}
+	log.Infof("Moving data directory %s to %s", status.OldDir, status.NewDir)
+
+	// Show progress until finished
+	deadline := time.Now().Add(moveDataOptions.timeout)
+	lastPhase := ""
+	for {
+		if status.Phase != lastPhase {
+			showMoveDataPhase(status)
+			lastPhase = status.Phase
+		}
+		if status.Failed {
+			log.Fatalf("Moving data has failed: %s", status.Reason)
+		}
+		if status.Ready {
+			log.Infof("Data directory has been moved to %s", status.NewDir)
+			log.Infof("Use `--starter.data-dir=%s` when starting this starter from now on", status.NewDir)
+			return
+		}
+		if time.Now().After(deadline) {
+			log.Fatalf("Moving data has not finished after %s", moveDataOptions.timeout)
+		}
+		time.Sleep(time.Second * 2)
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
+		if s, err := c.MoveDataStatus(ctx); err != nil {
+			log.Warningf("Failed to get status of moving data: %v", err)
+		} else {
+			status = s
+		}
+		cancel()
+	}
+}
+
+// showMoveDataPhase logs the phase of the given move status.
+func showMoveDataPhase(status client.MoveDataStatus) {
+	switch status.Phase {
+	case "stop-servers":
+		log.Info("Stopping servers...")
+	case "move":
+		if status.Copy {
+			log.Info("Copying data...")
+		} else {
+			log.Info("Moving data...")
+		}
+	case "start-servers":
+		log.Info("Starting servers...")
+	}
+}
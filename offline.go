@@ -0,0 +1,58 @@
+//
+// DISCLAIMER
+//
+// Copyright 2017 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+// Author Ewout Prangsma
+//
+
+package main
+
+import (
+	"strings"
+
+	service "github.com/arangodb-helper/arangodb/service"
+)
+
+// logOfflineEndpoints logs all endpoints, configured explicitly, that the starter still contacts in offline mode,
+// so operators can verify that none of them is outside their network.
+func logOfflineEndpoints() {
+	log.Info("Offline mode: no docker images are pulled and nothing is downloaded")
+	endpoints := map[string]string{
+		"starter.join":              masterAddress,
+		"starter.discovery":         discovery,
+		"tracing.endpoint":          tracingEndpoint,
+		"server.crash-loop-webhook": crashLoopWebhook,
+		"notify.webhook-url":        notifyWebhookURL,
+		"metrics.statsd":            metricsStatsd,
+	}
+	if strings.HasPrefix(logForward, "tcp://") {
+		endpoints["log.forward"] = logForward
+	}
+	if strings.Contains(recoveryFromBackup, "://") {
+		endpoints["recovery.from-backup"] = recoveryFromBackup
+	}
+	for _, name := range []string{"starter.join", "starter.discovery", "tracing.endpoint", "server.crash-loop-webhook", "notify.webhook-url", "metrics.statsd", "log.forward", "recovery.from-backup"} {
+		if value := endpoints[name]; value != "" {
+			value, _ = redactOptionValue(name, value)
+			log.Infof("Offline mode: --%s=%s is contacted, make sure it is inside your network", name, value)
+		}
+	}
+	if service.IsCloudAddress(ownAddress) {
+		log.Infof("Offline mode: --starter.address=%s contacts the instance metadata service of this machine", ownAddress)
+	}
+}
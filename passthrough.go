@@ -0,0 +1,150 @@
+//
+// DISCLAIMER
+//
+// Copyright 2017 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+// Author Ewout Prangsma
+//
+
+package main
+
+import (
+	"sort"
+	"strings"
+
+	service "github.com/arangodb-helper/arangodb/service"
+	"github.com/spf13/pflag"
+)
+
+var (
+	passthroughOptions []service.PassthroughOption
+	starterFlags       *pflag.FlagSet // Options of the starter itself, these are never passthrough options
+)
+
+// parsePassthroughOptionName splits the given option name into its server type prefix and arangod option name.
+// Options of the starter itself (e.g. `--dbservers.nice`) are not passthrough options.
+func parsePassthroughOptionName(name string) (prefix, optionName string, ok bool) {
+	if starterFlags != nil && starterFlags.Lookup(name) != nil {
+		return "", "", false
+	}
+	return service.ParsePassthroughOptionName(name)
+}
+
+// extractPassthroughOptions removes all passthrough options (e.g. `--dbservers.rocksdb.block-cache-size=1G`)
+// from the given command line arguments and returns them separately.
+// Both `--name=value` and `--name value` forms are supported.
+func extractPassthroughOptions(args []string) ([]string, []service.PassthroughOption) {
+	var remaining []string
+	var options []service.PassthroughOption
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			remaining = append(remaining, args[i:]...)
+			break
+		}
+		if !strings.HasPrefix(arg, "--") {
+			remaining = append(remaining, arg)
+			continue
+		}
+		name := strings.TrimPrefix(arg, "--")
+		value := ""
+		hasValue := false
+		if idx := strings.Index(name, "="); idx >= 0 {
+			name, value, hasValue = name[:idx], name[idx+1:], true
+		}
+		prefix, optionName, ok := parsePassthroughOptionName(name)
+		if !ok {
+			remaining = append(remaining, arg)
+			continue
+		}
+		if !hasValue {
+			if i+1 < len(args) && !strings.HasPrefix(args[i+1], "-") {
+				i++
+				value = args[i]
+			} else {
+				value = "true"
+			}
+		}
+		options = append(options, service.PassthroughOption{
+			Prefix: prefix,
+			Name:   optionName,
+			Value:  value,
+		})
+	}
+	return remaining, options
+}
+
+// hasPassthroughOption returns true if a passthrough option with given prefix & name has been given.
+func hasPassthroughOption(prefix, name string) bool {
+	for _, o := range passthroughOptions {
+		if o.Prefix == prefix && o.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// isPassthroughOptionName returns true if the given option name is a passthrough option.
+// Options of the starter itself (e.g. `dbservers.numactl` in a configuration file) are not.
+func isPassthroughOptionName(name string) bool {
+	_, _, ok := parsePassthroughOptionName(name)
+	return ok
+}
+
+// addConfigFilePassthroughOptions adds all passthrough options from the given configuration file options
+// that have not been specified on the command line.
+func addConfigFilePassthroughOptions(options map[string]string) {
+	onCommandLine := make(map[string]bool)
+	for _, o := range passthroughOptions {
+		onCommandLine[o.Prefix+"."+o.Name] = true
+	}
+	var names []string
+	for name := range options {
+		if isPassthroughOptionName(name) && !onCommandLine[name] {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		prefix, optionName, _ := parsePassthroughOptionName(name)
+		passthroughOptions = append(passthroughOptions, service.PassthroughOption{
+			Prefix: prefix,
+			Name:   optionName,
+			Value:  options[name],
+		})
+	}
+}
+
+// changedPassthroughOptions returns the names of all passthrough options that are
+// added, removed or changed between the given sets of configuration file options.
+func changedPassthroughOptions(oldOptions, newOptions map[string]string) []string {
+	var names []string
+	for name, value := range newOptions {
+		if isPassthroughOptionName(name) {
+			if oldValue, found := oldOptions[name]; !found || oldValue != value {
+				names = append(names, name)
+			}
+		}
+	}
+	for name := range oldOptions {
+		if _, found := newOptions[name]; !found && isPassthroughOptionName(name) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,174 @@
+//
+// DISCLAIMER
+//
+// Copyright 2017 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+// Author Ewout Prangsma
+//
+
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	cmdCreateLaunchdPlist = &cobra.Command{
+		Use:   "launchd-plist",
+		Short: "Create a launchd property list (macOS) that runs the starter with the given options",
+		Run:   cmdCreateLaunchdPlistRun,
+	}
+	launchdPlistOptions struct {
+		label       string
+		logFile     string
+		limitNoFile uint64
+		exitTimeout time.Duration
+		output      string
+		install     bool
+	}
+)
+
+func init() {
+	// The property list accepts all options of the starter itself, which are passed on to ProgramArguments.
+	// This relies on the flags of cmdMain being defined (in main.go) before this init function runs.
+	f := cmdCreateLaunchdPlist.Flags()
+	f.AddFlagSet(cmdMain.Flags())
+	f.SetNormalizeFunc(normalizeOptionNames)
+	f.StringVar(&launchdPlistOptions.label, "label", "com.arangodb.starter", "Label of the launchd job")
+	f.StringVar(&launchdPlistOptions.logFile, "log-file", "", "File the output of the starter is written to (default arangodb-starter.log in the data directory)")
+	f.Uint64Var(&launchdPlistOptions.limitNoFile, "limit-nofile", 8192, "Maximum number of open files of the starter and its servers")
+	f.DurationVar(&launchdPlistOptions.exitTimeout, "exit-timeout", time.Minute*5, "Time launchd gives the starter to shut down its servers before killing them")
+	f.StringVar(&launchdPlistOptions.output, "output", "", "Path of the property list to create (default standard output, or ~/Library/LaunchAgents/<label>.plist with --install)")
+	f.BoolVar(&launchdPlistOptions.install, "install", false, "If set, the property list is installed in ~/Library/LaunchAgents and loaded")
+	cmdCreate.AddCommand(cmdCreateLaunchdPlist)
+}
+
+func cmdCreateLaunchdPlistRun(cmd *cobra.Command, args []string) {
+	if len(args) > 0 {
+		log.Fatalf("Expected no arguments, got %q", args)
+	}
+	if launchdPlistOptions.exitTimeout < time.Second {
+		log.Fatal("--exit-timeout must be at least 1s")
+	}
+
+	// Only the passthrough options of the command line are passed on, the configuration file is read by the starter itself
+	cmdLinePassthroughOptions := passthroughOptions
+	if err := loadConfigFile(cmd.Flags()); err != nil {
+		log.Fatal(err.Error())
+	}
+	for _, p := range checkOptions() {
+		if p.Severity == severityError {
+			log.Fatalf("--%s: %s", p.Option, p.Message)
+		}
+	}
+
+	executable, err := starterExecutable()
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+	workDir, err := os.Getwd()
+	if err != nil {
+		log.Fatalf("Cannot get working directory: %v", err)
+	}
+	starterArgs, err := starterArguments(cmdLinePassthroughOptions)
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+	logFile := launchdPlistOptions.logFile
+	if logFile == "" {
+		logFile = filepath.Join(mustExpand(dataDir), "arangodb-starter.log")
+	}
+	if logFile, err = filepath.Abs(mustExpand(logFile)); err != nil {
+		log.Fatalf("Cannot get absolute path of %s: %v", launchdPlistOptions.logFile, err)
+	}
+	plist := createLaunchdPlist(append([]string{executable}, starterArgs...), workDir, logFile)
+
+	output := launchdPlistOptions.output
+	if output == "" && launchdPlistOptions.install {
+		output = filepath.Join(mustExpand("~/Library/LaunchAgents"), launchdPlistOptions.label+".plist")
+	}
+	if output == "" {
+		fmt.Print(plist)
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(output), 0755); err != nil {
+		log.Fatalf("Failed to create directory of %s: %v", output, err)
+	}
+	if err := ioutil.WriteFile(output, []byte(plist), 0644); err != nil {
+		log.Fatalf("Failed to write %s: %v", output, err)
+	}
+	log.Infof("Property list written to %s", output)
+	if !launchdPlistOptions.install {
+		return
+	}
+	c := exec.Command("launchctl", "load", "-w", output)
+	c.Stdout = os.Stdout
+	c.Stderr = os.Stderr
+	if err := c.Run(); err != nil {
+		log.Fatalf("launchctl load failed: %v", err)
+	}
+	log.Infof("Job %s has been loaded, use `launchctl unload %s` to stop it", launchdPlistOptions.label, output)
+}
+
+// createLaunchdPlist returns the content of a launchd property list that runs the given command line.
+func createLaunchdPlist(programArguments []string, workDir, logFile string) string {
+	o := launchdPlistOptions
+	b := &bytes.Buffer{}
+	str := func(s string) string {
+		escaped := &bytes.Buffer{}
+		xml.EscapeText(escaped, []byte(s))
+		return "<string>" + escaped.String() + "</string>"
+	}
+	fmt.Fprintln(b, `<?xml version="1.0" encoding="UTF-8"?>`)
+	fmt.Fprintln(b, `<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">`)
+	fmt.Fprintf(b, "<!-- Created by `%s create launchd-plist` (version %s) -->\n", projectName, projectVersion)
+	fmt.Fprintln(b, `<plist version="1.0">`)
+	fmt.Fprintln(b, "<dict>")
+	fmt.Fprintf(b, "\t<key>Label</key>\n\t%s\n", str(o.label))
+	fmt.Fprintln(b, "\t<key>ProgramArguments</key>")
+	fmt.Fprintln(b, "\t<array>")
+	for _, arg := range programArguments {
+		fmt.Fprintf(b, "\t\t%s\n", str(arg))
+	}
+	fmt.Fprintln(b, "\t</array>")
+	fmt.Fprintf(b, "\t<key>WorkingDirectory</key>\n\t%s\n", str(workDir))
+	fmt.Fprintln(b, "\t<key>RunAtLoad</key>\n\t<true/>")
+	// Restart the starter when it fails, but not when it has been stopped (e.g. using `arangodb stop`)
+	fmt.Fprintln(b, "\t<key>KeepAlive</key>\n\t<dict>\n\t\t<key>SuccessfulExit</key>\n\t\t<false/>\n\t</dict>")
+	// launchd sends SIGTERM on unload and kills the entire process group (including the servers)
+	// once ExitTimeOut has passed, so give the starter time to shut down its servers
+	fmt.Fprintf(b, "\t<key>ExitTimeOut</key>\n\t<integer>%d</integer>\n", int64(o.exitTimeout/time.Second))
+	fmt.Fprintln(b, "\t<key>ProcessType</key>\n\t<string>Standard</string>")
+	fmt.Fprintln(b, "\t<key>SoftResourceLimits</key>")
+	fmt.Fprintf(b, "\t<dict>\n\t\t<key>NumberOfFiles</key>\n\t\t<integer>%d</integer>\n\t</dict>\n", o.limitNoFile)
+	fmt.Fprintln(b, "\t<key>HardResourceLimits</key>")
+	fmt.Fprintf(b, "\t<dict>\n\t\t<key>NumberOfFiles</key>\n\t\t<integer>%d</integer>\n\t</dict>\n", o.limitNoFile)
+	fmt.Fprintf(b, "\t<key>StandardOutPath</key>\n\t%s\n", str(logFile))
+	fmt.Fprintf(b, "\t<key>StandardErrorPath</key>\n\t%s\n", str(logFile))
+	fmt.Fprintln(b, "</dict>")
+	fmt.Fprintln(b, "</plist>")
+	return b.String()
+}
@@ -0,0 +1,111 @@
+//
+// DISCLAIMER
+//
+// Copyright 2017 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+// Author Ewout Prangsma
+//
+
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/arangodb-helper/arangodb/client"
+	"github.com/spf13/cobra"
+)
+
+var (
+	cmdReplaceDBServer = &cobra.Command{
+		Use:   "replace-dbserver",
+		Short: "Replace a permanently failed dbserver by a fresh dbserver on another peer",
+		Run:   cmdReplaceDBServerRun,
+	}
+	replaceDBServerOptions struct {
+		endpoint string
+		from     string
+		peerID   string
+		timeout  time.Duration
+	}
+)
+
+func init() {
+	f := cmdReplaceDBServer.Flags()
+	addStarterEndpointFlag(f, &replaceDBServerOptions.endpoint)
+	f.StringVar(&replaceDBServerOptions.from, "from", "", "Cluster ID of the failed dbserver (e.g. PRMR-1234)")
+	f.StringVar(&replaceDBServerOptions.peerID, "peer", "", "ID of the peer to start the new dbserver on (default is the first peer without a dbserver)")
+	f.DurationVar(&replaceDBServerOptions.timeout, "timeout", time.Hour, "Time to wait for the replacement to finish")
+	cmdMain.AddCommand(cmdReplaceDBServer)
+}
+
+func cmdReplaceDBServerRun(cmd *cobra.Command, args []string) {
+	if replaceDBServerOptions.from == "" {
+		log.Fatal("--from must be set")
+	}
+	c := mustCreateStarterClient(replaceDBServerOptions.endpoint)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	status, err := c.ReplaceDBServer(ctx, client.ReplaceDBServerRequest{
+		From:   replaceDBServerOptions.from,
+		PeerID: replaceDBServerOptions.peerID,
+	})
+	cancel()
+	if err != nil {
+		log.Fatalf("Failed to start replacement using starter at %s: %v", replaceDBServerOptions.endpoint, err)
+	}
+	log.Infof("Replacing dbserver '%s' by a new dbserver on peer '%s'", status.From, status.PeerID)
+
+	// Show progress until finished
+	deadline := time.Now().Add(replaceDBServerOptions.timeout)
+	lastPhase, lastPending := "", -1
+	for {
+		if status.Phase != lastPhase || (status.Phase == "resync" && status.PendingShards != lastPending) {
+			showReplaceDBServerPhase(status)
+			lastPhase, lastPending = status.Phase, status.PendingShards
+		}
+		if status.Failed {
+			log.Fatalf("Replacement failed: %s", status.Reason)
+		}
+		if status.Ready {
+			log.Infof("Dbserver '%s' has been replaced by '%s'", status.From, status.NewServerID)
+			return
+		}
+		if time.Now().After(deadline) {
+			log.Fatalf("Replacement has not finished after %s", replaceDBServerOptions.timeout)
+		}
+		time.Sleep(time.Second * 5)
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
+		if s, err := c.ReplaceDBServerStatus(ctx); err != nil {
+			log.Warningf("Failed to get replacement status: %v", err)
+		} else {
+			status = s
+		}
+		cancel()
+	}
+}
+
+// showReplaceDBServerPhase logs the phase of the given replacement status.
+func showReplaceDBServerPhase(status client.ReplaceDBServerStatus) {
+	switch status.Phase {
+	case "start-dbserver":
+		log.Info("Waiting for the new dbserver to join the cluster...")
+	case "resync":
+		log.Infof("Waiting for shards to resynchronize (%d pending)...", status.PendingShards)
+	case "remove-server":
+		log.Infof("Removing dbserver '%s' from the cluster...", status.From)
+	}
+}
@@ -0,0 +1,94 @@
+//
+// DISCLAIMER
+//
+// Copyright 2017 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+// Author Ewout Prangsma
+//
+
+package main
+
+import (
+	"context"
+	"path/filepath"
+
+	service "github.com/arangodb-helper/arangodb/service"
+)
+
+// downloadArangod downloads the arangod binary described by the given options, unless it has been downloaded before.
+// In dry-run & offline mode nothing is downloaded, only a cached version is used.
+// Returns the paths of the arangod executable & JS startup directory and true if found.
+func downloadArangod(options service.DownloadArangodOptions) (string, string, bool) {
+	if dryRun || offline {
+		// Do not download anything, use a cached version if available
+		if p, jsPath, found := service.FindDownloadedArangod(options); found {
+			return p, jsPath, true
+		} else if offline {
+			log.Fatal(service.OfflineDownloadError(options).Error())
+		} else {
+			log.Warningf("ArangoDB %s has not been downloaded yet, it will be downloaded into %s at startup", options.Version, options.CacheDir)
+		}
+		return "", "", false
+	}
+	log.Infof("Looking for ArangoDB %s in %s, downloading it if needed", options.Version, options.CacheDir)
+	p, jsPath, err := service.DownloadArangod(context.Background(), options)
+	if err != nil {
+		log.Fatalf("Failed to download ArangoDB %s: %v", options.Version, err)
+	}
+	log.Infof("Using downloaded arangod %s", p)
+	return p, jsPath, true
+}
+
+// resolveServerBinaries returns the arangod binary (or docker image) of every server type that has been given its own
+// using the `--<prefix>.server.version`, `--<prefix>.server.arangod`, `--<prefix>.server.js-dir` or `--<prefix>.docker.image` options.
+// When running servers as processes, a pinned version without an explicit executable is downloaded (if needed).
+// When running servers in docker, a pinned version without an explicit image selects that tag of --docker.image.
+func resolveServerBinaries() service.ServerBinaries {
+	result := make(service.ServerBinaries)
+	for _, serverType := range []service.ServerType{service.ServerTypeAgent, service.ServerTypeDBServer, service.ServerTypeCoordinator, service.ServerTypeSingle} {
+		options := service.ServerBinaryOptions(passthroughOptions, serverType)
+		if len(options) == 0 {
+			continue
+		}
+		b := service.ServerBinary{Version: options[service.ServerBinaryOptionVersion]}
+		if dockerImage != "" {
+			b.DockerImage = options[service.ServerBinaryOptionImage]
+			if b.DockerImage == "" && b.Version != "" {
+				b.DockerImage = service.DockerImageWithVersion(dockerImage, b.Version)
+			}
+			if b.DockerImage != "" {
+				log.Infof("Using image %s for %s", b.DockerImage, serverType)
+			}
+		} else if p := options[service.ServerBinaryOptionArangod]; p != "" {
+			b.ArangodPath, _ = filepath.Abs(mustExpand(p))
+			if jsPath := options[service.ServerBinaryOptionJSDir]; jsPath != "" {
+				b.JSPath, _ = filepath.Abs(mustExpand(jsPath))
+			}
+			log.Infof("Using %s for %s", b.ArangodPath, serverType)
+		} else if b.Version != "" {
+			b.ArangodPath, b.JSPath, _ = downloadArangod(service.DownloadArangodOptions{
+				Version:  b.Version,
+				URL:      serverDownloadURL,
+				CacheDir: serverDownloadDir,
+			})
+		}
+		if !b.IsEmpty() {
+			result[serverType] = b
+		}
+	}
+	return result
+}
@@ -0,0 +1,157 @@
+//
+// DISCLAIMER
+//
+// Copyright 2017 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+// Author Ewout Prangsma
+//
+
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	addressAnnounceTimeout = time.Minute // Maximum time a relaunched slave tries to announce its address to the master
+)
+
+// applyOwnAddressChange updates the address of this starter in its list of peers, when it is relaunched
+// with a `--starter.address` that differs from the address recorded in setup.json.
+func (s *Service) applyOwnAddressChange() {
+	if s.OwnAddress == "" {
+		return
+	}
+	myPeer, found := s.myPeers.PeerByID(s.ID)
+	address := normalizeHostName(s.OwnAddress)
+	if !found || myPeer.Address == address {
+		return
+	}
+	if !s.myPeers.CanChangeAddress(s.ID, address, s.AllPortOffsetsUnique) {
+		s.peersLog.Errorf("Cannot change the address of this starter from %s to %s, its ports are in use by another peer at that address", myPeer.Address, address)
+		return
+	}
+	s.peersLog.Info(newLogEvent("peer-address-changed", LogFields{"peer-id": s.ID, "old-address": myPeer.Address, "address": address},
+		"Address of this starter has changed from %s to %s", myPeer.Address, address))
+	myPeer.Address = address
+	s.myPeers.UpdatePeerByID(myPeer)
+}
+
+// announceOwnAddress sends a hello request to the master when a slave is relaunched, so the master
+// updates the address of this starter (derived from the request, or given by `--starter.address`) when it has changed.
+// The master is contacted at `--starter.join` (if given) or at its recorded address.
+// The peers returned by the master replace the recorded peers. When the master cannot be reached
+// within addressAnnounceTimeout, the recorded peers are used.
+func (s *Service) announceOwnAddress() {
+	master := s.myPeers.Peers[0]
+	url := master.CreateStarterURL("/hello")
+	if s.MasterAddress != "" {
+		host, port := s.MasterAddress, s.MasterPort
+		if h, p, err := net.SplitHostPort(s.MasterAddress); err == nil {
+			host = h
+			port, _ = strconv.Atoi(p)
+		}
+		joined := master
+		joined.Address = trimIPv6Brackets(host)
+		joined.Port = port
+		url = joined.CreateStarterURL("/hello")
+	}
+	_, hostPort, err := s.getHTTPServerPort()
+	if err != nil {
+		s.peersLog.Warningf("Cannot announce address to master: %v", err)
+		return
+	}
+	myPeer, _ := s.myPeers.PeerByID(s.ID)
+
+	deadline := time.Now().Add(addressAnnounceTimeout)
+	for {
+		now := time.Now()
+		var joinProof string
+		if token := s.joinToken(); token != "" {
+			joinProof = createJoinProof(token, s.ID, now)
+		}
+		b, _ := json.Marshal(HelloRequest{
+			DataDir:       s.DataDir,
+			SlaveID:       s.ID,
+			SlaveAddress:  s.OwnAddress,
+			SlavePort:     hostPort,
+			IsSecure:      s.IsSecure(),
+			IsStandby:     myPeer.IsStandby,
+			IsPassive:     myPeer.IsPassive,
+			StorageEngine: s.ServerStorageEngine,
+			JoinProof:     joinProof,
+			Time:          now,
+		})
+		resp, err := httpClient.Post(url, "application/json", bytes.NewReader(b))
+		if err == nil {
+			body, _ := ioutil.ReadAll(resp.Body)
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				var errResp ErrorResponse
+				json.Unmarshal(body, &errResp)
+				s.peersLog.Errorf("Master refused the address of this starter: %s", errResp.Error)
+				return
+			}
+			var masterPeers peers
+			if err := json.Unmarshal(body, &masterPeers); err != nil {
+				s.peersLog.Warningf("Cannot parse peers from master: %v", err)
+				return
+			}
+			if newPeer, found := masterPeers.PeerByID(s.ID); found && len(masterPeers.Peers) > 0 {
+				if newPeer.Address != myPeer.Address {
+					s.peersLog.Info(newLogEvent("peer-address-changed", LogFields{"peer-id": s.ID, "old-address": myPeer.Address, "address": newPeer.Address},
+						"Address of this starter has changed from %s to %s", myPeer.Address, newPeer.Address))
+				}
+				s.updatePeers(masterPeers.Peers)
+			}
+			return
+		}
+		if time.Now().After(deadline) {
+			s.peersLog.Warningf("Cannot reach master at %s to announce the address of this starter, using the recorded peers: %v", url, err)
+			return
+		}
+		s.peersLog.Debugf("Cannot reach master to announce address: %v", err)
+		time.Sleep(time.Second * 2)
+	}
+}
+
+// followMasterAddress updates the address of the master in the list of peers, when the master lease
+// in the agency holds another address than the recorded one (e.g. the master has been relaunched with a new address).
+func (s *Service) followMasterAddress(lease masterLease) Peer {
+	s.mutex.Lock()
+	master := s.myPeers.Peers[0]
+	if master.ID != lease.ID || lease.Address == "" || (master.Address == lease.Address && master.Port == lease.Port) {
+		s.mutex.Unlock()
+		return master
+	}
+	s.peersLog.Info(newLogEvent("peer-address-changed", LogFields{"peer-id": master.ID, "old-address": master.Address, "address": lease.Address},
+		"Address of master '%s' has changed from %s to %s", master.ID, master.Address, lease.Address))
+	master.Address = lease.Address
+	master.Port = lease.Port
+	s.myPeers.Peers[0] = master
+	s.mutex.Unlock()
+	if err := s.saveSetup(); err != nil {
+		s.peersLog.Errorf("Failed to save setup: %#v", err)
+	}
+	return master
+}
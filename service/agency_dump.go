@@ -0,0 +1,84 @@
+//
+// DISCLAIMER
+//
+// Copyright 2017 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+// Author Ewout Prangsma
+//
+
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	agencyDumpTimeout = time.Second * 10
+)
+
+// agencyDumpHandler returns the content of the agency (at the prefix given in the `path` query, if any),
+// as read from the agent started by this starter.
+// If there is no agent running a 404 is returned.
+func (s *Service) agencyDumpHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		writeError(w, http.StatusMethodNotAllowed, "GET required")
+		return
+	}
+	if s.servers.agentProc == nil {
+		writeError(w, http.StatusNotFound, "No agent running")
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), agencyDumpTimeout)
+	defer cancel()
+	dump, err := s.agencyDump(ctx, r.FormValue("path"))
+	if err != nil {
+		writeError(w, http.StatusServiceUnavailable, err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(dump)
+}
+
+// agencyDump reads the content of the agency at the given path prefix ("/" if empty)
+// from the agent started by this starter.
+func (s *Service) agencyDump(ctx context.Context, path string) ([]byte, error) {
+	if path == "" {
+		path = "/"
+	} else if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	query, err := json.Marshal([][]string{{path}})
+	if err != nil {
+		return nil, maskAny(err)
+	}
+	content, err := s.serverRequest(ctx, ServerTypeAgent, "POST", "/_api/agency/read", query)
+	if err != nil {
+		return nil, maskAny(err)
+	}
+	var result []json.RawMessage
+	if err := json.Unmarshal(content, &result); err != nil {
+		return nil, maskAny(err)
+	}
+	if len(result) == 0 {
+		return []byte("{}"), nil
+	}
+	return result[0], nil
+}
@@ -0,0 +1,270 @@
+//
+// DISCLAIMER
+//
+// Copyright 2017 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+// Author Ewout Prangsma
+//
+
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"time"
+)
+
+const (
+	lostAgentCheckInterval = time.Second * 10 // Interval between checks for unreachable peers that run an agent
+	startAgentTimeout      = time.Second * 30 // Timeout of a request asking a peer to start an agent
+)
+
+// replaceAgent moves the agent of the given peer, that has left the deployment or has been lost,
+// to a peer that does not run an agent yet. Peers on an address without agent are preferred.
+// The agency is not changed, the new agent joins it as a replacement of the lost agent.
+// Only the master replaces agents.
+func (s *Service) replaceAgent(lostID string) {
+	s.mutex.Lock()
+	if s.myPeers.AgentCount() >= s.AgencySize {
+		s.mutex.Unlock()
+		return
+	}
+	agentAddresses := make(map[string]bool)
+	for _, p := range s.myPeers.Peers {
+		if p.HasAgent {
+			agentAddresses[p.Address] = true
+		}
+	}
+	var candidates []Peer
+	for _, p := range s.myPeers.Peers {
+		if p.HasAgent || !p.HasServers() {
+			continue
+		}
+		if agentAddresses[p.Address] {
+			candidates = append(candidates, p)
+		} else {
+			candidates = append([]Peer{p}, candidates...)
+		}
+	}
+	s.mutex.Unlock()
+
+	for _, candidate := range candidates {
+		if candidate.ID != s.ID && !isPeerReachable(candidate) {
+			continue
+		}
+		peerList, err := s.assignAgent(candidate.ID)
+		if err != nil {
+			s.peersLog.Errorf("Cannot assign an agent to peer '%s': %v", candidate.ID, err)
+			return
+		}
+		s.peersLog.Infof("Moving the agent of peer '%s' to peer '%s'", lostID, candidate.ID)
+		if err := s.saveSetup(); err != nil {
+			s.peersLog.Errorf("Failed to save setup: %#v", err)
+		}
+		if candidate.ID == s.ID {
+			s.startOwnAgent()
+			return
+		}
+		ctx, cancel := context.WithTimeout(s.ctx, startAgentTimeout)
+		defer cancel()
+		if err := s.startPeerAgent(ctx, candidate, peerList); err != nil {
+			s.peersLog.Errorf("Failed to start an agent on peer '%s': %v", candidate.ID, err)
+		}
+		return
+	}
+	s.peersLog.Warningf("No peer is available to take over the agent of peer '%s', add a peer to restore the agency size of %d", lostID, s.AgencySize)
+}
+
+// assignAgent marks the peer with given ID as running an agent, assigning it an agent port when port ranges are used.
+// Returns the updated peers.
+func (s *Service) assignAgent(id string) (peers, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	p, found := s.myPeers.PeerByID(id)
+	if !found {
+		return peers{}, maskAny(fmt.Errorf("Unknown peer '%s'", id))
+	}
+	if r, found := s.ServerPortRanges[ServerTypeAgent]; found {
+		ports, err := s.myPeers.GetFreeServerPorts(p.Address, s.AllPortOffsetsUnique, map[ServerType]PortRange{ServerTypeAgent: r})
+		if err != nil {
+			return peers{}, maskAny(err)
+		}
+		serverPorts := make(map[ServerType]int)
+		for t, port := range p.ServerPorts {
+			serverPorts[t] = port
+		}
+		serverPorts[ServerTypeAgent] = ports[ServerTypeAgent]
+		p.ServerPorts = serverPorts
+	}
+	p.HasAgent = true
+	s.myPeers.UpdatePeerByID(p)
+	result := s.myPeers
+	result.Peers = append([]Peer{}, s.myPeers.Peers...)
+	return result, nil
+}
+
+// startPeerAgent asks the given peer to start an agent, using the given peers.
+func (s *Service) startPeerAgent(ctx context.Context, peer Peer, peerList peers) error {
+	body, err := json.Marshal(peerList)
+	if err != nil {
+		return maskAny(err)
+	}
+	req, err := http.NewRequest("POST", peer.CreateStarterURL("/agent/start"), bytes.NewReader(body))
+	if err != nil {
+		return maskAny(err)
+	}
+	if err := addJwtHeader(req, s.JwtSecret); err != nil {
+		return maskAny(err)
+	}
+	resp, err := httpClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return maskAny(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+	content, _ := ioutil.ReadAll(resp.Body)
+	var errResp ErrorResponse
+	if err := json.Unmarshal(content, &errResp); err == nil && errResp.Error != "" {
+		return maskAny(fmt.Errorf("%s", errResp.Error))
+	}
+	return maskAny(fmt.Errorf("Invalid status %d", resp.StatusCode))
+}
+
+// startAgentHandler handles a `/agent/start` request, send by the master to make a peer
+// start an agent that replaces a lost agent. The body contains the peers, in which this peer runs an agent.
+func (s *Service) startAgentHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		writeError(w, http.StatusMethodNotAllowed, "POST required")
+		return
+	}
+	defer r.Body.Close()
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("Cannot read request body: %v", err.Error()))
+		return
+	}
+	var newPeers peers
+	if err := json.Unmarshal(body, &newPeers); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("Cannot parse request body: %v", err.Error()))
+		return
+	}
+	if myPeer, found := newPeers.PeerByID(s.ID); !found || !myPeer.HasAgent {
+		writeError(w, http.StatusBadRequest, "This peer has not been assigned an agent")
+		return
+	}
+
+	s.mutex.Lock()
+	if s.runner == nil {
+		s.mutex.Unlock()
+		writeError(w, http.StatusPreconditionFailed, "Not ready yet")
+		return
+	}
+	if s.servers.agentProc != nil {
+		s.mutex.Unlock()
+		writeError(w, http.StatusConflict, "An agent is already running")
+		return
+	}
+	s.myPeers.Peers = newPeers.Peers
+	s.mutex.Unlock()
+
+	if err := s.saveSetup(); err != nil {
+		s.peersLog.Errorf("Failed to save setup: %#v", err)
+	}
+	s.startOwnAgent()
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("OK"))
+}
+
+// startOwnAgent starts a fresh agent for this peer. An existing agent directory is moved aside.
+func (s *Service) startOwnAgent() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	myPeer, found := s.myPeers.PeerByID(s.ID)
+	if !found || s.runner == nil || s.servers.agentProc != nil {
+		return
+	}
+	if myHostDir, err := s.serverHostDir(ServerTypeAgent); err == nil {
+		if _, err := os.Stat(myHostDir); err == nil {
+			oldHostDir := fmt.Sprintf("%s.replaced-%s", myHostDir, time.Now().Format("20060102-150405"))
+			s.log.Infof("Moving existing agent directory to %s", oldHostDir)
+			if err := os.Rename(myHostDir, oldHostDir); err != nil {
+				s.log.Errorf("Failed to move existing agent directory: %v", err)
+			}
+		}
+	}
+	s.log.Info("Starting an agent that replaces a lost agent")
+	s.runAgent = true
+	go s.runArangod(s.runner, myPeer, ServerTypeAgent, &s.servers.agentProc, &s.runAgent)
+}
+
+// stopOwnAgent stops the agent of this peer, because the master has moved it to another peer.
+func (s *Service) stopOwnAgent() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.runAgent = false
+	if p := s.servers.agentProc; p != nil {
+		s.log.Warning("The agent of this peer has been moved to another peer, stopping it")
+		if err := p.Terminate(); err != nil {
+			s.log.Warningf("Failed to terminate agent: %v", err)
+		}
+	}
+}
+
+// watchForLostAgents lets the master move the agent of a peer that has been unreachable
+// for longer than --cluster.agent-failover-delay to another peer.
+func (s *Service) watchForLostAgents() {
+	lastSeen := make(map[string]time.Time)
+	for !s.stop {
+		if !s.isMaster() {
+			lastSeen = make(map[string]time.Time)
+			time.Sleep(lostAgentCheckInterval)
+			continue
+		}
+		s.mutex.Lock()
+		peerList := append([]Peer{}, s.myPeers.Peers...)
+		s.mutex.Unlock()
+
+		for _, p := range peerList {
+			if p.ID == s.ID || !p.HasAgent {
+				continue
+			}
+			if _, found := lastSeen[p.ID]; !found || isPeerReachable(p) {
+				lastSeen[p.ID] = time.Now()
+				continue
+			}
+			if downtime := time.Since(lastSeen[p.ID]); downtime > s.AgentFailoverDelay {
+				s.peersLog.Warningf("Peer '%s' that runs an agent has been unreachable for %s", p.ID, downtime)
+				s.mutex.Lock()
+				if lost, found := s.myPeers.PeerByID(p.ID); found {
+					lost.HasAgent = false
+					s.myPeers.UpdatePeerByID(lost)
+				}
+				s.mutex.Unlock()
+				delete(lastSeen, p.ID)
+				s.replaceAgent(p.ID)
+			}
+		}
+		time.Sleep(lostAgentCheckInterval)
+	}
+}
@@ -23,7 +23,6 @@
 package service
 
 import (
-	"bufio"
 	"context"
 	"crypto/rand"
 	"crypto/tls"
@@ -42,6 +41,7 @@ import (
 	"time"
 
 	logging "github.com/op/go-logging"
+	"github.com/pkg/errors"
 )
 
 const (
@@ -50,26 +50,112 @@ const (
 
 // Config holds all configuration for a single service.
 type Config struct {
-	ID                   string // Unique identifier of this peer
-	Mode                 string // Service mode cluster|single
-	AgencySize           int
-	ArangodPath          string
-	ArangodJSPath        string
-	MasterPort           int
-	RrPath               string
-	StartCoordinator     bool
-	StartDBserver        bool
-	StartLocalSlaves     bool // If set, start sufficient slave (Service's) locally.
-	DataDir              string
-	OwnAddress           string // IP address of used to reach this process
-	MasterAddress        string
-	Verbose              bool
-	ServerThreads        int    // If set to something other than 0, this will be added to the commandline of each server with `--server.threads`...
-	ServerStorageEngine  string // mmfiles | rocksdb
-	AllPortOffsetsUnique bool   // If set, all peers will get a unique port offset. If false (default) only portOffset+peerAddress pairs will be unique.
-	JwtSecret            string
-	SslKeyFile           string // Path containing an x509 certificate + private key to be used by the servers.
-	SslCAFile            string // Path containing an x509 CA certificate used to authenticate clients.
+	ID                        string // Unique identifier of this peer
+	Mode                      string // Service mode cluster|single
+	AgencySize                int
+	ArangodPath               string
+	ArangodJSPath             string
+	ServerBinaries            ServerBinaries // Arangod binary (or docker image) per server type, if different from ArangodPath (or DockerImage)
+	MasterPort                int
+	RrPath                    string
+	StartCoordinator          bool
+	StartDBserver             bool
+	StartLocalSlaves          bool // If set, start sufficient slave (Service's) locally.
+	DataDir                   string
+	OwnAddress                string // IP address of used to reach this process
+	BindAddress               string // IP address to listen on (empty means all interfaces)
+	MasterAddress             string
+	Discovery                 string // If set (etcd://... or consul://...), the master is elected using this coordination store
+	Verbose                   bool
+	LogFormat                 string // Format of the log of the starter text|json
+	LogRotateSize             int64  // If set, the log files of the servers are rotated once they reach this size (in bytes)
+	LogRotateFiles            int    // Number of compressed archives of rotated log files to keep
+	LogForward                string // If set, the logs of the servers are forwarded to this target syslog|journald|tcp://host:port
+	TracingEndpoint           string // If set, spans of the bootstrap phases are exported to this OTLP/HTTP endpoint
+	CoreDirectory             string // If set, the servers run in (a sub directory of) this directory, so their core dumps are collected
+	CoreLogLines              int    // Number of most recent log lines added to a crash bundle
+	ServerThreads             int    // If set to something other than 0, this will be added to the commandline of each server with `--server.threads`...
+	ServerStorageEngine       string // mmfiles | rocksdb
+	RocksDBPreset             string // If set, RocksDB of dbservers & single servers is tuned using this preset (auto|small|large|write-heavy)
+	AllPortOffsetsUnique      bool   // If set, all peers will get a unique port offset. If false (default) only portOffset+peerAddress pairs will be unique.
+	PreferHostnames           bool   // If set, peers register & advertise DNS names instead of IP addresses
+	JwtSecret                 string
+	JoinToken                 string                   // If set, starters must know this token to join (instead of the JWT secret)
+	SslKeyFile                string                   // Path containing an x509 certificate + private key to be used by the servers.
+	SslCAFile                 string                   // Path containing an x509 CA certificate used to authenticate clients.
+	SslAutoKeyFile            bool                     // If set, SslKeyFile has been created by the starter.
+	ConfigFile                string                   // Path of the configuration file of the starter (if any)
+	StrictReproducibility     bool                     // If set, digests of all external inputs are recorded and verified on every start.
+	AcceptInputChanges        bool                     // If set, changed inputs are accepted and recorded again (in strict reproducibility mode).
+	Standby                   bool                     // If set, this peer joins as a standby that runs no servers until it is activated.
+	Passive                   bool                     // If set, this peer joins as a passive peer that never runs servers, it only serves the starter API.
+	StandbyFailoverDelay      time.Duration            // If set, the master activates a standby peer once another peer has been unreachable for this long.
+	AgentFailoverDelay        time.Duration            // If set, the master moves the agent of a peer to another peer once it has been unreachable for this long.
+	MasterFailoverDelay       time.Duration            // If set, another starter becomes master once the master has been unreachable for this long.
+	PassthroughOptions        []PassthroughOption      // Options passed through to the arangod servers
+	BackupSchedule            string                   // If set, logical backups are created using this schedule (cron expression)
+	BackupDir                 string                   // Directory holding the backups (default is `backups` in the data directory)
+	BackupKeep                int                      // Number of successful backups to keep (0 keeps all)
+	RestartPolicy             string                   // Restart policy of servers (always | on-failure | never)
+	RestartMaxRetries         int                      // Maximum number of consecutive quick restarts of a server before it is marked failed (0 is unlimited)
+	RestartBackoffInitial     time.Duration            // Time to wait before restarting a server after its first quick failure
+	RestartBackoffMax         time.Duration            // Maximum time to wait before restarting a server
+	CrashLoopRestarts         int                      // Number of restarts within CrashLoopWindow after which a server is in a crash loop (0 disables detection)
+	CrashLoopWindow           time.Duration            // Window of the crash loop detection
+	CrashLoopWebhook          string                   // If set, this URL is called (POST) when a server enters a crash loop
+	NotifyWebhookURL          string                   // If set, lifecycle & health events are posted (as JSON) to this URL
+	NotifyWebhookSecret       string                   // If set, notifications are signed with an HMAC-SHA256 using this secret
+	APIRateLimit              float64                  // Requests per second every client (IP address, except peers) may send to the starter API (0 disables rate limiting)
+	APIRateBurst              int                      // Number of requests a client may send at once, before it is rate limited
+	APICORSAllowedOrigins     []string                 // Origins (or *) of browser-based clients that may call the starter API
+	APIMaxBodySize            int64                    // Maximum size (in bytes) of request bodies of the starter API (0 means unlimited)
+	APIReadOnly               bool                     // If set, all mutating requests without a JWT signed with JwtSecret are refused
+	MetricsStatsd             string                   // If set (host:port), the metrics of the starter are pushed to this statsd endpoint
+	MetricsStatsdPrefix       string                   // Prefix of the names of the metrics pushed to statsd (default DefaultStatsdPrefix)
+	MetricsStatsdTags         bool                     // If set, labels are pushed as DogStatsD tags instead of as part of the metric names
+	MetricsStatsdInterval     time.Duration            // Interval between pushes of the metrics to statsd
+	LivenessInterval          time.Duration            // If set, servers are probed for liveness at this interval
+	ProxyPort                 int                      // If set, the coordinator proxy listens on this port
+	ProxyHealthInterval       time.Duration            // Interval at which the coordinator proxy checks the health of the coordinators
+	LivenessTimeout           time.Duration            // Time a server has to respond to a liveness probe
+	LivenessFailures          int                      // Number of consecutive failed liveness probes after which a server is restarted
+	AgentStartupTimeout       time.Duration            // Time an agent has to become ready after it has been started
+	DBServerStartupTimeout    time.Duration            // Time a dbserver has to become ready after it has been started
+	CoordinatorStartupTimeout time.Duration            // Time a coordinator has to become ready after it has been started
+	SingleStartupTimeout      time.Duration            // Time a single server has to become ready after it has been started
+	MaxOpenFiles              uint64                   // Soft limit of open files of servers started as process (0 raises it to the hard limit)
+	MaxProcesses              uint64                   // Soft limit of processes of servers started as process (0 raises it to the hard limit)
+	Nice                      map[ServerType]int       // Nice value of servers started as process, per server type (0 leaves it unchanged)
+	IONice                    map[ServerType]string    // I/O scheduling priority (class[:level]) of servers started as process, per server type
+	DBServerNumactl           string                   // If set, dbservers are started with numactl using these (space separated) arguments
+	DBServerCpuset            string                   // If set, dbservers are restricted to these CPUs (taskset or cgroup cpuset in docker)
+	ServerPortRanges          map[ServerType]PortRange // Ports used by servers of a type instead of the base port + offset scheme (cluster mode only)
+	LocalPortLayout           PortLayout               // Port offsets of local slaves (zero value means DefaultPortLayout)
+	ServerDirTemplate         string                   // Name of the directory of every server, with {type}, {port} & {id} replaced (default DefaultServerDirTemplate)
+	ServerDirs                map[ServerType]string    // Directory containing the directories of the servers of a type, instead of the data directory
+	LocalServers              LocalClusterSize         // Number of servers of each type in a local test cluster (zero value means one of each per local starter)
+	Hooks                     map[Hook]string          // Scripts executed at lifecycle points of the starter, overriding those found in HooksDir
+	HooksDir                  string                   // If set, scripts in this directory named after a hook (e.g. post-ready) are executed at its lifecycle point
+	HookTimeout               time.Duration            // Time a hook script may run before it is killed
+	DiskMinFree               uint64                   // Minimum free disk space of the directories used by the servers (0 disables disk space checks)
+	DiskCheckInterval         time.Duration            // Interval between checks of the free disk space (0 only checks before starting the servers)
+	MemoryTotal               uint64                   // Total memory available to all servers on this machine (0 lets every server detect it)
+	RecoveryFromBackup        string                   // If set, this backup (arangodump directory or remote hot backup) is restored into a new deployment
+	RecoveryRemoteConfig      string                   // Path of a JSON file with the configuration of the remote repository of RecoveryFromBackup
+	BootstrapManifest         string                   // If set, path of a manifest of databases, collections & users to create once a new deployment is up
+	BootstrapScripts          []string                 // Paths of JavaScript files executed (using arangosh) once a new deployment is up
+	BootstrapFoxx             []FoxxService            // Foxx services installed once a new deployment is up
+	BootstrapImportDir        string                   // If set, the files in this directory are imported (using arangoimport) once a new deployment is up
+	BootstrapImportJobs       int                      // Number of files imported at the same time (0 uses the default)
+	ClockSkewWarning          time.Duration            // Skew of the clock of a peer (relative to the master) that is logged (0 disables clock checks)
+	ClockSkewLimit            time.Duration            // Skew of the clock of a peer (relative to the master) beyond which it cannot join (0 never refuses peers)
+	StarterListen             string                   // If set (unix:///path), the starter API is served on this unix socket (instead of TCP in single server mode)
+	ServerListen              string                   // If set (unix:///path), the single server listens on this unix socket instead of its TCP port
+	StartSequential           bool                     // If set, the servers of this starter are started one after another, once the previous one is up
+	Offline                   bool                     // If set, no docker images are pulled and nothing is downloaded
+	LicenseKey                string                   // If set, this license key is passed to all servers (ARANGO_LICENSE_KEY)
+	RootPassword              string                   // If set, the password of the root user, set when the deployment is bootstrapped
+	CoordinatorDBServers      int                      // Number of dbservers in the cluster that must be up before the coordinator is started (0 does not wait)
 
 	DockerContainerName string // Name of the container running this process
 	DockerEndpoint      string // Where to reach the docker daemon
@@ -87,20 +173,74 @@ type Config struct {
 // Service implements the actual starter behavior of the ArangoDB starter.
 type Service struct {
 	Config
-	log                 *logging.Logger
+	log                 *logging.Logger // Logger of the service component
+	peersLog            *logging.Logger // Logger of the peers component
+	apiLog              *logging.Logger // Logger of the api component
+	logWithID           bool            // If set, the text log format includes the ID of this peer in each log line
 	ctx                 context.Context
 	cancel              context.CancelFunc
 	state               State
 	myPeers             peers
 	startRunningWaiter  context.Context
 	startRunningTrigger context.CancelFunc
+	activateWaiter      context.Context
+	activateTrigger     context.CancelFunc
 	announcePort        int         // Port I can be reached on from the outside
 	tlsConfig           *tls.Config // Server side TLS config (if any)
 	isNetHost           bool        // Is this process running in a container with `--net=host` or running outside a container?
 	mutex               sync.Mutex  // Mutex used to protect access to this datastructure
 	logMutex            sync.Mutex  // Mutex used to synchronize server log output
+	logRotateMutex      sync.Mutex  // Mutex used to serialize the rotation of server log files
+	setupMutex          sync.Mutex  // Mutex used to serialize writing the setup file
 	allowSameDataDir    bool        // If set, multiple arangdb instances are allowed to have the same dataDir (docker case)
 	isLocalSlave        bool
+	reloader            Reloader            // If set, used to handle `/reload` requests
+	configProvider      ConfigProvider      // If set, used to handle `/config` requests
+	logLevelSetter      LogLevelSetter      // If set, used to change the log levels of the starter
+	inputDigests        map[string]string   // Digests of all external inputs (recorded in setup.json)
+	serverBinary        string              // Digest of the arangod executable (or ID of the docker image) of this run
+	serverVersions      recordedVersions    // Versions last reported by the servers, recorded in setup.json
+	upgradedBinaries    upgradedBinaries    // Binaries servers have been upgraded to by a rolling upgrade, recorded in setup.json
+	recordedBinary      string              // Digest of the arangod executable (or ID of the docker image) recorded in setup.json
+	appliedManifest     string              // Digest of the bootstrap manifest that has been applied to the deployment, recorded in setup.json
+	executedScripts     []string            // Digests of the bootstrap scripts that have been executed successfully, recorded in setup.json
+	importedFiles       []string            // Database, collection & digest of the files that have been imported successfully, recorded in setup.json
+	imports             importProgress      // Progress of the initial data import
+	clockSkews          clockSkews          // Peers with a skewed clock (detected by the master)
+	bootstrapTasks      bootstrapTasks      // State of the bootstrap tasks (manifest, Foxx services & scripts) of a new deployment
+	upgradeOnStart      bool                // If set, the database of every server is upgraded before it is started
+	bootstrapping       bool                // If set, this run bootstraps a new deployment (it is not a relaunch)
+	serverStates        serverStates        // Last known health of the servers started by this starter
+	localSlaves         []*Service          // Services of local slaves started by this starter
+	removeDataOnStop    bool                // If set, all data of this starter is removed after its servers have stopped
+	httpServer          *http.Server        // Server serving the HTTP API (if started)
+	upgrades            upgradeManager      // State of the rolling upgrade (master only)
+	tracer              *tracer             // Tracer of the phases of the starter (nil if tracing is disabled)
+	bootstrapSpan       *span               // Root span of the bootstrap of the starter
+	bootstrapPending    map[ServerType]bool // Servers that are not yet up during the bootstrap
+	startupPhases       startupPhases       // Durations of the phases of the startup
+	resources           resourceSamples     // Last sampled resource usage of our servers
+	recentLog           *recentLogBackend   // Most recent log lines of the starter (for diagnostics)
+	audit               auditLog            // Audit log of all mutating API calls
+	replacement         replacementManager  // State of the replacement of a failed dbserver (master only)
+	runner              Runner              // Runner used to start the servers (set once running)
+	backups             backupScheduler     // State of the scheduled backups
+	hotBackups          hotBackupManager    // State of the current (or last) hot backup operation
+	operations          operationTracker    // Long-running operations started through the API
+	recoveryDone        chan struct{}       // Closed once the recovery from RecoveryFromBackup has finished (nil if no recovery is needed)
+	recoveryErr         error               // Error of a failed recovery from RecoveryFromBackup
+	dataMove            dataMoveManager     // State of moving the data directory
+	slaveScaling        slaveScalingManager // State of scaling the local slaves
+	diskSpace           diskMonitor         // Last known free disk space of the directories used by the servers
+	events              eventHub            // Subscribers of the event stream
+	joinFailures        joinFailureLimiter  // Failed join attempts, by address
+	joinProofs          joinProofCache      // Accepted join proofs, to refuse replayed hello requests
+	peerAddresses       peerAddressCache    // IP addresses of all peers, used to recognize requests of peers
+	commandLines        serverCommandLines  // Command lines used to launch the servers
+	readiness           readiness           // Signals (& callbacks) for servers that are up
+	customRunner        Runner              // If set, used instead of a process or docker runner
+	systemd             systemdNotifier     // Notifies systemd of the state of the starter (if started as a Type=notify unit)
+	runAgent            bool                // If set, the agent is restarted when it terminates
 	servers             struct {
 		agentProc       Process
 		dbserverProc    Process
@@ -111,7 +251,8 @@ type Service struct {
 }
 
 // NewService creates a new Service instance from the given config.
-func NewService(log *logging.Logger, config Config, isLocalSlave bool) (*Service, error) {
+// Use options to customize the service (e.g. OnReady).
+func NewService(config Config, options ...Option) (*Service, error) {
 	// Create unique ID
 	if config.ID == "" {
 		var err error
@@ -132,6 +273,8 @@ func NewService(log *logging.Logger, config Config, isLocalSlave bool) (*Service
 	default:
 		return nil, maskAny(fmt.Errorf("Unknown mode '%s'", config.Mode))
 	}
+	config.OwnAddress = trimIPv6Brackets(config.OwnAddress)
+	config.BindAddress = trimIPv6Brackets(config.BindAddress)
 
 	// Load certificates (if needed)
 	var tlsConfig *tls.Config
@@ -146,15 +289,31 @@ func NewService(log *logging.Logger, config Config, isLocalSlave bool) (*Service
 	}
 
 	ctx, trigger := context.WithCancel(context.Background())
-	return &Service{
+	activateCtx, activateTrigger := context.WithCancel(context.Background())
+	s := &Service{
 		Config:              config,
-		log:                 log,
 		state:               stateStart,
 		startRunningWaiter:  ctx,
 		startRunningTrigger: trigger,
-		isLocalSlave:        isLocalSlave,
+		activateWaiter:      activateCtx,
+		activateTrigger:     activateTrigger,
 		tlsConfig:           tlsConfig,
-	}, nil
+		recentLog:           newRecentLogBackend(recentStarterLogLines),
+		readiness:           readiness{ready: make(chan struct{})},
+	}
+	for _, o := range options {
+		o(s)
+	}
+	s.initLoggers()
+	return s, nil
+}
+
+// Reloader re-reads the configuration of the starter and applies all changes that can be applied at runtime.
+type Reloader func() (ReloadResponse, error)
+
+// SetReloader sets the function used to handle `/reload` requests.
+func (s *Service) SetReloader(reloader Reloader) {
+	s.reloader = reloader
 }
 
 // createUniqueID creates a new random ID.
@@ -184,8 +343,8 @@ const (
 )
 
 const (
-	minRecentFailuresForLog = 2   // Number of recent failures needed before a log file is shown.
-	maxRecentFailures       = 100 // Maximum number of recent failures before the starter gives up.
+	minRecentFailuresForLog = 2               // Number of recent failures needed before a log file is shown.
+	defaultStartupTimeout   = time.Minute * 5 // Time a server has to become ready when no startup timeout is configured.
 )
 
 const (
@@ -195,6 +354,7 @@ const (
 
 // normalizeHostName normalizes all loopback addresses to "localhost"
 func normalizeHostName(host string) string {
+	host = trimIPv6Brackets(host)
 	if ip := net.ParseIP(host); ip != nil {
 		if ip.IsLoopback() {
 			return "localhost"
@@ -203,6 +363,15 @@ func normalizeHostName(host string) string {
 	return host
 }
 
+// trimIPv6Brackets removes the brackets around an IPv6 literal (e.g. `[::1]` becomes `::1`).
+// Addresses are stored without brackets, net.JoinHostPort adds them where needed.
+func trimIPv6Brackets(host string) string {
+	if strings.HasPrefix(host, "[") && strings.HasSuffix(host, "]") {
+		return host[1 : len(host)-1]
+	}
+	return host
+}
+
 // For Windows we need to change backslashes to slashes, strangely enough:
 func slasher(s string) string {
 	return strings.Replace(s, "\\", "/", -1)
@@ -220,9 +389,27 @@ func (s *Service) serverPort(serverType ServerType) (int, error) {
 		// Cannot find my own peer.
 		return 0, maskAny(fmt.Errorf("Cannot find peer %s", s.ID))
 	}
-	// Find log path
-	portOffset := myPeer.PortOffset
-	return s.MasterPort + portOffset + serverType.PortOffset(), nil
+	return myPeer.ServerPort(s.MasterPort, serverType), nil
+}
+
+// freeServerPorts returns the ports of the servers of a new peer at the given address,
+// for all server types that have a configured range of ports.
+// No agent port is assigned to peers that do not run an agent.
+func (s *Service) freeServerPorts(address string, hasAgent bool) (map[ServerType]int, error) {
+	if !s.isClusterMode() {
+		return nil, nil
+	}
+	ranges := make(map[ServerType]PortRange)
+	for serverType, r := range s.ServerPortRanges {
+		if serverType != ServerTypeAgent || hasAgent {
+			ranges[serverType] = r
+		}
+	}
+	ports, err := s.myPeers.GetFreeServerPorts(address, s.AllPortOffsetsUnique, ranges)
+	if err != nil {
+		return nil, maskAny(err)
+	}
+	return ports, nil
 }
 
 // serverHostDir returns the path of the folder (in host namespace) containing data for the given server.
@@ -231,7 +418,7 @@ func (s *Service) serverHostDir(serverType ServerType) (string, error) {
 	if err != nil {
 		return "", maskAny(err)
 	}
-	return filepath.Join(s.DataDir, fmt.Sprintf("%s%d", serverType, myPort)), nil
+	return filepath.Join(s.serverBaseDir(serverType), s.serverDirName(serverType, myPort)), nil
 }
 
 // serverExecutable returns the path of the server's executable.
@@ -242,13 +429,17 @@ func (s *Service) serverExecutable() string {
 	return s.ArangodPath
 }
 
-// testInstance checks the `up` status of an arangod server instance.
-func (s *Service) testInstance(ctx context.Context, address string, port int) (up bool, version string, cancelled bool) {
-	instanceUp := make(chan string)
+// testInstance checks the `up` status of an arangod server instance of given type.
+func (s *Service) testInstance(ctx context.Context, serverType ServerType, address string, port int, timeout time.Duration) (up bool, version string, cancelled bool) {
+	instanceUp := make(chan string, 1)
 	go func() {
 		client := &http.Client{Timeout: time.Second * 10}
 		scheme := "http"
-		if s.IsSecure() {
+		addr := net.JoinHostPort(address, strconv.Itoa(port))
+		if socket := s.serverSocket(serverType); socket != "" {
+			client = serverSocketHTTPClient(socket, client.Timeout)
+			addr = "localhost"
+		} else if s.IsSecure() {
 			scheme = "https"
 			client.Transport = &http.Transport{
 				TLSClientConfig: &tls.Config{
@@ -257,7 +448,6 @@ func (s *Service) testInstance(ctx context.Context, address string, port int) (u
 			}
 		}
 		makeRequest := func() (string, error) {
-			addr := net.JoinHostPort(address, strconv.Itoa(port))
 			url := fmt.Sprintf("%s://%s/_api/version", scheme, addr)
 			req, err := http.NewRequest("GET", url, nil)
 			if err != nil {
@@ -284,10 +474,11 @@ func (s *Service) testInstance(ctx context.Context, address string, port int) (u
 			return versionResponse.Version, nil
 		}
 
-		for i := 0; i < 300; i++ {
+		deadline := time.Now().Add(timeout)
+		for time.Now().Before(deadline) && ctx.Err() == nil {
 			if version, err := makeRequest(); err == nil {
 				instanceUp <- version
-				break
+				return
 			}
 			time.Sleep(time.Millisecond * 500)
 		}
@@ -295,87 +486,125 @@ func (s *Service) testInstance(ctx context.Context, address string, port int) (u
 	}()
 	select {
 	case version := <-instanceUp:
+		if version == "" && ctx.Err() != nil {
+			return false, "", true
+		}
 		return version != "", version, false
 	case <-ctx.Done():
 		return false, "", true
 	}
 }
 
-// makeBaseArgs returns the command line arguments needed to run an arangod server of given type.
-func (s *Service) makeBaseArgs(myHostDir, myContainerDir string, myAddress string, myPort string, serverType ServerType) (args []string, configVolumes []Volume) {
-	hostConfFileName := filepath.Join(myHostDir, confFileName)
-	containerConfFileName := filepath.Join(myContainerDir, confFileName)
+// startupTimeout returns the time a server of given type has to become ready
+// after it has been started, together with the name of the option that sets it.
+func (s *Service) startupTimeout(serverType ServerType) (time.Duration, string) {
+	var timeout time.Duration
+	var option string
+	switch serverType {
+	case ServerTypeAgent:
+		timeout, option = s.AgentStartupTimeout, "cluster.agent-startup-timeout"
+	case ServerTypeDBServer:
+		timeout, option = s.DBServerStartupTimeout, "cluster.dbserver-startup-timeout"
+	case ServerTypeCoordinator:
+		timeout, option = s.CoordinatorStartupTimeout, "cluster.coordinator-startup-timeout"
+	default:
+		timeout, option = s.SingleStartupTimeout, "server.single-startup-timeout"
+	}
+	if timeout <= 0 {
+		timeout = defaultStartupTimeout
+	}
+	return timeout, option
+}
+
+// serverPlacement returns the CPUs & NUMA nodes the server of given type is restricted to.
+func (s *Service) serverPlacement(serverType ServerType) Placement {
+	if serverType != ServerTypeDBServer {
+		return Placement{}
+	}
+	return Placement{
+		Numactl: strings.Fields(s.DBServerNumactl),
+		Cpuset:  s.DBServerCpuset,
+	}
+}
+
+// createArangodConf creates the content of the configuration file for an arangod server of given type.
+func (s *Service) createArangodConf(serverType ServerType, myPort string) configFile {
 	scheme := "tcp"
 	if s.IsSecure() {
 		scheme = "ssl"
 	}
-
-	if runtime.GOOS != "linux" {
-		configVolumes = append(configVolumes, Volume{
-			HostPath:      hostConfFileName,
-			ContainerPath: containerConfFileName,
-			ReadOnly:      true,
-		})
+	var threads, v8Contexts string
+	logLevel := "INFO"
+	switch serverType {
+	// Parameters are: port, server threads, log level, v8-contexts
+	case ServerTypeAgent:
+		threads = "8"
+		v8Contexts = "1"
+	case ServerTypeDBServer:
+		threads = "4"
+		v8Contexts = "4"
+	case ServerTypeCoordinator, ServerTypeSingle:
+		threads = "16"
+		v8Contexts = "4"
 	}
-
-	if _, err := os.Stat(hostConfFileName); os.IsNotExist(err) {
-		var threads, v8Contexts string
-		logLevel := "INFO"
-		switch serverType {
-		// Parameters are: port, server threads, log level, v8-contexts
-		case ServerTypeAgent:
-			threads = "8"
-			v8Contexts = "1"
-		case ServerTypeDBServer:
-			threads = "4"
-			v8Contexts = "4"
-		case ServerTypeCoordinator, ServerTypeSingle:
-			threads = "16"
-			v8Contexts = "4"
-		}
-		serverSection := &configSection{
-			Name: "server",
+	bindAddress := s.serverBindAddress()
+	if bindAddress == "" {
+		bindAddress = "::"
+	}
+	serverSection := &configSection{
+		Name: "server",
+		Settings: map[string]string{
+			"endpoint":       fmt.Sprintf("%s://%s", scheme, net.JoinHostPort(bindAddress, myPort)),
+			"threads":        threads,
+			"authentication": "false",
+		},
+	}
+	if socket := s.serverSocket(serverType); socket != "" {
+		serverSection.Settings["endpoint"] = unixSocketPrefix + socket
+	}
+	if s.JwtSecret != "" {
+		serverSection.Settings["authentication"] = "true"
+		serverSection.Settings["jwt-secret"] = s.JwtSecret
+	}
+	if s.ServerStorageEngine == "rocksdb" {
+		serverSection.Settings["storage-engine"] = "rocksdb"
+	}
+	config := configFile{
+		serverSection,
+		&configSection{
+			Name: "log",
 			Settings: map[string]string{
-				"endpoint":       fmt.Sprintf("%s://[::]:%s", scheme, myPort),
-				"threads":        threads,
-				"authentication": "false",
+				"level": logLevel,
 			},
-		}
-		if s.JwtSecret != "" {
-			serverSection.Settings["authentication"] = "true"
-			serverSection.Settings["jwt-secret"] = s.JwtSecret
-		}
-		if s.ServerStorageEngine == "rocksdb" {
-			serverSection.Settings["storage-engine"] = "rocksdb"
-		}
-		config := configFile{
-			serverSection,
-			&configSection{
-				Name: "log",
-				Settings: map[string]string{
-					"level": logLevel,
-				},
+		},
+		&configSection{
+			Name: "javascript",
+			Settings: map[string]string{
+				"v8-contexts": v8Contexts,
 			},
-			&configSection{
-				Name: "javascript",
-				Settings: map[string]string{
-					"v8-contexts": v8Contexts,
-				},
+		},
+	}
+	if s.IsSecure() {
+		sslSection := &configSection{
+			Name: "ssl",
+			Settings: map[string]string{
+				"keyfile": s.SslKeyFile,
 			},
 		}
-		if s.IsSecure() {
-			sslSection := &configSection{
-				Name: "ssl",
-				Settings: map[string]string{
-					"keyfile": s.SslKeyFile,
-				},
-			}
-			if s.SslCAFile != "" {
-				sslSection.Settings["cafile"] = s.SslCAFile
-			}
-			config = append(config, sslSection)
+		if s.SslCAFile != "" {
+			sslSection.Settings["cafile"] = s.SslCAFile
 		}
+		config = append(config, sslSection)
+	}
+	return config
+}
 
+// writeArangodConf writes the configuration file for an arangod server of given type
+// into the given host directory, unless it already exists.
+func (s *Service) writeArangodConf(myHostDir string, myPort string, serverType ServerType) {
+	hostConfFileName := filepath.Join(myHostDir, confFileName)
+	if _, err := os.Stat(hostConfFileName); os.IsNotExist(err) {
+		config := s.createArangodConf(serverType, myPort)
 		out, e := os.Create(hostConfFileName)
 		if e != nil {
 			s.log.Fatalf("Could not create configuration file %s, error: %#v", hostConfFileName, e)
@@ -386,9 +615,27 @@ func (s *Service) makeBaseArgs(myHostDir, myContainerDir string, myAddress strin
 			s.log.Fatalf("Cannot create config file: %v", err)
 		}
 	}
+}
+
+// makeBaseArgs returns the command line arguments needed to run an arangod server of given type.
+func (s *Service) makeBaseArgs(myHostDir, myContainerDir string, myAddress string, myPort string, serverType ServerType) (args []string, configVolumes []Volume) {
+	hostConfFileName := filepath.Join(myHostDir, confFileName)
+	containerConfFileName := filepath.Join(myContainerDir, confFileName)
+	scheme := "tcp"
+	if s.IsSecure() {
+		scheme = "ssl"
+	}
+
+	if runtime.GOOS != "linux" {
+		configVolumes = append(configVolumes, Volume{
+			HostPath:      hostConfFileName,
+			ContainerPath: containerConfFileName,
+			ReadOnly:      true,
+		})
+	}
+
 	args = make([]string, 0, 40)
-	executable := s.ArangodPath
-	jsStartup := s.ArangodJSPath
+	executable, jsStartup := s.serverArangodPaths(serverType)
 	if s.RrPath != "" {
 		args = append(args, s.RrPath)
 	}
@@ -401,6 +648,10 @@ func (s *Service) makeBaseArgs(myHostDir, myContainerDir string, myAddress strin
 		"--log.file", slasher(filepath.Join(myContainerDir, logFileName)),
 		"--log.force-direct", "false",
 	)
+	if s.usesDockerLogDriver() {
+		// Log to stdout as well, so the log driver of the container can forward it
+		args = append(args, "--log.output", "-")
+	}
 	if s.ServerThreads != 0 {
 		args = append(args, "--server.threads", strconv.Itoa(s.ServerThreads))
 	}
@@ -419,7 +670,7 @@ func (s *Service) makeBaseArgs(myHostDir, myContainerDir string, myAddress strin
 			if p.HasAgent && p.ID != s.ID {
 				args = append(args,
 					"--agency.endpoint",
-					fmt.Sprintf("%s://%s", scheme, net.JoinHostPort(p.Address, strconv.Itoa(s.MasterPort+p.PortOffset+_portOffsetAgent))),
+					fmt.Sprintf("%s://%s", scheme, net.JoinHostPort(p.Address, strconv.Itoa(p.ServerPort(s.MasterPort, ServerTypeAgent)))),
 				)
 			}
 		}
@@ -446,14 +697,17 @@ func (s *Service) makeBaseArgs(myHostDir, myContainerDir string, myAddress strin
 		)
 	}
 	if serverType != ServerTypeAgent && serverType != ServerTypeSingle {
-		for i := 0; i < s.AgencySize; i++ {
-			p := s.myPeers.Peers[i]
-			args = append(args,
-				"--cluster.agency-endpoint",
-				fmt.Sprintf("%s://%s", scheme, net.JoinHostPort(p.Address, strconv.Itoa(s.MasterPort+p.PortOffset+_portOffsetAgent))),
-			)
+		for _, p := range s.myPeers.Peers {
+			if p.HasAgent {
+				args = append(args,
+					"--cluster.agency-endpoint",
+					fmt.Sprintf("%s://%s", scheme, net.JoinHostPort(p.Address, strconv.Itoa(p.ServerPort(s.MasterPort, ServerTypeAgent)))),
+				)
+			}
 		}
 	}
+	args = append(args, s.rocksDBPresetArgs(serverType)...)
+	args = s.addPassthroughArgs(args, serverType)
 	return
 }
 
@@ -481,8 +735,23 @@ func addDataVolumes(configVolumes []Volume, hostPath, containerPath string) []Vo
 	return configVolumes
 }
 
+// createServerCommand returns the command line, volumes & container name used to start an arangod server of given type.
+func (s *Service) createServerCommand(runner Runner, myHostAddress, myHostDir string, myPort int, serverType ServerType, restart int) ([]string, []Volume, string) {
+	myContainerDir := runner.GetContainerDir(myHostDir)
+	args, vols := s.makeBaseArgs(myHostDir, myContainerDir, myHostAddress, strconv.Itoa(myPort), serverType)
+	vols = addDataVolumes(vols, myHostDir, myContainerDir)
+	containerNamePrefix := ""
+	if s.DockerContainerName != "" {
+		containerNamePrefix = fmt.Sprintf("%s-", s.DockerContainerName)
+	}
+	containerName := fmt.Sprintf("%s%s-%s-%d-%s-%d", containerNamePrefix, serverType, s.ID, restart, myHostAddress, myPort)
+	return args, vols, containerName
+}
+
 // startArangod starts a single Arango server of the given type.
-func (s *Service) startArangod(runner Runner, myHostAddress string, serverType ServerType, restart int) (Process, bool, error) {
+func (s *Service) startArangod(runner Runner, myHostAddress string, serverType ServerType, restart int, autoUpgrade bool) (Process, bool, error) {
+	runner = s.serverRunner(runner, serverType)
+	serverLog := s.serverLogger(serverType)
 	myPort, err := s.serverPort(serverType)
 	if err != nil {
 		return nil, false, maskAny(err)
@@ -495,138 +764,248 @@ func (s *Service) startArangod(runner Runner, myHostAddress string, serverType S
 	os.MkdirAll(filepath.Join(myHostDir, "apps"), 0755)
 
 	// Check if the server is already running
-	s.log.Infof("Looking for a running instance of %s on port %d", serverType, myPort)
+	serverLog.Infof("Looking for a running instance of %s on port %d", serverType, myPort)
 	p, err := runner.GetRunningServer(myHostDir)
 	if err != nil {
 		return nil, false, maskAny(err)
 	}
 	if p != nil {
-		s.log.Infof("%s seems to be running already, checking port %d...", serverType, myPort)
-		ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
-		up, _, _ := s.testInstance(ctx, myHostAddress, myPort)
-		cancel()
+		serverLog.Infof("%s seems to be running already, checking port %d...", serverType, myPort)
+		up, _, _ := s.testInstance(context.Background(), serverType, myHostAddress, myPort, time.Second*10)
 		if up {
-			s.log.Infof("%s is already running on %d. No need to start anything.", serverType, myPort)
+			serverLog.Infof("%s is already running on %d. No need to start anything.", serverType, myPort)
 			return p, false, nil
 		}
-		s.log.Infof("%s is not up on port %d. Terminating existing process and restarting it...", serverType, myPort)
+		serverLog.Infof("%s is not up on port %d. Terminating existing process and restarting it...", serverType, myPort)
 		p.Terminate()
 	}
 
 	// Check availability of port
-	if !IsPortOpen(myPort) {
+	if s.serverSocket(serverType) == "" && !IsPortOpen(s.serverBindAddress(), myPort) {
 		return nil, true, maskAny(fmt.Errorf("Cannot start %s, because port %d is already in use", serverType, myPort))
 	}
 
-	s.log.Infof("Starting %s on port %d", serverType, myPort)
-	myContainerDir := runner.GetContainerDir(myHostDir)
-	args, vols := s.makeBaseArgs(myHostDir, myContainerDir, myHostAddress, strconv.Itoa(myPort), serverType)
-	vols = addDataVolumes(vols, myHostDir, myContainerDir)
+	serverLog.Infof("Starting %s on port %d", serverType, myPort)
+	s.writeArangodConf(myHostDir, strconv.Itoa(myPort), serverType)
+	args, vols, containerName := s.createServerCommand(runner, myHostAddress, myHostDir, myPort, serverType, restart)
+	if autoUpgrade {
+		serverLog.Infof("Upgrading database of %s", serverType)
+		args = append(args, "--database.auto-upgrade=true")
+	}
 	s.writeCommand(filepath.Join(myHostDir, "arangod_command.txt"), s.serverExecutable(), args)
-	containerNamePrefix := ""
-	if s.DockerContainerName != "" {
-		containerNamePrefix = fmt.Sprintf("%s-", s.DockerContainerName)
+	coreDir, err := s.serverCoreDir(serverType)
+	if err != nil {
+		return nil, false, maskAny(err)
+	}
+	if coreDir != "" {
+		os.MkdirAll(coreDir, 0755)
 	}
-	containerName := fmt.Sprintf("%s%s-%s-%d-%s-%d", containerNamePrefix, serverType, s.ID, restart, myHostAddress, myPort)
 	ports := []int{myPort}
-	if p, err := runner.Start(args[0], args[1:], vols, ports, containerName, myHostDir); err != nil {
+	placement := s.serverPlacement(serverType)
+	if !placement.IsEmpty() {
+		serverLog.Infof("Restricting %s to %s", serverType, placement)
+	}
+	env := s.serverEnv(serverType)
+	if p, err := runner.Start(args[0], args[1:], vols, ports, containerName, myHostDir, coreDir, placement, env); err != nil {
 		return nil, false, maskAny(err)
 	} else {
+		s.applyServerPriority(serverType, p)
+		s.recordCommandLine(runner, serverType, args, vols, ports, containerName, coreDir, placement, env)
 		return p, false, nil
 	}
 }
 
 // showRecentLogs dumps the most recent log lines of the server of given type to the console.
 func (s *Service) showRecentLogs(serverType ServerType) {
+	serverLog := s.serverLogger(serverType)
 	myHostDir, err := s.serverHostDir(serverType)
 	if err != nil {
-		s.log.Errorf("Cannot find server host dir: %#v", err)
+		serverLog.Errorf("Cannot find server host dir: %#v", err)
 		return
 	}
-	logPath := filepath.Join(myHostDir, logFileName)
-	logFile, err := os.Open(logPath)
-	if os.IsNotExist(err) {
-		s.log.Infof("Log file for %s is empty", serverType)
+	s.showRecentLines(serverType, "log", filepath.Join(myHostDir, logFileName))
+	// Errors written before the log file is opened only end up in stderr
+	if stderrPath, err := latestOutputFile(myHostDir, OutputStreamStderr); err == nil && stderrPath != "" {
+		if info, err := os.Stat(stderrPath); err == nil && info.Size() > 0 {
+			s.showRecentLines(serverType, OutputStreamStderr, stderrPath)
+		}
+	}
+}
+
+// recentServerLogLines returns the most recent lines of the log (and stderr output) of the server of given type,
+// or nil if they cannot be read.
+func (s *Service) recentServerLogLines(serverType ServerType, maxLines int) []string {
+	myHostDir, err := s.serverHostDir(serverType)
+	if err != nil {
+		return nil
+	}
+	paths := []string{filepath.Join(myHostDir, logFileName)}
+	if stderrPath, err := latestOutputFile(myHostDir, OutputStreamStderr); err == nil && stderrPath != "" {
+		paths = append(paths, stderrPath)
+	}
+	var result []string
+	for _, path := range paths {
+		lines, err := readRecentLogLines(path, maxLines)
+		if err != nil {
+			continue
+		}
+		for _, line := range lines {
+			result = append(result, strings.TrimSuffix(line, "\n"))
+		}
+	}
+	return result
+}
+
+// showRecentLines dumps the most recent lines of the given file (log or output) of the server of given type to the console.
+func (s *Service) showRecentLines(serverType ServerType, what, path string) {
+	serverLog := s.serverLogger(serverType)
+	lines, err := readRecentLogLines(path, 20)
+	if os.IsNotExist(errors.Cause(err)) {
+		serverLog.Infof("The %s file of %s is empty", what, serverType)
 	} else if err != nil {
-		s.log.Errorf("Cannot open log file for %s: %#v", serverType, err)
+		serverLog.Errorf("Cannot open %s file for %s: %#v", what, serverType, err)
 	} else {
-		defer logFile.Close()
-		rd := bufio.NewReader(logFile)
-		lines := [20]string{}
-		maxLines := 0
-		for {
-			line, err := rd.ReadString('\n')
-			if line != "" || err == nil {
-				copy(lines[1:], lines[0:])
-				lines[0] = line
-				if maxLines < len(lines) {
-					maxLines++
-				}
-			}
-			if err != nil {
-				break
-			}
-		}
 		s.logMutex.Lock()
 		defer s.logMutex.Unlock()
-		s.log.Infof("## Start of %s log", serverType)
-		for i := maxLines - 1; i >= 0; i-- {
-			fmt.Println("\t" + strings.TrimSuffix(lines[i], "\n"))
+		serverLog.Infof("## Start of %s %s", serverType, what)
+		for _, line := range lines {
+			line = strings.TrimSuffix(line, "\n")
+			if s.LogFormat == LogFormatJSON {
+				serverLog.Info(newLogEvent("server-log", LogFields{"stream": what}, "%s", line))
+			} else {
+				fmt.Println("\t" + line)
+			}
 		}
-		s.log.Infof("## End of %s log", serverType)
+		serverLog.Infof("## End of %s %s", serverType, what)
 	}
 }
 
 // runArangod starts a single Arango server of the given type and keeps restarting it when needed.
 func (s *Service) runArangod(runner Runner, myPeer Peer, serverType ServerType, processVar *Process, runProcess_ *bool) {
+	serverLog := s.serverLogger(serverType)
 	restart := 0
 	recentFailures := 0
 	for {
 		myHostAddress := myPeer.Address
 		startTime := time.Now()
-		p, portInUse, err := s.startArangod(runner, myHostAddress, serverType, restart)
+		autoUpgrade := s.serverStates.takeAutoUpgrade(serverType)
+		startSpan := s.bootstrapSpan.child("start "+string(serverType), map[string]string{
+			"server-type":  string(serverType),
+			"restart":      strconv.Itoa(restart),
+			"auto-upgrade": strconv.FormatBool(autoUpgrade),
+		})
+		p, portInUse, err := s.startArangod(runner, myHostAddress, serverType, restart, autoUpgrade)
+		exitCode := -1
+		startupTimedOut := false
 		if err != nil {
-			s.log.Errorf("Error while starting %s: %#v", serverType, err)
+			startSpan.finish(err)
+			serverLog.Errorf("Error while starting %s: %#v", serverType, err)
 			if !portInUse {
 				break
 			}
+		} else if autoUpgrade {
+			// The server terminates by itself once its database has been upgraded
+			*processVar = p
+			if exitCode := p.Wait(); exitCode != 0 {
+				serverLog.Errorf("Database upgrade of %s has failed with exit code %d", serverType, exitCode)
+				s.serverStates.setUpgradeFailed(serverType, fmt.Sprintf("Database upgrade has failed with exit code %d", exitCode))
+				startSpan.finish(fmt.Errorf("Database upgrade has failed with exit code %d", exitCode))
+			} else {
+				serverLog.Infof("Database upgrade of %s has finished", serverType)
+				startSpan.finish(nil)
+			}
+			if s.stop {
+				break
+			}
+			restart++
+			continue
 		} else {
 			*processVar = p
 			ctx, cancel := context.WithCancel(s.ctx)
+			startupFailed := make(chan struct{})
 			go func() {
 				port, err := s.serverPort(serverType)
 				if err != nil {
-					s.log.Fatalf("Cannot collect serverPort: %#v", err)
+					serverLog.Fatalf("Cannot collect serverPort: %#v", err)
 				}
-				if up, version, cancelled := s.testInstance(ctx, myHostAddress, port); !cancelled {
+				timeout, option := s.startupTimeout(serverType)
+				if up, version, cancelled := s.testInstance(ctx, serverType, myHostAddress, port, timeout); !cancelled {
 					if up {
-						s.log.Infof("%s up and running (version %s).", serverType, version)
+						serverLog.Info(newLogEvent("server-up", LogFields{"version": version, "port": port},
+							"%s up and running (version %s).", serverType, version))
+						s.serverStates.setUp(serverType, version)
+						s.recordServerVersion(serverType, version)
+						s.publishServerEvent(ProcessEventServerUp, serverType, version, "")
+						s.signalServerUp(serverType, version)
+						startSpan.setAttribute("version", version)
+						startSpan.finish(nil)
+						s.serverUpStartupPhase(serverType)
+						s.bootstrapServerUp(serverType)
 						if (serverType == ServerTypeCoordinator && !s.isLocalSlave) || serverType == ServerTypeSingle {
+							if err := s.waitForRecovery(ctx); err != nil {
+								if ctx.Err() == nil {
+									serverLog.Errorf("%s is up, but the deployment could not be recovered from %s: %v", serverType, s.RecoveryFromBackup, err)
+								}
+								return
+							}
 							hostPort, err := p.HostPort(port)
-							if err != nil {
+							if socket := s.serverSocket(serverType); socket != "" {
+								endpoint := unixSocketPrefix + socket
+								s.logMutex.Lock()
+								serverLog.Info(newLogEvent("ready", LogFields{
+									"what":              "single server",
+									"arangosh-endpoint": endpoint,
+								}, "Your single server can now be accessed using `arangosh --server.endpoint %s`.", endpoint))
+								s.logMutex.Unlock()
+							} else if err != nil {
 								if id := p.ContainerID(); id != "" {
-									s.log.Infof("%s can only be accessed from inside a container.", serverType)
+									serverLog.Infof("%s can only be accessed from inside a container.", serverType)
 								}
 							} else {
-								ip := myPeer.Address
+								addr := net.JoinHostPort(myPeer.Address, strconv.Itoa(hostPort))
 								urlSchemes := NewURLSchemes(myPeer.IsSecure)
 								what := "cluster"
 								if serverType == ServerTypeSingle {
 									what = "single server"
 								}
 								s.logMutex.Lock()
-								s.log.Infof("Your %s can now be accessed with a browser at `%s://%s:%d` or", what, urlSchemes.Browser, ip, hostPort)
-								s.log.Infof("using `arangosh --server.endpoint %s://%s:%d`.", urlSchemes.ArangoSH, ip, hostPort)
+								serverLog.Info(newLogEvent("ready", LogFields{
+									"what":              what,
+									"browser-url":       fmt.Sprintf("%s://%s", urlSchemes.Browser, addr),
+									"arangosh-endpoint": fmt.Sprintf("%s://%s", urlSchemes.ArangoSH, addr),
+								}, "Your %s can now be accessed with a browser at `%s://%s` or", what, urlSchemes.Browser, addr))
+								serverLog.Infof("using `arangosh --server.endpoint %s://%s`.", urlSchemes.ArangoSH, addr)
 								s.logMutex.Unlock()
 							}
 						}
 					} else {
-						s.log.Warningf("%s not ready after 5min!", serverType)
+						reason := fmt.Sprintf("%s did not become ready within %s (see --%s)", serverType, timeout, option)
+						serverLog.Error(newLogEvent("server-startup-timeout", LogFields{"port": port, "timeout": timeout.String()},
+							"%s on port %d did not become ready within %s, terminating it. Use --%s to allow more time.", serverType, port, timeout, option))
+						startSpan.finish(fmt.Errorf("%s", reason))
+						s.serverStates.setFailed(serverType, reason)
+						s.publishServerEvent(ProcessEventServerFailed, serverType, "", reason)
+						close(startupFailed)
+						p.Terminate()
 					}
 				}
 			}()
-			p.Wait()
+			exitCode = p.Wait()
 			cancel()
+			select {
+			case <-startupFailed:
+				startupTimedOut = true
+			default:
+			}
+			startSpan.finish(fmt.Errorf("%s has terminated before it was up", serverType))
+			if s.CoreDirectory != "" {
+				s.collectCrash(serverType, startTime, exitCode)
+			}
+			s.serverStates.setDown(serverType)
+			s.publishServerEvent(ProcessEventServerDown, serverType, "", "")
+			if exitCode != 0 && !s.stop && !startupTimedOut {
+				s.publishServerEvent(ProcessEventServerCrash, serverType, "", fmt.Sprintf("exit code %d", exitCode))
+			}
 		}
 		uptime := time.Since(startTime)
 		var isRecentFailure bool
@@ -640,29 +1019,60 @@ func (s *Service) runArangod(runner Runner, myPeer Peer, serverType ServerType,
 
 		if isRecentFailure {
 			if !portInUse {
-				s.log.Infof("%s has terminated, quickly, in %s (recent failures: %d)", serverType, uptime, recentFailures)
+				serverLog.Infof("%s has terminated, quickly, in %s (recent failures: %d)", serverType, uptime, recentFailures)
 				if recentFailures >= minRecentFailuresForLog {
 					// Show logs of the server
 					s.showRecentLogs(serverType)
 				}
 			}
-			if recentFailures >= maxRecentFailures {
-				s.log.Errorf("%s has failed %d times, giving up", serverType, recentFailures)
-				s.stop = true
-				break
-			}
 		} else {
-			s.log.Infof("%s has terminated", serverType)
+			serverLog.Infof("%s has terminated", serverType)
 		}
 		if portInUse {
 			time.Sleep(time.Second)
 		}
 
+		if s.stop || startupTimedOut {
+			break
+		}
+		if runProcess_ != nil && !*runProcess_ {
+			serverLog.Infof("%s is no longer needed, not restarting it", serverType)
+			*processVar = nil
+			break
+		}
+		restartRequested := s.serverStates.takeRestartRequest(serverType)
+		if !portInUse && !restartRequested {
+			// Apply the restart policy
+			if ok, failure := s.shouldRestart(exitCode, recentFailures); !ok {
+				if failure != "" {
+					serverLog.Error(newLogEvent("server-failed", LogFields{"exit-code": exitCode, "recent-failures": recentFailures},
+						"%s has failed, not restarting it: %s", serverType, failure))
+					s.serverStates.setFailed(serverType, failure)
+					s.publishServerEvent(ProcessEventServerFailed, serverType, "", failure)
+				} else {
+					serverLog.Infof("%s has terminated normally, not restarting it (restart policy %s)", serverType, s.RestartPolicy)
+				}
+				break
+			}
+			if isRecentFailure {
+				delay := s.restartBackoff(recentFailures)
+				if delay > 0 {
+					serverLog.Infof("Waiting %s before restarting %s", delay, serverType)
+				}
+				if !s.waitBeforeRestart(delay) {
+					break
+				}
+			}
+		}
+		s.waitWhileMovingData(serverType)
 		if s.stop {
 			break
 		}
 
-		s.log.Infof("restarting %s", serverType)
+		serverLog.Infof("restarting %s", serverType)
+		s.serverStates.addRestart(serverType)
+		s.publishServerEvent(ProcessEventServerRestart, serverType, "", "")
+		s.checkCrashLoop(serverType)
 		restart++
 	}
 }
@@ -670,32 +1080,140 @@ func (s *Service) runArangod(runner Runner, myPeer Peer, serverType ServerType,
 // startRunning starts all relevant servers and keeps the running.
 func (s *Service) startRunning(runner Runner) {
 	s.state = stateRunning
+	s.runner = runner
 	myPeer, ok := s.myPeers.PeerByID(s.ID)
 	if !ok {
 		s.log.Fatalf("Cannot find peer information for my ID ('%s')", s.ID)
 	}
+	if myPeer.HasDBServer {
+		// A dbserver has been started on this peer to replace a failed dbserver
+		s.StartDBserver = true
+	}
+	s.finishStartupPhase(phasePeerDiscovery)
+	s.checkDiskSpaceBeforeStart()
 
+	if s.StandbyFailoverDelay > 0 {
+		go s.watchForFailedPeers()
+	}
+	go s.followMasterPeers()
+	if s.MasterFailoverDelay > 0 && s.isClusterMode() {
+		go s.runMasterElection()
+	}
 	if s.isClusterMode() {
+		go s.runPeerMembership()
+	}
+	if s.AgentFailoverDelay > 0 && s.isClusterMode() {
+		go s.watchForLostAgents()
+	}
+	go s.sampleResourceUsage()
+	go s.watchPeerEvents()
+	if s.LivenessInterval > 0 {
+		go s.probeLiveness()
+	}
+	if s.ProxyPort != 0 && s.isClusterMode() {
+		go s.runCoordinatorProxy()
+	}
+	if s.CoreDirectory != "" {
+		s.prepareCoreDumps()
+	}
+	if s.LogRotateSize > 0 {
+		go s.rotateServerLogs()
+	}
+	if s.LicenseKey != "" {
+		go s.watchLicenseExpiry()
+	}
+	if s.NotifyWebhookURL != "" {
+		go s.runNotifyWebhook(s.events.subscribe())
+	}
+	if s.MetricsStatsd != "" {
+		go s.runStatsdPush()
+	}
+	if s.DiskMinFree > 0 && s.DiskCheckInterval > 0 && !s.isLocalSlave {
+		go s.watchDiskSpace()
+	}
+	if s.ClockSkewWarning > 0 && !s.isLocalSlave {
+		go s.watchClockSkew()
+	}
+	if s.recoveryDone != nil {
+		go s.recoverFromBackup()
+	}
+	if s.hasBootstrapTasks() && !s.isLocalSlave {
+		go s.runBootstrapTasks()
+	}
+	if s.BackupSchedule != "" {
+		go s.runBackupSchedule()
+	}
+	if s.LogForward != "" && !s.usesDockerLogDriver() {
+		// When using docker, syslog & journald logs are forwarded by the log driver of the containers
+		go s.forwardServerLogs()
+	}
+
+	// Passive peers never run servers
+	if myPeer.IsPassive {
+		s.log.Info("Serving as passive peer, no servers are started")
+	}
+	// Standby peers wait until they are activated
+	if myPeer.IsStandby {
+		s.log.Info("Serving as standby, waiting to be activated...")
+	}
+	for myPeer.IsStandby && !s.stop {
+		select {
+		case <-s.activateWaiter.Done():
+			myPeer, _ = s.myPeers.PeerByID(s.ID)
+			s.log.Info("Standby has been activated")
+		case <-time.After(time.Second):
+		}
+	}
+
+	serverTypes := s.ownServerTypes(myPeer)
+	if len(serverTypes) > 0 && !s.stop {
+		if err := s.runHook(HookPreStart, serverTypes); err != nil {
+			s.log.Fatalf("The %s hook failed, servers are not started: %v", HookPreStart, err)
+		}
+	}
+	s.startupPhases.expect(serverTypes)
+	s.setBootstrapServers(serverTypes)
+	if s.isClusterMode() && len(serverTypes) > 0 {
+		go s.waitForAgencyLeader()
+	}
+
+	if s.isClusterMode() && myPeer.HasServers() {
+		// All servers are started at once, the dbserver & coordinator wait for the agency themselves.
+		// When databases must be upgraded, every server is upgraded & up again before the next one is started.
+		// The start can be further delayed by the configured start order.
+		upgraded := true
+
 		// Start agent:
 		if s.needsAgent() {
-			runAlways := true
-			go s.runArangod(runner, myPeer, ServerTypeAgent, &s.servers.agentProc, &runAlways)
-			time.Sleep(time.Second)
+			s.runAgent = true
+			s.requestStartupUpgrade(ServerTypeAgent)
+			go s.runArangod(runner, myPeer, ServerTypeAgent, &s.servers.agentProc, &s.runAgent)
+			upgraded = s.waitForStartupUpgrade(ServerTypeAgent)
 		}
 
 		// Start DBserver:
-		if s.StartDBserver {
+		if s.StartDBserver && upgraded && s.waitForStartOrder(ServerTypeDBServer) {
+			s.requestStartupUpgrade(ServerTypeDBServer)
 			go s.runArangod(runner, myPeer, ServerTypeDBServer, &s.servers.dbserverProc, &s.StartDBserver)
-			time.Sleep(time.Second)
+			upgraded = s.waitForStartupUpgrade(ServerTypeDBServer)
 		}
 
 		// Start Coordinator:
-		if s.StartCoordinator {
+		if s.StartCoordinator && upgraded && s.waitForStartOrder(ServerTypeCoordinator) {
+			s.requestStartupUpgrade(ServerTypeCoordinator)
 			go s.runArangod(runner, myPeer, ServerTypeCoordinator, &s.servers.coordinatorProc, &s.StartCoordinator)
+			upgraded = s.waitForStartupUpgrade(ServerTypeCoordinator)
+		}
+		if upgraded && !s.stop {
+			s.finishStartupUpgrade()
 		}
 	} else if s.isSingleMode() {
 		// Start Single server:
+		s.requestStartupUpgrade(ServerTypeSingle)
 		go s.runArangod(runner, myPeer, ServerTypeSingle, &s.servers.singleProc, nil)
+		if s.waitForStartupUpgrade(ServerTypeSingle) {
+			s.finishStartupUpgrade()
+		}
 	}
 
 	for {
@@ -705,7 +1223,15 @@ func (s *Service) startRunning(runner Runner) {
 		}
 	}
 
+	if myPeer, found := s.myPeers.PeerByID(s.ID); found {
+		if serverTypes := s.ownServerTypes(myPeer); len(serverTypes) > 0 {
+			if err := s.runHook(HookPreStop, serverTypes); err != nil {
+				s.log.Warningf("The %s hook failed: %v", HookPreStop, err)
+			}
+		}
+	}
 	s.log.Info("Shutting down services...")
+	s.notifySystemd("STOPPING=1", "STATUS=Shutting down servers")
 	if p := s.servers.singleProc; p != nil {
 		if err := p.Terminate(); err != nil {
 			s.log.Warningf("Failed to terminate single server: %v", err)
@@ -757,6 +1283,25 @@ func (s *Service) startRunning(runner Runner) {
 	}
 }
 
+// ownServerTypes returns the types of the servers started by this starter for the given (own) peer.
+func (s *Service) ownServerTypes(myPeer Peer) []ServerType {
+	var serverTypes []ServerType
+	if s.isClusterMode() && myPeer.HasServers() {
+		if s.needsAgent() {
+			serverTypes = append(serverTypes, ServerTypeAgent)
+		}
+		if s.StartDBserver {
+			serverTypes = append(serverTypes, ServerTypeDBServer)
+		}
+		if s.StartCoordinator {
+			serverTypes = append(serverTypes, ServerTypeCoordinator)
+		}
+	} else if s.isSingleMode() {
+		serverTypes = append(serverTypes, ServerTypeSingle)
+	}
+	return serverTypes
+}
+
 // Run runs the service in either master or slave mode.
 func (s *Service) Run(rootCtx context.Context) {
 	s.ctx, s.cancel = context.WithCancel(rootCtx)
@@ -767,8 +1312,76 @@ func (s *Service) Run(rootCtx context.Context) {
 		}
 	}()
 
+	s.startupPhases.begin()
+	s.startBootstrapTrace()
+	go s.tracer.run(s.ctx)
+	if !s.isLocalSlave {
+		s.systemd = newSystemdNotifier()
+		s.notifySystemd("STATUS=Starting")
+		go s.runSystemdWatchdog(s.ctx)
+	}
+
+	runner, useDockerRunner := s.createRunner()
+	if !useDockerRunner {
+		s.raiseProcessLimits()
+	}
+
+	// Collect digests of all inputs (if needed)
+	if s.StrictReproducibility {
+		digests, err := s.collectInputDigests(useDockerRunner)
+		if err != nil {
+			s.log.Fatalf("Failed to collect input digests: %v", err)
+		}
+		s.inputDigests = digests
+	}
+
+	// Identify the arangod executable (or docker image), to detect changes since the last run
+	if digest, err := s.serverBinaryDigest(useDockerRunner); err != nil {
+		s.log.Warningf("Cannot identify arangod executable, databases are not upgraded automatically when it changes: %v", err)
+	} else {
+		s.serverBinary = digest
+		s.recordedBinary = digest
+	}
+
+	// Is this a new start or a restart?
+	if !s.relaunch(runner) {
+		s.bootstrapping = true
+		// Find the master (if needed)
+		if s.MasterAddress == "" && s.Discovery != "" && s.isClusterMode() {
+			s.discoverMaster()
+		}
+		// Do we have to register?
+		if s.MasterAddress != "" {
+			s.state = stateSlave
+			s.startSlave(s.MasterAddress, runner)
+		} else {
+			s.state = stateMaster
+			s.startMaster(runner)
+		}
+	}
+
+	// Remove data (if requested)
+	s.mutex.Lock()
+	removeData := s.removeDataOnStop
+	s.mutex.Unlock()
+	if removeData {
+		s.removeData()
+	}
+
+	// Stop serving requests (needed for local slaves, which share the process)
+	s.stopHTTPServer()
+
+	// Export remaining spans
+	s.bootstrapSpan.finish(fmt.Errorf("Stopped before all servers were up"))
+	s.tracer.flush()
+}
+
+// createRunner completes the configuration of the service for its environment
+// and creates the runner used to start the servers.
+// Returns the runner and true if it is a docker runner.
+func (s *Service) createRunner() (Runner, bool) {
 	// Decide what type of process runner to use.
-	useDockerRunner := s.DockerEndpoint != "" && s.DockerImage != ""
+	useDockerRunner := s.customRunner == nil && s.DockerEndpoint != "" && s.DockerImage != ""
 
 	// Guess own IP address if not specified
 	if s.OwnAddress == "" && s.isSingleMode() && !useDockerRunner {
@@ -794,7 +1407,7 @@ func (s *Service) Run(rootCtx context.Context) {
 		hostPort, isNetHost, networkMode, err := findDockerExposedAddress(s.DockerEndpoint, s.DockerContainerName, s.MasterPort)
 		if err != nil {
 			s.log.Fatalf("Failed to detect port mapping: %#v", err)
-			return
+			return nil, false
 		}
 		if s.DockerNetworkMode == "" && networkMode != "" && networkMode != "default" {
 			s.log.Infof("Auto detected network mode: %s", networkMode)
@@ -809,9 +1422,12 @@ func (s *Service) Run(rootCtx context.Context) {
 
 	// Create a runner
 	var runner Runner
-	if useDockerRunner {
+	if s.customRunner != nil {
+		runner = s.customRunner
+		s.log.Debug("Using custom runner")
+	} else if useDockerRunner {
 		var err error
-		runner, err = NewDockerRunner(s.log, s.DockerEndpoint, s.DockerImage, s.DockerUser, s.DockerContainerName, s.DockerGCDelay, s.DockerNetworkMode, s.DockerPrivileged)
+		runner, err = NewDockerRunner(s.createLogger(LogComponentRunner, nil), s.DockerEndpoint, s.DockerImage, s.DockerUser, s.DockerContainerName, s.DockerGCDelay, s.DockerNetworkMode, s.BindAddress, s.DockerPrivileged, s.LogForward, s.Offline)
 		if err != nil {
 			s.log.Fatalf("Failed to create docker runner: %#v", err)
 		}
@@ -825,23 +1441,30 @@ func (s *Service) Run(rootCtx context.Context) {
 		if s.RunningInDocker {
 			s.log.Fatalf("When running in docker, you must provide a --docker.endpoint=<endpoint> and --docker.image=<image>")
 		}
-		runner = NewProcessRunner(s.log)
+		runner = NewProcessRunner(s.createLogger(LogComponentRunner, nil))
 		s.log.Debug("Using process runner")
 	}
+	return runner, useDockerRunner
+}
 
-	// Is this a new start or a restart?
-	if s.relaunch(runner) {
-		return
+// starterBindAddress returns the IP address the HTTP server of the starter listens on.
+// Inside a container with mapped ports, the address of the host cannot be used, so it
+// listens on all interfaces.
+func (s *Service) starterBindAddress() string {
+	if !s.isNetHost {
+		return ""
 	}
+	return s.BindAddress
+}
 
-	// Do we have to register?
-	if s.MasterAddress != "" {
-		s.state = stateSlave
-		s.startSlave(s.MasterAddress, runner)
-	} else {
-		s.state = stateMaster
-		s.startMaster(runner)
+// serverBindAddress returns the IP address the servers listen on.
+// Servers started in a container with mapped ports listen on all interfaces,
+// the ports are then only published on the bind address.
+func (s *Service) serverBindAddress() string {
+	if s.DockerImage != "" && s.DockerNetworkMode != "host" {
+		return ""
 	}
+	return s.BindAddress
 }
 
 // isClusterMode returns true when the service is running in cluster mode.
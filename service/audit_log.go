@@ -0,0 +1,217 @@
+//
+// DISCLAIMER
+//
+// Copyright 2017 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+// Author Ewout Prangsma
+//
+
+package service
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	auditLogFileName = "audit.log"
+)
+
+// AuditLogEntry holds a single mutating API call, as recorded in the audit log.
+type AuditLogEntry struct {
+	Time              time.Time `json:"time"`                         // Time the call was received
+	Operation         string    `json:"operation"`                    // Name of the operation (e.g. shutdown)
+	Method            string    `json:"method"`                       // HTTP method of the call
+	Path              string    `json:"path"`                         // Path (including query) of the call
+	RemoteAddress     string    `json:"remote-address"`               // Address the call was received from
+	ForwardedFor      string    `json:"forwarded-for,omitempty"`      // Content of the X-Forwarded-For header (if any)
+	UserAgent         string    `json:"user-agent,omitempty"`         // User agent of the caller (if any)
+	ClientCertificate string    `json:"client-certificate,omitempty"` // Common name of the TLS client certificate of the caller (if any)
+	Status            int       `json:"status"`                       // HTTP status of the response
+	Outcome           string    `json:"outcome"`                      // success | redirect | failure
+	Error             string    `json:"error,omitempty"`              // Error of a failed call
+	Duration          string    `json:"duration"`                     // Time it took to handle the call
+}
+
+// auditLog appends entries to the audit log file of the starter.
+type auditLog struct {
+	mutex sync.Mutex
+}
+
+// auditLogPath returns the path of the audit log file of this starter.
+func (s *Service) auditLogPath() string {
+	return filepath.Join(s.DataDir, auditLogFileName)
+}
+
+// append adds the given entry to the audit log file at given path.
+// The file is only ever appended to.
+func (a *auditLog) append(path string, entry AuditLogEntry) error {
+	encoded, err := json.Marshal(entry)
+	if err != nil {
+		return maskAny(err)
+	}
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return maskAny(err)
+	}
+	defer f.Close()
+	if _, err := f.Write(append(encoded, '\n')); err != nil {
+		return maskAny(err)
+	}
+	return maskAny(f.Close())
+}
+
+// read returns the last limit entries (all entries if limit <= 0) of the audit log file at given path.
+func (a *auditLog) read(path string, limit int) ([]AuditLogEntry, error) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, maskAny(err)
+	}
+	defer f.Close()
+	var result []AuditLogEntry
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var entry AuditLogEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, maskAny(err)
+		}
+		result = append(result, entry)
+		if limit > 0 && len(result) > limit {
+			result = result[1:]
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, maskAny(err)
+	}
+	return result, nil
+}
+
+// auditResponseWriter records the status & error of a response.
+type auditResponseWriter struct {
+	http.ResponseWriter
+	status int
+	body   []byte
+}
+
+// WriteHeader implements http.ResponseWriter.
+func (w *auditResponseWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// Write implements http.ResponseWriter.
+// The start of the body of a failed response is kept to find its error.
+func (w *auditResponseWriter) Write(data []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	if w.status >= 400 && len(w.body) < 1024 {
+		w.body = append(w.body, data...)
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+// audited wraps the given handler of a mutating API call, recording all calls that
+// can change the state of the starter (all methods other than GET & HEAD) in the audit log.
+func (s *Service) audited(operation string, handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "GET" || r.Method == "HEAD" {
+			handler(w, r)
+			return
+		}
+		start := time.Now()
+		aw := &auditResponseWriter{ResponseWriter: w}
+		handler(aw, r)
+		if aw.status == 0 {
+			aw.status = http.StatusOK
+		}
+		entry := AuditLogEntry{
+			Time:          start,
+			Operation:     operation,
+			Method:        r.Method,
+			Path:          r.URL.RequestURI(),
+			RemoteAddress: r.RemoteAddr,
+			ForwardedFor:  r.Header.Get("X-Forwarded-For"),
+			UserAgent:     r.UserAgent(),
+			Status:        aw.status,
+			Outcome:       "success",
+			Duration:      time.Since(start).String(),
+		}
+		if r.TLS != nil && len(r.TLS.PeerCertificates) > 0 {
+			entry.ClientCertificate = r.TLS.PeerCertificates[0].Subject.CommonName
+		}
+		if aw.status >= 300 && aw.status < 400 {
+			entry.Outcome = "redirect"
+		} else if aw.status >= 400 {
+			entry.Outcome = "failure"
+			var errResp ErrorResponse
+			if err := json.Unmarshal(aw.body, &errResp); err == nil && errResp.Error != "" {
+				entry.Error = errResp.Error
+			} else {
+				entry.Error = http.StatusText(aw.status)
+			}
+		}
+		if err := s.audit.append(s.auditLogPath(), entry); err != nil {
+			s.apiLog.Errorf("Failed to write audit log entry for %s: %v", operation, err)
+		}
+	}
+}
+
+// auditLogHandler returns the entries of the audit log as a JSON array.
+// The `limit` query can be used to return only the most recent entries.
+func (s *Service) auditLogHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		writeError(w, http.StatusMethodNotAllowed, "GET required")
+		return
+	}
+	limit := 0
+	if v := r.FormValue("limit"); v != "" {
+		var err error
+		if limit, err = strconv.Atoi(v); err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid limit")
+			return
+		}
+	}
+	entries, err := s.audit.read(s.auditLogPath(), limit)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if entries == nil {
+		entries = []AuditLogEntry{}
+	}
+	b, err := json.Marshal(entries)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+	} else {
+		w.Write(b)
+	}
+}
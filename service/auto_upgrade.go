@@ -0,0 +1,105 @@
+//
+// DISCLAIMER
+//
+// Copyright 2017 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+// Author Ewout Prangsma
+//
+
+package service
+
+import (
+	"time"
+)
+
+// serverBinaryDigest returns the digest of the arangod executable, or the ID of the docker image,
+// used to run the servers.
+func (s *Service) serverBinaryDigest(useDockerRunner bool) (string, error) {
+	if useDockerRunner {
+		id, err := findDockerImageID(s.DockerEndpoint, s.DockerImage, s.Offline)
+		if err != nil {
+			return "", maskAny(err)
+		}
+		return id, nil
+	}
+	digest, err := fileDigest(s.ArangodPath)
+	if err != nil {
+		return "", maskAny(err)
+	}
+	return digest, nil
+}
+
+// checkRecordedServerBinary compares the arangod executable (or docker image) of this run with the one
+// recorded in the setup file. When it has changed, the databases of all servers are upgraded on start.
+func (s *Service) checkRecordedServerBinary(recorded string) {
+	if recorded == "" || s.serverBinary == "" {
+		// Nothing recorded before, or cannot tell
+		return
+	}
+	s.recordedBinary = recorded
+	if recorded != s.serverBinary {
+		s.log.Infof("The arangod executable (or docker image) has changed since the last run, the database of every server is upgraded before it is started")
+		s.upgradeOnStart = true
+	}
+}
+
+// requestStartupUpgrade marks the server of given type to be started once with `--database.auto-upgrade=true`,
+// if the arangod executable (or docker image) has changed since the last run.
+func (s *Service) requestStartupUpgrade(serverType ServerType) {
+	if s.upgradeOnStart {
+		s.serverStates.requestAutoUpgrade(serverType)
+	}
+}
+
+// waitForStartupUpgrade waits until the server of given type is up again after the upgrade of its database
+// on start (if any).
+// Returns false when the upgrade has failed (the starter is then stopped) or the starter is stopping.
+func (s *Service) waitForStartupUpgrade(serverType ServerType) bool {
+	if !s.upgradeOnStart {
+		return true
+	}
+	for !s.stop {
+		state := s.serverStates.get(serverType)
+		if state.UpgradeErr != "" {
+			s.log.Errorf("Upgrading the database of %s has failed: %s. Fix the problem (see its log) and restart the starter to try again.", serverType, state.UpgradeErr)
+			s.showRecentLogs(serverType)
+			s.stop = true
+			return false
+		}
+		if state.Up {
+			s.log.Infof("Database of %s has been upgraded to version %s", serverType, state.Version)
+			return true
+		}
+		time.Sleep(time.Second)
+	}
+	return false
+}
+
+// finishStartupUpgrade records the arangod executable (or docker image) of this run in the setup file,
+// once the databases of all servers have been upgraded.
+func (s *Service) finishStartupUpgrade() {
+	if !s.upgradeOnStart {
+		return
+	}
+	s.upgradeOnStart = false
+	s.recordedBinary = s.serverBinary
+	if err := s.saveSetup(); err != nil {
+		s.log.Errorf("Failed to save setup after upgrading databases: %v", err)
+		return
+	}
+	s.log.Info("Databases of all servers have been upgraded")
+}
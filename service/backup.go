@@ -0,0 +1,298 @@
+//
+// DISCLAIMER
+//
+// Copyright 2017 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+// Author Ewout Prangsma
+//
+
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	backupDirPrefix    = "backup-"
+	backupInfoFileName = "backup.json"
+	backupListTimeout  = time.Second * 30
+)
+
+// BackupInfo describes a single logical backup created by the backup scheduler.
+// It is stored as `backup.json` in the directory of the backup.
+type BackupInfo struct {
+	Name      string    `json:"name"`                // Name of the directory of the backup
+	Started   time.Time `json:"started"`             // Time the backup was started
+	Finished  time.Time `json:"finished"`            // Time the backup has finished
+	Databases []string  `json:"databases,omitempty"` // Names of the databases in the backup
+	Success   bool      `json:"success"`             // If set, all databases have been dumped
+	Error     string    `json:"error,omitempty"`     // Reason of the failure (if any)
+}
+
+// BackupStatus is the JSON response of a `/backup` request.
+type BackupStatus struct {
+	Schedule  string       `json:"schedule,omitempty"`  // Schedule of the backups (cron expression)
+	Directory string       `json:"directory,omitempty"` // Directory holding all backups
+	Keep      int          `json:"keep,omitempty"`      // Number of backups that are kept
+	Running   bool         `json:"running,omitempty"`   // If set, a backup is being created
+	Next      *time.Time   `json:"next,omitempty"`      // Time of the next backup
+	Last      *BackupInfo  `json:"last,omitempty"`      // Last backup created by this starter (if any)
+	Backups   []BackupInfo `json:"backups,omitempty"`   // All backups in the backup directory, oldest first
+}
+
+// backupScheduler holds the state of the scheduled backups of this starter.
+type backupScheduler struct {
+	mutex   sync.Mutex
+	running bool
+	next    time.Time
+	last    *BackupInfo
+}
+
+// backupDir returns the directory holding all backups.
+func (s *Service) backupDir() string {
+	if s.BackupDir != "" {
+		return s.BackupDir
+	}
+	return filepath.Join(s.DataDir, "backups")
+}
+
+// runBackupSchedule creates a logical backup of all databases each time the backup schedule matches,
+// until the service is stopped.
+func (s *Service) runBackupSchedule() {
+	schedule, err := ParseCronSchedule(s.BackupSchedule)
+	if err != nil {
+		s.log.Errorf("Backups are disabled: %v", err)
+		return
+	}
+	s.log.Infof("Creating backups in %s using schedule '%s'", s.backupDir(), s.BackupSchedule)
+	for !s.stop {
+		next := schedule.Next(time.Now())
+		if next.IsZero() {
+			s.log.Errorf("Backup schedule '%s' never matches, backups are disabled", s.BackupSchedule)
+			return
+		}
+		s.backups.mutex.Lock()
+		s.backups.next = next
+		s.backups.mutex.Unlock()
+		for !s.stop && time.Now().Before(next) {
+			time.Sleep(time.Second)
+		}
+		if s.stop {
+			return
+		}
+		s.createBackup()
+	}
+}
+
+// createBackup runs arangodump for every database against the coordinator (or single server)
+// of this starter, records the result and removes the oldest backups beyond the retention limit.
+func (s *Service) createBackup() {
+	started := time.Now()
+	info := BackupInfo{
+		Name:    backupDirPrefix + started.Format("20060102-150405"),
+		Started: started,
+	}
+	s.backups.mutex.Lock()
+	s.backups.running = true
+	s.backups.mutex.Unlock()
+
+	s.log.Infof("Creating backup %s", info.Name)
+	err := s.dumpDatabases(&info)
+	info.Finished = time.Now()
+	if err != nil {
+		info.Error = err.Error()
+		s.log.Errorf("Backup %s has failed: %v", info.Name, err)
+	} else {
+		info.Success = true
+		s.log.Info(newLogEvent("backup", LogFields{"name": info.Name, "databases": len(info.Databases)},
+			"Backup %s of %d databases has finished in %s", info.Name, len(info.Databases), info.Finished.Sub(started)))
+	}
+	if encoded, err := json.MarshalIndent(info, "", "  "); err == nil {
+		dir := filepath.Join(s.backupDir(), info.Name)
+		if err := ioutil.WriteFile(filepath.Join(dir, backupInfoFileName), encoded, 0644); err != nil {
+			s.log.Warningf("Failed to write %s: %v", backupInfoFileName, err)
+		}
+	}
+
+	s.backups.mutex.Lock()
+	s.backups.running = false
+	s.backups.last = &info
+	s.backups.mutex.Unlock()
+
+	if info.Success {
+		s.removeOldBackups()
+	}
+}
+
+// dumpDatabases dumps all databases into the directory of the given backup.
+func (s *Service) dumpDatabases(info *BackupInfo) error {
+	serverType := ServerType(ServerTypeCoordinator)
+	if s.isSingleMode() {
+		serverType = ServerTypeSingle
+	}
+	if s.serverProcess(serverType) == nil || !s.serverStates.get(serverType).Up {
+		return maskAny(fmt.Errorf("No %s is up", serverType))
+	}
+	dir := filepath.Join(s.backupDir(), info.Name)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return maskAny(err)
+	}
+	databases, err := s.listDatabases(serverType)
+	if err != nil {
+		return maskAny(err)
+	}
+	endpoint, err := s.clientToolEndpoint(serverType)
+	if err != nil {
+		return maskAny(err)
+	}
+	credentials, removeCredentials, err := s.clientToolCredentials("arangodump", dir)
+	if err != nil {
+		return maskAny(err)
+	}
+	defer removeCredentials()
+	for _, db := range databases {
+		args := append(credentials,
+			"--server.endpoint", endpoint,
+			"--server.database", db,
+			"--server.username", "root",
+			"--output-directory", filepath.Join(dir, db),
+			"--overwrite", "true",
+		)
+		if err := s.runClientTool("arangodump", args, dir); err != nil {
+			return maskAny(fmt.Errorf("Failed to dump database '%s': %v", db, err))
+		}
+		info.Databases = append(info.Databases, db)
+	}
+	return nil
+}
+
+// listDatabases returns the names of all databases, as known by the server of given type.
+func (s *Service) listDatabases(serverType ServerType) ([]string, error) {
+	ctx, cancel := context.WithTimeout(s.ctx, backupListTimeout)
+	defer cancel()
+	content, err := s.serverRequest(ctx, serverType, "GET", "/_api/database", nil)
+	if err != nil {
+		return nil, maskAny(err)
+	}
+	var resp struct {
+		Result []string `json:"result"`
+	}
+	if err := json.Unmarshal(content, &resp); err != nil {
+		return nil, maskAny(err)
+	}
+	return resp.Result, nil
+}
+
+// listBackups returns all backups in the backup directory, oldest first.
+func (s *Service) listBackups() ([]BackupInfo, error) {
+	entries, err := ioutil.ReadDir(s.backupDir())
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, maskAny(err)
+	}
+	var result []BackupInfo
+	for _, entry := range entries {
+		if !entry.IsDir() || !strings.HasPrefix(entry.Name(), backupDirPrefix) {
+			continue
+		}
+		info := BackupInfo{Name: entry.Name()}
+		if content, err := ioutil.ReadFile(filepath.Join(s.backupDir(), entry.Name(), backupInfoFileName)); err == nil {
+			json.Unmarshal(content, &info)
+		}
+		result = append(result, info)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result, nil
+}
+
+// removeOldBackups removes the oldest successful backups, so at most BackupKeep successful backups remain.
+// Failed backups are removed once they are older than the oldest backup that is kept.
+func (s *Service) removeOldBackups() {
+	if s.BackupKeep <= 0 {
+		return
+	}
+	backups, err := s.listBackups()
+	if err != nil {
+		s.log.Warningf("Cannot list backups: %v", err)
+		return
+	}
+	kept := 0
+	for i := len(backups) - 1; i >= 0; i-- {
+		b := backups[i]
+		if b.Success && kept < s.BackupKeep {
+			kept++
+			continue
+		}
+		if !b.Success && kept < s.BackupKeep {
+			// Keep failed backups newer than the oldest kept backup, for analysis
+			continue
+		}
+		s.log.Infof("Removing old backup %s", b.Name)
+		if err := os.RemoveAll(filepath.Join(s.backupDir(), b.Name)); err != nil {
+			s.log.Warningf("Failed to remove backup %s: %v", b.Name, err)
+		}
+	}
+}
+
+// backupHandler returns the status of the scheduled backups of this starter.
+func (s *Service) backupHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		writeError(w, http.StatusMethodNotAllowed, "GET required")
+		return
+	}
+	if s.BackupSchedule == "" {
+		writeError(w, http.StatusNotFound, "Backups are not enabled")
+		return
+	}
+	s.backups.mutex.Lock()
+	status := BackupStatus{
+		Schedule:  s.BackupSchedule,
+		Directory: s.backupDir(),
+		Keep:      s.BackupKeep,
+		Running:   s.backups.running,
+		Last:      s.backups.last,
+	}
+	if !s.backups.next.IsZero() {
+		next := s.backups.next
+		status.Next = &next
+	}
+	s.backups.mutex.Unlock()
+	backups, err := s.listBackups()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	status.Backups = backups
+
+	b, err := json.Marshal(status)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+	} else {
+		w.Write(b)
+	}
+}
@@ -0,0 +1,128 @@
+//
+// DISCLAIMER
+//
+// Copyright 2017 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+// Author Ewout Prangsma
+//
+
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	foxxInstallTimeout = time.Minute * 5
+)
+
+// FoxxService is a Foxx service installed once a new deployment is up (see `--bootstrap.foxx`).
+type FoxxService struct {
+	Source string // Path of the zip file (or single JavaScript file) of the service
+	Mount  string // Mount point of the service in the _system database
+}
+
+// ParseFoxxService parses a Foxx service given as `<path>:<mountpoint>`.
+func ParseFoxxService(spec string) (FoxxService, error) {
+	// The mount point starts with a '/', so a path containing a drive letter (C:\...) is not split
+	idx := strings.LastIndex(spec, ":/")
+	if idx <= 0 {
+		return FoxxService{}, maskAny(fmt.Errorf("Invalid Foxx service '%s', expected <path>:<mountpoint>", spec))
+	}
+	return FoxxService{Source: spec[:idx], Mount: spec[idx+1:]}, nil
+}
+
+// contentType returns the content type of the source of the service.
+func (f FoxxService) contentType() string {
+	if strings.EqualFold(filepath.Ext(f.Source), ".js") {
+		return "application/javascript"
+	}
+	return "application/zip"
+}
+
+// ValidateFoxxService checks that the source of the given Foxx service can be installed.
+func ValidateFoxxService(f FoxxService) error {
+	info, err := os.Stat(f.Source)
+	if err != nil {
+		return maskAny(err)
+	}
+	if info.IsDir() {
+		return maskAny(fmt.Errorf("%s is a directory, expected a zip file", f.Source))
+	}
+	return nil
+}
+
+// installBootstrapFoxxServices installs the services given by BootstrapFoxx on a coordinator
+// (or the single server). Mount points that already have a service installed are left unchanged.
+func (s *Service) installBootstrapFoxxServices() error {
+	if len(s.BootstrapFoxx) == 0 {
+		return nil
+	}
+	mounts, err := s.foxxMounts()
+	if err != nil {
+		return maskAny(err)
+	}
+	for _, f := range s.BootstrapFoxx {
+		if mounts[f.Mount] {
+			s.log.Debugf("A Foxx service is already installed at %s", f.Mount)
+			continue
+		}
+		s.log.Infof("Installing Foxx service %s at %s", f.Source, f.Mount)
+		content, err := ioutil.ReadFile(f.Source)
+		if err != nil {
+			return maskAny(fmt.Errorf("Cannot read Foxx service %s: %v", f.Source, err))
+		}
+		ctx, cancel := context.WithTimeout(s.ctx, foxxInstallTimeout)
+		_, err = s.clusterRequestWithContentType(ctx, s.bootstrapServerType(), "POST", "/_api/foxx?mount="+url.QueryEscape(f.Mount), f.contentType(), content)
+		cancel()
+		if err != nil {
+			return maskAny(fmt.Errorf("Installing Foxx service %s at %s has failed: %v", f.Source, f.Mount, err))
+		}
+		s.log.Info(newLogEvent("bootstrap-foxx", LogFields{"source": f.Source, "mount": f.Mount},
+			"Foxx service %s has been installed at %s", f.Source, f.Mount))
+	}
+	return nil
+}
+
+// foxxMounts returns the mount points of all (non-system) Foxx services installed in the _system database.
+func (s *Service) foxxMounts() (map[string]bool, error) {
+	ctx, cancel := context.WithTimeout(s.ctx, manifestRequestTimeout)
+	defer cancel()
+	content, err := s.clusterRequest(ctx, s.bootstrapServerType(), "GET", "/_api/foxx?excludeSystem=true", nil)
+	if err != nil {
+		return nil, maskAny(err)
+	}
+	var services []struct {
+		Mount string `json:"mount"`
+	}
+	if err := json.Unmarshal(content, &services); err != nil {
+		return nil, maskAny(err)
+	}
+	result := make(map[string]bool)
+	for _, svc := range services {
+		result[svc.Mount] = true
+	}
+	return result, nil
+}
@@ -0,0 +1,300 @@
+//
+// DISCLAIMER
+//
+// Copyright 2017 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+// Author Ewout Prangsma
+//
+
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	importSpecFileName           = "import.json" // Name of the (optional) file in the import directory that specifies the import
+	defaultImportParallelism     = 2
+	importCollectionTypeEdge     = "edge"
+	importCollectionTypeDocument = "document"
+)
+
+var (
+	// importFileTypes maps the extension of an importable file to its arangoimport type.
+	importFileTypes = map[string]string{
+		".jsonl": "jsonl",
+		".json":  "json",
+		".csv":   "csv",
+		".tsv":   "tsv",
+	}
+)
+
+// ImportSpec specifies which files of the import directory (see `--bootstrap.import-dir`) are imported
+// into which collections. It is read from `import.json` in the import directory.
+type ImportSpec struct {
+	Files []ImportFile `json:"files"`
+}
+
+// ImportFile specifies the import of a single file of the import directory.
+type ImportFile struct {
+	File           string `json:"file"`                      // Path of the file, relative to the import directory
+	Database       string `json:"database,omitempty"`        // Database to import into (default _system)
+	Collection     string `json:"collection,omitempty"`      // Collection to import into (default is the name of the file without extension)
+	CollectionType string `json:"collection-type,omitempty"` // Type of the collection when it is created: document (default) or edge
+}
+
+// ImportProgress holds the progress of the initial data import.
+type ImportProgress struct {
+	Total    int      `json:"total"`             // Number of files to import
+	Imported int      `json:"imported"`          // Number of files that have been imported
+	Running  []string `json:"running,omitempty"` // Files that are being imported
+}
+
+// importProgress tracks the progress of the initial data import.
+type importProgress struct {
+	mutex    sync.Mutex
+	progress *ImportProgress
+}
+
+// start marks the given file as being imported.
+func (p *importProgress) start(file string) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.progress.Running = append(p.progress.Running, file)
+}
+
+// finish marks the given file as no longer being imported. Returns the number of imported files.
+func (p *importProgress) finish(file string, imported bool) int {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	for i, f := range p.progress.Running {
+		if f == file {
+			p.progress.Running = append(p.progress.Running[:i], p.progress.Running[i+1:]...)
+			break
+		}
+	}
+	if imported {
+		p.progress.Imported++
+	}
+	return p.progress.Imported
+}
+
+// get returns a copy of the progress, or nil when no import has been started.
+func (p *importProgress) get() *ImportProgress {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.progress == nil {
+		return nil
+	}
+	result := *p.progress
+	result.Running = append([]string(nil), p.progress.Running...)
+	return &result
+}
+
+// ReadImportSpec reads the import specification of the given import directory.
+// Without `import.json`, every importable file in the directory is imported into the collection
+// of the _system database named after the file.
+func ReadImportSpec(dir string) (ImportSpec, error) {
+	var spec ImportSpec
+	if content, err := ioutil.ReadFile(filepath.Join(dir, importSpecFileName)); err == nil {
+		if err := json.Unmarshal(content, &spec); err != nil {
+			return ImportSpec{}, maskAny(fmt.Errorf("Cannot parse %s: %v", importSpecFileName, err))
+		}
+	} else if os.IsNotExist(err) {
+		entries, err := ioutil.ReadDir(dir)
+		if err != nil {
+			return ImportSpec{}, maskAny(err)
+		}
+		for _, e := range entries {
+			if _, found := importFileTypes[strings.ToLower(filepath.Ext(e.Name()))]; found && !e.IsDir() && e.Name() != importSpecFileName {
+				spec.Files = append(spec.Files, ImportFile{File: e.Name()})
+			}
+		}
+	} else {
+		return ImportSpec{}, maskAny(err)
+	}
+	if len(spec.Files) == 0 {
+		return ImportSpec{}, maskAny(fmt.Errorf("No files to import in %s", dir))
+	}
+	for i, f := range spec.Files {
+		if f.File == "" {
+			return ImportSpec{}, maskAny(fmt.Errorf("File without name in %s", importSpecFileName))
+		}
+		if _, found := importFileTypes[strings.ToLower(filepath.Ext(f.File))]; !found {
+			return ImportSpec{}, maskAny(fmt.Errorf("Cannot import %s, expected a .jsonl, .json, .csv or .tsv file", f.File))
+		}
+		if f.CollectionType != "" && f.CollectionType != importCollectionTypeDocument && f.CollectionType != importCollectionTypeEdge {
+			return ImportSpec{}, maskAny(fmt.Errorf("Invalid collection-type '%s' of %s, expected document or edge", f.CollectionType, f.File))
+		}
+		if _, err := os.Stat(filepath.Join(dir, f.File)); err != nil {
+			return ImportSpec{}, maskAny(err)
+		}
+		if f.Database == "" {
+			spec.Files[i].Database = "_system"
+		}
+		if f.Collection == "" {
+			spec.Files[i].Collection = strings.TrimSuffix(filepath.Base(f.File), filepath.Ext(f.File))
+		}
+	}
+	return spec, nil
+}
+
+// importBootstrapData imports the files of the directory given by BootstrapImportDir using arangoimport,
+// running up to BootstrapImportJobs imports at the same time.
+// Every file is only imported once per deployment: the digests of files that have been imported
+// successfully are recorded in setup.json.
+func (s *Service) importBootstrapData() error {
+	if s.BootstrapImportDir == "" {
+		return nil
+	}
+	spec, err := ReadImportSpec(s.BootstrapImportDir)
+	if err != nil {
+		return maskAny(err)
+	}
+	endpoint, err := s.clientToolEndpoint(s.bootstrapServerType())
+	if err != nil {
+		return maskAny(err)
+	}
+	parallelism := s.BootstrapImportJobs
+	if parallelism <= 0 {
+		parallelism = defaultImportParallelism
+	}
+
+	// Collect the files that have not been imported before
+	var files []ImportFile
+	digests := make(map[string]string)
+	for _, f := range spec.Files {
+		digest, err := fileDigest(filepath.Join(s.BootstrapImportDir, f.File))
+		if err != nil {
+			return maskAny(err)
+		}
+		key := f.Database + "/" + f.Collection + "/" + digest
+		if s.isImportedFile(key) {
+			s.log.Debugf("%s has been imported into %s/%s before", f.File, f.Database, f.Collection)
+			continue
+		}
+		digests[f.File] = key
+		files = append(files, f)
+	}
+	s.imports.mutex.Lock()
+	s.imports.progress = &ImportProgress{Total: len(files)}
+	s.imports.mutex.Unlock()
+	if len(files) == 0 {
+		return nil
+	}
+
+	started := time.Now()
+	s.log.Infof("Importing %d files from %s (%d at a time)", len(files), s.BootstrapImportDir, parallelism)
+	queue := make(chan ImportFile, len(files))
+	for _, f := range files {
+		queue <- f
+	}
+	close(queue)
+	var (
+		wg       sync.WaitGroup
+		mutex    sync.Mutex
+		firstErr error
+	)
+	for i := 0; i < parallelism; i++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			for f := range queue {
+				if s.stop {
+					return
+				}
+				s.imports.start(f.File)
+				err := s.importFile(f, endpoint, worker)
+				imported := s.imports.finish(f.File, err == nil)
+				mutex.Lock()
+				if err != nil {
+					if firstErr == nil {
+						firstErr = err
+					}
+					mutex.Unlock()
+					continue
+				}
+				s.importedFiles = append(s.importedFiles, digests[f.File])
+				if err := s.saveSetup(); err != nil {
+					s.log.Errorf("Failed to record imported file in %s: %v", setupFileName, err)
+				}
+				mutex.Unlock()
+				s.log.Infof("Imported %s into %s/%s (%d/%d files)", f.File, f.Database, f.Collection, imported, len(files))
+			}
+		}(i)
+	}
+	wg.Wait()
+	if firstErr != nil {
+		return maskAny(firstErr)
+	}
+	s.log.Info(newLogEvent("bootstrap-import", LogFields{"dir": s.BootstrapImportDir, "files": len(files)},
+		"Imported %d files from %s in %s", len(files), s.BootstrapImportDir, time.Since(started)))
+	return nil
+}
+
+// importFile imports a single file using arangoimport, with the output captured in a directory per worker.
+func (s *Service) importFile(f ImportFile, endpoint string, worker int) error {
+	outputDir := filepath.Join(s.DataDir, bootstrapDirName, "import-"+strconv.Itoa(worker))
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		return maskAny(err)
+	}
+	collectionType := f.CollectionType
+	if collectionType == "" {
+		collectionType = importCollectionTypeDocument
+	}
+	s.log.Infof("Importing %s into %s/%s", f.File, f.Database, f.Collection)
+	credentials, removeCredentials, err := s.clientToolCredentials("arangoimport", outputDir)
+	if err != nil {
+		return maskAny(err)
+	}
+	defer removeCredentials()
+	args := append(credentials,
+		"--server.endpoint", endpoint,
+		"--server.username", "root",
+		"--server.database", f.Database,
+		"--create-database", "true",
+		"--collection", f.Collection,
+		"--create-collection", "true",
+		"--create-collection-type", collectionType,
+		"--type", importFileTypes[strings.ToLower(filepath.Ext(f.File))],
+		"--file", filepath.Join(s.BootstrapImportDir, f.File),
+	)
+	err = s.runClientTool("arangoimport", args, outputDir, s.BootstrapImportDir)
+	s.logBootstrapOutput(f.File, outputDir)
+	if err != nil {
+		return maskAny(fmt.Errorf("Importing %s into %s/%s has failed: %v", f.File, f.Database, f.Collection, err))
+	}
+	return nil
+}
+
+// isImportedFile returns true if a file with given key (database, collection & digest) has been imported before.
+func (s *Service) isImportedFile(key string) bool {
+	for _, k := range s.importedFiles {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
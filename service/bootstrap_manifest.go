@@ -0,0 +1,316 @@
+//
+// DISCLAIMER
+//
+// Copyright 2017 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+// Author Ewout Prangsma
+//
+
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"time"
+)
+
+const (
+	manifestRequestTimeout = time.Minute
+)
+
+// BootstrapManifest describes the databases, collections, users and permissions that are created
+// in a new deployment once it is up (see `--bootstrap.manifest`).
+// The manifest is written in JSON.
+type BootstrapManifest struct {
+	Databases []ManifestDatabase `json:"databases,omitempty"`
+	Users     []ManifestUser     `json:"users,omitempty"`
+}
+
+// ManifestDatabase describes a database of a bootstrap manifest, with the collections created in it.
+type ManifestDatabase struct {
+	Name        string               `json:"name"`
+	Collections []ManifestCollection `json:"collections,omitempty"`
+}
+
+// ManifestCollection describes a collection of a bootstrap manifest.
+type ManifestCollection struct {
+	Name              string `json:"name"`
+	Type              string `json:"type,omitempty"`               // document (default) or edge
+	NumberOfShards    int    `json:"number-of-shards,omitempty"`   // Only used in a cluster (0 uses the server default)
+	ReplicationFactor int    `json:"replication-factor,omitempty"` // Only used in a cluster (0 uses the server default)
+}
+
+// ManifestUser describes a user of a bootstrap manifest, with the permissions granted to it.
+type ManifestUser struct {
+	Name         string            `json:"name"`
+	Password     string            `json:"password,omitempty"`
+	PasswordFile string            `json:"password-file,omitempty"` // Relative paths are relative to the directory of the manifest
+	Grants       map[string]string `json:"grants,omitempty"`        // Permission (rw, ro or none) by database (or <database>/<collection>)
+}
+
+// ReadBootstrapManifest reads and validates the bootstrap manifest at given path.
+// Returns the manifest and the digest of its content.
+func ReadBootstrapManifest(path string) (BootstrapManifest, string, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return BootstrapManifest{}, "", maskAny(err)
+	}
+	var m BootstrapManifest
+	if err := json.Unmarshal(content, &m); err != nil {
+		return BootstrapManifest{}, "", maskAny(fmt.Errorf("Cannot parse %s (the manifest must be written in JSON): %v", path, err))
+	}
+	if err := checkManifestFields(content, reflect.TypeOf(m), ""); err != nil {
+		return BootstrapManifest{}, "", maskAny(fmt.Errorf("Cannot parse %s: %v", path, err))
+	}
+	databases := map[string]bool{"_system": true}
+	for _, db := range m.Databases {
+		if db.Name == "" {
+			return BootstrapManifest{}, "", maskAny(fmt.Errorf("Database without name"))
+		}
+		databases[db.Name] = true
+		for _, c := range db.Collections {
+			if c.Name == "" {
+				return BootstrapManifest{}, "", maskAny(fmt.Errorf("Collection without name in database '%s'", db.Name))
+			}
+			if c.Type != "" && c.Type != "document" && c.Type != "edge" {
+				return BootstrapManifest{}, "", maskAny(fmt.Errorf("Invalid type '%s' of collection '%s', expected document or edge", c.Type, c.Name))
+			}
+		}
+	}
+	for i, u := range m.Users {
+		if u.Name == "" {
+			return BootstrapManifest{}, "", maskAny(fmt.Errorf("User without name"))
+		}
+		if u.Password != "" && u.PasswordFile != "" {
+			return BootstrapManifest{}, "", maskAny(fmt.Errorf("User '%s' has both a password and a password-file", u.Name))
+		}
+		if u.PasswordFile != "" {
+			passwordPath := u.PasswordFile
+			if !filepath.IsAbs(passwordPath) {
+				passwordPath = filepath.Join(filepath.Dir(path), passwordPath)
+			}
+			password, err := ioutil.ReadFile(passwordPath)
+			if err != nil {
+				return BootstrapManifest{}, "", maskAny(fmt.Errorf("Cannot read password of user '%s': %v", u.Name, err))
+			}
+			m.Users[i].Password = strings.TrimRight(string(password), "\r\n")
+		}
+		for target, permission := range u.Grants {
+			switch permission {
+			case "rw", "ro", "none":
+			default:
+				return BootstrapManifest{}, "", maskAny(fmt.Errorf("Invalid permission '%s' of user '%s' on '%s', expected rw, ro or none", permission, u.Name, target))
+			}
+			db := strings.SplitN(target, "/", 2)[0]
+			if db != "*" && !databases[db] {
+				return BootstrapManifest{}, "", maskAny(fmt.Errorf("User '%s' is granted access to unknown database '%s'", u.Name, db))
+			}
+		}
+	}
+	return m, stringDigest(string(content)), nil
+}
+
+// checkManifestFields returns an error when the given JSON value contains an object field
+// that is not a field of (the structs in) the given type, so typos in a manifest are not silently ignored.
+func checkManifestFields(content json.RawMessage, t reflect.Type, path string) error {
+	switch t.Kind() {
+	case reflect.Struct:
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(content, &fields); err != nil {
+			return maskAny(err)
+		}
+		known := make(map[string]reflect.Type)
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			name := strings.Split(f.Tag.Get("json"), ",")[0]
+			known[name] = f.Type
+		}
+		for name, value := range fields {
+			fieldType, found := known[name]
+			if !found {
+				return maskAny(fmt.Errorf("Unknown field '%s%s'", path, name))
+			}
+			if err := checkManifestFields(value, fieldType, path+name+"."); err != nil {
+				return maskAny(err)
+			}
+		}
+	case reflect.Slice:
+		var items []json.RawMessage
+		if err := json.Unmarshal(content, &items); err != nil {
+			return maskAny(err)
+		}
+		for i, item := range items {
+			if err := checkManifestFields(item, t.Elem(), fmt.Sprintf("%s%d.", path, i)); err != nil {
+				return maskAny(err)
+			}
+		}
+	}
+	return nil
+}
+
+// applyBootstrapManifest applies the manifest given by BootstrapManifest (if any) to the deployment.
+// The manifest is only applied once per deployment: the digest of the applied manifest is recorded in setup.json.
+func (s *Service) applyBootstrapManifest() error {
+	if s.BootstrapManifest == "" {
+		return nil
+	}
+	m, digest, err := ReadBootstrapManifest(s.BootstrapManifest)
+	if err != nil {
+		return maskAny(err)
+	}
+	if s.appliedManifest != "" {
+		if s.appliedManifest != digest {
+			s.log.Warningf("Bootstrap manifest %s has changed since it has been applied, changes are ignored", s.BootstrapManifest)
+		}
+		return nil
+	}
+
+	s.log.Infof("Applying bootstrap manifest %s", s.BootstrapManifest)
+	if err := s.applyManifest(m); err != nil {
+		return maskAny(err)
+	}
+	s.appliedManifest = digest
+	if err := s.saveSetup(); err != nil {
+		s.log.Errorf("Failed to record bootstrap manifest in %s: %v", setupFileName, err)
+	}
+	s.log.Info(newLogEvent("bootstrap-manifest", LogFields{"manifest": s.BootstrapManifest},
+		"Bootstrap manifest %s has been applied", s.BootstrapManifest))
+	return nil
+}
+
+// applyManifest creates all databases, collections and users of the given manifest that do not exist yet
+// and grants the permissions of the users. Existing databases, collections and users are left unchanged.
+func (s *Service) applyManifest(m BootstrapManifest) error {
+	databases, err := s.manifestNames("/_api/database", "")
+	if err != nil {
+		return maskAny(err)
+	}
+	for _, db := range m.Databases {
+		if !databases[db.Name] {
+			s.log.Infof("Creating database '%s'", db.Name)
+			if err := s.manifestRequest("POST", "/_api/database", map[string]interface{}{"name": db.Name}); err != nil {
+				return maskAny(fmt.Errorf("Cannot create database '%s': %v", db.Name, err))
+			}
+		}
+		dbPath := "/_db/" + url.PathEscape(db.Name)
+		collections, err := s.manifestNames(dbPath+"/_api/collection?excludeSystem=true", "name")
+		if err != nil {
+			return maskAny(err)
+		}
+		for _, c := range db.Collections {
+			if collections[c.Name] {
+				continue
+			}
+			s.log.Infof("Creating collection '%s' in database '%s'", c.Name, db.Name)
+			body := map[string]interface{}{"name": c.Name, "type": 2}
+			if c.Type == "edge" {
+				body["type"] = 3
+			}
+			if s.isClusterMode() {
+				if c.NumberOfShards > 0 {
+					body["numberOfShards"] = c.NumberOfShards
+				}
+				if c.ReplicationFactor > 0 {
+					body["replicationFactor"] = c.ReplicationFactor
+				}
+			}
+			if err := s.manifestRequest("POST", dbPath+"/_api/collection", body); err != nil {
+				return maskAny(fmt.Errorf("Cannot create collection '%s' in database '%s': %v", c.Name, db.Name, err))
+			}
+		}
+	}
+
+	users, err := s.manifestNames("/_api/user", "user")
+	if err != nil {
+		return maskAny(err)
+	}
+	for _, u := range m.Users {
+		if !users[u.Name] {
+			s.log.Infof("Creating user '%s'", u.Name)
+			body := map[string]interface{}{"user": u.Name, "passwd": u.Password, "active": true}
+			if err := s.manifestRequest("POST", "/_api/user", body); err != nil {
+				return maskAny(fmt.Errorf("Cannot create user '%s': %v", u.Name, err))
+			}
+		}
+		for target, permission := range u.Grants {
+			path := "/_api/user/" + url.PathEscape(u.Name) + "/database"
+			for _, part := range strings.SplitN(target, "/", 2) {
+				path += "/" + url.PathEscape(part)
+			}
+			if err := s.manifestRequest("PUT", path, map[string]interface{}{"grant": permission}); err != nil {
+				return maskAny(fmt.Errorf("Cannot grant %s permission on '%s' to user '%s': %v", permission, target, u.Name, err))
+			}
+		}
+	}
+	return nil
+}
+
+// manifestNames returns the names found in the result of a GET request to the given path.
+// The result is either a list of names (field is empty), or a list of objects with the name in given field.
+func (s *Service) manifestNames(path, field string) (map[string]bool, error) {
+	ctx, cancel := context.WithTimeout(s.ctx, manifestRequestTimeout)
+	defer cancel()
+	content, err := s.clusterRequest(ctx, s.bootstrapServerType(), "GET", path, nil)
+	if err != nil {
+		return nil, maskAny(err)
+	}
+	result := make(map[string]bool)
+	if field == "" {
+		var resp struct {
+			Result []string `json:"result"`
+		}
+		if err := json.Unmarshal(content, &resp); err != nil {
+			return nil, maskAny(err)
+		}
+		for _, name := range resp.Result {
+			result[name] = true
+		}
+		return result, nil
+	}
+	var resp struct {
+		Result []map[string]interface{} `json:"result"`
+	}
+	if err := json.Unmarshal(content, &resp); err != nil {
+		return nil, maskAny(err)
+	}
+	for _, obj := range resp.Result {
+		if name, ok := obj[field].(string); ok {
+			result[name] = true
+		}
+	}
+	return result, nil
+}
+
+// manifestRequest sends a request with given JSON body to a coordinator (or the single server) of the deployment.
+func (s *Service) manifestRequest(method, path string, body map[string]interface{}) error {
+	b, err := json.Marshal(body)
+	if err != nil {
+		return maskAny(err)
+	}
+	ctx, cancel := context.WithTimeout(s.ctx, manifestRequestTimeout)
+	defer cancel()
+	if _, err := s.clusterRequest(ctx, s.bootstrapServerType(), method, path, b); err != nil {
+		return maskAny(err)
+	}
+	return nil
+}
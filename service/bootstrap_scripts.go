@@ -0,0 +1,128 @@
+//
+// DISCLAIMER
+//
+// Copyright 2017 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+// Author Ewout Prangsma
+//
+
+package service
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	bootstrapDirName = "bootstrap" // Directory (in the data directory) capturing the output of bootstrap scripts
+)
+
+// runBootstrapScripts executes the scripts given by BootstrapScripts (in order) using arangosh.
+// Every script is only executed once per deployment: the digests of scripts that have been executed
+// successfully are recorded in setup.json. When a script fails, the scripts following it are not executed.
+func (s *Service) runBootstrapScripts() error {
+	if len(s.BootstrapScripts) == 0 {
+		return nil
+	}
+	outputDir := filepath.Join(s.DataDir, bootstrapDirName)
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		return maskAny(err)
+	}
+	endpoint, err := s.clientToolEndpoint(s.bootstrapServerType())
+	if err != nil {
+		return maskAny(err)
+	}
+	for _, script := range s.BootstrapScripts {
+		digest, err := fileDigest(script)
+		if err != nil {
+			return maskAny(fmt.Errorf("Cannot read bootstrap script %s: %v", script, err))
+		}
+		if s.isExecutedScript(digest) {
+			s.log.Debugf("Bootstrap script %s has been executed before", script)
+			continue
+		}
+
+		s.log.Infof("Executing bootstrap script %s", script)
+		credentials, removeCredentials, err := s.clientToolCredentials("arangosh", outputDir)
+		if err != nil {
+			return maskAny(err)
+		}
+		args := append(credentials,
+			"--server.endpoint", endpoint,
+			"--server.username", "root",
+			"--javascript.execute", script,
+		)
+		err = s.runClientTool("arangosh", args, outputDir, filepath.Dir(script))
+		removeCredentials()
+		s.logBootstrapOutput(filepath.Base(script), outputDir)
+		if err != nil {
+			return maskAny(fmt.Errorf("Bootstrap script %s has failed: %v", script, err))
+		}
+		s.executedScripts = append(s.executedScripts, digest)
+		if err := s.saveSetup(); err != nil {
+			s.log.Errorf("Failed to record bootstrap script in %s: %v", setupFileName, err)
+		}
+		s.log.Info(newLogEvent("bootstrap-script", LogFields{"script": script},
+			"Bootstrap script %s has been executed", script))
+	}
+	return nil
+}
+
+// isExecutedScript returns true if a bootstrap script with given digest has been executed successfully before.
+func (s *Service) isExecutedScript(digest string) bool {
+	for _, d := range s.executedScripts {
+		if d == digest {
+			return true
+		}
+	}
+	return false
+}
+
+// logBootstrapOutput logs the output (stdout & stderr) of the last client tool run by a bootstrap task,
+// captured in the given directory. Every line is prefixed with the given name.
+func (s *Service) logBootstrapOutput(name, outputDir string) {
+	for _, stream := range []string{OutputStreamStdout, OutputStreamStderr} {
+		path, err := latestOutputFile(outputDir, stream)
+		if err != nil || path == "" {
+			continue
+		}
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		for _, line := range strings.Split(strings.TrimSpace(string(content)), "\n") {
+			if line != "" {
+				s.log.Infof("%s: %s", name, line)
+			}
+		}
+	}
+}
+
+// ValidateBootstrapScript checks that the given bootstrap script (of `--bootstrap.js-script`) can be read.
+func ValidateBootstrapScript(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return maskAny(err)
+	}
+	if info.IsDir() {
+		return maskAny(fmt.Errorf("%s is a directory", path))
+	}
+	return nil
+}
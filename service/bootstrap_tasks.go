@@ -0,0 +1,122 @@
+//
+// DISCLAIMER
+//
+// Copyright 2017 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+// Author Ewout Prangsma
+//
+
+package service
+
+import (
+	"fmt"
+	"sync"
+)
+
+const (
+	// BootstrapRunning indicates that the bootstrap tasks of a new deployment are running (or waiting for the deployment).
+	BootstrapRunning = "running"
+	// BootstrapDone indicates that all bootstrap tasks of a new deployment have finished.
+	BootstrapDone = "done"
+	// BootstrapFailed indicates that a bootstrap task of a new deployment has failed.
+	BootstrapFailed = "failed"
+)
+
+// BootstrapHealth holds the state of the bootstrap tasks (manifest, Foxx services, scripts, data import) of a new deployment.
+type BootstrapHealth struct {
+	Status string          `json:"status"`           // running | done | failed
+	Reason string          `json:"reason,omitempty"` // Reason of a failed status
+	Import *ImportProgress `json:"import,omitempty"` // Progress of the initial data import (if any)
+}
+
+// bootstrapTasks tracks the state of the bootstrap tasks, which are only run by the master.
+type bootstrapTasks struct {
+	mutex  sync.Mutex
+	health *BootstrapHealth
+}
+
+// set changes the state of the bootstrap tasks.
+func (b *bootstrapTasks) set(status, reason string) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.health = &BootstrapHealth{Status: status, Reason: reason}
+}
+
+// get returns a copy of the state of the bootstrap tasks (without import progress), or nil if no bootstrap tasks are run.
+func (b *bootstrapTasks) get() *BootstrapHealth {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if b.health == nil {
+		return nil
+	}
+	health := *b.health
+	return &health
+}
+
+// hasBootstrapTasks returns true when a new deployment has to be bootstrapped with a manifest, Foxx services, scripts or data.
+func (s *Service) hasBootstrapTasks() bool {
+	return s.BootstrapManifest != "" || len(s.BootstrapFoxx) > 0 || len(s.BootstrapScripts) > 0 || s.BootstrapImportDir != ""
+}
+
+// runBootstrapTasks applies the bootstrap manifest, installs the Foxx services, executes the bootstrap scripts
+// and imports the initial data (in that order),
+// once the deployment is up and a recovery from a backup (if any) has finished.
+// Only the master runs the bootstrap tasks. Tasks that have finished are recorded in setup.json
+// and never run again, failed tasks are retried when the master is restarted.
+func (s *Service) runBootstrapTasks() {
+	if !s.isMaster() {
+		return
+	}
+	s.bootstrapTasks.set(BootstrapRunning, "")
+	fail := func(format string, args ...interface{}) {
+		s.log.Errorf(format, args...)
+		s.bootstrapTasks.set(BootstrapFailed, fmt.Sprintf(format, args...))
+	}
+	if err := s.waitForRecoveryTarget(); err != nil {
+		fail("Cannot bootstrap the deployment: %v", err)
+		return
+	}
+	if err := s.waitForRecovery(s.ctx); err != nil {
+		fail("Cannot bootstrap the deployment, its recovery has failed: %v", err)
+		return
+	}
+	if err := s.applyBootstrapManifest(); err != nil {
+		fail("Applying bootstrap manifest %s has failed: %v", s.BootstrapManifest, err)
+		return
+	}
+	if err := s.installBootstrapFoxxServices(); err != nil {
+		fail("%v", err)
+		return
+	}
+	if err := s.runBootstrapScripts(); err != nil {
+		fail("%v", err)
+		return
+	}
+	if err := s.importBootstrapData(); err != nil {
+		fail("%v", err)
+		return
+	}
+	s.bootstrapTasks.set(BootstrapDone, "")
+}
+
+// bootstrapServerType returns the type of server the deployment is bootstrapped with.
+func (s *Service) bootstrapServerType() ServerType {
+	if s.isSingleMode() {
+		return ServerTypeSingle
+	}
+	return ServerTypeCoordinator
+}
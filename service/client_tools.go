@@ -0,0 +1,143 @@
+//
+// DISCLAIMER
+//
+// Copyright 2017 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+// Author Ewout Prangsma
+//
+
+package service
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"time"
+)
+
+// clientToolPath returns the path of the ArangoDB client tool (e.g. arangodump) with given name.
+// When using docker, this is the path inside the image, otherwise the tool is searched next to
+// the arangod executable and in the PATH.
+func (s *Service) clientToolPath(name string) string {
+	if s.DockerEndpoint != "" && s.DockerImage != "" {
+		return "/usr/bin/" + name
+	}
+	if runtime.GOOS == "windows" {
+		name = name + ".exe"
+	}
+	dir := filepath.Dir(s.ArangodPath)
+	for _, p := range []string{filepath.Join(dir, name), filepath.Join(dir, "..", "bin", name)} {
+		if _, err := os.Stat(p); err == nil {
+			return p
+		}
+	}
+	if p, err := exec.LookPath(name); err == nil {
+		return p
+	}
+	return filepath.Join(dir, name)
+}
+
+// clientToolEndpoint returns the endpoint used by client tools to reach the server of given type
+// started by this starter.
+func (s *Service) clientToolEndpoint(serverType ServerType) (string, error) {
+	myPeer, found := s.myPeers.PeerByID(s.ID)
+	if !found {
+		return "", maskAny(fmt.Errorf("Cannot find peer %s", s.ID))
+	}
+	if socket := s.serverSocket(serverType); socket != "" {
+		return unixSocketPrefix + socket, nil
+	}
+	port, err := s.serverPort(serverType)
+	if err != nil {
+		return "", maskAny(err)
+	}
+	return fmt.Sprintf("%s://%s", NewURLSchemes(s.IsSecure()).ArangoSH, net.JoinHostPort(myPeer.Address, strconv.Itoa(port))), nil
+}
+
+// clientToolCredentials writes a configuration file holding the password of the root user for the client tool
+// with given name into the given directory, readable by the owner only, so the password does not show up on a
+// command line. It returns the arguments that make the tool use it, and a function that removes the file.
+// Since the file replaces the default configuration of the tool, the JS startup directory is added for arangosh.
+func (s *Service) clientToolCredentials(name, dir string) ([]string, func(), error) {
+	if s.RootPassword == "" {
+		return []string{"--server.password", ""}, func() {}, nil
+	}
+	content := fmt.Sprintf("[server]\npassword = %s\n", s.RootPassword)
+	if name == "arangosh" && s.ArangodJSPath != "" && !(s.DockerEndpoint != "" && s.DockerImage != "") {
+		content += fmt.Sprintf("[javascript]\nstartup-directory = %s\n", s.ArangodJSPath)
+	}
+	f, err := ioutil.TempFile(dir, name+"-")
+	if err != nil {
+		return nil, nil, maskAny(err)
+	}
+	remove := func() { os.Remove(f.Name()) }
+	if err := f.Chmod(0600); err != nil {
+		f.Close()
+		remove()
+		return nil, nil, maskAny(err)
+	}
+	if _, err := f.WriteString(content); err != nil {
+		f.Close()
+		remove()
+		return nil, nil, maskAny(err)
+	}
+	if err := f.Close(); err != nil {
+		remove()
+		return nil, nil, maskAny(err)
+	}
+	return []string{"--configuration", f.Name()}, remove, nil
+}
+
+// runClientTool runs the ArangoDB client tool with given name and arguments using the runner of
+// the servers and waits until it has terminated.
+// The given directories are made available to the tool (when using docker) and the first of them
+// holds the container ID file (when using docker).
+func (s *Service) runClientTool(name string, args []string, dirs ...string) error {
+	if s.runner == nil {
+		return maskAny(fmt.Errorf("Cannot run %s before the servers are started", name))
+	}
+	var vols []Volume
+	for _, dir := range dirs {
+		vols = append(vols, Volume{HostPath: dir, ContainerPath: dir})
+	}
+	containerNamePrefix := ""
+	if s.DockerContainerName != "" {
+		containerNamePrefix = fmt.Sprintf("%s-", s.DockerContainerName)
+	}
+	containerName := fmt.Sprintf("%s%s-%s-%d", containerNamePrefix, name, s.ID, time.Now().Unix())
+	serverDir := ""
+	if len(dirs) > 0 {
+		serverDir = dirs[0]
+	}
+	p, err := s.runner.Start(s.clientToolPath(name), args, vols, nil, containerName, serverDir, "", Placement{}, nil)
+	if err != nil {
+		return maskAny(err)
+	}
+	exitCode := p.Wait()
+	if err := p.Cleanup(); err != nil {
+		s.log.Warningf("Failed to cleanup %s: %v", name, err)
+	}
+	if exitCode != 0 {
+		return maskAny(fmt.Errorf("%s has failed with exit code %d", name, exitCode))
+	}
+	return nil
+}
@@ -0,0 +1,174 @@
+//
+// DISCLAIMER
+//
+// Copyright 2017 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+// Author Ewout Prangsma
+//
+
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	clockSkewCheckInterval = time.Minute // Interval between checks of the clocks of all peers (by the master)
+)
+
+// TimeResponse is the JSON response of a `/time` request.
+type TimeResponse struct {
+	Time time.Time `json:"time"` // Current (wall clock) time of the starter
+}
+
+// clockSkews tracks the peers whose clock is skewed more than ClockSkewWarning.
+type clockSkews struct {
+	mutex  sync.Mutex
+	skewed map[string]bool // Peer ID -> skewed
+}
+
+// set records whether the clock of the peer with given ID is skewed.
+// Returns true when that has changed.
+func (c *clockSkews) set(peerID string, skewed bool) bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.skewed == nil {
+		c.skewed = make(map[string]bool)
+	}
+	changed := c.skewed[peerID] != skewed
+	c.skewed[peerID] = skewed
+	return changed
+}
+
+// timeHandler returns the current time of this starter, used by the master to detect skewed clocks.
+func (s *Service) timeHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		writeError(w, http.StatusMethodNotAllowed, "GET required")
+		return
+	}
+	b, err := json.Marshal(TimeResponse{Time: time.Now()})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.Write(b)
+}
+
+// formatClockSkew describes the given skew of the clock of a peer, relative to the clock of this starter.
+func formatClockSkew(skew time.Duration) string {
+	if skew < 0 {
+		return fmt.Sprintf("%s behind", -skew/time.Millisecond*time.Millisecond)
+	}
+	return fmt.Sprintf("%s ahead of", skew/time.Millisecond*time.Millisecond)
+}
+
+// absDuration returns the absolute value of the given duration.
+func absDuration(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
+
+// checkJoinClockSkew checks the clock of a peer that joins, given the time it has sent with its hello request.
+// It returns an error message when a new peer must be refused because the skew exceeds ClockSkewLimit,
+// a skew exceeding ClockSkewWarning is only logged.
+func (s *Service) checkJoinClockSkew(peerID, address string, peerTime time.Time, isNewPeer bool) string {
+	if peerTime.IsZero() {
+		// Older starters do not send their time
+		return ""
+	}
+	skew := peerTime.Sub(time.Now())
+	if isNewPeer && s.ClockSkewLimit > 0 && absDuration(skew) > s.ClockSkewLimit {
+		return fmt.Sprintf("The clock of peer '%s' at %s is %s the clock of the master, which exceeds %s (--starter.clock-skew-limit). Synchronize the clocks (e.g. using NTP).",
+			peerID, address, formatClockSkew(skew), s.ClockSkewLimit)
+	}
+	if s.ClockSkewWarning > 0 && absDuration(skew) > s.ClockSkewWarning {
+		s.peersLog.Warningf("The clock of peer '%s' at %s is %s the clock of the master, synchronize the clocks (e.g. using NTP)",
+			peerID, address, formatClockSkew(skew))
+	}
+	return ""
+}
+
+// measureClockSkew returns the skew of the clock of the given peer, relative to the clock of this starter.
+// The time of the peer is compared with the middle of the request, to compensate for network latency.
+func (s *Service) measureClockSkew(p Peer) (time.Duration, error) {
+	start := time.Now()
+	resp, err := httpClient.Get(p.CreateStarterURL("/time"))
+	if err != nil {
+		return 0, maskAny(err)
+	}
+	defer resp.Body.Close()
+	end := time.Now()
+	if resp.StatusCode != http.StatusOK {
+		return 0, maskAny(fmt.Errorf("Invalid status %d", resp.StatusCode))
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return 0, maskAny(err)
+	}
+	var result TimeResponse
+	if err := json.Unmarshal(body, &result); err != nil {
+		return 0, maskAny(err)
+	}
+	return result.Time.Sub(start.Add(end.Sub(start) / 2)), nil
+}
+
+// watchClockSkew periodically compares the clocks of all peers with the clock of the master (while this starter is the master).
+// When the skew of a peer exceeds ClockSkewWarning (or has recovered), it is logged and an event is published.
+func (s *Service) watchClockSkew() {
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-time.After(clockSkewCheckInterval):
+		}
+		if !s.isMaster() {
+			continue
+		}
+		s.mutex.Lock()
+		peerList := append([]Peer{}, s.myPeers.Peers...)
+		s.mutex.Unlock()
+		for _, p := range peerList {
+			if p.ID == s.ID {
+				continue
+			}
+			skew, err := s.measureClockSkew(p)
+			if err != nil {
+				s.peersLog.Debugf("Cannot get time of peer '%s': %v", p.ID, err)
+				continue
+			}
+			skewed := absDuration(skew) > s.ClockSkewWarning
+			if !s.clockSkews.set(p.ID, skewed) {
+				continue
+			}
+			if skewed {
+				reason := fmt.Sprintf("The clock of peer '%s' at %s is %s the clock of the master", p.ID, p.Address, formatClockSkew(skew))
+				s.peersLog.Warning(newLogEvent("clock-skew", LogFields{"peer-id": p.ID, "address": p.Address, "skew": skew.String()},
+					"%s, synchronize the clocks (e.g. using NTP)", reason))
+				s.events.publish(ProcessEvent{Type: ProcessEventClockSkew, PeerID: p.ID, Address: p.Address, Port: p.Port, Reason: reason})
+			} else {
+				s.peersLog.Infof("The clock of peer '%s' at %s is synchronized again (%s the clock of the master)", p.ID, p.Address, formatClockSkew(skew))
+			}
+		}
+	}
+}
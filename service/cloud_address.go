@@ -0,0 +1,168 @@
+//
+// DISCLAIMER
+//
+// Copyright 2017 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+// Author Ewout Prangsma
+//
+
+package service
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	cloudAddressPrefix = "auto-"
+	cloudPublicSuffix  = "-public"
+)
+
+var (
+	// metadataHTTPClient is used for requests to the instance metadata services of cloud providers.
+	// The metadata services must never be reached through a proxy.
+	metadataHTTPClient = &http.Client{
+		Timeout:   time.Second * 5,
+		Transport: &http.Transport{Proxy: nil},
+	}
+)
+
+// metadataRequest describes a request to an instance metadata service.
+type metadataRequest struct {
+	URL     string
+	Headers map[string]string
+}
+
+// cloudProviders maps the names of cloud providers to functions that create the metadata request
+// for their private (or public) address.
+var cloudProviders = map[string]func(public bool) metadataRequest{
+	"aws": func(public bool) metadataRequest {
+		what := "local-ipv4"
+		if public {
+			what = "public-ipv4"
+		}
+		headers := make(map[string]string)
+		// Prefer IMDSv2 (session token), fall back to IMDSv1 when no token can be obtained
+		if token, err := awsMetadataToken(); err == nil {
+			headers["X-aws-ec2-metadata-token"] = token
+		}
+		return metadataRequest{URL: "http://169.254.169.254/latest/meta-data/" + what, Headers: headers}
+	},
+	"gcp": func(public bool) metadataRequest {
+		what := "ip"
+		if public {
+			what = "access-configs/0/external-ip"
+		}
+		return metadataRequest{
+			URL:     "http://metadata.google.internal/computeMetadata/v1/instance/network-interfaces/0/" + what,
+			Headers: map[string]string{"Metadata-Flavor": "Google"},
+		}
+	},
+	"azure": func(public bool) metadataRequest {
+		what := "privateIpAddress"
+		if public {
+			what = "publicIpAddress"
+		}
+		return metadataRequest{
+			URL:     "http://169.254.169.254/metadata/instance/network/interface/0/ipv4/ipAddress/0/" + what + "?api-version=2021-02-01&format=text",
+			Headers: map[string]string{"Metadata": "true"},
+		}
+	},
+}
+
+// IsCloudAddress returns true if the given address asks for detection of the address
+// using the instance metadata service of a cloud provider (auto-aws, auto-gcp-public, ...).
+func IsCloudAddress(address string) bool {
+	_, _, err := parseCloudAddress(address)
+	return err == nil
+}
+
+// parseCloudAddress returns the cloud provider and kind (private/public) of address of
+// the given auto-<provider>[-public] address.
+func parseCloudAddress(address string) (provider string, public bool, err error) {
+	if !strings.HasPrefix(address, cloudAddressPrefix) {
+		return "", false, maskAny(fmt.Errorf("'%s' is not a cloud address", address))
+	}
+	provider = strings.TrimPrefix(address, cloudAddressPrefix)
+	if strings.HasSuffix(provider, cloudPublicSuffix) {
+		provider, public = strings.TrimSuffix(provider, cloudPublicSuffix), true
+	}
+	if _, found := cloudProviders[provider]; !found {
+		return "", false, maskAny(fmt.Errorf("Unknown cloud provider '%s', expected auto-aws, auto-gcp or auto-azure (optionally followed by -public)", provider))
+	}
+	return provider, public, nil
+}
+
+// DetectCloudAddress queries the instance metadata service of the cloud provider in the
+// given auto-<provider>[-public] address for the private (or public) address of this machine.
+func DetectCloudAddress(address string) (string, error) {
+	provider, public, err := parseCloudAddress(address)
+	if err != nil {
+		return "", maskAny(err)
+	}
+	mr := cloudProviders[provider](public)
+	req, err := http.NewRequest("GET", mr.URL, nil)
+	if err != nil {
+		return "", maskAny(err)
+	}
+	for k, v := range mr.Headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := metadataHTTPClient.Do(req)
+	if err != nil {
+		return "", maskAny(err)
+	}
+	defer resp.Body.Close()
+	content, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", maskAny(err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", maskAny(fmt.Errorf("Invalid status %d from metadata service of %s", resp.StatusCode, provider))
+	}
+	ip := strings.TrimSpace(string(content))
+	if net.ParseIP(ip) == nil {
+		return "", maskAny(fmt.Errorf("Metadata service of %s returned no IP address (got '%s')", provider, ip))
+	}
+	return ip, nil
+}
+
+// awsMetadataToken requests a session token for the instance metadata service of AWS (IMDSv2).
+func awsMetadataToken() (string, error) {
+	req, err := http.NewRequest("PUT", "http://169.254.169.254/latest/api/token", nil)
+	if err != nil {
+		return "", maskAny(err)
+	}
+	req.Header.Set("X-aws-ec2-metadata-token-ttl-seconds", "60")
+	resp, err := metadataHTTPClient.Do(req)
+	if err != nil {
+		return "", maskAny(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", maskAny(fmt.Errorf("Invalid status %d", resp.StatusCode))
+	}
+	token, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", maskAny(err)
+	}
+	return string(token), nil
+}
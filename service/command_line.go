@@ -0,0 +1,128 @@
+//
+// DISCLAIMER
+//
+// Copyright 2017 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+// Author Ewout Prangsma
+//
+
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ServerCommandLine is the JSON response of a `/processes/<server-type>/commandline` request.
+type ServerCommandLine struct {
+	Type      ServerType        `json:"type"`                 // agent | coordinator | dbserver | single
+	Args      []string          `json:"args"`                 // Exact argv of the server (inside its container when using docker)
+	Env       map[string]string `json:"env,omitempty"`        // Environment variables set in addition to those of the starter (process) or image (docker)
+	DockerRun []string          `json:"docker-run,omitempty"` // Equivalent `docker run` command line (docker only)
+	Started   time.Time         `json:"started"`              // Time the server was started with this command line
+}
+
+// serverCommandLines holds the command lines used to launch the servers of this starter.
+type serverCommandLines struct {
+	mutex sync.Mutex
+	lines map[ServerType]ServerCommandLine
+}
+
+// set records the command line used to launch the server of given type.
+func (l *serverCommandLines) set(cmdLine ServerCommandLine) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.lines == nil {
+		l.lines = make(map[ServerType]ServerCommandLine)
+	}
+	l.lines[cmdLine.Type] = cmdLine
+}
+
+// get returns the command line used to launch the server of given type.
+func (l *serverCommandLines) get(serverType ServerType) (ServerCommandLine, bool) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	cmdLine, found := l.lines[serverType]
+	return cmdLine, found
+}
+
+// recordCommandLine records the command line the given runner uses to start a server of given type
+// with given arguments.
+func (s *Service) recordCommandLine(runner Runner, serverType ServerType, args []string, volumes []Volume, ports []int, containerName, coreDir string, placement Placement, env map[string]string) {
+	argv, dockerRun := runner.CommandLine(args[0], args[1:], volumes, ports, containerName, coreDir, placement, env)
+	for _, secret := range []string{licenseKeyEnvVar, rootPasswordEnvVar} {
+		if _, found := env[secret]; !found {
+			continue
+		}
+		// Do not reveal the license key or root password
+		redactedEnv := make(map[string]string)
+		for k, v := range env {
+			redactedEnv[k] = v
+		}
+		redactedEnv[secret] = redacted
+		env = redactedEnv
+		for i, arg := range dockerRun {
+			if strings.HasPrefix(arg, secret+"=") {
+				dockerRun[i] = secret + "=" + redacted
+			}
+		}
+	}
+	s.commandLines.set(ServerCommandLine{
+		Type:      serverType,
+		Args:      argv,
+		Env:       env,
+		DockerRun: dockerRun,
+		Started:   time.Now(),
+	})
+}
+
+// commandLineHandler returns the command line used to launch the server of the type given in the path
+// (`/processes/<server-type>/commandline`).
+func (s *Service) commandLineHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		writeError(w, http.StatusMethodNotAllowed, "GET required")
+		return
+	}
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/processes/"), "/")
+	if len(parts) != 2 || parts[1] != "commandline" {
+		writeError(w, http.StatusNotFound, "Unknown path, expected /processes/<server-type>/commandline")
+		return
+	}
+	serverType := ServerType(parts[0])
+	switch serverType {
+	case ServerTypeAgent, ServerTypeDBServer, ServerTypeCoordinator, ServerTypeSingle:
+	default:
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("Unknown server type '%s'", serverType))
+		return
+	}
+	cmdLine, found := s.commandLines.get(serverType)
+	if !found {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("No %s has been started by this starter", serverType))
+		return
+	}
+
+	b, err := json.Marshal(cmdLine)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+	} else {
+		w.Write(b)
+	}
+}
@@ -0,0 +1,204 @@
+//
+// DISCLAIMER
+//
+// Copyright 2017 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+// Author Ewout Prangsma
+//
+
+package service
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	minCompressSize = 1024 // Responses smaller than this are not compressed
+)
+
+var (
+	// uncompressedPaths are served as is: the event stream must be flushed per event
+	// and diagnostics bundles are compressed already.
+	uncompressedPaths = map[string]bool{
+		"/events":      true,
+		"/diagnostics": true,
+	}
+	// streamedPathPrefixes are compressed while they are written (without ETag), because they can be large.
+	streamedPathPrefixes = []string{"/logs/"}
+)
+
+// compressed wraps the given handler, such that GET responses get an ETag (answering a request
+// with a matching If-None-Match header with 304 Not Modified) and are gzip compressed
+// when the client accepts it.
+func compressed(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if (r.Method != "GET" && r.Method != "HEAD") || uncompressedPaths[r.URL.Path] {
+			next.ServeHTTP(w, r)
+			return
+		}
+		for _, prefix := range streamedPathPrefixes {
+			if strings.HasPrefix(r.URL.Path, prefix) {
+				if !acceptsGzip(r) {
+					next.ServeHTTP(w, r)
+					return
+				}
+				gw := &gzipResponseWriter{ResponseWriter: w}
+				defer gw.close()
+				next.ServeHTTP(gw, r)
+				return
+			}
+		}
+		bw := &bufferedResponseWriter{header: make(http.Header)}
+		next.ServeHTTP(bw, r)
+		bw.writeTo(w, r)
+	})
+}
+
+// acceptsGzip returns true if the client of the given request accepts gzip compressed responses.
+func acceptsGzip(r *http.Request) bool {
+	for _, encoding := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		parts := strings.Split(strings.TrimSpace(encoding), ";")
+		if strings.TrimSpace(parts[0]) != "gzip" {
+			continue
+		}
+		if len(parts) > 1 && strings.Replace(strings.TrimSpace(parts[1]), " ", "", -1) == "q=0" {
+			return false
+		}
+		return true
+	}
+	return false
+}
+
+// etagMatches returns true if the given If-None-Match header value matches the given (weak) ETag.
+func etagMatches(ifNoneMatch, etag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+// bufferedResponseWriter holds an entire response, so its ETag can be computed before it is sent.
+type bufferedResponseWriter struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (bw *bufferedResponseWriter) Header() http.Header {
+	return bw.header
+}
+
+func (bw *bufferedResponseWriter) WriteHeader(status int) {
+	if bw.status == 0 {
+		bw.status = status
+	}
+}
+
+func (bw *bufferedResponseWriter) Write(data []byte) (int, error) {
+	if bw.status == 0 {
+		bw.status = http.StatusOK
+	}
+	return bw.body.Write(data)
+}
+
+// writeTo sends the buffered response, as answer to the given request, to the given writer.
+func (bw *bufferedResponseWriter) writeTo(w http.ResponseWriter, r *http.Request) {
+	if bw.status == 0 {
+		bw.status = http.StatusOK
+	}
+	header := w.Header()
+	for key, values := range bw.header {
+		header[key] = values
+	}
+	header.Add("Vary", "Accept-Encoding")
+	body := bw.body.Bytes()
+
+	if bw.status == http.StatusOK && header.Get("ETag") == "" {
+		// A weak ETag, since the same ETag is used for the compressed & uncompressed response
+		hash := sha256.Sum256(body)
+		etag := `W/"` + hex.EncodeToString(hash[:16]) + `"`
+		header.Set("ETag", etag)
+		if inm := r.Header.Get("If-None-Match"); inm != "" && etagMatches(inm, etag) {
+			header.Del("Content-Type")
+			header.Del("Content-Length")
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+	}
+	if len(body) >= minCompressSize && header.Get("Content-Encoding") == "" && acceptsGzip(r) {
+		var compressedBody bytes.Buffer
+		gw := gzip.NewWriter(&compressedBody)
+		if _, err := gw.Write(body); err == nil && gw.Close() == nil {
+			body = compressedBody.Bytes()
+			header.Set("Content-Encoding", "gzip")
+		}
+	}
+	header.Set("Content-Length", strconv.Itoa(len(body)))
+	w.WriteHeader(bw.status)
+	w.Write(body)
+}
+
+// gzipResponseWriter compresses a response while it is written.
+type gzipResponseWriter struct {
+	http.ResponseWriter
+	gw          *gzip.Writer
+	wroteHeader bool
+}
+
+func (gw *gzipResponseWriter) WriteHeader(status int) {
+	if gw.wroteHeader {
+		return
+	}
+	gw.wroteHeader = true
+	header := gw.ResponseWriter.Header()
+	header.Add("Vary", "Accept-Encoding")
+	if status != http.StatusNoContent && status != http.StatusNotModified && header.Get("Content-Encoding") == "" {
+		header.Del("Content-Length")
+		header.Set("Content-Encoding", "gzip")
+		gw.gw = gzip.NewWriter(gw.ResponseWriter)
+	}
+	gw.ResponseWriter.WriteHeader(status)
+}
+
+func (gw *gzipResponseWriter) Write(data []byte) (int, error) {
+	if !gw.wroteHeader {
+		gw.WriteHeader(http.StatusOK)
+	}
+	if gw.gw == nil {
+		return gw.ResponseWriter.Write(data)
+	}
+	return gw.gw.Write(data)
+}
+
+// close finishes the compressed response (if any).
+func (gw *gzipResponseWriter) close() {
+	if !gw.wroteHeader {
+		gw.WriteHeader(http.StatusOK)
+	}
+	if gw.gw != nil {
+		gw.gw.Close()
+	}
+}
@@ -25,6 +25,7 @@ package service
 import (
 	"fmt"
 	"io"
+	"sort"
 	"strings"
 )
 
@@ -64,8 +65,13 @@ type configSection struct {
 // WriteTo writes the configuration section to the given writer.
 func (s *configSection) WriteTo(w io.Writer) (int64, error) {
 	lines := []string{"[" + s.Name + "]"}
-	for k, v := range s.Settings {
-		lines = append(lines, fmt.Sprintf("%s = %s", k, v))
+	keys := make([]string, 0, len(s.Settings))
+	for k := range s.Settings {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		lines = append(lines, fmt.Sprintf("%s = %s", k, s.Settings[k]))
 	}
 	lines = append(lines, "")
 	n, err := w.Write([]byte(strings.Join(lines, "\n")))
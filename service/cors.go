@@ -0,0 +1,96 @@
+//
+// DISCLAIMER
+//
+// Copyright 2017 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+// Author Ewout Prangsma
+//
+
+package service
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const (
+	corsAllowedMethods = "GET, POST, PUT, DELETE, OPTIONS"
+	corsExposedHeaders = "ETag, Retry-After, Location, X-Arangodb-Operation-Id"
+	corsMaxAge         = "600" // Seconds browsers may cache the response to a preflight request
+)
+
+// ValidateCORSOrigin checks that the given origin is `*` or a URL consisting of scheme, host & (optional) port.
+func ValidateCORSOrigin(origin string) error {
+	if origin == "*" {
+		return nil
+	}
+	u, err := url.Parse(origin)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || (u.Path != "" && u.Path != "/") || u.RawQuery != "" {
+		return maskAny(fmt.Errorf("Invalid origin '%s', expected * or http(s)://host[:port]", origin))
+	}
+	return nil
+}
+
+// corsOrigin returns the value of the Access-Control-Allow-Origin header for the given origin,
+// or "" if the origin is not allowed.
+func (s *Service) corsOrigin(origin string) string {
+	for _, allowed := range s.APICORSAllowedOrigins {
+		if allowed == "*" {
+			return "*"
+		}
+		if strings.EqualFold(strings.TrimSuffix(allowed, "/"), origin) {
+			return origin
+		}
+	}
+	return ""
+}
+
+// withCORS wraps the given handler, such that browsers on the origins in APICORSAllowedOrigins
+// can call the starter API. Preflight (OPTIONS) requests of allowed origins are answered directly.
+func (s *Service) withCORS(next http.Handler) http.Handler {
+	if len(s.APICORSAllowedOrigins) == 0 {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		header := w.Header()
+		header.Add("Vary", "Origin")
+		allowOrigin := s.corsOrigin(origin)
+		if allowOrigin == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		header.Set("Access-Control-Allow-Origin", allowOrigin)
+		if r.Method == "OPTIONS" && r.Header.Get("Access-Control-Request-Method") != "" {
+			header.Set("Access-Control-Allow-Methods", corsAllowedMethods)
+			if requested := r.Header.Get("Access-Control-Request-Headers"); requested != "" {
+				header.Set("Access-Control-Allow-Headers", requested)
+			}
+			header.Set("Access-Control-Max-Age", corsMaxAge)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		header.Set("Access-Control-Expose-Headers", corsExposedHeaders)
+		next.ServeHTTP(w, r)
+	})
+}
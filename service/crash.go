@@ -0,0 +1,207 @@
+//
+// DISCLAIMER
+//
+// Copyright 2017 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+// Author Ewout Prangsma
+//
+
+package service
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	crashesDirName     = "crashes"
+	corePatternPath    = "/proc/sys/kernel/core_pattern"
+	crashInfoFileName  = "crash.json"
+	crashLogFileName   = "arangod.log"
+	coreFileNamePrefix = "core"
+)
+
+// CrashInfo describes a crash of a server, as stored in its crash bundle.
+type CrashInfo struct {
+	Type     string    `json:"type"`              // agent | coordinator | dbserver | single
+	PeerID   string    `json:"peer-id"`           // ID of the peer that started the server
+	Port     int       `json:"port"`              // Port the server was listening on
+	Time     time.Time `json:"time"`              // Time the crash was detected
+	ExitCode int       `json:"exit-code"`         // Exit code of the server (-1 if unknown)
+	Version  string    `json:"version,omitempty"` // Last known version of the server (if any)
+	Core     string    `json:"core"`              // Name of the core file in the bundle
+}
+
+// serverCoreDir returns the path of the folder (in host namespace) in which the server of given
+// type runs, so its core dumps are written into it.
+// Returns an empty string when core dumps are not collected.
+func (s *Service) serverCoreDir(serverType ServerType) (string, error) {
+	if s.CoreDirectory == "" {
+		return "", nil
+	}
+	myPort, err := s.serverPort(serverType)
+	if err != nil {
+		return "", maskAny(err)
+	}
+	return filepath.Join(s.CoreDirectory, fmt.Sprintf("%s%d", serverType, myPort)), nil
+}
+
+// prepareCoreDumps raises the core file size limit (inherited by the servers) and warns when
+// the core pattern of the system will not write core dumps into the core directory.
+func (s *Service) prepareCoreDumps() {
+	if err := raiseCoreLimit(); err != nil {
+		s.log.Warningf("Cannot raise core file size limit: %v", err)
+	}
+	if content, err := ioutil.ReadFile(corePatternPath); err == nil {
+		pattern := strings.TrimSpace(string(content))
+		if strings.HasPrefix(pattern, "|") || filepath.IsAbs(pattern) {
+			s.log.Warningf("Core pattern '%s' does not write core dumps into the working directory of the servers, no cores will be collected. Use e.g. `sysctl kernel.core_pattern=core.%%p`", pattern)
+		}
+	}
+}
+
+// collectCrash looks for a core dump written by the server of given type since the given
+// start time. If found, a crash bundle containing the core plus the most recent log lines
+// of the server is created in the data directory and announced with a `crash` event.
+func (s *Service) collectCrash(serverType ServerType, startTime time.Time, exitCode int) {
+	serverLog := s.serverLogger(serverType)
+	coreDir, err := s.serverCoreDir(serverType)
+	if err != nil || coreDir == "" {
+		return
+	}
+	corePath, found := findCoreFile(coreDir, startTime)
+	if !found {
+		return
+	}
+	myPort, _ := s.serverPort(serverType)
+	myHostDir, err := s.serverHostDir(serverType)
+	if err != nil {
+		serverLog.Errorf("Cannot find server host dir: %#v", err)
+		return
+	}
+	now := time.Now()
+	bundleDir := filepath.Join(s.DataDir, crashesDirName, fmt.Sprintf("%s%d-%s", serverType, myPort, now.UTC().Format("20060102T150405Z")))
+	if err := os.MkdirAll(bundleDir, 0755); err != nil {
+		serverLog.Errorf("Failed to create crash bundle directory %s: %v", bundleDir, err)
+		return
+	}
+	coreName := filepath.Base(corePath)
+	if err := moveFile(corePath, filepath.Join(bundleDir, coreName)); err != nil {
+		serverLog.Errorf("Failed to move core %s into crash bundle: %v", corePath, err)
+		return
+	}
+	if lines, err := readRecentLogLines(filepath.Join(myHostDir, logFileName), s.CoreLogLines); err != nil {
+		serverLog.Warningf("Cannot read log file of %s: %v", serverType, err)
+	} else if err := ioutil.WriteFile(filepath.Join(bundleDir, crashLogFileName), []byte(strings.Join(lines, "")), 0644); err != nil {
+		serverLog.Warningf("Failed to write log lines into crash bundle: %v", err)
+	}
+	info := CrashInfo{
+		Type:     serverType.String(),
+		PeerID:   s.ID,
+		Port:     myPort,
+		Time:     now,
+		ExitCode: exitCode,
+		Version:  s.serverStates.get(serverType).Version,
+		Core:     coreName,
+	}
+	if encoded, err := json.MarshalIndent(info, "", "  "); err == nil {
+		if err := ioutil.WriteFile(filepath.Join(bundleDir, crashInfoFileName), encoded, 0644); err != nil {
+			serverLog.Warningf("Failed to write crash info into crash bundle: %v", err)
+		}
+	}
+	serverLog.Error(newLogEvent("crash", LogFields{"bundle": bundleDir, "core": coreName, "exit-code": exitCode},
+		"%s has crashed and produced a core, crash bundle created in %s", serverType, bundleDir))
+}
+
+// findCoreFile returns the path of the most recent core file in the given directory
+// that has been written since the given time.
+func findCoreFile(dir string, since time.Time) (string, bool) {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return "", false
+	}
+	var result os.FileInfo
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasPrefix(e.Name(), coreFileNamePrefix) || e.ModTime().Before(since) {
+			continue
+		}
+		if result == nil || e.ModTime().After(result.ModTime()) {
+			result = e
+		}
+	}
+	if result == nil {
+		return "", false
+	}
+	return filepath.Join(dir, result.Name()), true
+}
+
+// readRecentLogLines returns the last maxLines lines (including line endings) of the file at given path.
+func readRecentLogLines(path string, maxLines int) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, maskAny(err)
+	}
+	defer f.Close()
+	rd := bufio.NewReader(f)
+	var lines []string
+	for {
+		line, err := rd.ReadString('\n')
+		if line != "" {
+			lines = append(lines, line)
+			if len(lines) > maxLines {
+				lines = lines[1:]
+			}
+		}
+		if err != nil {
+			break
+		}
+	}
+	return lines, nil
+}
+
+// moveFile moves the file at given source path to the given destination path,
+// copying it when it cannot be renamed (e.g. when it is on another file system).
+func moveFile(sourcePath, destPath string) error {
+	if err := os.Rename(sourcePath, destPath); err == nil {
+		return nil
+	}
+	source, err := os.Open(sourcePath)
+	if err != nil {
+		return maskAny(err)
+	}
+	defer source.Close()
+	dest, err := os.OpenFile(destPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return maskAny(err)
+	}
+	defer dest.Close()
+	if _, err := io.Copy(dest, source); err != nil {
+		return maskAny(err)
+	}
+	if err := dest.Close(); err != nil {
+		return maskAny(err)
+	}
+	source.Close()
+	return maskAny(os.Remove(sourcePath))
+}
@@ -0,0 +1,184 @@
+//
+// DISCLAIMER
+//
+// Copyright 2017 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+// Author Ewout Prangsma
+//
+
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+const (
+	crashLoopWebhookTimeout = time.Second * 10
+)
+
+// Health states of a server or starter, as reported by `/health`.
+const (
+	HealthOK       = "ok"       // Server is up
+	HealthDown     = "down"     // Server is not (yet) up
+	HealthDegraded = "degraded" // Server is in a crash loop
+	HealthFailed   = "failed"   // Server has failed and is no longer restarted
+)
+
+// HealthResponse is the JSON response of a `/health` request.
+type HealthResponse struct {
+	Status    string           `json:"status"`              // ok | degraded | failed
+	Servers   []ServerHealth   `json:"servers,omitempty"`   // Health of every server started by the starter
+	License   *LicenseHealth   `json:"license,omitempty"`   // Health of the license key passed to the servers (if any)
+	Disks     []DiskHealth     `json:"disks,omitempty"`     // Free disk space of the directories used by the servers (if monitored)
+	Bootstrap *BootstrapHealth `json:"bootstrap,omitempty"` // State of the bootstrap tasks of a new deployment (if any, on the master)
+}
+
+// ServerHealth holds the health of a single server started by the starter.
+type ServerHealth struct {
+	Type     string `json:"type"`               // agent | coordinator | dbserver | single
+	Status   string `json:"status"`             // ok | down | degraded | failed
+	Restarts int    `json:"restarts,omitempty"` // Number of restarts within the crash loop window
+	Reason   string `json:"reason,omitempty"`   // Reason of a degraded or failed status
+}
+
+// crashLoopEvent is the JSON body posted to the crash loop webhook.
+type crashLoopEvent struct {
+	Event      string    `json:"event"`       // Always crash-loop
+	StarterID  string    `json:"starter-id"`  // ID of the starter
+	Address    string    `json:"address"`     // Address of the starter
+	ServerType string    `json:"server-type"` // Type of the server in a crash loop
+	Restarts   int       `json:"restarts"`    // Number of restarts within the window
+	Window     string    `json:"window"`      // Window of the crash loop detection
+	Time       time.Time `json:"time"`        // Time the crash loop has been detected
+}
+
+// checkCrashLoop checks if the server of given type has been restarted CrashLoopRestarts times
+// within CrashLoopWindow. When it has entered a crash loop, an event is logged and the webhook is called.
+func (s *Service) checkCrashLoop(serverType ServerType) {
+	if s.CrashLoopRestarts <= 0 {
+		return
+	}
+	restarts := s.serverStates.recentRestarts(serverType, s.CrashLoopWindow)
+	inLoop := restarts >= s.CrashLoopRestarts
+	if !s.serverStates.setCrashLoop(serverType, inLoop) || !inLoop {
+		return
+	}
+	s.serverLogger(serverType).Error(newLogEvent("crash-loop", LogFields{"restarts": restarts, "window": s.CrashLoopWindow.String()},
+		"%s is in a crash loop, it has been restarted %d times in %s", serverType, restarts, s.CrashLoopWindow))
+	s.publishServerEvent(ProcessEventCrashLoop, serverType, "", fmt.Sprintf("restarted %d times in %s", restarts, s.CrashLoopWindow))
+	if s.CrashLoopWebhook != "" {
+		go s.callCrashLoopWebhook(crashLoopEvent{
+			Event:      "crash-loop",
+			StarterID:  s.ID,
+			Address:    s.OwnAddress,
+			ServerType: string(serverType),
+			Restarts:   restarts,
+			Window:     s.CrashLoopWindow.String(),
+			Time:       time.Now(),
+		})
+	}
+}
+
+// callCrashLoopWebhook posts the given event to the crash loop webhook.
+func (s *Service) callCrashLoopWebhook(event crashLoopEvent) {
+	body, err := json.Marshal(event)
+	if err != nil {
+		s.log.Warningf("Cannot encode crash loop event: %v", err)
+		return
+	}
+	c := &http.Client{Timeout: crashLoopWebhookTimeout}
+	resp, err := c.Post(s.CrashLoopWebhook, "application/json", bytes.NewReader(body))
+	if err != nil {
+		s.log.Warningf("Failed to call crash loop webhook: %v", err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		s.log.Warningf("Crash loop webhook returned status %d", resp.StatusCode)
+	}
+}
+
+// healthHandler returns the health of this starter and the servers started by it.
+// It returns status 503 when a server has failed.
+func (s *Service) healthHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		writeError(w, http.StatusMethodNotAllowed, "GET required")
+		return
+	}
+
+	resp := s.health()
+	b, err := json.Marshal(resp)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if resp.Status == HealthFailed {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	w.Write(b)
+}
+
+// health returns the health of this starter and the servers started by it.
+func (s *Service) health() HealthResponse {
+	resp := HealthResponse{Status: HealthOK}
+	if myPeer, found := s.myPeers.PeerByID(s.ID); found {
+		for _, sp := range s.serverProcesses(myPeer) {
+			serverType := ServerType(sp.Type)
+			state := s.serverStates.get(serverType)
+			health := ServerHealth{
+				Type:     sp.Type,
+				Status:   HealthOK,
+				Restarts: s.serverStates.recentRestarts(serverType, s.CrashLoopWindow),
+			}
+			switch {
+			case state.Failed:
+				health.Status = HealthFailed
+				health.Reason = state.FailReason
+				resp.Status = HealthFailed
+			case s.CrashLoopRestarts > 0 && health.Restarts >= s.CrashLoopRestarts:
+				health.Status = HealthDegraded
+				health.Reason = fmt.Sprintf("restarted %d times in %s", health.Restarts, s.CrashLoopWindow)
+			case !state.Up:
+				health.Status = HealthDown
+			}
+			if health.Status != HealthOK && resp.Status == HealthOK {
+				resp.Status = HealthDegraded
+			}
+			resp.Servers = append(resp.Servers, health)
+		}
+	}
+	if resp.License = s.licenseHealth(); resp.License != nil && resp.License.Status == LicenseExpired && resp.Status == HealthOK {
+		resp.Status = HealthDegraded
+	}
+	if resp.Bootstrap = s.bootstrapTasks.get(); resp.Bootstrap != nil {
+		resp.Bootstrap.Import = s.imports.get()
+		if resp.Bootstrap.Status == BootstrapFailed && resp.Status == HealthOK {
+			resp.Status = HealthDegraded
+		}
+	}
+	resp.Disks = s.diskSpace.get()
+	for _, d := range resp.Disks {
+		if d.Status == DiskStatusLow && resp.Status == HealthOK {
+			resp.Status = HealthDegraded
+		}
+	}
+	return resp
+}
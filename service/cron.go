@@ -0,0 +1,127 @@
+//
+// DISCLAIMER
+//
+// Copyright 2017 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+// Author Ewout Prangsma
+//
+
+package service
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CronSchedule is a parsed cron expression with 5 fields: minute, hour, day of month, month & day of week.
+type CronSchedule struct {
+	fields [5]map[int]bool
+	anyDom bool // If set, the day of month field is `*`
+	anyDow bool // If set, the day of week field is `*`
+}
+
+// cronFieldRanges holds the minimum & maximum value of each field of a cron expression.
+var cronFieldRanges = [5][2]int{{0, 59}, {0, 23}, {1, 31}, {1, 12}, {0, 7}}
+
+// ParseCronSchedule parses a cron expression like `0 2 * * *`.
+// Each field supports `*`, values, ranges (`1-5`), lists (`1,3`) and steps (`*/15`).
+func ParseCronSchedule(spec string) (CronSchedule, error) {
+	parts := strings.Fields(spec)
+	if len(parts) != 5 {
+		return CronSchedule{}, maskAny(fmt.Errorf("Invalid schedule '%s', expected 5 fields (minute hour day-of-month month day-of-week)", spec))
+	}
+	var result CronSchedule
+	for i, part := range parts {
+		min, max := cronFieldRanges[i][0], cronFieldRanges[i][1]
+		values := make(map[int]bool)
+		for _, item := range strings.Split(part, ",") {
+			step := 1
+			if idx := strings.Index(item, "/"); idx >= 0 {
+				var err error
+				step, err = strconv.Atoi(item[idx+1:])
+				if err != nil || step <= 0 {
+					return CronSchedule{}, maskAny(fmt.Errorf("Invalid step in '%s' of schedule '%s'", item, spec))
+				}
+				item = item[:idx]
+			}
+			from, to := min, max
+			if item != "*" {
+				bounds := strings.SplitN(item, "-", 2)
+				var err error
+				if from, err = strconv.Atoi(bounds[0]); err != nil {
+					return CronSchedule{}, maskAny(fmt.Errorf("Invalid value '%s' in schedule '%s'", item, spec))
+				}
+				to = from
+				if len(bounds) == 2 {
+					if to, err = strconv.Atoi(bounds[1]); err != nil {
+						return CronSchedule{}, maskAny(fmt.Errorf("Invalid value '%s' in schedule '%s'", item, spec))
+					}
+				}
+			}
+			if from < min || to > max || from > to {
+				return CronSchedule{}, maskAny(fmt.Errorf("Value '%s' out of range %d-%d in schedule '%s'", item, min, max, spec))
+			}
+			for v := from; v <= to; v += step {
+				if i == 4 && v == 7 {
+					// Sunday can be written as 0 or 7
+					values[0] = true
+				} else {
+					values[v] = true
+				}
+			}
+		}
+		result.fields[i] = values
+	}
+	result.anyDom = parts[2] == "*"
+	result.anyDow = parts[4] == "*"
+	return result, nil
+}
+
+// Matches returns true if the given time (in minutes) matches the schedule.
+func (c CronSchedule) Matches(t time.Time) bool {
+	if !c.fields[0][t.Minute()] || !c.fields[1][t.Hour()] || !c.fields[3][int(t.Month())] {
+		return false
+	}
+	dom, dow := c.fields[2][t.Day()], c.fields[4][int(t.Weekday())]
+	switch {
+	case c.anyDom && c.anyDow:
+		return true
+	case c.anyDom:
+		return dow
+	case c.anyDow:
+		return dom
+	default:
+		// When both are restricted, either of them must match
+		return dom || dow
+	}
+}
+
+// Next returns the first time (in whole minutes) after the given time that matches the schedule.
+// Returns the zero time if there is no such time within 5 years.
+func (c CronSchedule) Next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	end := t.AddDate(5, 0, 0)
+	for t.Before(end) {
+		if c.Matches(t) {
+			return t
+		}
+		t = t.Add(time.Minute)
+	}
+	return time.Time{}
+}
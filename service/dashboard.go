@@ -0,0 +1,222 @@
+//
+// DISCLAIMER
+//
+// Copyright 2017 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+// Author Ewout Prangsma
+//
+
+package service
+
+import (
+	"net/http"
+)
+
+// dashboardHandler is a request handler for GET /.
+// It serves a small single-page UI showing the peers, servers, health & events of the starter,
+// backed entirely by the HTTP API of the starter.
+func (s *Service) dashboardHandler(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		writeError(w, http.StatusNotFound, "Not found")
+		return
+	}
+	if r.Method != "GET" && r.Method != "HEAD" {
+		writeError(w, http.StatusMethodNotAllowed, "GET required")
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Frame-Options", "DENY")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(dashboardHTML))
+}
+
+// dashboardHTML is the page served by dashboardHandler.
+// It polls /status & /health, streams /events and uses POST /server/restart & /logs/rotate for its actions.
+const dashboardHTML = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>ArangoDB Starter</title>
+<style>
+body { font-family: sans-serif; margin: 1.5em; color: #222; }
+h1 { font-size: 1.4em; margin-bottom: 0.2em; }
+h2 { font-size: 1.1em; margin-top: 1.5em; }
+table { border-collapse: collapse; min-width: 40em; }
+th, td { text-align: left; padding: 0.25em 0.8em; border-bottom: 1px solid #ddd; }
+th { background: #f4f4f4; }
+.ok, .up { color: #1a7f37; }
+.degraded, .expiring { color: #b58100; }
+.down, .failed, .expired { color: #c62828; }
+#message { margin-top: 1em; min-height: 1.2em; }
+#events td { font-family: monospace; font-size: 0.9em; }
+button { margin-right: 0.5em; }
+</style>
+</head>
+<body>
+<h1>ArangoDB Starter <span id="starter"></span></h1>
+<div>Health: <b id="health">loading...</b></div>
+<div id="message"></div>
+
+<h2>Servers</h2>
+<table>
+<thead><tr><th>Type</th><th>Address</th><th>Status</th><th>Version</th><th>Restarts</th><th></th></tr></thead>
+<tbody id="servers"></tbody>
+</table>
+<p><button id="rotate">Rotate log files</button></p>
+
+<h2>Peers</h2>
+<table>
+<thead><tr><th>ID</th><th>Address</th><th>Roles</th></tr></thead>
+<tbody id="peers"></tbody>
+</table>
+
+<h2>Recent events</h2>
+<table>
+<thead><tr><th>Time</th><th>Type</th><th>Details</th></tr></thead>
+<tbody id="events"></tbody>
+</table>
+
+<script>
+"use strict";
+var maxEvents = 50;
+
+function el(tag, text, cls) {
+  var e = document.createElement(tag);
+  if (text !== undefined && text !== null) { e.textContent = String(text); }
+  if (cls) { e.className = cls; }
+  return e;
+}
+
+function row(cells) {
+  var tr = el("tr");
+  cells.forEach(function (c) {
+    if (c instanceof Node) { var td = el("td"); td.appendChild(c); tr.appendChild(td); }
+    else { tr.appendChild(el("td", c)); }
+  });
+  return tr;
+}
+
+function fill(id, rows) {
+  var body = document.getElementById(id);
+  while (body.firstChild) { body.removeChild(body.firstChild); }
+  rows.forEach(function (r) { body.appendChild(r); });
+}
+
+function message(text, cls) {
+  var m = document.getElementById("message");
+  m.textContent = text;
+  m.className = cls || "";
+}
+
+function getJSON(path) {
+  return fetch(path, { headers: { "Accept": "application/json" } }).then(function (resp) {
+    return resp.json();
+  });
+}
+
+function post(path, label) {
+  message(label + "...");
+  return fetch(path, { method: "POST" }).then(function (resp) {
+    return resp.text().then(function (body) {
+      if (!resp.ok) {
+        var reason = body;
+        try { reason = JSON.parse(body).error || body; } catch (e) {}
+        throw new Error(reason);
+      }
+      message(label + " done", "ok");
+      refresh();
+    });
+  }).catch(function (err) {
+    message(label + " failed: " + err.message, "failed");
+  });
+}
+
+function refresh() {
+  Promise.all([getJSON("/status"), getJSON("/health")]).then(function (res) {
+    var status = res[0], health = res[1];
+    document.getElementById("starter").textContent =
+      status.id + " (" + status.mode + ", version " + status.version + ", build " + status.build + ")";
+    var h = document.getElementById("health");
+    h.textContent = health.status;
+    h.className = health.status;
+    var healthByType = {};
+    (health.servers || []).forEach(function (s) { healthByType[s.type] = s; });
+    fill("servers", (status.servers || []).map(function (s) {
+      var sh = healthByType[s.type] || { status: s.up ? "ok" : "down" };
+      var restart = el("button", "Restart");
+      restart.onclick = function () {
+        if (confirm("Restart the " + s.type + "?")) {
+          post("/server/restart?type=" + encodeURIComponent(s.type), "Restarting " + s.type);
+        }
+      };
+      return row([s.type, s.ip + ":" + s.port, el("span", sh.status + (sh.reason ? " (" + sh.reason + ")" : ""), sh.status),
+        s.version || "", s.restarts || 0, restart]);
+    }));
+    fill("peers", (status.peers || []).map(function (p) {
+      var roles = [];
+      if (p["is-master"]) { roles.push("master"); }
+      if (p["has-agent"]) { roles.push("agent"); }
+      if (p["is-standby"]) { roles.push("standby"); }
+      if (p["is-passive"]) { roles.push("passive"); }
+      return row([p.id, p.address + ":" + p.port, roles.join(", ")]);
+    }));
+  }).catch(function (err) {
+    message("Cannot reach the starter: " + err.message, "failed");
+  });
+}
+
+function addEvent(e) {
+  var details = [];
+  ["server-type", "version", "peer-id", "address", "path", "reason"].forEach(function (k) {
+    if (e[k]) { details.push(k + "=" + e[k]); }
+  });
+  var body = document.getElementById("events");
+  body.insertBefore(row([new Date(e.time).toLocaleString(), e.type, details.join(" ")]), body.firstChild);
+  while (body.childNodes.length > maxEvents) { body.removeChild(body.lastChild); }
+  refresh();
+}
+
+function watchEvents() {
+  fetch("/events").then(function (resp) {
+    var reader = resp.body.getReader(), decoder = new TextDecoder(), buffer = "";
+    function read() {
+      return reader.read().then(function (chunk) {
+        if (chunk.done) { throw new Error("event stream closed"); }
+        buffer += decoder.decode(chunk.value, { stream: true });
+        var lines = buffer.split("\n");
+        buffer = lines.pop();
+        lines.forEach(function (line) {
+          if (line.trim() !== "") { addEvent(JSON.parse(line)); }
+        });
+        return read();
+      });
+    }
+    return read();
+  }).catch(function () {
+    setTimeout(watchEvents, 5000);
+  });
+}
+
+document.getElementById("rotate").onclick = function () { post("/logs/rotate", "Rotating log files"); };
+refresh();
+setInterval(refresh, 10000);
+watchEvents();
+</script>
+</body>
+</html>
+`
@@ -0,0 +1,245 @@
+//
+// DISCLAIMER
+//
+// Copyright 2017 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+// Author Ewout Prangsma
+//
+
+package service
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	logging "github.com/op/go-logging"
+)
+
+const (
+	recentStarterLogLines  = 1000 // Number of most recent log lines of the starter kept for diagnostics
+	diagnosticsServerLines = 1000 // Number of most recent log lines of each server added to a diagnostics bundle
+	redacted               = RedactedValue
+)
+
+// DiagnosticsInfo describes the content of a diagnostics bundle.
+// It is added to the bundle as `diagnostics.json`.
+type DiagnosticsInfo struct {
+	ID      string            `json:"id"`               // ID of the starter that created the bundle
+	Version string            `json:"version"`          // Version of the starter
+	Build   string            `json:"build"`            // Build of the starter
+	Time    time.Time         `json:"time"`             // Time the bundle was created
+	Files   []string          `json:"files"`            // Names of all files in the bundle
+	Errors  map[string]string `json:"errors,omitempty"` // Errors per file that could not be gathered
+}
+
+// recentLogBackend is a logging backend that keeps the most recent log lines in memory.
+type recentLogBackend struct {
+	mutex    sync.Mutex
+	lines    []string
+	maxLines int
+}
+
+// newRecentLogBackend creates a backend keeping at most maxLines log lines.
+func newRecentLogBackend(maxLines int) *recentLogBackend {
+	return &recentLogBackend{maxLines: maxLines}
+}
+
+// Log implements logging.Backend.
+func (b *recentLogBackend) Log(level logging.Level, calldepth int, rec *logging.Record) error {
+	line := fmt.Sprintf("%s %s [%s] %s\n", rec.Time.Format(time.RFC3339), level, rec.Module, rec.Message())
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.lines = append(b.lines, line)
+	if len(b.lines) > b.maxLines {
+		b.lines = b.lines[len(b.lines)-b.maxLines:]
+	}
+	return nil
+}
+
+// String returns all log lines kept by the backend.
+func (b *recentLogBackend) String() string {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return strings.Join(b.lines, "")
+}
+
+// diagnosticsHandler writes a diagnostics bundle (tar.gz) of this starter.
+func (s *Service) diagnosticsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		writeError(w, http.StatusMethodNotAllowed, "POST required")
+		return
+	}
+	s.apiLog.Info("Creating diagnostics bundle")
+	w.Header().Set("Content-Type", "application/gzip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"arangodb-diagnostics-%s.tar.gz\"", s.ID))
+	w.WriteHeader(http.StatusOK)
+	if err := s.writeDiagnostics(r.Context(), w); err != nil {
+		s.apiLog.Errorf("Failed to write diagnostics bundle: %v", err)
+	}
+}
+
+// writeDiagnostics gathers the logs of the starter, its (redacted) setup & configuration,
+// the most recent logs of its servers, an agency dump and its process list and writes
+// them as a tar.gz bundle to the given writer.
+// Parts that cannot be gathered are listed in `diagnostics.json`.
+func (s *Service) writeDiagnostics(ctx context.Context, w io.Writer) error {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	now := time.Now()
+	info := DiagnosticsInfo{
+		ID:      s.ID,
+		Version: s.ProjectVersion,
+		Build:   s.ProjectBuild,
+		Time:    now,
+	}
+	addFile := func(name string, content []byte) error {
+		if err := tw.WriteHeader(&tar.Header{
+			Name:    name,
+			Mode:    0644,
+			Size:    int64(len(content)),
+			ModTime: now,
+		}); err != nil {
+			return maskAny(err)
+		}
+		if _, err := tw.Write(content); err != nil {
+			return maskAny(err)
+		}
+		info.Files = append(info.Files, name)
+		return nil
+	}
+	addError := func(name string, err error) {
+		if info.Errors == nil {
+			info.Errors = make(map[string]string)
+		}
+		info.Errors[name] = err.Error()
+	}
+	addJSONFile := func(name string, v interface{}) error {
+		encoded, err := json.MarshalIndent(v, "", "  ")
+		if err != nil {
+			addError(name, err)
+			return nil
+		}
+		return maskAny(addFile(name, encoded))
+	}
+
+	// Starter
+	if err := addFile("starter.log", []byte(s.recentLog.String())); err != nil {
+		return maskAny(err)
+	}
+	if err := addJSONFile("config.json", s.redactedConfig()); err != nil {
+		return maskAny(err)
+	}
+	if content, err := ioutil.ReadFile(filepath.Join(s.DataDir, setupFileName)); err != nil {
+		addError(setupFileName, err)
+	} else {
+		var setup SetupConfigFile
+		if err := json.Unmarshal(content, &setup); err != nil {
+			addError(setupFileName, err)
+		} else {
+			if _, found := setup.InputDigests[inputJwtSecret]; found {
+				setup.InputDigests[inputJwtSecret] = redacted
+			}
+			if err := addJSONFile(setupFileName, setup); err != nil {
+				return maskAny(err)
+			}
+		}
+	}
+	if entries, err := s.audit.read(s.auditLogPath(), 0); err != nil {
+		addError("audit-log.json", err)
+	} else if err := addJSONFile("audit-log.json", entries); err != nil {
+		return maskAny(err)
+	}
+	resp := ProcessListResponse{}
+	if myPeer, found := s.myPeers.PeerByID(s.ID); found {
+		resp.Servers = s.serverProcesses(myPeer)
+	}
+	if err := addJSONFile("processes.json", resp); err != nil {
+		return maskAny(err)
+	}
+
+	// Servers
+	for _, serverType := range []ServerType{ServerTypeAgent, ServerTypeDBServer, ServerTypeCoordinator, ServerTypeSingle} {
+		if s.serverProcess(serverType) == nil {
+			continue
+		}
+		myHostDir, err := s.serverHostDir(serverType)
+		if err != nil {
+			addError(serverType.String(), err)
+			continue
+		}
+		logName := filepath.ToSlash(filepath.Join(serverType.String(), logFileName))
+		if lines, err := readRecentLogLines(filepath.Join(myHostDir, logFileName), diagnosticsServerLines); err != nil {
+			addError(logName, err)
+		} else if err := addFile(logName, []byte(strings.Join(lines, ""))); err != nil {
+			return maskAny(err)
+		}
+		confName := filepath.ToSlash(filepath.Join(serverType.String(), confFileName))
+		if conf, err := ioutil.ReadFile(filepath.Join(myHostDir, confFileName)); err != nil {
+			addError(confName, err)
+		} else if err := addFile(confName, []byte(jwtSecretConfigLine.ReplaceAllString(string(conf), "$1 "+redacted))); err != nil {
+			return maskAny(err)
+		}
+	}
+
+	// Agency
+	if s.servers.agentProc != nil {
+		agencyCtx, cancel := context.WithTimeout(ctx, agencyDumpTimeout)
+		dump, err := s.agencyDump(agencyCtx, "/")
+		cancel()
+		if err != nil {
+			addError("agency-dump.json", err)
+		} else if err := addFile("agency-dump.json", dump); err != nil {
+			return maskAny(err)
+		}
+	}
+
+	if err := addJSONFile("diagnostics.json", info); err != nil {
+		return maskAny(err)
+	}
+	if err := tw.Close(); err != nil {
+		return maskAny(err)
+	}
+	if err := gz.Close(); err != nil {
+		return maskAny(err)
+	}
+	return nil
+}
+
+// redactedConfig returns the configuration of the starter with all secrets removed.
+func (s *Service) redactedConfig() Config {
+	config := s.Config
+	redactFields(&config)
+	if config.LicenseKey != "" {
+		config.LicenseKey = redacted
+	}
+	config.PassthroughOptions = make([]PassthroughOption, len(s.PassthroughOptions))
+	for i, o := range s.PassthroughOptions {
+		o.Value, _ = RedactValue(o.Name, o.Value)
+		config.PassthroughOptions[i] = o
+	}
+	return config
+}
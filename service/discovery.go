@@ -0,0 +1,209 @@
+//
+// DISCLAIMER
+//
+// Copyright 2017 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+// Author Ewout Prangsma
+//
+
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultDiscoveryPrefix = "/arangodb"
+)
+
+// discovery is an external coordination store (etcd or Consul) used to elect the master.
+type discovery interface {
+	// ClaimMaster registers the given candidate as master, unless another master has
+	// already been registered. Returns the address of the master.
+	ClaimMaster(ctx context.Context, candidate string) (string, error)
+}
+
+// newDiscovery creates a discovery for the given URI (etcd://host:port/prefix or consul://host:port/prefix).
+func newDiscovery(uri string) (discovery, error) {
+	u, err := ParseDiscoveryURI(uri)
+	if err != nil {
+		return nil, maskAny(err)
+	}
+	prefix := strings.TrimSuffix(u.Path, "/")
+	if prefix == "" {
+		prefix = defaultDiscoveryPrefix
+	}
+	switch u.Scheme {
+	case "etcd":
+		return &etcdDiscovery{endpoint: "http://" + u.Host, key: path.Join(prefix, "master")}, nil
+	default:
+		return &consulDiscovery{endpoint: "http://" + u.Host, key: strings.TrimPrefix(path.Join(prefix, "master"), "/")}, nil
+	}
+}
+
+// ParseDiscoveryURI parses the given discovery URI (etcd://host:port/prefix or consul://host:port/prefix).
+func ParseDiscoveryURI(uri string) (*url.URL, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, maskAny(err)
+	}
+	if u.Scheme != "etcd" && u.Scheme != "consul" {
+		return nil, maskAny(fmt.Errorf("Unsupported discovery '%s', expected etcd://host:port/prefix or consul://host:port/prefix", uri))
+	}
+	if u.Host == "" {
+		return nil, maskAny(fmt.Errorf("Missing host in discovery '%s'", uri))
+	}
+	return u, nil
+}
+
+// discoverMaster claims the master role in the discovery store.
+// When another starter is master, its address is stored in MasterAddress.
+func (s *Service) discoverMaster() {
+	d, err := newDiscovery(s.Discovery)
+	if err != nil {
+		s.peersLog.Fatalf("Invalid discovery: %v", err)
+	}
+	candidate := net.JoinHostPort(s.OwnAddress, strconv.Itoa(s.announcePort))
+	for {
+		ctx, cancel := context.WithTimeout(s.ctx, time.Second*10)
+		master, err := d.ClaimMaster(ctx, candidate)
+		cancel()
+		if err == nil {
+			if master != candidate {
+				s.peersLog.Infof("Discovered master %s using %s", master, s.Discovery)
+				s.MasterAddress = master
+			} else {
+				s.peersLog.Infof("Elected as master using %s", s.Discovery)
+			}
+			return
+		}
+		if s.ctx.Err() != nil {
+			return
+		}
+		s.peersLog.Warningf("Cannot claim master role using %s, retrying: %v", s.Discovery, err)
+		time.Sleep(time.Second * 2)
+	}
+}
+
+// etcdDiscovery uses the v3 JSON API of etcd.
+type etcdDiscovery struct {
+	endpoint string
+	key      string
+}
+
+// ClaimMaster puts the candidate into the master key in a transaction that only succeeds
+// if the key does not exist yet, otherwise the existing value is read.
+func (d *etcdDiscovery) ClaimMaster(ctx context.Context, candidate string) (string, error) {
+	key := base64.StdEncoding.EncodeToString([]byte(d.key))
+	txn := map[string]interface{}{
+		"compare": []interface{}{
+			map[string]interface{}{"key": key, "target": "CREATE", "create_revision": "0"},
+		},
+		"success": []interface{}{
+			map[string]interface{}{"request_put": map[string]interface{}{"key": key, "value": base64.StdEncoding.EncodeToString([]byte(candidate))}},
+		},
+		"failure": []interface{}{
+			map[string]interface{}{"request_range": map[string]interface{}{"key": key}},
+		},
+	}
+	body, _ := json.Marshal(txn)
+	content, err := discoveryRequest(ctx, "POST", d.endpoint+"/v3/kv/txn", body)
+	if err != nil {
+		return "", maskAny(err)
+	}
+	var resp struct {
+		Succeeded bool `json:"succeeded"`
+		Responses []struct {
+			ResponseRange struct {
+				Kvs []struct {
+					Value string `json:"value"`
+				} `json:"kvs"`
+			} `json:"response_range"`
+		} `json:"responses"`
+	}
+	if err := json.Unmarshal(content, &resp); err != nil {
+		return "", maskAny(err)
+	}
+	if resp.Succeeded {
+		return candidate, nil
+	}
+	if len(resp.Responses) == 0 || len(resp.Responses[0].ResponseRange.Kvs) == 0 {
+		return "", maskAny(fmt.Errorf("Master key %s has disappeared", d.key))
+	}
+	value, err := base64.StdEncoding.DecodeString(resp.Responses[0].ResponseRange.Kvs[0].Value)
+	if err != nil {
+		return "", maskAny(err)
+	}
+	return string(value), nil
+}
+
+// consulDiscovery uses the KV API of Consul.
+type consulDiscovery struct {
+	endpoint string
+	key      string
+}
+
+// ClaimMaster puts the candidate into the master key using a check-and-set that only succeeds
+// if the key does not exist yet, otherwise the existing value is read.
+func (d *consulDiscovery) ClaimMaster(ctx context.Context, candidate string) (string, error) {
+	keyURL := d.endpoint + "/v1/kv/" + d.key
+	content, err := discoveryRequest(ctx, "PUT", keyURL+"?cas=0", []byte(candidate))
+	if err != nil {
+		return "", maskAny(err)
+	}
+	if strings.TrimSpace(string(content)) == "true" {
+		return candidate, nil
+	}
+	value, err := discoveryRequest(ctx, "GET", keyURL+"?raw", nil)
+	if err != nil {
+		return "", maskAny(err)
+	}
+	return string(value), nil
+}
+
+// discoveryRequest performs a request to a discovery store and returns the body of its response.
+func discoveryRequest(ctx context.Context, method, url string, body []byte) ([]byte, error) {
+	req, err := http.NewRequest(method, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, maskAny(err)
+	}
+	resp, err := httpClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, maskAny(err)
+	}
+	defer resp.Body.Close()
+	content, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, maskAny(err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, maskAny(fmt.Errorf("Invalid status %d from %s", resp.StatusCode, url))
+	}
+	return content, nil
+}
@@ -0,0 +1,176 @@
+//
+// DISCLAIMER
+//
+// Copyright 2017 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+// Author Ewout Prangsma
+//
+
+package service
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// DiskStatusLow is the status of a directory whose free disk space is below the minimum, as reported by `/health`.
+	DiskStatusLow = "low"
+)
+
+// DiskHealth holds the free disk space of a directory used by the servers, as reported by `/health`.
+type DiskHealth struct {
+	Path    string `json:"path"`     // Directory (the data directory or a --<type>s.dir)
+	Status  string `json:"status"`   // ok | low
+	Free    uint64 `json:"free"`     // Free disk space in bytes
+	MinFree uint64 `json:"min-free"` // Minimum free disk space in bytes (--disk.min-free)
+}
+
+// diskMonitor holds the last known free disk space of all directories used by the servers.
+type diskMonitor struct {
+	mutex sync.Mutex
+	disks map[string]DiskHealth
+}
+
+// get returns the last known free disk space of all directories, sorted by path.
+func (m *diskMonitor) get() []DiskHealth {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	result := make([]DiskHealth, 0, len(m.disks))
+	for _, d := range m.disks {
+		result = append(result, d)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Path < result[j].Path })
+	return result
+}
+
+// set stores the given free disk space of a directory and returns its previous status (if any).
+func (m *diskMonitor) set(d DiskHealth) string {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if m.disks == nil {
+		m.disks = make(map[string]DiskHealth)
+	}
+	previous := m.disks[d.Path].Status
+	m.disks[d.Path] = d
+	return previous
+}
+
+// diskDirs returns the directories used by the servers of this starter:
+// the data directory and the directories given for server types (if any).
+func (s *Service) diskDirs() []string {
+	dirs := []string{s.DataDir}
+	seen := map[string]bool{s.DataDir: true}
+	for _, dir := range s.ServerDirs {
+		if !seen[dir] {
+			seen[dir] = true
+			dirs = append(dirs, dir)
+		}
+	}
+	sort.Strings(dirs[1:])
+	return dirs
+}
+
+// checkDiskSpace measures the free disk space of all directories used by the servers.
+// Directories that do not exist yet are measured at their nearest existing parent.
+func (s *Service) checkDiskSpace() ([]DiskHealth, error) {
+	var result []DiskHealth
+	for _, dir := range s.diskDirs() {
+		path := dir
+		for {
+			if _, err := os.Stat(path); err == nil || filepath.Dir(path) == path {
+				break
+			}
+			path = filepath.Dir(path)
+		}
+		free, err := freeDiskSpace(path)
+		if err != nil {
+			return nil, maskAny(err)
+		}
+		d := DiskHealth{Path: dir, Status: HealthOK, Free: free, MinFree: s.DiskMinFree}
+		if free < s.DiskMinFree {
+			d.Status = DiskStatusLow
+		}
+		result = append(result, d)
+	}
+	return result, nil
+}
+
+// checkDiskSpaceBeforeStart refuses to start the servers when a directory used by them
+// has less free disk space than the configured minimum.
+func (s *Service) checkDiskSpaceBeforeStart() {
+	if s.DiskMinFree == 0 || s.isLocalSlave {
+		return
+	}
+	disks, err := s.checkDiskSpace()
+	if err != nil {
+		s.log.Warningf("Cannot check free disk space: %v", err)
+		return
+	}
+	for _, d := range disks {
+		s.diskSpace.set(d)
+		if d.Status == DiskStatusLow {
+			s.log.Fatal(newLogEvent("disk-space-low", LogFields{"path": d.Path, "free": d.Free, "min-free": d.MinFree},
+				"Only %s of disk space is free in %s, at least %s is needed (--disk.min-free)", formatDiskSize(d.Free), d.Path, formatDiskSize(d.MinFree)))
+		}
+	}
+}
+
+// watchDiskSpace periodically checks the free disk space of all directories used by the servers,
+// until the starter is stopped. A warning is logged & an event is published when the free disk space
+// drops below the minimum, and again when it has recovered.
+func (s *Service) watchDiskSpace() {
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-time.After(s.DiskCheckInterval):
+		}
+		disks, err := s.checkDiskSpace()
+		if err != nil {
+			s.log.Debugf("Cannot check free disk space: %v", err)
+			continue
+		}
+		for _, d := range disks {
+			previous := s.diskSpace.set(d)
+			if d.Status == previous {
+				continue
+			}
+			if d.Status == DiskStatusLow {
+				reason := fmt.Sprintf("Only %s of disk space is free in %s, less than %s (--disk.min-free)", formatDiskSize(d.Free), d.Path, formatDiskSize(d.MinFree))
+				s.log.Warning(newLogEvent("disk-space-low", LogFields{"path": d.Path, "free": d.Free, "min-free": d.MinFree}, "%s", reason))
+				s.events.publish(ProcessEvent{Type: ProcessEventDiskSpaceLow, Path: d.Path, Reason: reason})
+			} else if previous == DiskStatusLow {
+				s.log.Info(newLogEvent("disk-space-ok", LogFields{"path": d.Path, "free": d.Free, "min-free": d.MinFree},
+					"Free disk space in %s has recovered to %s", d.Path, formatDiskSize(d.Free)))
+				s.events.publish(ProcessEvent{Type: ProcessEventDiskSpaceOK, Path: d.Path})
+			}
+		}
+	}
+}
+
+// formatDiskSize formats the given number of bytes in MiB or GiB.
+func formatDiskSize(size uint64) string {
+	if size >= gib {
+		return fmt.Sprintf("%.1fGiB", float64(size)/float64(gib))
+	}
+	return fmt.Sprintf("%dMiB", size/mib)
+}
@@ -0,0 +1,33 @@
+//
+// DISCLAIMER
+//
+// Copyright 2017 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+// Author Ewout Prangsma
+//
+
+//go:build !linux && !darwin
+// +build !linux,!darwin
+
+package service
+
+import "fmt"
+
+// freeDiskSpace returns an error, since detecting the free disk space is only supported on Linux & macOS.
+func freeDiskSpace(path string) (uint64, error) {
+	return 0, maskAny(fmt.Errorf("Detecting the free disk space is not supported on this platform"))
+}
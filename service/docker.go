@@ -58,3 +58,27 @@ func findDockerExposedAddress(dockerEndpoint, containerName string, port int) (h
 	}
 	return hostPort, isNetHost, networkMode, nil
 }
+
+// findDockerImageID pulls the given image (unless offline is set) and returns its ID.
+func findDockerImageID(dockerEndpoint, image string, offline bool) (string, error) {
+	client, err := docker.NewClient(dockerEndpoint)
+	if err != nil {
+		return "", maskAny(err)
+	}
+	if !offline {
+		repo, tag := docker.ParseRepositoryTag(image)
+		if err := client.PullImage(docker.PullImageOptions{
+			Repository: repo,
+			Tag:        tag,
+		}, docker.AuthConfiguration{}); err != nil {
+			return "", maskAny(fmt.Errorf("Failed to pull image %s: %v", image, err))
+		}
+	}
+	img, err := client.InspectImage(image)
+	if err == docker.ErrNoSuchImage && offline {
+		return "", maskAny(OfflineImageError(image))
+	} else if err != nil {
+		return "", maskAny(err)
+	}
+	return img.ID, nil
+}
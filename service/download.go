@@ -0,0 +1,282 @@
+//
+// DISCLAIMER
+//
+// Copyright 2017 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+// Author Ewout Prangsma
+//
+
+package service
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+)
+
+const (
+	// DefaultDownloadURL is the base URL of the server hosting the official ArangoDB downloads.
+	DefaultDownloadURL = "https://download.arangodb.com"
+)
+
+var (
+	downloadVersionPattern = regexp.MustCompile(`^(\d+)\.(\d+)\.\d+(-[0-9A-Za-z.]+)?$`)
+)
+
+// DownloadArangodOptions configures which arangod binary to download and where to keep it.
+type DownloadArangodOptions struct {
+	Version  string // Version of ArangoDB to download (e.g. 3.2.4)
+	URL      string // Base URL of the download server
+	SHA256   string // Expected SHA256 digest of the archive. If empty, the digest published next to the archive is used
+	CacheDir string // Directory in which all downloaded versions are kept
+}
+
+// ValidateDownloadVersion checks that the given version can be downloaded.
+func ValidateDownloadVersion(version string) error {
+	if !downloadVersionPattern.MatchString(version) {
+		return maskAny(fmt.Errorf("Invalid version '%s', expected <major>.<minor>.<patch>", version))
+	}
+	return nil
+}
+
+// downloadArchiveURL returns the URL of the archive containing the official binaries of the given version
+// for the current platform.
+func downloadArchiveURL(baseURL, version string) (string, error) {
+	m := downloadVersionPattern.FindStringSubmatch(version)
+	if m == nil {
+		return "", maskAny(ValidateDownloadVersion(version))
+	}
+	var platform, folder string
+	switch runtime.GOOS {
+	case "linux":
+		platform, folder = "linux", "Linux"
+	case "darwin":
+		platform, folder = "macos", "MacOSX"
+	default:
+		return "", maskAny(fmt.Errorf("Downloading arangod is not supported on %s", runtime.GOOS))
+	}
+	return fmt.Sprintf("%s/arangodb%s%s/Community/%s/arangodb3-%s-%s.tar.gz", strings.TrimSuffix(baseURL, "/"), m[1], m[2], folder, platform, version), nil
+}
+
+// FindDownloadedArangod returns the path of the arangod executable & JS directory of the given version,
+// if that version has been downloaded into the cache directory before.
+func FindDownloadedArangod(options DownloadArangodOptions) (arangodPath, jsPath string, found bool) {
+	versionDir := filepath.Join(options.CacheDir, options.Version)
+	if _, err := os.Stat(versionDir); err != nil {
+		return "", "", false
+	}
+	arangodPath, jsPath, err := findInstallation(versionDir)
+	if err != nil {
+		return "", "", false
+	}
+	return arangodPath, jsPath, true
+}
+
+// DownloadArangod ensures that the official arangod binary of the requested version is available
+// in the cache directory. If not, the archive of that version is downloaded, its SHA256 digest is verified
+// and it is extracted into the cache directory.
+// Returns the path of the arangod executable & JS directory.
+func DownloadArangod(ctx context.Context, options DownloadArangodOptions) (arangodPath, jsPath string, err error) {
+	if arangodPath, jsPath, found := FindDownloadedArangod(options); found {
+		return arangodPath, jsPath, nil
+	}
+	archiveURL, err := downloadArchiveURL(options.URL, options.Version)
+	if err != nil {
+		return "", "", maskAny(err)
+	}
+	if err := os.MkdirAll(options.CacheDir, 0755); err != nil {
+		return "", "", maskAny(err)
+	}
+
+	// Fetch expected digest
+	expected := strings.ToLower(strings.TrimSpace(options.SHA256))
+	if expected == "" {
+		content, err := downloadFile(ctx, archiveURL+".sha256")
+		if err != nil {
+			return "", "", maskAny(fmt.Errorf("Cannot fetch SHA256 digest of %s: %v", archiveURL, err))
+		}
+		fields := strings.Fields(string(content))
+		if len(fields) == 0 {
+			return "", "", maskAny(fmt.Errorf("Empty SHA256 digest of %s", archiveURL))
+		}
+		expected = strings.ToLower(fields[0])
+	}
+
+	// Download archive
+	archive, err := ioutil.TempFile(options.CacheDir, ".download-")
+	if err != nil {
+		return "", "", maskAny(err)
+	}
+	defer os.Remove(archive.Name())
+	defer archive.Close()
+	hash := sha256.New()
+	if err := downloadTo(ctx, archiveURL, io.MultiWriter(archive, hash)); err != nil {
+		return "", "", maskAny(fmt.Errorf("Cannot download %s: %v", archiveURL, err))
+	}
+	if actual := hex.EncodeToString(hash.Sum(nil)); actual != expected {
+		return "", "", maskAny(fmt.Errorf("SHA256 digest of %s is %s, expected %s", archiveURL, actual, expected))
+	}
+
+	// Extract archive into a temporary directory, then move it in place
+	tmpDir, err := ioutil.TempDir(options.CacheDir, ".extract-")
+	if err != nil {
+		return "", "", maskAny(err)
+	}
+	defer os.RemoveAll(tmpDir)
+	if _, err := archive.Seek(0, io.SeekStart); err != nil {
+		return "", "", maskAny(err)
+	}
+	if err := extractTarGz(archive, tmpDir); err != nil {
+		return "", "", maskAny(fmt.Errorf("Cannot extract %s: %v", archiveURL, err))
+	}
+	if _, _, err := findInstallation(tmpDir); err != nil {
+		return "", "", maskAny(err)
+	}
+	versionDir := filepath.Join(options.CacheDir, options.Version)
+	if err := os.Rename(tmpDir, versionDir); err != nil {
+		return "", "", maskAny(err)
+	}
+	arangodPath, jsPath, err = findInstallation(versionDir)
+	if err != nil {
+		return "", "", maskAny(err)
+	}
+	return arangodPath, jsPath, nil
+}
+
+// downloadFile returns the content of the file at given URL.
+func downloadFile(ctx context.Context, url string) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := downloadTo(ctx, url, &buf); err != nil {
+		return nil, maskAny(err)
+	}
+	return buf.Bytes(), nil
+}
+
+// downloadTo writes the content of the file at given URL to the given writer.
+func downloadTo(ctx context.Context, url string, w io.Writer) error {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return maskAny(err)
+	}
+	resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return maskAny(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return maskAny(fmt.Errorf("Invalid status %d", resp.StatusCode))
+	}
+	if _, err := io.Copy(w, resp.Body); err != nil {
+		return maskAny(err)
+	}
+	return nil
+}
+
+// extractTarGz extracts the given tar.gz archive into the given directory.
+// Entries that would end up outside of that directory are refused.
+func extractTarGz(r io.Reader, dir string) error {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return maskAny(err)
+	}
+	defer gz.Close()
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return maskAny(err)
+		}
+		target := filepath.Join(dir, filepath.FromSlash(hdr.Name))
+		if target != dir && !strings.HasPrefix(target, dir+string(filepath.Separator)) {
+			return maskAny(fmt.Errorf("Archive entry '%s' is outside of the archive", hdr.Name))
+		}
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return maskAny(err)
+			}
+		case tar.TypeReg, tar.TypeRegA:
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return maskAny(err)
+			}
+			f, err := os.OpenFile(target, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, os.FileMode(hdr.Mode)&0755)
+			if err != nil {
+				return maskAny(err)
+			}
+			if _, err := io.Copy(f, tr); err != nil {
+				f.Close()
+				return maskAny(err)
+			}
+			if err := f.Close(); err != nil {
+				return maskAny(err)
+			}
+		case tar.TypeSymlink:
+			if linkTarget := filepath.Join(filepath.Dir(target), filepath.FromSlash(hdr.Linkname)); filepath.IsAbs(hdr.Linkname) || !strings.HasPrefix(linkTarget, dir+string(filepath.Separator)) {
+				return maskAny(fmt.Errorf("Archive entry '%s' links outside of the archive", hdr.Name))
+			}
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return maskAny(err)
+			}
+			if err := os.Symlink(hdr.Linkname, target); err != nil {
+				return maskAny(err)
+			}
+		}
+	}
+}
+
+// findInstallation looks for the arangod executable & JS directory in the given extracted archive.
+func findInstallation(dir string) (arangodPath, jsPath string, err error) {
+	walkErr := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		switch {
+		case arangodPath == "" && info.Mode().IsRegular() && info.Name() == "arangod" && info.Mode()&0111 != 0:
+			if parent := filepath.Base(filepath.Dir(path)); parent == "bin" || parent == "sbin" {
+				arangodPath = path
+			}
+		case jsPath == "" && info.IsDir() && strings.HasSuffix(filepath.ToSlash(path), "/share/arangodb3/js"):
+			jsPath = path
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	if walkErr != nil {
+		return "", "", maskAny(walkErr)
+	}
+	if arangodPath == "" {
+		return "", "", maskAny(fmt.Errorf("Cannot find arangod executable in %s", dir))
+	}
+	if jsPath == "" {
+		return "", "", maskAny(fmt.Errorf("Cannot find JS directory in %s", dir))
+	}
+	return arangodPath, jsPath, nil
+}
@@ -0,0 +1,264 @@
+//
+// DISCLAIMER
+//
+// Copyright 2017 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+// Author Ewout Prangsma
+//
+
+package service
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strconv"
+)
+
+var (
+	jwtSecretConfigLine = regexp.MustCompile(`(?m)^(\s*jwt-secret\s*=).*$`)
+)
+
+// DryRunPlan is the result of a dry run: everything the starter would start.
+type DryRunPlan struct {
+	ID      string          `json:"id"`              // Unique ID of the starter
+	Mode    string          `json:"mode"`            // Starter mode (cluster | single)
+	Runner  string          `json:"runner"`          // Runner used to start servers (process | docker)
+	Peers   []PeerStatus    `json:"peers"`           // Peers known (or assumed) at startup
+	Servers []PlannedServer `json:"servers"`         // Servers that would be started
+	Notes   []string        `json:"notes,omitempty"` // Assumptions made while creating the plan
+}
+
+// PlannedServer holds everything needed to start a single server.
+type PlannedServer struct {
+	PeerID        string     `json:"peer-id"`                  // ID of the peer that starts the server
+	Type          string     `json:"type"`                     // agent | coordinator | dbserver | single
+	Port          int        `json:"port"`                     // Port the server listens on
+	DataDir       string     `json:"data-dir"`                 // Directory (on the host) holding all data of the server
+	Command       []string   `json:"command"`                  // Full command line, starting with the executable
+	ConfigFile    string     `json:"config-file"`              // Path (on the host) of the arangod configuration file
+	Config        string     `json:"config"`                   // Content of the arangod configuration file (secrets are redacted)
+	Volumes       []Volume   `json:"volumes,omitempty"`        // Volumes mapped into the container (docker only)
+	ContainerName string     `json:"container-name,omitempty"` // Name of the container (docker only)
+	Image         string     `json:"image,omitempty"`          // Image of the container (docker only)
+	Placement     *Placement `json:"placement,omitempty"`      // CPUs & NUMA nodes the server is restricted to (if any)
+}
+
+// DryRun resolves the configuration of the service and returns all servers it would start,
+// without starting anything or changing anything on disk.
+func (s *Service) DryRun() (DryRunPlan, error) {
+	runner, useDockerRunner := s.createRunner()
+	plan := DryRunPlan{
+		Mode:   s.Mode,
+		Runner: "process",
+	}
+	if useDockerRunner {
+		plan.Runner = "docker"
+	}
+
+	// Find the peers that exist at startup
+	startLocalSlaves := s.StartLocalSlaves
+	if cfg, found := s.readSetup(); found {
+		s.ID = cfg.ID
+		s.myPeers = cfg.Peers
+		s.AgencySize = cfg.Peers.AgencySize
+		startLocalSlaves = cfg.StartLocalSlaves
+		s.checkRecordedLocalServers(cfg.LocalServers)
+		plan.Notes = append(plan.Notes, fmt.Sprintf("Using the peers recorded in %s", filepath.Join(s.DataDir, setupFileName)))
+	} else {
+		notes, err := s.createDryRunPeers()
+		if err != nil {
+			return DryRunPlan{}, maskAny(err)
+		}
+		plan.Notes = append(plan.Notes, notes...)
+	}
+	if s.SslAutoKeyFile {
+		plan.Notes = append(plan.Notes, "A new self-signed certificate is created on every start, the keyfile shown is temporary")
+	}
+	if s.OwnAddress == "" {
+		plan.Notes = append(plan.Notes, "starter.address is not set, the address of this peer is detected once other peers join")
+	}
+	plan.ID = s.ID
+
+	// Plan the servers of all peers started by this process
+	for i, p := range s.myPeers.Peers {
+		plan.Peers = append(plan.Peers, PeerStatus{
+			ID:        p.ID,
+			Address:   p.Address,
+			Port:      p.Port,
+			HasAgent:  p.HasAgent,
+			IsMaster:  i == 0,
+			IsSecure:  p.IsSecure,
+			IsStandby: p.IsStandby,
+			IsPassive: p.IsPassive,
+		})
+		planner := s
+		if p.ID != s.ID {
+			if !startLocalSlaves {
+				continue
+			}
+			config := s.Config
+			config.ID = p.ID
+			config.DataDir = p.DataDir
+			config.StartLocalSlaves = false
+			s.applyLocalServers(&config, p.DataDir)
+			var err error
+			planner, err = NewService(config, asLocalSlave())
+			if err != nil {
+				return DryRunPlan{}, maskAny(err)
+			}
+			planner.myPeers = s.myPeers
+			planner.announcePort = s.announcePort
+		}
+		servers, err := planner.planServers(runner, p, useDockerRunner)
+		if err != nil {
+			return DryRunPlan{}, maskAny(err)
+		}
+		plan.Servers = append(plan.Servers, servers...)
+	}
+	return plan, nil
+}
+
+// createDryRunPeers creates the peers this starter would have once all
+// (local) peers have joined.
+// Returns notes about the assumptions made.
+func (s *Service) createDryRunPeers() ([]string, error) {
+	var notes []string
+	s.myPeers = peers{AgencySize: s.AgencySize, StorageEngine: s.ServerStorageEngine}
+	if s.isSingleMode() {
+		s.myPeers.AgencySize = 1
+	}
+	serverPorts, err := s.freeServerPorts(s.OwnAddress, !s.isSingleMode() && !s.Standby && !s.Passive)
+	if err != nil {
+		return nil, maskAny(err)
+	}
+	s.myPeers.Peers = []Peer{
+		Peer{
+			ID:          s.ID,
+			Address:     s.OwnAddress,
+			Port:        s.announcePort,
+			DataDir:     s.DataDir,
+			HasAgent:    !s.isSingleMode(),
+			IsSecure:    s.IsSecure(),
+			IsStandby:   s.Standby,
+			IsPassive:   s.Passive,
+			ServerPorts: serverPorts,
+		},
+	}
+	if s.Standby || s.Passive {
+		s.myPeers.Peers[0].HasAgent = false
+	}
+	if s.MasterAddress != "" {
+		notes = append(notes, fmt.Sprintf("The port offset, server ports and the agent of this peer are decided by the master at %s, assuming port offset 0", s.MasterAddress))
+		return notes, nil
+	}
+	if s.StartLocalSlaves {
+		address := s.OwnAddress
+		if address == "" {
+			// Local slaves register at the master using the loopback address, so does the master itself
+			address = "127.0.0.1"
+			s.myPeers.Peers[0].Address = address
+		}
+		for index := 2; index <= s.localPeerCount(); index++ {
+			id, err := createUniqueID()
+			if err != nil {
+				return nil, maskAny(err)
+			}
+			portOffset := s.myPeers.GetFreePortOffset(address, s.AllPortOffsetsUnique, s.portLayout())
+			serverPorts, err := s.freeServerPorts(address, s.myPeers.AgentCount() < s.AgencySize)
+			if err != nil {
+				return nil, maskAny(err)
+			}
+			s.myPeers.Peers = append(s.myPeers.Peers, Peer{
+				ID:          id,
+				Address:     address,
+				Port:        s.announcePort + portOffset,
+				PortOffset:  portOffset,
+				DataDir:     filepath.Join(s.DataDir, fmt.Sprintf("local-slave-%d", index-1)),
+				HasAgent:    s.myPeers.AgentCount() < s.AgencySize,
+				IsSecure:    s.IsSecure(),
+				ServerPorts: serverPorts,
+			})
+		}
+		notes = append(notes, "The IDs of local slaves are generated when they start")
+	} else if s.isClusterMode() && s.AgencySize > 1 {
+		notes = append(notes, fmt.Sprintf("Only this peer is known, %d more peers have to join before servers are started", s.AgencySize-1))
+	}
+	return notes, nil
+}
+
+// planServers returns all servers that would be started for the given peer.
+func (s *Service) planServers(runner Runner, myPeer Peer, useDockerRunner bool) ([]PlannedServer, error) {
+	var serverTypes []ServerType
+	if s.isClusterMode() && myPeer.HasServers() {
+		if myPeer.HasAgent {
+			serverTypes = append(serverTypes, ServerTypeAgent)
+		}
+		if s.StartDBserver {
+			serverTypes = append(serverTypes, ServerTypeDBServer)
+		}
+		if s.StartCoordinator {
+			serverTypes = append(serverTypes, ServerTypeCoordinator)
+		}
+	} else if s.isSingleMode() {
+		serverTypes = append(serverTypes, ServerTypeSingle)
+	}
+
+	var result []PlannedServer
+	for _, serverType := range serverTypes {
+		myPort, err := s.serverPort(serverType)
+		if err != nil {
+			return nil, maskAny(err)
+		}
+		myHostDir, err := s.serverHostDir(serverType)
+		if err != nil {
+			return nil, maskAny(err)
+		}
+		args, vols, containerName := s.createServerCommand(runner, myPeer.Address, myHostDir, myPort, serverType, 0)
+		confPath := filepath.Join(myHostDir, confFileName)
+		var conf []byte
+		if content, err := ioutil.ReadFile(confPath); err == nil {
+			// Existing files are used as is
+			conf = content
+		} else {
+			var buf bytes.Buffer
+			s.createArangodConf(serverType, strconv.Itoa(myPort)).WriteTo(&buf)
+			conf = buf.Bytes()
+		}
+		server := PlannedServer{
+			PeerID:     myPeer.ID,
+			Type:       serverType.String(),
+			Port:       myPort,
+			DataDir:    myHostDir,
+			Command:    args,
+			ConfigFile: confPath,
+			Config:     jwtSecretConfigLine.ReplaceAllString(string(conf), "$1 "+redacted),
+		}
+		if placement := s.serverPlacement(serverType); !placement.IsEmpty() {
+			server.Placement = &placement
+		}
+		if useDockerRunner {
+			server.Volumes = vols
+			server.ContainerName = containerName
+			server.Image = s.serverImage(serverType)
+		}
+		result = append(result, server)
+	}
+	return result, nil
+}
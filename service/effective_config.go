@@ -0,0 +1,82 @@
+//
+// DISCLAIMER
+//
+// Copyright 2017 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+// Author Ewout Prangsma
+//
+
+package service
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+const (
+	ConfigSourceCommandLine = "command-line" // Option has been set on the command line
+	ConfigSourceConfigFile  = "config-file"  // Option has been set in the configuration file
+	ConfigSourceDefault     = "default"      // Option has its default value
+)
+
+// ConfigProvider returns the effective configuration of the starter.
+type ConfigProvider func() (ConfigResponse, error)
+
+// ConfigResponse is the JSON response of a `/config` request.
+type ConfigResponse struct {
+	ConfigFile string         `json:"config-file,omitempty"` // Path of the configuration file that has been loaded (if any)
+	Options    []ConfigOption `json:"options"`               // All options of the starter, sorted by name
+}
+
+// ConfigOption holds the effective value of a single option of the starter.
+type ConfigOption struct {
+	Name            string `json:"name"`                       // Name of the option (without leading dashes)
+	Value           string `json:"value"`                      // Effective value of the option
+	Default         string `json:"default,omitempty"`          // Default value of the option
+	Source          string `json:"source"`                     // command-line | config-file | default
+	Redacted        bool   `json:"redacted,omitempty"`         // If set, (part of) the value has been hidden, because it holds a secret
+	Passthrough     bool   `json:"passthrough,omitempty"`      // If set, the option is passed through to the servers
+	RestartRequired bool   `json:"restart-required,omitempty"` // If set, a change of the option only takes effect after a restart
+}
+
+// SetConfigProvider sets the function used to handle `/config` requests.
+func (s *Service) SetConfigProvider(provider ConfigProvider) {
+	s.configProvider = provider
+}
+
+// configHandler returns the effective configuration of the starter, with secrets redacted.
+func (s *Service) configHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		writeError(w, http.StatusMethodNotAllowed, "GET required")
+		return
+	}
+	if s.configProvider == nil {
+		writeError(w, http.StatusNotImplemented, "Showing the configuration is not supported")
+		return
+	}
+	resp, err := s.configProvider()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	b, err := json.Marshal(resp)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+	} else {
+		w.Write(b)
+	}
+}
@@ -0,0 +1,129 @@
+//
+// DISCLAIMER
+//
+// Copyright 2017 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+// Author Ewout Prangsma
+//
+
+// Package service implements the ArangoDB starter. It can be embedded in other Go programs
+// (e.g. operators, test frameworks or appliances) instead of running the arangodb binary:
+//
+//	svc, err := service.NewService(config,
+//		service.OnReady(func() { log.Println("all servers are up") }),
+//		service.OnServerUp(func(t service.ServerType, version string) { ... }),
+//	)
+//	if err != nil { ... }
+//	ctx, cancel := context.WithCancel(context.Background())
+//	go svc.Run(ctx) // Blocks until ctx is cancelled (or a shutdown is requested through the API)
+//	<-svc.Ready()
+//	...
+//	cancel() // Stops all servers started by the starter
+//
+// Run still terminates the program on unrecoverable errors (e.g. when the configured
+// ports are in use), so embedders must validate the configuration beforehand.
+package service
+
+import "sync"
+
+// Option configures a Service created by NewService.
+type Option func(*Service)
+
+// WithReloader sets the function used to handle `/reload` requests.
+func WithReloader(reloader Reloader) Option {
+	return func(s *Service) {
+		s.reloader = reloader
+	}
+}
+
+// WithConfigProvider sets the function used to handle `/config` requests.
+func WithConfigProvider(provider ConfigProvider) Option {
+	return func(s *Service) {
+		s.configProvider = provider
+	}
+}
+
+// WithLogLevelSetter sets the function used to change the log levels of the starter.
+func WithLogLevelSetter(setter LogLevelSetter) Option {
+	return func(s *Service) {
+		s.logLevelSetter = setter
+	}
+}
+
+// WithRunner sets the runner used to start the servers, instead of a process or docker runner
+// (e.g. a FakeRunner in tests).
+func WithRunner(runner Runner) Option {
+	return func(s *Service) {
+		s.customRunner = runner
+	}
+}
+
+// OnReady adds a callback that is called (once) when all servers started by the starter are up.
+func OnReady(callback func()) Option {
+	return func(s *Service) {
+		s.readiness.onReady = append(s.readiness.onReady, callback)
+	}
+}
+
+// OnServerUp adds a callback that is called every time a server started by the starter is up,
+// with the type & version of that server. The callback must not block.
+func OnServerUp(callback func(serverType ServerType, version string)) Option {
+	return func(s *Service) {
+		s.readiness.onServerUp = append(s.readiness.onServerUp, callback)
+	}
+}
+
+// asLocalSlave marks the service as a local slave, started by another service in the same process.
+func asLocalSlave() Option {
+	return func(s *Service) {
+		s.isLocalSlave = true
+		s.logWithID = true
+	}
+}
+
+// readiness signals that (all) servers started by the starter are up.
+type readiness struct {
+	once       sync.Once
+	ready      chan struct{}                                 // Closed once all servers are up
+	onReady    []func()                                      // Called once all servers are up
+	onServerUp []func(serverType ServerType, version string) // Called every time a server is up
+}
+
+// Ready returns a channel that is closed once all servers started by the starter are up.
+func (s *Service) Ready() <-chan struct{} {
+	return s.readiness.ready
+}
+
+// signalReady closes the ready channel, calls the OnReady callbacks and executes the post-ready hook (once).
+func (s *Service) signalReady() {
+	s.readiness.once.Do(func() {
+		close(s.readiness.ready)
+		s.events.publish(ProcessEvent{Type: ProcessEventClusterReady})
+		s.notifySystemd("READY=1", "STATUS=All servers are up")
+		for _, cb := range s.readiness.onReady {
+			go cb()
+		}
+		go s.runPostReadyHook()
+	})
+}
+
+// signalServerUp calls the OnServerUp callbacks for the server of given type.
+func (s *Service) signalServerUp(serverType ServerType, version string) {
+	for _, cb := range s.readiness.onServerUp {
+		cb(serverType, version)
+	}
+}
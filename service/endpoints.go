@@ -0,0 +1,68 @@
+//
+// DISCLAIMER
+//
+// Copyright 2017 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+// Author Ewout Prangsma
+//
+
+package service
+
+import (
+	"net"
+	"strconv"
+	"strings"
+)
+
+// deploymentEndpoints holds the URLs of the starters of all peers and of the servers they run.
+// Standby and passive peers run no servers, only their starter is listed.
+type deploymentEndpoints struct {
+	Starters     []string // URLs of the starters of all peers
+	Agents       []string // URLs of all agents
+	DBServers    []string // URLs of all dbservers
+	Coordinators []string // URLs of all coordinators
+	Single       []string // URL of the single server
+}
+
+// endpoints returns the URLs of the starters of all peers and of the servers they run.
+func (s *Service) endpoints() deploymentEndpoints {
+	s.mutex.Lock()
+	peerList := append([]Peer{}, s.myPeers.Peers...)
+	s.mutex.Unlock()
+
+	scheme := NewURLSchemes(s.IsSecure()).Browser
+	serverURL := func(p Peer, serverType ServerType) string {
+		return scheme + "://" + net.JoinHostPort(p.Address, strconv.Itoa(p.ServerPort(s.MasterPort, serverType)))
+	}
+	resp := deploymentEndpoints{Starters: []string{}}
+	for _, p := range peerList {
+		resp.Starters = append(resp.Starters, strings.TrimSuffix(p.CreateStarterURL("/"), "/"))
+		if !p.HasServers() {
+			continue
+		}
+		if s.isSingleMode() {
+			resp.Single = append(resp.Single, serverURL(p, ServerTypeSingle))
+			continue
+		}
+		if p.HasAgent {
+			resp.Agents = append(resp.Agents, serverURL(p, ServerTypeAgent))
+		}
+		resp.DBServers = append(resp.DBServers, serverURL(p, ServerTypeDBServer))
+		resp.Coordinators = append(resp.Coordinators, serverURL(p, ServerTypeCoordinator))
+	}
+	return resp
+}
@@ -0,0 +1,206 @@
+//
+// DISCLAIMER
+//
+// Copyright 2017 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+// Author Ewout Prangsma
+//
+
+package service
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	eventSubscriberBufferSize = 64          // Number of events buffered per subscriber of the event stream
+	peerEventInterval         = time.Second // Interval between checks of the peer list for added or removed peers
+)
+
+const (
+	ProcessEventServerUp      = "server-up"      // A server has started responding to requests
+	ProcessEventServerDown    = "server-down"    // A server has terminated
+	ProcessEventServerFailed  = "server-failed"  // A server has failed and is no longer restarted
+	ProcessEventServerRestart = "server-restart" // A terminated server is restarted
+	ProcessEventServerCrash   = "server-crash"   // A server has terminated unexpectedly (with a non-zero exit code)
+	ProcessEventCrashLoop     = "crash-loop"     // A server has entered a crash loop
+	ProcessEventClusterReady  = "cluster-ready"  // All servers started by the starter are up
+	ProcessEventUpgradeStart  = "upgrade-start"  // A rolling upgrade has been started (by this starter)
+	ProcessEventUpgradeFinish = "upgrade-finish" // A rolling upgrade (started by this starter) has finished or failed
+	ProcessEventUpgradePaused = "upgrade-paused" // A canary upgrade (started by this starter) waits for confirmation to proceed
+	ProcessEventPeerAdded     = "peer-added"     // A peer has joined the deployment
+	ProcessEventPeerRemoved   = "peer-removed"   // A peer has left the deployment
+	ProcessEventPeerChanged   = "peer-changed"   // The address of a peer has changed
+	ProcessEventMasterChanged = "master-changed" // Another peer has become the master
+	ProcessEventDiskSpaceLow  = "disk-space-low" // The free disk space of a directory has dropped below the minimum
+	ProcessEventDiskSpaceOK   = "disk-space-ok"  // The free disk space of a directory has recovered
+	ProcessEventClockSkew     = "clock-skew"     // The clock of a peer is skewed, relative to the clock of the master
+)
+
+// ProcessEvent is a single event of the `/events` stream.
+type ProcessEvent struct {
+	Type       string     `json:"type"`                  // One of the ProcessEvent... constants (e.g. server-up)
+	Time       time.Time  `json:"time"`                  // Time the event occurred
+	ServerType ServerType `json:"server-type,omitempty"` // Type of the server (server events only)
+	Version    string     `json:"version,omitempty"`     // Version of the server (server-up only)
+	Reason     string     `json:"reason,omitempty"`      // Reason of a failure (server-failed, server-crash, crash-loop, upgrade-finish, disk-space-low & clock-skew only)
+	PeerID     string     `json:"peer-id,omitempty"`     // ID of the peer (peer events only)
+	Address    string     `json:"address,omitempty"`     // Address of the peer (peer events only)
+	Port       int        `json:"port,omitempty"`        // Port of the starter on the peer (peer events only)
+	Path       string     `json:"path,omitempty"`        // Directory (disk space events only)
+}
+
+// eventHub distributes events to all subscribers of the event stream.
+type eventHub struct {
+	mutex       sync.Mutex
+	subscribers map[chan ProcessEvent]struct{}
+}
+
+// subscribe returns a channel receiving all events published from now on.
+func (h *eventHub) subscribe() chan ProcessEvent {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if h.subscribers == nil {
+		h.subscribers = make(map[chan ProcessEvent]struct{})
+	}
+	ch := make(chan ProcessEvent, eventSubscriberBufferSize)
+	h.subscribers[ch] = struct{}{}
+	return ch
+}
+
+// unsubscribe stops sending events to the given channel.
+func (h *eventHub) unsubscribe(ch chan ProcessEvent) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if _, found := h.subscribers[ch]; found {
+		delete(h.subscribers, ch)
+		close(ch)
+	}
+}
+
+// publish sends the given event to all subscribers.
+// A subscriber that does not keep up is dropped (its channel is closed),
+// rather than silently missing events.
+func (h *eventHub) publish(e ProcessEvent) {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	for ch := range h.subscribers {
+		select {
+		case ch <- e:
+		default:
+			delete(h.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+// publishServerEvent publishes an event of given type for the server of given type.
+func (s *Service) publishServerEvent(eventType string, serverType ServerType, version, reason string) {
+	s.events.publish(ProcessEvent{
+		Type:       eventType,
+		ServerType: serverType,
+		Version:    version,
+		Reason:     reason,
+	})
+}
+
+// watchPeerEvents publishes an event for every peer that joins, leaves or changes its address,
+// until the starter is stopped.
+func (s *Service) watchPeerEvents() {
+	known := make(map[string]Peer)
+	s.mutex.Lock()
+	for _, p := range s.myPeers.Peers {
+		known[p.ID] = p
+	}
+	s.mutex.Unlock()
+
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-time.After(peerEventInterval):
+		}
+		current := make(map[string]Peer)
+		s.mutex.Lock()
+		for _, p := range s.myPeers.Peers {
+			current[p.ID] = p
+		}
+		s.mutex.Unlock()
+
+		for id, p := range current {
+			if old, found := known[id]; !found {
+				s.events.publish(ProcessEvent{Type: ProcessEventPeerAdded, PeerID: id, Address: p.Address, Port: p.Port})
+			} else if old.Address != p.Address || old.Port != p.Port {
+				s.events.publish(ProcessEvent{Type: ProcessEventPeerChanged, PeerID: id, Address: p.Address, Port: p.Port})
+			}
+		}
+		for id, p := range known {
+			if _, found := current[id]; !found {
+				s.events.publish(ProcessEvent{Type: ProcessEventPeerRemoved, PeerID: id, Address: p.Address, Port: p.Port})
+			}
+		}
+		known = current
+	}
+}
+
+// eventsHandler streams all events of the starter (one JSON object per line),
+// until the client disconnects or the starter is stopped.
+func (s *Service) eventsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		writeError(w, http.StatusMethodNotAllowed, "GET required")
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, "Streaming is not supported")
+		return
+	}
+
+	ch := s.events.subscribe()
+	defer s.events.unsubscribe(ch)
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	encoder := json.NewEncoder(w)
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-s.ctx.Done():
+			return
+		case e, ok := <-ch:
+			if !ok {
+				// Dropped because we could not keep up
+				s.apiLog.Warningf("Closing event stream of %s, because it cannot keep up", r.RemoteAddr)
+				return
+			}
+			if err := encoder.Encode(e); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
@@ -42,25 +42,9 @@ func GuessOwnAddress() (string, error) {
 		if intf.Flags&net.FlagLoopback != 0 {
 			continue
 		}
-		addrs, err := intf.Addrs()
-		if err != nil {
-			// Just ignore this interface in case we cannot get its addresses
-			continue
-		}
-		for _, addr := range addrs {
-			ip, _, err := net.ParseCIDR(addr.String())
-			if err != nil {
-				continue
-			}
-			if ip.IsLoopback() || ip.IsLinkLocalMulticast() || ip.IsLinkLocalUnicast() {
-				continue
-			}
-			if ip4 := ip.To4(); ip4 != nil {
-				validIP4s = append(validIP4s, ip4)
-			} else if ip6 := ip.To16(); ip6 != nil {
-				validIP6s = append(validIP6s, ip6)
-			}
-		}
+		ip4s, ip6s := interfaceAddresses(intf)
+		validIP4s = append(validIP4s, ip4s...)
+		validIP6s = append(validIP6s, ip6s...)
 	}
 	if len(validIP4s) > 0 {
 		return validIP4s[0].String(), nil
@@ -70,3 +54,48 @@ func GuessOwnAddress() (string, error) {
 	}
 	return "", fmt.Errorf("No suitable addresses found")
 }
+
+// InterfaceAddress returns the IP address of the network interface with given name.
+// IPv4 addresses are preferred over IPv6 addresses.
+func InterfaceAddress(name string) (string, error) {
+	intf, err := net.InterfaceByName(name)
+	if err != nil {
+		return "", maskAny(err)
+	}
+	if intf.Flags&net.FlagUp == 0 {
+		return "", maskAny(fmt.Errorf("Network interface %s is down", name))
+	}
+	ip4s, ip6s := interfaceAddresses(*intf)
+	if len(ip4s) > 0 {
+		return ip4s[0].String(), nil
+	}
+	if len(ip6s) > 0 {
+		return ip6s[0].String(), nil
+	}
+	return "", maskAny(fmt.Errorf("Network interface %s has no suitable addresses", name))
+}
+
+// interfaceAddresses returns the IPv4 & IPv6 addresses of the given interface that can be used
+// to reach this host (loopback & link local addresses are skipped).
+func interfaceAddresses(intf net.Interface) (ip4s, ip6s []net.IP) {
+	addrs, err := intf.Addrs()
+	if err != nil {
+		// Just ignore this interface in case we cannot get its addresses
+		return nil, nil
+	}
+	for _, addr := range addrs {
+		ip, _, err := net.ParseCIDR(addr.String())
+		if err != nil {
+			continue
+		}
+		if ip.IsLoopback() || ip.IsLinkLocalMulticast() || ip.IsLinkLocalUnicast() {
+			continue
+		}
+		if ip4 := ip.To4(); ip4 != nil {
+			ip4s = append(ip4s, ip4)
+		} else if ip6 := ip.To16(); ip6 != nil {
+			ip6s = append(ip6s, ip6)
+		}
+	}
+	return ip4s, ip6s
+}
@@ -0,0 +1,177 @@
+//
+// DISCLAIMER
+//
+// Copyright 2017 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+// Author Ewout Prangsma
+//
+
+package service
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Hook identifies a lifecycle point of the starter at which a script can be executed.
+type Hook string
+
+const (
+	HookPreStart    Hook = "pre-start"    // Before the servers of the starter are started
+	HookPostReady   Hook = "post-ready"   // Once all servers of the starter are up
+	HookPreStop     Hook = "pre-stop"     // Before the servers of the starter are stopped
+	HookCanaryCheck Hook = "canary-check" // On the master, once the canary servers of a canary upgrade have been upgraded
+)
+
+const (
+	defaultHookTimeout = time.Minute // Time a hook script may run when no timeout is configured
+)
+
+// AllHooks lists all lifecycle points at which a script can be executed.
+var AllHooks = []Hook{HookPreStart, HookPostReady, HookPreStop, HookCanaryCheck}
+
+// hookScript returns the path of the script executed for the given hook, or "" if there is none.
+// An explicitly configured script takes precedence over a script (named after the hook) in HooksDir.
+func (s *Service) hookScript(hook Hook) string {
+	if path := s.Hooks[hook]; path != "" {
+		return path
+	}
+	if s.HooksDir != "" {
+		path := filepath.Join(s.HooksDir, string(hook))
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			return path
+		}
+	}
+	return ""
+}
+
+// hookEnv returns the environment variables describing this starter, its servers (of given types)
+// and the endpoints of the deployment, passed to hook scripts.
+func (s *Service) hookEnv(hook Hook, serverTypes []ServerType) []string {
+	roles := make([]string, 0, len(serverTypes))
+	for _, t := range serverTypes {
+		roles = append(roles, t.String())
+	}
+	env := []string{
+		"ARANGODB_STARTER_HOOK=" + string(hook),
+		"ARANGODB_STARTER_ID=" + s.ID,
+		"ARANGODB_STARTER_MODE=" + s.Mode,
+		"ARANGODB_STARTER_MASTER=" + strconv.FormatBool(s.isMaster()),
+		"ARANGODB_STARTER_DATA_DIR=" + s.DataDir,
+		"ARANGODB_STARTER_ROLES=" + strings.Join(roles, ","),
+	}
+	if myPeer, found := s.myPeers.PeerByID(s.ID); found {
+		scheme := NewURLSchemes(s.IsSecure()).Browser
+		env = append(env, "ARANGODB_STARTER_URL="+strings.TrimSuffix(myPeer.CreateStarterURL("/"), "/"))
+		for _, t := range serverTypes {
+			port := myPeer.ServerPort(s.MasterPort, t)
+			env = append(env, fmt.Sprintf("ARANGODB_%s_ENDPOINT=%s://%s", strings.ToUpper(t.String()), scheme, net.JoinHostPort(myPeer.Address, strconv.Itoa(port))))
+		}
+	}
+	endpoints := s.endpoints()
+	env = append(env,
+		"ARANGODB_STARTERS="+strings.Join(endpoints.Starters, ","),
+		"ARANGODB_AGENTS="+strings.Join(endpoints.Agents, ","),
+		"ARANGODB_DBSERVERS="+strings.Join(endpoints.DBServers, ","),
+		"ARANGODB_COORDINATORS="+strings.Join(endpoints.Coordinators, ","),
+		"ARANGODB_SINGLE="+strings.Join(endpoints.Single, ","),
+	)
+	return env
+}
+
+// runHook executes the script of the given hook (if any) and waits for it to finish.
+// It returns an error when the script fails or does not finish within HookTimeout.
+func (s *Service) runHook(hook Hook, serverTypes []ServerType) error {
+	script := s.hookScript(hook)
+	if script == "" {
+		return nil
+	}
+	timeout := s.HookTimeout
+	if timeout <= 0 {
+		timeout = defaultHookTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	// The output is written to a file (instead of a pipe), so a killed script
+	// cannot block us through child processes that still hold its output open.
+	output, err := ioutil.TempFile("", "arangodb-hook-")
+	if err != nil {
+		return maskAny(err)
+	}
+	defer os.Remove(output.Name())
+	defer output.Close()
+
+	s.log.Infof("Executing %s hook %s", hook, script)
+	c := exec.CommandContext(ctx, script)
+	c.Dir = s.DataDir
+	c.Env = append(os.Environ(), s.hookEnv(hook, serverTypes)...)
+	c.Stdout = output
+	c.Stderr = output
+	err = c.Run()
+	content, _ := ioutil.ReadFile(output.Name())
+	for _, line := range strings.Split(strings.TrimSpace(string(content)), "\n") {
+		if line != "" {
+			s.log.Infof("%s hook: %s", hook, line)
+		}
+	}
+	if ctx.Err() == context.DeadlineExceeded {
+		return maskAny(fmt.Errorf("%s did not finish within %s", script, timeout))
+	} else if err != nil {
+		return maskAny(fmt.Errorf("%s: %v", script, err))
+	}
+	return nil
+}
+
+// runPostReadyHook executes the post-ready hook (if any) for the servers of this starter.
+func (s *Service) runPostReadyHook() {
+	myPeer, found := s.myPeers.PeerByID(s.ID)
+	if !found {
+		return
+	}
+	serverTypes := s.ownServerTypes(myPeer)
+	if len(serverTypes) == 0 {
+		return
+	}
+	if err := s.runHook(HookPostReady, serverTypes); err != nil {
+		s.log.Warningf("The %s hook failed: %v", HookPostReady, err)
+	}
+}
+
+// ValidateHookScript checks that the script of a hook exists and can be executed.
+func ValidateHookScript(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return maskAny(err)
+	}
+	if info.IsDir() {
+		return maskAny(fmt.Errorf("%s is a directory", path))
+	}
+	if info.Mode()&0111 == 0 && runtime.GOOS != "windows" {
+		return maskAny(fmt.Errorf("%s is not executable", path))
+	}
+	return nil
+}
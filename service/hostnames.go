@@ -0,0 +1,112 @@
+//
+// DISCLAIMER
+//
+// Copyright 2017 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+// Author Ewout Prangsma
+//
+
+package service
+
+import (
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	peerAddressRefreshInterval = time.Minute // Interval at which the IP addresses of peers registered with a host name are resolved again
+)
+
+// OwnHostName returns the DNS name of this machine, advertised as its address with `--starter.prefer-hostnames`.
+// When the host name is not fully qualified, its canonical name is used (if it can be resolved).
+func OwnHostName() (string, error) {
+	name, err := os.Hostname()
+	if err != nil {
+		return "", maskAny(err)
+	}
+	if !strings.Contains(name, ".") {
+		if cname, err := net.LookupCNAME(name); err == nil {
+			if cname = strings.TrimSuffix(cname, "."); strings.Contains(cname, ".") {
+				name = cname
+			}
+		}
+	}
+	return name, nil
+}
+
+// peerHostName returns the address a peer is registered with, when it has been derived from the IP address of its request.
+// With PreferHostnames, the DNS name of that IP address is used (if it can be resolved).
+func (s *Service) peerHostName(address string) string {
+	if !s.PreferHostnames || net.ParseIP(address) == nil {
+		return address
+	}
+	names, err := net.LookupAddr(address)
+	if err != nil || len(names) == 0 {
+		s.peersLog.Debugf("Cannot find host name of %s, using the IP address: %v", address, err)
+		return address
+	}
+	return strings.TrimSuffix(names[0], ".")
+}
+
+// peerAddressCache holds the IP addresses of all peers, with host names resolved.
+// It is rebuilt when the addresses of the peers change (and every peerAddressRefreshInterval),
+// so checking the address of a request does not cost DNS queries.
+type peerAddressCache struct {
+	mutex     sync.Mutex
+	addresses []string        // Addresses of the peers the cache was built for
+	ips       map[string]bool // IP addresses of these peers
+	resolved  time.Time       // Time the host names were resolved
+}
+
+// contains returns true if the given IP address is the address of one of the peers with given addresses.
+func (c *peerAddressCache) contains(addresses []string, ip string) bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.ips == nil || !equalStrings(c.addresses, addresses) || time.Since(c.resolved) > peerAddressRefreshInterval {
+		c.ips = make(map[string]bool)
+		for _, address := range addresses {
+			c.ips[address] = true
+			if net.ParseIP(address) != nil || address == "localhost" {
+				continue
+			}
+			if resolved, err := net.LookupHost(address); err == nil {
+				for _, a := range resolved {
+					c.ips[normalizeHostName(a)] = true
+				}
+			}
+		}
+		c.addresses = addresses
+		c.resolved = time.Now()
+	}
+	return c.ips[ip]
+}
+
+// equalStrings returns true if both lists hold the same strings in the same order.
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
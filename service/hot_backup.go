@@ -0,0 +1,421 @@
+//
+// DISCLAIMER
+//
+// Copyright 2017 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+// Author Ewout Prangsma
+//
+
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	hotBackupCreateTimeout   = time.Minute * 10 // Maximum time for creating a hot backup
+	hotBackupTransferTimeout = time.Hour * 24   // Maximum time for uploading/downloading a hot backup
+	hotBackupRequestTimeout  = time.Second * 30
+	hotBackupCheckInterval   = time.Second * 5
+)
+
+// Operations on hot backups
+const (
+	HotBackupOperationCreate   = "create"
+	HotBackupOperationUpload   = "upload"
+	HotBackupOperationDownload = "download"
+)
+
+var (
+	errHotBackupRunning = errors.New("Hot backup operation already running")
+)
+
+// HotBackupCreateRequest is the JSON body of a POST `/hotbackup` request.
+type HotBackupCreateRequest struct {
+	Label             string  `json:"label,omitempty"`             // Label added to the ID of the backup
+	Timeout           float64 `json:"timeout,omitempty"`           // Time (in seconds) to wait for the global lock
+	AllowInconsistent bool    `json:"allowInconsistent,omitempty"` // If set, a backup is created even if the global lock cannot be obtained
+}
+
+// HotBackupTransferRequest is the JSON body of a POST `/hotbackup/upload` or `/hotbackup/download` request.
+type HotBackupTransferRequest struct {
+	ID               string                 `json:"id"`               // ID of the hot backup to transfer
+	RemoteRepository string                 `json:"remoteRepository"` // Remote repository (e.g. S3://bucket/path)
+	Config           map[string]interface{} `json:"config"`           // Configuration of the remote repository (rclone)
+}
+
+// HotBackup holds the information of a single hot backup, as listed by the cluster.
+type HotBackup struct {
+	ID                      string `json:"id"`
+	Version                 string `json:"version,omitempty"`
+	DateTime                string `json:"datetime,omitempty"`
+	SizeInBytes             int64  `json:"sizeInBytes,omitempty"`
+	NrDBServers             int    `json:"nrDBServers,omitempty"`
+	NrFiles                 int    `json:"nrFiles,omitempty"`
+	Available               bool   `json:"available"`
+	PotentiallyInconsistent bool   `json:"potentiallyInconsistent,omitempty"`
+}
+
+// HotBackupStatus is the JSON response of a `/hotbackup` request.
+type HotBackupStatus struct {
+	Operation  string            `json:"operation,omitempty"`   // create | upload | download
+	Running    bool              `json:"running,omitempty"`     // If set, the operation is in progress
+	Ready      bool              `json:"ready,omitempty"`       // If set, the operation has finished successfully
+	Failed     bool              `json:"failed,omitempty"`      // If set, the operation has failed
+	Reason     string            `json:"reason,omitempty"`      // Reason of the failure (if any)
+	ID         string            `json:"id,omitempty"`          // ID of the hot backup
+	TransferID string            `json:"transfer-id,omitempty"` // ID of the upload/download job
+	Progress   map[string]string `json:"progress,omitempty"`    // Status of the upload/download per dbserver
+	Started    *time.Time        `json:"started,omitempty"`     // Time the operation was started
+	Finished   *time.Time        `json:"finished,omitempty"`    // Time the operation has finished
+	Backups    []HotBackup       `json:"backups,omitempty"`     // All hot backups of the cluster
+	ListError  string            `json:"list-error,omitempty"`  // Reason the hot backups could not be listed (if any)
+}
+
+// hotBackupManager holds the state of the current (or last) hot backup operation of this starter.
+type hotBackupManager struct {
+	mutex  sync.Mutex
+	status HotBackupStatus
+}
+
+// getStatus returns a copy of the current hot backup status.
+func (m *hotBackupManager) getStatus() HotBackupStatus {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	status := m.status
+	status.Progress = make(map[string]string)
+	for k, v := range m.status.Progress {
+		status.Progress[k] = v
+	}
+	return status
+}
+
+// update calls the given function with exclusive access to the hot backup status.
+func (m *hotBackupManager) update(f func(status *HotBackupStatus)) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	f(&m.status)
+}
+
+// start records the start of an operation, failing when another operation is running.
+func (m *hotBackupManager) start(operation, id string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if m.status.Running {
+		return maskAny(errHotBackupRunning)
+	}
+	now := time.Now()
+	m.status = HotBackupStatus{
+		Operation: operation,
+		Running:   true,
+		ID:        id,
+		Started:   &now,
+	}
+	return nil
+}
+
+// finish records the end of the current operation.
+func (m *hotBackupManager) finish(err error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	now := time.Now()
+	m.status.Running = false
+	m.status.Finished = &now
+	if err != nil {
+		m.status.Failed = true
+		m.status.Reason = err.Error()
+	} else {
+		m.status.Ready = true
+	}
+}
+
+// hotBackupHandler starts the creation of a hot backup (POST) or returns the status of the
+// current (or last) hot backup operation with a list of all hot backups (GET).
+func (s *Service) hotBackupHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" && r.Method != "GET" {
+		writeError(w, http.StatusMethodNotAllowed, "GET or POST required")
+		return
+	}
+	if r.Method == "POST" {
+		var req HotBackupCreateRequest
+		if !readJSONBody(w, r, &req, true) {
+			return
+		}
+		op, existing, ok := s.beginOperation(w, r, OperationTypeHotBackupCreate)
+		if !ok {
+			return
+		}
+		if !existing {
+			if err := s.hotBackups.start(HotBackupOperationCreate, ""); err != nil {
+				s.operations.remove(op.ID)
+				writeError(w, http.StatusConflict, err.Error())
+				return
+			}
+			s.log.Info("Creating hot backup")
+			go s.createHotBackup(req)
+			s.watchOperation(op.ID, s.hotBackupProgress)
+		}
+		if prefersAsync(r) {
+			op, _ = s.operations.get(op.ID)
+			writeOperation(w, http.StatusAccepted, op)
+			return
+		}
+	}
+	s.writeHotBackupStatus(w, r.Method == "GET")
+}
+
+// hotBackupTransferHandler starts the upload (or download) of a hot backup to (or from) a remote repository.
+func (s *Service) hotBackupTransferHandler(operation string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			writeError(w, http.StatusMethodNotAllowed, "POST required")
+			return
+		}
+		var req HotBackupTransferRequest
+		if !readJSONBody(w, r, &req, false) {
+			return
+		}
+		if req.ID == "" || req.RemoteRepository == "" {
+			writeError(w, http.StatusBadRequest, "ID and RemoteRepository must be set.")
+			return
+		}
+		op, existing, ok := s.beginOperation(w, r, "hotbackup-"+operation)
+		if !ok {
+			return
+		}
+		if !existing {
+			if err := s.hotBackups.start(operation, req.ID); err != nil {
+				s.operations.remove(op.ID)
+				writeError(w, http.StatusConflict, err.Error())
+				return
+			}
+			s.log.Infof("Starting %s of hot backup %s", operation, req.ID)
+			go s.transferHotBackup(operation, req)
+			s.watchOperation(op.ID, s.hotBackupProgress)
+		}
+		if prefersAsync(r) {
+			op, _ = s.operations.get(op.ID)
+			writeOperation(w, http.StatusAccepted, op)
+			return
+		}
+		s.writeHotBackupStatus(w, false)
+	}
+}
+
+// hotBackupProgress returns the state of the current (or last) hot backup operation, for tracking it as an operation.
+func (s *Service) hotBackupProgress() (bool, string, interface{}, error) {
+	status := s.hotBackups.getStatus()
+	if status.Failed {
+		return false, "", status, maskAny(errors.New(status.Reason))
+	}
+	return status.Running, "", status, nil
+}
+
+// writeHotBackupStatus writes the current hot backup status, optionally with a list of all hot backups.
+func (s *Service) writeHotBackupStatus(w http.ResponseWriter, withList bool) {
+	status := s.hotBackups.getStatus()
+	if withList {
+		ctx, cancel := context.WithTimeout(s.ctx, hotBackupRequestTimeout)
+		backups, err := s.listHotBackups(ctx)
+		cancel()
+		if err != nil {
+			status.ListError = err.Error()
+		} else {
+			status.Backups = backups
+		}
+	}
+	b, err := json.Marshal(status)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+	} else {
+		w.Write(b)
+	}
+}
+
+// createHotBackup creates a hot backup of the cluster (or single server).
+func (s *Service) createHotBackup(req HotBackupCreateRequest) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		s.hotBackups.finish(maskAny(err))
+		return
+	}
+	ctx, cancel := context.WithTimeout(s.ctx, hotBackupCreateTimeout)
+	defer cancel()
+	var result struct {
+		ID                      string `json:"id"`
+		PotentiallyInconsistent bool   `json:"potentiallyInconsistent"`
+	}
+	if err := s.hotBackupRequest(ctx, serverLongHTTPClient, "/_admin/backup/create", body, &result); err != nil {
+		s.log.Errorf("Failed to create hot backup: %v", err)
+		s.hotBackups.finish(err)
+		return
+	}
+	if result.PotentiallyInconsistent {
+		s.log.Warningf("Hot backup %s has been created, but is potentially inconsistent", result.ID)
+	} else {
+		s.log.Infof("Hot backup %s has been created", result.ID)
+	}
+	s.hotBackups.update(func(status *HotBackupStatus) {
+		status.ID = result.ID
+	})
+	s.hotBackups.finish(nil)
+}
+
+// transferHotBackup uploads (or downloads) a hot backup to (or from) a remote repository and
+// tracks its progress until all dbservers have finished.
+func (s *Service) transferHotBackup(operation string, req HotBackupTransferRequest) {
+	idField := operation + "Id"
+	path := "/_admin/backup/" + operation
+	body, err := json.Marshal(req)
+	if err != nil {
+		s.hotBackups.finish(maskAny(err))
+		return
+	}
+	var started map[string]interface{}
+	ctx, cancel := context.WithTimeout(s.ctx, hotBackupRequestTimeout)
+	err = s.hotBackupRequest(ctx, serverHTTPClient, path, body, &started)
+	cancel()
+	if err != nil {
+		s.log.Errorf("Failed to start %s of hot backup %s: %v", operation, req.ID, err)
+		s.hotBackups.finish(err)
+		return
+	}
+	transferID := fmt.Sprintf("%v", started[idField])
+	s.hotBackups.update(func(status *HotBackupStatus) {
+		status.TransferID = transferID
+	})
+
+	deadline := time.Now().Add(hotBackupTransferTimeout)
+	for {
+		time.Sleep(hotBackupCheckInterval)
+		if s.stop {
+			s.hotBackups.finish(fmt.Errorf("Stopped while waiting for %s", operation))
+			return
+		}
+		if time.Now().After(deadline) {
+			s.hotBackups.finish(fmt.Errorf("%s has not finished after %s", operation, hotBackupTransferTimeout))
+			return
+		}
+		var progress struct {
+			DBServers map[string]struct {
+				Status       string `json:"Status"`
+				ErrorMessage string `json:"ErrorMessage"`
+			} `json:"DBServers"`
+		}
+		body, _ := json.Marshal(map[string]string{idField: transferID})
+		ctx, cancel := context.WithTimeout(s.ctx, hotBackupRequestTimeout)
+		err := s.hotBackupRequest(ctx, serverHTTPClient, path, body, &progress)
+		cancel()
+		if err != nil {
+			s.log.Debugf("Cannot get progress of %s: %v", operation, err)
+			continue
+		}
+		done, failed := len(progress.DBServers) > 0, ""
+		perServer := make(map[string]string)
+		for id, p := range progress.DBServers {
+			perServer[id] = p.Status
+			switch p.Status {
+			case "COMPLETED":
+			case "FAILED":
+				failed = fmt.Sprintf("%s has failed on %s: %s", operation, id, p.ErrorMessage)
+			default:
+				done = false
+			}
+		}
+		s.hotBackups.update(func(status *HotBackupStatus) {
+			status.Progress = perServer
+		})
+		if failed != "" {
+			s.log.Errorf("Failed to %s hot backup %s: %s", operation, req.ID, failed)
+			s.hotBackups.finish(errors.New(failed))
+			return
+		}
+		if done {
+			s.log.Infof("Finished %s of hot backup %s", operation, req.ID)
+			s.hotBackups.finish(nil)
+			return
+		}
+	}
+}
+
+// listHotBackups returns all hot backups of the cluster (or single server).
+func (s *Service) listHotBackups(ctx context.Context) ([]HotBackup, error) {
+	var result struct {
+		List map[string]HotBackup `json:"list"`
+	}
+	if err := s.hotBackupRequest(ctx, serverHTTPClient, "/_admin/backup/list", []byte("{}"), &result); err != nil {
+		return nil, maskAny(err)
+	}
+	list := make([]HotBackup, 0, len(result.List))
+	for id, b := range result.List {
+		b.ID = id
+		list = append(list, b)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+	return list, nil
+}
+
+// hotBackupRequest performs a POST request (using the given client) to the given hot backup API of a coordinator
+// (or the single server) and decodes the `result` field of the response into the given result.
+func (s *Service) hotBackupRequest(ctx context.Context, httpClient *http.Client, path string, body []byte, result interface{}) error {
+	serverType := ServerType(ServerTypeCoordinator)
+	if s.isSingleMode() {
+		serverType = ServerTypeSingle
+	}
+	content, err := s.clusterRequestWithClient(ctx, httpClient, serverType, "POST", path, "application/json", body)
+	if err != nil {
+		return maskAny(err)
+	}
+	var resp struct {
+		Result json.RawMessage `json:"result"`
+	}
+	if err := json.Unmarshal(content, &resp); err != nil {
+		return maskAny(err)
+	}
+	if err := json.Unmarshal(resp.Result, result); err != nil {
+		return maskAny(err)
+	}
+	return nil
+}
+
+// readJSONBody decodes the body of the given request into the given value.
+// On failure an error response is written and false is returned.
+func readJSONBody(w http.ResponseWriter, r *http.Request, v interface{}, allowEmpty bool) bool {
+	defer r.Body.Close()
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("Cannot read request body: %v", err.Error()))
+		return false
+	}
+	if len(body) == 0 && allowEmpty {
+		return true
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("Cannot parse request body: %v", err.Error()))
+		return false
+	}
+	return true
+}
@@ -0,0 +1,152 @@
+//
+// DISCLAIMER
+//
+// Copyright 2017 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+// Author Ewout Prangsma
+//
+
+package service
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Names of the external inputs recorded in strict reproducibility mode.
+const (
+	inputArangod       = "arangod"
+	inputRr            = "rr"
+	inputDockerImage   = "docker-image"
+	inputConfiguration = "configuration"
+	inputSslKeyFile    = "ssl-keyfile"
+	inputSslCAFile     = "ssl-cafile"
+	inputJwtSecret     = "jwt-secret"
+)
+
+// collectInputDigests computes the digests of all external inputs the starter depends on.
+func (s *Service) collectInputDigests(useDockerRunner bool) (map[string]string, error) {
+	result := make(map[string]string)
+	addFile := func(name, path string) error {
+		if path == "" {
+			return nil
+		}
+		digest, err := fileDigest(path)
+		if err != nil {
+			return maskAny(fmt.Errorf("Cannot compute digest of %s (%s): %v", name, path, err))
+		}
+		result[name] = digest
+		return nil
+	}
+	if useDockerRunner {
+		id, err := findDockerImageID(s.DockerEndpoint, s.DockerImage, s.Offline)
+		if err != nil {
+			return nil, maskAny(fmt.Errorf("Cannot find ID of docker image %s: %v", s.DockerImage, err))
+		}
+		result[inputDockerImage] = id
+	} else {
+		if err := addFile(inputArangod, s.ArangodPath); err != nil {
+			return nil, maskAny(err)
+		}
+		if err := addFile(inputRr, s.RrPath); err != nil {
+			return nil, maskAny(err)
+		}
+	}
+	if err := addFile(inputConfiguration, s.ConfigFile); err != nil {
+		return nil, maskAny(err)
+	}
+	if !s.SslAutoKeyFile {
+		// Automatically created key files change on every start
+		if err := addFile(inputSslKeyFile, s.SslKeyFile); err != nil {
+			return nil, maskAny(err)
+		}
+	}
+	if err := addFile(inputSslCAFile, s.SslCAFile); err != nil {
+		return nil, maskAny(err)
+	}
+	if s.JwtSecret != "" {
+		result[inputJwtSecret] = stringDigest(s.JwtSecret)
+	}
+	return result, nil
+}
+
+// verifyInputDigests compares the current input digests with the given recorded digests.
+// It returns the names of all inputs that have been added, removed or changed.
+func (s *Service) verifyInputDigests(recorded map[string]string) []string {
+	var changed []string
+	for name, digest := range s.inputDigests {
+		if recorded[name] != digest {
+			changed = append(changed, name)
+		}
+	}
+	for name := range recorded {
+		if _, found := s.inputDigests[name]; !found {
+			changed = append(changed, name)
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
+
+// checkRecordedInputs verifies the current inputs against those recorded in the setup file.
+// In strict reproducibility mode, the starter refuses to continue when inputs have changed,
+// unless changes are explicitly accepted.
+func (s *Service) checkRecordedInputs(recorded map[string]string) {
+	if !s.StrictReproducibility {
+		// Keep whatever has been recorded before
+		s.inputDigests = recorded
+		return
+	}
+	if len(recorded) == 0 {
+		s.log.Infof("Recording digests of all inputs in %s", setupFileName)
+		return
+	}
+	changed := s.verifyInputDigests(recorded)
+	if len(changed) == 0 {
+		s.log.Infof("All inputs match their recorded digests")
+		return
+	}
+	if !s.AcceptInputChanges {
+		s.log.Fatalf("The following inputs have changed since they have been recorded: %s. Use --starter.accept-changes to accept these changes.", strings.Join(changed, ", "))
+	}
+	s.log.Warningf("Accepting changes of the following inputs: %s", strings.Join(changed, ", "))
+}
+
+// fileDigest returns the SHA256 digest of the content of the file with given path.
+func fileDigest(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", maskAny(err)
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", maskAny(err)
+	}
+	return "sha256:" + hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// stringDigest returns the SHA256 digest of the given string.
+func stringDigest(value string) string {
+	h := sha256.Sum256([]byte(value))
+	return "sha256:" + hex.EncodeToString(h[:])
+}
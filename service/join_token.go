@@ -0,0 +1,133 @@
+//
+// DISCLAIMER
+//
+// Copyright 2017 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+// Author Ewout Prangsma
+//
+
+package service
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+const (
+	joinFailureWindow = time.Minute     // Window in which failed join attempts are counted
+	maxJoinFailures   = 5               // Number of failed join attempts from an address within the window, after which it is blocked
+	joinProofWindow   = time.Minute * 5 // Maximum difference between the time of a join proof and the time of the master
+)
+
+// joinToken returns the token that a starter must know to join the deployment.
+// This is the --starter.join-token, or the JWT secret when no join token is set.
+// Returns an empty string when any starter can join.
+func (s *Service) joinToken() string {
+	if s.JoinToken != "" {
+		return s.JoinToken
+	}
+	return s.JwtSecret
+}
+
+// createJoinProof returns the proof that a starter with given ID knows the given join token,
+// for a hello request sent at the given time.
+// The proof is sent in the hello request, so the token itself is never sent.
+func createJoinProof(token, slaveID string, t time.Time) string {
+	mac := hmac.New(sha256.New, []byte(token))
+	mac.Write([]byte(slaveID))
+	mac.Write([]byte{0})
+	mac.Write([]byte(t.UTC().Format(time.RFC3339Nano)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// isValidJoinProof returns true if the given proof has been created from the given token for the given starter ID
+// and time, and that time is within joinProofWindow of the current time.
+func isValidJoinProof(token, slaveID string, t time.Time, proof string) bool {
+	if absDuration(time.Since(t)) > joinProofWindow {
+		return false
+	}
+	return hmac.Equal([]byte(proof), []byte(createJoinProof(token, slaveID, t)))
+}
+
+// joinProofCache holds the join proofs that have been accepted within joinProofWindow,
+// so a captured hello request cannot be replayed.
+type joinProofCache struct {
+	mutex  sync.Mutex
+	proofs map[string]time.Time
+}
+
+// use records the given proof and returns true, or returns false if it has been used before.
+func (c *joinProofCache) use(proof string) bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.proofs == nil {
+		c.proofs = make(map[string]time.Time)
+	}
+	for p, t := range c.proofs {
+		if time.Since(t) > joinProofWindow*2 {
+			delete(c.proofs, p)
+		}
+	}
+	if _, found := c.proofs[proof]; found {
+		return false
+	}
+	c.proofs[proof] = time.Now()
+	return true
+}
+
+// joinFailureLimiter tracks failed join attempts by address, to rate-limit guessing the join token.
+type joinFailureLimiter struct {
+	mutex    sync.Mutex
+	failures map[string][]time.Time
+}
+
+// isBlocked returns true if the given address has failed to join too often recently.
+func (l *joinFailureLimiter) isBlocked(address string) bool {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return len(l.recent(address)) >= maxJoinFailures
+}
+
+// add records a failed join attempt from the given address.
+func (l *joinFailureLimiter) add(address string) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.failures == nil {
+		l.failures = make(map[string][]time.Time)
+	}
+	l.failures[address] = append(l.recent(address), time.Now())
+}
+
+// recent returns the failed join attempts from the given address within the window, forgetting older ones.
+// Must be called with the mutex held.
+func (l *joinFailureLimiter) recent(address string) []time.Time {
+	var result []time.Time
+	for _, t := range l.failures[address] {
+		if time.Since(t) < joinFailureWindow {
+			result = append(result, t)
+		}
+	}
+	if len(result) == 0 {
+		delete(l.failures, address)
+	} else {
+		l.failures[address] = result
+	}
+	return result
+}
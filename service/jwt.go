@@ -23,7 +23,10 @@
 package service
 
 import (
+	"fmt"
+	"net"
 	"net/http"
+	"strings"
 
 	jwt "github.com/dgrijalva/jwt-go"
 )
@@ -51,3 +54,37 @@ func addJwtHeader(req *http.Request, jwtSecret string) error {
 	req.Header.Set("Authorization", "bearer "+signedToken)
 	return nil
 }
+
+// isValidJwtHeader returns true if the given request carries a JWT authorization header
+// signed with the given secret, like the one added by addJwtHeader.
+func isValidJwtHeader(req *http.Request, jwtSecret string) bool {
+	const prefix = "bearer "
+	auth := req.Header.Get("Authorization")
+	if len(auth) <= len(prefix) || !strings.EqualFold(auth[:len(prefix)], prefix) {
+		return false
+	}
+	token, err := jwt.Parse(auth[len(prefix):], func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("Unexpected signing method %v", token.Header["alg"])
+		}
+		return []byte(jwtSecret), nil
+	})
+	return err == nil && token.Valid
+}
+
+// authorized wraps the given handler, such that requests without a JWT authorization header signed
+// with the JWT secret (see addJwtHeader) are refused with status 401.
+// This protects endpoints that act on the deployment using the JWT secret, or that destroy data.
+// Requests over the unix socket are allowed, as are all requests when no JWT secret is set.
+func (s *Service) authorized(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.JwtSecret != "" && !isValidJwtHeader(r, s.JwtSecret) {
+			if _, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+				s.apiLog.Debugf("Refused %s %s from %s: no valid JWT", r.Method, r.URL.Path, r.RemoteAddr)
+				writeError(w, http.StatusUnauthorized, "A JWT signed with the JWT secret of the deployment is required")
+				return
+			}
+		}
+		handler(w, r)
+	}
+}
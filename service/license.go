@@ -0,0 +1,138 @@
+//
+// DISCLAIMER
+//
+// Copyright 2017 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+// Author Ewout Prangsma
+//
+
+package service
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	// licenseKeyEnvVar is the environment variable arangod reads its license key from.
+	licenseKeyEnvVar = "ARANGO_LICENSE_KEY"
+	// licenseExpiryWarning is the time before the expiry of the license from which on warnings are given.
+	licenseExpiryWarning = time.Hour * 24 * 30
+
+	LicenseOK       = "ok"       // License is valid
+	LicenseExpiring = "expiring" // License expires within 30 days
+	LicenseExpired  = "expired"  // License has expired
+	LicenseUnknown  = "unknown"  // Expiry of the license cannot be determined
+)
+
+// License holds the information decoded from a license key.
+type License struct {
+	Expires time.Time // Time the license expires (zero if unknown)
+}
+
+// LicenseHealth holds the health of the license key passed to the servers (see HealthResponse).
+type LicenseHealth struct {
+	Status  string     `json:"status"`            // ok | expiring | expired | unknown
+	Expires *time.Time `json:"expires,omitempty"` // Time the license expires (if known)
+}
+
+// ParseLicenseKey validates the given license key and decodes its expiry (if possible).
+// An error is returned for keys that can never be valid (e.g. empty or containing whitespace).
+// A key with an unknown encoding is accepted, with a zero expiry.
+func ParseLicenseKey(key string) (License, error) {
+	key = strings.TrimSpace(key)
+	if key == "" {
+		return License{}, maskAny(fmt.Errorf("License key is empty"))
+	}
+	if strings.ContainsAny(key, " \t\r\n") {
+		return License{}, maskAny(fmt.Errorf("License key contains whitespace, it must be a single line"))
+	}
+	// A license key is a base64 encoded JSON object with a (base64 encoded) grant & a signature
+	var envelope struct {
+		Grant string `json:"grant"`
+	}
+	var grant struct {
+		Features struct {
+			Expires int64 `json:"expires"`
+		} `json:"features"`
+	}
+	content, err := base64.StdEncoding.DecodeString(key)
+	if err != nil || json.Unmarshal(content, &envelope) != nil || envelope.Grant == "" {
+		return License{}, nil
+	}
+	content, err = base64.StdEncoding.DecodeString(envelope.Grant)
+	if err != nil || json.Unmarshal(content, &grant) != nil || grant.Features.Expires == 0 {
+		return License{}, nil
+	}
+	return License{Expires: time.Unix(grant.Features.Expires, 0).UTC()}, nil
+}
+
+// Status returns the status of the license at the given time.
+func (l License) Status(now time.Time) string {
+	switch {
+	case l.Expires.IsZero():
+		return LicenseUnknown
+	case !now.Before(l.Expires):
+		return LicenseExpired
+	case l.Expires.Sub(now) < licenseExpiryWarning:
+		return LicenseExpiring
+	default:
+		return LicenseOK
+	}
+}
+
+// licenseHealth returns the health of the license key passed to the servers (nil if there is none).
+func (s *Service) licenseHealth() *LicenseHealth {
+	if s.LicenseKey == "" {
+		return nil
+	}
+	license, _ := ParseLicenseKey(s.LicenseKey)
+	health := &LicenseHealth{Status: license.Status(time.Now())}
+	if !license.Expires.IsZero() {
+		health.Expires = &license.Expires
+	}
+	return health
+}
+
+// watchLicenseExpiry logs a warning (once a day) when the license key expires soon or has expired.
+func (s *Service) watchLicenseExpiry() {
+	license, _ := ParseLicenseKey(s.LicenseKey)
+	if license.Expires.IsZero() {
+		s.log.Info("License key is passed to all servers, its expiry cannot be determined")
+		return
+	}
+	s.log.Infof("License key is passed to all servers, it expires at %s", license.Expires.Format(time.RFC3339))
+	for !s.stop {
+		switch license.Status(time.Now()) {
+		case LicenseExpired:
+			s.log.Warning(newLogEvent("license-expired", LogFields{"expires": license.Expires},
+				"License key has expired at %s, update --server.license-file (or --server.license-env)", license.Expires.Format(time.RFC3339)))
+		case LicenseExpiring:
+			s.log.Warning(newLogEvent("license-expiring", LogFields{"expires": license.Expires},
+				"License key expires at %s (in %d days), update --server.license-file (or --server.license-env)",
+				license.Expires.Format(time.RFC3339), int(time.Until(license.Expires).Hours()/24)))
+		}
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-time.After(time.Hour * 24):
+		}
+	}
+}
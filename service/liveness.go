@@ -0,0 +1,84 @@
+//
+// DISCLAIMER
+//
+// Copyright 2017 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+// Author Ewout Prangsma
+//
+
+package service
+
+import (
+	"context"
+	"net"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// probeLiveness periodically requests `/_api/version` of all servers of this starter that are up.
+// A server that does not respond in time LivenessFailures times in a row is considered hung
+// and its process is killed, so it is restarted (according to the restart policy).
+func (s *Service) probeLiveness() {
+	failures := make(map[ServerType]int)
+	for !s.stop {
+		select {
+		case <-time.After(s.LivenessInterval):
+		case <-s.ctx.Done():
+			return
+		}
+		for _, serverType := range []ServerType{ServerTypeAgent, ServerTypeDBServer, ServerTypeCoordinator, ServerTypeSingle} {
+			p := s.serverProcess(serverType)
+			if p == nil || !s.serverStates.get(serverType).Up {
+				failures[serverType] = 0
+				continue
+			}
+			ctx, cancel := context.WithTimeout(s.ctx, s.LivenessTimeout)
+			_, err := s.serverRequest(ctx, serverType, "GET", "/_api/version", nil)
+			timedOut := err != nil && isTimeout(ctx, err)
+			cancel()
+			if !timedOut {
+				failures[serverType] = 0
+				continue
+			}
+			failures[serverType]++
+			serverLog := s.serverLogger(serverType)
+			serverLog.Warningf("%s did not respond within %s (%d/%d)", serverType, s.LivenessTimeout, failures[serverType], s.LivenessFailures)
+			if failures[serverType] < s.LivenessFailures {
+				continue
+			}
+			failures[serverType] = 0
+			serverLog.Error(newLogEvent("server-hung", LogFields{"timeout": s.LivenessTimeout.String(), "failures": s.LivenessFailures},
+				"%s is hung, it did not respond %d times in a row, killing it", serverType, s.LivenessFailures))
+			s.serverStates.setDown(serverType)
+			if err := p.Kill(); err != nil {
+				serverLog.Errorf("Failed to kill %s: %v", serverType, err)
+			}
+		}
+	}
+}
+
+// isTimeout returns true if the given error of a request with given context is caused by a timeout.
+func isTimeout(ctx context.Context, err error) bool {
+	if ctx.Err() == context.DeadlineExceeded {
+		return true
+	}
+	if netErr, ok := errors.Cause(err).(net.Error); ok && netErr.Timeout() {
+		return true
+	}
+	return false
+}
@@ -0,0 +1,101 @@
+//
+// DISCLAIMER
+//
+// Copyright 2017 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+// Author Ewout Prangsma
+//
+
+package service
+
+import (
+	"fmt"
+	"path/filepath"
+)
+
+// LocalClusterSize holds the number of servers of each type in a local test cluster.
+// The zero value means that every local starter runs an agent (up to the agency size),
+// a dbserver and a coordinator.
+type LocalClusterSize struct {
+	Agents       int `json:"agents"`
+	DBServers    int `json:"dbservers"`
+	Coordinators int `json:"coordinators"`
+}
+
+// IsSet returns true if the number of servers of a local test cluster has been configured.
+func (c LocalClusterSize) IsSet() bool {
+	return c.Agents > 0
+}
+
+// Peers returns the number of local starters (the master and its local slaves) that are needed
+// to run all servers.
+func (c LocalClusterSize) Peers() int {
+	result := c.Agents
+	if c.DBServers > result {
+		result = c.DBServers
+	}
+	if c.Coordinators > result {
+		result = c.Coordinators
+	}
+	return result
+}
+
+// String returns a human readable description of the number of servers.
+func (c LocalClusterSize) String() string {
+	return fmt.Sprintf("%d agents, %d dbservers, %d coordinators", c.Agents, c.DBServers, c.Coordinators)
+}
+
+// runs returns whether the local starter with given index (the master has index 0) runs a dbserver
+// and a coordinator. Local slaves beyond the configured counts (added at runtime) run both.
+func (c LocalClusterSize) runs(index int) (dbserver, coordinator bool) {
+	if !c.IsSet() || index >= c.Peers() {
+		return true, true
+	}
+	return index < c.DBServers, index < c.Coordinators
+}
+
+// localPeerCount returns the number of local starters (the master and its local slaves) of a local test cluster.
+func (s *Service) localPeerCount() int {
+	if s.LocalServers.IsSet() {
+		return s.LocalServers.Peers()
+	}
+	return s.AgencySize
+}
+
+// applyLocalServers decides which servers the local slave with given data directory runs,
+// according to the configured number of servers of the local test cluster.
+func (s *Service) applyLocalServers(config *Config, dataDir string) {
+	var index int
+	if _, err := fmt.Sscanf(filepath.Base(dataDir), "local-slave-%d", &index); err != nil {
+		return
+	}
+	dbserver, coordinator := s.LocalServers.runs(index)
+	config.StartDBserver = config.StartDBserver && dbserver
+	config.StartCoordinator = config.StartCoordinator && coordinator
+}
+
+// checkRecordedLocalServers makes the number of servers recorded in setup.json the number of servers
+// of this local test cluster. It cannot change once the local slaves have been started.
+func (s *Service) checkRecordedLocalServers(recorded *LocalClusterSize) {
+	if recorded == nil {
+		return
+	}
+	if s.LocalServers.IsSet() && s.LocalServers != *recorded {
+		s.log.Warningf("Ignoring the configured number of servers (%s), using the number of servers recorded in %s (%s)", s.LocalServers, setupFileName, *recorded)
+	}
+	s.LocalServers = *recorded
+}
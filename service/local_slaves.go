@@ -34,7 +34,7 @@ import (
 // createAndStartLocalSlaves creates additional peers for local slaves and starts services for them.
 func (s *Service) createAndStartLocalSlaves(wg *sync.WaitGroup) {
 	peers := make([]Peer, 0, s.AgencySize)
-	for index := 2; index <= s.AgencySize; index++ {
+	for index := 2; index <= s.localPeerCount(); index++ {
 		p := Peer{}
 		var err error
 		p.ID, err = createUniqueID()
@@ -50,10 +50,20 @@ func (s *Service) createAndStartLocalSlaves(wg *sync.WaitGroup) {
 
 // startLocalSlaves starts additional services for local slaves based on the given peers.
 func (s *Service) startLocalSlaves(wg *sync.WaitGroup, peers []Peer) {
-	s.log = s.mustCreateIDLogger(s.ID)
-	s.log.Infof("Starting %d local slaves...", len(peers)-1)
+	s.logWithID = true
+	s.initLoggers()
+	count := 0
+	for _, p := range peers {
+		if p.ID != s.ID {
+			count++
+		}
+	}
+	s.log.Infof("Starting %d local slaves...", count)
 	masterAddr := s.OwnAddress
-	if masterAddr == "" {
+	if ip := net.ParseIP(s.BindAddress); ip != nil && !ip.IsUnspecified() {
+		// The master only listens on its bind address
+		masterAddr = s.BindAddress
+	} else if masterAddr == "" {
 		masterAddr = "127.0.0.1"
 	}
 	masterAddr = net.JoinHostPort(masterAddr, strconv.Itoa(s.announcePort))
@@ -66,12 +76,21 @@ func (s *Service) startLocalSlaves(wg *sync.WaitGroup, peers []Peer) {
 		config.DataDir = p.DataDir
 		config.MasterAddress = masterAddr
 		config.StartLocalSlaves = false
+		config.BackupSchedule = ""     // Backups are created by the master only
+		config.RecoveryFromBackup = "" // The deployment is recovered by the master only
+		config.StarterListen = ""      // The unix socket is served by the master only
+		config.ProxyPort = 0           // The coordinator proxy is run by the master only
+		s.applyLocalServers(&config, p.DataDir)
 		os.MkdirAll(config.DataDir, 0755)
-		slaveService, err := NewService(s.mustCreateIDLogger(config.ID), config, true)
+		slaveService, err := NewService(config, asLocalSlave(), WithRunner(s.customRunner),
+			WithReloader(s.reloader), WithLogLevelSetter(s.logLevelSetter), WithConfigProvider(s.configProvider))
 		if err != nil {
 			s.log.Errorf("Failed to create local slave service %d: %#v", index, err)
 			continue
 		}
+		s.mutex.Lock()
+		s.localSlaves = append(s.localSlaves, slaveService)
+		s.mutex.Unlock()
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
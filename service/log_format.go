@@ -0,0 +1,114 @@
+//
+// DISCLAIMER
+//
+// Copyright 2017 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+// Author Ewout Prangsma
+//
+
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	logging "github.com/op/go-logging"
+)
+
+const (
+	// LogFormatText is the human readable log format (default).
+	LogFormatText = "text"
+	// LogFormatJSON is the machine readable log format, emitting one JSON object per line.
+	LogFormatJSON = "json"
+)
+
+// LogFields holds structured fields of a log entry.
+type LogFields map[string]interface{}
+
+// LogEvent is a log message with a name & structured fields, used for messages that
+// tools may want to act upon (e.g. a server that has become ready).
+// In the text log format only the message is shown, in the JSON log format
+// the event name and all fields are included in the log entry.
+type LogEvent struct {
+	Event   string
+	Message string
+	Fields  LogFields
+}
+
+// newLogEvent creates a new LogEvent with a formatted message.
+func newLogEvent(event string, fields LogFields, format string, args ...interface{}) LogEvent {
+	return LogEvent{
+		Event:   event,
+		Message: fmt.Sprintf(format, args...),
+		Fields:  fields,
+	}
+}
+
+// String returns the message of the event.
+func (e LogEvent) String() string {
+	return e.Message
+}
+
+// NewJSONLogBackend creates a logging backend that writes every log record
+// as a single line JSON object to the given writer.
+// The given fields are added to every entry.
+func NewJSONLogBackend(w io.Writer, fields LogFields) logging.Backend {
+	return &jsonLogBackend{
+		w:      w,
+		fields: fields,
+	}
+}
+
+type jsonLogBackend struct {
+	mutex  sync.Mutex
+	w      io.Writer
+	fields LogFields
+}
+
+// Log implements logging.Backend.
+func (b *jsonLogBackend) Log(level logging.Level, calldepth int, rec *logging.Record) error {
+	entry := make(LogFields)
+	for k, v := range b.fields {
+		entry[k] = v
+	}
+	for _, arg := range rec.Args {
+		if e, ok := arg.(LogEvent); ok {
+			for k, v := range e.Fields {
+				entry[k] = v
+			}
+			entry["event"] = e.Event
+		}
+	}
+	entry["time"] = rec.Time.Format(time.RFC3339Nano)
+	entry["level"] = strings.ToLower(level.String())
+	entry["module"] = rec.Module
+	entry["message"] = rec.Message()
+	encoded, err := json.Marshal(entry)
+	if err != nil {
+		return maskAny(err)
+	}
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if _, err := b.w.Write(append(encoded, '\n')); err != nil {
+		return maskAny(err)
+	}
+	return nil
+}
@@ -0,0 +1,275 @@
+//
+// DISCLAIMER
+//
+// Copyright 2017 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+// Author Ewout Prangsma
+//
+
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// LogForwardSyslog forwards server logs to the local syslog daemon.
+	LogForwardSyslog = "syslog"
+	// LogForwardJournald forwards server logs to the local systemd journal.
+	LogForwardJournald = "journald"
+
+	logForwardTCPPrefix   = "tcp://"
+	logForwardInterval    = time.Second // Interval between reads of the server log files
+	journaldSocket        = "/run/systemd/journal/socket"
+	logForwardIdentifier  = "arangod"
+	logForwardDialTimeout = time.Second * 5
+)
+
+// logForwarder sends lines of the log files of servers to an external log system.
+type logForwarder interface {
+	// Forward sends a single log line of the server with given type, started by the peer with given ID.
+	Forward(peerID string, serverType ServerType, line string) error
+	// Close releases all resources of the forwarder.
+	Close() error
+}
+
+// ValidateLogForward checks the given log forwarding target (syslog|journald|tcp://host:port).
+func ValidateLogForward(target string) error {
+	switch {
+	case target == LogForwardSyslog, target == LogForwardJournald:
+		return nil
+	case strings.HasPrefix(target, logForwardTCPPrefix):
+		if _, _, err := net.SplitHostPort(strings.TrimPrefix(target, logForwardTCPPrefix)); err != nil {
+			return maskAny(fmt.Errorf("Invalid TCP address in '%s': %v", target, err))
+		}
+		return nil
+	default:
+		return maskAny(fmt.Errorf("Unknown log forwarding target '%s', expected syslog, journald or tcp://host:port", target))
+	}
+}
+
+// newLogForwarder creates a forwarder for the given target.
+func newLogForwarder(target string) (logForwarder, error) {
+	if err := ValidateLogForward(target); err != nil {
+		return nil, maskAny(err)
+	}
+	switch {
+	case target == LogForwardSyslog:
+		return newSyslogForwarder()
+	case target == LogForwardJournald:
+		return &journaldForwarder{}, nil
+	default:
+		return &tcpForwarder{address: strings.TrimPrefix(target, logForwardTCPPrefix)}, nil
+	}
+}
+
+// usesDockerLogDriver returns true if the logs of the servers are forwarded by the log driver
+// of their docker containers, instead of by the starter.
+// Logs forwarded to a TCP target are always sent by the starter, so they use the same (JSON) format
+// for all runners.
+func (s *Service) usesDockerLogDriver() bool {
+	return s.DockerImage != "" && (s.LogForward == LogForwardSyslog || s.LogForward == LogForwardJournald)
+}
+
+// logLineLevel returns the level (error|warning|debug|info) of the given arangod log line.
+func logLineLevel(line string) string {
+	switch {
+	case strings.Contains(line, " FATAL ") || strings.Contains(line, " ERROR "):
+		return "error"
+	case strings.Contains(line, " WARNING "):
+		return "warning"
+	case strings.Contains(line, " DEBUG ") || strings.Contains(line, " TRACE "):
+		return "debug"
+	default:
+		return "info"
+	}
+}
+
+// forwardServerLogs tails the log files of all servers started by this starter
+// and forwards new lines to the configured log forwarding target.
+func (s *Service) forwardServerLogs() {
+	forwarder, err := newLogForwarder(s.LogForward)
+	if err != nil {
+		s.log.Errorf("Cannot forward server logs: %v", err)
+		return
+	}
+	defer forwarder.Close()
+	tails := make(map[ServerType]*logTail)
+	failing := false
+	for !s.stop {
+		for _, serverType := range []ServerType{ServerTypeAgent, ServerTypeDBServer, ServerTypeCoordinator, ServerTypeSingle} {
+			if s.serverProcess(serverType) == nil {
+				continue
+			}
+			tail, found := tails[serverType]
+			if !found {
+				myHostDir, err := s.serverHostDir(serverType)
+				if err != nil {
+					continue
+				}
+				tail = newLogTail(filepath.Join(myHostDir, logFileName))
+				tails[serverType] = tail
+			}
+			for _, line := range tail.readLines() {
+				if err := forwarder.Forward(s.ID, serverType, line); err != nil {
+					if !failing {
+						s.log.Warningf("Failed to forward log of %s: %v", serverType, err)
+					}
+					failing = true
+				} else {
+					failing = false
+				}
+			}
+		}
+		time.Sleep(logForwardInterval)
+	}
+}
+
+// logTail reads lines that are appended to a log file.
+type logTail struct {
+	path    string
+	offset  int64
+	partial string
+}
+
+// newLogTail creates a tail for the file at given path.
+// Content that already exists in the file is skipped.
+func newLogTail(path string) *logTail {
+	t := &logTail{path: path}
+	if info, err := os.Stat(path); err == nil {
+		t.offset = info.Size()
+	}
+	return t
+}
+
+// readLines returns all complete lines that have been appended to the file since the last call.
+// When the file has been truncated or rotated, reading restarts at its beginning.
+func (t *logTail) readLines() []string {
+	info, err := os.Stat(t.path)
+	if err != nil {
+		return nil
+	}
+	if info.Size() < t.offset {
+		t.offset = 0
+		t.partial = ""
+	}
+	if info.Size() == t.offset {
+		return nil
+	}
+	f, err := os.Open(t.path)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	if _, err := f.Seek(t.offset, io.SeekStart); err != nil {
+		return nil
+	}
+	buf := make([]byte, info.Size()-t.offset)
+	n, _ := io.ReadFull(f, buf)
+	t.offset += int64(n)
+	lines := strings.Split(t.partial+string(buf[:n]), "\n")
+	t.partial = lines[len(lines)-1]
+	return lines[:len(lines)-1]
+}
+
+// journaldForwarder forwards log lines to the systemd journal, using its native protocol.
+type journaldForwarder struct {
+	conn net.Conn
+}
+
+// Forward implements logForwarder.
+func (f *journaldForwarder) Forward(peerID string, serverType ServerType, line string) error {
+	if f.conn == nil {
+		conn, err := net.Dial("unixgram", journaldSocket)
+		if err != nil {
+			return maskAny(err)
+		}
+		f.conn = conn
+	}
+	priority := map[string]int{"error": 3, "warning": 4, "info": 6, "debug": 7}[logLineLevel(line)]
+	msg := fmt.Sprintf("MESSAGE=%s\nPRIORITY=%d\nSYSLOG_IDENTIFIER=%s\nARANGODB_PEER_ID=%s\nARANGODB_SERVER_TYPE=%s\n",
+		line, priority, logForwardIdentifier, peerID, serverType)
+	if _, err := f.conn.Write([]byte(msg)); err != nil {
+		f.Close()
+		return maskAny(err)
+	}
+	return nil
+}
+
+// Close implements logForwarder.
+func (f *journaldForwarder) Close() error {
+	if f.conn != nil {
+		f.conn.Close()
+		f.conn = nil
+	}
+	return nil
+}
+
+// tcpForwarder forwards log lines as JSON objects (one per line) over a TCP connection.
+type tcpForwarder struct {
+	mutex   sync.Mutex
+	address string
+	conn    net.Conn
+}
+
+// Forward implements logForwarder.
+func (f *tcpForwarder) Forward(peerID string, serverType ServerType, line string) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if f.conn == nil {
+		conn, err := net.DialTimeout("tcp", f.address, logForwardDialTimeout)
+		if err != nil {
+			return maskAny(err)
+		}
+		f.conn = conn
+	}
+	encoded, err := json.Marshal(LogFields{
+		"time":        time.Now().Format(time.RFC3339Nano),
+		"peer-id":     peerID,
+		"server-type": serverType,
+		"level":       logLineLevel(line),
+		"message":     line,
+	})
+	if err != nil {
+		return maskAny(err)
+	}
+	if _, err := f.conn.Write(append(encoded, '\n')); err != nil {
+		f.conn.Close()
+		f.conn = nil
+		return maskAny(err)
+	}
+	return nil
+}
+
+// Close implements logForwarder.
+func (f *tcpForwarder) Close() error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if f.conn != nil {
+		f.conn.Close()
+		f.conn = nil
+	}
+	return nil
+}
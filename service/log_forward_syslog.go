@@ -0,0 +1,67 @@
+//
+// DISCLAIMER
+//
+// Copyright 2017 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+// Author Ewout Prangsma
+//
+
+//go:build !windows
+// +build !windows
+
+package service
+
+import (
+	"fmt"
+	"log/syslog"
+)
+
+// syslogForwarder forwards log lines to the local syslog daemon.
+type syslogForwarder struct {
+	w *syslog.Writer
+}
+
+// newSyslogForwarder creates a forwarder connected to the local syslog daemon.
+func newSyslogForwarder() (logForwarder, error) {
+	w, err := syslog.New(syslog.LOG_INFO|syslog.LOG_DAEMON, logForwardIdentifier)
+	if err != nil {
+		return nil, maskAny(err)
+	}
+	return &syslogForwarder{w: w}, nil
+}
+
+// Forward implements logForwarder.
+func (f *syslogForwarder) Forward(peerID string, serverType ServerType, line string) error {
+	msg := fmt.Sprintf("peer-id=%s server-type=%s %s", peerID, serverType, line)
+	var err error
+	switch logLineLevel(line) {
+	case "error":
+		err = f.w.Err(msg)
+	case "warning":
+		err = f.w.Warning(msg)
+	case "debug":
+		err = f.w.Debug(msg)
+	default:
+		err = f.w.Info(msg)
+	}
+	return maskAny(err)
+}
+
+// Close implements logForwarder.
+func (f *syslogForwarder) Close() error {
+	return maskAny(f.w.Close())
+}
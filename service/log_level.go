@@ -0,0 +1,140 @@
+//
+// DISCLAIMER
+//
+// Copyright 2017 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+// Author Ewout Prangsma
+//
+
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	logging "github.com/op/go-logging"
+)
+
+// LogLevelSetter changes the log levels of the starter.
+// The levels use the syntax of the `--log.level` option (e.g. `info,runner=debug`).
+type LogLevelSetter func(levels string) error
+
+// LogLevelRequest is the JSON body of a PUT `/logs/level` request.
+type LogLevelRequest struct {
+	Starter string            `json:"starter,omitempty"` // New log levels of the starter, using the syntax of `--log.level`
+	Servers map[string]string `json:"servers,omitempty"` // New log levels (per topic) of all servers started by the starter
+}
+
+// LogLevelResponse is the JSON response of a `/logs/level` request.
+type LogLevelResponse struct {
+	Starter map[string]string            `json:"starter"`           // Log level per component of the starter
+	Servers map[string]map[string]string `json:"servers,omitempty"` // Log level per topic, per server type
+	Errors  map[string]string            `json:"errors,omitempty"`  // Errors per server type
+}
+
+// SetLogLevelSetter sets the function used to change the log levels of the starter.
+func (s *Service) SetLogLevelSetter(setter LogLevelSetter) {
+	s.logLevelSetter = setter
+}
+
+// logLevelHandler shows (GET) or changes (PUT) the log levels of the starter and
+// the servers started by it.
+func (s *Service) logLevelHandler(w http.ResponseWriter, r *http.Request) {
+	var serversBody []byte
+	switch r.Method {
+	case "GET":
+		// Show current levels
+	case "PUT":
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		var req LogLevelRequest
+		if err := json.Unmarshal(body, &req); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if req.Starter != "" {
+			if s.logLevelSetter == nil {
+				writeError(w, http.StatusNotImplemented, "Changing the log level is not supported")
+				return
+			}
+			if err := s.logLevelSetter(req.Starter); err != nil {
+				writeError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			s.apiLog.Infof("Changed log level to '%s'", req.Starter)
+		}
+		if len(req.Servers) > 0 {
+			serversBody, _ = json.Marshal(req.Servers)
+		}
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "GET or PUT required")
+		return
+	}
+
+	resp := LogLevelResponse{
+		Starter: make(map[string]string),
+	}
+	for _, component := range LogComponents {
+		resp.Starter[component] = strings.ToLower(logging.GetLevel(component).String())
+	}
+	if r.Method == "GET" || serversBody != nil {
+		method := r.Method
+		for _, serverType := range []ServerType{ServerTypeAgent, ServerTypeDBServer, ServerTypeCoordinator, ServerTypeSingle} {
+			if s.serverProcess(serverType) == nil {
+				continue
+			}
+			levels, err := s.serverLogLevels(r.Context(), serverType, method, serversBody)
+			if err != nil {
+				if resp.Errors == nil {
+					resp.Errors = make(map[string]string)
+				}
+				resp.Errors[string(serverType)] = err.Error()
+				continue
+			}
+			if resp.Servers == nil {
+				resp.Servers = make(map[string]map[string]string)
+			}
+			resp.Servers[string(serverType)] = levels
+		}
+	}
+	b, err := json.Marshal(resp)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+	} else {
+		w.Write(b)
+	}
+}
+
+// serverLogLevels fetches (GET) or changes (PUT) the log levels of the server of given type,
+// using its `/_admin/log/level` API.
+func (s *Service) serverLogLevels(ctx context.Context, serverType ServerType, method string, body []byte) (map[string]string, error) {
+	content, err := s.serverRequest(ctx, serverType, method, "/_admin/log/level", body)
+	if err != nil {
+		return nil, maskAny(err)
+	}
+	var levels map[string]string
+	if err := json.Unmarshal(content, &levels); err != nil {
+		return nil, maskAny(err)
+	}
+	return levels, nil
+}
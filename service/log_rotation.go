@@ -0,0 +1,214 @@
+//
+// DISCLAIMER
+//
+// Copyright 2017 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+// Author Ewout Prangsma
+//
+
+package service
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	logRotateInterval      = time.Second * 30       // Interval between checks of the size of the arangod log files
+	logReopenTimeout       = time.Second * 30       // Maximum time a server has to start writing to a new log file after a rotation
+	logReopenCheckInterval = time.Millisecond * 100 // Interval between checks of a reopened log file
+)
+
+// LogRotateResponse is the JSON response of a POST `/logs/rotate` request.
+type LogRotateResponse struct {
+	Rotated  []ServerType      `json:"rotated,omitempty"`  // Servers whose log file has been rotated by the starter
+	Reopened []ServerType      `json:"reopened,omitempty"` // Servers that have been asked to reopen their log file
+	Errors   map[string]string `json:"errors,omitempty"`   // Errors per server type
+}
+
+// rotateServerLogs checks the size of the log files of all servers started by this starter
+// and rotates them once they have grown beyond the configured size.
+func (s *Service) rotateServerLogs() {
+	for !s.stop {
+		for _, serverType := range []ServerType{ServerTypeAgent, ServerTypeDBServer, ServerTypeCoordinator, ServerTypeSingle} {
+			p := s.serverProcess(serverType)
+			if p == nil {
+				continue
+			}
+			myHostDir, err := s.serverHostDir(serverType)
+			if err != nil {
+				continue
+			}
+			logPath := filepath.Join(myHostDir, logFileName)
+			if info, err := os.Stat(logPath); err != nil || info.Size() < s.LogRotateSize {
+				continue
+			}
+			serverLog := s.serverLogger(serverType)
+			serverLog.Infof("Rotating log file of %s", serverType)
+			s.logRotateMutex.Lock()
+			err = rotateLogFile(logPath, s.LogRotateFiles, p)
+			s.logRotateMutex.Unlock()
+			if err != nil {
+				serverLog.Errorf("Failed to rotate log file of %s: %v", serverType, err)
+			}
+		}
+		time.Sleep(logRotateInterval)
+	}
+}
+
+// logRotateHandler rotates the log files of all servers started by this starter (when
+// `--log.rotate-size` is set), or asks these servers to reopen their log files, so an
+// external tool (e.g. logrotate) that has moved the files away needs to call only this endpoint.
+func (s *Service) logRotateHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		writeError(w, http.StatusMethodNotAllowed, "POST required")
+		return
+	}
+
+	s.logRotateMutex.Lock()
+	defer s.logRotateMutex.Unlock()
+	var resp LogRotateResponse
+	addError := func(serverType ServerType, err error) {
+		if resp.Errors == nil {
+			resp.Errors = make(map[string]string)
+		}
+		resp.Errors[string(serverType)] = err.Error()
+	}
+	for _, serverType := range []ServerType{ServerTypeAgent, ServerTypeDBServer, ServerTypeCoordinator, ServerTypeSingle} {
+		p := s.serverProcess(serverType)
+		if p == nil {
+			continue
+		}
+		serverLog := s.serverLogger(serverType)
+		if s.LogRotateSize > 0 {
+			myHostDir, err := s.serverHostDir(serverType)
+			if err != nil {
+				addError(serverType, err)
+				continue
+			}
+			serverLog.Infof("Rotating log file of %s (requested by %s)", serverType, r.RemoteAddr)
+			if err := rotateLogFile(filepath.Join(myHostDir, logFileName), s.LogRotateFiles, p); err != nil {
+				serverLog.Errorf("Failed to rotate log file of %s: %v", serverType, err)
+				addError(serverType, err)
+				continue
+			}
+			resp.Rotated = append(resp.Rotated, serverType)
+		} else {
+			serverLog.Infof("Asking %s to reopen its log file (requested by %s)", serverType, r.RemoteAddr)
+			if err := p.ReopenLogs(); err != nil {
+				serverLog.Errorf("Failed to ask %s to reopen its log file: %v", serverType, err)
+				addError(serverType, err)
+				continue
+			}
+			resp.Reopened = append(resp.Reopened, serverType)
+		}
+	}
+
+	b, err := json.Marshal(resp)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if len(resp.Errors) > 0 {
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+	w.Write(b)
+}
+
+// rotateLogFile renames the log file at given path to `<path>.1`, asks the given process to
+// reopen its log file and compresses the renamed file into `<path>.1.gz`, once the process
+// writes to the new log file.
+// Existing archives are renamed to `<path>.<n+1>.gz`, keeping at most maxArchives archives.
+func rotateLogFile(path string, maxArchives int, p Process) error {
+	archiveName := func(n int) string {
+		return fmt.Sprintf("%s.%d.gz", path, n)
+	}
+	// Remove the oldest archive(s) and move the others up
+	os.Remove(archiveName(maxArchives))
+	for i := maxArchives - 1; i >= 1; i-- {
+		if err := os.Rename(archiveName(i), archiveName(i+1)); err != nil && !os.IsNotExist(err) {
+			return maskAny(err)
+		}
+	}
+	rotatedPath := path + ".1"
+	if err := os.Rename(path, rotatedPath); err != nil {
+		return maskAny(err)
+	}
+	if err := p.ReopenLogs(); err != nil {
+		return maskAny(err)
+	}
+	// Lines written before the process has reopened its log file still end up in the renamed file
+	if err := waitForLogReopen(path, logReopenTimeout); err != nil {
+		return maskAny(fmt.Errorf("%v, keeping %s", err, rotatedPath))
+	}
+	if maxArchives < 1 {
+		// Keep no archives
+		return maskAny(os.Remove(rotatedPath))
+	}
+	if err := compressFile(rotatedPath, archiveName(1)); err != nil {
+		return maskAny(err)
+	}
+	if err := os.Remove(rotatedPath); err != nil {
+		return maskAny(err)
+	}
+	return nil
+}
+
+// waitForLogReopen waits until a new log file exists at given path and has been written to.
+func waitForLogReopen(path string, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		if info, err := os.Stat(path); err == nil && info.Size() > 0 {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return maskAny(fmt.Errorf("Log file %s has not been reopened within %s", path, timeout))
+		}
+		time.Sleep(logReopenCheckInterval)
+	}
+}
+
+// compressFile writes a gzip compressed copy of the file at given source path to the given destination path.
+func compressFile(sourcePath, destPath string) error {
+	source, err := os.Open(sourcePath)
+	if err != nil {
+		return maskAny(err)
+	}
+	defer source.Close()
+	dest, err := os.OpenFile(destPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return maskAny(err)
+	}
+	defer dest.Close()
+	w := gzip.NewWriter(dest)
+	if _, err := io.Copy(w, source); err != nil {
+		return maskAny(err)
+	}
+	if err := w.Close(); err != nil {
+		return maskAny(err)
+	}
+	if err := dest.Close(); err != nil {
+		return maskAny(err)
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+//
+// DISCLAIMER
+//
+// Copyright 2017 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+// Author Ewout Prangsma
+//
+
+package service
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// logWritingProcess is a Process that writes to a log file and reopens it (with a delay) when asked to.
+type logWritingProcess struct {
+	Process
+	path string
+	f    *os.File
+}
+
+// ReopenLogs writes a line to the old (renamed) log file before switching to a new one,
+// like a server that is still logging while it receives the signal to reopen its log file.
+func (p *logWritingProcess) ReopenLogs() error {
+	go func() {
+		time.Sleep(logReopenCheckInterval * 3)
+		p.f.WriteString("late line\n")
+		p.f.Close()
+		f, err := os.OpenFile(p.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+		if err != nil {
+			return
+		}
+		p.f = f
+		p.f.WriteString("new line\n")
+	}()
+	return nil
+}
+
+// TestRotateLogFile checks that no log lines are lost when a log file is rotated.
+func TestRotateLogFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "log-rotation")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, logFileName)
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("Failed to create log file: %v", err)
+	}
+	f.WriteString("old line\n")
+	p := &logWritingProcess{path: path, f: f}
+	defer func() { p.f.Close() }()
+
+	if err := rotateLogFile(path, 2, p); err != nil {
+		t.Fatalf("Failed to rotate log file: %v", err)
+	}
+
+	archive, err := os.Open(path + ".1.gz")
+	if err != nil {
+		t.Fatalf("Failed to open archive: %v", err)
+	}
+	defer archive.Close()
+	r, err := gzip.NewReader(archive)
+	if err != nil {
+		t.Fatalf("Failed to read archive: %v", err)
+	}
+	if content, err := ioutil.ReadAll(r); err != nil {
+		t.Errorf("Failed to read archive: %v", err)
+	} else if string(content) != "old line\nlate line\n" {
+		t.Errorf("Unexpected content of archive: %q", content)
+	}
+	if content, err := ioutil.ReadFile(path); err != nil {
+		t.Errorf("Failed to read new log file: %v", err)
+	} else if string(content) != "new line\n" {
+		t.Errorf("Unexpected content of new log file: %q", content)
+	}
+	if _, err := os.Stat(path + ".1"); !os.IsNotExist(err) {
+		t.Errorf("Rotated log file still exists")
+	}
+}
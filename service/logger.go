@@ -0,0 +1,83 @@
+//
+// DISCLAIMER
+//
+// Copyright 2017 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+// Author Ewout Prangsma
+//
+
+package service
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	logging "github.com/op/go-logging"
+)
+
+const (
+	// LogComponentService is the log component (module) of the service itself.
+	LogComponentService = "service"
+	// LogComponentRunner is the log component (module) of the process & docker runners.
+	LogComponentRunner = "runner"
+	// LogComponentPeers is the log component (module) of the communication between peers.
+	LogComponentPeers = "peers"
+	// LogComponentAPI is the log component (module) of the HTTP API.
+	LogComponentAPI = "api"
+)
+
+// LogComponents contains the names of all log components.
+// Each component is a logging module, which has its own log level.
+var LogComponents = []string{LogComponentService, LogComponentRunner, LogComponentPeers, LogComponentAPI}
+
+// initLoggers (re)creates the loggers of all components of the service.
+// It must be called when the ID of the service has changed.
+func (s *Service) initLoggers() {
+	s.log = s.createLogger(LogComponentService, nil)
+	s.peersLog = s.createLogger(LogComponentPeers, nil)
+	s.apiLog = s.createLogger(LogComponentAPI, nil)
+}
+
+// serverLogger creates a logger for messages about the server of given type.
+func (s *Service) serverLogger(serverType ServerType) *logging.Logger {
+	return s.createLogger(LogComponentService, LogFields{"server-type": serverType})
+}
+
+// createLogger creates a logger for the given component.
+// In the JSON log format, each entry contains the ID of this peer and the given fields.
+// In the text log format, each line is prefixed with the ID of this peer when logWithID is set.
+func (s *Service) createLogger(component string, fields LogFields) *logging.Logger {
+	var backend logging.Backend
+	if s.LogFormat == LogFormatJSON {
+		allFields := LogFields{"peer-id": s.ID}
+		for k, v := range fields {
+			allFields[k] = v
+		}
+		backend = NewJSONLogBackend(os.Stderr, allFields)
+	} else {
+		prefix := ""
+		if s.logWithID {
+			prefix = fmt.Sprintf("[%s] ", s.ID)
+		}
+		backend = logging.NewBackendFormatter(logging.NewLogBackend(os.Stderr, "", log.LstdFlags), logging.MustStringFormatter(prefix+"%{message}"))
+	}
+	// Levels are taken from the default backend (see logging.SetLevel)
+	l := logging.MustGetLogger(component)
+	l.SetBackend(logging.MultiLogger(backend, s.recentLog))
+	return l
+}
@@ -24,13 +24,10 @@ package service
 
 import (
 	"fmt"
-	"log"
-	"os"
+	"net"
 	"strconv"
 	"sync"
 	"time"
-
-	logging "github.com/op/go-logging"
 )
 
 // startMaster starts the Service as master.
@@ -40,26 +37,36 @@ func (s *Service) startMaster(runner Runner) {
 	if err != nil {
 		s.log.Fatalf("Cannot find HTTP server info: %#v", err)
 	}
-	if !IsPortOpen(containerHTTPPort) {
+	if !(s.isSingleMode() && s.StarterListen != "") && !IsPortOpen(s.starterBindAddress(), containerHTTPPort) {
 		s.log.Fatalf("Port %d is already in use", containerHTTPPort)
 	}
 
+	// A new deployment is recovered from a backup once it is up
+	if s.RecoveryFromBackup != "" {
+		s.recoveryDone = make(chan struct{})
+	}
+
 	// Start HTTP listener
 	s.startHTTPServer()
 
 	// Permanent loop:
-	s.log.Infof("Serving as master with ID '%s' on %s:%d...", s.ID, s.OwnAddress, s.announcePort)
+	s.log.Infof("Serving as master with ID '%s' on %s...", s.ID, net.JoinHostPort(s.OwnAddress, strconv.Itoa(s.announcePort)))
 
 	if s.AgencySize == 1 {
+		serverPorts, err := s.freeServerPorts(s.OwnAddress, !s.isSingleMode())
+		if err != nil {
+			s.log.Fatalf("Cannot assign server ports: %v", err)
+		}
 		s.myPeers.Peers = []Peer{
 			Peer{
-				ID:         s.ID,
-				Address:    s.OwnAddress,
-				Port:       s.announcePort,
-				PortOffset: 0,
-				DataDir:    s.DataDir,
-				HasAgent:   !s.isSingleMode(),
-				IsSecure:   s.IsSecure(),
+				ID:          s.ID,
+				Address:     s.OwnAddress,
+				Port:        s.announcePort,
+				PortOffset:  0,
+				DataDir:     s.DataDir,
+				HasAgent:    !s.isSingleMode(),
+				IsSecure:    s.IsSecure(),
+				ServerPorts: serverPorts,
 			},
 		}
 		s.myPeers.AgencySize = s.AgencySize
@@ -69,6 +76,7 @@ func (s *Service) startMaster(runner Runner) {
 		return
 	}
 
+	waitSpan := s.bootstrapSpan.child("wait for peers", map[string]string{"agency-size": strconv.Itoa(s.AgencySize)})
 	wg := sync.WaitGroup{}
 	if s.StartLocalSlaves {
 		// Start additional local slaves
@@ -80,19 +88,23 @@ func (s *Service) startMaster(runner Runner) {
 	}
 
 	for {
-		time.Sleep(time.Second)
+		// Start as soon as the agency is complete
 		select {
 		case <-s.startRunningWaiter.Done():
+			waitSpan.finish(nil)
 			s.saveSetup()
 			s.log.Info("Starting service...")
 			s.startRunning(runner)
+			// Wait for any local slaves to return.
+			wg.Wait()
 			return
-		default:
+		case <-time.After(time.Second):
 		}
 		if s.stop {
 			break
 		}
 	}
+	waitSpan.finish(fmt.Errorf("Stopped while waiting for peers"))
 	// Wait for any local slaves to return.
 	wg.Wait()
 }
@@ -100,22 +112,20 @@ func (s *Service) startMaster(runner Runner) {
 // showSlaveStartCommands prints out the commands needed to start additional slaves.
 func (s *Service) showSlaveStartCommands(runner Runner) {
 	s.log.Infof("Use the following commands to start other servers:")
-	fmt.Println()
+	if s.LogFormat != LogFormatJSON {
+		fmt.Println()
+	}
 	for index := 2; index <= s.AgencySize; index++ {
 		port := ""
 		if s.announcePort != s.MasterPort {
 			port = strconv.Itoa(s.announcePort)
 		}
-		fmt.Println(runner.CreateStartArangodbCommand(s.DataDir, index, s.OwnAddress, port))
-		fmt.Println()
+		command := runner.CreateStartArangodbCommand(s.DataDir, index, s.OwnAddress, port)
+		if s.LogFormat == LogFormatJSON {
+			s.log.Info(newLogEvent("start-command", LogFields{"command": command}, "%s", command))
+		} else {
+			fmt.Println(command)
+			fmt.Println()
+		}
 	}
 }
-
-// mustCreateIDLogger creates a logger that includes the given ID in each log line.
-func (s *Service) mustCreateIDLogger(id string) *logging.Logger {
-	backend := logging.NewLogBackend(os.Stderr, "", log.LstdFlags)
-	formattedBackend := logging.NewBackendFormatter(backend, logging.MustStringFormatter(fmt.Sprintf("[%s] %%{message}", id)))
-	log := logging.MustGetLogger(s.log.Module)
-	log.SetBackend(logging.AddModuleLevel(formattedBackend))
-	return log
-}
@@ -0,0 +1,284 @@
+//
+// DISCLAIMER
+//
+// Copyright 2017 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+// Author Ewout Prangsma
+//
+
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+)
+
+const (
+	masterLeaseKey       = "/arangodb-helper/starter/master" // Agency key holding the lease of the master starter
+	masterLeaseInterval  = time.Second * 5                   // Interval between renewals (or checks) of the master lease
+	agencyRequestTimeout = time.Second * 5                   // Timeout of a request to a single agent
+
+	// MinMasterFailoverDelay is the minimum value of --starter.master-failover-delay, the master lease
+	// must survive a few failed renewals.
+	MinMasterFailoverDelay = masterLeaseInterval * 3
+)
+
+// masterLease is the value of the master lease in the agency.
+// The master renews it regularly, it expires once the master has not renewed it for --starter.master-failover-delay.
+type masterLease struct {
+	ID      string `json:"id"`      // ID of the master starter
+	Address string `json:"address"` // Address of the master starter
+	Port    int    `json:"port"`    // Port of the master starter
+}
+
+// isMaster returns true if this starter is the master of the deployment.
+func (s *Service) isMaster() bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return len(s.myPeers.Peers) > 0 && s.myPeers.Peers[0].ID == s.ID
+}
+
+// runMasterElection keeps the master lease in the agency up to date, until the starter is stopped.
+// The master renews its lease. Once the lease of the master has expired and the master is unreachable,
+// the other starters try to take over the lease. The starter that succeeds becomes the new master,
+// all others follow the starter that holds the lease.
+func (s *Service) runMasterElection() {
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-time.After(masterLeaseInterval):
+		}
+		if s.stop {
+			return
+		}
+		s.updateMasterLease()
+	}
+}
+
+// updateMasterLease renews, takes over or follows the master lease (see runMasterElection).
+func (s *Service) updateMasterLease() {
+	lease, err := s.readMasterLease()
+	if err != nil {
+		// Without agency, no master can be elected
+		s.peersLog.Debugf("Cannot read master lease: %v", err)
+		return
+	}
+	s.mutex.Lock()
+	master := s.myPeers.Peers[0]
+	myPeer, _ := s.myPeers.PeerByID(s.ID)
+	s.mutex.Unlock()
+
+	switch {
+	case lease.ID != "" && lease.ID != master.ID:
+		// Another starter has become the master
+		s.adoptMaster(lease.ID)
+		return
+	case master.ID == s.ID:
+		if err := s.writeMasterLease(lease.ID); err != nil {
+			s.peersLog.Warningf("Failed to renew master lease: %v", err)
+		}
+		return
+	case lease.ID == "":
+		if isPeerReachable(master) {
+			// The master is alive, but has not taken the lease (yet)
+			break
+		}
+		if myPeer.IsPassive {
+			// Passive peers never become master
+			break
+		}
+		s.peersLog.Warningf("Master '%s' has not renewed its lease and is unreachable, trying to take over", master.ID)
+		if err := s.writeMasterLease(""); err != nil {
+			s.peersLog.Infof("Failed to take over as master: %v", err)
+			return
+		}
+		s.adoptMaster(s.ID)
+		return
+	}
+	if lease.ID == master.ID {
+		master = s.followMasterAddress(lease)
+	}
+	s.syncPeersFromMaster(master)
+}
+
+// adoptMaster makes the peer with given ID the master, by moving it to the front of the list of peers.
+func (s *Service) adoptMaster(id string) {
+	s.mutex.Lock()
+	index := -1
+	for i, p := range s.myPeers.Peers {
+		if p.ID == id {
+			index = i
+			break
+		}
+	}
+	if index <= 0 {
+		s.mutex.Unlock()
+		if index < 0 {
+			s.peersLog.Warningf("Starter '%s' holds the master lease, but is not a known peer", id)
+		}
+		return
+	}
+	master := s.myPeers.Peers[index]
+	peerList := append([]Peer{master}, s.myPeers.Peers[:index]...)
+	s.myPeers.Peers = append(peerList, s.myPeers.Peers[index+1:]...)
+	s.mutex.Unlock()
+
+	if id == s.ID {
+		s.peersLog.Infof("This starter is the master now")
+	} else {
+		s.peersLog.Infof("Starter '%s' at %s is the master now", id, master.CreateStarterURL("/"))
+	}
+	s.events.publish(ProcessEvent{Type: ProcessEventMasterChanged, PeerID: master.ID, Address: master.Address, Port: master.Port})
+	if err := s.saveSetup(); err != nil {
+		s.peersLog.Errorf("Failed to save setup: %#v", err)
+	}
+}
+
+// syncPeersFromMaster replaces the list of peers with the one of the given master.
+func (s *Service) syncPeersFromMaster(master Peer) {
+	resp, err := httpClient.Get(master.CreateStarterURL("/hello"))
+	if err != nil {
+		s.peersLog.Debugf("Failed to fetch peers from master: %v", err)
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return
+	}
+	var masterPeers peers
+	if err := json.Unmarshal(body, &masterPeers); err != nil {
+		s.peersLog.Warningf("Cannot parse peers from master: %v", err)
+		return
+	}
+	if len(masterPeers.Peers) == 0 || masterPeers.Peers[0].ID != master.ID {
+		// The master does not consider itself master (anymore)
+		return
+	}
+	if _, found := masterPeers.PeerByID(s.ID); !found {
+		return
+	}
+	s.updatePeers(masterPeers.Peers)
+}
+
+// readMasterLease reads the master lease from the agency.
+// Returns an empty lease when no starter holds it.
+func (s *Service) readMasterLease() (masterLease, error) {
+	query, err := json.Marshal([][]string{{masterLeaseKey}})
+	if err != nil {
+		return masterLease{}, maskAny(err)
+	}
+	content, err := s.agencyRequest("/_api/agency/read", query)
+	if err != nil {
+		return masterLease{}, maskAny(err)
+	}
+	var result []struct {
+		Helper struct {
+			Starter struct {
+				Master masterLease `json:"master"`
+			} `json:"starter"`
+		} `json:"arangodb-helper"`
+	}
+	if err := json.Unmarshal(content, &result); err != nil {
+		return masterLease{}, maskAny(err)
+	}
+	if len(result) == 0 {
+		return masterLease{}, nil
+	}
+	return result[0].Helper.Starter.Master, nil
+}
+
+// writeMasterLease writes a master lease for this starter into the agency,
+// on condition that the lease is currently held by the given starter (or nobody if empty).
+func (s *Service) writeMasterLease(currentID string) error {
+	s.mutex.Lock()
+	myPeer, found := s.myPeers.PeerByID(s.ID)
+	s.mutex.Unlock()
+	if !found {
+		return maskAny(fmt.Errorf("Cannot find peer %s", s.ID))
+	}
+	operation := map[string]interface{}{
+		masterLeaseKey: map[string]interface{}{
+			"op":  "set",
+			"new": masterLease{ID: s.ID, Address: myPeer.Address, Port: myPeer.Port},
+			"ttl": int(s.MasterFailoverDelay.Seconds()),
+		},
+	}
+	precondition := map[string]interface{}{
+		masterLeaseKey: map[string]interface{}{"oldEmpty": true},
+	}
+	if currentID != "" {
+		precondition = map[string]interface{}{
+			masterLeaseKey + "/id": map[string]interface{}{"old": currentID},
+		}
+	}
+	transaction, err := json.Marshal([][]interface{}{{operation, precondition}})
+	if err != nil {
+		return maskAny(err)
+	}
+	if _, err := s.agencyRequest("/_api/agency/write", transaction); err != nil {
+		return maskAny(err)
+	}
+	return nil
+}
+
+// agencyRequest performs a POST request to the agency, trying the agent of this starter first
+// and then the agents of the other peers, until one of them succeeds.
+func (s *Service) agencyRequest(path string, body []byte) ([]byte, error) {
+	s.mutex.Lock()
+	var agents []Peer
+	for _, p := range s.myPeers.Peers {
+		if p.HasAgent {
+			if p.ID == s.ID {
+				agents = append([]Peer{p}, agents...)
+			} else {
+				agents = append(agents, p)
+			}
+		}
+	}
+	s.mutex.Unlock()
+
+	lastErr := fmt.Errorf("No peer runs an agent")
+	for _, p := range agents {
+		ctx, cancel := context.WithTimeout(s.ctx, agencyRequestTimeout)
+		content, err := s.peerServerRequest(ctx, p, ServerTypeAgent, "POST", path, body)
+		cancel()
+		if err == nil {
+			return content, nil
+		}
+		lastErr = err
+	}
+	return nil, maskAny(lastErr)
+}
+
+// isPeerReachable returns true if the starter of the given peer responds.
+func isPeerReachable(p Peer) bool {
+	resp, err := httpClient.Get(p.CreateStarterURL("/version"))
+	if err != nil {
+		return false
+	}
+	resp.Body.Close()
+	return true
+}
@@ -0,0 +1,99 @@
+//
+// DISCLAIMER
+//
+// Copyright 2017 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+// Author Ewout Prangsma
+//
+
+package service
+
+import "strconv"
+
+const (
+	// overrideTotalMemoryEnvVar is the environment variable that overrides the total memory detected by arangod.
+	overrideTotalMemoryEnvVar = "ARANGODB_OVERRIDE_DETECTED_TOTAL_MEMORY"
+)
+
+// memoryWeights holds the relative amount of memory given to each type of server,
+// when multiple servers run on the same machine.
+var memoryWeights = map[ServerType]uint64{
+	ServerTypeAgent:       1,
+	ServerTypeCoordinator: 3,
+	ServerTypeDBServer:    6,
+	ServerTypeSingle:      1,
+}
+
+// serverMemory returns the amount of memory the server of given type can use,
+// or 0 when the server has to detect the total memory itself.
+// MemoryTotal is divided amongst all local starters and then amongst the servers
+// of this starter, according to their weight.
+func (s *Service) serverMemory(serverType ServerType) uint64 {
+	if s.MemoryTotal == 0 {
+		return 0
+	}
+	total := s.MemoryTotal
+	if (s.StartLocalSlaves || s.isLocalSlave) && s.localPeerCount() > 1 {
+		total /= uint64(s.localPeerCount())
+	}
+	var serverTypes []ServerType
+	if s.isSingleMode() {
+		serverTypes = append(serverTypes, ServerTypeSingle)
+	} else {
+		if myPeer, ok := s.myPeers.PeerByID(s.ID); !ok || myPeer.HasAgent {
+			serverTypes = append(serverTypes, ServerTypeAgent)
+		}
+		if s.StartDBserver {
+			serverTypes = append(serverTypes, ServerTypeDBServer)
+		}
+		if s.StartCoordinator {
+			serverTypes = append(serverTypes, ServerTypeCoordinator)
+		}
+	}
+	var sum uint64
+	for _, t := range serverTypes {
+		sum += memoryWeights[t]
+	}
+	if sum == 0 {
+		return total
+	}
+	return total / sum * memoryWeights[serverType]
+}
+
+// serverEnv returns the environment variables to set for the server of given type.
+func (s *Service) serverEnv(serverType ServerType) map[string]string {
+	var env map[string]string
+	if memory := s.serverMemory(serverType); memory != 0 {
+		s.serverLogger(serverType).Infof("%s can use %d MB of memory", serverType, memory>>20)
+		env = map[string]string{
+			overrideTotalMemoryEnvVar: strconv.FormatUint(memory, 10),
+		}
+	}
+	if s.LicenseKey != "" {
+		if env == nil {
+			env = make(map[string]string)
+		}
+		env[licenseKeyEnvVar] = s.LicenseKey
+	}
+	if password := s.bootstrapRootPassword(serverType); password != "" {
+		if env == nil {
+			env = make(map[string]string)
+		}
+		env[rootPasswordEnvVar] = password
+	}
+	return env
+}
@@ -0,0 +1,81 @@
+//
+// DISCLAIMER
+//
+// Copyright 2017 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+// Author Ewout Prangsma
+//
+
+//go:build linux
+// +build linux
+
+package service
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+)
+
+var (
+	// cgroupMemoryLimitPaths holds the files that contain the memory limit of the cgroup of
+	// this process (cgroup v2 and v1).
+	cgroupMemoryLimitPaths = []string{
+		"/sys/fs/cgroup/memory.max",
+		"/sys/fs/cgroup/memory/memory.limit_in_bytes",
+	}
+)
+
+// DetectTotalMemory returns the total amount of memory of this machine,
+// limited by the memory limit of the cgroup of this process (if any).
+func DetectTotalMemory() (uint64, error) {
+	f, err := os.Open("/proc/meminfo")
+	if err != nil {
+		return 0, maskAny(err)
+	}
+	defer f.Close()
+	var total uint64
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 2 && fields[0] == "MemTotal:" {
+			kb, err := strconv.ParseUint(fields[1], 10, 64)
+			if err != nil {
+				return 0, maskAny(fmt.Errorf("Invalid MemTotal in /proc/meminfo: %v", err))
+			}
+			total = kb * 1024
+			break
+		}
+	}
+	if total == 0 {
+		return 0, maskAny(fmt.Errorf("Cannot find MemTotal in /proc/meminfo"))
+	}
+	for _, path := range cgroupMemoryLimitPaths {
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		if limit, err := strconv.ParseUint(strings.TrimSpace(string(content)), 10, 64); err == nil && limit < total {
+			total = limit
+		}
+		break
+	}
+	return total, nil
+}
@@ -0,0 +1,33 @@
+//
+// DISCLAIMER
+//
+// Copyright 2017 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+// Author Ewout Prangsma
+//
+
+//go:build !linux
+// +build !linux
+
+package service
+
+import "fmt"
+
+// DetectTotalMemory returns an error, since detecting the total memory is only supported on Linux.
+func DetectTotalMemory() (uint64, error) {
+	return 0, maskAny(fmt.Errorf("Detecting the total memory is not supported on this platform, use --memory.total"))
+}
@@ -0,0 +1,188 @@
+//
+// DISCLAIMER
+//
+// Copyright 2017 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+// Author Ewout Prangsma
+//
+
+package service
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// Names of the metrics of the starter, as served by `/metrics` (in the Prometheus text format).
+const (
+	MetricStarterInfo          = "arangodb_starter_info"                    // Always 1, labels id, mode & version describe the starter
+	MetricStarterIsMaster      = "arangodb_starter_is_master"               // 1 if the starter is the master, 0 otherwise
+	MetricStarterPeers         = "arangodb_starter_peers"                   // Number of peers of the deployment
+	MetricStarterHealth        = "arangodb_starter_health"                  // Health of the starter: 0 ok, 1 degraded, 2 failed
+	MetricStartupPhaseSeconds  = "arangodb_starter_startup_phase_seconds"   // Time between the start of the starter and the end of a startup phase
+	MetricServerUp             = "arangodb_starter_server_up"               // 1 if the server is responding to requests, 0 otherwise
+	MetricServerFailed         = "arangodb_starter_server_failed"           // 1 if the server has failed and is no longer restarted, 0 otherwise
+	MetricServerCrashLoop      = "arangodb_starter_server_crash_loop"       // 1 if the server is in a crash loop, 0 otherwise
+	MetricServerRestarts       = "arangodb_starter_server_restarts_total"   // Number of times the server has been restarted
+	MetricServerCPUPercent     = "arangodb_starter_server_cpu_percent"      // CPU usage of the server (100 = 1 core)
+	MetricServerRSSBytes       = "arangodb_starter_server_rss_bytes"        // Resident set size of the server
+	MetricServerOpenFiles      = "arangodb_starter_server_open_files"       // Number of open file descriptors of the server
+	MetricServerDiskUsageBytes = "arangodb_starter_server_disk_usage_bytes" // Size of the directory of the server
+	MetricDiskFreeBytes        = "arangodb_starter_disk_free_bytes"         // Free space of a directory holding server data
+	MetricDiskLow              = "arangodb_starter_disk_low"                // 1 if the free space of a directory is below --disk.min-free, 0 otherwise
+)
+
+const (
+	metricsContentType   = "text/plain; version=0.0.4" // Content type of the Prometheus text format
+	metricHealthOK       = 0
+	metricHealthDegraded = 1
+	metricHealthFailed   = 2
+)
+
+// metricsWriter collects metrics and writes them in the Prometheus text format
+// (with all samples of a metric grouped together) or pushes them to statsd.
+type metricsWriter struct {
+	names    []string
+	families map[string]*metricFamily
+}
+
+// metricFamily holds all samples of a single metric.
+type metricFamily struct {
+	metricType string
+	help       string
+	samples    []metricSample
+}
+
+// metricSample holds a single value of a metric.
+type metricSample struct {
+	labels []string // Name, value pairs
+	value  float64
+}
+
+// add adds a sample of the metric with given name, type & help text.
+// Labels are given as name, value pairs.
+func (mw *metricsWriter) add(name, metricType, help string, value float64, labels ...string) {
+	if mw.families == nil {
+		mw.families = make(map[string]*metricFamily)
+	}
+	f, found := mw.families[name]
+	if !found {
+		f = &metricFamily{metricType: metricType, help: help}
+		mw.families[name] = f
+		mw.names = append(mw.names, name)
+	}
+	f.samples = append(f.samples, metricSample{labels: labels, value: value})
+}
+
+// bytes returns all collected metrics in the Prometheus text format.
+func (mw *metricsWriter) bytes() []byte {
+	var buf bytes.Buffer
+	for _, name := range mw.names {
+		f := mw.families[name]
+		fmt.Fprintf(&buf, "# HELP %s %s\n# TYPE %s %s\n", name, f.help, name, f.metricType)
+		for _, sample := range f.samples {
+			buf.WriteString(name)
+			if len(sample.labels) > 0 {
+				var pairs []string
+				for i := 0; i+1 < len(sample.labels); i += 2 {
+					value := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(sample.labels[i+1])
+					pairs = append(pairs, fmt.Sprintf(`%s="%s"`, sample.labels[i], value))
+				}
+				buf.WriteString("{" + strings.Join(pairs, ",") + "}")
+			}
+			fmt.Fprintf(&buf, " %v\n", sample.value)
+		}
+	}
+	return buf.Bytes()
+}
+
+// boolMetric returns 1 for true and 0 for false.
+func boolMetric(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// metricsHandler returns the metrics of this starter and the servers started by it.
+func (s *Service) metricsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		writeError(w, http.StatusMethodNotAllowed, "GET required")
+		return
+	}
+	w.Header().Set("Content-Type", metricsContentType)
+	w.Write(s.metrics().bytes())
+}
+
+// metrics collects the metrics of this starter and the servers started by it.
+func (s *Service) metrics() *metricsWriter {
+	mw := &metricsWriter{}
+	mw.add(MetricStarterInfo, "gauge", "Information about the starter.", 1, "id", s.ID, "mode", s.Mode, "version", s.ProjectVersion)
+	mw.add(MetricStarterIsMaster, "gauge", "1 if the starter is the master, 0 otherwise.", boolMetric(s.isMaster()))
+	s.mutex.Lock()
+	peerCount := len(s.myPeers.Peers)
+	s.mutex.Unlock()
+	mw.add(MetricStarterPeers, "gauge", "Number of peers of the deployment.", float64(peerCount))
+
+	health := s.health()
+	healthValue := metricHealthOK
+	switch health.Status {
+	case HealthDegraded:
+		healthValue = metricHealthDegraded
+	case HealthFailed:
+		healthValue = metricHealthFailed
+	}
+	mw.add(MetricStarterHealth, "gauge", "Health of the starter: 0 ok, 1 degraded, 2 failed.", float64(healthValue))
+
+	elapsed := s.startupPhases.elapsed()
+	var phases []string
+	for name := range elapsed {
+		phases = append(phases, name)
+	}
+	sort.Slice(phases, func(i, j int) bool { return elapsed[phases[i]] < elapsed[phases[j]] })
+	for _, name := range phases {
+		mw.add(MetricStartupPhaseSeconds, "gauge", "Time between the start of the starter and the end of a startup phase.", elapsed[name].Seconds(), "phase", name)
+	}
+
+	if myPeer, found := s.myPeers.PeerByID(s.ID); found {
+		for _, sp := range s.serverProcesses(myPeer) {
+			serverType := ServerType(sp.Type)
+			state := s.serverStates.get(serverType)
+			mw.add(MetricServerUp, "gauge", "1 if the server is responding to requests, 0 otherwise.", boolMetric(state.Up), "type", sp.Type)
+			mw.add(MetricServerFailed, "gauge", "1 if the server has failed and is no longer restarted, 0 otherwise.", boolMetric(state.Failed), "type", sp.Type)
+			mw.add(MetricServerCrashLoop, "gauge", "1 if the server is in a crash loop, 0 otherwise.", boolMetric(state.CrashLoop), "type", sp.Type)
+			mw.add(MetricServerRestarts, "counter", "Number of times the server has been restarted.", float64(state.Restarts), "type", sp.Type)
+			if res, found := s.resources.get(serverType); found {
+				mw.add(MetricServerCPUPercent, "gauge", "CPU usage of the server (100 = 1 core).", res.CPUPercent, "type", sp.Type)
+				mw.add(MetricServerRSSBytes, "gauge", "Resident set size of the server.", float64(res.RSS), "type", sp.Type)
+				if res.OpenFiles > 0 {
+					mw.add(MetricServerOpenFiles, "gauge", "Number of open file descriptors of the server.", float64(res.OpenFiles), "type", sp.Type)
+				}
+				mw.add(MetricServerDiskUsageBytes, "gauge", "Size of the directory of the server.", float64(res.DiskUsage), "type", sp.Type)
+			}
+		}
+	}
+
+	for _, d := range health.Disks {
+		mw.add(MetricDiskFreeBytes, "gauge", "Free space of a directory holding server data.", float64(d.Free), "path", d.Path)
+		mw.add(MetricDiskLow, "gauge", "1 if the free space of a directory is below the minimum, 0 otherwise.", boolMetric(d.Status == DiskStatusLow), "path", d.Path)
+	}
+	return mw
+}
@@ -0,0 +1,390 @@
+//
+// DISCLAIMER
+//
+// Copyright 2017 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+// Author Ewout Prangsma
+//
+
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	movedFileName     = "moved.json"    // Name of the file left in a data directory that has been moved
+	moveStopTimeout   = time.Minute * 5 // Maximum time to wait for the servers to stop before moving their data
+	moveCheckInterval = time.Second
+)
+
+// Phases of moving the data directory
+const (
+	MovePhaseStopServers  = "stop-servers"
+	MovePhaseMove         = "move"
+	MovePhaseStartServers = "start-servers"
+)
+
+var (
+	errMoveRunning = errors.New("Data directory is already being moved")
+)
+
+// MoveDataRequest is the JSON body of a POST `/data/move` request.
+type MoveDataRequest struct {
+	NewDir string `json:"new-dir"`        // New data directory of the starter
+	Copy   bool   `json:"copy,omitempty"` // If set, the data is copied and the old data directory is left untouched
+}
+
+// MoveDataStatus is the JSON response of a `/data/move` request.
+type MoveDataStatus struct {
+	Running bool   `json:"running,omitempty"` // If set, the data directory is being moved
+	Ready   bool   `json:"ready,omitempty"`   // If set, the data directory has been moved successfully
+	Failed  bool   `json:"failed,omitempty"`  // If set, moving the data directory has failed
+	Reason  string `json:"reason,omitempty"`  // Reason of the failure (if any)
+	OldDir  string `json:"old-dir,omitempty"` // Data directory before the move
+	NewDir  string `json:"new-dir,omitempty"` // Data directory after the move
+	Copy    bool   `json:"copy,omitempty"`    // If set, the data is copied instead of moved
+	Phase   string `json:"phase,omitempty"`   // stop-servers | move | start-servers
+}
+
+// movedInfo is the content of the file left in a data directory that has been moved.
+type movedInfo struct {
+	NewDir string    `json:"new-dir"`
+	Moved  time.Time `json:"moved"`
+}
+
+// dataMoveManager holds the state of moving the data directory of a starter.
+type dataMoveManager struct {
+	mutex  sync.Mutex
+	status MoveDataStatus
+	paused bool // If set, servers that terminate are not restarted
+}
+
+// getStatus returns a copy of the current status.
+func (m *dataMoveManager) getStatus() MoveDataStatus {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.status
+}
+
+// update calls the given function with exclusive access to the status.
+func (m *dataMoveManager) update(f func(status *MoveDataStatus)) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	f(&m.status)
+}
+
+// setPaused changes whether servers that terminate are restarted.
+func (m *dataMoveManager) setPaused(paused bool) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.paused = paused
+}
+
+// isPaused returns true while servers that terminate must not be restarted.
+func (m *dataMoveManager) isPaused() bool {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.paused
+}
+
+// moveDataHandler starts moving the data directory of this starter (POST) or returns the status of the move (GET).
+func (s *Service) moveDataHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" && r.Method != "GET" {
+		writeError(w, http.StatusMethodNotAllowed, "GET or POST required")
+		return
+	}
+	if r.Method == "POST" {
+		if s.isLocalSlave {
+			writeError(w, http.StatusPreconditionFailed, "Data of local slaves is moved together with the data of the master")
+			return
+		}
+		var req MoveDataRequest
+		if !readJSONBody(w, r, &req, false) {
+			return
+		}
+		if err := s.startMoveData(req); err != nil {
+			writeError(w, http.StatusPreconditionFailed, err.Error())
+			return
+		}
+	}
+	b, err := json.Marshal(s.dataMove.getStatus())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+	} else {
+		w.Write(b)
+	}
+}
+
+// startMoveData checks the given request and starts moving the data directory.
+func (s *Service) startMoveData(req MoveDataRequest) error {
+	if req.NewDir == "" || !filepath.IsAbs(req.NewDir) {
+		return maskAny(fmt.Errorf("New directory must be an absolute path"))
+	}
+	oldDir := filepath.Clean(s.DataDir)
+	newDir := filepath.Clean(req.NewDir)
+	if newDir == oldDir || strings.HasPrefix(newDir, oldDir+string(filepath.Separator)) {
+		return maskAny(fmt.Errorf("New directory cannot be (inside) the current data directory %s", oldDir))
+	}
+	if entries, err := ioutil.ReadDir(newDir); err == nil && len(entries) > 0 {
+		return maskAny(fmt.Errorf("New directory %s is not empty", newDir))
+	}
+	if s.dataMove.getStatus().Running {
+		return maskAny(errMoveRunning)
+	}
+	s.dataMove.update(func(status *MoveDataStatus) {
+		*status = MoveDataStatus{
+			Running: true,
+			OldDir:  oldDir,
+			NewDir:  newDir,
+			Copy:    req.Copy,
+		}
+	})
+	s.log.Infof("Moving data directory %s to %s", oldDir, newDir)
+	go s.runMoveData(oldDir, newDir, req.Copy)
+	return nil
+}
+
+// runMoveData stops the servers of this starter (and its local slaves), moves (or copies) the data
+// directory, updates the data directories recorded in setup.json and starts the servers again.
+func (s *Service) runMoveData(oldDir, newDir string, copyOnly bool) {
+	services := append([]*Service{s}, s.localSlaves...)
+	setPhase := func(phase string) {
+		s.dataMove.update(func(status *MoveDataStatus) {
+			status.Phase = phase
+		})
+	}
+	resume := func() {
+		setPhase(MovePhaseStartServers)
+		for _, svc := range services {
+			svc.dataMove.setPaused(false)
+		}
+	}
+	fail := func(err error) {
+		s.log.Errorf("Failed to move data directory: %v", err)
+		resume()
+		s.dataMove.update(func(status *MoveDataStatus) {
+			status.Running = false
+			status.Failed = true
+			status.Reason = err.Error()
+		})
+	}
+
+	// Stop all servers
+	setPhase(MovePhaseStopServers)
+	for _, svc := range services {
+		svc.dataMove.setPaused(true)
+		for _, serverType := range []ServerType{ServerTypeCoordinator, ServerTypeSingle, ServerTypeDBServer, ServerTypeAgent} {
+			if p := svc.serverProcess(serverType); p != nil {
+				svc.log.Infof("Stopping %s to move its data", serverType)
+				if err := p.Terminate(); err != nil {
+					svc.log.Warningf("Failed to terminate %s: %v", serverType, err)
+				}
+			}
+		}
+	}
+	deadline := time.Now().Add(moveStopTimeout)
+	for !s.allServersParked(services) {
+		if s.stop {
+			fail(fmt.Errorf("Stopped while waiting for servers to stop"))
+			return
+		}
+		if time.Now().After(deadline) {
+			fail(fmt.Errorf("Servers have not stopped after %s", moveStopTimeout))
+			return
+		}
+		time.Sleep(moveCheckInterval)
+	}
+
+	// Move the data
+	setPhase(MovePhaseMove)
+	copied, err := moveDataDir(oldDir, newDir, copyOnly)
+	if err != nil {
+		fail(err)
+		return
+	}
+	ids := make(map[string]bool)
+	for _, svc := range services {
+		ids[svc.ID] = true
+	}
+	for _, svc := range services {
+		svc.mutex.Lock()
+		svc.DataDir = relocatePath(svc.DataDir, oldDir, newDir)
+		for i, p := range svc.myPeers.Peers {
+			if ids[p.ID] {
+				svc.myPeers.Peers[i].DataDir = relocatePath(p.DataDir, oldDir, newDir)
+			}
+		}
+		svc.mutex.Unlock()
+		if err := svc.saveSetup(); err != nil {
+			svc.log.Errorf("Failed to save setup after moving data: %v", err)
+		}
+	}
+	if !copyOnly {
+		if copied {
+			if err := os.RemoveAll(oldDir); err != nil {
+				s.log.Warningf("Data has been copied to %s, but %s cannot be removed: %v", newDir, oldDir, err)
+			}
+		}
+		writeMovedInfo(oldDir, newDir)
+	}
+
+	// Start all servers
+	resume()
+	s.log.Info(newLogEvent("data-moved", LogFields{"old-dir": oldDir, "new-dir": newDir},
+		"Data directory has been moved to %s, use `--starter.data-dir=%s` from now on", newDir, newDir))
+	s.dataMove.update(func(status *MoveDataStatus) {
+		status.Running = false
+		status.Ready = true
+	})
+}
+
+// allServersParked returns true when all servers of the given services have stopped
+// and are waiting until the data has been moved.
+func (s *Service) allServersParked(services []*Service) bool {
+	for _, svc := range services {
+		for _, serverType := range []ServerType{ServerTypeCoordinator, ServerTypeSingle, ServerTypeDBServer, ServerTypeAgent} {
+			if svc.serverProcess(serverType) != nil && !svc.serverStates.get(serverType).Parked {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// waitWhileMovingData blocks while the data directory is being moved.
+// It is called by runArangod before restarting a server that has terminated.
+func (s *Service) waitWhileMovingData(serverType ServerType) {
+	if !s.dataMove.isPaused() {
+		return
+	}
+	s.serverLogger(serverType).Infof("Waiting for the data directory to be moved before restarting %s", serverType)
+	s.serverStates.setParked(serverType, true)
+	for s.dataMove.isPaused() && !s.stop {
+		time.Sleep(moveCheckInterval)
+	}
+	s.serverStates.setParked(serverType, false)
+}
+
+// relocatePath returns the given path with the given old directory prefix replaced by the given new directory.
+func relocatePath(path, oldDir, newDir string) string {
+	if path == oldDir {
+		return newDir
+	}
+	if strings.HasPrefix(path, oldDir+string(filepath.Separator)) {
+		return filepath.Join(newDir, strings.TrimPrefix(path, oldDir))
+	}
+	return path
+}
+
+// moveDataDir moves (or copies) the given old data directory to the given new directory.
+// When the directory cannot be renamed (e.g. when it is on another file system), it is copied.
+// Returns true if the data has been copied, leaving the old data directory in place.
+func moveDataDir(oldDir, newDir string, copyOnly bool) (bool, error) {
+	if err := os.MkdirAll(filepath.Dir(newDir), 0755); err != nil {
+		return false, maskAny(err)
+	}
+	if !copyOnly {
+		// An empty new directory (e.g. a mount point) is replaced
+		os.Remove(newDir)
+		if err := os.Rename(oldDir, newDir); err == nil {
+			return false, nil
+		}
+	}
+	if err := copyTree(oldDir, newDir); err != nil {
+		return true, maskAny(errors.Wrapf(err, "Failed to copy %s to %s", oldDir, newDir))
+	}
+	return true, nil
+}
+
+// writeMovedInfo leaves a file in the given old data directory that points to the new data directory,
+// so a starter that is started with the old data directory does not bootstrap a new deployment.
+func writeMovedInfo(oldDir, newDir string) {
+	encoded, err := json.Marshal(movedInfo{NewDir: newDir, Moved: time.Now()})
+	if err != nil {
+		return
+	}
+	os.MkdirAll(oldDir, 0755)
+	ioutil.WriteFile(filepath.Join(oldDir, movedFileName), encoded, 0644)
+}
+
+// readMovedInfo returns the new data directory recorded in the given data directory,
+// or false if that directory has not been moved.
+func readMovedInfo(dataDir string) (string, bool) {
+	content, err := ioutil.ReadFile(filepath.Join(dataDir, movedFileName))
+	if err != nil {
+		return "", false
+	}
+	var info movedInfo
+	if err := json.Unmarshal(content, &info); err != nil || info.NewDir == "" {
+		return "", false
+	}
+	return info.NewDir, true
+}
+
+// copyTree copies the directory tree at given source path to the given destination path,
+// keeping file modes and symbolic links.
+func copyTree(sourcePath, destPath string) error {
+	return filepath.Walk(sourcePath, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return maskAny(err)
+		}
+		rel, err := filepath.Rel(sourcePath, path)
+		if err != nil {
+			return maskAny(err)
+		}
+		target := filepath.Join(destPath, rel)
+		switch {
+		case info.IsDir():
+			return maskAny(os.MkdirAll(target, info.Mode().Perm()))
+		case info.Mode()&os.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return maskAny(err)
+			}
+			return maskAny(os.Symlink(link, target))
+		case !info.Mode().IsRegular():
+			// Skip sockets, pipes etc.
+			return nil
+		}
+		source, err := os.Open(path)
+		if err != nil {
+			return maskAny(err)
+		}
+		defer source.Close()
+		dest, err := os.OpenFile(target, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, info.Mode().Perm())
+		if err != nil {
+			return maskAny(err)
+		}
+		defer dest.Close()
+		if _, err := io.Copy(dest, source); err != nil {
+			return maskAny(err)
+		}
+		return maskAny(dest.Close())
+	})
+}
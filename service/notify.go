@@ -0,0 +1,153 @@
+//
+// DISCLAIMER
+//
+// Copyright 2017 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+// Author Ewout Prangsma
+//
+
+package service
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+const (
+	notifyWebhookTimeout  = time.Second * 10 // Time a single call of the notification webhook may take
+	notifyWebhookAttempts = 3                // Number of times a notification is posted before it is dropped
+	notifyWebhookBackoff  = time.Second * 2  // Delay before retrying a failed notification (doubled for every attempt)
+
+	// NotifySignatureHeader holds the HMAC-SHA256 (hex encoded) of the body of a notification,
+	// computed with the webhook secret, prefixed by "sha256=".
+	NotifySignatureHeader = "X-Arangodb-Signature"
+	// NotifyEventHeader holds the event type of a notification.
+	NotifyEventHeader = "X-Arangodb-Event"
+)
+
+// notifyEvents lists the types of events posted to the notification webhook.
+var notifyEvents = map[string]bool{
+	ProcessEventClusterReady:  true,
+	ProcessEventServerCrash:   true,
+	ProcessEventCrashLoop:     true,
+	ProcessEventUpgradeStart:  true,
+	ProcessEventUpgradeFinish: true,
+	ProcessEventUpgradePaused: true,
+	ProcessEventPeerAdded:     true,
+	ProcessEventPeerRemoved:   true,
+	ProcessEventPeerChanged:   true,
+	ProcessEventClockSkew:     true,
+}
+
+// Notification is the JSON body posted to the notification webhook.
+type Notification struct {
+	ProcessEvent
+	StarterID      string `json:"starter-id"`      // ID of the starter sending the notification
+	StarterAddress string `json:"starter-address"` // Address of the starter sending the notification
+	Mode           string `json:"mode"`            // Mode of the starter (cluster or single)
+}
+
+// SignNotification returns the value of the NotifySignatureHeader for the given body & secret.
+// Receivers verify a notification by comparing this value with the header (in constant time).
+func SignNotification(body []byte, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// runNotifyWebhook posts all events of the given subscription that must be notified to the webhook,
+// until the starter is stopped.
+func (s *Service) runNotifyWebhook(ch chan ProcessEvent) {
+	defer s.events.unsubscribe(ch)
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+		case e, ok := <-ch:
+			if !ok {
+				// Dropped because we could not keep up
+				s.log.Warning("Notification webhook cannot keep up, events have been dropped")
+				ch = s.events.subscribe()
+				continue
+			}
+			if notifyEvents[e.Type] {
+				s.postNotification(Notification{
+					ProcessEvent:   e,
+					StarterID:      s.ID,
+					StarterAddress: s.OwnAddress,
+					Mode:           s.Mode,
+				})
+			}
+		}
+	}
+}
+
+// postNotification posts the given notification to the webhook, retrying a few times on failures.
+func (s *Service) postNotification(n Notification) {
+	body, err := json.Marshal(n)
+	if err != nil {
+		s.log.Warningf("Cannot encode %s notification: %v", n.Type, err)
+		return
+	}
+	backoff := notifyWebhookBackoff
+	for attempt := 1; ; attempt++ {
+		err := s.callNotifyWebhook(n.Type, body)
+		if err == nil {
+			return
+		}
+		if attempt >= notifyWebhookAttempts {
+			s.log.Warningf("Failed to post %s notification, giving up: %v", n.Type, err)
+			return
+		}
+		s.log.Debugf("Failed to post %s notification, retrying in %s: %v", n.Type, backoff, err)
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// callNotifyWebhook posts the given (encoded) notification to the webhook once.
+func (s *Service) callNotifyWebhook(eventType string, body []byte) error {
+	req, err := http.NewRequest("POST", s.NotifyWebhookURL, bytes.NewReader(body))
+	if err != nil {
+		return maskAny(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(NotifyEventHeader, eventType)
+	if s.NotifyWebhookSecret != "" {
+		req.Header.Set(NotifySignatureHeader, SignNotification(body, s.NotifyWebhookSecret))
+	}
+	c := &http.Client{Timeout: notifyWebhookTimeout}
+	resp, err := c.Do(req)
+	if err != nil {
+		return maskAny(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return maskAny(fmt.Errorf("webhook returned status %d", resp.StatusCode))
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+//
+// DISCLAIMER
+//
+// Copyright 2017 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+// Author Ewout Prangsma
+//
+
+package service
+
+import (
+	"fmt"
+
+	docker "github.com/fsouza/go-dockerclient"
+)
+
+// CheckOfflineDockerImage returns an error (explaining how to pre-stage the image)
+// when the given docker image is not available locally, so it would have to be pulled.
+func CheckOfflineDockerImage(endpoint, image string) error {
+	client, err := docker.NewClient(endpoint)
+	if err != nil {
+		return maskAny(err)
+	}
+	if _, err := client.InspectImage(image); err == docker.ErrNoSuchImage {
+		return maskAny(OfflineImageError(image))
+	} else if err != nil {
+		return maskAny(fmt.Errorf("Cannot inspect docker image %s: %v", image, err))
+	}
+	return nil
+}
+
+// OfflineDownloadError returns the error (explaining how to pre-stage the binary) for an arangod
+// version that has not been downloaded, while downloads are disabled in offline mode.
+func OfflineDownloadError(options DownloadArangodOptions) error {
+	return fmt.Errorf("ArangoDB %s has not been downloaded into %s and cannot be downloaded in offline mode. "+
+		"Download it on a machine with internet access (start the starter there with --server.download --server.version=%s) "+
+		"and copy its download directory to %s on this machine first", options.Version, options.CacheDir, options.Version, options.CacheDir)
+}
+
+// OfflineImageError returns the error (explaining how to pre-stage the image) for a docker image
+// that is not available locally, while pulling images is disabled in offline mode.
+func OfflineImageError(image string) error {
+	return fmt.Errorf("Docker image %s is not available locally and cannot be pulled in offline mode. "+
+		"Use `docker save %s > image.tar` on a machine with internet access and `docker load < image.tar` on this machine first", image, image)
+}
@@ -0,0 +1,290 @@
+//
+// DISCLAIMER
+//
+// Copyright 2017 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+// Author Ewout Prangsma
+//
+
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Types of long-running operations started through the API
+const (
+	OperationTypeUpgrade           = "upgrade"            // Rolling upgrade (POST /upgrade)
+	OperationTypeShutdown          = "shutdown"           // Shutdown of the starter (POST /shutdown)
+	OperationTypeHotBackupCreate   = "hotbackup-create"   // Creation of a hot backup (POST /hotbackup)
+	OperationTypeHotBackupUpload   = "hotbackup-upload"   // Upload of a hot backup (POST /hotbackup/upload)
+	OperationTypeHotBackupDownload = "hotbackup-download" // Download of a hot backup (POST /hotbackup/download)
+)
+
+// States of an operation
+const (
+	OperationStateRunning   = "running"
+	OperationStateSucceeded = "succeeded"
+	OperationStateFailed    = "failed"
+)
+
+const (
+	maxOperations         = 100         // Number of operations kept, finished operations beyond this are forgotten
+	maxIdempotencyKeyLen  = 255         // Maximum length of an Idempotency-Key header
+	operationPollInterval = time.Second // Interval at which the state of a watched operation is checked
+	idempotencyKeyHeader  = "Idempotency-Key"
+	operationIDHeader     = "X-Arangodb-Operation-Id"
+	preferRespondAsync    = "respond-async"
+)
+
+// Operation holds the state of a long-running operation started through the API.
+type Operation struct {
+	ID             string      `json:"id"`                        // Unique ID of the operation
+	Type           string      `json:"type"`                      // One of the OperationType... constants
+	State          string      `json:"state"`                     // running | succeeded | failed
+	IdempotencyKey string      `json:"idempotency-key,omitempty"` // Idempotency-Key of the request that started the operation (if any)
+	Progress       string      `json:"progress,omitempty"`        // Human readable progress of a running operation
+	Result         interface{} `json:"result,omitempty"`          // Result of the operation (e.g. the upgrade status)
+	Error          string      `json:"error,omitempty"`           // Reason of the failure (if any)
+	Started        time.Time   `json:"started"`                   // Time the operation was started
+	Finished       *time.Time  `json:"finished,omitempty"`        // Time the operation has finished
+}
+
+// operationTracker holds the most recent operations of this starter, by ID and idempotency key.
+type operationTracker struct {
+	mutex      sync.Mutex
+	operations []*Operation // Oldest first
+}
+
+// begin registers a new operation of given type.
+// When an operation with the given (non-empty) idempotency key exists, that operation is returned
+// and existing is set. An error is returned when that operation has a different type.
+func (t *operationTracker) begin(opType, key string) (op Operation, existing bool, err error) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if key != "" {
+		for _, o := range t.operations {
+			if o.IdempotencyKey == key {
+				if o.Type != opType {
+					return Operation{}, false, maskAny(fmt.Errorf("Idempotency key '%s' has already been used for an operation of type %s", key, o.Type))
+				}
+				return *o, true, nil
+			}
+		}
+	}
+	var id string
+	for id == "" || t.find(id) != nil {
+		if id, err = createUniqueID(); err != nil {
+			return Operation{}, false, maskAny(err)
+		}
+	}
+	o := &Operation{
+		ID:             id,
+		Type:           opType,
+		State:          OperationStateRunning,
+		IdempotencyKey: key,
+		Started:        time.Now(),
+	}
+	t.operations = append(t.operations, o)
+	// Forget the oldest finished operations
+	for i := 0; len(t.operations) > maxOperations && i < len(t.operations); {
+		if t.operations[i].State != OperationStateRunning {
+			t.operations = append(t.operations[:i], t.operations[i+1:]...)
+		} else {
+			i++
+		}
+	}
+	return *o, false, nil
+}
+
+// find returns the operation with given ID, or nil if not found.
+// The mutex must be held.
+func (t *operationTracker) find(id string) *Operation {
+	for _, o := range t.operations {
+		if o.ID == id {
+			return o
+		}
+	}
+	return nil
+}
+
+// get returns a copy of the operation with given ID.
+func (t *operationTracker) get(id string) (Operation, bool) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if o := t.find(id); o != nil {
+		return *o, true
+	}
+	return Operation{}, false
+}
+
+// list returns a copy of all known operations, oldest first.
+func (t *operationTracker) list() []Operation {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	result := make([]Operation, 0, len(t.operations))
+	for _, o := range t.operations {
+		result = append(result, *o)
+	}
+	return result
+}
+
+// remove forgets the operation with given ID, used when the operation could not be started,
+// so a retry with the same idempotency key can start it again.
+func (t *operationTracker) remove(id string) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	for i, o := range t.operations {
+		if o.ID == id {
+			t.operations = append(t.operations[:i], t.operations[i+1:]...)
+			return
+		}
+	}
+}
+
+// update records the progress of the operation with given ID.
+func (t *operationTracker) update(id, progress string, result interface{}) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if o := t.find(id); o != nil {
+		o.Progress = progress
+		o.Result = result
+	}
+}
+
+// finish records the outcome of the operation with given ID.
+func (t *operationTracker) finish(id string, result interface{}, err error) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if o := t.find(id); o != nil {
+		now := time.Now()
+		o.Finished = &now
+		o.Result = result
+		o.Progress = ""
+		if err != nil {
+			o.State = OperationStateFailed
+			o.Error = err.Error()
+		} else {
+			o.State = OperationStateSucceeded
+		}
+	}
+}
+
+// prefersAsync returns true if the client asked for an immediate response (`Prefer: respond-async`).
+func prefersAsync(r *http.Request) bool {
+	for _, prefer := range r.Header["Prefer"] {
+		for _, p := range strings.Split(prefer, ",") {
+			if strings.EqualFold(strings.TrimSpace(p), preferRespondAsync) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// beginOperation registers an operation of given type for the given request, using its Idempotency-Key header (if any),
+// and adds the Location & X-Arangodb-Operation-Id headers to the response.
+// When an operation with the same idempotency key exists, that operation is returned and existing is set.
+// On failure an error response is written and ok is false.
+func (s *Service) beginOperation(w http.ResponseWriter, r *http.Request, opType string) (op Operation, existing, ok bool) {
+	key := r.Header.Get(idempotencyKeyHeader)
+	if len(key) > maxIdempotencyKeyLen {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("%s header exceeds %d characters", idempotencyKeyHeader, maxIdempotencyKeyLen))
+		return Operation{}, false, false
+	}
+	op, existing, err := s.operations.begin(opType, key)
+	if err != nil {
+		writeError(w, http.StatusUnprocessableEntity, err.Error())
+		return Operation{}, false, false
+	}
+	if existing {
+		s.apiLog.Infof("Request with idempotency key '%s' matches operation %s, not starting another %s", key, op.ID, opType)
+	}
+	w.Header().Set("Location", "/operations/"+op.ID)
+	w.Header().Set(operationIDHeader, op.ID)
+	return op, existing, true
+}
+
+// runOperation runs the given function, recording its outcome as the outcome of the operation with given ID.
+func (s *Service) runOperation(id string, f func() (interface{}, error)) (interface{}, error) {
+	result, err := f()
+	s.operations.finish(id, result, err)
+	return result, maskAny(err)
+}
+
+// watchOperation checks the state of the operation with given ID, using the given function, until it has finished.
+// The function returns whether the operation is still running, its progress, its (intermediate) result and its error (if any).
+func (s *Service) watchOperation(id string, poll func() (running bool, progress string, result interface{}, err error)) {
+	go func() {
+		for {
+			running, progress, result, err := poll()
+			if !running {
+				s.operations.finish(id, result, err)
+				return
+			}
+			s.operations.update(id, progress, result)
+			select {
+			case <-s.ctx.Done():
+				s.operations.finish(id, result, errors.New("Starter is stopping"))
+				return
+			case <-time.After(operationPollInterval):
+			}
+		}
+	}()
+}
+
+// writeOperation writes the given operation with given status.
+func writeOperation(w http.ResponseWriter, status int, op Operation) {
+	b, err := json.Marshal(op)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.WriteHeader(status)
+	w.Write(b)
+}
+
+// operationsHandler is a request handler for GET /operations & GET /operations/<id>.
+func (s *Service) operationsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		writeError(w, http.StatusMethodNotAllowed, "GET required")
+		return
+	}
+	id := strings.Trim(strings.TrimPrefix(r.URL.Path, "/operations"), "/")
+	if id == "" {
+		b, err := json.Marshal(s.operations.list())
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+		} else {
+			w.Write(b)
+		}
+		return
+	}
+	op, found := s.operations.get(id)
+	if !found {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("Operation '%s' not found", id))
+		return
+	}
+	writeOperation(w, http.StatusOK, op)
+}
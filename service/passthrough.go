@@ -0,0 +1,118 @@
+//
+// DISCLAIMER
+//
+// Copyright 2017 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+// Author Ewout Prangsma
+//
+
+package service
+
+import "strings"
+
+const (
+	PassthroughPrefixAll          = "all"
+	PassthroughPrefixAgents       = "agents"
+	PassthroughPrefixDBServers    = "dbservers"
+	PassthroughPrefixCoordinators = "coordinators"
+)
+
+// PassthroughOption holds an arangod option that is passed through to
+// the servers of a specific type (or all servers).
+type PassthroughOption struct {
+	Prefix string // Type of servers the option is passed to (all|agents|dbservers|coordinators)
+	Name   string // Name of the arangod option (without leading dashes)
+	Value  string
+}
+
+// ParsePassthroughOptionName splits an option name like `dbservers.rocksdb.block-cache-size`
+// into its server type prefix and arangod option name.
+// Returns false if the given name is not a passthrough option.
+func ParsePassthroughOptionName(name string) (prefix, optionName string, ok bool) {
+	parts := strings.SplitN(name, ".", 2)
+	if len(parts) != 2 || parts[1] == "" {
+		return "", "", false
+	}
+	switch parts[0] {
+	case PassthroughPrefixAll, PassthroughPrefixAgents, PassthroughPrefixDBServers, PassthroughPrefixCoordinators:
+		return parts[0], parts[1], true
+	default:
+		return "", "", false
+	}
+}
+
+// passthroughPrefix returns the passthrough prefix specific for the given server type.
+func passthroughPrefix(serverType ServerType) string {
+	switch serverType {
+	case ServerTypeAgent:
+		return PassthroughPrefixAgents
+	case ServerTypeDBServer:
+		return PassthroughPrefixDBServers
+	case ServerTypeCoordinator:
+		return PassthroughPrefixCoordinators
+	default:
+		return ""
+	}
+}
+
+// passthroughArgs returns the command line arguments for all passthrough options
+// that apply to a server of given type.
+// Options given for a specific server type take precedence over options given for all servers.
+func (s *Service) passthroughArgs(serverType ServerType) []string {
+	specific := make(map[string]bool)
+	prefix := passthroughPrefix(serverType)
+	for _, o := range s.PassthroughOptions {
+		if o.Prefix == prefix {
+			specific[o.Name] = true
+		}
+	}
+	var args []string
+	for _, o := range s.PassthroughOptions {
+		if IsServerBinaryOption(o.Name) {
+			// Used by the starter itself
+			continue
+		}
+		if o.Prefix == prefix || (o.Prefix == PassthroughPrefixAll && !specific[o.Name]) {
+			args = append(args, "--"+o.Name, o.Value)
+		}
+	}
+	return args
+}
+
+// addPassthroughArgs appends all passthrough options for a server of given type to the given arguments.
+// Arguments generated by the starter with the same name as a passthrough option are removed.
+func (s *Service) addPassthroughArgs(args []string, serverType ServerType) []string {
+	passthrough := s.passthroughArgs(serverType)
+	if len(passthrough) == 0 {
+		return args
+	}
+	overridden := make(map[string]bool)
+	for i := 0; i < len(passthrough); i += 2 {
+		overridden[passthrough[i]] = true
+	}
+	result := make([]string, 0, len(args)+len(passthrough))
+	for i := 0; i < len(args); i++ {
+		if overridden[args[i]] && i+1 < len(args) {
+			// Skip option and its value
+			s.log.Debugf("Option %s is overridden by a passthrough option", args[i])
+			i++
+			continue
+		}
+		result = append(result, args[i])
+	}
+	return append(result, passthrough...)
+}
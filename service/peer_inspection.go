@@ -0,0 +1,83 @@
+//
+// DISCLAIMER
+//
+// Copyright 2017 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+// Author Ewout Prangsma
+//
+
+package service
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// peerInspectionPaths maps the resources that can be inspected through another starter
+// (`/peers/<id>/<resource>`) to the path of the API of the starter of that peer.
+var peerInspectionPaths = map[string]string{
+	"processes": "/process",
+	"health":    "/health",
+	"status":    "/status",
+}
+
+// peerInspectionHandler forwards a `/peers/<id>/<resource>` request to the starter of the peer with given ID,
+// so a client that can reach only one starter can inspect all starters of the deployment.
+func (s *Service) peerInspectionHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		writeError(w, http.StatusMethodNotAllowed, "GET required")
+		return
+	}
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/peers/"), "/")
+	if len(parts) != 2 {
+		writeError(w, http.StatusNotFound, "Unknown path, expected /peers/<id>/<resource>")
+		return
+	}
+	peerID, resource := parts[0], parts[1]
+	path, found := peerInspectionPaths[resource]
+	if !found {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("Unknown resource '%s', expected processes, health or status", resource))
+		return
+	}
+	s.mutex.Lock()
+	peer, found := s.myPeers.PeerByID(peerID)
+	s.mutex.Unlock()
+	if !found {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("Unknown peer '%s'", peerID))
+		return
+	}
+
+	req, err := http.NewRequest("GET", peer.CreateStarterURL(path), nil)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	resp, err := httpClient.Do(req.WithContext(r.Context()))
+	if err != nil {
+		s.apiLog.Debugf("Failed to inspect peer '%s': %v", peerID, err)
+		writeError(w, http.StatusBadGateway, fmt.Sprintf("Cannot reach starter of peer '%s': %v", peerID, err))
+		return
+	}
+	defer resp.Body.Close()
+	if contentType := resp.Header.Get("Content-Type"); contentType != "" {
+		w.Header().Set("Content-Type", contentType)
+	}
+	w.WriteHeader(resp.StatusCode)
+	io.Copy(w, resp.Body)
+}
@@ -0,0 +1,175 @@
+//
+// DISCLAIMER
+//
+// Copyright 2017 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+// Author Ewout Prangsma
+//
+
+package service
+
+import (
+	"encoding/json"
+	"reflect"
+	"time"
+)
+
+const (
+	agencyPeersKey         = "/arangodb-helper/starter/peers" // Agency key holding the authoritative list of peers
+	peerMembershipInterval = time.Second * 5                  // Interval between checks whether the peers in the agency must be updated
+)
+
+// runPeerMembership keeps the list of peers in the agency up to date, until the starter is stopped.
+// Once the agency is reachable, the peers are first reconciled with the peers in the agency,
+// which are authoritative after bootstrap. After that, the master writes every change of the peers to the agency.
+func (s *Service) runPeerMembership() {
+	reconciled := false
+	var written []Peer
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-time.After(peerMembershipInterval):
+		}
+		if s.stop {
+			return
+		}
+		if !reconciled {
+			if err := s.reconcilePeersWithAgency(); err != nil {
+				s.peersLog.Debugf("Cannot reconcile peers with agency: %v", err)
+				continue
+			}
+			reconciled = true
+		}
+		if !s.isMaster() {
+			continue
+		}
+		s.mutex.Lock()
+		current := s.myPeers
+		current.Peers = append([]Peer{}, s.myPeers.Peers...)
+		s.mutex.Unlock()
+		if reflect.DeepEqual(current.Peers, written) {
+			continue
+		}
+		if err := s.writeAgencyPeers(current); err != nil {
+			s.peersLog.Warningf("Failed to write peers to agency: %v", err)
+			continue
+		}
+		written = current.Peers
+	}
+}
+
+// reconcilePeersWithAgency replaces the peers of this starter (and its setup.json) with the peers in the agency.
+// Nothing is changed when the agency does not contain peers yet, or when this starter is not one of them.
+func (s *Service) reconcilePeersWithAgency() error {
+	agencyPeers, found, err := s.readAgencyPeers()
+	if err != nil {
+		return maskAny(err)
+	}
+	if !found {
+		return nil
+	}
+	if _, found := agencyPeers.PeerByID(s.ID); !found {
+		s.peersLog.Errorf("This starter is not one of the peers in the agency, it has probably been removed from the cluster. Its %s is kept unchanged.", setupFileName)
+		return nil
+	}
+	s.mutex.Lock()
+	known := make(map[string]bool)
+	for _, p := range s.myPeers.Peers {
+		known[p.ID] = true
+	}
+	s.mutex.Unlock()
+	for _, p := range agencyPeers.Peers {
+		if !known[p.ID] {
+			s.peersLog.Infof("Peer '%s' is missing in %s, adding it from the agency", p.ID, setupFileName)
+		}
+		delete(known, p.ID)
+	}
+	for id := range known {
+		s.peersLog.Infof("Peer '%s' is no longer in the agency, removing it", id)
+	}
+	s.updatePeers(agencyPeers.Peers)
+	return nil
+}
+
+// updatePeers replaces the peers of this starter with the given peers (from the master or the agency).
+// The setup is saved when the peers have changed. When the agent of this starter has been moved
+// to another peer, it is stopped.
+func (s *Service) updatePeers(newPeers []Peer) {
+	s.mutex.Lock()
+	changed := !reflect.DeepEqual(s.myPeers.Peers, newPeers)
+	oldPeer, _ := s.myPeers.PeerByID(s.ID)
+	if changed {
+		s.myPeers.Peers = newPeers
+	}
+	newPeer, _ := s.myPeers.PeerByID(s.ID)
+	s.mutex.Unlock()
+	if !changed {
+		return
+	}
+	if err := s.saveSetup(); err != nil {
+		s.peersLog.Errorf("Failed to save setup: %#v", err)
+	}
+	if oldPeer.HasAgent && !newPeer.HasAgent {
+		s.stopOwnAgent()
+	}
+}
+
+// readAgencyPeers reads the peers from the agency.
+// Returns false when the agency does not contain peers (yet).
+func (s *Service) readAgencyPeers() (peers, bool, error) {
+	query, err := json.Marshal([][]string{{agencyPeersKey}})
+	if err != nil {
+		return peers{}, false, maskAny(err)
+	}
+	content, err := s.agencyRequest("/_api/agency/read", query)
+	if err != nil {
+		return peers{}, false, maskAny(err)
+	}
+	var result []struct {
+		Helper struct {
+			Starter struct {
+				Peers *peers `json:"peers"`
+			} `json:"starter"`
+		} `json:"arangodb-helper"`
+	}
+	if err := json.Unmarshal(content, &result); err != nil {
+		return peers{}, false, maskAny(err)
+	}
+	if len(result) == 0 || result[0].Helper.Starter.Peers == nil || len(result[0].Helper.Starter.Peers.Peers) == 0 {
+		return peers{}, false, nil
+	}
+	return *result[0].Helper.Starter.Peers, true, nil
+}
+
+// writeAgencyPeers writes the given peers into the agency.
+func (s *Service) writeAgencyPeers(p peers) error {
+	operation := map[string]interface{}{
+		agencyPeersKey: map[string]interface{}{
+			"op":  "set",
+			"new": p,
+		},
+	}
+	transaction, err := json.Marshal([][]interface{}{{operation}})
+	if err != nil {
+		return maskAny(err)
+	}
+	if _, err := s.agencyRequest("/_api/agency/write", transaction); err != nil {
+		return maskAny(err)
+	}
+	return nil
+}
@@ -37,6 +37,25 @@ type Peer struct {
 	DataDir    string // Directory holding my data
 	HasAgent   bool   // If set, this peer is running an agent
 	IsSecure   bool   // If set, servers started by this peer are using an SSL connection
+	IsStandby  bool   // If set, this peer runs no servers until it is activated
+	IsPassive  bool   // If set, this peer never runs servers, it only serves the starter API
+	// If set, this peer runs a dbserver (that replaced a failed dbserver), even when started without --cluster.start-dbserver
+	HasDBServer bool `json:",omitempty"`
+	// Ports of servers that do not use the base port + offset scheme (see --cluster.<type>-port-range)
+	ServerPorts map[ServerType]int `json:",omitempty"`
+}
+
+// ServerPort returns the port the server of given type, started by this peer, listens on.
+func (p Peer) ServerPort(masterPort int, serverType ServerType) int {
+	if port, found := p.ServerPorts[serverType]; found {
+		return port
+	}
+	return masterPort + p.PortOffset + serverType.PortOffset()
+}
+
+// HasServers returns true if this peer runs servers, i.e. it is neither a standby nor passive.
+func (p Peer) HasServers() bool {
+	return !p.IsStandby && !p.IsPassive
 }
 
 // CreateStarterURL creates a URL to the relative path to the starter on this peer.
@@ -52,6 +71,8 @@ func (p Peer) CreateStarterURL(relPath string) string {
 type peers struct {
 	Peers      []Peer // All peers (index 0 is reserver for the master)
 	AgencySize int    // Number of agents
+	// Storage engine used by all servers (mmfiles|rocksdb), empty for setups created by older starters
+	StorageEngine string `json:",omitempty"`
 }
 
 // PeerByID returns a peer with given id & true, or false if not found.
@@ -64,7 +85,31 @@ func (p peers) PeerByID(id string) (Peer, bool) {
 	return Peer{}, false
 }
 
+// UpdatePeerByID replaces the peer with the same ID as the given peer.
+// Returns false if no such peer exists.
+func (p *peers) UpdatePeerByID(update Peer) bool {
+	for i, x := range p.Peers {
+		if x.ID == update.ID {
+			p.Peers[i] = update
+			return true
+		}
+	}
+	return false
+}
+
+// AgentCount returns the number of peers that run an agent.
+func (p peers) AgentCount() int {
+	count := 0
+	for _, x := range p.Peers {
+		if x.HasAgent {
+			count++
+		}
+	}
+	return count
+}
+
 // RemovePeerByID removes the peer with given ID.
+// Returns false if no such peer exists.
 func (p *peers) RemovePeerByID(id string) bool {
 	newPeers := make([]Peer, 0, len(p.Peers))
 	found := false
@@ -88,13 +133,76 @@ func (p peers) IDs() []string {
 	return list
 }
 
-// GetFreePortOffset returns the first unallocated port offset.
-func (p peers) GetFreePortOffset(peerAddress string, allPortOffsetsUnique bool) int {
-	portOffset := 0
-	for {
+// GetFreeServerPorts returns the first unallocated port in the given range of every server type,
+// for a new peer at the given address.
+// Returns nil if no ranges are given.
+func (p peers) GetFreeServerPorts(peerAddress string, allPortOffsetsUnique bool, ranges map[ServerType]PortRange) (map[ServerType]int, error) {
+	if len(ranges) == 0 {
+		return nil, nil
+	}
+	used := make(map[int]bool)
+	for _, x := range p.Peers {
+		if allPortOffsetsUnique || x.Address == peerAddress {
+			for _, port := range x.ServerPorts {
+				used[port] = true
+			}
+		}
+	}
+	result := make(map[ServerType]int)
+	for _, serverType := range []ServerType{ServerTypeAgent, ServerTypeCoordinator, ServerTypeDBServer} {
+		r, found := ranges[serverType]
+		if !found {
+			continue
+		}
+		port := r.Min
+		for port <= r.Max && used[port] {
+			port++
+		}
+		if port > r.Max {
+			return nil, maskAny(fmt.Errorf("All ports in range %s for %s servers at %s are in use", r, serverType, peerAddress))
+		}
+		used[port] = true
+		result[serverType] = port
+	}
+	return result, nil
+}
+
+// CanChangeAddress returns true if the peer with given ID can change its address to the given address,
+// i.e. its ports do not overlap with the ports of another peer at that address.
+func (p peers) CanChangeAddress(id, peerAddress string, allPortOffsetsUnique bool) bool {
+	if allPortOffsetsUnique {
+		return true
+	}
+	peer, found := p.PeerByID(id)
+	if !found {
+		return false
+	}
+	for _, x := range p.Peers {
+		if x.ID == id || x.Address != peerAddress {
+			continue
+		}
+		if x.PortOffset > peer.PortOffset-MinPortOffsetIncrement && x.PortOffset < peer.PortOffset+MinPortOffsetIncrement {
+			return false
+		}
+		for _, port := range x.ServerPorts {
+			for _, own := range peer.ServerPorts {
+				if port == own {
+					return false
+				}
+			}
+		}
+	}
+	return true
+}
+
+// GetFreePortOffset returns the first port offset of the given layout whose ports
+// do not overlap with the ports of an existing peer.
+func (p peers) GetFreePortOffset(peerAddress string, allPortOffsetsUnique bool, layout PortLayout) int {
+	for index := 0; ; index++ {
+		portOffset := layout.Offset(index)
 		found := false
 		for _, p := range p.Peers {
-			if p.PortOffset == portOffset {
+			if p.PortOffset > portOffset-MinPortOffsetIncrement && p.PortOffset < portOffset+MinPortOffsetIncrement {
 				if allPortOffsetsUnique || p.Address == peerAddress {
 					found = true
 					break
@@ -104,6 +212,5 @@ func (p peers) GetFreePortOffset(peerAddress string, allPortOffsetsUnique bool)
 		if !found {
 			return portOffset
 		}
-		portOffset += portOffsetIncrement
 	}
 }
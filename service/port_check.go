@@ -23,13 +23,14 @@
 package service
 
 import (
-	"fmt"
 	"net"
+	"strconv"
 )
 
-// IsPortOpen checks if a TCP port is free to listen on.
-func IsPortOpen(port int) bool {
-	l, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
+// IsPortOpen checks if a TCP port is free to listen on at the given IP address
+// (empty means all interfaces).
+func IsPortOpen(host string, port int) bool {
+	l, err := net.Listen("tcp", net.JoinHostPort(host, strconv.Itoa(port)))
 	if err != nil {
 		return false
 	}
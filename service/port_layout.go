@@ -0,0 +1,79 @@
+//
+// DISCLAIMER
+//
+// Copyright 2017 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+// Author Ewout Prangsma
+//
+
+package service
+
+import (
+	"fmt"
+)
+
+const (
+	// MinPortOffsetIncrement is the minimum difference between the port offsets of peers at the same address,
+	// since every peer uses its base port + offset for its own HTTP server, agent, coordinator and dbserver.
+	MinPortOffsetIncrement = _portOffsetAgent + 1
+)
+
+// PortLayout determines the port offsets of the peers at the same address.
+type PortLayout struct {
+	FirstOffset int `json:"first-offset"` // Port offset of the first peer after the master
+	Increment   int `json:"increment"`    // Difference between the port offsets of subsequent peers
+}
+
+// DefaultPortLayout is the layout of port offsets used when no other layout is configured.
+var DefaultPortLayout = PortLayout{
+	FirstOffset: portOffsetIncrement,
+	Increment:   portOffsetIncrement,
+}
+
+// Offset returns the port offset of the peer with given index (the master has index 0).
+func (l PortLayout) Offset(index int) int {
+	if index == 0 {
+		return 0
+	}
+	return l.FirstOffset + (index-1)*l.Increment
+}
+
+// String returns the layout as `first-offset+increment`.
+func (l PortLayout) String() string {
+	return fmt.Sprintf("%d+%d", l.FirstOffset, l.Increment)
+}
+
+// portLayout returns the layout of the port offsets given to new peers.
+// A configured layout is only used for local slaves.
+func (s *Service) portLayout() PortLayout {
+	if s.LocalPortLayout.Increment > 0 {
+		return s.LocalPortLayout
+	}
+	return DefaultPortLayout
+}
+
+// checkRecordedPortLayout makes the port layout recorded in setup.json the layout of this starter.
+// The layout cannot change once local slaves have been started with it.
+func (s *Service) checkRecordedPortLayout(recorded *PortLayout) {
+	if recorded == nil {
+		return
+	}
+	if s.LocalPortLayout.Increment > 0 && s.LocalPortLayout != *recorded {
+		s.log.Warningf("Ignoring port layout %s of local slaves, using port layout %s recorded in %s", s.LocalPortLayout, *recorded, setupFileName)
+	}
+	s.LocalPortLayout = *recorded
+}
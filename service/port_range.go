@@ -0,0 +1,62 @@
+//
+// DISCLAIMER
+//
+// Copyright 2017 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+// Author Ewout Prangsma
+//
+
+package service
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// PortRange is an inclusive range of TCP ports.
+type PortRange struct {
+	Min int
+	Max int
+}
+
+// ParsePortRange parses a single port (e.g. `5001`) or a range of ports (e.g. `6000-6010`).
+func ParsePortRange(value string) (PortRange, error) {
+	parts := strings.SplitN(value, "-", 2)
+	min, err := strconv.Atoi(strings.TrimSpace(parts[0]))
+	if err != nil {
+		return PortRange{}, maskAny(fmt.Errorf("Invalid port range '%s'", value))
+	}
+	max := min
+	if len(parts) == 2 {
+		if max, err = strconv.Atoi(strings.TrimSpace(parts[1])); err != nil {
+			return PortRange{}, maskAny(fmt.Errorf("Invalid port range '%s'", value))
+		}
+	}
+	if min <= 0 || max > 65535 || min > max {
+		return PortRange{}, maskAny(fmt.Errorf("Invalid port range '%s', expected ports between 1 and 65535 with the lowest port first", value))
+	}
+	return PortRange{Min: min, Max: max}, nil
+}
+
+// String returns the range as `min-max` (or `port` for a single port).
+func (r PortRange) String() string {
+	if r.Min == r.Max {
+		return strconv.Itoa(r.Min)
+	}
+	return fmt.Sprintf("%d-%d", r.Min, r.Max)
+}
@@ -0,0 +1,110 @@
+//
+// DISCLAIMER
+//
+// Copyright 2017 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+// Author Ewout Prangsma
+//
+
+package service
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	ioClassRealtime   = 1
+	ioClassBestEffort = 2
+	ioClassIdle       = 3
+)
+
+var ioClassNames = map[string]int{
+	"realtime":    ioClassRealtime,
+	"best-effort": ioClassBestEffort,
+	"idle":        ioClassIdle,
+}
+
+// parseIONice parses an I/O scheduling priority formatted as `class[:level]`,
+// where class is realtime, best-effort or idle and level is 0 (highest) - 7 (lowest).
+func parseIONice(value string) (class, level int, err error) {
+	parts := strings.SplitN(value, ":", 2)
+	class, found := ioClassNames[parts[0]]
+	if !found {
+		return 0, 0, maskAny(fmt.Errorf("Unknown I/O scheduling class '%s'", parts[0]))
+	}
+	if class != ioClassIdle {
+		level = 4
+	}
+	if len(parts) == 2 {
+		if class == ioClassIdle {
+			return 0, 0, maskAny(fmt.Errorf("I/O scheduling class idle has no level"))
+		}
+		level, err = strconv.Atoi(parts[1])
+		if err != nil || level < 0 || level > 7 {
+			return 0, 0, maskAny(fmt.Errorf("Invalid I/O scheduling level '%s', expected 0-7", parts[1]))
+		}
+	}
+	return class, level, nil
+}
+
+// IsValidIONice returns true when the given value is a valid I/O scheduling priority (`class[:level]`).
+func IsValidIONice(value string) bool {
+	_, _, err := parseIONice(value)
+	return err == nil
+}
+
+// raiseProcessLimits raises the limits of open files & processes of this process,
+// which are inherited by the servers it starts, and logs the effective limits.
+func (s *Service) raiseProcessLimits() {
+	openFiles, processes, err := setProcessLimits(s.MaxOpenFiles, s.MaxProcesses)
+	if err != nil {
+		s.log.Warningf("Cannot raise limits of open files & processes: %v", err)
+	}
+	if openFiles > 0 {
+		s.log.Infof("Servers are started with a limit of %d open files and %d processes", openFiles, processes)
+	}
+}
+
+// applyServerPriority sets the nice value & I/O scheduling priority configured
+// for the given server type on its process.
+func (s *Service) applyServerPriority(serverType ServerType, p Process) {
+	nice := s.Nice[serverType]
+	ionice := s.IONice[serverType]
+	pid := p.ProcessID()
+	if (nice == 0 && ionice == "") || pid == 0 {
+		return
+	}
+	serverLog := s.serverLogger(serverType)
+	ioClass, ioLevel := 0, 0
+	if ionice != "" {
+		var err error
+		if ioClass, ioLevel, err = parseIONice(ionice); err != nil {
+			serverLog.Warningf("Cannot set I/O priority of %s: %v", serverType, err)
+			ionice = ""
+		}
+	}
+	if err := setProcessPriority(pid, nice, ioClass, ioLevel); err != nil {
+		serverLog.Warningf("Cannot set priority of %s (pid %d): %v", serverType, pid, err)
+		return
+	}
+	if ionice == "" {
+		ionice = "unchanged"
+	}
+	serverLog.Infof("%s (pid %d) runs with nice %d and I/O priority %s", serverType, pid, nice, ionice)
+}
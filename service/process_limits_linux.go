@@ -0,0 +1,83 @@
+//
+// DISCLAIMER
+//
+// Copyright 2017 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+// Author Ewout Prangsma
+//
+
+//go:build linux
+// +build linux
+
+package service
+
+import "syscall"
+
+const (
+	rlimitNProc      = 0x6 // RLIMIT_NPROC, which is not defined by package syscall
+	ioprioWhoProcess = 1   // IOPRIO_WHO_PROCESS
+	ioprioClassShift = 13  // IOPRIO_CLASS_SHIFT
+)
+
+// setProcessLimits raises the soft limits of open files & processes of this process to the given values
+// (or to the hard limit when 0). It returns the resulting soft limits.
+func setProcessLimits(maxOpenFiles, maxProcesses uint64) (openFiles, processes uint64, err error) {
+	raise := func(resource int, value uint64) (uint64, error) {
+		var limit syscall.Rlimit
+		if err := syscall.Getrlimit(resource, &limit); err != nil {
+			return 0, maskAny(err)
+		}
+		if value == 0 {
+			value = limit.Max
+		}
+		if value > limit.Max {
+			limit.Max = value
+		}
+		limit.Cur = value
+		if err := syscall.Setrlimit(resource, &limit); err != nil {
+			syscall.Getrlimit(resource, &limit)
+			return limit.Cur, maskAny(err)
+		}
+		return limit.Cur, nil
+	}
+	openFiles, err = raise(syscall.RLIMIT_NOFILE, maxOpenFiles)
+	if err != nil {
+		return 0, 0, maskAny(err)
+	}
+	processes, err = raise(rlimitNProc, maxProcesses)
+	if err != nil {
+		return openFiles, processes, maskAny(err)
+	}
+	return openFiles, processes, nil
+}
+
+// setProcessPriority sets the nice value (if not 0) and I/O scheduling class & level (if class is not 0)
+// of the process with given pid.
+func setProcessPriority(pid, nice, ioClass, ioLevel int) error {
+	if nice != 0 {
+		if err := syscall.Setpriority(syscall.PRIO_PROCESS, pid, nice); err != nil {
+			return maskAny(err)
+		}
+	}
+	if ioClass != 0 {
+		prio := uintptr(ioClass<<ioprioClassShift | ioLevel)
+		if _, _, errno := syscall.Syscall(syscall.SYS_IOPRIO_SET, ioprioWhoProcess, uintptr(pid), prio); errno != 0 {
+			return maskAny(errno)
+		}
+	}
+	return nil
+}
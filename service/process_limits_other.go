@@ -0,0 +1,38 @@
+//
+// DISCLAIMER
+//
+// Copyright 2017 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+// Author Ewout Prangsma
+//
+
+//go:build !linux
+// +build !linux
+
+package service
+
+import "fmt"
+
+// setProcessLimits does nothing, since the limits of processes are only adjusted on Linux.
+func setProcessLimits(maxOpenFiles, maxProcesses uint64) (openFiles, processes uint64, err error) {
+	return 0, 0, nil
+}
+
+// setProcessPriority returns an error, since setting the priority of servers is only supported on Linux.
+func setProcessPriority(pid, nice, ioClass, ioLevel int) error {
+	return maskAny(fmt.Errorf("Setting process priorities is not supported on this platform"))
+}
@@ -0,0 +1,203 @@
+//
+// DISCLAIMER
+//
+// Copyright 2017 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+// Author Ewout Prangsma
+//
+
+package service
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	proxyHealthTimeout = time.Second * 5 // Time a coordinator has to respond to a health check of the proxy
+)
+
+// coordinatorProxy is a reverse proxy that load-balances requests over the healthy coordinators of all peers.
+type coordinatorProxy struct {
+	mutex    sync.Mutex
+	backends []*proxyBackend
+	next     int
+}
+
+// proxyBackend is a coordinator that requests can be forwarded to.
+type proxyBackend struct {
+	peer    Peer
+	url     *url.URL
+	proxy   *httputil.ReverseProxy
+	healthy bool
+}
+
+// runCoordinatorProxy serves the coordinator proxy on the proxy port until the service is stopped.
+func (s *Service) runCoordinatorProxy() {
+	p := &coordinatorProxy{}
+	addr := net.JoinHostPort(s.starterBindAddress(), strconv.Itoa(s.ProxyPort))
+	server := &http.Server{
+		Addr:      addr,
+		Handler:   p,
+		TLSConfig: s.tlsConfig,
+	}
+	go func() {
+		<-s.ctx.Done()
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+		defer cancel()
+		server.Shutdown(ctx)
+	}()
+	go s.checkProxyBackends(p)
+
+	var err error
+	if s.tlsConfig != nil {
+		s.log.Infof("Coordinator proxy listening on %s using TLS", addr)
+		err = server.ListenAndServeTLS("", "")
+	} else {
+		s.log.Infof("Coordinator proxy listening on %s", addr)
+		err = server.ListenAndServe()
+	}
+	if err != nil && err != http.ErrServerClosed {
+		s.log.Errorf("Coordinator proxy failed to listen on %s: %v", addr, err)
+	}
+}
+
+// checkProxyBackends periodically updates the coordinators of the given proxy from the list of peers
+// and checks their health. Unhealthy coordinators are evicted until they respond again.
+func (s *Service) checkProxyBackends(p *coordinatorProxy) {
+	for {
+		s.mutex.Lock()
+		peerList := append([]Peer{}, s.myPeers.Peers...)
+		s.mutex.Unlock()
+		backends := p.update(peerList, s.MasterPort, s.IsSecure())
+
+		for _, b := range backends {
+			ctx, cancel := context.WithTimeout(s.ctx, proxyHealthTimeout)
+			_, err := s.peerServerRequest(ctx, b.peer, ServerTypeCoordinator, "GET", "/_api/version", nil)
+			cancel()
+			if s.ctx.Err() != nil {
+				return
+			}
+			if p.setHealthy(b, err == nil) {
+				if err == nil {
+					s.log.Infof("Coordinator proxy: coordinator at %s is healthy", b.url.Host)
+				} else {
+					s.log.Warningf("Coordinator proxy: evicting coordinator at %s: %v", b.url.Host, err)
+				}
+			}
+		}
+
+		select {
+		case <-time.After(s.ProxyHealthInterval):
+		case <-s.ctx.Done():
+			return
+		}
+	}
+}
+
+// update sets the backends of the proxy to the coordinators of the given peers.
+// Known backends keep their health, new backends are unhealthy until they have been checked.
+// Returns the current backends.
+func (p *coordinatorProxy) update(peerList []Peer, masterPort int, isSecure bool) []*proxyBackend {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	known := make(map[string]*proxyBackend)
+	for _, b := range p.backends {
+		known[b.url.String()] = b
+	}
+	scheme := NewURLSchemes(isSecure).Browser
+	var backends []*proxyBackend
+	for _, peer := range peerList {
+		if !peer.HasServers() {
+			continue
+		}
+		u := &url.URL{
+			Scheme: scheme,
+			Host:   net.JoinHostPort(peer.Address, strconv.Itoa(peer.ServerPort(masterPort, ServerTypeCoordinator))),
+		}
+		if b, found := known[u.String()]; found {
+			b.peer = peer
+			backends = append(backends, b)
+			continue
+		}
+		b := &proxyBackend{peer: peer, url: u}
+		b.proxy = httputil.NewSingleHostReverseProxy(u)
+		b.proxy.Transport = &proxyTransport{proxy: p, backend: b, transport: serverHTTPClient.Transport}
+		backends = append(backends, b)
+	}
+	p.backends = backends
+	return backends
+}
+
+// proxyTransport forwards requests to a backend and evicts the backend when a request to it fails.
+// The reverse proxy responds to a failed request with a 502 (Bad Gateway).
+type proxyTransport struct {
+	proxy     *coordinatorProxy
+	backend   *proxyBackend
+	transport http.RoundTripper
+}
+
+// RoundTrip forwards the given request to the backend.
+func (t *proxyTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	resp, err := t.transport.RoundTrip(r)
+	if err != nil && r.Context().Err() == nil {
+		// Not caused by the client giving up on the request
+		t.proxy.setHealthy(t.backend, false)
+	}
+	return resp, err
+}
+
+// setHealthy sets the health of the given backend.
+// Returns true if its health has changed.
+func (p *coordinatorProxy) setHealthy(b *proxyBackend, healthy bool) bool {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	changed := b.healthy != healthy
+	b.healthy = healthy
+	return changed
+}
+
+// pick returns the next healthy backend (round robin), or nil if there is none.
+func (p *coordinatorProxy) pick() *proxyBackend {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	for i := 0; i < len(p.backends); i++ {
+		b := p.backends[(p.next+i)%len(p.backends)]
+		if b.healthy {
+			p.next = (p.next + i + 1) % len(p.backends)
+			return b
+		}
+	}
+	return nil
+}
+
+// ServeHTTP forwards the given request to a healthy coordinator.
+func (p *coordinatorProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	b := p.pick()
+	if b == nil {
+		writeError(w, http.StatusServiceUnavailable, "No healthy coordinator available")
+		return
+	}
+	b.proxy.ServeHTTP(w, r)
+}
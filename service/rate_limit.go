@@ -0,0 +1,130 @@
+//
+// DISCLAIMER
+//
+// Copyright 2017 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+// Author Ewout Prangsma
+//
+
+package service
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	rateLimitIdleTimeout  = time.Minute * 10 // Clients that have not sent a request for this long are forgotten
+	rateLimitMaxClients   = 10000            // Number of tracked clients above which idle clients are forgotten
+	defaultRateLimitBurst = 20               // Burst of requests per client when no burst is configured
+)
+
+// rateLimiter limits the number of requests per client (IP address) using a token bucket per client.
+type rateLimiter struct {
+	mutex   sync.Mutex
+	rate    float64 // Requests per second
+	burst   int     // Maximum number of requests at once
+	clients map[string]*tokenBucket
+}
+
+// tokenBucket holds the remaining requests of a single client.
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// allow takes a token from the bucket of the given client.
+// It returns false (and the time until the next token) when the bucket is empty.
+func (rl *rateLimiter) allow(client string, now time.Time) (bool, time.Duration) {
+	rl.mutex.Lock()
+	defer rl.mutex.Unlock()
+	if rl.clients == nil {
+		rl.clients = make(map[string]*tokenBucket)
+	}
+	if len(rl.clients) >= rateLimitMaxClients {
+		for c, b := range rl.clients {
+			if now.Sub(b.last) > rateLimitIdleTimeout {
+				delete(rl.clients, c)
+			}
+		}
+	}
+	b, found := rl.clients[client]
+	if !found {
+		b = &tokenBucket{tokens: float64(rl.burst), last: now}
+		rl.clients[client] = b
+	}
+	b.tokens = math.Min(float64(rl.burst), b.tokens+now.Sub(b.last).Seconds()*rl.rate)
+	b.last = now
+	if b.tokens < 1 {
+		return false, time.Duration((1 - b.tokens) / rl.rate * float64(time.Second))
+	}
+	b.tokens--
+	return true, 0
+}
+
+// isPeerAddress returns true if the given address is the address of one of the peers of the deployment.
+// Peers registered with a host name match the IP addresses that name resolves to (see peerAddressCache).
+func (s *Service) isPeerAddress(address string) bool {
+	s.mutex.Lock()
+	addresses := make([]string, 0, len(s.myPeers.Peers))
+	for _, p := range s.myPeers.Peers {
+		addresses = append(addresses, normalizeHostName(p.Address))
+	}
+	s.mutex.Unlock()
+	return s.peerAddresses.contains(addresses, address)
+}
+
+// limited wraps the given handler, such that every client (IP address) can send at most APIRateLimit
+// requests per second (with bursts of APIRateBurst requests) and request bodies are limited to APIMaxBodySize bytes.
+// Requests of peers and over the unix socket are not rate limited.
+func (s *Service) limited(next http.Handler) http.Handler {
+	if s.APIRateLimit <= 0 && s.APIMaxBodySize <= 0 {
+		return next
+	}
+	burst := s.APIRateBurst
+	if burst <= 0 {
+		burst = defaultRateLimitBurst
+	}
+	limiter := &rateLimiter{rate: s.APIRateLimit, burst: burst}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.APIMaxBodySize > 0 && r.Body != nil {
+			if r.ContentLength > s.APIMaxBodySize {
+				writeError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("Request body exceeds %d bytes", s.APIMaxBodySize))
+				return
+			}
+			r.Body = http.MaxBytesReader(w, r.Body, s.APIMaxBodySize)
+		}
+		if s.APIRateLimit > 0 {
+			if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+				client := normalizeHostName(host)
+				if !s.isPeerAddress(client) {
+					if ok, wait := limiter.allow(client, time.Now()); !ok {
+						w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+						writeError(w, http.StatusTooManyRequests, "Too many requests, slow down")
+						return
+					}
+				}
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,62 @@
+//
+// DISCLAIMER
+//
+// Copyright 2017 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+// Author Ewout Prangsma
+//
+
+package service
+
+import (
+	"net"
+	"net/http"
+)
+
+// isReadOnlyMethod returns true if requests with given method never change the state of the starter.
+func isReadOnlyMethod(method string) bool {
+	switch method {
+	case "GET", "HEAD", "OPTIONS":
+		return true
+	default:
+		return false
+	}
+}
+
+// readOnly wraps the given handler, such that all mutating requests (e.g. shutdown, restart & upgrade)
+// are refused with status 403 when APIReadOnly is set, while all inspection endpoints stay available.
+// Requests carrying a JWT signed with the JWT secret (as sent by peers, see addJwtHeader) and requests over the unix socket
+// are allowed, as are joins of new peers (protected by the join token). The source address of a request is not trusted,
+// since in local clusters all peers share one address, as do all processes on a peer.
+func (s *Service) readOnly(next http.Handler) http.Handler {
+	if !s.APIReadOnly {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if isReadOnlyMethod(r.Method) || r.URL.Path == "/hello" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		hasJwt := s.JwtSecret != "" && isValidJwtHeader(r, s.JwtSecret)
+		if _, _, err := net.SplitHostPort(r.RemoteAddr); err == nil && !hasJwt {
+			s.apiLog.Debugf("Refused %s %s from %s: API is read-only", r.Method, r.URL.Path, r.RemoteAddr)
+			writeError(w, http.StatusForbidden, "The starter API is read-only (--starter.api.read-only)")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,108 @@
+//
+// DISCLAIMER
+//
+// Copyright 2017 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+// Author Ewout Prangsma
+//
+
+//go:build linux
+// +build linux
+
+package service
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	logging "github.com/op/go-logging"
+)
+
+// ReapOrphans reaps orphaned processes that have been re-parented to this process, until the given
+// context is canceled. This is needed when the starter runs as init process (PID 1), e.g. as entrypoint
+// of a container, since otherwise terminated orphans (e.g. sub processes of arangod) remain zombies.
+func ReapOrphans(ctx context.Context, log *logging.Logger) {
+	sigChild := make(chan os.Signal, 1)
+	signal.Notify(sigChild, syscall.SIGCHLD)
+	go func() {
+		defer signal.Stop(sigChild)
+		ticker := time.NewTicker(time.Second * 10)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-sigChild:
+			case <-ticker.C:
+			}
+			reapZombies(log)
+		}
+	}()
+}
+
+// reapZombies waits for all terminated child processes of this process,
+// except those started by a process runner (which wait for them themselves).
+func reapZombies(log *logging.Logger) {
+	managedProcesses.mutex.Lock()
+	defer managedProcesses.mutex.Unlock()
+	for _, pid := range zombieChildren(os.Getpid()) {
+		if _, managed := managedProcesses.processes[pid]; managed {
+			continue
+		}
+		var status syscall.WaitStatus
+		if wpid, err := syscall.Wait4(pid, &status, syscall.WNOHANG, nil); err == nil && wpid == pid {
+			log.Debugf("Reaped orphaned process %d (exit status %d)", pid, status.ExitStatus())
+		}
+	}
+}
+
+// zombieChildren returns the IDs of all terminated child processes of the process with given ID
+// that have not been waited for.
+func zombieChildren(parentPid int) []int {
+	dirs, err := filepath.Glob("/proc/[0-9]*/stat")
+	if err != nil {
+		return nil
+	}
+	var result []int
+	for _, path := range dirs {
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		// Format: pid (comm) state ppid ..., where comm can contain spaces & parentheses
+		stat := string(content)
+		idx := strings.LastIndex(stat, ")")
+		if idx < 0 {
+			continue
+		}
+		fields := strings.Fields(stat[idx+1:])
+		if len(fields) < 2 || fields[0] != "Z" || fields[1] != strconv.Itoa(parentPid) {
+			continue
+		}
+		if pid, err := strconv.Atoi(strings.Fields(stat)[0]); err == nil {
+			result = append(result, pid)
+		}
+	}
+	return result
+}
@@ -0,0 +1,295 @@
+//
+// DISCLAIMER
+//
+// Copyright 2017 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+// Author Ewout Prangsma
+//
+
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	recoveryStartTimeout   = time.Minute * 10 // Maximum time to wait for the deployment to be up before restoring
+	recoveryRequestTimeout = time.Minute * 5
+	recoveryCheckInterval  = time.Second * 5
+	dumpInfoFileName       = "dump.json" // Name of the file written by arangodump in every dump directory
+)
+
+// isRemoteBackup returns true if the given backup (of `--recovery.from-backup`) is a hot backup
+// in a remote repository (e.g. S3://bucket/path/<backup-id>), instead of a local arangodump directory.
+func isRemoteBackup(backup string) bool {
+	return strings.Contains(backup, "://")
+}
+
+// recoverFromBackup restores the backup given by RecoveryFromBackup into the newly bootstrapped deployment.
+// Until it has finished, the deployment is not reported as ready.
+func (s *Service) recoverFromBackup() {
+	defer close(s.recoveryDone)
+	started := time.Now()
+	s.log.Infof("Recovering deployment from backup %s", s.RecoveryFromBackup)
+
+	var err error
+	if err = s.waitForRecoveryTarget(); err == nil {
+		if isRemoteBackup(s.RecoveryFromBackup) {
+			err = s.restoreHotBackup(s.RecoveryFromBackup)
+		} else {
+			err = s.restoreDump(s.RecoveryFromBackup)
+		}
+	}
+	if err != nil {
+		s.recoveryErr = err
+		s.log.Errorf("Recovery from backup %s has failed: %v", s.RecoveryFromBackup, err)
+		return
+	}
+	s.log.Info(newLogEvent("recovery", LogFields{"backup": s.RecoveryFromBackup},
+		"Recovery from backup %s has finished in %s", s.RecoveryFromBackup, time.Since(started)))
+}
+
+// waitForRecovery waits until the recovery from a backup (if any) has finished.
+// Returns the error of a failed recovery.
+func (s *Service) waitForRecovery(ctx context.Context) error {
+	if s.recoveryDone == nil {
+		return nil
+	}
+	select {
+	case <-s.recoveryDone:
+		return s.recoveryErr
+	case <-ctx.Done():
+		return maskAny(ctx.Err())
+	}
+}
+
+// waitForRecoveryTarget waits until the coordinator (or single server) of this starter is up and,
+// in a cluster, all dbservers are healthy.
+func (s *Service) waitForRecoveryTarget() error {
+	serverType := ServerType(ServerTypeCoordinator)
+	if s.isSingleMode() {
+		serverType = ServerTypeSingle
+	}
+	deadline := time.Now().Add(recoveryStartTimeout)
+	for {
+		if s.stop {
+			return maskAny(fmt.Errorf("Stopped while waiting for the deployment"))
+		}
+		if s.serverStates.get(serverType).Up {
+			if !s.isClusterMode() {
+				return nil
+			}
+			ctx, cancel := context.WithTimeout(s.ctx, recoveryRequestTimeout)
+			health, err := s.clusterHealth(ctx)
+			cancel()
+			if err != nil {
+				s.log.Debugf("Cannot get cluster health: %v", err)
+			} else {
+				dbservers, good := 0, 0
+				for _, h := range health {
+					if h.Role == "DBServer" {
+						dbservers++
+						if h.Status == "GOOD" {
+							good++
+						}
+					}
+				}
+				if dbservers > 0 && good == dbservers {
+					return nil
+				}
+			}
+		}
+		if time.Now().After(deadline) {
+			return maskAny(fmt.Errorf("Deployment is not up after %s", recoveryStartTimeout))
+		}
+		time.Sleep(recoveryCheckInterval)
+	}
+}
+
+// restoreDump restores an arangodump directory into the deployment using arangorestore.
+// The directory can be a backup created by the starter (see `--backup.dir`), a directory with
+// a dump of every database in a sub directory, or the dump of a single database.
+func (s *Service) restoreDump(dir string) error {
+	serverType := ServerType(ServerTypeCoordinator)
+	if s.isSingleMode() {
+		serverType = ServerTypeSingle
+	}
+	dumps, err := findDatabaseDumps(dir)
+	if err != nil {
+		return maskAny(err)
+	}
+	endpoint, err := s.clientToolEndpoint(serverType)
+	if err != nil {
+		return maskAny(err)
+	}
+	// The backup directory may be read-only, so the credentials are written into the data directory
+	credentials, removeCredentials, err := s.clientToolCredentials("arangorestore", s.DataDir)
+	if err != nil {
+		return maskAny(err)
+	}
+	defer removeCredentials()
+	for db, dumpDir := range dumps {
+		s.log.Infof("Restoring database '%s' from %s", db, dumpDir)
+		args := append(credentials,
+			"--server.endpoint", endpoint,
+			"--server.database", db,
+			"--server.username", "root",
+			"--create-database", "true",
+			"--input-directory", dumpDir,
+		)
+		if err := s.runClientTool("arangorestore", args, dir, s.DataDir); err != nil {
+			return maskAny(fmt.Errorf("Failed to restore database '%s': %v", db, err))
+		}
+	}
+	return nil
+}
+
+// findDatabaseDumps returns the dump directory of every database in the given arangodump directory,
+// keyed by database name.
+func findDatabaseDumps(dir string) (map[string]string, error) {
+	// Backup created by the starter
+	if content, err := ioutil.ReadFile(filepath.Join(dir, backupInfoFileName)); err == nil {
+		var info BackupInfo
+		if err := json.Unmarshal(content, &info); err != nil {
+			return nil, maskAny(fmt.Errorf("Cannot parse %s: %v", backupInfoFileName, err))
+		}
+		if !info.Success {
+			return nil, maskAny(fmt.Errorf("Backup %s has not succeeded: %s", dir, info.Error))
+		}
+		result := make(map[string]string)
+		for _, db := range info.Databases {
+			result[db] = filepath.Join(dir, db)
+		}
+		return result, nil
+	}
+	// Dump of a single database
+	if db, found := dumpDatabaseName(dir); found {
+		return map[string]string{db: dir}, nil
+	}
+	// Dump of every database in a sub directory
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, maskAny(err)
+	}
+	result := make(map[string]string)
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		subDir := filepath.Join(dir, entry.Name())
+		if db, found := dumpDatabaseName(subDir); found {
+			result[db] = subDir
+		}
+	}
+	if len(result) == 0 {
+		return nil, maskAny(fmt.Errorf("No arangodump found in %s", dir))
+	}
+	return result, nil
+}
+
+// dumpDatabaseName returns the name of the database dumped by arangodump into the given directory.
+// Returns false if the directory contains no dump.
+func dumpDatabaseName(dir string) (string, bool) {
+	content, err := ioutil.ReadFile(filepath.Join(dir, dumpInfoFileName))
+	if err != nil {
+		return "", false
+	}
+	var info struct {
+		Database string `json:"database"`
+	}
+	if err := json.Unmarshal(content, &info); err != nil || info.Database == "" {
+		return filepath.Base(dir), true
+	}
+	return info.Database, true
+}
+
+// restoreHotBackup downloads the hot backup at the given location in a remote repository
+// (e.g. S3://bucket/path/<backup-id>) into the deployment and restores it.
+func (s *Service) restoreHotBackup(location string) error {
+	repository, id := path.Split(strings.TrimSuffix(location, "/"))
+	repository = strings.TrimSuffix(repository, "/")
+	if id == "" || repository == "" {
+		return maskAny(fmt.Errorf("Invalid hot backup location %s, expected <repository>/<backup-id>", location))
+	}
+	config := make(map[string]interface{})
+	if s.RecoveryRemoteConfig != "" {
+		content, err := ioutil.ReadFile(s.RecoveryRemoteConfig)
+		if err != nil {
+			return maskAny(err)
+		}
+		if err := json.Unmarshal(content, &config); err != nil {
+			return maskAny(fmt.Errorf("Cannot parse %s: %v", s.RecoveryRemoteConfig, err))
+		}
+	}
+
+	// Download the hot backup (tracked like any other hot backup operation)
+	if err := s.hotBackups.start(HotBackupOperationDownload, id); err != nil {
+		return maskAny(err)
+	}
+	s.log.Infof("Downloading hot backup %s from %s", id, repository)
+	s.transferHotBackup(HotBackupOperationDownload, HotBackupTransferRequest{
+		ID:               id,
+		RemoteRepository: repository,
+		Config:           config,
+	})
+	if status := s.hotBackups.getStatus(); status.Failed {
+		return maskAny(fmt.Errorf("Download of hot backup %s has failed: %s", id, status.Reason))
+	}
+
+	// Restore it
+	s.log.Infof("Restoring hot backup %s", id)
+	body, err := json.Marshal(map[string]string{"id": id})
+	if err != nil {
+		return maskAny(err)
+	}
+	var result map[string]interface{}
+	ctx, cancel := context.WithTimeout(s.ctx, recoveryRequestTimeout)
+	err = s.hotBackupRequest(ctx, serverLongHTTPClient, "/_admin/backup/restore", body, &result)
+	cancel()
+	if err != nil {
+		return maskAny(fmt.Errorf("Restore of hot backup %s has failed: %v", id, err))
+	}
+
+	// The servers restart while restoring, wait until they are back
+	time.Sleep(recoveryCheckInterval)
+	if err := s.waitForRecoveryTarget(); err != nil {
+		return maskAny(err)
+	}
+	return nil
+}
+
+// ValidateRecoveryBackup checks that the given backup (of `--recovery.from-backup`) can be restored.
+func ValidateRecoveryBackup(backup string) error {
+	if isRemoteBackup(backup) {
+		return nil
+	}
+	if _, err := os.Stat(backup); err != nil {
+		return maskAny(err)
+	}
+	if _, err := findDatabaseDumps(backup); err != nil {
+		return maskAny(err)
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+//
+// DISCLAIMER
+//
+// Copyright 2017 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+// Author Ewout Prangsma
+//
+
+package service
+
+import (
+	"net/url"
+	"reflect"
+	"strings"
+)
+
+const (
+	// RedactedValue replaces secrets in the configuration, command lines & diagnostics shown by the starter.
+	RedactedValue = "xxxxx"
+)
+
+// IsSecretName returns true if the given (option, parameter or field) name suggests that its value is a secret.
+func IsSecretName(name string) bool {
+	lowerName := strings.ToLower(name)
+	for _, word := range []string{"password", "passwd", "secret", "token", "credential"} {
+		if strings.Contains(lowerName, word) {
+			return true
+		}
+	}
+	return false
+}
+
+// RedactValue hides the given value when the given name suggests that it holds a secret
+// (e.g. a password), or hides the password & secret query parameters of a URL in the value.
+// Returns the (redacted) value and true if anything was hidden.
+func RedactValue(name, value string) (string, bool) {
+	if value != "" && IsSecretName(name) {
+		return RedactedValue, true
+	}
+	return RedactURL(value)
+}
+
+// RedactURL hides the password & secret query parameters of the given value, if it is a URL.
+// Returns the (redacted) value and true if anything was hidden.
+func RedactURL(value string) (string, bool) {
+	if value == "" {
+		return value, false
+	}
+	u, err := url.Parse(value)
+	if err != nil || u.Scheme == "" {
+		return value, false
+	}
+	redacted := false
+	if u.User != nil {
+		if _, hasPassword := u.User.Password(); hasPassword {
+			u.User = url.UserPassword(u.User.Username(), RedactedValue)
+			redacted = true
+		}
+	}
+	query := u.Query()
+	for key := range query {
+		if IsSecretName(key) {
+			query.Set(key, RedactedValue)
+			redacted = true
+		}
+	}
+	if !redacted {
+		return value, false
+	}
+	u.RawQuery = query.Encode()
+	return u.String(), true
+}
+
+// redactFields hides the secrets in all string fields of the given struct (pointer),
+// based on the names of the fields and URLs in their values.
+func redactFields(v interface{}) {
+	value := reflect.ValueOf(v).Elem()
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Field(i)
+		if field.Kind() != reflect.String || !field.CanSet() {
+			continue
+		}
+		if redactedValue, redacted := RedactValue(value.Type().Field(i).Name, field.String()); redacted {
+			field.SetString(redactedValue)
+		}
+	}
+}
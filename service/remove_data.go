@@ -0,0 +1,69 @@
+//
+// DISCLAIMER
+//
+// Copyright 2017 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+// Author Ewout Prangsma
+//
+
+package service
+
+import (
+	"os"
+)
+
+// setRemoveDataOnStop marks this starter and all its local slaves
+// to remove their data after their servers have stopped.
+func (s *Service) setRemoveDataOnStop() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.removeDataOnStop = true
+	for _, slave := range s.localSlaves {
+		slave.setRemoveDataOnStop()
+	}
+}
+
+// removeData removes the data directories of all servers of this starter
+// and its setup file.
+// The data directory itself is only removed for local slaves, since it was created by the
+// starter and it is expected to be empty at that point.
+func (s *Service) removeData() {
+	if _, found := s.myPeers.PeerByID(s.ID); found {
+		for _, serverType := range []ServerType{ServerTypeAgent, ServerTypeDBServer, ServerTypeCoordinator, ServerTypeSingle} {
+			dir, err := s.serverHostDir(serverType)
+			if err != nil {
+				s.log.Warningf("Failed to get data directory of %s: %v", serverType, err)
+				continue
+			}
+			if _, err := os.Stat(dir); os.IsNotExist(err) {
+				continue
+			}
+			s.log.Infof("Removing %s", dir)
+			if err := os.RemoveAll(dir); err != nil {
+				s.log.Errorf("Failed to remove %s: %v", dir, err)
+			}
+		}
+	}
+	if err := removeSetupFiles(s.DataDir); err != nil {
+		s.log.Errorf("Failed to remove %s: %v", setupFileName, err)
+	}
+	if s.isLocalSlave {
+		if err := os.Remove(s.DataDir); err != nil && !os.IsNotExist(err) {
+			s.log.Warningf("Failed to remove %s: %v", s.DataDir, err)
+		}
+	}
+}
@@ -0,0 +1,479 @@
+//
+// DISCLAIMER
+//
+// Copyright 2017 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+// Author Ewout Prangsma
+//
+
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	replaceStartTimeout   = time.Minute * 5 // Maximum time for the new dbserver to join the cluster
+	replaceResyncTimeout  = time.Hour       // Maximum time for all shards to be moved away from the failed dbserver
+	replaceCheckInterval  = time.Second * 5 // Interval between checks of the progress of a replacement
+	replaceRequestTimeout = time.Second * 30
+)
+
+// Phases of the replacement of a failed dbserver
+const (
+	ReplacePhaseStartDBServer = "start-dbserver" // A fresh dbserver is started and joins the cluster
+	ReplacePhaseResync        = "resync"         // Shards are moved away from the failed dbserver & resynchronized
+	ReplacePhaseRemoveServer  = "remove-server"  // The failed dbserver is removed from the cluster
+)
+
+var (
+	errReplacementRunning = errors.New("Replacement already running")
+	errDBServerRunning    = errors.New("DBServer already running")
+)
+
+// ReplaceDBServerRequest is the JSON body of a POST `/dbserver/replace` request.
+type ReplaceDBServerRequest struct {
+	From   string `json:"from"`              // Cluster ID of the failed dbserver (e.g. PRMR-1234)
+	PeerID string `json:"peer-id,omitempty"` // ID of the peer to start the new dbserver on. If empty, the first peer without a dbserver is used.
+}
+
+// ReplaceDBServerStatus is the JSON response of a `/dbserver/replace` request.
+type ReplaceDBServerStatus struct {
+	Running       bool   `json:"running,omitempty"`        // If set, the replacement is in progress
+	Ready         bool   `json:"ready,omitempty"`          // If set, the replacement has finished successfully
+	Failed        bool   `json:"failed,omitempty"`         // If set, the replacement has failed
+	Reason        string `json:"reason,omitempty"`         // Reason of the failure (if any)
+	From          string `json:"from,omitempty"`           // Cluster ID of the failed dbserver
+	PeerID        string `json:"peer-id,omitempty"`        // ID of the peer running the new dbserver
+	NewServerID   string `json:"new-server-id,omitempty"`  // Cluster ID of the new dbserver (once it has joined)
+	Phase         string `json:"phase,omitempty"`          // start-dbserver | resync | remove-server
+	PendingShards int    `json:"pending-shards,omitempty"` // Number of shards that still use the failed dbserver or are not in sync
+}
+
+// replacementManager holds the state of the replacement of a failed dbserver, orchestrated by the master.
+type replacementManager struct {
+	mutex  sync.Mutex
+	status ReplaceDBServerStatus
+}
+
+// getStatus returns a copy of the current replacement status.
+func (m *replacementManager) getStatus() ReplaceDBServerStatus {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.status
+}
+
+// update calls the given function with exclusive access to the replacement status.
+func (m *replacementManager) update(f func(status *ReplaceDBServerStatus)) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	f(&m.status)
+}
+
+// clusterServerHealth holds the health of a single server, as reported by `/_admin/cluster/health`.
+type clusterServerHealth struct {
+	Role     string `json:"Role"`
+	Status   string `json:"Status"`
+	Endpoint string `json:"Endpoint"`
+}
+
+// replaceDBServerHandler starts the replacement of a failed dbserver (POST) or returns its status (GET).
+// This request must be handled by the master, other peers redirect it to the master.
+func (s *Service) replaceDBServerHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" && r.Method != "GET" {
+		writeError(w, http.StatusMethodNotAllowed, "GET or POST required")
+		return
+	}
+	if len(s.myPeers.Peers) == 0 {
+		writeError(w, http.StatusPreconditionFailed, "No master known.")
+		return
+	}
+	if master := s.myPeers.Peers[0]; master.ID != s.ID {
+		w.Header().Add("Location", master.CreateStarterURL("/dbserver/replace"))
+		w.WriteHeader(http.StatusTemporaryRedirect)
+		return
+	}
+
+	if r.Method == "POST" {
+		var req ReplaceDBServerRequest
+		defer r.Body.Close()
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("Cannot read request body: %v", err.Error()))
+			return
+		}
+		if err := json.Unmarshal(body, &req); err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("Cannot parse request body: %v", err.Error()))
+			return
+		}
+		if req.From == "" {
+			writeError(w, http.StatusBadRequest, "From must be set.")
+			return
+		}
+		if err := s.startReplaceDBServer(req); errors.Cause(err) == errReplacementRunning {
+			writeError(w, http.StatusConflict, err.Error())
+			return
+		} else if err != nil {
+			writeError(w, http.StatusPreconditionFailed, err.Error())
+			return
+		}
+	}
+	b, err := json.Marshal(s.replacement.getStatus())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+	} else {
+		w.Write(b)
+	}
+}
+
+// startDBServerHandler handles a `/dbserver/start` request, send by the master to make a peer
+// start a fresh dbserver. An existing dbserver directory is moved aside.
+// The peer records that it runs a dbserver, so it is started again when the starter is restarted.
+func (s *Service) startDBServerHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		writeError(w, http.StatusMethodNotAllowed, "POST required")
+		return
+	}
+
+	// Claim exclusive access to our data structures
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	myPeer, found := s.myPeers.PeerByID(s.ID)
+	if !found || s.runner == nil {
+		writeError(w, http.StatusPreconditionFailed, "Not ready yet")
+		return
+	}
+	if myPeer.IsStandby {
+		writeError(w, http.StatusPreconditionFailed, "Standby peers cannot start a dbserver, activate them instead")
+		return
+	}
+	if myPeer.IsPassive {
+		writeError(w, http.StatusPreconditionFailed, "Passive peers cannot start a dbserver")
+		return
+	}
+	if s.servers.dbserverProc != nil {
+		writeError(w, http.StatusConflict, errDBServerRunning.Error())
+		return
+	}
+	myHostDir, err := s.serverHostDir(ServerTypeDBServer)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if _, err := os.Stat(myHostDir); err == nil {
+		oldHostDir := fmt.Sprintf("%s.replaced-%s", myHostDir, time.Now().Format("20060102-150405"))
+		s.log.Infof("Moving existing dbserver directory to %s", oldHostDir)
+		if err := os.Rename(myHostDir, oldHostDir); err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+	}
+	s.log.Info("Starting a fresh dbserver")
+	myPeer.HasDBServer = true
+	s.myPeers.UpdatePeerByID(myPeer)
+	if err := s.saveSetup(); err != nil {
+		s.log.Errorf("Failed to save setup: %#v", err)
+	}
+	s.StartDBserver = true
+	go s.runArangod(s.runner, myPeer, ServerTypeDBServer, &s.servers.dbserverProc, &s.StartDBserver)
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("OK"))
+}
+
+// startReplaceDBServer checks that the given dbserver has failed, starts a fresh dbserver
+// on the requested peer (or the first peer that has no dbserver) and then continues the replacement
+// in the background.
+func (s *Service) startReplaceDBServer(req ReplaceDBServerRequest) error {
+	var err error
+	s.replacement.update(func(status *ReplaceDBServerStatus) {
+		if status.Running {
+			err = maskAny(errReplacementRunning)
+			return
+		}
+		*status = ReplaceDBServerStatus{
+			Running: true,
+			From:    req.From,
+			Phase:   ReplacePhaseStartDBServer,
+		}
+	})
+	if err != nil {
+		return maskAny(err)
+	}
+	failed := func(err error) error {
+		s.replacement.update(func(status *ReplaceDBServerStatus) {
+			status.Running = false
+			status.Failed = true
+			status.Reason = err.Error()
+		})
+		return maskAny(err)
+	}
+
+	ctx, cancel := context.WithTimeout(s.ctx, replaceRequestTimeout)
+	defer cancel()
+	health, err := s.clusterHealth(ctx)
+	if err != nil {
+		return failed(err)
+	}
+	if h, found := health[req.From]; !found {
+		return failed(fmt.Errorf("Unknown server '%s'", req.From))
+	} else if h.Role != "DBServer" {
+		return failed(fmt.Errorf("Server '%s' is not a dbserver but a %s", req.From, h.Role))
+	} else if h.Status != "FAILED" {
+		return failed(fmt.Errorf("Server '%s' has not failed (status %s)", req.From, h.Status))
+	}
+	knownServers := make(map[string]bool)
+	for id := range health {
+		knownServers[id] = true
+	}
+
+	// Start a fresh dbserver
+	s.mutex.Lock()
+	peerList := append([]Peer{}, s.myPeers.Peers...)
+	s.mutex.Unlock()
+	var peer Peer
+	found := false
+	for _, p := range peerList {
+		if !p.HasServers() || (req.PeerID != "" && p.ID != req.PeerID) {
+			continue
+		}
+		err := s.startPeerDBServer(ctx, p)
+		if err == nil {
+			peer = p
+			found = true
+			break
+		} else if req.PeerID != "" || errors.Cause(err) != errDBServerRunning {
+			return failed(errors.Wrapf(err, "Cannot start dbserver on peer '%s'", p.ID))
+		}
+	}
+	if !found {
+		if req.PeerID != "" {
+			return failed(fmt.Errorf("Unknown peer '%s'", req.PeerID))
+		}
+		return failed(fmt.Errorf("All peers are already running a dbserver"))
+	}
+	s.log.Infof("Replacing failed dbserver '%s' by a new dbserver on peer '%s'", req.From, peer.ID)
+	s.mutex.Lock()
+	if p, found := s.myPeers.PeerByID(peer.ID); found && !p.HasDBServer {
+		p.HasDBServer = true
+		s.myPeers.UpdatePeerByID(p)
+		if err := s.saveSetup(); err != nil {
+			s.log.Errorf("Failed to save setup: %#v", err)
+		}
+	}
+	s.mutex.Unlock()
+	s.replacement.update(func(status *ReplaceDBServerStatus) {
+		status.PeerID = peer.ID
+	})
+	go s.runReplaceDBServer(req.From, knownServers)
+	return nil
+}
+
+// runReplaceDBServer waits for the new dbserver to join the cluster and for all shards to be moved
+// away from the failed dbserver, after which the failed dbserver is removed from the cluster.
+func (s *Service) runReplaceDBServer(from string, knownServers map[string]bool) {
+	setPhase := func(phase string) {
+		s.replacement.update(func(status *ReplaceDBServerStatus) {
+			status.Phase = phase
+		})
+	}
+	fail := func(err error) {
+		s.log.Errorf("Failed to replace dbserver '%s': %v", from, err)
+		s.replacement.update(func(status *ReplaceDBServerStatus) {
+			status.Running = false
+			status.Failed = true
+			status.Reason = err.Error()
+		})
+	}
+
+	// Wait for the new dbserver to join
+	deadline := time.Now().Add(replaceStartTimeout)
+	for {
+		ctx, cancel := context.WithTimeout(s.ctx, replaceRequestTimeout)
+		health, err := s.clusterHealth(ctx)
+		cancel()
+		newServerID := ""
+		if err == nil {
+			for id, h := range health {
+				if !knownServers[id] && h.Role == "DBServer" && h.Status == "GOOD" {
+					newServerID = id
+				}
+			}
+		}
+		if newServerID != "" {
+			s.log.Infof("New dbserver '%s' has joined the cluster", newServerID)
+			s.replacement.update(func(status *ReplaceDBServerStatus) {
+				status.NewServerID = newServerID
+			})
+			break
+		}
+		if time.Now().After(deadline) {
+			fail(fmt.Errorf("New dbserver has not joined the cluster after %s", replaceStartTimeout))
+			return
+		}
+		if s.stop {
+			fail(fmt.Errorf("Stopped while waiting for the new dbserver"))
+			return
+		}
+		time.Sleep(replaceCheckInterval)
+	}
+
+	// Wait until the supervision has moved all shards away from the failed dbserver
+	setPhase(ReplacePhaseResync)
+	deadline = time.Now().Add(replaceResyncTimeout)
+	for {
+		ctx, cancel := context.WithTimeout(s.ctx, replaceRequestTimeout)
+		pending, err := s.pendingShards(ctx, from)
+		cancel()
+		if err != nil {
+			s.log.Debugf("Cannot check shards: %v", err)
+		} else {
+			s.replacement.update(func(status *ReplaceDBServerStatus) {
+				status.PendingShards = pending
+			})
+			if pending == 0 {
+				break
+			}
+		}
+		if time.Now().After(deadline) {
+			fail(fmt.Errorf("Shards have not been resynchronized after %s", replaceResyncTimeout))
+			return
+		}
+		if s.stop {
+			fail(fmt.Errorf("Stopped while waiting for shards to resynchronize"))
+			return
+		}
+		time.Sleep(replaceCheckInterval)
+	}
+
+	// Remove the failed dbserver
+	setPhase(ReplacePhaseRemoveServer)
+	body, _ := json.Marshal(from)
+	ctx, cancel := context.WithTimeout(s.ctx, replaceRequestTimeout)
+	_, err := s.clusterRequest(ctx, ServerTypeCoordinator, "POST", "/_admin/cluster/removeServer", body)
+	cancel()
+	if err != nil {
+		fail(errors.Wrapf(err, "Cannot remove server '%s'", from))
+		return
+	}
+	s.log.Infof("Failed dbserver '%s' has been replaced", from)
+	s.replacement.update(func(status *ReplaceDBServerStatus) {
+		status.Running = false
+		status.Ready = true
+	})
+}
+
+// startPeerDBServer asks the given peer to start a fresh dbserver.
+func (s *Service) startPeerDBServer(ctx context.Context, peer Peer) error {
+	req, err := http.NewRequest("POST", peer.CreateStarterURL("/dbserver/start"), nil)
+	if err != nil {
+		return maskAny(err)
+	}
+	if err := addJwtHeader(req, s.JwtSecret); err != nil {
+		return maskAny(err)
+	}
+	resp, err := httpClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return maskAny(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+	body, _ := ioutil.ReadAll(resp.Body)
+	var errResp ErrorResponse
+	message := fmt.Sprintf("Invalid status %d", resp.StatusCode)
+	if err := json.Unmarshal(body, &errResp); err == nil && errResp.Error != "" {
+		message = errResp.Error
+	}
+	if resp.StatusCode == http.StatusConflict {
+		return maskAny(errors.Wrap(errDBServerRunning, message))
+	}
+	return maskAny(errors.New(message))
+}
+
+// clusterHealth returns the health of all servers of the cluster, by their ID.
+func (s *Service) clusterHealth(ctx context.Context) (map[string]clusterServerHealth, error) {
+	content, err := s.clusterRequest(ctx, ServerTypeCoordinator, "GET", "/_admin/cluster/health", nil)
+	if err != nil {
+		return nil, maskAny(err)
+	}
+	var resp struct {
+		Health map[string]clusterServerHealth `json:"Health"`
+	}
+	if err := json.Unmarshal(content, &resp); err != nil {
+		return nil, maskAny(err)
+	}
+	return resp.Health, nil
+}
+
+// pendingShards returns the number of shards that are planned on the given server
+// or whose followers are not yet in sync.
+func (s *Service) pendingShards(ctx context.Context, serverID string) (int, error) {
+	content, err := s.clusterRequest(ctx, ServerTypeAgent, "POST", "/_api/agency/read", []byte(`[["/arango/Plan/Collections","/arango/Current/Collections"]]`))
+	if err != nil {
+		return 0, maskAny(err)
+	}
+	var resp []struct {
+		Arango struct {
+			Plan struct {
+				Collections map[string]map[string]struct {
+					Shards map[string][]string `json:"shards"`
+				} `json:"Collections"`
+			} `json:"Plan"`
+			Current struct {
+				Collections map[string]map[string]map[string]struct {
+					Servers []string `json:"servers"`
+				} `json:"Collections"`
+			} `json:"Current"`
+		} `json:"arango"`
+	}
+	if err := json.Unmarshal(content, &resp); err != nil {
+		return 0, maskAny(err)
+	}
+	if len(resp) == 0 {
+		return 0, maskAny(fmt.Errorf("Empty agency response"))
+	}
+	plan, current := resp[0].Arango.Plan.Collections, resp[0].Arango.Current.Collections
+	pending := 0
+	for db, collections := range plan {
+		for collID, collection := range collections {
+			for shard, planServers := range collection.Shards {
+				inSync := make(map[string]bool)
+				for _, id := range current[db][collID][shard].Servers {
+					inSync[id] = true
+				}
+				for _, id := range planServers {
+					if id == serverID || !inSync[id] {
+						pending++
+						break
+					}
+				}
+			}
+		}
+	}
+	return pending, nil
+}
@@ -0,0 +1,132 @@
+//
+// DISCLAIMER
+//
+// Copyright 2017 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+// Author Ewout Prangsma
+//
+
+package service
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	resourceSampleInterval = time.Second * 10 // Interval between samples of the resource usage of the servers
+)
+
+// ProcessUsage holds the resource usage of a process, as reported by its runner.
+type ProcessUsage struct {
+	CPUTime   time.Duration // Total CPU time used by the process
+	RSS       uint64        // Resident set size in bytes
+	OpenFiles int           // Number of open file descriptors (0 if unknown)
+}
+
+// serverResources holds the last sampled resource usage of a server.
+type serverResources struct {
+	CPUPercent float64 // CPU usage (100 = 1 core) between the last 2 samples
+	RSS        uint64
+	OpenFiles  int
+	DiskUsage  int64 // Size (in bytes) of all files in the directory of the server
+	cpuTime    time.Duration
+	sampledAt  time.Time
+}
+
+// resourceSamples holds the last sampled resource usage of all servers started by the starter.
+type resourceSamples struct {
+	mutex   sync.Mutex
+	samples map[ServerType]serverResources
+}
+
+// get returns the last sample of the server of given type.
+func (r *resourceSamples) get(serverType ServerType) (serverResources, bool) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	sample, found := r.samples[serverType]
+	return sample, found
+}
+
+// add records a new usage of the server of given type, computing its CPU percentage
+// from the previous sample.
+func (r *resourceSamples) add(serverType ServerType, usage ProcessUsage, diskUsage int64) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.samples == nil {
+		r.samples = make(map[ServerType]serverResources)
+	}
+	now := time.Now()
+	sample := serverResources{
+		RSS:       usage.RSS,
+		OpenFiles: usage.OpenFiles,
+		DiskUsage: diskUsage,
+		cpuTime:   usage.CPUTime,
+		sampledAt: now,
+	}
+	if prev, found := r.samples[serverType]; found && usage.CPUTime >= prev.cpuTime {
+		if elapsed := now.Sub(prev.sampledAt); elapsed > 0 {
+			sample.CPUPercent = float64(usage.CPUTime-prev.cpuTime) * 100 / float64(elapsed)
+		}
+	}
+	r.samples[serverType] = sample
+}
+
+// remove forgets the samples of the server of given type.
+func (r *resourceSamples) remove(serverType ServerType) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	delete(r.samples, serverType)
+}
+
+// sampleResourceUsage periodically samples the resource usage of all servers started by this starter.
+func (s *Service) sampleResourceUsage() {
+	for !s.stop {
+		for _, serverType := range []ServerType{ServerTypeAgent, ServerTypeDBServer, ServerTypeCoordinator, ServerTypeSingle} {
+			p := s.serverProcess(serverType)
+			if p == nil {
+				s.resources.remove(serverType)
+				continue
+			}
+			usage, err := p.Usage()
+			if err != nil {
+				s.log.Debugf("Cannot get resource usage of %s: %v", serverType, err)
+				continue
+			}
+			var diskUsage int64
+			if myHostDir, err := s.serverHostDir(serverType); err == nil {
+				diskUsage = directorySize(myHostDir)
+			}
+			s.resources.add(serverType, usage, diskUsage)
+		}
+		time.Sleep(resourceSampleInterval)
+	}
+}
+
+// directorySize returns the total size of all files in the given directory (recursive).
+func directorySize(dir string) int64 {
+	var size int64
+	filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size
+}
@@ -0,0 +1,99 @@
+//
+// DISCLAIMER
+//
+// Copyright 2017 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+// Author Ewout Prangsma
+//
+
+package service
+
+import (
+	"fmt"
+	"time"
+)
+
+// Restart policies of servers
+const (
+	RestartPolicyAlways    = "always"     // Servers are always restarted when they terminate
+	RestartPolicyOnFailure = "on-failure" // Servers are only restarted when they terminate with a non-zero exit code
+	RestartPolicyNever     = "never"      // Servers are never restarted
+)
+
+// IsValidRestartPolicy returns true if the given restart policy is supported.
+func IsValidRestartPolicy(policy string) bool {
+	switch policy {
+	case RestartPolicyAlways, RestartPolicyOnFailure, RestartPolicyNever:
+		return true
+	default:
+		return false
+	}
+}
+
+// shouldRestart decides, using the restart policy, whether a server that has terminated with given exit code
+// after given number of consecutive quick failures must be restarted.
+// When the server must not be restarted because it has failed, the reason of the failure is returned.
+func (s *Service) shouldRestart(exitCode, recentFailures int) (bool, string) {
+	switch s.RestartPolicy {
+	case RestartPolicyNever:
+		if exitCode != 0 {
+			return false, fmt.Sprintf("terminated with exit code %d (restart policy %s)", exitCode, s.RestartPolicy)
+		}
+		return false, ""
+	case RestartPolicyOnFailure:
+		if exitCode == 0 {
+			return false, ""
+		}
+	}
+	if s.RestartMaxRetries > 0 && recentFailures > s.RestartMaxRetries {
+		return false, fmt.Sprintf("terminated quickly %d times in a row", recentFailures)
+	}
+	return true, ""
+}
+
+// restartBackoff returns the time to wait before restarting a server after given number of
+// consecutive quick failures. The time doubles with every failure, up to RestartBackoffMax.
+func (s *Service) restartBackoff(recentFailures int) time.Duration {
+	if recentFailures <= 0 || s.RestartBackoffInitial <= 0 {
+		return 0
+	}
+	delay := s.RestartBackoffInitial
+	for i := 1; i < recentFailures; i++ {
+		delay *= 2
+		if s.RestartBackoffMax > 0 && delay >= s.RestartBackoffMax {
+			return s.RestartBackoffMax
+		}
+	}
+	if s.RestartBackoffMax > 0 && delay > s.RestartBackoffMax {
+		return s.RestartBackoffMax
+	}
+	return delay
+}
+
+// waitBeforeRestart waits the given time before a server is restarted.
+// Returns false when the starter is stopped while waiting.
+func (s *Service) waitBeforeRestart(delay time.Duration) bool {
+	if delay <= 0 {
+		return !s.stop
+	}
+	select {
+	case <-time.After(delay):
+		return !s.stop
+	case <-s.ctx.Done():
+		return false
+	}
+}
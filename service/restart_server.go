@@ -0,0 +1,88 @@
+//
+// DISCLAIMER
+//
+// Copyright 2017 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+// Author Ewout Prangsma
+//
+
+package service
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	restartServerTimeout = time.Minute * 5 // Maximum time for a server to be up again after a restart on request
+)
+
+// restartServerHandler is a request handler for POST /server/restart.
+// It restarts the server of the type given in the `type` query.
+// The response is sent once the server is up again.
+func (s *Service) restartServerHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		writeError(w, http.StatusMethodNotAllowed, "POST required")
+		return
+	}
+	serverType := ServerType(r.FormValue("type"))
+	ctx, cancel := context.WithTimeout(r.Context(), restartServerTimeout)
+	defer cancel()
+	if err := s.restartServer(ctx, serverType); errors.Cause(err) == errServerNotRunning {
+		writeError(w, http.StatusNotFound, err.Error())
+	} else if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+	} else {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("OK"))
+	}
+}
+
+// restartServer terminates the server of given type, so it is restarted regardless of
+// the restart policy, and waits until it is up again.
+func (s *Service) restartServer(ctx context.Context, serverType ServerType) error {
+	p := s.serverProcess(serverType)
+	if p == nil {
+		return maskAny(errors.Wrapf(errServerNotRunning, "No %s started", serverType))
+	}
+	starts := s.serverStates.get(serverType).Starts
+	s.log.Infof("Restarting %s on request", serverType)
+	s.serverStates.requestRestart(serverType)
+	if err := p.Terminate(); err != nil {
+		s.serverStates.takeRestartRequest(serverType)
+		return maskAny(err)
+	}
+	for {
+		state := s.serverStates.get(serverType)
+		if state.Failed {
+			return maskAny(fmt.Errorf("%s has failed after restart: %s", serverType, state.FailReason))
+		}
+		if state.Up && state.Starts > starts {
+			s.log.Infof("%s is up again after restart", serverType)
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return maskAny(fmt.Errorf("%s is not up after restart: %v", serverType, ctx.Err()))
+		case <-time.After(time.Second):
+		}
+	}
+}
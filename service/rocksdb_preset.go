@@ -0,0 +1,177 @@
+//
+// DISCLAIMER
+//
+// Copyright 2017 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+// Author Ewout Prangsma
+//
+
+package service
+
+import (
+	"fmt"
+	"strconv"
+)
+
+const (
+	// RocksDBPresetAuto selects the small or large preset, depending on the memory of the server.
+	RocksDBPresetAuto = "auto"
+	// RocksDBPresetSmall limits the memory used by RocksDB, for small machines and development.
+	RocksDBPresetSmall = "small"
+	// RocksDBPresetLarge tunes RocksDB for dedicated machines with plenty of memory.
+	RocksDBPresetLarge = "large"
+	// RocksDBPresetWriteHeavy tunes RocksDB for a sustained high write load.
+	RocksDBPresetWriteHeavy = "write-heavy"
+
+	mib = uint64(1) << 20
+	gib = uint64(1) << 30
+
+	// rocksDBAutoLargeMemory is the minimum memory of a server for which the auto preset selects the large preset.
+	rocksDBAutoLargeMemory = 8 * gib
+)
+
+// rocksDBPreset holds the RocksDB settings of a preset, relative to the memory & free disk space of a server.
+type rocksDBPreset struct {
+	BlockCacheDivisor       uint64 // Block cache size is memory / BlockCacheDivisor
+	WriteBufferSize         uint64 // Size of a single memtable
+	MaxWriteBufferNumber    int    // Maximum number of memtables
+	TotalWriteBufferDivisor uint64 // Total size of all memtables is memory / TotalWriteBufferDivisor
+	PendingSlowdownDivisor  uint64 // Writes are slowed down when the pending compactions exceed disk / PendingSlowdownDivisor
+	PendingStopDivisor      uint64 // Writes are stopped when the pending compactions exceed disk / PendingStopDivisor
+	Level0SlowdownTrigger   int    // If set, number of level 0 files that slows down writes
+	Level0StopTrigger       int    // If set, number of level 0 files that stops writes
+}
+
+// rocksDBPresets holds all presets, except auto.
+var rocksDBPresets = map[string]rocksDBPreset{
+	RocksDBPresetSmall: {
+		BlockCacheDivisor:       8,
+		WriteBufferSize:         16 * mib,
+		MaxWriteBufferNumber:    2,
+		TotalWriteBufferDivisor: 16,
+		PendingSlowdownDivisor:  16,
+		PendingStopDivisor:      8,
+	},
+	RocksDBPresetLarge: {
+		BlockCacheDivisor:       3,
+		WriteBufferSize:         64 * mib,
+		MaxWriteBufferNumber:    4,
+		TotalWriteBufferDivisor: 8,
+		PendingSlowdownDivisor:  8,
+		PendingStopDivisor:      4,
+	},
+	RocksDBPresetWriteHeavy: {
+		BlockCacheDivisor:       4,
+		WriteBufferSize:         128 * mib,
+		MaxWriteBufferNumber:    8,
+		TotalWriteBufferDivisor: 4,
+		PendingSlowdownDivisor:  4,
+		PendingStopDivisor:      2,
+		Level0SlowdownTrigger:   40,
+		Level0StopTrigger:       64,
+	},
+}
+
+// ValidateRocksDBPreset returns an error if the given name is not a known RocksDB preset.
+// An empty name is valid, it does not tune RocksDB.
+func ValidateRocksDBPreset(name string) error {
+	if name == "" || name == RocksDBPresetAuto {
+		return nil
+	}
+	if _, found := rocksDBPresets[name]; !found {
+		return maskAny(fmt.Errorf("Unknown RocksDB preset '%s', expected auto, small, large or write-heavy", name))
+	}
+	return nil
+}
+
+// rocksDBPresetArgs returns the command line arguments that tune RocksDB for the server of given type,
+// according to --rocksdb.preset. Only servers that store data (dbservers & single servers) are tuned.
+// Settings derived from memory or disk space are left to arangod when these cannot be detected.
+func (s *Service) rocksDBPresetArgs(serverType ServerType) []string {
+	if s.RocksDBPreset == "" || s.ServerStorageEngine != "rocksdb" {
+		return nil
+	}
+	if serverType != ServerTypeDBServer && serverType != ServerTypeSingle {
+		return nil
+	}
+	memory := s.serverMemory(serverType)
+	name := s.RocksDBPreset
+	if name == RocksDBPresetAuto {
+		name = RocksDBPresetSmall
+		if memory >= rocksDBAutoLargeMemory {
+			name = RocksDBPresetLarge
+		}
+	}
+	preset, found := rocksDBPresets[name]
+	if !found {
+		return nil
+	}
+	logger := s.serverLogger(serverType)
+	args := []string{
+		"--rocksdb.write-buffer-size", strconv.FormatUint(preset.WriteBufferSize, 10),
+		"--rocksdb.max-write-buffer-number", strconv.Itoa(preset.MaxWriteBufferNumber),
+	}
+	if memory != 0 {
+		blockCache := maxUint64(memory/preset.BlockCacheDivisor, 64*mib) / mib * mib
+		totalWriteBuffer := maxUint64(memory/preset.TotalWriteBufferDivisor, preset.WriteBufferSize*uint64(preset.MaxWriteBufferNumber)) / mib * mib
+		args = append(args,
+			"--rocksdb.block-cache-size", strconv.FormatUint(blockCache, 10),
+			"--rocksdb.total-write-buffer-size", strconv.FormatUint(totalWriteBuffer, 10),
+		)
+	} else {
+		logger.Warningf("Memory of %s is unknown, RocksDB preset %s leaves the cache sizes to arangod (use --memory.total)", serverType, name)
+	}
+	if disk, err := s.serverDiskSpace(); err == nil {
+		slowdown := maxUint64(disk/preset.PendingSlowdownDivisor, gib) / gib * gib
+		stop := maxUint64(disk/preset.PendingStopDivisor, 2*slowdown) / gib * gib
+		args = append(args,
+			"--rocksdb.pending-compactions-bytes-slowdown-trigger", strconv.FormatUint(slowdown, 10),
+			"--rocksdb.pending-compactions-bytes-stop-trigger", strconv.FormatUint(stop, 10),
+		)
+	} else {
+		logger.Warningf("Cannot detect free disk space, RocksDB preset %s leaves the pending compaction limits to arangod: %v", name, err)
+	}
+	if preset.Level0SlowdownTrigger != 0 {
+		args = append(args, "--rocksdb.level0-slowdown-trigger", strconv.Itoa(preset.Level0SlowdownTrigger))
+	}
+	if preset.Level0StopTrigger != 0 {
+		args = append(args, "--rocksdb.level0-stop-trigger", strconv.Itoa(preset.Level0StopTrigger))
+	}
+	logger.Infof("Tuning RocksDB of %s using preset %s", serverType, name)
+	return args
+}
+
+// serverDiskSpace returns the free disk space available to the servers of this starter.
+// The free space of the data directory is divided amongst all local starters.
+func (s *Service) serverDiskSpace() (uint64, error) {
+	free, err := freeDiskSpace(s.DataDir)
+	if err != nil {
+		return 0, maskAny(err)
+	}
+	if (s.StartLocalSlaves || s.isLocalSlave) && s.localPeerCount() > 1 {
+		free /= uint64(s.localPeerCount())
+	}
+	return free, nil
+}
+
+// maxUint64 returns the largest of the given values.
+func maxUint64(a, b uint64) uint64 {
+	if a > b {
+		return a
+	}
+	return b
+}
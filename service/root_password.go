@@ -0,0 +1,46 @@
+//
+// DISCLAIMER
+//
+// Copyright 2017 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+// Author Ewout Prangsma
+//
+
+package service
+
+const (
+	// rootPasswordEnvVar is the environment variable arangod reads the password of the root user from,
+	// when it creates the root user while bootstrapping the `_system` database.
+	rootPasswordEnvVar = "ARANGODB_DEFAULT_ROOT_PASSWORD"
+)
+
+// bootstrapRootPassword returns the password of the root user to pass to the server of given type,
+// or an empty string if there is none.
+// The password is only passed to the servers that create the root user (coordinators & single servers)
+// while this run bootstraps a new deployment. Since arangod only uses it when it creates the root user,
+// a password changed later on is never overwritten, not even when such a server is restarted.
+func (s *Service) bootstrapRootPassword(serverType ServerType) string {
+	if s.RootPassword == "" || !s.bootstrapping {
+		return ""
+	}
+	switch serverType {
+	case ServerTypeCoordinator, ServerTypeSingle:
+		return s.RootPassword
+	default:
+		return ""
+	}
+}
@@ -22,10 +22,35 @@
 
 package service
 
+import "strings"
+
 type Volume struct {
-	HostPath      string
-	ContainerPath string
-	ReadOnly      bool
+	HostPath      string `json:"host-path"`
+	ContainerPath string `json:"container-path"`
+	ReadOnly      bool   `json:"read-only,omitempty"`
+}
+
+// Placement restricts the CPUs & NUMA nodes a server runs on.
+type Placement struct {
+	Numactl []string `json:"numactl,omitempty"` // Arguments of numactl to run the server with (process only)
+	Cpuset  string   `json:"cpuset,omitempty"`  // CPUs the server is restricted to (e.g. `0-7,16-23`)
+}
+
+// IsEmpty returns true when the placement does not restrict anything.
+func (p Placement) IsEmpty() bool {
+	return len(p.Numactl) == 0 && p.Cpuset == ""
+}
+
+// String returns a human readable description of the placement.
+func (p Placement) String() string {
+	var parts []string
+	if len(p.Numactl) > 0 {
+		parts = append(parts, "numactl "+strings.Join(p.Numactl, " "))
+	}
+	if p.Cpuset != "" {
+		parts = append(parts, "cpuset "+p.Cpuset)
+	}
+	return strings.Join(parts, ", ")
 }
 
 type Runner interface {
@@ -37,8 +62,15 @@ type Runner interface {
 	// Otherwise nil is returned.
 	GetRunningServer(serverDir string) (Process, error)
 
-	// Start a server with given arguments
-	Start(command string, args []string, volumes []Volume, ports []int, containerName, serverDir string) (Process, error)
+	// Start a server with given arguments.
+	// If coreDir is set, the server runs in that directory (in host namespace) with core dumps enabled.
+	// The server is restricted to the CPUs & NUMA nodes of the given placement.
+	// The given environment variables are set in addition to those of the starter (process) or image (docker).
+	Start(command string, args []string, volumes []Volume, ports []int, containerName, serverDir, coreDir string, placement Placement, env map[string]string) (Process, error)
+
+	// CommandLine returns the exact argv of a server started with given arguments (see Start).
+	// Runners that start servers in a container also return the equivalent `docker run` command line.
+	CommandLine(command string, args []string, volumes []Volume, ports []int, containerName, coreDir string, placement Placement, env map[string]string) (argv []string, dockerRun []string)
 
 	// Create a command that a user should use to start a slave arangodb instance.
 	CreateStartArangodbCommand(myDataDir string, index int, masterIP string, masterPort string) string
@@ -57,12 +89,17 @@ type Process interface {
 	// HostPort returns the port on the host that is used to access the given port of the process.
 	HostPort(containerPort int) (int, error)
 
-	// Wait until the process has terminated
-	Wait()
+	// Wait until the process has terminated.
+	// Returns the exit code of the process, or -1 if unknown.
+	Wait() int
 	// Terminate performs a graceful termination of the process
 	Terminate() error
 	// Kill performs a hard termination of the process
 	Kill() error
+	// ReopenLogs asks the process to reopen its log file (by sending it a SIGHUP)
+	ReopenLogs() error
+	// Usage returns the current resource usage of the process
+	Usage() (ProcessUsage, error)
 
 	// Remove all traces of this process
 	Cleanup() error
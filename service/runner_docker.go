@@ -28,6 +28,7 @@ import (
 	"net"
 	"os"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -46,7 +47,7 @@ const (
 )
 
 // NewDockerRunner creates a runner that starts processes in a docker container.
-func NewDockerRunner(log *logging.Logger, endpoint, image, user, volumesFrom string, gcDelay time.Duration, networkMode string, privileged bool) (Runner, error) {
+func NewDockerRunner(log *logging.Logger, endpoint, image, user, volumesFrom string, gcDelay time.Duration, networkMode, bindAddress string, privileged bool, logForward string, offline bool) (Runner, error) {
 	client, err := docker.NewClient(endpoint)
 	if err != nil {
 		return nil, maskAny(err)
@@ -60,7 +61,10 @@ func NewDockerRunner(log *logging.Logger, endpoint, image, user, volumesFrom str
 		containerIDs: make(map[string]time.Time),
 		gcDelay:      gcDelay,
 		networkMode:  networkMode,
+		bindAddress:  bindAddress,
 		privileged:   privileged,
+		logForward:   logForward,
+		offline:      offline,
 	}, nil
 }
 
@@ -72,11 +76,38 @@ type dockerRunner struct {
 	user         string
 	volumesFrom  string
 	mutex        sync.Mutex
+	pullMutex    sync.Mutex // Serializes pulling the image by servers that are started at the same time
 	containerIDs map[string]time.Time
 	gcOnce       sync.Once
 	gcDelay      time.Duration
 	networkMode  string
+	bindAddress  string
 	privileged   bool
+	logForward   string
+	offline      bool // If set, the image is never pulled
+}
+
+// dockerImageRunner is a dockerRunner that starts servers using another image than its docker runner.
+type dockerImageRunner struct {
+	*dockerRunner
+	image string
+}
+
+// WithImage returns a runner that starts servers using the given image,
+// sharing everything else (containers, GC) with this runner.
+func (r *dockerRunner) WithImage(image string) Runner {
+	return &dockerImageRunner{dockerRunner: r, image: image}
+}
+
+// Start a server with given arguments in a container of the image of this runner.
+func (r *dockerImageRunner) Start(command string, args []string, volumes []Volume, ports []int, containerName, serverDir, coreDir string, placement Placement, env map[string]string) (Process, error) {
+	return r.startImage(r.image, command, args, volumes, ports, containerName, serverDir, coreDir, placement, env)
+}
+
+// CommandLine returns the argv of a server started in a container of the image of this runner,
+// together with the equivalent `docker run` command line.
+func (r *dockerImageRunner) CommandLine(command string, args []string, volumes []Volume, ports []int, containerName, coreDir string, placement Placement, env map[string]string) ([]string, []string) {
+	return r.commandLine(r.image, command, args, volumes, ports, containerName, coreDir, placement, env)
 }
 
 type dockerContainer struct {
@@ -124,12 +155,17 @@ func (r *dockerRunner) GetRunningServer(serverDir string) (Process, error) {
 	}, nil
 }
 
-func (r *dockerRunner) Start(command string, args []string, volumes []Volume, ports []int, containerName, serverDir string) (Process, error) {
+func (r *dockerRunner) Start(command string, args []string, volumes []Volume, ports []int, containerName, serverDir, coreDir string, placement Placement, env map[string]string) (Process, error) {
+	return r.startImage(r.image, command, args, volumes, ports, containerName, serverDir, coreDir, placement, env)
+}
+
+// startImage starts a server with given arguments in a container of the given image.
+func (r *dockerRunner) startImage(image, command string, args []string, volumes []Volume, ports []int, containerName, serverDir, coreDir string, placement Placement, env map[string]string) (Process, error) {
 	// Start gc (once)
 	r.startGC()
 
 	// Pull docker image
-	if err := r.pullImage(r.image); err != nil {
+	if err := r.pullImage(image); err != nil {
 		return nil, maskAny(err)
 	}
 
@@ -147,7 +183,7 @@ func (r *dockerRunner) Start(command string, args []string, volumes []Volume, po
 			r.log.Errorf("Failed to remove container '%s': %v", containerName, err)
 		}
 		// Try starting it now
-		p, err := r.start(command, args, volumes, ports, containerName, serverDir)
+		p, err := r.start(image, command, args, volumes, ports, containerName, serverDir, coreDir, placement, env)
 		if err != nil {
 			return maskAny(err)
 		}
@@ -161,18 +197,56 @@ func (r *dockerRunner) Start(command string, args []string, volumes []Volume, po
 	return result, nil
 }
 
+// captureOutput writes the stdout & stderr of the container with given ID into
+// new output files in the given directory, until the container terminates.
+func (r *dockerRunner) captureOutput(containerID, serverDir string) {
+	stdout, stderr, err := createOutputFiles(serverDir)
+	if err != nil {
+		r.log.Warningf("Cannot capture output of container %s: %v", containerID, err)
+		return
+	}
+	go func() {
+		defer stdout.Close()
+		defer stderr.Close()
+		if err := r.client.Logs(docker.LogsOptions{
+			Container:    containerID,
+			OutputStream: stdout,
+			ErrorStream:  stderr,
+			Follow:       true,
+			Stdout:       true,
+			Stderr:       true,
+		}); err != nil {
+			r.log.Debugf("Stopped capturing output of container %s: %v", containerID, err)
+		}
+	}()
+}
+
 // startGC ensures GC is started (only once)
 func (r *dockerRunner) startGC() {
 	// Start gc (once)
 	r.gcOnce.Do(func() { go r.gc() })
 }
 
-// Try to start a command with given arguments
-func (r *dockerRunner) start(command string, args []string, volumes []Volume, ports []int, containerName, serverDir string) (Process, error) {
+// CommandLine returns the argv of a server started in a container with given arguments (see Start),
+// together with the equivalent `docker run` command line.
+func (r *dockerRunner) CommandLine(command string, args []string, volumes []Volume, ports []int, containerName, coreDir string, placement Placement, env map[string]string) ([]string, []string) {
+	return r.commandLine(r.image, command, args, volumes, ports, containerName, coreDir, placement, env)
+}
+
+// commandLine returns the argv of a server started in a container of the given image with given arguments,
+// together with the equivalent `docker run` command line.
+func (r *dockerRunner) commandLine(image, command string, args []string, volumes []Volume, ports []int, containerName, coreDir string, placement Placement, env map[string]string) ([]string, []string) {
+	containerName = strings.Replace(containerName, ":", "", -1)
+	opts := r.createContainerOptions(image, command, args, volumes, ports, containerName, coreDir, placement, env)
+	return append([]string{command}, args...), dockerRunCommand(opts)
+}
+
+// createContainerOptions returns the options used to create a container for a server with given arguments.
+func (r *dockerRunner) createContainerOptions(image, command string, args []string, volumes []Volume, ports []int, containerName, coreDir string, placement Placement, env map[string]string) docker.CreateContainerOptions {
 	opts := docker.CreateContainerOptions{
 		Name: containerName,
 		Config: &docker.Config{
-			Image:        r.image,
+			Image:        image,
 			Entrypoint:   []string{command},
 			Cmd:          args,
 			Tty:          true,
@@ -208,12 +282,38 @@ func (r *dockerRunner) start(command string, args []string, volumes []Volume, po
 			opts.Config.ExposedPorts[dockerPort] = struct{}{}
 			opts.HostConfig.PortBindings[dockerPort] = []docker.PortBinding{
 				docker.PortBinding{
-					HostIP:   "0.0.0.0",
+					HostIP:   r.bindAddress, // Empty means all interfaces, IPv4 & IPv6
 					HostPort: strconv.Itoa(p),
 				},
 			}
 		}
 	}
+	if coreDir != "" {
+		// Run in the core directory, so core dumps end up on the host
+		containerCoreDir := coreDir
+		if r.volumesFrom == "" {
+			containerCoreDir = "/cores"
+			opts.HostConfig.Binds = append(opts.HostConfig.Binds, fmt.Sprintf("%s:%s", coreDir, containerCoreDir))
+		}
+		opts.Config.WorkingDir = containerCoreDir
+		opts.HostConfig.Ulimits = []docker.ULimit{docker.ULimit{Name: "core", Soft: -1, Hard: -1}}
+	}
+	for k, v := range env {
+		opts.Config.Env = append(opts.Config.Env, k+"="+v)
+	}
+	if placement.Cpuset != "" {
+		// Restrict the container to the given CPUs (cgroup cpuset)
+		opts.HostConfig.CPUSetCPUs = placement.Cpuset
+	}
+	if logConfig, ok := dockerLogConfig(r.logForward, containerName); ok {
+		opts.HostConfig.LogConfig = logConfig
+	}
+	return opts
+}
+
+// Try to start a command with given arguments
+func (r *dockerRunner) start(image, command string, args []string, volumes []Volume, ports []int, containerName, serverDir, coreDir string, placement Placement, env map[string]string) (Process, error) {
+	opts := r.createContainerOptions(image, command, args, volumes, ports, containerName, coreDir, placement, env)
 	r.log.Debugf("Creating container %s", containerName)
 	c, err := r.client.CreateContainer(opts)
 	if err != nil {
@@ -225,6 +325,9 @@ func (r *dockerRunner) start(command string, args []string, volumes []Volume, po
 		return nil, maskAny(err)
 	}
 	r.log.Debugf("Started container %s", containerName)
+	if serverDir != "" {
+		r.captureOutput(c.ID, serverDir)
+	}
 	// Write container ID to disk
 	containerFilePath := filepath.Join(serverDir, containerFileName)
 	if err := ioutil.WriteFile(containerFilePath, []byte(c.ID), 0755); err != nil {
@@ -243,9 +346,22 @@ func (r *dockerRunner) start(command string, args []string, volumes []Volume, po
 
 // pullImage tries to pull the given image.
 // It retries several times upon failure.
+// In offline mode, it only checks that the image is available locally.
 func (r *dockerRunner) pullImage(image string) error {
+	r.pullMutex.Lock()
+	defer r.pullMutex.Unlock()
+
+	if r.offline {
+		if _, err := r.client.InspectImage(image); err == docker.ErrNoSuchImage {
+			return maskAny(OfflineImageError(image))
+		} else if err != nil {
+			return maskAny(err)
+		}
+		return nil
+	}
+
 	// Pull docker image
-	repo, tag := docker.ParseRepositoryTag(r.image)
+	repo, tag := docker.ParseRepositoryTag(image)
 
 	op := func() error {
 		r.log.Debugf("Pulling image %s:%s", repo, tag)
@@ -422,8 +538,12 @@ func (p *dockerContainer) HostPort(containerPort int) (int, error) {
 	return 0, fmt.Errorf("Cannot find port mapping.")
 }
 
-func (p *dockerContainer) Wait() {
-	p.client.WaitContainer(p.container.ID)
+func (p *dockerContainer) Wait() int {
+	exitCode, err := p.client.WaitContainer(p.container.ID)
+	if err != nil {
+		return -1
+	}
+	return exitCode
 }
 
 func (p *dockerContainer) Terminate() error {
@@ -442,6 +562,36 @@ func (p *dockerContainer) Kill() error {
 	return nil
 }
 
+func (p *dockerContainer) ReopenLogs() error {
+	if err := p.client.KillContainer(docker.KillContainerOptions{
+		ID:     p.container.ID,
+		Signal: docker.SIGHUP,
+	}); err != nil {
+		return maskAny(err)
+	}
+	return nil
+}
+
+func (p *dockerContainer) Usage() (ProcessUsage, error) {
+	statsChan := make(chan *docker.Stats, 1)
+	if err := p.client.Stats(docker.StatsOptions{
+		ID:      p.container.ID,
+		Stats:   statsChan,
+		Stream:  false,
+		Timeout: time.Second * 10,
+	}); err != nil {
+		return ProcessUsage{}, maskAny(err)
+	}
+	stats, ok := <-statsChan
+	if !ok || stats == nil {
+		return ProcessUsage{}, maskAny(fmt.Errorf("No stats received for container %s", p.container.ID))
+	}
+	return ProcessUsage{
+		CPUTime: time.Duration(stats.CPUStats.CPUUsage.TotalUsage),
+		RSS:     stats.MemoryStats.Usage,
+	}, nil
+}
+
 func (p *dockerContainer) Cleanup() error {
 	opts := docker.RemoveContainerOptions{
 		ID:            p.container.ID,
@@ -472,3 +622,91 @@ func isNotFound(err error) bool {
 	}
 	return false
 }
+
+// dockerLogConfig returns the configuration of the log driver that forwards the output
+// of a container to the given log forwarding target (syslog|journald).
+// The name of the container (which contains the server type & peer ID) is used as tag.
+// TCP targets are not handled by a log driver, see usesDockerLogDriver.
+func dockerLogConfig(logForward, containerName string) (docker.LogConfig, bool) {
+	switch {
+	case logForward == LogForwardSyslog:
+		return docker.LogConfig{Type: "syslog", Config: map[string]string{"tag": containerName}}, true
+	case logForward == LogForwardJournald:
+		return docker.LogConfig{Type: "journald", Config: map[string]string{"tag": containerName}}, true
+	default:
+		return docker.LogConfig{}, false
+	}
+}
+
+// dockerRunCommand returns the `docker run` command line that creates & starts a container
+// with given options.
+func dockerRunCommand(opts docker.CreateContainerOptions) []string {
+	cfg, hostCfg := opts.Config, opts.HostConfig
+	cmd := []string{"docker", "run", "-d", "--name=" + opts.Name}
+	if cfg.Tty {
+		cmd = append(cmd, "-t")
+	}
+	if cfg.User != "" {
+		cmd = append(cmd, "--user="+cfg.User)
+	}
+	if hostCfg.Privileged {
+		cmd = append(cmd, "--privileged")
+	}
+	labels := make([]string, 0, len(cfg.Labels))
+	for k, v := range cfg.Labels {
+		labels = append(labels, "--label="+k+"="+v)
+	}
+	sort.Strings(labels)
+	cmd = append(cmd, labels...)
+	for _, v := range hostCfg.VolumesFrom {
+		cmd = append(cmd, "--volumes-from="+v)
+	}
+	for _, b := range hostCfg.Binds {
+		cmd = append(cmd, "-v", b)
+	}
+	if hostCfg.NetworkMode != "" {
+		cmd = append(cmd, "--net="+hostCfg.NetworkMode)
+	}
+	var publish []string
+	for port, bindings := range hostCfg.PortBindings {
+		for _, b := range bindings {
+			hostPort := b.HostPort
+			if b.HostIP != "" {
+				hostPort = net.JoinHostPort(b.HostIP, b.HostPort)
+			}
+			publish = append(publish, hostPort+":"+string(port))
+		}
+	}
+	sort.Strings(publish)
+	for _, p := range publish {
+		cmd = append(cmd, "-p", p)
+	}
+	if cfg.WorkingDir != "" {
+		cmd = append(cmd, "-w", cfg.WorkingDir)
+	}
+	for _, u := range hostCfg.Ulimits {
+		cmd = append(cmd, fmt.Sprintf("--ulimit=%s=%d:%d", u.Name, u.Soft, u.Hard))
+	}
+	env := append([]string(nil), cfg.Env...)
+	sort.Strings(env)
+	for _, e := range env {
+		cmd = append(cmd, "-e", e)
+	}
+	if hostCfg.CPUSetCPUs != "" {
+		cmd = append(cmd, "--cpuset-cpus="+hostCfg.CPUSetCPUs)
+	}
+	if hostCfg.LogConfig.Type != "" {
+		cmd = append(cmd, "--log-driver="+hostCfg.LogConfig.Type)
+		logOpts := make([]string, 0, len(hostCfg.LogConfig.Config))
+		for k, v := range hostCfg.LogConfig.Config {
+			logOpts = append(logOpts, "--log-opt="+k+"="+v)
+		}
+		sort.Strings(logOpts)
+		cmd = append(cmd, logOpts...)
+	}
+	if len(cfg.Entrypoint) > 0 {
+		cmd = append(cmd, "--entrypoint="+cfg.Entrypoint[0])
+	}
+	cmd = append(cmd, cfg.Image)
+	return append(cmd, cfg.Cmd...)
+}
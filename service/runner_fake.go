@@ -0,0 +1,244 @@
+//
+// DISCLAIMER
+//
+// Copyright 2017 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+// Author Ewout Prangsma
+//
+
+package service
+
+import (
+	"fmt"
+	"net"
+	"sync"
+)
+
+const (
+	fakeProcessFirstPID = 10000 // PID of the first process started by a FakeRunner
+)
+
+// NewFakeRunner creates a runner that records the servers it is asked to start, without launching anything.
+// Use it (see WithRunner) to test orchestration logic without docker or arangod installed.
+// Note that the recorded servers never respond to requests, so they are never reported as up.
+func NewFakeRunner() *FakeRunner {
+	return &FakeRunner{}
+}
+
+// FakeRunner implements a Runner that records started servers as FakeProcess's.
+type FakeRunner struct {
+	mutex     sync.Mutex
+	processes []*FakeProcess
+}
+
+// FakeProcess is a server "started" by a FakeRunner.
+// It keeps running until it is terminated, killed or exits (see Exit).
+type FakeProcess struct {
+	Command       string            // Command the server was started with
+	Args          []string          // Arguments of the command
+	Volumes       []Volume          // Volumes given to Start
+	Ports         []int             // Ports given to Start
+	ContainerName string            // Container name given to Start
+	ServerDir     string            // Directory of the server
+	CoreDir       string            // Directory core dumps are written to (if any)
+	Placement     Placement         // CPUs & NUMA nodes the server is restricted to
+	Env           map[string]string // Additional environment variables
+
+	pid        int
+	mutex      sync.Mutex
+	done       chan struct{}
+	exitCode   int
+	logReopens int
+	terminated bool
+	killed     bool
+}
+
+// Processes returns all processes started by the runner, in order of starting.
+func (r *FakeRunner) Processes() []*FakeProcess {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return append([]*FakeProcess(nil), r.processes...)
+}
+
+// GetContainerDir returns the given host directory, the runner uses no containers.
+func (r *FakeRunner) GetContainerDir(hostDir string) string {
+	return hostDir
+}
+
+// GetRunningServer returns the most recently started process in the given server directory,
+// if it is still running.
+func (r *FakeRunner) GetRunningServer(serverDir string) (Process, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	for i := len(r.processes) - 1; i >= 0; i-- {
+		if p := r.processes[i]; p.ServerDir == serverDir {
+			if p.IsRunning() {
+				return p, nil
+			}
+			return nil, nil
+		}
+	}
+	return nil, nil
+}
+
+// Start records a server with given arguments.
+func (r *FakeRunner) Start(command string, args []string, volumes []Volume, ports []int, containerName, serverDir, coreDir string, placement Placement, env map[string]string) (Process, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	p := &FakeProcess{
+		Command:       command,
+		Args:          append([]string(nil), args...),
+		Volumes:       volumes,
+		Ports:         ports,
+		ContainerName: containerName,
+		ServerDir:     serverDir,
+		CoreDir:       coreDir,
+		Placement:     placement,
+		Env:           env,
+		pid:           fakeProcessFirstPID + len(r.processes),
+		done:          make(chan struct{}),
+	}
+	r.processes = append(r.processes, p)
+	return p, nil
+}
+
+// CommandLine returns the given command & arguments.
+func (r *FakeRunner) CommandLine(command string, args []string, volumes []Volume, ports []int, containerName, coreDir string, placement Placement, env map[string]string) ([]string, []string) {
+	return append([]string{command}, args...), nil
+}
+
+// CreateStartArangodbCommand returns the command a user would use to start a slave.
+func (r *FakeRunner) CreateStartArangodbCommand(myDataDir string, index int, masterIP string, masterPort string) string {
+	addr := masterIP
+	if masterPort != "" {
+		addr = net.JoinHostPort(addr, masterPort)
+	}
+	return fmt.Sprintf("arangodb --starter.join %s", addr)
+}
+
+// Cleanup does nothing.
+func (r *FakeRunner) Cleanup() error {
+	return nil
+}
+
+// Exit lets the process terminate with given exit code (e.g. to simulate a crash).
+// It has no effect when the process has already terminated.
+func (p *FakeProcess) Exit(exitCode int) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	select {
+	case <-p.done:
+	default:
+		p.exitCode = exitCode
+		close(p.done)
+	}
+}
+
+// IsRunning returns true if the process has not yet terminated.
+func (p *FakeProcess) IsRunning() bool {
+	select {
+	case <-p.done:
+		return false
+	default:
+		return true
+	}
+}
+
+// WasTerminated returns true if the process has been terminated gracefully (see Terminate).
+func (p *FakeProcess) WasTerminated() bool {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.terminated
+}
+
+// WasKilled returns true if the process has been killed (see Kill).
+func (p *FakeProcess) WasKilled() bool {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.killed
+}
+
+// LogReopens returns the number of times the process has been asked to reopen its log file.
+func (p *FakeProcess) LogReopens() int {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.logReopens
+}
+
+// ProcessID returns the fake pid of the process.
+func (p *FakeProcess) ProcessID() int {
+	return p.pid
+}
+
+// ContainerID returns an empty string, the process does not run in a container.
+func (p *FakeProcess) ContainerID() string {
+	return ""
+}
+
+// ContainerIP returns an empty string, the process does not run in a container.
+func (p *FakeProcess) ContainerIP() string {
+	return ""
+}
+
+// HostPort returns the given port, ports are not mapped.
+func (p *FakeProcess) HostPort(containerPort int) (int, error) {
+	return containerPort, nil
+}
+
+// Wait until the process has terminated.
+func (p *FakeProcess) Wait() int {
+	<-p.done
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.exitCode
+}
+
+// Terminate lets the process terminate with exit code 0.
+func (p *FakeProcess) Terminate() error {
+	p.mutex.Lock()
+	p.terminated = true
+	p.mutex.Unlock()
+	p.Exit(0)
+	return nil
+}
+
+// Kill lets the process terminate with exit code -1.
+func (p *FakeProcess) Kill() error {
+	p.mutex.Lock()
+	p.killed = true
+	p.mutex.Unlock()
+	p.Exit(-1)
+	return nil
+}
+
+// ReopenLogs records a request to reopen the log file.
+func (p *FakeProcess) ReopenLogs() error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.logReopens++
+	return nil
+}
+
+// Usage returns an empty resource usage.
+func (p *FakeProcess) Usage() (ProcessUsage, error) {
+	return ProcessUsage{}, nil
+}
+
+// Cleanup does nothing.
+func (p *FakeProcess) Cleanup() error {
+	return nil
+}
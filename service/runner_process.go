@@ -31,12 +31,29 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
 
 	logging "github.com/op/go-logging"
 )
 
+// managedProcesses holds all child processes started by process runners.
+// These are waited for by their runner, so they are never reaped as orphans.
+var managedProcesses = struct {
+	mutex     sync.Mutex
+	processes map[int]*os.Process
+}{processes: make(map[int]*os.Process)}
+
+// SignalManagedProcesses sends the given signal to all child processes started by process runners.
+func SignalManagedProcesses(sig os.Signal) {
+	managedProcesses.mutex.Lock()
+	defer managedProcesses.mutex.Unlock()
+	for _, p := range managedProcesses.processes {
+		p.Signal(sig)
+	}
+}
+
 // NewProcessRunner creates a runner that starts processes on the local OS.
 func NewProcessRunner(log *logging.Logger) Runner {
 	return &processRunner{
@@ -90,14 +107,62 @@ func (r *processRunner) GetRunningServer(serverDir string) (Process, error) {
 	return &process{log: r.log, p: p, isChild: false}, nil
 }
 
-func (r *processRunner) Start(command string, args []string, volumes []Volume, ports []int, containerName, serverDir string) (Process, error) {
+func (r *processRunner) Start(command string, args []string, volumes []Volume, ports []int, containerName, serverDir, coreDir string, placement Placement, env map[string]string) (Process, error) {
+	command, args = wrapCommand(command, args, placement)
 	c := exec.Command(command, args...)
+	if len(env) > 0 {
+		c.Env = os.Environ()
+		for k, v := range env {
+			c.Env = append(c.Env, k+"="+v)
+		}
+	}
+	c.Dir = coreDir
+	if serverDir != "" {
+		// Capture stdout & stderr, so output written before the log file is opened is not lost
+		stdout, stderr, err := createOutputFiles(serverDir)
+		if err != nil {
+			r.log.Warningf("Cannot capture output of %s: %v", command, err)
+		} else {
+			defer stdout.Close()
+			defer stderr.Close()
+			c.Stdout = stdout
+			c.Stderr = stderr
+		}
+	}
+	managedProcesses.mutex.Lock()
+	defer managedProcesses.mutex.Unlock()
 	if err := c.Start(); err != nil {
 		return nil, maskAny(err)
 	}
+	managedProcesses.processes[c.Process.Pid] = c.Process
 	return &process{log: r.log, p: c.Process, isChild: true}, nil
 }
 
+// CommandLine returns the argv of a process started with given arguments (see Start).
+func (r *processRunner) CommandLine(command string, args []string, volumes []Volume, ports []int, containerName, coreDir string, placement Placement, env map[string]string) ([]string, []string) {
+	command, args = wrapCommand(command, args, placement)
+	return append([]string{command}, args...), nil
+}
+
+// wrapCommand prefixes the given command with numactl or taskset to apply the given placement.
+func wrapCommand(command string, args []string, placement Placement) (string, []string) {
+	var wrapper []string
+	switch {
+	case len(placement.Numactl) > 0:
+		wrapper = append([]string{"numactl"}, placement.Numactl...)
+		if placement.Cpuset != "" {
+			wrapper = append(wrapper, "--physcpubind="+placement.Cpuset)
+		}
+		wrapper = append(wrapper, "--")
+	case placement.Cpuset != "":
+		wrapper = []string{"taskset", "-c", placement.Cpuset}
+	default:
+		return command, args
+	}
+	wrapped := append(append(wrapper[1:], command), args...)
+	return wrapper[0], wrapped
+}
+
 func (r *processRunner) CreateStartArangodbCommand(myDataDir string, index int, masterIP string, masterPort string) string {
 	if masterIP == "" {
 		masterIP = "127.0.0.1"
@@ -145,12 +210,20 @@ func (p *process) HostPort(containerPort int) (int, error) {
 	return containerPort, nil
 }
 
-func (p *process) Wait() {
+func (p *process) Wait() int {
 	if proc := p.p; proc != nil {
 		p.log.Debugf("Waiting on %d", proc.Pid)
 		if p.isChild {
-			_, err := proc.Wait()
+			ps, err := proc.Wait()
 			p.log.Debugf("Wait on %d returned %v\n", proc.Pid, err)
+			managedProcesses.mutex.Lock()
+			delete(managedProcesses.processes, proc.Pid)
+			managedProcesses.mutex.Unlock()
+			if err == nil {
+				if status, ok := ps.Sys().(syscall.WaitStatus); ok {
+					return status.ExitStatus()
+				}
+			}
 		} else {
 			// Cannot wait on non-child process, so let's do it the hard way
 			for {
@@ -163,6 +236,7 @@ func (p *process) Wait() {
 			}
 		}
 	}
+	return -1
 }
 
 func (p *process) Terminate() error {
@@ -178,6 +252,26 @@ func (p *process) Terminate() error {
 	return nil
 }
 
+func (p *process) ReopenLogs() error {
+	if proc := p.p; proc != nil {
+		if err := proc.Signal(syscall.SIGHUP); err != nil {
+			return maskAny(err)
+		}
+	}
+	return nil
+}
+
+func (p *process) Usage() (ProcessUsage, error) {
+	if proc := p.p; proc != nil {
+		usage, err := processUsage(proc.Pid)
+		if err != nil {
+			return ProcessUsage{}, maskAny(err)
+		}
+		return usage, nil
+	}
+	return ProcessUsage{}, maskAny(fmt.Errorf("Process not started"))
+}
+
 func (p *process) Kill() error {
 	if proc := p.p; proc != nil {
 		if err := proc.Kill(); err != nil {
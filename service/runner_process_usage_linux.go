@@ -0,0 +1,77 @@
+//
+// DISCLAIMER
+//
+// Copyright 2017 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+// Author Ewout Prangsma
+//
+
+package service
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	clockTicksPerSecond = 100 // USER_HZ, which is 100 on all supported architectures
+)
+
+// processUsage reads the resource usage of the process with given PID from /proc.
+func processUsage(pid int) (ProcessUsage, error) {
+	procDir := fmt.Sprintf("/proc/%d", pid)
+
+	// CPU time (utime & stime are field 14 & 15 of stat, counting after the command name)
+	stat, err := ioutil.ReadFile(procDir + "/stat")
+	if err != nil {
+		return ProcessUsage{}, maskAny(err)
+	}
+	fields := strings.Fields(string(stat[strings.LastIndex(string(stat), ")")+1:]))
+	if len(fields) < 13 {
+		return ProcessUsage{}, maskAny(fmt.Errorf("Unexpected content of %s/stat", procDir))
+	}
+	utime, _ := strconv.ParseInt(fields[11], 10, 64)
+	stime, _ := strconv.ParseInt(fields[12], 10, 64)
+	usage := ProcessUsage{
+		CPUTime: time.Duration(utime+stime) * time.Second / clockTicksPerSecond,
+	}
+
+	// Resident set size
+	if f, err := os.Open(procDir + "/status"); err == nil {
+		defer f.Close()
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			if line := scanner.Text(); strings.HasPrefix(line, "VmRSS:") {
+				if parts := strings.Fields(line); len(parts) >= 2 {
+					kb, _ := strconv.ParseUint(parts[1], 10, 64)
+					usage.RSS = kb * 1024
+				}
+			}
+		}
+	}
+
+	// Open file descriptors
+	if fds, err := ioutil.ReadDir(procDir + "/fd"); err == nil {
+		usage.OpenFiles = len(fds)
+	}
+	return usage, nil
+}
@@ -0,0 +1,33 @@
+//
+// DISCLAIMER
+//
+// Copyright 2017 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+// Author Ewout Prangsma
+//
+
+//go:build !linux
+// +build !linux
+
+package service
+
+import "fmt"
+
+// processUsage returns an error, since the resource usage of processes is only available on Linux.
+func processUsage(pid int) (ProcessUsage, error) {
+	return ProcessUsage{}, maskAny(fmt.Errorf("Resource usage is not supported on this platform"))
+}
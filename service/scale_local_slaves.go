@@ -0,0 +1,353 @@
+//
+// DISCLAIMER
+//
+// Copyright 2017 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+// Author Ewout Prangsma
+//
+
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	errScalingRunning = errors.New("Local slaves are already being scaled")
+)
+
+// LocalSlavesRequest is the JSON body of a POST `/local-slaves` request.
+type LocalSlavesRequest struct {
+	Count int `json:"count"` // Number of local slaves to run (besides the starter itself)
+}
+
+// LocalSlavesStatus is the JSON response of a `/local-slaves` request.
+type LocalSlavesStatus struct {
+	Running       bool   `json:"running,omitempty"`        // If set, local slaves are being added or retired
+	Ready         bool   `json:"ready,omitempty"`          // If set, the local slaves have been scaled successfully
+	Failed        bool   `json:"failed,omitempty"`         // If set, scaling the local slaves has failed
+	Reason        string `json:"reason,omitempty"`         // Reason of the failure (if any)
+	Count         int    `json:"count"`                    // Number of local slaves that are currently running
+	Target        int    `json:"target,omitempty"`         // Number of local slaves requested
+	Retiring      string `json:"retiring,omitempty"`       // ID of the local slave that is being retired
+	PendingShards int    `json:"pending-shards,omitempty"` // Number of shards that still have to be moved off the retiring local slave
+}
+
+// slaveScalingManager holds the state of scaling the local slaves of a starter.
+type slaveScalingManager struct {
+	mutex  sync.Mutex
+	status LocalSlavesStatus
+}
+
+// getStatus returns a copy of the current status.
+func (m *slaveScalingManager) getStatus() LocalSlavesStatus {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.status
+}
+
+// update calls the given function with exclusive access to the status.
+func (m *slaveScalingManager) update(f func(status *LocalSlavesStatus)) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	f(&m.status)
+}
+
+// localSlavesHandler changes the number of local slaves (POST) or returns the status of that change (GET).
+// This request is only handled by a starter started with `--starter.local`.
+func (s *Service) localSlavesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" && r.Method != "GET" {
+		writeError(w, http.StatusMethodNotAllowed, "GET or POST required")
+		return
+	}
+	if !s.StartLocalSlaves || s.isLocalSlave {
+		writeError(w, http.StatusPreconditionFailed, "Local slaves can only be scaled by a starter started with --starter.local")
+		return
+	}
+	if r.Method == "POST" {
+		var req LocalSlavesRequest
+		if !readJSONBody(w, r, &req, false) {
+			return
+		}
+		if err := s.startScaleLocalSlaves(req); errors.Cause(err) == errScalingRunning {
+			writeError(w, http.StatusConflict, err.Error())
+			return
+		} else if err != nil {
+			writeError(w, http.StatusPreconditionFailed, err.Error())
+			return
+		}
+	}
+	status := s.slaveScaling.getStatus()
+	s.mutex.Lock()
+	status.Count = len(s.localSlaves)
+	s.mutex.Unlock()
+	b, err := json.Marshal(status)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+	} else {
+		w.Write(b)
+	}
+}
+
+// startScaleLocalSlaves checks the given request and starts additional local slaves,
+// or starts retiring local slaves in the background.
+func (s *Service) startScaleLocalSlaves(req LocalSlavesRequest) error {
+	if req.Count < 0 {
+		return maskAny(fmt.Errorf("Count cannot be negative"))
+	}
+	s.mutex.Lock()
+	running := s.state == stateRunning
+	slaves := append([]*Service{}, s.localSlaves...)
+	peerList := s.myPeers
+	s.mutex.Unlock()
+	if !running {
+		return maskAny(fmt.Errorf("Local slaves can only be scaled once the cluster is running"))
+	}
+
+	// Select the local slaves to retire (the most recently added ones without an agent)
+	var retirees []*Service
+	if req.Count < len(slaves) {
+		for i := len(slaves) - 1; i >= 0 && len(slaves)-len(retirees) > req.Count; i-- {
+			if p, found := peerList.PeerByID(slaves[i].ID); found && p.HasAgent {
+				continue
+			}
+			retirees = append(retirees, slaves[i])
+		}
+		if len(slaves)-len(retirees) > req.Count {
+			return maskAny(fmt.Errorf("Local slaves that run an agent cannot be retired, at least %d local slaves are needed", len(slaves)-len(retirees)))
+		}
+	}
+
+	var err error
+	s.slaveScaling.update(func(status *LocalSlavesStatus) {
+		if status.Running {
+			err = maskAny(errScalingRunning)
+			return
+		}
+		*status = LocalSlavesStatus{
+			Running: true,
+			Target:  req.Count,
+		}
+	})
+	if err != nil {
+		return maskAny(err)
+	}
+
+	if req.Count > len(slaves) {
+		s.addLocalSlaves(req.Count-len(slaves), peerList)
+		s.slaveScaling.update(func(status *LocalSlavesStatus) {
+			status.Running = false
+			status.Ready = true
+		})
+	} else {
+		go s.runRetireLocalSlaves(retirees)
+	}
+	return nil
+}
+
+// addLocalSlaves creates peers for the given number of additional local slaves and starts services for them.
+// The new local slaves join the running cluster like any other slave.
+func (s *Service) addLocalSlaves(count int, peerList peers) {
+	usedDirs := make(map[string]bool)
+	for _, p := range peerList.Peers {
+		usedDirs[filepath.Clean(p.DataDir)] = true
+	}
+	var newPeers []Peer
+	for index := 1; len(newPeers) < count; index++ {
+		dataDir := filepath.Join(s.DataDir, fmt.Sprintf("local-slave-%d", index))
+		if usedDirs[dataDir] {
+			continue
+		}
+		if _, err := os.Stat(dataDir); err == nil {
+			// Left over from an earlier local slave
+			continue
+		}
+		id, err := createUniqueID()
+		if err != nil {
+			s.log.Errorf("Failed to create unique ID: %#v", err)
+			return
+		}
+		newPeers = append(newPeers, Peer{ID: id, DataDir: dataDir})
+	}
+	// Local slaves stop together with this starter, there is no need to wait for them
+	var wg sync.WaitGroup
+	s.startLocalSlaves(&wg, newPeers)
+}
+
+// runRetireLocalSlaves retires the given local slaves one after the other.
+// The shards of their dbservers are first moved to other dbservers, after which the local slaves
+// leave the cluster, stop their servers and remove their data.
+func (s *Service) runRetireLocalSlaves(retirees []*Service) {
+	for _, slave := range retirees {
+		if err := s.retireLocalSlave(slave); err != nil {
+			s.log.Errorf("Failed to retire local slave '%s': %v", slave.ID, err)
+			s.slaveScaling.update(func(status *LocalSlavesStatus) {
+				status.Running = false
+				status.Failed = true
+				status.Reason = err.Error()
+			})
+			return
+		}
+	}
+	s.slaveScaling.update(func(status *LocalSlavesStatus) {
+		status.Running = false
+		status.Ready = true
+		status.Retiring = ""
+		status.PendingShards = 0
+	})
+}
+
+// retireLocalSlave moves the data off the dbserver of the given local slave,
+// removes it from the peers and stops it.
+func (s *Service) retireLocalSlave(slave *Service) error {
+	s.log.Infof("Retiring local slave '%s'", slave.ID)
+	s.slaveScaling.update(func(status *LocalSlavesStatus) {
+		status.Retiring = slave.ID
+		status.PendingShards = 0
+	})
+	s.mutex.Lock()
+	peer, found := s.myPeers.PeerByID(slave.ID)
+	s.mutex.Unlock()
+
+	// Move all shards off its dbserver
+	var serverIDs []string
+	if found && peer.HasServers() {
+		for _, serverType := range []ServerType{ServerTypeDBServer, ServerTypeCoordinator} {
+			if slave.serverProcess(serverType) == nil {
+				continue
+			}
+			id, err := s.peerServerID(peer, serverType)
+			if err != nil {
+				return errors.Wrapf(err, "Cannot get ID of %s", serverType)
+			}
+			serverIDs = append(serverIDs, id)
+			if serverType == ServerTypeDBServer {
+				if err := s.cleanOutServer(id); err != nil {
+					return maskAny(err)
+				}
+			}
+		}
+	}
+
+	// Leave the cluster & stop
+	if err := slave.sendMasterGoodbye(); err != nil {
+		return errors.Wrap(err, "Cannot remove peer")
+	}
+	slave.setRemoveDataOnStop()
+	slave.cancel()
+	s.mutex.Lock()
+	for i, svc := range s.localSlaves {
+		if svc == slave {
+			s.localSlaves = append(s.localSlaves[:i], s.localSlaves[i+1:]...)
+			break
+		}
+	}
+	s.mutex.Unlock()
+
+	// Remove its servers from the cluster, once they are known to have failed
+	for _, id := range serverIDs {
+		s.removeRetiredServer(id)
+	}
+	s.log.Infof("Local slave '%s' has been retired", slave.ID)
+	return nil
+}
+
+// peerServerID returns the cluster ID of the server of given type, started by the given peer.
+func (s *Service) peerServerID(peer Peer, serverType ServerType) (string, error) {
+	ctx, cancel := context.WithTimeout(s.ctx, replaceRequestTimeout)
+	defer cancel()
+	content, err := s.peerServerRequest(ctx, peer, serverType, "GET", "/_admin/server/id", nil)
+	if err != nil {
+		return "", maskAny(err)
+	}
+	var resp struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(content, &resp); err != nil {
+		return "", maskAny(err)
+	}
+	if resp.ID == "" {
+		return "", maskAny(fmt.Errorf("Empty server ID"))
+	}
+	return resp.ID, nil
+}
+
+// cleanOutServer asks the cluster to move all shards off the given dbserver
+// and waits until no shard uses that dbserver anymore.
+func (s *Service) cleanOutServer(id string) error {
+	s.log.Infof("Moving all shards off dbserver '%s'", id)
+	body, _ := json.Marshal(map[string]string{"server": id})
+	ctx, cancel := context.WithTimeout(s.ctx, replaceRequestTimeout)
+	_, err := s.clusterRequest(ctx, ServerTypeCoordinator, "POST", "/_admin/cluster/cleanOutServer", body)
+	cancel()
+	if err != nil {
+		return errors.Wrapf(err, "Cannot clean out server '%s'", id)
+	}
+	deadline := time.Now().Add(replaceResyncTimeout)
+	for {
+		ctx, cancel := context.WithTimeout(s.ctx, replaceRequestTimeout)
+		pending, err := s.pendingShards(ctx, id)
+		cancel()
+		if err != nil {
+			s.log.Debugf("Cannot check shards: %v", err)
+		} else {
+			s.slaveScaling.update(func(status *LocalSlavesStatus) {
+				status.PendingShards = pending
+			})
+			if pending == 0 {
+				return nil
+			}
+		}
+		if time.Now().After(deadline) {
+			return maskAny(fmt.Errorf("Shards have not been moved off server '%s' after %s", id, replaceResyncTimeout))
+		}
+		if s.stop {
+			return maskAny(fmt.Errorf("Stopped while moving shards off server '%s'", id))
+		}
+		time.Sleep(replaceCheckInterval)
+	}
+}
+
+// removeRetiredServer removes the given server of a retired local slave (or a failed peer) from the cluster.
+// The cluster only removes servers that have failed, so this is retried for some time.
+func (s *Service) removeRetiredServer(id string) {
+	body, _ := json.Marshal(id)
+	deadline := time.Now().Add(replaceStartTimeout)
+	for {
+		ctx, cancel := context.WithTimeout(s.ctx, replaceRequestTimeout)
+		_, err := s.clusterRequest(ctx, ServerTypeCoordinator, "POST", "/_admin/cluster/removeServer", body)
+		cancel()
+		if err == nil {
+			s.log.Infof("Server '%s' has been removed from the cluster", id)
+			return
+		}
+		if time.Now().After(deadline) || s.stop {
+			s.log.Warningf("Failed to remove server '%s' from the cluster: %v", id, err)
+			return
+		}
+		time.Sleep(replaceCheckInterval)
+	}
+}
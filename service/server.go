@@ -23,6 +23,7 @@
 package service
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -32,8 +33,10 @@ import (
 	"os"
 	"path/filepath"
 	"strconv"
+	"time"
 
 	"github.com/arangodb-helper/arangodb/client"
+	"github.com/pkg/errors"
 )
 
 var (
@@ -41,11 +44,16 @@ var (
 )
 
 type HelloRequest struct {
-	SlaveID      string // Unique ID of the slave
-	SlaveAddress string // IP address used to reach the slave (if empty, this will be derived from the request)
-	SlavePort    int    // Port used to reach the slave
-	DataDir      string // Directory used for data by this slave
-	IsSecure     bool   // If set, servers started by this peer are using an SSL connection
+	SlaveID       string    // Unique ID of the slave
+	SlaveAddress  string    // IP address used to reach the slave (if empty, this will be derived from the request)
+	SlavePort     int       // Port used to reach the slave
+	DataDir       string    // Directory used for data by this slave
+	IsSecure      bool      // If set, servers started by this peer are using an SSL connection
+	IsStandby     bool      // If set, the slave runs no servers until it is activated
+	IsPassive     bool      // If set, the slave never runs servers
+	StorageEngine string    // Storage engine the slave is configured with (empty for older starters)
+	JoinProof     string    // Proof that the slave knows the join token (see createJoinProof)
+	Time          time.Time // Time of the slave when sending the request (zero for older starters)
 }
 
 type GoodbyeRequest struct {
@@ -62,55 +70,136 @@ type VersionResponse struct {
 	Build   string `json:"build"`
 }
 
+// ReloadResponse is the JSON response of a `/reload` request.
+type ReloadResponse struct {
+	Applied         []string `json:"applied,omitempty"`          // Options that have been changed at runtime
+	RestartRequired []string `json:"restart-required,omitempty"` // Options that have changed, but only take effect after a restart
+}
+
 type ServerProcess struct {
-	Type        string `json:"type"`                   // agent | coordinator | dbserver
-	IP          string `json:"ip"`                     // IP address needed to reach the server
-	Port        int    `json:"port"`                   // Port needed to reach the server
-	ProcessID   int    `json:"pid,omitempty"`          // PID of the process (0 when running in docker)
-	ContainerID string `json:"container-id,omitempty"` // ID of docker container running the server
-	ContainerIP string `json:"container-ip,omitempty"` // IP address of docker container running the server
-	IsSecure    bool   `json:"is-secure,omitempty"`    // If set, this server is using an SSL connection
+	Type        string  `json:"type"`                   // agent | coordinator | dbserver
+	IP          string  `json:"ip"`                     // IP address needed to reach the server
+	Port        int     `json:"port"`                   // Port needed to reach the server
+	ProcessID   int     `json:"pid,omitempty"`          // PID of the process (0 when running in docker)
+	ContainerID string  `json:"container-id,omitempty"` // ID of docker container running the server
+	ContainerIP string  `json:"container-ip,omitempty"` // IP address of docker container running the server
+	IsSecure    bool    `json:"is-secure,omitempty"`    // If set, this server is using an SSL connection
+	CPUPercent  float64 `json:"cpu-percent,omitempty"`  // CPU usage of the server (100 = 1 core)
+	RSS         uint64  `json:"rss,omitempty"`          // Resident set size (in bytes) of the server
+	OpenFiles   int     `json:"open-files,omitempty"`   // Number of open file descriptors of the server (not available in docker)
+	DiskUsage   int64   `json:"disk-usage,omitempty"`   // Size (in bytes) of the directory of the server
 }
 
 // startHTTPServer initializes and runs the HTTP server.
 // If will return directly after starting it.
 func (s *Service) startHTTPServer() {
 	mux := http.NewServeMux()
-	mux.HandleFunc("/hello", s.helloHandler)
-	mux.HandleFunc("/goodbye", s.goodbyeHandler)
+	mux.HandleFunc("/", s.dashboardHandler)
+	mux.HandleFunc("/hello", s.audited("join", s.helloHandler))
+	mux.HandleFunc("/goodbye", s.audited("remove-peer", s.goodbyeHandler))
 	mux.HandleFunc("/process", s.processListHandler)
+	mux.HandleFunc("/processes/", s.commandLineHandler)
+	mux.HandleFunc("/status", s.statusHandler)
+	mux.HandleFunc("/health", s.healthHandler)
+	mux.HandleFunc("/metrics", s.metricsHandler)
+	mux.HandleFunc("/events", s.eventsHandler)
+	mux.HandleFunc("/peers/", s.peerInspectionHandler)
 	mux.HandleFunc("/logs/agent", s.agentLogsHandler)
 	mux.HandleFunc("/logs/dbserver", s.dbserverLogsHandler)
 	mux.HandleFunc("/logs/coordinator", s.coordinatorLogsHandler)
 	mux.HandleFunc("/logs/single", s.singleLogsHandler)
+	mux.HandleFunc("/logs/level", s.audited("set-log-level", s.authorized(s.logLevelHandler)))
+	mux.HandleFunc("/logs/rotate", s.audited("rotate-logs", s.logRotateHandler))
 	mux.HandleFunc("/version", s.versionHandler)
-	mux.HandleFunc("/shutdown", s.shutdownHandler)
+	mux.HandleFunc("/time", s.timeHandler)
+	mux.HandleFunc("/shutdown", s.audited("shutdown", s.shutdownHandler))
+	mux.HandleFunc("/reload", s.audited("reload", s.reloadHandler))
+	mux.HandleFunc("/config", s.configHandler)
+	mux.HandleFunc("/standby/activate", s.audited("activate-standby", s.authorized(s.activateStandbyHandler)))
+	mux.HandleFunc("/activate", s.audited("activate", s.authorized(s.activateHandler)))
+	mux.HandleFunc("/upgrade", s.audited("upgrade", s.upgradeHandler))
+	mux.HandleFunc("/operations", s.operationsHandler)
+	mux.HandleFunc("/operations/", s.operationsHandler)
+	mux.HandleFunc("/upgrade/server", s.audited("upgrade-server", s.upgradeServerHandler))
+	mux.HandleFunc("/upgrade/info", s.upgradeInfoHandler)
+	mux.HandleFunc("/upgrade/resume", s.audited("upgrade-resume", s.upgradeDecisionHandler("/upgrade/resume", true)))
+	mux.HandleFunc("/upgrade/abort", s.audited("upgrade-abort", s.upgradeDecisionHandler("/upgrade/abort", false)))
+	mux.HandleFunc("/server/restart", s.audited("restart-server", s.authorized(s.restartServerHandler)))
+	mux.HandleFunc("/dbserver/replace", s.audited("replace-dbserver", s.authorized(s.replaceDBServerHandler)))
+	mux.HandleFunc("/dbserver/start", s.audited("start-dbserver", s.authorized(s.startDBServerHandler)))
+	mux.HandleFunc("/agent/start", s.audited("start-agent", s.startAgentHandler))
+	mux.HandleFunc("/diagnostics", s.audited("diagnostics", s.authorized(s.diagnosticsHandler)))
+	mux.HandleFunc("/auditlog", s.auditLogHandler)
+	mux.HandleFunc("/agency/dump", s.authorized(s.agencyDumpHandler))
+	mux.HandleFunc("/backup", s.backupHandler)
+	mux.HandleFunc("/data/move", s.audited("move-data", s.moveDataHandler))
+	mux.HandleFunc("/local-slaves", s.audited("scale-local-slaves", s.localSlavesHandler))
+	mux.HandleFunc("/hotbackup", s.audited("hotbackup", s.authorized(s.hotBackupHandler)))
+	mux.HandleFunc("/hotbackup/upload", s.audited("hotbackup-upload", s.authorized(s.hotBackupTransferHandler(HotBackupOperationUpload))))
+	mux.HandleFunc("/hotbackup/download", s.audited("hotbackup-download", s.authorized(s.hotBackupTransferHandler(HotBackupOperationDownload))))
+
+	server := &http.Server{
+		Handler: s.withCORS(s.limited(s.readOnly(compressed(mux)))),
+	}
+	s.mutex.Lock()
+	s.httpServer = server
+	s.mutex.Unlock()
+
+	if socket := unixSocketPath(s.StarterListen); socket != "" {
+		l, err := listenUnixSocket(socket)
+		if err != nil {
+			s.apiLog.Fatalf("Failed to listen on unix socket %s: %v", socket, err)
+		}
+		s.apiLog.Infof("Listening on unix socket %s", socket)
+		go func() {
+			if err := server.Serve(l); err != nil && err != http.ErrServerClosed {
+				s.apiLog.Errorf("Failed to serve on unix socket %s: %v", socket, err)
+			}
+		}()
+		if s.isSingleMode() {
+			// There are no peers that need to reach us over TCP
+			return
+		}
+	}
 
 	go func() {
 		containerPort, hostPort, err := s.getHTTPServerPort()
 		if err != nil {
-			s.log.Fatalf("Failed to get HTTP port info: %#v", err)
-		}
-		addr := fmt.Sprintf("0.0.0.0:%d", containerPort)
-		server := &http.Server{
-			Addr:    addr,
-			Handler: mux,
+			s.apiLog.Fatalf("Failed to get HTTP port info: %#v", err)
 		}
+		// Listen on the bind address, or all interfaces (IPv4 & IPv6) when not set
+		addr := net.JoinHostPort(s.starterBindAddress(), strconv.Itoa(containerPort))
+		server.Addr = addr
 		if s.tlsConfig != nil {
-			s.log.Infof("Listening on %s (%s) using TLS", addr, net.JoinHostPort(s.OwnAddress, strconv.Itoa(hostPort)))
+			s.apiLog.Infof("Listening on %s (%s) using TLS", addr, net.JoinHostPort(s.OwnAddress, strconv.Itoa(hostPort)))
 			server.TLSConfig = s.tlsConfig
-			if err := server.ListenAndServeTLS("", ""); err != nil {
-				s.log.Errorf("Failed to listen on %s: %v", addr, err)
+			if err := server.ListenAndServeTLS("", ""); err != nil && err != http.ErrServerClosed {
+				s.apiLog.Errorf("Failed to listen on %s: %v", addr, err)
 			}
 		} else {
-			s.log.Infof("Listening on %s (%s)", addr, net.JoinHostPort(s.OwnAddress, strconv.Itoa(hostPort)))
-			if err := server.ListenAndServe(); err != nil {
-				s.log.Errorf("Failed to listen on %s: %v", addr, err)
+			s.apiLog.Infof("Listening on %s (%s)", addr, net.JoinHostPort(s.OwnAddress, strconv.Itoa(hostPort)))
+			if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				s.apiLog.Errorf("Failed to listen on %s: %v", addr, err)
 			}
 		}
 	}()
 }
 
+// stopHTTPServer stops the HTTP server (if any), after all pending requests have been served.
+func (s *Service) stopHTTPServer() {
+	s.mutex.Lock()
+	server := s.httpServer
+	s.httpServer = nil
+	s.mutex.Unlock()
+	if server != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+		defer cancel()
+		if err := server.Shutdown(ctx); err != nil {
+			s.apiLog.Warningf("Failed to stop HTTP server: %v", err)
+		}
+	}
+}
+
 // HTTP service function:
 
 func (s *Service) helloHandler(w http.ResponseWriter, r *http.Request) {
@@ -118,8 +207,10 @@ func (s *Service) helloHandler(w http.ResponseWriter, r *http.Request) {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
-	s.log.Debugf("Received request from %s", r.RemoteAddr)
-	if s.state == stateSlave {
+	s.apiLog.Debugf("Received request from %s", r.RemoteAddr)
+	// Only the master handles hello requests, a running slave also forwards them
+	isRunningSlave := s.state == stateRunning && len(s.myPeers.Peers) > 0 && s.myPeers.Peers[0].ID != s.ID
+	if s.state == stateSlave || isRunningSlave {
 		header := w.Header()
 		if len(s.myPeers.Peers) > 0 {
 			master := s.myPeers.Peers[0]
@@ -139,20 +230,31 @@ func (s *Service) helloHandler(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 		myself := normalizeHostName(host)
+		if s.PreferHostnames && s.OwnAddress != "" {
+			// Advertise the DNS name of the master, instead of the address the slave used to reach it
+			myself = s.OwnAddress
+		}
 		_, hostPort, _ := s.getHTTPServerPort()
+		serverPorts, err := s.freeServerPorts(myself, !s.isSingleMode())
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
 		s.myPeers.Peers = []Peer{
 			Peer{
-				ID:         s.ID,
-				Address:    myself,
-				Port:       hostPort,
-				PortOffset: 0,
-				DataDir:    s.DataDir,
-				HasAgent:   !s.isSingleMode(),
-				IsSecure:   s.IsSecure(),
+				ID:          s.ID,
+				Address:     myself,
+				Port:        hostPort,
+				PortOffset:  0,
+				DataDir:     s.DataDir,
+				HasAgent:    !s.isSingleMode(),
+				IsSecure:    s.IsSecure(),
+				ServerPorts: serverPorts,
 			},
 		}
-		s.log.Infof("Added master '%s': %s, portOffset: %d", s.myPeers.Peers[0].ID, s.myPeers.Peers[0].Address, s.myPeers.Peers[0].PortOffset)
+		s.peersLog.Infof("Added master '%s': %s, portOffset: %d", s.myPeers.Peers[0].ID, s.myPeers.Peers[0].Address, s.myPeers.Peers[0].PortOffset)
 		s.myPeers.AgencySize = s.AgencySize
+		s.myPeers.StorageEngine = s.ServerStorageEngine
 	}
 
 	if r.Method == "POST" {
@@ -175,7 +277,7 @@ func (s *Service) helloHandler(w http.ResponseWriter, r *http.Request) {
 				writeError(w, http.StatusBadRequest, "SlaveAddress must be set.")
 				return
 			}
-			slaveAddr = normalizeHostName(host)
+			slaveAddr = s.peerHostName(normalizeHostName(host))
 		} else {
 			slaveAddr = normalizeHostName(slaveAddr)
 		}
@@ -187,6 +289,27 @@ func (s *Service) helloHandler(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
+		// Check join token
+		if token := s.joinToken(); token != "" {
+			remoteHost, _, _ := net.SplitHostPort(r.RemoteAddr)
+			if s.joinFailures.isBlocked(remoteHost) {
+				writeError(w, http.StatusTooManyRequests, "Too many failed join attempts, try again later.")
+				return
+			}
+			if !isValidJoinProof(token, req.SlaveID, req.Time, req.JoinProof) {
+				s.joinFailures.add(remoteHost)
+				s.peersLog.Warningf("Rejecting peer '%s' from %s: invalid, outdated or missing join token", req.SlaveID, remoteHost)
+				writeError(w, http.StatusUnauthorized, fmt.Sprintf("Invalid, outdated or missing join token. Use the same --starter.join-token (or --auth.jwt-secret) on all peers and make sure their clocks differ less than %s.", joinProofWindow))
+				return
+			}
+			if !s.joinProofs.use(req.JoinProof) {
+				s.joinFailures.add(remoteHost)
+				s.peersLog.Warningf("Rejecting peer '%s' from %s: replayed join request", req.SlaveID, remoteHost)
+				writeError(w, http.StatusUnauthorized, "Join request has been sent before.")
+				return
+			}
+		}
+
 		// Check datadir
 		if !s.allowSameDataDir {
 			for _, p := range s.myPeers.Peers {
@@ -203,21 +326,39 @@ func (s *Service) helloHandler(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		// If slaveID already known, then return data right away.
+		// Check storage engine, cannot mix storage engines
+		if msg := storageEngineMismatch(s.myPeers.StorageEngine, req.StorageEngine); msg != "" {
+			s.peersLog.Errorf("Rejecting peer '%s' at %s: %s", req.SlaveID, slaveAddr, msg)
+			writeError(w, http.StatusBadRequest, msg)
+			return
+		}
+
+		// Check the clock of the slave
 		_, idFound := s.myPeers.PeerByID(req.SlaveID)
+		if msg := s.checkJoinClockSkew(req.SlaveID, slaveAddr, req.Time, !idFound); msg != "" {
+			s.peersLog.Errorf("Rejecting peer '%s' at %s: %s", req.SlaveID, slaveAddr, msg)
+			writeError(w, http.StatusBadRequest, msg)
+			return
+		}
+
+		// If slaveID already known, then return data right away.
 		if idFound {
 			// ID already found, update peer data
 			for i, p := range s.myPeers.Peers {
 				if p.ID == req.SlaveID {
 					s.myPeers.Peers[i].Port = req.SlavePort
-					if s.AllPortOffsetsUnique {
-						s.myPeers.Peers[i].Address = slaveAddr
-					} else {
-						// Slave address may not change
-						if p.Address != slaveAddr {
-							writeError(w, http.StatusBadRequest, "Cannot change slave address while using an existing ID.")
+					if p.Address != slaveAddr {
+						// Slave address has changed (e.g. a new DHCP lease), its ports must remain available
+						if !s.myPeers.CanChangeAddress(p.ID, slaveAddr, s.AllPortOffsetsUnique) {
+							writeError(w, http.StatusBadRequest, fmt.Sprintf("Cannot change slave address to %s, its ports are in use by another peer at that address.", slaveAddr))
 							return
 						}
+						s.myPeers.Peers[i].Address = slaveAddr
+						s.peersLog.Info(newLogEvent("peer-address-changed", LogFields{"peer-id": p.ID, "old-address": p.Address, "address": slaveAddr},
+							"Address of peer '%s' has changed from %s to %s", p.ID, p.Address, slaveAddr))
+						if err := s.saveSetup(); err != nil {
+							s.peersLog.Errorf("Failed to save setup: %#v", err)
+						}
 					}
 					s.myPeers.Peers[i].DataDir = req.DataDir
 				}
@@ -229,18 +370,33 @@ func (s *Service) helloHandler(w http.ResponseWriter, r *http.Request) {
 				return
 			}
 			// ID not yet found, add it
+			hasAgent := (s.myPeers.AgentCount() < s.AgencySize) && !s.isSingleMode() && !req.IsStandby && !req.IsPassive
+			serverPorts, err := s.freeServerPorts(slaveAddr, hasAgent)
+			if err != nil {
+				writeError(w, http.StatusServiceUnavailable, err.Error())
+				return
+			}
 			newPeer := Peer{
-				ID:         req.SlaveID,
-				Address:    slaveAddr,
-				Port:       slavePort,
-				PortOffset: s.myPeers.GetFreePortOffset(slaveAddr, s.AllPortOffsetsUnique),
-				DataDir:    req.DataDir,
-				HasAgent:   (len(s.myPeers.Peers) < s.AgencySize) && !s.isSingleMode(),
-				IsSecure:   req.IsSecure,
+				ID:          req.SlaveID,
+				Address:     slaveAddr,
+				Port:        slavePort,
+				PortOffset:  s.myPeers.GetFreePortOffset(slaveAddr, s.AllPortOffsetsUnique, s.portLayout()),
+				DataDir:     req.DataDir,
+				HasAgent:    hasAgent,
+				IsSecure:    req.IsSecure,
+				IsStandby:   req.IsStandby,
+				IsPassive:   req.IsPassive,
+				ServerPorts: serverPorts,
 			}
 			s.myPeers.Peers = append(s.myPeers.Peers, newPeer)
-			s.log.Infof("Added new peer '%s': %s, portOffset: %d", newPeer.ID, newPeer.Address, newPeer.PortOffset)
-			if len(s.myPeers.Peers) == s.AgencySize {
+			if newPeer.IsStandby {
+				s.peersLog.Infof("Added new standby peer '%s': %s, portOffset: %d", newPeer.ID, newPeer.Address, newPeer.PortOffset)
+			} else if newPeer.IsPassive {
+				s.peersLog.Infof("Added new passive peer '%s': %s", newPeer.ID, newPeer.Address)
+			} else {
+				s.peersLog.Infof("Added new peer '%s': %s, portOffset: %d", newPeer.ID, newPeer.Address, newPeer.PortOffset)
+			}
+			if newPeer.HasAgent && s.myPeers.AgentCount() == s.AgencySize {
 				s.startRunningTrigger()
 			}
 		}
@@ -282,7 +438,8 @@ func (s *Service) goodbyeHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Remove the peer
-	s.log.Infof("Removing peer %s", req.SlaveID)
+	s.peersLog.Infof("Removing peer %s", req.SlaveID)
+	removedPeer, _ := s.myPeers.PeerByID(req.SlaveID)
 	if removed := s.myPeers.RemovePeerByID(req.SlaveID); !removed {
 		// ID not found
 		writeError(w, http.StatusNotFound, "Unknown ID")
@@ -290,9 +447,14 @@ func (s *Service) goodbyeHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Peer has been removed, update stored config
-	s.log.Info("Saving setup")
+	s.apiLog.Info("Saving setup")
 	if err := s.saveSetup(); err != nil {
-		s.log.Errorf("Failed to save setup: %#v", err)
+		s.apiLog.Errorf("Failed to save setup: %#v", err)
+	}
+
+	// Move the agent of the removed peer to another peer
+	if removedPeer.HasAgent && s.state == stateRunning && s.isClusterMode() {
+		go s.replaceAgent(removedPeer.ID)
 	}
 
 	w.WriteHeader(http.StatusOK)
@@ -305,36 +467,12 @@ func (s *Service) processListHandler(w http.ResponseWriter, r *http.Request) {
 	expectedServers := 2
 	myPeer, found := s.myPeers.PeerByID(s.ID)
 	if found {
-		portOffset := myPeer.PortOffset
-		ip := myPeer.Address
 		if myPeer.HasAgent {
 			expectedServers = 3
+		} else if !myPeer.HasServers() {
+			expectedServers = 0
 		}
-
-		createServerProcess := func(serverType ServerType, p Process) ServerProcess {
-			return ServerProcess{
-				Type:        serverType.String(),
-				IP:          ip,
-				Port:        s.MasterPort + portOffset + serverType.PortOffset(),
-				ProcessID:   p.ProcessID(),
-				ContainerID: p.ContainerID(),
-				ContainerIP: p.ContainerIP(),
-				IsSecure:    s.IsSecure(),
-			}
-		}
-
-		if p := s.servers.agentProc; p != nil {
-			resp.Servers = append(resp.Servers, createServerProcess(ServerTypeAgent, p))
-		}
-		if p := s.servers.coordinatorProc; p != nil {
-			resp.Servers = append(resp.Servers, createServerProcess(ServerTypeCoordinator, p))
-		}
-		if p := s.servers.dbserverProc; p != nil {
-			resp.Servers = append(resp.Servers, createServerProcess(ServerTypeDBServer, p))
-		}
-		if p := s.servers.singleProc; p != nil {
-			resp.Servers = append(resp.Servers, createServerProcess(ServerTypeSingle, p))
-		}
+		resp.Servers = s.serverProcesses(myPeer)
 	}
 	if s.isSingleMode() {
 		expectedServers = 1
@@ -348,6 +486,43 @@ func (s *Service) processListHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// serverProcesses returns information about all servers started by this starter on the given peer.
+func (s *Service) serverProcesses(myPeer Peer) []ServerProcess {
+	var result []ServerProcess
+	createServerProcess := func(serverType ServerType, p Process) ServerProcess {
+		sp := ServerProcess{
+			Type:        serverType.String(),
+			IP:          myPeer.Address,
+			Port:        myPeer.ServerPort(s.MasterPort, serverType),
+			ProcessID:   p.ProcessID(),
+			ContainerID: p.ContainerID(),
+			ContainerIP: p.ContainerIP(),
+			IsSecure:    s.IsSecure(),
+		}
+		if res, found := s.resources.get(serverType); found {
+			sp.CPUPercent = res.CPUPercent
+			sp.RSS = res.RSS
+			sp.OpenFiles = res.OpenFiles
+			sp.DiskUsage = res.DiskUsage
+		}
+		return sp
+	}
+
+	if p := s.servers.agentProc; p != nil {
+		result = append(result, createServerProcess(ServerTypeAgent, p))
+	}
+	if p := s.servers.coordinatorProc; p != nil {
+		result = append(result, createServerProcess(ServerTypeCoordinator, p))
+	}
+	if p := s.servers.dbserverProc; p != nil {
+		result = append(result, createServerProcess(ServerTypeDBServer, p))
+	}
+	if p := s.servers.singleProc; p != nil {
+		result = append(result, createServerProcess(ServerTypeSingle, p))
+	}
+	return result
+}
+
 // agentLogsHandler servers the entire agent log (if any).
 // If there is no agent running a 404 is returned.
 func (s *Service) agentLogsHandler(w http.ResponseWriter, r *http.Request) {
@@ -382,13 +557,30 @@ func (s *Service) logsHandler(w http.ResponseWriter, r *http.Request, serverType
 		return
 	}
 	logPath := filepath.Join(myHostDir, logFileName)
-	s.log.Debugf("Fetching logs in %s", logPath)
+	switch stream := r.URL.Query().Get("stream"); stream {
+	case "":
+		// arangod log file
+	case OutputStreamStdout, OutputStreamStderr:
+		logPath, err = latestOutputFile(myHostDir, stream)
+		if err != nil && !os.IsNotExist(errors.Cause(err)) {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		} else if logPath == "" {
+			// No output captured (yet), we allow this
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+	default:
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("Unknown stream '%s', expected stdout or stderr", stream))
+		return
+	}
+	s.apiLog.Debugf("Fetching logs in %s", logPath)
 	rd, err := os.Open(logPath)
 	if os.IsNotExist(err) {
 		// Log file not there (yet), we allow this
 		w.WriteHeader(http.StatusOK)
 	} else if err != nil {
-		s.log.Errorf("Failed to open log file '%s': %#v", logPath, err)
+		s.apiLog.Errorf("Failed to open log file '%s': %#v", logPath, err)
 		w.WriteHeader(http.StatusInternalServerError)
 		w.Write([]byte(err.Error()))
 	} else {
@@ -407,7 +599,7 @@ func (s *Service) versionHandler(w http.ResponseWriter, r *http.Request) {
 	}
 	data, err := json.Marshal(v)
 	if err != nil {
-		s.log.Errorf("Failed to marshal version response: %#v", err)
+		s.apiLog.Errorf("Failed to marshal version response: %#v", err)
 		w.WriteHeader(http.StatusInternalServerError)
 		w.Write([]byte(err.Error()))
 	} else {
@@ -423,21 +615,76 @@ func (s *Service) shutdownHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if r.FormValue("mode") == "goodbye" {
-		// Inform the master we're leaving for good
-		if err := s.sendMasterGoodbye(); err != nil {
-			s.log.Errorf("Failed to send master goodbye: %#v", err)
+	op, existing, ok := s.beginOperation(w, r, OperationTypeShutdown)
+	if !ok {
+		return
+	}
+	if !existing {
+		goodbye := r.FormValue("mode") == "goodbye"
+		removeData := r.FormValue("remove-data") == "true"
+		shutdown := func() (interface{}, error) {
+			if goodbye {
+				// Inform the master we're leaving for good
+				if err := s.sendMasterGoodbye(); err != nil {
+					s.apiLog.Errorf("Failed to send master goodbye: %#v", err)
+					return nil, maskAny(err)
+				}
+			}
+
+			if removeData {
+				// Remove all data once the servers have stopped
+				s.apiLog.Info("All data will be removed after shutdown")
+				s.setRemoveDataOnStop()
+			}
+
+			// Stop my services
+			s.cancel()
+			return nil, nil
+		}
+		if prefersAsync(r) {
+			go s.runOperation(op.ID, shutdown)
+		} else if _, err := s.runOperation(op.ID, shutdown); err != nil {
+			// Nothing has happened, so a retry may start it again
+			s.operations.remove(op.ID)
 			writeError(w, http.StatusInternalServerError, err.Error())
 			return
 		}
+	} else if op.State == OperationStateFailed && !prefersAsync(r) {
+		writeError(w, http.StatusInternalServerError, op.Error)
+		return
+	}
+	if prefersAsync(r) {
+		op, _ = s.operations.get(op.ID)
+		writeOperation(w, http.StatusAccepted, op)
+		return
 	}
-
-	// Stop my services
-	s.cancel()
 	w.WriteHeader(http.StatusOK)
 	w.Write([]byte("OK"))
 }
 
+// reloadHandler re-reads the configuration file and applies all changes that can be applied at runtime.
+func (s *Service) reloadHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		writeError(w, http.StatusMethodNotAllowed, "POST required")
+		return
+	}
+	if s.reloader == nil {
+		writeError(w, http.StatusNotImplemented, "Reload is not supported")
+		return
+	}
+	resp, err := s.reloader()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	b, err := json.Marshal(resp)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+	} else {
+		w.Write(b)
+	}
+}
+
 func writeError(w http.ResponseWriter, status int, message string) {
 	if message == "" {
 		message = "Unknown error"
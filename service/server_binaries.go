@@ -0,0 +1,278 @@
+//
+// DISCLAIMER
+//
+// Copyright 2017 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+// Author Ewout Prangsma
+//
+
+package service
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	docker "github.com/fsouza/go-dockerclient"
+)
+
+// Names of the passthrough options (e.g. `--dbservers.server.version`) that select the arangod binary
+// (or docker image) of servers. They are used by the starter itself and never passed to the servers.
+const (
+	ServerBinaryOptionVersion = "server.version" // Version the servers are pinned to
+	ServerBinaryOptionArangod = "server.arangod" // Path of the arangod executable
+	ServerBinaryOptionJSDir   = "server.js-dir"  // Path of the JS startup directory
+	ServerBinaryOptionImage   = "docker.image"   // Docker image
+)
+
+// IsServerBinaryOption returns true if the passthrough option with given name selects the arangod binary
+// (or docker image) of servers, instead of being passed to the servers.
+func IsServerBinaryOption(name string) bool {
+	switch name {
+	case ServerBinaryOptionVersion, ServerBinaryOptionArangod, ServerBinaryOptionJSDir, ServerBinaryOptionImage:
+		return true
+	default:
+		return false
+	}
+}
+
+// ServerBinary holds the arangod binary (or docker image) used to run the servers of a specific type,
+// when it differs from the one given for the starter.
+type ServerBinary struct {
+	Version     string `json:"version,omitempty"`      // Version the servers are pinned to (if any)
+	ArangodPath string `json:"arangod,omitempty"`      // Path of the arangod executable (when running servers as processes)
+	JSPath      string `json:"js-dir,omitempty"`       // Path of the JS startup directory (when running servers as processes)
+	DockerImage string `json:"docker-image,omitempty"` // Docker image (when running servers in docker)
+}
+
+// ServerBinaries holds the arangod binary (or docker image) per server type, for the server types
+// that do not use the binary of the starter.
+type ServerBinaries map[ServerType]ServerBinary
+
+// IsEmpty returns true if the binary of the starter is used.
+func (b ServerBinary) IsEmpty() bool {
+	return b == ServerBinary{}
+}
+
+// ServerBinaryOptions returns the values (by name) of the server binary options that apply to servers of given type.
+// Options given for a specific server type take precedence over options given for all servers.
+func ServerBinaryOptions(options []PassthroughOption, serverType ServerType) map[string]string {
+	result := make(map[string]string)
+	prefix := passthroughPrefix(serverType)
+	for _, o := range options {
+		if o.Prefix == PassthroughPrefixAll && IsServerBinaryOption(o.Name) {
+			result[o.Name] = o.Value
+		}
+	}
+	for _, o := range options {
+		if prefix != "" && o.Prefix == prefix && IsServerBinaryOption(o.Name) {
+			result[o.Name] = o.Value
+		}
+	}
+	return result
+}
+
+// DockerImageWithVersion returns the given image with its tag replaced by the given version.
+func DockerImageWithVersion(image, version string) string {
+	repo, _ := docker.ParseRepositoryTag(image)
+	return repo + ":" + version
+}
+
+// serverBinaryOf returns the binary (or docker image) used for servers of given type, when it differs
+// from the one given for the starter. A binary installed by a rolling upgrade takes precedence over
+// the binary given in the options.
+func (s *Service) serverBinaryOf(serverType ServerType) ServerBinary {
+	if b, found := s.upgradedBinaries.get(serverType); found {
+		return b
+	}
+	return s.ServerBinaries[serverType]
+}
+
+// serverRunner returns the runner used to start the servers of given type.
+// When the servers are pinned to another docker image, the given runner is adapted to use that image.
+func (s *Service) serverRunner(runner Runner, serverType ServerType) Runner {
+	image := s.serverBinaryOf(serverType).DockerImage
+	if image == "" {
+		return runner
+	}
+	if ir, ok := runner.(interface {
+		WithImage(image string) Runner
+	}); ok {
+		return ir.WithImage(image)
+	}
+	return runner
+}
+
+// serverArangodPaths returns the paths of the arangod executable & JS startup directory used for servers of given type.
+func (s *Service) serverArangodPaths(serverType ServerType) (arangodPath, jsPath string) {
+	arangodPath, jsPath = s.ArangodPath, s.ArangodJSPath
+	if b := s.serverBinaryOf(serverType); b.ArangodPath != "" {
+		arangodPath = b.ArangodPath
+		if b.JSPath != "" {
+			jsPath = b.JSPath
+		}
+	}
+	return arangodPath, jsPath
+}
+
+// serverImage returns the docker image used for servers of given type.
+func (s *Service) serverImage(serverType ServerType) string {
+	if image := s.serverBinaryOf(serverType).DockerImage; image != "" {
+		return image
+	}
+	return s.DockerImage
+}
+
+// recordedVersions holds the versions last reported by the servers of this starter, as recorded in the setup file.
+type recordedVersions struct {
+	mutex    sync.Mutex
+	versions map[string]string // Version by server type
+}
+
+// set records the version of the server of given type.
+// Returns the previously recorded version and true if it has changed.
+func (v *recordedVersions) set(serverType ServerType, version string) (string, bool) {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	previous := v.versions[string(serverType)]
+	if previous == version {
+		return previous, false
+	}
+	if v.versions == nil {
+		v.versions = make(map[string]string)
+	}
+	v.versions[string(serverType)] = version
+	return previous, true
+}
+
+// get returns a copy of all recorded versions, or nil if none.
+func (v *recordedVersions) get() map[string]string {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	if len(v.versions) == 0 {
+		return nil
+	}
+	result := make(map[string]string, len(v.versions))
+	for k, version := range v.versions {
+		result[k] = version
+	}
+	return result
+}
+
+// recordServerVersion checks the version reported by the server of given type against the version it is pinned to
+// and records it in the setup file when it has changed.
+func (s *Service) recordServerVersion(serverType ServerType, version string) {
+	if version == "" {
+		return
+	}
+	if pinned := s.serverBinaryOf(serverType).Version; pinned != "" && !strings.HasPrefix(version, pinned) {
+		s.log.Warningf("%s runs version %s, but is pinned to version %s", serverType, version, pinned)
+	}
+	previous, changed := s.serverVersions.set(serverType, version)
+	if !changed {
+		return
+	}
+	if previous != "" {
+		s.log.Infof("Version of %s has changed from %s to %s", serverType, previous, version)
+	}
+	if _, err := os.Stat(filepath.Join(s.DataDir, setupFileName)); err == nil {
+		s.saveSetup()
+	}
+}
+
+// checkRecordedServerVersions loads the versions of the servers recorded in the setup file.
+// The database of every server that is pinned to another version than recorded is upgraded on start.
+func (s *Service) checkRecordedServerVersions(recorded map[string]string) {
+	for t, version := range recorded {
+		serverType := ServerType(t)
+		s.serverVersions.set(serverType, version)
+		if pinned := s.ServerBinaries[serverType].Version; pinned != "" && !strings.HasPrefix(version, pinned) {
+			s.log.Infof("%s has run version %s, but is now pinned to version %s, its database is upgraded before it is started", serverType, version, pinned)
+			s.serverStates.requestAutoUpgrade(serverType)
+		}
+	}
+}
+
+// upgradedBinaries holds the binaries (or docker images) that servers of this starter have been upgraded to
+// by a rolling upgrade (see UpgradeRequest), as recorded in the setup file.
+type upgradedBinaries struct {
+	mutex    sync.Mutex
+	binaries ServerBinaries
+}
+
+// set records the binary of the server of given type, or removes it when the given binary is empty.
+func (u *upgradedBinaries) set(serverType ServerType, b ServerBinary) {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+	if b.IsEmpty() {
+		delete(u.binaries, serverType)
+		return
+	}
+	if u.binaries == nil {
+		u.binaries = make(ServerBinaries)
+	}
+	u.binaries[serverType] = b
+}
+
+// get returns the binary the server of given type has been upgraded to and true, or false if none.
+func (u *upgradedBinaries) get(serverType ServerType) (ServerBinary, bool) {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+	b, found := u.binaries[serverType]
+	return b, found
+}
+
+// getAll returns a copy of all recorded binaries, or nil if none.
+func (u *upgradedBinaries) getAll() ServerBinaries {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+	if len(u.binaries) == 0 {
+		return nil
+	}
+	result := make(ServerBinaries, len(u.binaries))
+	for k, b := range u.binaries {
+		result[k] = b
+	}
+	return result
+}
+
+// checkRecordedUpgradedBinaries loads the binaries that servers have been upgraded to, as recorded in the setup file.
+func (s *Service) checkRecordedUpgradedBinaries(recorded ServerBinaries) {
+	for serverType, b := range recorded {
+		s.log.Infof("%s uses the binary it has been upgraded to by a rolling upgrade: %s", serverType, describeServerBinary(b))
+		s.upgradedBinaries.set(serverType, b)
+	}
+}
+
+// describeServerBinary returns a human readable description of the given binary.
+func describeServerBinary(b ServerBinary) string {
+	var parts []string
+	if b.DockerImage != "" {
+		parts = append(parts, "image "+b.DockerImage)
+	}
+	if b.ArangodPath != "" {
+		parts = append(parts, "arangod "+b.ArangodPath)
+	}
+	if b.JSPath != "" {
+		parts = append(parts, "js-dir "+b.JSPath)
+	}
+	if len(parts) == 0 {
+		return "binary of the starter"
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,179 @@
+//
+// DISCLAIMER
+//
+// Copyright 2017 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+// Author Ewout Prangsma
+//
+
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/arangodb-helper/arangodb/client"
+)
+
+var (
+	// serverHTTPClient is used for API requests to the servers started by the starter.
+	serverHTTPClient = &http.Client{
+		Timeout: time.Second * 30,
+		Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{
+				InsecureSkipVerify: true,
+			},
+		},
+	}
+	// serverLongHTTPClient is used for API requests to the servers that can take longer than the timeout of
+	// serverHTTPClient (e.g. creating a hot backup). These requests are only limited by their context.
+	serverLongHTTPClient = &http.Client{
+		Transport: serverHTTPClient.Transport,
+	}
+)
+
+// serverRequest performs an API request to the server of given type, started by this starter.
+// Returns the body of the response, or an error if the request failed or its status is not 2xx.
+func (s *Service) serverRequest(ctx context.Context, serverType ServerType, method, path string, body []byte) ([]byte, error) {
+	myPeer, found := s.myPeers.PeerByID(s.ID)
+	if !found {
+		return nil, maskAny(fmt.Errorf("Cannot find peer %s", s.ID))
+	}
+	content, err := s.peerServerRequest(ctx, myPeer, serverType, method, path, body)
+	if err != nil {
+		return nil, maskAny(err)
+	}
+	return content, nil
+}
+
+// clusterRequest performs an API request to a server of given type, started by any of the peers.
+// The peers are tried in order, until one of them responds with a 2xx status.
+func (s *Service) clusterRequest(ctx context.Context, serverType ServerType, method, path string, body []byte) ([]byte, error) {
+	content, err := s.clusterRequestWithContentType(ctx, serverType, method, path, "application/json", body)
+	if err != nil {
+		return nil, maskAny(err)
+	}
+	return content, nil
+}
+
+// clusterRequestWithContentType performs an API request with a body of given content type to a server of given type,
+// started by any of the peers. The peers are tried in order, until one of them responds with a 2xx status.
+// Since POST requests are not idempotent, they are only sent to the next peer when the server of a peer cannot be reached.
+func (s *Service) clusterRequestWithContentType(ctx context.Context, serverType ServerType, method, path, contentType string, body []byte) ([]byte, error) {
+	content, err := s.clusterRequestWithClient(ctx, serverHTTPClient, serverType, method, path, contentType, body)
+	if err != nil {
+		return nil, maskAny(err)
+	}
+	return content, nil
+}
+
+// clusterRequestWithClient performs an API request like clusterRequestWithContentType, using the given HTTP client.
+func (s *Service) clusterRequestWithClient(ctx context.Context, httpClient *http.Client, serverType ServerType, method, path, contentType string, body []byte) ([]byte, error) {
+	s.mutex.Lock()
+	peerList := append([]Peer{}, s.myPeers.Peers...)
+	s.mutex.Unlock()
+
+	lastErr := fmt.Errorf("No peer runs a %s", serverType)
+	for _, p := range peerList {
+		if !p.HasServers() || (serverType == ServerTypeAgent && !p.HasAgent) {
+			continue
+		}
+		content, err := s.peerServerRequestWithClient(ctx, httpClient, p, serverType, method, path, contentType, body)
+		if err == nil {
+			return content, nil
+		}
+		lastErr = err
+		if ctx.Err() != nil {
+			break
+		}
+		if method == "POST" && !client.IsConnectionError(err) {
+			// The request may have been executed
+			break
+		}
+	}
+	return nil, maskAny(lastErr)
+}
+
+// peerServerRequest performs an API request to the server of given type, started by the given peer.
+// Returns the body of the response, or an error if the request failed or its status is not 2xx.
+func (s *Service) peerServerRequest(ctx context.Context, peer Peer, serverType ServerType, method, path string, body []byte) ([]byte, error) {
+	content, err := s.peerServerRequestWithContentType(ctx, peer, serverType, method, path, "application/json", body)
+	if err != nil {
+		return nil, maskAny(err)
+	}
+	return content, nil
+}
+
+// peerServerRequestWithContentType performs an API request with a body of given content type to the server
+// of given type, started by the given peer.
+// Returns the body of the response, or an error if the request failed or its status is not 2xx.
+func (s *Service) peerServerRequestWithContentType(ctx context.Context, peer Peer, serverType ServerType, method, path, contentType string, body []byte) ([]byte, error) {
+	content, err := s.peerServerRequestWithClient(ctx, serverHTTPClient, peer, serverType, method, path, contentType, body)
+	if err != nil {
+		return nil, maskAny(err)
+	}
+	return content, nil
+}
+
+// peerServerRequestWithClient performs an API request like peerServerRequestWithContentType, using the given HTTP client.
+func (s *Service) peerServerRequestWithClient(ctx context.Context, httpClient *http.Client, peer Peer, serverType ServerType, method, path, contentType string, body []byte) ([]byte, error) {
+	port := peer.ServerPort(s.MasterPort, serverType)
+	scheme := NewURLSchemes(s.IsSecure()).Browser
+	url := fmt.Sprintf("%s://%s%s", scheme, net.JoinHostPort(peer.Address, strconv.Itoa(port)), path)
+	if socket := s.serverSocket(serverType); socket != "" && peer.ID == s.ID {
+		url = "http://localhost" + path
+		httpClient = serverSocketHTTPClient(socket, httpClient.Timeout)
+	}
+	req, err := http.NewRequest(method, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, maskAny(err)
+	}
+	req = req.WithContext(ctx)
+	if body != nil {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if err := addJwtHeader(req, s.JwtSecret); err != nil {
+		return nil, maskAny(err)
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, maskAny(err)
+	}
+	defer resp.Body.Close()
+	content, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, maskAny(err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		var errResp struct {
+			ErrorMessage string `json:"errorMessage"`
+		}
+		if json.Unmarshal(content, &errResp); errResp.ErrorMessage != "" {
+			return nil, maskAny(fmt.Errorf("Invalid status %d from %s: %s", resp.StatusCode, serverType, errResp.ErrorMessage))
+		}
+		return nil, maskAny(fmt.Errorf("Invalid status %d from %s", resp.StatusCode, serverType))
+	}
+	return content, nil
+}
@@ -0,0 +1,112 @@
+//
+// DISCLAIMER
+//
+// Copyright 2017 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+// Author Ewout Prangsma
+//
+
+package service
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	// DefaultServerDirTemplate is the name of the directory of every server when no other template is configured.
+	DefaultServerDirTemplate = "{type}{port}"
+)
+
+var (
+	serverDirPlaceholderPattern = regexp.MustCompile(`\{[^}]*\}`)
+)
+
+// ValidateServerDirTemplate checks the given template of the names of server directories.
+// The names must be unique for all servers that can share a directory, so the template
+// must contain {port}, or both {type} and {id}.
+func ValidateServerDirTemplate(template string) error {
+	if template == "" {
+		return nil
+	}
+	for _, placeholder := range serverDirPlaceholderPattern.FindAllString(template, -1) {
+		switch placeholder {
+		case "{type}", "{port}", "{id}":
+			// Ok
+		default:
+			return maskAny(fmt.Errorf("Unknown placeholder %s, expected {type}, {port} or {id}", placeholder))
+		}
+	}
+	if strings.ContainsAny(template, `/\`) || template == "." || template == ".." {
+		return maskAny(fmt.Errorf("Template must be a single directory name"))
+	}
+	if !strings.Contains(template, "{port}") && !(strings.Contains(template, "{type}") && strings.Contains(template, "{id}")) {
+		return maskAny(fmt.Errorf("Template must contain {port}, or both {type} and {id}, to give every server its own directory"))
+	}
+	return nil
+}
+
+// serverDirName returns the name of the directory of the server of given type that listens on the given port.
+func (s *Service) serverDirName(serverType ServerType, port int) string {
+	template := s.ServerDirTemplate
+	if template == "" {
+		template = DefaultServerDirTemplate
+	}
+	return strings.NewReplacer(
+		"{type}", string(serverType),
+		"{port}", strconv.Itoa(port),
+		"{id}", s.ID,
+	).Replace(template)
+}
+
+// serverBaseDir returns the directory that contains the directory of the server of given type.
+func (s *Service) serverBaseDir(serverType ServerType) string {
+	if dir := s.ServerDirs[serverType]; dir != "" {
+		return dir
+	}
+	return s.DataDir
+}
+
+// checkServerDirLayout checks that no data of a server is left in its default directory
+// when the configured directory layout places it elsewhere.
+// Such data would otherwise silently be ignored, while a new, empty server is started.
+func (s *Service) checkServerDirLayout() {
+	for _, serverType := range []ServerType{ServerTypeAgent, ServerTypeDBServer, ServerTypeCoordinator, ServerTypeSingle} {
+		myPort, err := s.serverPort(serverType)
+		if err != nil {
+			return
+		}
+		dir, err := s.serverHostDir(serverType)
+		if err != nil {
+			return
+		}
+		defaultDir := filepath.Join(s.DataDir, fmt.Sprintf("%s%d", serverType, myPort))
+		if dir == defaultDir {
+			continue
+		}
+		if _, err := os.Stat(dir); !os.IsNotExist(err) {
+			continue
+		}
+		if _, err := os.Stat(defaultDir); err == nil {
+			s.log.Fatalf("Data of %s found in %s, but the directory layout (--server.dir-template & --<type>s.dir) places it in %s. Move the data or use the previous layout.", serverType, defaultDir, dir)
+		}
+	}
+}
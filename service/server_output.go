@@ -0,0 +1,102 @@
+//
+// DISCLAIMER
+//
+// Copyright 2017 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+// Author Ewout Prangsma
+//
+
+package service
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// OutputStreamStdout identifies the standard output of a server.
+	OutputStreamStdout = "stdout"
+	// OutputStreamStderr identifies the standard error of a server.
+	OutputStreamStderr = "stderr"
+
+	outputFileTimeFormat = "20060102T150405.000Z"
+	maxOutputFiles       = 5 // Number of output files kept per stream
+)
+
+// outputFileName returns the name of the file capturing the given stream of a process started at the given time.
+func outputFileName(stream string, startTime time.Time) string {
+	return fmt.Sprintf("%s-%s.log", stream, startTime.UTC().Format(outputFileTimeFormat))
+}
+
+// outputFiles returns the paths of all files in the given directory that capture the given stream,
+// oldest first.
+func outputFiles(dir, stream string) ([]string, error) {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, maskAny(err)
+	}
+	var result []string
+	for _, e := range entries {
+		if name := e.Name(); strings.HasPrefix(name, stream+"-") && strings.HasSuffix(name, ".log") && !e.IsDir() {
+			result = append(result, filepath.Join(dir, name))
+		}
+	}
+	// The names contain the start time, so sorting them sorts by time
+	sort.Strings(result)
+	return result, nil
+}
+
+// latestOutputFile returns the path of the most recent file in the given directory that captures
+// the given stream, or an empty string when there is no such file.
+func latestOutputFile(dir, stream string) (string, error) {
+	files, err := outputFiles(dir, stream)
+	if err != nil {
+		return "", maskAny(err)
+	}
+	if len(files) == 0 {
+		return "", nil
+	}
+	return files[len(files)-1], nil
+}
+
+// createOutputFiles creates new files in the given directory that capture the stdout & stderr
+// of a process that is started now. Older output files are removed, keeping the most recent ones.
+func createOutputFiles(dir string) (stdout, stderr *os.File, err error) {
+	now := time.Now()
+	for _, stream := range []string{OutputStreamStdout, OutputStreamStderr} {
+		if files, err := outputFiles(dir, stream); err == nil && len(files) >= maxOutputFiles {
+			for _, path := range files[:len(files)-maxOutputFiles+1] {
+				os.Remove(path)
+			}
+		}
+	}
+	stdout, err = os.Create(filepath.Join(dir, outputFileName(OutputStreamStdout, now)))
+	if err != nil {
+		return nil, nil, maskAny(err)
+	}
+	stderr, err = os.Create(filepath.Join(dir, outputFileName(OutputStreamStderr, now)))
+	if err != nil {
+		stdout.Close()
+		return nil, nil, maskAny(err)
+	}
+	return stdout, stderr, nil
+}
@@ -23,25 +23,177 @@
 package service
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"fmt"
 	"io/ioutil"
+	"net"
+	"os"
 	"path/filepath"
+	"strconv"
 	"sync"
+
+	"github.com/pkg/errors"
 )
 
 const (
 	// SetupConfigVersion is the semantic version of the process that created this.
-	// If the structure of SetupConfigFile (or any underlying fields) or its semantics change, you must increase this version.
-	SetupConfigVersion = "0.2.1"
+	// If the structure of SetupConfigFile (or any underlying fields) or its semantics change, you must increase this version
+	// and add a migration from the previous version to `setupMigrations`.
+	SetupConfigVersion = "0.2.3"
 	setupFileName      = "setup.json"
+	setupBackupCount   = 3 // Number of previous (valid) setup files that are kept as setup.json.1 .. setup.json.N
 )
 
 // SetupConfigFile is the JSON structure stored in the setup file of this process.
 type SetupConfigFile struct {
-	Version          string `json:"version"` // Version of the process that created this. If the structure or semantics changed, you must increase this version.
-	ID               string `json:"id"`      // My unique peer ID
-	Peers            peers  `json:"peers"`
-	StartLocalSlaves bool   `json:"start-local-slaves,omitempty"`
+	Version          string            `json:"version"` // Version of the process that created this. If the structure or semantics changed, you must increase this version.
+	ID               string            `json:"id"`      // My unique peer ID
+	Peers            peers             `json:"peers"`
+	StartLocalSlaves bool              `json:"start-local-slaves,omitempty"`
+	LocalPortLayout  *PortLayout       `json:"local-port-layout,omitempty"` // Port offsets of local slaves
+	LocalServers     *LocalClusterSize `json:"local-servers,omitempty"`     // Number of servers of each type in a local test cluster
+	InputDigests     map[string]string `json:"input-digests,omitempty"`     // Digests of all external inputs (strict reproducibility mode)
+	ServerBinary     string            `json:"server-binary,omitempty"`     // Digest of the arangod executable (or ID of the docker image) the databases have been upgraded for
+	ServerVersions   map[string]string `json:"server-versions,omitempty"`   // Versions last reported by the servers of this starter, by server type
+	UpgradedBinaries ServerBinaries    `json:"upgraded-binaries,omitempty"` // Binaries (or docker images) servers have been upgraded to by a rolling upgrade, by server type
+	AppliedManifest  string            `json:"applied-manifest,omitempty"`  // Digest of the bootstrap manifest that has been applied to the deployment
+	ExecutedScripts  []string          `json:"executed-scripts,omitempty"`  // Digests of the bootstrap scripts that have been executed successfully
+	ImportedFiles    []string          `json:"imported-files,omitempty"`    // Database, collection & digest of the files that have been imported successfully
+	Checksum         string            `json:"checksum,omitempty"`          // SHA256 of the content of this file (with an empty checksum)
+
+	migratedFrom string // Version of the setup file before it has been migrated (if migrated)
+	source       string // Path of the file this setup has been read from
+}
+
+// setupFilePaths returns the paths of the setup file in the given directory and all its backups, newest first.
+func setupFilePaths(dataDir string) []string {
+	path := filepath.Join(dataDir, setupFileName)
+	result := []string{path}
+	for i := 1; i <= setupBackupCount; i++ {
+		result = append(result, path+"."+strconv.Itoa(i))
+	}
+	return result
+}
+
+// setupChecksum returns the checksum of the given setup.
+func setupChecksum(cfg SetupConfigFile) (string, error) {
+	cfg.Checksum = ""
+	b, err := json.Marshal(cfg)
+	if err != nil {
+		return "", maskAny(err)
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// readSetupFile reads and verifies the setup file at given path.
+// The file is not migrated, the version of the returned setup can be outdated.
+func readSetupFile(path string) ([]byte, SetupConfigFile, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, SetupConfigFile{}, maskAny(err)
+	}
+	var cfg SetupConfigFile
+	if err := json.Unmarshal(content, &cfg); err != nil {
+		return nil, SetupConfigFile{}, maskAny(err)
+	}
+	if cfg.Checksum != "" && cfg.Version == SetupConfigVersion {
+		expected, err := setupChecksum(cfg)
+		if err != nil {
+			return nil, SetupConfigFile{}, maskAny(err)
+		}
+		if cfg.Checksum != expected {
+			return nil, SetupConfigFile{}, maskAny(fmt.Errorf("checksum mismatch"))
+		}
+	}
+	cfg.source = path
+	return content, cfg, nil
+}
+
+// ReadSetupFile reads the setup file in the given data directory. When that file is missing or
+// invalid, the newest valid backup of it is read instead.
+// Returns the setup, the content and the path of the file it has been read from.
+// The setup is not migrated, its version can be outdated.
+func ReadSetupFile(dataDir string) (SetupConfigFile, []byte, string, error) {
+	var firstErr error
+	for _, path := range setupFilePaths(dataDir) {
+		content, cfg, err := readSetupFile(path)
+		if err == nil {
+			return cfg, content, path, nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	return SetupConfigFile{}, nil, "", maskAny(firstErr)
+}
+
+// writeSetupFile writes the given setup (with checksum) atomically into the given data directory.
+// The previous setup file (if valid) is kept as the newest backup.
+func writeSetupFile(dataDir string, cfg SetupConfigFile) error {
+	checksum, err := setupChecksum(cfg)
+	if err != nil {
+		return maskAny(err)
+	}
+	cfg.Checksum = checksum
+	b, err := json.Marshal(cfg)
+	if err != nil {
+		return maskAny(err)
+	}
+
+	// Write a temporary file first
+	paths := setupFilePaths(dataDir)
+	tmpPath := paths[0] + ".tmp"
+	f, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return maskAny(err)
+	}
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		return maskAny(err)
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return maskAny(err)
+	}
+	if err := f.Close(); err != nil {
+		return maskAny(err)
+	}
+
+	// Rotate backups, keeping only valid setup files
+	if _, _, err := readSetupFile(paths[0]); err == nil {
+		for i := len(paths) - 1; i > 1; i-- {
+			if _, err := os.Stat(paths[i-1]); err == nil {
+				os.Rename(paths[i-1], paths[i])
+			}
+		}
+		if err := os.Rename(paths[0], paths[1]); err != nil {
+			return maskAny(err)
+		}
+	}
+
+	// Replace the setup file
+	if err := os.Rename(tmpPath, paths[0]); err != nil {
+		return maskAny(err)
+	}
+	if dir, err := os.Open(dataDir); err == nil {
+		dir.Sync()
+		dir.Close()
+	}
+	return nil
+}
+
+// removeSetupFiles removes the setup file in the given data directory and all its backups.
+func removeSetupFiles(dataDir string) error {
+	var firstErr error
+	for _, path := range append(setupFilePaths(dataDir), filepath.Join(dataDir, setupFileName+".tmp")) {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return maskAny(firstErr)
 }
 
 // saveSetup saves the current peer configuration to disk.
@@ -51,13 +203,25 @@ func (s *Service) saveSetup() error {
 		ID:               s.ID,
 		Peers:            s.myPeers,
 		StartLocalSlaves: s.StartLocalSlaves,
+		InputDigests:     s.inputDigests,
+		ServerBinary:     s.recordedBinary,
+		ServerVersions:   s.serverVersions.get(),
+		UpgradedBinaries: s.upgradedBinaries.getAll(),
+		AppliedManifest:  s.appliedManifest,
+		ExecutedScripts:  s.executedScripts,
+		ImportedFiles:    s.importedFiles,
 	}
-	b, err := json.Marshal(cfg)
-	if err != nil {
-		s.log.Errorf("Cannot serialize config: %#v", err)
-		return maskAny(err)
+	if s.StartLocalSlaves || s.LocalPortLayout.Increment > 0 {
+		layout := s.portLayout()
+		cfg.LocalPortLayout = &layout
 	}
-	if err := ioutil.WriteFile(filepath.Join(s.DataDir, setupFileName), b, 0644); err != nil {
+	if s.LocalServers.IsSet() {
+		counts := s.LocalServers
+		cfg.LocalServers = &counts
+	}
+	s.setupMutex.Lock()
+	defer s.setupMutex.Unlock()
+	if err := writeSetupFile(s.DataDir, cfg); err != nil {
 		s.log.Errorf("Error writing setup: %#v", err)
 		return maskAny(err)
 	}
@@ -68,28 +232,84 @@ func (s *Service) saveSetup() error {
 // Returns true on relaunch or false to continue with a fresh start.
 func (s *Service) relaunch(runner Runner) bool {
 	// Is this a new start or a restart?
-	if setupContent, err := ioutil.ReadFile(filepath.Join(s.DataDir, setupFileName)); err == nil {
-		// Could read file
-		var cfg SetupConfigFile
-		if err := json.Unmarshal(setupContent, &cfg); err == nil {
-			if cfg.Version == SetupConfigVersion {
-				s.myPeers = cfg.Peers
-				s.ID = cfg.ID
-				s.AgencySize = s.myPeers.AgencySize
-				s.log.Infof("Relaunching service with id '%s' on %s:%d...", s.ID, s.OwnAddress, s.announcePort)
-				s.startHTTPServer()
-				wg := &sync.WaitGroup{}
-				if cfg.StartLocalSlaves {
-					s.startLocalSlaves(wg, cfg.Peers.Peers)
-				}
-				s.startRunning(runner)
-				wg.Wait()
-				return true
+	cfg, found := s.readSetup()
+	if !found {
+		return false
+	}
+	s.myPeers = cfg.Peers
+	s.ID = cfg.ID
+	s.initLoggers()
+	s.AgencySize = s.myPeers.AgencySize
+	s.checkRecordedInputs(cfg.InputDigests)
+	s.checkRecordedServerBinary(cfg.ServerBinary)
+	s.checkRecordedServerVersions(cfg.ServerVersions)
+	s.checkRecordedUpgradedBinaries(cfg.UpgradedBinaries)
+	s.appliedManifest = cfg.AppliedManifest
+	s.executedScripts = cfg.ExecutedScripts
+	s.importedFiles = cfg.ImportedFiles
+	s.checkRecordedStorageEngine()
+	s.checkRecordedPortLayout(cfg.LocalPortLayout)
+	s.checkRecordedLocalServers(cfg.LocalServers)
+	s.checkServerDirLayout()
+	s.applyOwnAddressChange()
+	if cfg.migratedFrom != "" {
+		// Keep the original setup file
+		setupPath := filepath.Join(s.DataDir, setupFileName)
+		if content, err := ioutil.ReadFile(cfg.source); err == nil {
+			if err := ioutil.WriteFile(setupPath+".v"+cfg.migratedFrom, content, 0644); err != nil {
+				s.log.Warningf("Failed to keep a copy of %s: %v", setupFileName, err)
+			}
+		}
+	}
+	s.saveSetup()
+	s.log.Infof("Relaunching service with id '%s' on %s...", s.ID, net.JoinHostPort(s.OwnAddress, strconv.Itoa(s.announcePort)))
+	if s.RecoveryFromBackup != "" {
+		s.log.Warningf("Ignoring --recovery.from-backup, the deployment has been recovered (or started) before")
+	}
+	if s.RootPassword != "" {
+		s.log.Infof("Ignoring --auth.root-password, the root password is only set when the deployment is bootstrapped")
+	}
+	relaunchSpan := s.bootstrapSpan.child("relaunch", map[string]string{"peer-id": s.ID})
+	s.startHTTPServer()
+	if !s.isMaster() && !s.isLocalSlave {
+		s.announceOwnAddress()
+	}
+	wg := &sync.WaitGroup{}
+	if cfg.StartLocalSlaves {
+		s.startLocalSlaves(wg, cfg.Peers.Peers)
+	}
+	relaunchSpan.finish(nil)
+	s.startRunning(runner)
+	wg.Wait()
+	return true
+}
+
+// readSetup reads the setup file from the data directory, migrating it when it has been created by an older starter.
+// When the setup file is invalid (e.g. partially written), the newest valid backup of it is used.
+// Returns false if there is no setup file. A setup file that cannot be used is a fatal error.
+func (s *Service) readSetup() (SetupConfigFile, bool) {
+	cfg, setupContent, path, err := ReadSetupFile(s.DataDir)
+	if err != nil {
+		if os.IsNotExist(errors.Cause(err)) {
+			if newDir, moved := readMovedInfo(s.DataDir); moved {
+				s.log.Fatalf("Data directory %s has been moved to %s, use `--starter.data-dir=%s`", s.DataDir, newDir, newDir)
 			}
-			s.log.Warningf("%s is outdated. Starting fresh...", setupFileName)
-		} else {
-			s.log.Warningf("Failed to unmarshal existing %s: %#v", setupFileName, err)
+			return SetupConfigFile{}, false
+		}
+		s.log.Fatalf("Failed to read existing %s and no valid backup of it is found: %v", setupFileName, err)
+	}
+	if filepath.Base(path) != setupFileName {
+		s.log.Warningf("%s is missing or invalid, using its backup %s", setupFileName, path)
+	}
+	if cfg.Version != SetupConfigVersion {
+		version := cfg.Version
+		s.log.Infof("Migrating %s from version %s to %s", setupFileName, version, SetupConfigVersion)
+		migrated, err := migrateSetup(setupContent, version, setupMigrationContext{IsSecure: s.IsSecure()})
+		if err != nil {
+			s.log.Fatalf("Cannot use existing %s: %v", setupFileName, err)
 		}
+		cfg = migrated
+		cfg.migratedFrom = version
 	}
-	return false
+	return cfg, true
 }
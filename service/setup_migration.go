@@ -0,0 +1,179 @@
+//
+// DISCLAIMER
+//
+// Copyright 2017 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+// Author Ewout Prangsma
+//
+
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// setupMigration converts a setup file from one version to the next.
+type setupMigration struct {
+	From    string
+	To      string
+	Migrate func(raw map[string]interface{}, ctx setupMigrationContext) error
+}
+
+// setupMigrationContext holds the configuration of the starter that is needed
+// to fill in fields that older setup files do not have.
+type setupMigrationContext struct {
+	IsSecure bool // If set, the servers of this starter use SSL
+}
+
+// setupMigrations holds all supported migrations, oldest first.
+// When SetupConfigVersion is increased, add a migration from the previous version.
+var setupMigrations = []setupMigration{
+	{From: "0.2.0", To: "0.2.1", Migrate: migrateSetup020To021},
+	{From: "0.2.1", To: "0.2.2", Migrate: migrateSetup021To022},
+	{From: "0.2.2", To: "0.2.3", Migrate: migrateSetup022To023},
+}
+
+// incompatibleSetupVersions holds the versions of setup files that cannot be migrated, with the reason.
+var incompatibleSetupVersions = map[string]string{
+	"0.1.0": "it does not contain the IDs of the peers",
+}
+
+// migrateSetup020To021 adds the IsSecure field of all peers.
+// Peers of a 0.2.0 setup use SSL if and only if this starter is configured to use SSL.
+func migrateSetup020To021(raw map[string]interface{}, ctx setupMigrationContext) error {
+	peerList, err := rawSetupPeers(raw)
+	if err != nil {
+		return maskAny(err)
+	}
+	for _, p := range peerList {
+		if _, found := p["IsSecure"]; !found {
+			p["IsSecure"] = ctx.IsSecure
+		}
+	}
+	return nil
+}
+
+// migrateSetup021To022 adds the StorageEngine field of the peers.
+// A 0.2.1 setup does not record the storage engine, so the field is left empty. It is filled in
+// on relaunch, from the database directories of the servers.
+func migrateSetup021To022(raw map[string]interface{}, ctx setupMigrationContext) error {
+	if _, found := raw["peers"].(map[string]interface{}); !found {
+		return maskAny(fmt.Errorf("peers missing"))
+	}
+	return nil
+}
+
+// migrateSetup022To023 adds the IsPassive field of all peers.
+// Passive peers did not exist before 0.2.3.
+func migrateSetup022To023(raw map[string]interface{}, ctx setupMigrationContext) error {
+	peerList, err := rawSetupPeers(raw)
+	if err != nil {
+		return maskAny(err)
+	}
+	for _, p := range peerList {
+		if _, found := p["IsPassive"]; !found {
+			p["IsPassive"] = false
+		}
+	}
+	return nil
+}
+
+// rawSetupPeers returns the peers of the given (raw) setup file.
+func rawSetupPeers(raw map[string]interface{}) ([]map[string]interface{}, error) {
+	rawPeers, ok := raw["peers"].(map[string]interface{})
+	if !ok {
+		return nil, maskAny(fmt.Errorf("peers missing"))
+	}
+	list, ok := rawPeers["Peers"].([]interface{})
+	if !ok {
+		return nil, maskAny(fmt.Errorf("peers.Peers missing"))
+	}
+	result := make([]map[string]interface{}, 0, len(list))
+	for _, x := range list {
+		p, ok := x.(map[string]interface{})
+		if !ok {
+			return nil, maskAny(fmt.Errorf("invalid peer"))
+		}
+		result = append(result, p)
+	}
+	return result, nil
+}
+
+// CheckSetupVersion returns nil if a setup file of given version can be used by this starter,
+// either directly or after migrating it.
+func CheckSetupVersion(version string) error {
+	if version == SetupConfigVersion {
+		return nil
+	}
+	if _, err := setupMigrationPath(version); err != nil {
+		return maskAny(err)
+	}
+	return nil
+}
+
+// setupMigrationPath returns the migrations needed to convert a setup file of given version
+// into the current version.
+func setupMigrationPath(version string) ([]setupMigration, error) {
+	if reason, found := incompatibleSetupVersions[version]; found {
+		return nil, maskAny(fmt.Errorf("%s has version '%s', which cannot be migrated because %s", setupFileName, version, reason))
+	}
+	original := version
+	var path []setupMigration
+	for version != SetupConfigVersion {
+		found := false
+		for _, m := range setupMigrations {
+			if m.From == version {
+				path = append(path, m)
+				version = m.To
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, maskAny(fmt.Errorf("%s has version '%s', which cannot be migrated to version %s (created by a starter that is too old or too new)", setupFileName, original, SetupConfigVersion))
+		}
+	}
+	return path, nil
+}
+
+// migrateSetup converts the given content of a setup file of given version into the current version.
+func migrateSetup(content []byte, version string, ctx setupMigrationContext) (SetupConfigFile, error) {
+	path, err := setupMigrationPath(version)
+	if err != nil {
+		return SetupConfigFile{}, maskAny(err)
+	}
+	var raw map[string]interface{}
+	if err := json.Unmarshal(content, &raw); err != nil {
+		return SetupConfigFile{}, maskAny(err)
+	}
+	for _, m := range path {
+		if err := m.Migrate(raw, ctx); err != nil {
+			return SetupConfigFile{}, maskAny(fmt.Errorf("Failed to migrate %s from version %s to %s: %v", setupFileName, m.From, m.To, err))
+		}
+		raw["version"] = m.To
+	}
+	migrated, err := json.Marshal(raw)
+	if err != nil {
+		return SetupConfigFile{}, maskAny(err)
+	}
+	var cfg SetupConfigFile
+	if err := json.Unmarshal(migrated, &cfg); err != nil {
+		return SetupConfigFile{}, maskAny(err)
+	}
+	return cfg, nil
+}
@@ -39,27 +39,41 @@ func (s *Service) startSlave(peerAddress string, runner Runner) {
 	if host, port, err := net.SplitHostPort(peerAddress); err == nil {
 		peerAddress = host
 		masterPort, _ = strconv.Atoi(port)
+	} else {
+		// No port given, IPv6 literals may be enclosed in brackets
+		peerAddress = trimIPv6Brackets(peerAddress)
 	}
+	joinSpan := s.bootstrapSpan.child("join master", map[string]string{"master": net.JoinHostPort(peerAddress, strconv.Itoa(masterPort))})
 	for {
 		masterAddr := net.JoinHostPort(peerAddress, strconv.Itoa(masterPort))
-		s.log.Infof("Contacting master %s...", masterAddr)
+		s.peersLog.Infof("Contacting master %s...", masterAddr)
 		_, hostPort, err := s.getHTTPServerPort()
 		if err != nil {
-			s.log.Fatalf("Failed to get HTTP server port: %#v", err)
+			s.peersLog.Fatalf("Failed to get HTTP server port: %#v", err)
+		}
+		now := time.Now()
+		var joinProof string
+		if token := s.joinToken(); token != "" {
+			joinProof = createJoinProof(token, s.ID, now)
 		}
 		b, _ := json.Marshal(HelloRequest{
-			DataDir:      s.DataDir,
-			SlaveID:      s.ID,
-			SlaveAddress: s.OwnAddress,
-			SlavePort:    hostPort,
-			IsSecure:     s.IsSecure(),
+			DataDir:       s.DataDir,
+			SlaveID:       s.ID,
+			SlaveAddress:  s.OwnAddress,
+			SlavePort:     hostPort,
+			IsSecure:      s.IsSecure(),
+			IsStandby:     s.Standby,
+			IsPassive:     s.Passive,
+			StorageEngine: s.ServerStorageEngine,
+			JoinProof:     joinProof,
+			Time:          now,
 		})
 		buf := bytes.Buffer{}
 		buf.Write(b)
 		scheme := NewURLSchemes(s.IsSecure()).Browser
 		r, e := httpClient.Post(fmt.Sprintf("%s://%s/hello", scheme, masterAddr), "application/json", &buf)
 		if e != nil {
-			s.log.Infof("Cannot start because of error from master: %v", e)
+			s.peersLog.Infof("Cannot start because of error from master: %v", e)
 			time.Sleep(time.Second)
 			continue
 		}
@@ -67,7 +81,7 @@ func (s *Service) startSlave(peerAddress string, runner Runner) {
 		body, e := ioutil.ReadAll(r.Body)
 		defer r.Body.Close()
 		if e != nil {
-			s.log.Infof("Cannot start because HTTP response from master was bad: %v", e)
+			s.peersLog.Infof("Cannot start because HTTP response from master was bad: %v", e)
 			time.Sleep(time.Second)
 			continue
 		}
@@ -75,24 +89,30 @@ func (s *Service) startSlave(peerAddress string, runner Runner) {
 		if r.StatusCode != http.StatusOK {
 			var errResp ErrorResponse
 			json.Unmarshal(body, &errResp)
-			s.log.Fatalf("Cannot start because of HTTP error from master: code=%d, message=%s\n", r.StatusCode, errResp.Error)
+			s.peersLog.Fatalf("Cannot start because of HTTP error from master: code=%d, message=%s\n", r.StatusCode, errResp.Error)
 		}
 		e = json.Unmarshal(body, &s.myPeers)
 		if e != nil {
-			s.log.Warningf("Cannot parse body from master: %v", e)
+			s.peersLog.Warningf("Cannot parse body from master: %v", e)
+			joinSpan.finish(e)
 			return
 		}
 		s.AgencySize = s.myPeers.AgencySize
+		// Now that our port offset is known, make sure the master knows our actual port.
+		if _, newHostPort, err := s.getHTTPServerPort(); err == nil && newHostPort != hostPort {
+			continue
+		}
 		break
 	}
+	joinSpan.finish(nil)
 
 	// Check HTTP server port
 	containerHTTPPort, _, err := s.getHTTPServerPort()
 	if err != nil {
-		s.log.Fatalf("Cannot find HTTP server info: %#v", err)
+		s.peersLog.Fatalf("Cannot find HTTP server info: %#v", err)
 	}
-	if !IsPortOpen(containerHTTPPort) {
-		s.log.Fatalf("Port %d is already in use", containerHTTPPort)
+	if !IsPortOpen(s.starterBindAddress(), containerHTTPPort) {
+		s.peersLog.Fatalf("Port %d is already in use", containerHTTPPort)
 	}
 
 	// Run the HTTP service so we can forward other clients
@@ -100,11 +120,13 @@ func (s *Service) startSlave(peerAddress string, runner Runner) {
 
 	// Wait until we can start:
 	if s.AgencySize > 1 {
-		s.log.Infof("Waiting for %d servers to show up...", s.AgencySize)
+		s.peersLog.Infof("Waiting for %d servers to show up...", s.AgencySize)
 	}
+	waitSpan := s.bootstrapSpan.child("wait for peers", map[string]string{"agency-size": strconv.Itoa(s.AgencySize)})
 	for {
-		if len(s.myPeers.Peers) >= s.AgencySize {
-			s.log.Infof("Serving as slave with ID '%s' on %s:%d...", s.ID, s.OwnAddress, s.announcePort)
+		if s.myPeers.AgentCount() >= s.AgencySize {
+			waitSpan.finish(nil)
+			s.peersLog.Infof("Serving as slave with ID '%s' on %s...", s.ID, net.JoinHostPort(s.OwnAddress, strconv.Itoa(s.announcePort)))
 			s.saveSetup()
 			s.startRunning(runner)
 			return
@@ -113,7 +135,7 @@ func (s *Service) startSlave(peerAddress string, runner Runner) {
 		master := s.myPeers.Peers[0]
 		r, err := httpClient.Get(master.CreateStarterURL("/hello"))
 		if err != nil {
-			s.log.Errorf("Failed to connect to master: %v", err)
+			s.peersLog.Errorf("Failed to connect to master: %v", err)
 			time.Sleep(time.Second * 2)
 		} else {
 			defer r.Body.Close()
@@ -133,13 +155,21 @@ func (s *Service) sendMasterGoodbye() error {
 		return nil
 	}
 	u := master.CreateStarterURL("/goodbye")
-	s.log.Infof("Saying goodbye to master at %s", u)
+	s.peersLog.Infof("Saying goodbye to master at %s", u)
 	req := GoodbyeRequest{SlaveID: s.ID}
 	data, err := json.Marshal(req)
 	if err != nil {
 		return maskAny(err)
 	}
-	resp, err := httpClient.Post(u, "application/json", bytes.NewReader(data))
+	httpReq, err := http.NewRequest("POST", u, bytes.NewReader(data))
+	if err != nil {
+		return maskAny(err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if err := addJwtHeader(httpReq, s.JwtSecret); err != nil {
+		return maskAny(err)
+	}
+	resp, err := httpClient.Do(httpReq)
 	if err != nil {
 		return maskAny(err)
 	}
@@ -0,0 +1,287 @@
+//
+// DISCLAIMER
+//
+// Copyright 2017 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+// Author Ewout Prangsma
+//
+
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	failedPeerCheckInterval = time.Second * 10 // Interval between checks for unreachable peers
+	followMasterInterval    = time.Second * 5  // Interval between fetches of the peers of the master
+)
+
+var (
+	errNoStandbyAvailable = errors.New("No standby peer available")
+)
+
+// ActivateStandbyRequest is the JSON body of a `/standby/activate` request.
+type ActivateStandbyRequest struct {
+	ID string `json:"id,omitempty"` // ID of the standby peer to activate. If empty, any standby peer is activated.
+}
+
+// activateStandbyHandler activates a standby peer, so it starts its servers.
+// This request must be handled by the master, other peers redirect it to the master.
+func (s *Service) activateStandbyHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		writeError(w, http.StatusMethodNotAllowed, "POST required")
+		return
+	}
+	if len(s.myPeers.Peers) == 0 {
+		writeError(w, http.StatusPreconditionFailed, "No master known.")
+		return
+	}
+	if master := s.myPeers.Peers[0]; master.ID != s.ID {
+		w.Header().Add("Location", master.CreateStarterURL("/standby/activate"))
+		w.WriteHeader(http.StatusTemporaryRedirect)
+		return
+	}
+
+	var req ActivateStandbyRequest
+	defer r.Body.Close()
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("Cannot read request body: %v", err.Error()))
+		return
+	}
+	if len(body) > 0 {
+		if err := json.Unmarshal(body, &req); err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("Cannot parse request body: %v", err.Error()))
+			return
+		}
+	}
+
+	standby, err := s.activateStandby(req.ID)
+	if errors.Cause(err) == errNoStandbyAvailable {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	} else if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	b, err := json.Marshal(standby)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+	} else {
+		w.Write(b)
+	}
+}
+
+// activateHandler handles an `/activate` request, send by the master to a standby peer
+// to make it start its servers.
+func (s *Service) activateHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		writeError(w, http.StatusMethodNotAllowed, "POST required")
+		return
+	}
+
+	// Claim exclusive access to our data structures
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	myPeer, found := s.myPeers.PeerByID(s.ID)
+	if !found {
+		writeError(w, http.StatusPreconditionFailed, "Not ready yet")
+		return
+	}
+	if myPeer.IsStandby {
+		myPeer.IsStandby = false
+		s.myPeers.UpdatePeerByID(myPeer)
+		if err := s.saveSetup(); err != nil {
+			s.peersLog.Errorf("Failed to save setup: %#v", err)
+		}
+		s.activateTrigger()
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("OK"))
+}
+
+// activateStandby turns the standby peer with given ID (or any standby peer if id is empty)
+// into a regular peer and tells it to start its servers.
+func (s *Service) activateStandby(id string) (Peer, error) {
+	s.mutex.Lock()
+	var standby Peer
+	found := false
+	for _, p := range s.myPeers.Peers {
+		if p.IsStandby && (id == "" || p.ID == id) {
+			standby = p
+			found = true
+			break
+		}
+	}
+	if !found {
+		s.mutex.Unlock()
+		return Peer{}, maskAny(errNoStandbyAvailable)
+	}
+	standby.IsStandby = false
+	s.myPeers.UpdatePeerByID(standby)
+	s.saveSetup()
+	s.mutex.Unlock()
+
+	s.peersLog.Infof("Activating standby peer '%s'", standby.ID)
+	restoreStandby := func() {
+		s.mutex.Lock()
+		defer s.mutex.Unlock()
+		standby.IsStandby = true
+		s.myPeers.UpdatePeerByID(standby)
+		s.saveSetup()
+	}
+	req, err := http.NewRequest("POST", standby.CreateStarterURL("/activate"), nil)
+	if err != nil {
+		restoreStandby()
+		return Peer{}, maskAny(err)
+	}
+	if err := addJwtHeader(req, s.JwtSecret); err != nil {
+		restoreStandby()
+		return Peer{}, maskAny(err)
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		restoreStandby()
+		return Peer{}, maskAny(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		restoreStandby()
+		return Peer{}, maskAny(fmt.Errorf("Invalid status %d", resp.StatusCode))
+	}
+	return standby, nil
+}
+
+// watchForFailedPeers activates a standby peer each time another peer has been
+// unreachable for longer than the configured failover delay.
+func (s *Service) watchForFailedPeers() {
+	lastSeen := make(map[string]time.Time)
+	replaced := make(map[string]bool)
+	for !s.stop {
+		if !s.isMaster() {
+			// Only the master activates standby peers
+			lastSeen = make(map[string]time.Time)
+			time.Sleep(failedPeerCheckInterval)
+			continue
+		}
+		s.mutex.Lock()
+		peerList := append([]Peer{}, s.myPeers.Peers...)
+		s.mutex.Unlock()
+
+		for _, p := range peerList {
+			if p.ID == s.ID || !p.HasServers() || replaced[p.ID] {
+				continue
+			}
+			if _, found := lastSeen[p.ID]; !found {
+				lastSeen[p.ID] = time.Now()
+			}
+			if resp, err := httpClient.Get(p.CreateStarterURL("/version")); err == nil {
+				resp.Body.Close()
+				lastSeen[p.ID] = time.Now()
+				continue
+			}
+			if downtime := time.Since(lastSeen[p.ID]); downtime > s.StandbyFailoverDelay {
+				s.peersLog.Warningf("Peer '%s' has been unreachable for %s", p.ID, downtime)
+				standby, err := s.activateStandby("")
+				if err != nil {
+					s.peersLog.Errorf("Cannot replace peer '%s': %v", p.ID, err)
+				} else {
+					s.peersLog.Infof("Peer '%s' has been replaced by standby peer '%s'", p.ID, standby.ID)
+					if s.isClusterMode() {
+						go s.removeFailedPeerServers(p)
+					}
+				}
+				// Do not try to replace the same peer again
+				replaced[p.ID] = true
+			}
+		}
+		time.Sleep(failedPeerCheckInterval)
+	}
+}
+
+// followMasterPeers keeps the peers of a starter that is not the master equal to those of the master,
+// so all starters learn about changes made by the master (e.g. an activated standby peer).
+func (s *Service) followMasterPeers() {
+	for !s.stop {
+		time.Sleep(followMasterInterval)
+		s.mutex.Lock()
+		if len(s.myPeers.Peers) == 0 || s.myPeers.Peers[0].ID == s.ID {
+			s.mutex.Unlock()
+			continue
+		}
+		master := s.myPeers.Peers[0]
+		s.mutex.Unlock()
+		s.syncPeersFromMaster(master)
+	}
+}
+
+// removeFailedPeerServers removes the dbserver & coordinator of the given unreachable peer from the cluster,
+// once the supervision of the cluster has moved all shards away from its dbserver.
+func (s *Service) removeFailedPeerServers(peer Peer) {
+	ctx, cancel := context.WithTimeout(s.ctx, replaceRequestTimeout)
+	health, err := s.clusterHealth(ctx)
+	cancel()
+	if err != nil {
+		s.peersLog.Warningf("Cannot find the servers of failed peer '%s': %v", peer.ID, err)
+		return
+	}
+	for id, h := range health {
+		var serverType ServerType
+		switch h.Role {
+		case "DBServer":
+			serverType = ServerTypeDBServer
+		case "Coordinator":
+			serverType = ServerTypeCoordinator
+		default:
+			continue
+		}
+		address := net.JoinHostPort(peer.Address, strconv.Itoa(peer.ServerPort(s.MasterPort, serverType)))
+		if endpoint, err := url.Parse(h.Endpoint); err != nil || endpoint.Host != address {
+			continue
+		}
+		if serverType == ServerTypeDBServer {
+			s.peersLog.Infof("Waiting until all shards have been moved away from dbserver '%s' of failed peer '%s'", id, peer.ID)
+			deadline := time.Now().Add(replaceResyncTimeout)
+			for {
+				ctx, cancel := context.WithTimeout(s.ctx, replaceRequestTimeout)
+				pending, err := s.pendingShards(ctx, id)
+				cancel()
+				if err == nil && pending == 0 {
+					break
+				}
+				if time.Now().After(deadline) || s.stop {
+					s.peersLog.Warningf("Shards still use dbserver '%s' of failed peer '%s', it is not removed from the cluster", id, peer.ID)
+					return
+				}
+				time.Sleep(replaceCheckInterval)
+			}
+		}
+		s.removeRetiredServer(id)
+	}
+}
@@ -0,0 +1,83 @@
+//
+// DISCLAIMER
+//
+// Copyright 2017 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+// Author Ewout Prangsma
+//
+
+package service
+
+import (
+	"context"
+	"time"
+)
+
+// waitForStartOrder waits until the server of given type may be started, according to
+// the configured start order (--cluster.start-sequential & --cluster.coordinator-wait-for-dbservers).
+// Returns false when the starter is stopping.
+func (s *Service) waitForStartOrder(serverType ServerType) bool {
+	if s.StartSequential {
+		// Wait for the servers of this starter that are started before the given one
+		var previous []ServerType
+		if s.needsAgent() && serverType != ServerTypeAgent {
+			previous = append(previous, ServerTypeAgent)
+		}
+		if s.StartDBserver && serverType == ServerTypeCoordinator {
+			previous = append(previous, ServerTypeDBServer)
+		}
+		for _, t := range previous {
+			s.log.Infof("Waiting for %s to be up before starting %s", t, serverType)
+			for !s.serverStates.get(t).Up {
+				if s.stop {
+					return false
+				}
+				time.Sleep(time.Second)
+			}
+		}
+	}
+	if serverType == ServerTypeCoordinator && s.CoordinatorDBServers > 0 {
+		s.log.Infof("Waiting for %d dbservers to be up before starting %s", s.CoordinatorDBServers, serverType)
+		for {
+			if s.stop {
+				return false
+			}
+			if up := s.dbserversUp(); up >= s.CoordinatorDBServers {
+				s.log.Infof("%d dbservers are up, starting %s", up, serverType)
+				break
+			}
+			time.Sleep(time.Second * 2)
+		}
+	}
+	return true
+}
+
+// dbserversUp returns the number of dbservers, started by any of the peers, that respond to requests.
+func (s *Service) dbserversUp() int {
+	up := 0
+	for _, p := range s.myPeers.Peers {
+		if !p.HasServers() {
+			continue
+		}
+		ctx, cancel := context.WithTimeout(s.ctx, time.Second*5)
+		if _, err := s.peerServerRequest(ctx, p, ServerTypeDBServer, "GET", "/_api/version", nil); err == nil {
+			up++
+		}
+		cancel()
+	}
+	return up
+}
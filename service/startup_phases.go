@@ -0,0 +1,233 @@
+//
+// DISCLAIMER
+//
+// Copyright 2017 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+// Author Ewout Prangsma
+//
+
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Names of the phases of the startup of a starter, in order.
+const (
+	phasePeerDiscovery     = "peer-discovery"     // Enough peers have joined to form the agency
+	phaseAgencyStart       = "agency-start"       // The agent of this starter is up
+	phaseAgencyReady       = "agency-ready"       // The agency has elected a leader
+	phaseDBServersReady    = "dbservers-ready"    // The dbserver of this starter is up
+	phaseCoordinatorsReady = "coordinators-ready" // The coordinator of this starter is up
+	phaseSingleReady       = "single-ready"       // The single server of this starter is up
+	phaseBootstrapDone     = "bootstrap-done"     // All servers of this starter are up
+)
+
+// StartupPhase holds the progress of a single phase of the startup of a starter.
+type StartupPhase struct {
+	Name     string `json:"name"`               // Name of the phase (e.g. agency-ready)
+	Done     bool   `json:"done,omitempty"`     // If set, the phase has finished
+	Duration string `json:"duration,omitempty"` // Time between the end of the previous phase and the end of this phase
+	Elapsed  string `json:"elapsed,omitempty"`  // Time between the start of the starter and the end of this phase
+}
+
+// startupPhases tracks the phases of the startup of a starter.
+type startupPhases struct {
+	mutex    sync.Mutex
+	start    time.Time
+	expected []string
+	done     map[string]time.Time
+}
+
+// begin records the start of the startup.
+func (sp *startupPhases) begin() {
+	sp.mutex.Lock()
+	defer sp.mutex.Unlock()
+	sp.start = time.Now()
+	sp.expected = []string{phasePeerDiscovery, phaseBootstrapDone}
+	sp.done = make(map[string]time.Time)
+}
+
+// expect sets the phases of the startup, based on the types of servers started by the starter.
+func (sp *startupPhases) expect(serverTypes []ServerType) {
+	sp.mutex.Lock()
+	defer sp.mutex.Unlock()
+	hasType := make(map[ServerType]bool)
+	for _, t := range serverTypes {
+		hasType[t] = true
+	}
+	expected := []string{phasePeerDiscovery}
+	if hasType[ServerTypeAgent] {
+		expected = append(expected, phaseAgencyStart)
+	}
+	if hasType[ServerTypeAgent] || hasType[ServerTypeDBServer] || hasType[ServerTypeCoordinator] {
+		expected = append(expected, phaseAgencyReady)
+	}
+	if hasType[ServerTypeDBServer] {
+		expected = append(expected, phaseDBServersReady)
+	}
+	if hasType[ServerTypeCoordinator] {
+		expected = append(expected, phaseCoordinatorsReady)
+	}
+	if hasType[ServerTypeSingle] {
+		expected = append(expected, phaseSingleReady)
+	}
+	sp.expected = append(expected, phaseBootstrapDone)
+}
+
+// finish records the end of the phase with given name.
+// Returns false if the phase had already finished.
+func (sp *startupPhases) finish(name string) (StartupPhase, bool) {
+	sp.mutex.Lock()
+	defer sp.mutex.Unlock()
+	if _, found := sp.done[name]; found || sp.done == nil {
+		return StartupPhase{}, false
+	}
+	sp.done[name] = time.Now()
+	for _, p := range sp.list() {
+		if p.Name == name {
+			return p, true
+		}
+	}
+	return StartupPhase{}, false
+}
+
+// get returns all phases of the startup.
+func (sp *startupPhases) get() []StartupPhase {
+	sp.mutex.Lock()
+	defer sp.mutex.Unlock()
+	return sp.list()
+}
+
+// elapsed returns the time between the start of the starter and the end of every finished phase.
+func (sp *startupPhases) elapsed() map[string]time.Duration {
+	sp.mutex.Lock()
+	defer sp.mutex.Unlock()
+	result := make(map[string]time.Duration)
+	for name, end := range sp.done {
+		result[name] = end.Sub(sp.start)
+	}
+	return result
+}
+
+// list returns all phases of the startup, assuming the mutex is held.
+// The duration of each phase is measured from the end of the phase that finished before it.
+func (sp *startupPhases) list() []StartupPhase {
+	var ends []time.Time
+	for _, t := range sp.done {
+		ends = append(ends, t)
+	}
+	sort.Slice(ends, func(i, j int) bool { return ends[i].Before(ends[j]) })
+	result := make([]StartupPhase, 0, len(sp.expected))
+	for _, name := range sp.expected {
+		p := StartupPhase{Name: name}
+		if end, found := sp.done[name]; found {
+			begin := sp.start
+			for _, t := range ends {
+				if t.Before(end) {
+					begin = t
+				}
+			}
+			p.Done = true
+			p.Duration = (end.Sub(begin) / time.Millisecond * time.Millisecond).String()
+			p.Elapsed = (end.Sub(sp.start) / time.Millisecond * time.Millisecond).String()
+		}
+		result = append(result, p)
+	}
+	return result
+}
+
+// finishStartupPhase records the end of the startup phase with given name and logs its duration.
+func (s *Service) finishStartupPhase(name string) {
+	if p, ok := s.startupPhases.finish(name); ok {
+		s.log.Info(newLogEvent("startup-phase", LogFields{"phase": p.Name, "duration": p.Duration, "elapsed": p.Elapsed},
+			"Startup phase %s finished in %s (%s since start)", p.Name, p.Duration, p.Elapsed))
+		s.notifySystemd(fmt.Sprintf("STATUS=Startup phase %s finished (%s since start)", p.Name, p.Elapsed))
+	}
+}
+
+// serverUpStartupPhase records the end of the startup phase that ends once the server of given type is up.
+func (s *Service) serverUpStartupPhase(serverType ServerType) {
+	switch serverType {
+	case ServerTypeAgent:
+		s.finishStartupPhase(phaseAgencyStart)
+	case ServerTypeDBServer:
+		s.finishStartupPhase(phaseDBServersReady)
+	case ServerTypeCoordinator:
+		s.finishStartupPhase(phaseCoordinatorsReady)
+	case ServerTypeSingle:
+		s.finishStartupPhase(phaseSingleReady)
+	}
+}
+
+// waitForAgencyLeader waits until the agency has elected a leader and marks the agency as ready.
+func (s *Service) waitForAgencyLeader() {
+	for !s.stop {
+		for _, p := range s.myPeers.Peers {
+			if !p.HasAgent {
+				continue
+			}
+			port := p.ServerPort(s.MasterPort, ServerTypeAgent)
+			ctx, cancel := context.WithTimeout(s.ctx, time.Second*5)
+			leader, err := s.agencyLeader(ctx, p.Address, port)
+			cancel()
+			if err == nil && leader != "" {
+				s.finishStartupPhase(phaseAgencyReady)
+				return
+			}
+		}
+		time.Sleep(time.Second)
+	}
+}
+
+// agencyLeader returns the ID of the leader of the agency, as known by the agent at given address & port.
+// Returns an empty string if no leader has been elected yet.
+func (s *Service) agencyLeader(ctx context.Context, address string, port int) (string, error) {
+	scheme := NewURLSchemes(s.IsSecure()).Browser
+	url := fmt.Sprintf("%s://%s/_api/agency/config", scheme, net.JoinHostPort(address, strconv.Itoa(port)))
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return "", maskAny(err)
+	}
+	req = req.WithContext(ctx)
+	if err := addJwtHeader(req, s.JwtSecret); err != nil {
+		return "", maskAny(err)
+	}
+	resp, err := serverHTTPClient.Do(req)
+	if err != nil {
+		return "", maskAny(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", maskAny(fmt.Errorf("Invalid status %d", resp.StatusCode))
+	}
+	var config struct {
+		LeaderID string `json:"leaderId"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&config); err != nil {
+		return "", maskAny(err)
+	}
+	return config.LeaderID, nil
+}
@@ -0,0 +1,141 @@
+//
+// DISCLAIMER
+//
+// Copyright 2017 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+// Author Ewout Prangsma
+//
+
+package service
+
+import (
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultStatsdPrefix is the prefix of the names of the metrics pushed to statsd.
+const DefaultStatsdPrefix = "arangodb.starter"
+
+const (
+	statsdMaxPacketSize   = 1432                // Maximum size of a UDP packet sent to statsd (fits the MTU of most networks)
+	defaultStatsdInterval = time.Second * 10    // Interval between pushes to statsd when no interval is configured
+	statsdStarterIDTag    = "starter-id"        // Tag holding the ID of the starter (with tags only)
+	metricNamePrefix      = "arangodb_starter_" // Prefix of all metric names, replaced by the statsd prefix
+)
+
+var (
+	statsdUnsafeChars = regexp.MustCompile(`[^a-zA-Z0-9_-]+`)
+)
+
+// statsdLines returns the metrics in the statsd line format. Gauges are sent as is, counters as the
+// increase since the last push (recorded in counters). With tags, labels are sent as DogStatsD tags,
+// otherwise the label values (and the ID of the starter) become part of the metric name.
+func (mw *metricsWriter) statsdLines(prefix, starterID string, tags bool, counters map[string]float64) []string {
+	var lines []string
+	for _, name := range mw.names {
+		f := mw.families[name]
+		if name == MetricStarterInfo && !tags {
+			// Only consists of labels
+			continue
+		}
+		shortName := strings.TrimSuffix(strings.TrimPrefix(name, metricNamePrefix), "_total")
+		for _, sample := range f.samples {
+			var key, suffix string
+			if tags {
+				key = prefix + "." + shortName
+				tagList := []string{statsdStarterIDTag + ":" + starterID}
+				for i := 0; i+1 < len(sample.labels); i += 2 {
+					tagList = append(tagList, sample.labels[i]+":"+sample.labels[i+1])
+				}
+				suffix = "|#" + strings.Join(tagList, ",")
+			} else {
+				key = prefix + "." + statsdUnsafeChars.ReplaceAllString(starterID, "_") + "." + shortName
+				for i := 0; i+1 < len(sample.labels); i += 2 {
+					key += "." + statsdUnsafeChars.ReplaceAllString(strings.Trim(sample.labels[i+1], "/"), "_")
+				}
+			}
+			if f.metricType == "counter" {
+				id := key + suffix
+				delta := sample.value - counters[id]
+				if delta < 0 {
+					// The counter has been reset
+					delta = sample.value
+				}
+				counters[id] = sample.value
+				if delta > 0 {
+					lines = append(lines, key+":"+strconv.FormatFloat(delta, 'f', -1, 64)+"|c"+suffix)
+				}
+				continue
+			}
+			lines = append(lines, key+":"+strconv.FormatFloat(sample.value, 'f', -1, 64)+"|g"+suffix)
+		}
+	}
+	return lines
+}
+
+// runStatsdPush pushes the metrics of the starter to statsd (over UDP) every MetricsStatsdInterval,
+// until the starter is stopped.
+func (s *Service) runStatsdPush() {
+	conn, err := net.Dial("udp", s.MetricsStatsd)
+	if err != nil {
+		s.log.Errorf("Cannot push metrics to statsd at %s: %v", s.MetricsStatsd, err)
+		return
+	}
+	defer conn.Close()
+	prefix := strings.TrimSuffix(s.MetricsStatsdPrefix, ".")
+	if prefix == "" {
+		prefix = DefaultStatsdPrefix
+	}
+	interval := s.MetricsStatsdInterval
+	if interval <= 0 {
+		interval = defaultStatsdInterval
+	}
+	s.log.Infof("Pushing metrics to statsd at %s every %s", s.MetricsStatsd, interval)
+
+	counters := make(map[string]float64)
+	for {
+		var packet []byte
+		flush := func() {
+			if len(packet) == 0 {
+				return
+			}
+			if _, err := conn.Write(packet); err != nil {
+				s.log.Debugf("Failed to push metrics to statsd: %v", err)
+			}
+			packet = packet[:0]
+		}
+		for _, line := range s.metrics().statsdLines(prefix, s.ID, s.MetricsStatsdTags, counters) {
+			if len(packet) > 0 && len(packet)+1+len(line) > statsdMaxPacketSize {
+				flush()
+			}
+			if len(packet) > 0 {
+				packet = append(packet, '\n')
+			}
+			packet = append(packet, line...)
+		}
+		flush()
+
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+	}
+}
@@ -0,0 +1,311 @@
+//
+// DISCLAIMER
+//
+// Copyright 2017 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+// Author Ewout Prangsma
+//
+
+package service
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// StatusResponse is the JSON response of a `/status` request.
+type StatusResponse struct {
+	ID      string         `json:"id"`                // Unique ID of the starter
+	Mode    string         `json:"mode"`              // Starter mode (cluster | single)
+	Version string         `json:"version"`           // Version of the starter
+	Build   string         `json:"build"`             // Build of the starter
+	Servers []ServerStatus `json:"servers,omitempty"` // Servers started by the starter
+	Peers   []PeerStatus   `json:"peers,omitempty"`   // All peers known by the starter
+	Startup []StartupPhase `json:"startup,omitempty"` // Phases of the startup of the starter
+}
+
+// ServerStatus holds the runtime status of a single server started by the starter.
+type ServerStatus struct {
+	ServerProcess
+	Up         bool   `json:"up"`                    // If set, the server is responding to requests
+	Version    string `json:"version,omitempty"`     // Version of the server (if known)
+	Restarts   int    `json:"restarts,omitempty"`    // Number of times the server has been restarted
+	Failed     bool   `json:"failed,omitempty"`      // If set, the server has failed and is no longer restarted (see `--server.restart-policy`)
+	FailReason string `json:"fail-reason,omitempty"` // Reason the server has failed
+}
+
+// PeerStatus holds the information of a single peer known by the starter.
+type PeerStatus struct {
+	ID        string `json:"id"`                   // Unique ID of the peer
+	Address   string `json:"address"`              // IP address of the peer
+	Port      int    `json:"port"`                 // Port of the starter on the peer
+	HasAgent  bool   `json:"has-agent,omitempty"`  // If set, the peer is running an agent
+	IsMaster  bool   `json:"is-master,omitempty"`  // If set, the peer is the master
+	IsSecure  bool   `json:"is-secure,omitempty"`  // If set, servers started by the peer are using an SSL connection
+	IsStandby bool   `json:"is-standby,omitempty"` // If set, the peer is a standby
+	IsPassive bool   `json:"is-passive,omitempty"` // If set, the peer is passive, it never runs servers
+}
+
+const (
+	maxRecordedRestarts = 1000 // Maximum number of restart times kept per server
+)
+
+// serverState holds the last known health of a server.
+type serverState struct {
+	Up           bool
+	Version      string
+	Starts       int         // Number of times the server has been found up
+	AutoUpgrade  bool        // If set, the server is started with `--database.auto-upgrade=true` on its next start
+	RestartReq   bool        // If set, the server has been terminated on request and must be restarted, regardless of the restart policy
+	UpgradeErr   string      // Error of the last database upgrade (if any)
+	Parked       bool        // If set, the server has stopped and waits until the data directory has been moved
+	Restarts     int         // Number of times the server has been restarted
+	Failed       bool        // If set, the server has failed and is no longer restarted
+	FailReason   string      // Reason the server has failed
+	CrashLoop    bool        // If set, the server has been restarted too often recently
+	RestartTimes []time.Time // Times of the most recent restarts of the server
+}
+
+// serverStates tracks the health of all servers started by this starter.
+type serverStates struct {
+	mutex  sync.Mutex
+	states map[ServerType]serverState
+}
+
+// setUp marks the server of given type as responding, running the given version.
+func (ss *serverStates) setUp(serverType ServerType, version string) {
+	ss.mutex.Lock()
+	defer ss.mutex.Unlock()
+	if ss.states == nil {
+		ss.states = make(map[ServerType]serverState)
+	}
+	state := ss.states[serverType]
+	state.Up = true
+	state.Version = version
+	state.Starts++
+	state.Failed = false
+	state.FailReason = ""
+	ss.states[serverType] = state
+}
+
+// setDown marks the server of given type as not responding.
+// The last known version is kept.
+func (ss *serverStates) setDown(serverType ServerType) {
+	ss.mutex.Lock()
+	defer ss.mutex.Unlock()
+	if state, found := ss.states[serverType]; found {
+		state.Up = false
+		ss.states[serverType] = state
+	}
+}
+
+// setFailed marks the server of given type as failed, it is no longer restarted.
+func (ss *serverStates) setFailed(serverType ServerType, reason string) {
+	ss.mutex.Lock()
+	defer ss.mutex.Unlock()
+	if ss.states == nil {
+		ss.states = make(map[ServerType]serverState)
+	}
+	state := ss.states[serverType]
+	state.Up = false
+	state.Failed = true
+	state.FailReason = reason
+	ss.states[serverType] = state
+}
+
+// addRestart increments the number of restarts of the server of given type.
+func (ss *serverStates) addRestart(serverType ServerType) {
+	ss.mutex.Lock()
+	defer ss.mutex.Unlock()
+	if ss.states == nil {
+		ss.states = make(map[ServerType]serverState)
+	}
+	state := ss.states[serverType]
+	state.Restarts++
+	state.RestartTimes = append(state.RestartTimes, time.Now())
+	if len(state.RestartTimes) > maxRecordedRestarts {
+		state.RestartTimes = state.RestartTimes[len(state.RestartTimes)-maxRecordedRestarts:]
+	}
+	ss.states[serverType] = state
+}
+
+// recentRestarts returns the number of restarts of the server of given type within the given window.
+func (ss *serverStates) recentRestarts(serverType ServerType, window time.Duration) int {
+	ss.mutex.Lock()
+	defer ss.mutex.Unlock()
+	since := time.Now().Add(-window)
+	count := 0
+	for _, t := range ss.states[serverType].RestartTimes {
+		if t.After(since) {
+			count++
+		}
+	}
+	return count
+}
+
+// setCrashLoop changes whether the server of given type is in a crash loop.
+// Returns true if that has changed.
+func (ss *serverStates) setCrashLoop(serverType ServerType, inLoop bool) bool {
+	ss.mutex.Lock()
+	defer ss.mutex.Unlock()
+	if ss.states == nil {
+		ss.states = make(map[ServerType]serverState)
+	}
+	state := ss.states[serverType]
+	if state.CrashLoop == inLoop {
+		return false
+	}
+	state.CrashLoop = inLoop
+	ss.states[serverType] = state
+	return true
+}
+
+// setParked marks the server of given type as waiting (or no longer waiting) for the data directory to be moved.
+func (ss *serverStates) setParked(serverType ServerType, parked bool) {
+	ss.mutex.Lock()
+	defer ss.mutex.Unlock()
+	if ss.states == nil {
+		ss.states = make(map[ServerType]serverState)
+	}
+	state := ss.states[serverType]
+	state.Parked = parked
+	ss.states[serverType] = state
+}
+
+// requestAutoUpgrade marks the server of given type to be started with
+// `--database.auto-upgrade=true` on its next start.
+func (ss *serverStates) requestAutoUpgrade(serverType ServerType) {
+	ss.mutex.Lock()
+	defer ss.mutex.Unlock()
+	if ss.states == nil {
+		ss.states = make(map[ServerType]serverState)
+	}
+	state := ss.states[serverType]
+	state.AutoUpgrade = true
+	state.UpgradeErr = ""
+	ss.states[serverType] = state
+}
+
+// setUpgradeFailed records the failure of a database upgrade of the server of given type.
+func (ss *serverStates) setUpgradeFailed(serverType ServerType, message string) {
+	ss.mutex.Lock()
+	defer ss.mutex.Unlock()
+	if state, found := ss.states[serverType]; found {
+		state.UpgradeErr = message
+		ss.states[serverType] = state
+	}
+}
+
+// requestRestart marks the server of given type to be restarted when it terminates,
+// regardless of the restart policy.
+func (ss *serverStates) requestRestart(serverType ServerType) {
+	ss.mutex.Lock()
+	defer ss.mutex.Unlock()
+	if ss.states == nil {
+		ss.states = make(map[ServerType]serverState)
+	}
+	state := ss.states[serverType]
+	state.RestartReq = true
+	ss.states[serverType] = state
+}
+
+// takeRestartRequest returns true if the server of given type has been terminated
+// on request and must be restarted, clearing that request.
+func (ss *serverStates) takeRestartRequest(serverType ServerType) bool {
+	ss.mutex.Lock()
+	defer ss.mutex.Unlock()
+	state, found := ss.states[serverType]
+	if !found || !state.RestartReq {
+		return false
+	}
+	state.RestartReq = false
+	ss.states[serverType] = state
+	return true
+}
+
+// takeAutoUpgrade returns true if the server of given type must be started
+// with `--database.auto-upgrade=true`, clearing that request.
+func (ss *serverStates) takeAutoUpgrade(serverType ServerType) bool {
+	ss.mutex.Lock()
+	defer ss.mutex.Unlock()
+	state, found := ss.states[serverType]
+	if !found || !state.AutoUpgrade {
+		return false
+	}
+	state.AutoUpgrade = false
+	ss.states[serverType] = state
+	return true
+}
+
+// get returns the last known state of the server of given type.
+func (ss *serverStates) get(serverType ServerType) serverState {
+	ss.mutex.Lock()
+	defer ss.mutex.Unlock()
+	return ss.states[serverType]
+}
+
+// statusHandler returns a JSON object describing the starter, its servers and its peers.
+func (s *Service) statusHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		writeError(w, http.StatusMethodNotAllowed, "GET required")
+		return
+	}
+
+	resp := StatusResponse{
+		ID:      s.ID,
+		Mode:    s.Mode,
+		Version: s.ProjectVersion,
+		Build:   s.ProjectBuild,
+		Startup: s.startupPhases.get(),
+	}
+	if myPeer, found := s.myPeers.PeerByID(s.ID); found {
+		for _, sp := range s.serverProcesses(myPeer) {
+			state := s.serverStates.get(ServerType(sp.Type))
+			resp.Servers = append(resp.Servers, ServerStatus{
+				ServerProcess: sp,
+				Up:            state.Up,
+				Version:       state.Version,
+				Restarts:      state.Restarts,
+				Failed:        state.Failed,
+				FailReason:    state.FailReason,
+			})
+		}
+	}
+	s.mutex.Lock()
+	for i, p := range s.myPeers.Peers {
+		resp.Peers = append(resp.Peers, PeerStatus{
+			ID:        p.ID,
+			Address:   p.Address,
+			Port:      p.Port,
+			HasAgent:  p.HasAgent,
+			IsMaster:  i == 0,
+			IsSecure:  p.IsSecure,
+			IsStandby: p.IsStandby,
+			IsPassive: p.IsPassive,
+		})
+	}
+	s.mutex.Unlock()
+
+	b, err := json.Marshal(resp)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+	} else {
+		w.Write(b)
+	}
+}
@@ -0,0 +1,77 @@
+//
+// DISCLAIMER
+//
+// Copyright 2017 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+// Author Ewout Prangsma
+//
+
+package service
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// engineFileName is the name of the file in the database directory of a server in which arangod records its storage engine.
+	engineFileName = "ENGINE"
+)
+
+// storageEngineMismatch returns a description of the problem when a peer configured with the given storage engine
+// tries to take part in a cluster using the recorded storage engine, or an empty string if both match.
+func storageEngineMismatch(recorded, configured string) string {
+	if recorded == "" || configured == "" || recorded == configured {
+		return ""
+	}
+	return fmt.Sprintf("Cannot mix storage engines: the deployment uses the %s storage engine, but the starter is configured with --server.storage-engine=%s. Use --server.storage-engine=%s on all peers.", recorded, configured, recorded)
+}
+
+// checkRecordedStorageEngine verifies that the storage engine recorded in the setup matches the configured one.
+// A setup without recorded storage engine (created by an older starter) gets the storage engine found in the
+// database directories of the servers, or else the configured one.
+func (s *Service) checkRecordedStorageEngine() {
+	if s.myPeers.StorageEngine == "" {
+		s.myPeers.StorageEngine = s.detectStorageEngine()
+		if s.myPeers.StorageEngine == "" {
+			s.myPeers.StorageEngine = s.ServerStorageEngine
+			return
+		}
+	}
+	if msg := storageEngineMismatch(s.myPeers.StorageEngine, s.ServerStorageEngine); msg != "" {
+		s.log.Fatal(msg)
+	}
+}
+
+// detectStorageEngine returns the storage engine recorded by arangod in the database directories of the servers
+// of this peer, or an empty string if there is none.
+func (s *Service) detectStorageEngine() string {
+	for _, serverType := range []ServerType{ServerTypeDBServer, ServerTypeSingle, ServerTypeAgent} {
+		dir, err := s.serverHostDir(serverType)
+		if err != nil {
+			continue
+		}
+		if content, err := ioutil.ReadFile(filepath.Join(dir, "data", engineFileName)); err == nil {
+			if engine := strings.TrimSpace(string(content)); engine != "" {
+				return engine
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,111 @@
+//
+// DISCLAIMER
+//
+// Copyright 2017 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+// Author Ewout Prangsma
+//
+
+package service
+
+import (
+	"context"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	systemdNotifySocketEnv = "NOTIFY_SOCKET"
+	systemdWatchdogUSecEnv = "WATCHDOG_USEC"
+	systemdWatchdogPIDEnv  = "WATCHDOG_PID"
+)
+
+// systemdNotifier sends notifications (see sd_notify(3)) to systemd, when the starter runs
+// as a unit with `Type=notify`.
+type systemdNotifier struct {
+	socket   string        // Path of the notification socket (empty if not started by systemd)
+	watchdog time.Duration // Interval in which systemd expects keep-alive pings (0 if the watchdog is disabled)
+}
+
+// newSystemdNotifier creates a notifier from the environment set by systemd.
+// The environment variables are removed, so the servers started by the starter do not inherit them.
+func newSystemdNotifier() systemdNotifier {
+	n := systemdNotifier{socket: os.Getenv(systemdNotifySocketEnv)}
+	if usec, err := strconv.ParseInt(os.Getenv(systemdWatchdogUSecEnv), 10, 64); err == nil && usec > 0 {
+		if pid := os.Getenv(systemdWatchdogPIDEnv); pid == "" || pid == strconv.Itoa(os.Getpid()) {
+			n.watchdog = time.Duration(usec) * time.Microsecond
+		}
+	}
+	os.Unsetenv(systemdNotifySocketEnv)
+	os.Unsetenv(systemdWatchdogUSecEnv)
+	os.Unsetenv(systemdWatchdogPIDEnv)
+	return n
+}
+
+// enabled returns true if the starter has been started by systemd with a notification socket.
+func (n systemdNotifier) enabled() bool {
+	return n.socket != ""
+}
+
+// notify sends the given state lines (e.g. `READY=1`) to systemd.
+// It does nothing when the starter has not been started by systemd.
+func (n systemdNotifier) notify(state ...string) error {
+	if !n.enabled() {
+		return nil
+	}
+	socket := n.socket
+	if strings.HasPrefix(socket, "@") {
+		// Abstract namespace socket
+		socket = "\x00" + socket[1:]
+	}
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		return maskAny(err)
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte(strings.Join(state, "\n"))); err != nil {
+		return maskAny(err)
+	}
+	return nil
+}
+
+// notifySystemd sends the given state lines to systemd (if the starter has been started by systemd).
+func (s *Service) notifySystemd(state ...string) {
+	if err := s.systemd.notify(state...); err != nil {
+		s.log.Debugf("Failed to notify systemd: %v", err)
+	}
+}
+
+// runSystemdWatchdog sends keep-alive pings to systemd at half of the watchdog interval,
+// until the given context is canceled.
+func (s *Service) runSystemdWatchdog(ctx context.Context) {
+	if !s.systemd.enabled() || s.systemd.watchdog == 0 {
+		return
+	}
+	s.log.Debugf("Sending systemd watchdog pings every %s", s.systemd.watchdog/2)
+	for {
+		s.notifySystemd("WATCHDOG=1")
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(s.systemd.watchdog / 2):
+		}
+	}
+}
@@ -0,0 +1,323 @@
+//
+// DISCLAIMER
+//
+// Copyright 2017 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+// Author Ewout Prangsma
+//
+
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	logging "github.com/op/go-logging"
+)
+
+const (
+	tracingFlushInterval = time.Second * 5 // Interval between exports of ended spans
+	tracingServiceName   = "arangodb-starter"
+)
+
+// tracer records spans of the phases of the starter and exports them to an
+// OpenTelemetry collector, using OTLP over HTTP with JSON encoding.
+// A nil tracer records nothing.
+type tracer struct {
+	log      *logging.Logger
+	endpoint string // URL of the traces endpoint of the collector
+	client   *http.Client
+	resource map[string]string
+	mutex    sync.Mutex
+	ended    []*span
+}
+
+// span is a single timed operation of a trace.
+// All methods of a nil span do nothing.
+type span struct {
+	tracer     *tracer
+	traceID    string
+	spanID     string
+	parentID   string
+	name       string
+	start      time.Time
+	mutex      sync.Mutex
+	end        time.Time
+	attributes map[string]string
+	errMsg     string
+}
+
+// newTracer creates a tracer that exports to the given OTLP/HTTP endpoint
+// (e.g. `http://localhost:4318`). Returns nil if the endpoint is empty.
+func newTracer(log *logging.Logger, endpoint string, resource map[string]string) *tracer {
+	if endpoint == "" {
+		return nil
+	}
+	endpoint = strings.TrimSuffix(endpoint, "/")
+	if !strings.HasSuffix(endpoint, "/v1/traces") {
+		endpoint = endpoint + "/v1/traces"
+	}
+	return &tracer{
+		log:      log,
+		endpoint: endpoint,
+		client:   &http.Client{Timeout: time.Second * 10},
+		resource: resource,
+	}
+}
+
+// startSpan starts a new root span.
+func (t *tracer) startSpan(name string, attributes map[string]string) *span {
+	if t == nil {
+		return nil
+	}
+	return t.newSpan(createTraceID(16), "", name, attributes)
+}
+
+// newSpan creates a new span in the trace with given ID.
+func (t *tracer) newSpan(traceID, parentID, name string, attributes map[string]string) *span {
+	attrs := make(map[string]string)
+	for k, v := range attributes {
+		attrs[k] = v
+	}
+	return &span{
+		tracer:     t,
+		traceID:    traceID,
+		spanID:     createTraceID(8),
+		parentID:   parentID,
+		name:       name,
+		start:      time.Now(),
+		attributes: attrs,
+	}
+}
+
+// child starts a new span as child of the given span.
+func (sp *span) child(name string, attributes map[string]string) *span {
+	if sp == nil {
+		return nil
+	}
+	return sp.tracer.newSpan(sp.traceID, sp.spanID, name, attributes)
+}
+
+// setAttribute sets an attribute of the span.
+func (sp *span) setAttribute(key, value string) {
+	if sp == nil {
+		return
+	}
+	sp.mutex.Lock()
+	defer sp.mutex.Unlock()
+	sp.attributes[key] = value
+}
+
+// finish ends the span (once) and queues it for export.
+// If the given error is not nil, the span is marked as failed.
+func (sp *span) finish(err error) {
+	if sp == nil {
+		return
+	}
+	sp.mutex.Lock()
+	if !sp.end.IsZero() {
+		sp.mutex.Unlock()
+		return
+	}
+	sp.end = time.Now()
+	if err != nil {
+		sp.errMsg = err.Error()
+	}
+	sp.mutex.Unlock()
+
+	sp.tracer.mutex.Lock()
+	defer sp.tracer.mutex.Unlock()
+	sp.tracer.ended = append(sp.tracer.ended, sp)
+}
+
+// run exports ended spans periodically, until the given context is canceled.
+func (t *tracer) run(ctx context.Context) {
+	if t == nil {
+		return
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			t.flush()
+			return
+		case <-time.After(tracingFlushInterval):
+			t.flush()
+		}
+	}
+}
+
+// flush exports all ended spans.
+func (t *tracer) flush() {
+	if t == nil {
+		return
+	}
+	t.mutex.Lock()
+	spans := t.ended
+	t.ended = nil
+	t.mutex.Unlock()
+	if len(spans) == 0 {
+		return
+	}
+	if err := t.export(spans); err != nil {
+		t.log.Warningf("Failed to export %d spans to %s: %v", len(spans), t.endpoint, err)
+	}
+}
+
+// otlpAttribute is the JSON encoding of an OTLP key/value pair.
+type otlpAttribute struct {
+	Key   string `json:"key"`
+	Value struct {
+		StringValue string `json:"stringValue"`
+	} `json:"value"`
+}
+
+func otlpAttributes(attributes map[string]string) []otlpAttribute {
+	result := make([]otlpAttribute, 0, len(attributes))
+	for k, v := range attributes {
+		a := otlpAttribute{Key: k}
+		a.Value.StringValue = v
+		result = append(result, a)
+	}
+	return result
+}
+
+// export sends the given spans to the collector.
+func (t *tracer) export(spans []*span) error {
+	type otlpStatus struct {
+		Code    int    `json:"code"` // 1=ok, 2=error
+		Message string `json:"message,omitempty"`
+	}
+	type otlpSpan struct {
+		TraceID           string          `json:"traceId"`
+		SpanID            string          `json:"spanId"`
+		ParentSpanID      string          `json:"parentSpanId,omitempty"`
+		Name              string          `json:"name"`
+		Kind              int             `json:"kind"` // 1=internal
+		StartTimeUnixNano string          `json:"startTimeUnixNano"`
+		EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+		Attributes        []otlpAttribute `json:"attributes,omitempty"`
+		Status            otlpStatus      `json:"status"`
+	}
+	encodedSpans := make([]otlpSpan, 0, len(spans))
+	for _, sp := range spans {
+		sp.mutex.Lock()
+		encodedSpan := otlpSpan{
+			TraceID:           sp.traceID,
+			SpanID:            sp.spanID,
+			ParentSpanID:      sp.parentID,
+			Name:              sp.name,
+			Kind:              1,
+			StartTimeUnixNano: strconv.FormatInt(sp.start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(sp.end.UnixNano(), 10),
+			Attributes:        otlpAttributes(sp.attributes),
+			Status:            otlpStatus{Code: 1},
+		}
+		if sp.errMsg != "" {
+			encodedSpan.Status = otlpStatus{Code: 2, Message: sp.errMsg}
+		}
+		sp.mutex.Unlock()
+		encodedSpans = append(encodedSpans, encodedSpan)
+	}
+	body := map[string]interface{}{
+		"resourceSpans": []interface{}{
+			map[string]interface{}{
+				"resource": map[string]interface{}{
+					"attributes": otlpAttributes(t.resource),
+				},
+				"scopeSpans": []interface{}{
+					map[string]interface{}{
+						"scope": map[string]string{"name": tracingServiceName},
+						"spans": encodedSpans,
+					},
+				},
+			},
+		},
+	}
+	encoded, err := json.Marshal(body)
+	if err != nil {
+		return maskAny(err)
+	}
+	resp, err := t.client.Post(t.endpoint, "application/json", bytes.NewReader(encoded))
+	if err != nil {
+		return maskAny(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return maskAny(fmt.Errorf("Invalid status %d", resp.StatusCode))
+	}
+	return nil
+}
+
+// createTraceID creates a random hex encoded ID of given length (in bytes).
+func createTraceID(length int) string {
+	b := make([]byte, length)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// startBootstrapTrace starts the root span of the bootstrap of this starter.
+func (s *Service) startBootstrapTrace() {
+	s.tracer = newTracer(s.log, s.TracingEndpoint, map[string]string{
+		"service.name":    tracingServiceName,
+		"service.version": s.ProjectVersion,
+	})
+	s.bootstrapSpan = s.tracer.startSpan("bootstrap", map[string]string{
+		"mode":    s.Mode,
+		"peer-id": s.ID,
+	})
+}
+
+// setBootstrapServers sets the types of servers that must be up before the bootstrap has finished.
+func (s *Service) setBootstrapServers(serverTypes []ServerType) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.bootstrapPending = make(map[ServerType]bool)
+	for _, t := range serverTypes {
+		s.bootstrapPending[t] = true
+	}
+	s.bootstrapSpan.setAttribute("peer-id", s.ID)
+	if len(serverTypes) == 0 {
+		s.bootstrapSpan.finish(nil)
+		s.finishStartupPhase(phaseBootstrapDone)
+		s.signalReady()
+	}
+}
+
+// bootstrapServerUp records that the server of given type is up.
+// Once all servers are up, the bootstrap span (and phase) is ended.
+func (s *Service) bootstrapServerUp(serverType ServerType) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if !s.bootstrapPending[serverType] {
+		return
+	}
+	delete(s.bootstrapPending, serverType)
+	if len(s.bootstrapPending) == 0 {
+		s.bootstrapSpan.finish(nil)
+		s.finishStartupPhase(phaseBootstrapDone)
+		s.signalReady()
+	}
+}
@@ -0,0 +1,89 @@
+//
+// DISCLAIMER
+//
+// Copyright 2017 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+// Author Ewout Prangsma
+//
+
+package service
+
+import (
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/arangodb-helper/arangodb/client"
+)
+
+const (
+	// unixSocketPrefix is the prefix of endpoints that refer to a unix socket.
+	unixSocketPrefix = "unix://"
+)
+
+// IsUnixSocketEndpoint returns true if the given endpoint refers to a unix socket (unix:///path).
+func IsUnixSocketEndpoint(endpoint string) bool {
+	return strings.HasPrefix(endpoint, unixSocketPrefix+"/")
+}
+
+// unixSocketPath returns the path of the unix socket in the given endpoint,
+// or an empty string if the endpoint does not refer to a unix socket.
+func unixSocketPath(endpoint string) string {
+	if !IsUnixSocketEndpoint(endpoint) {
+		return ""
+	}
+	return strings.TrimPrefix(endpoint, unixSocketPrefix)
+}
+
+// listenUnixSocket creates a listener on the unix socket at given path.
+// A socket left behind by an earlier run is removed first.
+// The socket is only accessible by the owner and group of the starter.
+func listenUnixSocket(path string) (net.Listener, error) {
+	if info, err := os.Lstat(path); err == nil && info.Mode()&os.ModeSocket != 0 {
+		os.Remove(path)
+	}
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, maskAny(err)
+	}
+	if err := os.Chmod(path, 0660); err != nil {
+		l.Close()
+		return nil, maskAny(err)
+	}
+	return l, nil
+}
+
+// serverSocket returns the path of the unix socket the server of given type
+// listens on, or an empty string if it listens on its TCP port.
+func (s *Service) serverSocket(serverType ServerType) string {
+	if serverType != ServerTypeSingle {
+		return ""
+	}
+	return unixSocketPath(s.ServerListen)
+}
+
+// serverSocketHTTPClient creates a client for API requests to a server listening on the
+// unix socket at given path.
+// Connections are not kept alive, since the client is not reused.
+func serverSocketHTTPClient(socketPath string, timeout time.Duration) *http.Client {
+	c := client.UnixSocketHTTPClient(socketPath)
+	c.Timeout = timeout
+	c.Transport.(*http.Transport).DisableKeepAlives = true
+	return c
+}
@@ -0,0 +1,553 @@
+//
+// DISCLAIMER
+//
+// Copyright 2017 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+// Author Ewout Prangsma
+//
+
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/arangodb-helper/arangodb/client"
+	"github.com/pkg/errors"
+)
+
+const (
+	upgradeServerTimeout   = time.Minute * 15 // Maximum time for upgrading a single server
+	upgradeRollbackTimeout = time.Minute * 5  // Maximum time for a server to come up again after rolling back its upgrade
+	upgradeLogLines        = 20               // Number of recent log lines of a server returned when its upgrade fails
+)
+
+// States of an upgrade step
+const (
+	UpgradeStepPending    = "pending"
+	UpgradeStepRunning    = "running"
+	UpgradeStepDone       = "done"
+	UpgradeStepSkipped    = "skipped"
+	UpgradeStepFailed     = "failed"
+	UpgradeStepRolledBack = "rolled-back"
+)
+
+var (
+	errServerNotRunning = errors.New("Server not running")
+	errUpgradeRunning   = errors.New("Upgrade already running")
+	errUpgradeNotPaused = errors.New("No upgrade is waiting for confirmation")
+	// upgradeHTTPClient is used for requests that last as long as the upgrade of a server.
+	upgradeHTTPClient = newUpgradeHTTPClient()
+)
+
+// UpgradeRequest is the (optional) JSON body of a POST `/upgrade` request.
+type UpgradeRequest struct {
+	Canary        bool   `json:"canary,omitempty"`         // If set, the upgrade pauses for confirmation after upgrading one dbserver & one coordinator
+	DockerImage   string `json:"docker-image,omitempty"`   // Docker image to upgrade the servers to (when running servers in docker)
+	ArangodPath   string `json:"arangod,omitempty"`        // Path of the arangod executable to upgrade the servers to (when running servers as processes)
+	JSPath        string `json:"js-dir,omitempty"`         // Path of the JS startup directory of that arangod executable
+	TargetVersion string `json:"target-version,omitempty"` // Version the servers are upgraded to. If empty, it is detected from the binary
+	Force         bool   `json:"force,omitempty"`          // If set, the upgrade starts even when the checks before the upgrade fail
+}
+
+// target returns the binary the servers are upgraded to, or an empty binary to upgrade the servers
+// with the binary they already use (e.g. after a package upgrade replaced it).
+func (r UpgradeRequest) target() ServerBinary {
+	return ServerBinary{DockerImage: r.DockerImage, ArangodPath: r.ArangodPath, JSPath: r.JSPath}
+}
+
+// UpgradeServerError is the JSON response of an `/upgrade/server` request, when the upgrade of the server has failed.
+type UpgradeServerError struct {
+	ErrorResponse
+	RolledBack bool     `json:"rolled-back,omitempty"` // If set, the server runs its previous binary again
+	Logs       []string `json:"logs,omitempty"`        // Most recent log lines of the server
+}
+
+// upgradeServerFailure is the error returned when the upgrade of a server has failed.
+type upgradeServerFailure struct {
+	UpgradeServerError
+}
+
+// Error implements the error interface.
+func (f upgradeServerFailure) Error() string {
+	return f.ErrorResponse.Error
+}
+
+// UpgradeStatus is the JSON response of a `/upgrade` request.
+type UpgradeStatus struct {
+	Running bool           `json:"running,omitempty"` // If set, the upgrade is in progress
+	Ready   bool           `json:"ready,omitempty"`   // If set, the upgrade has finished successfully
+	Failed  bool           `json:"failed,omitempty"`  // If set, the upgrade has failed
+	Reason  string         `json:"reason,omitempty"`  // Reason of the failure (if any)
+	Canary  bool           `json:"canary,omitempty"`  // If set, this is a canary upgrade
+	Paused  bool           `json:"paused,omitempty"`  // If set, the canary phase has passed and the upgrade waits for confirmation
+	Checks  []UpgradeCheck `json:"checks,omitempty"`  // Results of the checks before the upgrade and after the canary phase
+	Steps   []UpgradeStep  `json:"steps,omitempty"`   // All steps of the upgrade, in order of execution
+}
+
+// UpgradeStep holds the upgrade of a single server.
+type UpgradeStep struct {
+	PeerID     string   `json:"peer-id"`           // ID of the peer running the server
+	ServerType string   `json:"server-type"`       // Type of the server
+	State      string   `json:"state"`             // pending | running | done | skipped | failed | rolled-back
+	Message    string   `json:"message,omitempty"` // Details of the state (if any)
+	Canary     bool     `json:"canary,omitempty"`  // If set, the server is upgraded in the canary phase
+	Logs       []string `json:"logs,omitempty"`    // Most recent log lines of a server that failed to upgrade
+}
+
+// upgradeManager holds the state of a rolling upgrade, orchestrated by the master.
+type upgradeManager struct {
+	mutex    sync.Mutex
+	status   UpgradeStatus
+	decision chan bool // Receives the decision (true to proceed) of the operator on a paused canary upgrade
+}
+
+// newUpgradeHTTPClient creates an HTTP client without overall request timeout.
+func newUpgradeHTTPClient() *http.Client {
+	c := client.DefaultHTTPClient()
+	c.Timeout = 0
+	return c
+}
+
+// getStatus returns a copy of the current upgrade status.
+func (m *upgradeManager) getStatus() UpgradeStatus {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	status := m.status
+	status.Steps = append([]UpgradeStep{}, m.status.Steps...)
+	status.Checks = append([]UpgradeCheck(nil), m.status.Checks...)
+	return status
+}
+
+// update calls the given function with exclusive access to the upgrade status.
+func (m *upgradeManager) update(f func(status *UpgradeStatus)) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	f(&m.status)
+}
+
+// upgradeHandler starts a rolling upgrade (POST) or returns its status (GET).
+// This request must be handled by the master, other peers redirect it to the master.
+func (s *Service) upgradeHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" && r.Method != "GET" {
+		writeError(w, http.StatusMethodNotAllowed, "GET or POST required")
+		return
+	}
+	if len(s.myPeers.Peers) == 0 {
+		writeError(w, http.StatusPreconditionFailed, "No master known.")
+		return
+	}
+	if master := s.myPeers.Peers[0]; master.ID != s.ID {
+		w.Header().Add("Location", master.CreateStarterURL("/upgrade"))
+		w.WriteHeader(http.StatusTemporaryRedirect)
+		return
+	}
+
+	if r.Method == "POST" {
+		var req UpgradeRequest
+		if !readJSONBody(w, r, &req, true) {
+			return
+		}
+		op, existing, ok := s.beginOperation(w, r, OperationTypeUpgrade)
+		if !ok {
+			return
+		}
+		if !existing {
+			if err := s.startUpgrade(req); err != nil {
+				s.operations.remove(op.ID)
+				if refused, ok := errors.Cause(err).(upgradeRefused); ok {
+					b, _ := json.Marshal(refused.UpgradeRefusedError)
+					w.WriteHeader(http.StatusPreconditionFailed)
+					w.Write(b)
+				} else if errors.Cause(err) == errUpgradeRunning {
+					writeError(w, http.StatusConflict, err.Error())
+				} else {
+					writeError(w, http.StatusPreconditionFailed, err.Error())
+				}
+				return
+			}
+			s.watchOperation(op.ID, s.upgradeProgress)
+		}
+		if prefersAsync(r) {
+			op, _ = s.operations.get(op.ID)
+			writeOperation(w, http.StatusAccepted, op)
+			return
+		}
+	}
+	b, err := json.Marshal(s.upgrades.getStatus())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+	} else {
+		w.Write(b)
+	}
+}
+
+// upgradeProgress returns the progress of the current (or last) upgrade, for tracking it as an operation.
+func (s *Service) upgradeProgress() (bool, string, interface{}, error) {
+	status := s.upgrades.getStatus()
+	done := 0
+	for _, step := range status.Steps {
+		if step.State == UpgradeStepDone || step.State == UpgradeStepSkipped {
+			done++
+		}
+	}
+	progress := fmt.Sprintf("%d of %d servers upgraded", done, len(status.Steps))
+	if status.Paused {
+		progress += ", waiting for confirmation to proceed after the canary phase"
+	}
+	if status.Failed {
+		return false, progress, status, maskAny(errors.New(status.Reason))
+	}
+	return status.Running, progress, status, nil
+}
+
+// upgradeServerHandler handles an `/upgrade/server` request, send by the master to upgrade
+// the server of the type given in the `type` query, to the binary given in the `docker-image`,
+// `arangod` & `js-dir` queries (if any).
+// The response is sent once the server is up again after its upgrade, or after its rollback.
+func (s *Service) upgradeServerHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		writeError(w, http.StatusMethodNotAllowed, "POST required")
+		return
+	}
+	serverType := ServerType(r.FormValue("type"))
+	target := ServerBinary{
+		DockerImage: r.FormValue("docker-image"),
+		ArangodPath: r.FormValue("arangod"),
+		JSPath:      r.FormValue("js-dir"),
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), upgradeServerTimeout)
+	defer cancel()
+	if err := s.upgradeServer(ctx, serverType, target); errors.Cause(err) == errServerNotRunning {
+		writeError(w, http.StatusNotFound, err.Error())
+	} else if f, ok := errors.Cause(err).(upgradeServerFailure); ok {
+		b, _ := json.Marshal(f.UpgradeServerError)
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write(b)
+	} else if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+	} else {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("OK"))
+	}
+}
+
+// serverProcess returns the process of the server of given type, or nil if not started.
+func (s *Service) serverProcess(serverType ServerType) Process {
+	switch serverType {
+	case ServerTypeAgent:
+		return s.servers.agentProc
+	case ServerTypeDBServer:
+		return s.servers.dbserverProc
+	case ServerTypeCoordinator:
+		return s.servers.coordinatorProc
+	case ServerTypeSingle:
+		return s.servers.singleProc
+	default:
+		return nil
+	}
+}
+
+// upgradeServer restarts the server of given type with `--database.auto-upgrade=true`, using the given
+// target binary (if not empty), and waits until it is up again, after the upgrade has finished.
+// When the upgrade fails or the server is not up in time, the server is rolled back to its previous binary
+// and an upgradeServerFailure is returned.
+func (s *Service) upgradeServer(ctx context.Context, serverType ServerType, target ServerBinary) error {
+	p := s.serverProcess(serverType)
+	if p == nil {
+		return maskAny(errors.Wrapf(errServerNotRunning, "No %s started", serverType))
+	}
+	if err := s.checkUpgradeTarget(target); err != nil {
+		return maskAny(err)
+	}
+	previous, _ := s.upgradedBinaries.get(serverType)
+	if !target.IsEmpty() {
+		s.log.Infof("Switching %s to %s", serverType, describeServerBinary(target))
+		s.upgradedBinaries.set(serverType, target)
+	}
+	starts := s.serverStates.get(serverType).Starts
+	s.log.Infof("Restarting %s to upgrade its database", serverType)
+	s.serverStates.requestAutoUpgrade(serverType)
+	if err := p.Terminate(); err != nil {
+		s.serverStates.takeAutoUpgrade(serverType)
+		s.upgradedBinaries.set(serverType, previous)
+		return maskAny(err)
+	}
+	for {
+		state := s.serverStates.get(serverType)
+		if state.UpgradeErr != "" {
+			return maskAny(s.rollbackServer(serverType, target, previous, state.UpgradeErr))
+		}
+		if state.Up && state.Starts > starts {
+			s.log.Infof("%s has been upgraded to version %s", serverType, state.Version)
+			if !target.IsEmpty() {
+				if err := s.saveSetup(); err != nil {
+					s.log.Errorf("Failed to save setup after upgrading %s: %v", serverType, err)
+				}
+			}
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return maskAny(s.rollbackServer(serverType, target, previous, fmt.Sprintf("%s is not up after upgrade: %v", serverType, ctx.Err())))
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+// checkUpgradeTarget verifies that the arangod executable & JS startup directory of the given target binary exist,
+// when servers are run as processes.
+func (s *Service) checkUpgradeTarget(target ServerBinary) error {
+	if s.DockerImage != "" {
+		return nil
+	}
+	if target.ArangodPath != "" {
+		if info, err := os.Stat(target.ArangodPath); err != nil || info.IsDir() {
+			return maskAny(fmt.Errorf("Cannot find arangod executable %s", target.ArangodPath))
+		}
+	}
+	if target.JSPath != "" {
+		if info, err := os.Stat(target.JSPath); err != nil || !info.IsDir() {
+			return maskAny(fmt.Errorf("Cannot find JS startup directory %s", target.JSPath))
+		}
+	}
+	return nil
+}
+
+// rollbackServer restarts the server of given type, that failed to upgrade to the given target binary,
+// using its previous binary and waits until it is up again.
+// When the server was upgraded with the binary it already used (empty target), there is no previous
+// binary to go back to.
+// Returns an upgradeServerFailure describing the failure, the rollback and the recent log lines of the server.
+func (s *Service) rollbackServer(serverType ServerType, target, previous ServerBinary, reason string) error {
+	s.log.Errorf("Upgrade of %s has failed: %s", serverType, reason)
+	failure := upgradeServerFailure{}
+	failure.Logs = s.recentServerLogLines(serverType, upgradeLogLines)
+	if target.IsEmpty() {
+		failure.ErrorResponse.Error = reason + " (no rollback possible, the servers were upgraded with the binary they already used)"
+		return failure
+	}
+
+	s.log.Infof("Rolling back %s to %s", serverType, describeServerBinary(previous))
+	s.upgradedBinaries.set(serverType, previous)
+	// Do not start the server with `--database.auto-upgrade=true` again
+	s.serverStates.takeAutoUpgrade(serverType)
+	starts := s.serverStates.get(serverType).Starts
+	if p := s.serverProcess(serverType); p != nil {
+		s.serverStates.requestRestart(serverType)
+		if err := p.Terminate(); err != nil {
+			// Not running (anymore), it is restarted with the previous binary by itself
+			s.serverStates.takeRestartRequest(serverType)
+		}
+	}
+	ctx, cancel := context.WithTimeout(s.ctx, upgradeRollbackTimeout)
+	defer cancel()
+	for {
+		if state := s.serverStates.get(serverType); state.Up && state.Starts > starts {
+			s.log.Infof("%s has been rolled back and runs version %s", serverType, state.Version)
+			failure.RolledBack = true
+			failure.ErrorResponse.Error = fmt.Sprintf("%s (rolled back to version %s)", reason, state.Version)
+			return failure
+		}
+		select {
+		case <-ctx.Done():
+			s.log.Errorf("%s is not up after rolling back its upgrade", serverType)
+			failure.ErrorResponse.Error = fmt.Sprintf("%s (rollback failed: server is not up within %s)", reason, upgradeRollbackTimeout)
+			return failure
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+// startUpgrade creates the plan for a rolling upgrade of all servers and starts executing it.
+// Servers are upgraded one at a time: first all agents, then all dbservers, then all coordinators.
+// The upgrade is refused when one of the checks before the upgrade fails (see checkUpgrade), unless forced.
+// With canary set, one dbserver & one coordinator are upgraded directly after the agents,
+// after which the upgrade pauses until the operator confirms it (see canaryPhase).
+// With a non-empty target, every server is switched to that binary (or docker image) when it is upgraded.
+func (s *Service) startUpgrade(req UpgradeRequest) error {
+	canary, target := req.Canary, req.target()
+	s.mutex.Lock()
+	peerList := append([]Peer{}, s.myPeers.Peers...)
+	s.mutex.Unlock()
+	if s.upgrades.getStatus().Running {
+		return maskAny(errUpgradeRunning)
+	}
+
+	serverTypes := []ServerType{ServerTypeAgent, ServerTypeDBServer, ServerTypeCoordinator}
+	if s.isSingleMode() {
+		serverTypes = []ServerType{ServerTypeSingle}
+	}
+	var steps []UpgradeStep
+	for _, serverType := range serverTypes {
+		for _, p := range peerList {
+			if !p.HasServers() || (serverType == ServerTypeAgent && !p.HasAgent) {
+				continue
+			}
+			steps = append(steps, UpgradeStep{
+				PeerID:     p.ID,
+				ServerType: serverType.String(),
+				State:      UpgradeStepPending,
+			})
+		}
+	}
+	if len(steps) == 0 {
+		return maskAny(fmt.Errorf("No servers to upgrade"))
+	}
+	if canary {
+		var err error
+		if steps, err = canarySteps(steps); err != nil {
+			return maskAny(err)
+		}
+	}
+	checks, err := s.checkUpgrade(peerList, target, req.TargetVersion)
+	if err != nil && !req.Force {
+		return maskAny(err)
+	} else if err != nil {
+		s.log.Warningf("Starting upgrade although checks have failed: %v", err)
+	}
+
+	s.upgrades.update(func(status *UpgradeStatus) {
+		if status.Running {
+			err = maskAny(errUpgradeRunning)
+			return
+		}
+		*status = UpgradeStatus{
+			Running: true,
+			Canary:  canary,
+			Checks:  checks,
+			Steps:   steps,
+		}
+	})
+	if err != nil {
+		return maskAny(err)
+	}
+	s.log.Infof("Starting rolling upgrade of %d servers", len(steps))
+	s.events.publish(ProcessEvent{Type: ProcessEventUpgradeStart})
+	go s.runUpgrade(peerList, steps, target)
+	return nil
+}
+
+// runUpgrade executes the given upgrade steps, stopping at the first failure.
+// A server that fails to upgrade is rolled back to its previous binary by its peer (see rollbackServer).
+func (s *Service) runUpgrade(peerList []Peer, steps []UpgradeStep, target ServerBinary) {
+	setStep := func(index int, state, message string) {
+		s.upgrades.update(func(status *UpgradeStatus) {
+			status.Steps[index].State = state
+			status.Steps[index].Message = message
+		})
+	}
+	for i, step := range steps {
+		if i > 0 && steps[i-1].Canary && !step.Canary {
+			if !s.canaryPhase(peerList, steps[:i]) {
+				return
+			}
+		}
+		var peer Peer
+		for _, p := range peerList {
+			if p.ID == step.PeerID {
+				peer = p
+			}
+		}
+		s.log.Infof("Upgrading %s on peer '%s'", step.ServerType, step.PeerID)
+		setStep(i, UpgradeStepRunning, "")
+		if err := s.upgradePeerServer(peer, ServerType(step.ServerType), target); errors.Cause(err) == errServerNotRunning {
+			s.log.Infof("No %s running on peer '%s', skipping it", step.ServerType, step.PeerID)
+			setStep(i, UpgradeStepSkipped, "Server not running")
+		} else if err != nil {
+			s.log.Errorf("Failed to upgrade %s on peer '%s': %v", step.ServerType, step.PeerID, err)
+			state := UpgradeStepFailed
+			if f, ok := errors.Cause(err).(upgradeServerFailure); ok {
+				if f.RolledBack {
+					state = UpgradeStepRolledBack
+				}
+				s.upgrades.update(func(status *UpgradeStatus) {
+					status.Steps[i].Logs = f.Logs
+				})
+			}
+			setStep(i, state, err.Error())
+			s.upgrades.update(func(status *UpgradeStatus) {
+				status.Running = false
+				status.Failed = true
+				status.Reason = fmt.Sprintf("Failed to upgrade %s on peer '%s': %v", step.ServerType, step.PeerID, err)
+			})
+			s.events.publish(ProcessEvent{Type: ProcessEventUpgradeFinish, Reason: fmt.Sprintf("Failed to upgrade %s on peer '%s': %v", step.ServerType, step.PeerID, err)})
+			return
+		} else {
+			setStep(i, UpgradeStepDone, "")
+		}
+	}
+	s.log.Info("Rolling upgrade has finished")
+	s.events.publish(ProcessEvent{Type: ProcessEventUpgradeFinish})
+	s.upgrades.update(func(status *UpgradeStatus) {
+		status.Running = false
+		status.Ready = true
+	})
+}
+
+// upgradePeerServer asks the given peer to upgrade its server of given type to the given target binary (if not empty).
+func (s *Service) upgradePeerServer(peer Peer, serverType ServerType, target ServerBinary) error {
+	q := url.Values{}
+	q.Set("type", serverType.String())
+	if target.DockerImage != "" {
+		q.Set("docker-image", target.DockerImage)
+	}
+	if target.ArangodPath != "" {
+		q.Set("arangod", target.ArangodPath)
+	}
+	if target.JSPath != "" {
+		q.Set("js-dir", target.JSPath)
+	}
+	req, err := http.NewRequest("POST", peer.CreateStarterURL("/upgrade/server?"+q.Encode()), nil)
+	if err != nil {
+		return maskAny(err)
+	}
+	if err := addJwtHeader(req, s.JwtSecret); err != nil {
+		return maskAny(err)
+	}
+	// Leave the peer time to roll back the server when its upgrade fails
+	ctx, cancel := context.WithTimeout(s.ctx, upgradeServerTimeout+upgradeRollbackTimeout)
+	defer cancel()
+	resp, err := upgradeHTTPClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return maskAny(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+	body, _ := ioutil.ReadAll(resp.Body)
+	var errResp UpgradeServerError
+	message := fmt.Sprintf("Invalid status %d", resp.StatusCode)
+	if err := json.Unmarshal(body, &errResp); err == nil && errResp.Error != "" {
+		message = errResp.Error
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return maskAny(errors.Wrap(errServerNotRunning, message))
+	}
+	if errResp.RolledBack || len(errResp.Logs) > 0 {
+		return maskAny(upgradeServerFailure{errResp})
+	}
+	return maskAny(errors.New(message))
+}
@@ -0,0 +1,279 @@
+//
+// DISCLAIMER
+//
+// Copyright 2017 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+// Author Ewout Prangsma
+//
+
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Names of the checks run after the canary phase of an upgrade
+const (
+	UpgradeCheckCanaryServers    = "canary-servers"    // The upgraded canary servers are up
+	UpgradeCheckDeploymentHealth = "deployment-health" // All starters report a healthy status
+	UpgradeCheckHook             = "canary-check"      // The `canary-check` hook script succeeded
+)
+
+// UpgradeCheck holds the result of a single check run after the canary phase of an upgrade.
+type UpgradeCheck struct {
+	Name    string `json:"name"`              // Name of the check
+	Passed  bool   `json:"passed"`            // If set, the check has passed
+	Message string `json:"message,omitempty"` // Details of the result (if any)
+}
+
+// canarySteps moves the first dbserver & the first coordinator step directly behind the
+// agent steps of the given upgrade steps and marks them as canary steps.
+func canarySteps(steps []UpgradeStep) ([]UpgradeStep, error) {
+	var agents, canary, rest []UpgradeStep
+	for _, serverType := range []ServerType{ServerTypeDBServer, ServerTypeCoordinator} {
+		found := false
+		for _, step := range steps {
+			if step.ServerType == serverType.String() {
+				found = true
+				step.Canary = true
+				canary = append(canary, step)
+				break
+			}
+		}
+		if !found {
+			return nil, maskAny(fmt.Errorf("A canary upgrade requires a %s", serverType))
+		}
+	}
+	for _, step := range steps {
+		isCanary := false
+		for _, c := range canary {
+			if c.PeerID == step.PeerID && c.ServerType == step.ServerType {
+				isCanary = true
+			}
+		}
+		if isCanary {
+			continue
+		}
+		if ServerType(step.ServerType) == ServerTypeAgent {
+			agents = append(agents, step)
+		} else {
+			rest = append(rest, step)
+		}
+	}
+	return append(append(agents, canary...), rest...), nil
+}
+
+// canaryPhase runs the checks after the canary steps of an upgrade have finished and, when
+// they pass, waits for the operator to resume or abort the upgrade.
+// It returns true when the upgrade must proceed. Otherwise the upgrade has been marked as failed.
+func (s *Service) canaryPhase(peerList []Peer, canary []UpgradeStep) bool {
+	fail := func(reason string) bool {
+		s.log.Errorf("Rolling upgrade stopped: %s", reason)
+		s.upgrades.update(func(status *UpgradeStatus) {
+			status.Running = false
+			status.Paused = false
+			status.Failed = true
+			status.Reason = reason
+		})
+		s.events.publish(ProcessEvent{Type: ProcessEventUpgradeFinish, Reason: reason})
+		return false
+	}
+
+	s.log.Info("Canary servers have been upgraded, running checks")
+	checks := s.runCanaryChecks(peerList, canary)
+	var failed []string
+	for _, c := range checks {
+		if !c.Passed {
+			failed = append(failed, fmt.Sprintf("%s (%s)", c.Name, c.Message))
+		}
+	}
+	s.upgrades.update(func(status *UpgradeStatus) {
+		status.Checks = append(status.Checks, checks...)
+	})
+	if len(failed) > 0 {
+		return fail("Canary checks failed: " + strings.Join(failed, ", "))
+	}
+
+	decision := make(chan bool, 1)
+	s.upgrades.update(func(status *UpgradeStatus) {
+		status.Paused = true
+		s.upgrades.decision = decision
+	})
+	s.log.Info("Canary checks passed, waiting for confirmation to proceed with the upgrade")
+	s.events.publish(ProcessEvent{Type: ProcessEventUpgradePaused})
+	select {
+	case proceed := <-decision:
+		if !proceed {
+			return fail("Upgrade aborted after the canary phase")
+		}
+	case <-s.ctx.Done():
+		return fail("Starter is stopping")
+	}
+	s.log.Info("Proceeding with the rolling upgrade")
+	return true
+}
+
+// runCanaryChecks verifies the state of the deployment after the canary steps of an upgrade.
+func (s *Service) runCanaryChecks(peerList []Peer, canary []UpgradeStep) []UpgradeCheck {
+	findPeer := func(id string) Peer {
+		for _, p := range peerList {
+			if p.ID == id {
+				return p
+			}
+		}
+		return Peer{}
+	}
+
+	// Canary servers must be up
+	check := UpgradeCheck{Name: UpgradeCheckCanaryServers, Passed: true}
+	var messages []string
+	for _, step := range canary {
+		if step.State == UpgradeStepSkipped {
+			check.Passed = false
+			messages = append(messages, fmt.Sprintf("%s on peer '%s' is not running", step.ServerType, step.PeerID))
+			continue
+		}
+		var status StatusResponse
+		if err := getPeerJSON(findPeer(step.PeerID), "/status", &status); err != nil {
+			check.Passed = false
+			messages = append(messages, fmt.Sprintf("cannot get status of peer '%s': %v", step.PeerID, err))
+			continue
+		}
+		up := false
+		for _, server := range status.Servers {
+			if server.Type == step.ServerType && server.Up {
+				up = true
+				messages = append(messages, fmt.Sprintf("%s on peer '%s' is up with version %s", step.ServerType, step.PeerID, server.Version))
+			}
+		}
+		if !up {
+			check.Passed = false
+			messages = append(messages, fmt.Sprintf("%s on peer '%s' is not up", step.ServerType, step.PeerID))
+		}
+	}
+	check.Message = strings.Join(messages, ", ")
+	checks := []UpgradeCheck{check}
+
+	// All starters must report a healthy status
+	check = UpgradeCheck{Name: UpgradeCheckDeploymentHealth, Passed: true}
+	messages = nil
+	for _, p := range peerList {
+		if !p.HasServers() {
+			continue
+		}
+		var health HealthResponse
+		if err := getPeerJSON(p, "/health", &health); err != nil {
+			check.Passed = false
+			messages = append(messages, fmt.Sprintf("cannot get health of peer '%s': %v", p.ID, err))
+		} else if health.Status != HealthOK {
+			check.Passed = false
+			messages = append(messages, fmt.Sprintf("peer '%s' is %s", p.ID, health.Status))
+		}
+	}
+	if check.Passed {
+		check.Message = "all peers are healthy"
+	} else {
+		check.Message = strings.Join(messages, ", ")
+	}
+	checks = append(checks, check)
+
+	// Custom validation script
+	if s.hookScript(HookCanaryCheck) != "" {
+		check = UpgradeCheck{Name: UpgradeCheckHook, Passed: true, Message: "script succeeded"}
+		if err := s.runHook(HookCanaryCheck, nil); err != nil {
+			check.Passed = false
+			check.Message = err.Error()
+		}
+		checks = append(checks, check)
+	}
+	return checks
+}
+
+// getPeerJSON performs a GET request for the given path on the starter of the given peer
+// and decodes its JSON response into result.
+// Bodies of 503 responses are decoded too, since `/health` uses it for a failed status.
+func getPeerJSON(peer Peer, path string, result interface{}) error {
+	resp, err := httpClient.Get(peer.CreateStarterURL(path))
+	if err != nil {
+		return maskAny(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusServiceUnavailable {
+		return maskAny(fmt.Errorf("Invalid status %d", resp.StatusCode))
+	}
+	if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+		return maskAny(err)
+	}
+	return nil
+}
+
+// upgradeDecisionHandler returns a handler for the `/upgrade/resume` (proceed=true) and
+// `/upgrade/abort` (proceed=false) requests, deciding on an upgrade paused after its canary phase.
+// These requests must be handled by the master, other peers redirect them to the master.
+func (s *Service) upgradeDecisionHandler(path string, proceed bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			writeError(w, http.StatusMethodNotAllowed, "POST required")
+			return
+		}
+		if len(s.myPeers.Peers) == 0 {
+			writeError(w, http.StatusPreconditionFailed, "No master known.")
+			return
+		}
+		if master := s.myPeers.Peers[0]; master.ID != s.ID {
+			w.Header().Add("Location", master.CreateStarterURL(path))
+			w.WriteHeader(http.StatusTemporaryRedirect)
+			return
+		}
+
+		if err := s.decideUpgrade(proceed); errors.Cause(err) == errUpgradeNotPaused {
+			writeError(w, http.StatusConflict, err.Error())
+			return
+		} else if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		b, err := json.Marshal(s.upgrades.getStatus())
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+		} else {
+			w.Write(b)
+		}
+	}
+}
+
+// decideUpgrade passes the decision of the operator to an upgrade paused after its canary phase.
+func (s *Service) decideUpgrade(proceed bool) error {
+	var err error
+	s.upgrades.update(func(status *UpgradeStatus) {
+		if !status.Running || !status.Paused || s.upgrades.decision == nil {
+			err = maskAny(errUpgradeNotPaused)
+			return
+		}
+		s.upgrades.decision <- proceed
+		s.upgrades.decision = nil
+		if proceed {
+			status.Paused = false
+		}
+	})
+	return maskAny(err)
+}
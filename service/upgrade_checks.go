@@ -0,0 +1,475 @@
+//
+// DISCLAIMER
+//
+// Copyright 2017 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+// Author Ewout Prangsma
+//
+
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os/exec"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	docker "github.com/fsouza/go-dockerclient"
+)
+
+const (
+	arangodVersionTimeout  = time.Second * 10 // Maximum time for `arangod --version` to finish
+	maxReportedCollections = 10               // Maximum number of collections listed in the replication factor check
+	minUpgradeReplicaCount = 2                // Minimum number of replicas of every shard, to keep it available while its dbserver restarts
+)
+
+var (
+	releasePattern = regexp.MustCompile(`^v?(\d+)\.(\d+)`)
+)
+
+// Names of the checks run before a rolling upgrade starts
+const (
+	UpgradeCheckVersionJump       = "version-jump"       // The servers are upgraded to the same or the next release
+	UpgradeCheckDeprecatedOptions = "deprecated-options" // No options that are removed in the new release are passed to the servers
+	UpgradeCheckStorageEngine     = "storage-engine"     // The storage engine of the deployment is supported by the new release
+	UpgradeCheckReplicationFactor = "replication-factor" // All shards have enough replicas to stay available while a dbserver restarts
+)
+
+// UpgradeInfo is the JSON response of an `/upgrade/info` request, describing the servers of a peer
+// for the checks run before a rolling upgrade.
+type UpgradeInfo struct {
+	PeerID        string            `json:"peer-id"`                  // ID of the peer
+	Versions      map[string]string `json:"versions,omitempty"`       // Versions of the servers of the peer, by server type
+	TargetVersion string            `json:"target-version,omitempty"` // Version the servers are upgraded to (empty if unknown)
+	Options       []string          `json:"options,omitempty"`        // Options passed through to the servers, as `<prefix>.<name>`
+}
+
+// UpgradeRefusedError is the JSON response of a POST `/upgrade` request that is refused,
+// because the checks run before the upgrade have failed.
+type UpgradeRefusedError struct {
+	ErrorResponse
+	Checks []UpgradeCheck `json:"checks,omitempty"` // Results of all checks
+}
+
+// upgradeRefused is the error returned when the checks run before an upgrade have failed.
+type upgradeRefused struct {
+	UpgradeRefusedError
+}
+
+// Error implements the error interface.
+func (r upgradeRefused) Error() string {
+	return r.ErrorResponse.Error
+}
+
+// release holds the major & minor version of an ArangoDB release.
+type release struct {
+	major, minor int
+}
+
+// parseRelease returns the release of the given version (e.g. 3.4.1 or 3.4.1-rc.1).
+func parseRelease(version string) (release, bool) {
+	m := releasePattern.FindStringSubmatch(version)
+	if m == nil {
+		return release{}, false
+	}
+	major, _ := strconv.Atoi(m[1])
+	minor, _ := strconv.Atoi(m[2])
+	return release{major, minor}, true
+}
+
+// String returns the release as `<major>.<minor>`.
+func (r release) String() string {
+	return fmt.Sprintf("%d.%d", r.major, r.minor)
+}
+
+// less returns true if r is an older release than other.
+func (r release) less(other release) bool {
+	return r.major < other.major || (r.major == other.major && r.minor < other.minor)
+}
+
+// isNextRelease returns true if other is the release directly after r.
+// The release after the last release of a major version is the first release of the next major version.
+func (r release) isNextRelease(other release) bool {
+	return (other.major == r.major && other.minor == r.minor+1) || (other.major == r.major+1 && other.minor == 0)
+}
+
+// removedServerOption holds an arangod option that is deprecated, and removed in a later release.
+type removedServerOption struct {
+	name         string  // Name of the option, or a prefix (ending with a dot) of the names of a group of options
+	deprecatedIn release // Release in which the option has been deprecated
+	removedIn    release // Release in which the option has been removed
+	hint         string  // What to do instead
+}
+
+// removedServerOptions lists the arangod options that have been removed by a release.
+var removedServerOptions = []removedServerOption{
+	{"wal.", release{3, 6}, release{3, 7}, "the MMFiles storage engine has been removed"},
+	{"compaction.", release{3, 6}, release{3, 7}, "the MMFiles storage engine has been removed"},
+	{"database.maximal-journal-size", release{3, 6}, release{3, 7}, "the MMFiles storage engine has been removed"},
+}
+
+// matches returns true if the option with given name (without prefix) is the removed option.
+func (o removedServerOption) matches(name string) bool {
+	if strings.HasSuffix(o.name, ".") {
+		return strings.HasPrefix(name, o.name)
+	}
+	return name == o.name
+}
+
+// upgradeInfoHandler handles an `/upgrade/info` request, send by the master before a rolling upgrade.
+// The binary the servers are upgraded to is given in the `docker-image`, `arangod` & `js-dir` queries (if any),
+// its version in the `target-version` query (if known).
+func (s *Service) upgradeInfoHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		writeError(w, http.StatusMethodNotAllowed, "GET required")
+		return
+	}
+	target := ServerBinary{
+		DockerImage: r.FormValue("docker-image"),
+		ArangodPath: r.FormValue("arangod"),
+		JSPath:      r.FormValue("js-dir"),
+	}
+	b, err := json.Marshal(s.upgradeInfo(target, r.FormValue("target-version")))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+	} else {
+		w.Write(b)
+	}
+}
+
+// upgradeInfo describes the servers of this peer for the checks run before a rolling upgrade
+// to the given target binary (empty to upgrade with the binary the servers already use).
+func (s *Service) upgradeInfo(target ServerBinary, targetVersion string) UpgradeInfo {
+	info := UpgradeInfo{
+		PeerID:        s.ID,
+		Versions:      make(map[string]string),
+		TargetVersion: targetVersion,
+	}
+	var mainType ServerType = ServerTypeDBServer
+	if s.isSingleMode() {
+		mainType = ServerTypeSingle
+	}
+	for _, serverType := range []ServerType{ServerTypeAgent, ServerTypeDBServer, ServerTypeCoordinator, ServerTypeSingle} {
+		if version := s.serverStates.get(serverType).Version; version != "" {
+			info.Versions[serverType.String()] = version
+		}
+	}
+	if info.TargetVersion == "" {
+		info.TargetVersion = s.detectTargetVersion(target, mainType)
+	}
+	for _, o := range s.PassthroughOptions {
+		if !IsServerBinaryOption(o.Name) {
+			info.Options = append(info.Options, o.Prefix+"."+o.Name)
+		}
+	}
+	return info
+}
+
+// detectTargetVersion returns the version of the given target binary, or of the binary used by servers
+// of given type when the target is empty. Returns an empty string when the version cannot be detected.
+func (s *Service) detectTargetVersion(target ServerBinary, serverType ServerType) string {
+	if s.DockerImage != "" {
+		image := target.DockerImage
+		if image == "" {
+			image = s.serverImage(serverType)
+		}
+		_, tag := docker.ParseRepositoryTag(image)
+		if _, ok := parseRelease(tag); ok {
+			return tag
+		}
+		return ""
+	}
+	arangodPath := target.ArangodPath
+	if arangodPath == "" {
+		arangodPath, _ = s.serverArangodPaths(serverType)
+	}
+	version, err := arangodBinaryVersion(arangodPath)
+	if err != nil {
+		s.log.Warningf("Cannot detect version of %s: %v", arangodPath, err)
+		return ""
+	}
+	return version
+}
+
+// arangodBinaryVersion returns the version reported by `arangod --version` of the executable at given path.
+func arangodBinaryVersion(arangodPath string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), arangodVersionTimeout)
+	defer cancel()
+	output, err := exec.CommandContext(ctx, arangodPath, "--version").Output()
+	if err != nil {
+		return "", maskAny(err)
+	}
+	lines := strings.Split(string(output), "\n")
+	for _, line := range lines {
+		if strings.HasPrefix(line, "server-version:") {
+			return strings.TrimSpace(strings.TrimPrefix(line, "server-version:")), nil
+		}
+	}
+	for _, line := range lines {
+		if line = strings.TrimSpace(line); line != "" {
+			if _, ok := parseRelease(line); ok {
+				return line, nil
+			}
+			break
+		}
+	}
+	return "", maskAny(fmt.Errorf("Unexpected output of %s --version", arangodPath))
+}
+
+// checkUpgrade runs the checks before a rolling upgrade of the servers of the given peers to the given target binary
+// (with given version, if known). It returns an upgradeRefused error when a check fails, and the results of all checks.
+func (s *Service) checkUpgrade(peerList []Peer, target ServerBinary, targetVersion string) ([]UpgradeCheck, error) {
+	q := url.Values{}
+	q.Set("docker-image", target.DockerImage)
+	q.Set("arangod", target.ArangodPath)
+	q.Set("js-dir", target.JSPath)
+	q.Set("target-version", targetVersion)
+	var infos []UpgradeInfo
+	var infoErrors []string
+	for _, p := range peerList {
+		if !p.HasServers() {
+			continue
+		}
+		var info UpgradeInfo
+		if err := getPeerJSON(p, "/upgrade/info?"+q.Encode(), &info); err != nil {
+			infoErrors = append(infoErrors, fmt.Sprintf("cannot get servers of peer '%s': %v", p.ID, err))
+			continue
+		}
+		infos = append(infos, info)
+	}
+
+	checks := []UpgradeCheck{checkVersionJump(infos, infoErrors)}
+	checks = append(checks, checkRemovedOptions(infos))
+	checks = append(checks, checkStorageEngine(s.myPeers.StorageEngine, infos))
+	if !s.isSingleMode() {
+		checks = append(checks, s.checkReplicationFactor())
+	}
+
+	var failed []string
+	for _, c := range checks {
+		if !c.Passed {
+			failed = append(failed, fmt.Sprintf("%s (%s)", c.Name, c.Message))
+		}
+	}
+	if len(failed) > 0 {
+		refused := upgradeRefused{}
+		refused.ErrorResponse.Error = "Upgrade refused, checks failed: " + strings.Join(failed, ", ") + ". Use force to upgrade anyway."
+		refused.Checks = checks
+		return checks, maskAny(refused)
+	}
+	return checks, nil
+}
+
+// highestTargetRelease returns the highest release the servers of the given peers are upgraded to.
+func highestTargetRelease(infos []UpgradeInfo) (release, bool) {
+	var result release
+	found := false
+	for _, info := range infos {
+		if r, ok := parseRelease(info.TargetVersion); ok && (!found || result.less(r)) {
+			result, found = r, true
+		}
+	}
+	return result, found
+}
+
+// checkVersionJump verifies that the servers of all peers are upgraded to the release they already run,
+// or to the release directly after it. Skipping releases and downgrades are not supported.
+func checkVersionJump(infos []UpgradeInfo, infoErrors []string) UpgradeCheck {
+	check := UpgradeCheck{Name: UpgradeCheckVersionJump, Passed: len(infoErrors) == 0}
+	problems := append([]string{}, infoErrors...)
+	jumps := make(map[string]bool)
+	unknown := false
+	for _, info := range infos {
+		to, ok := parseRelease(info.TargetVersion)
+		if !ok {
+			unknown = true
+			continue
+		}
+		for serverType, version := range info.Versions {
+			from, ok := parseRelease(version)
+			if !ok {
+				continue
+			}
+			switch {
+			case to.less(from):
+				check.Passed = false
+				problems = append(problems, fmt.Sprintf("%s on peer '%s' would be downgraded from %s to %s", serverType, info.PeerID, version, info.TargetVersion))
+			case to != from && !from.isNextRelease(to):
+				check.Passed = false
+				problems = append(problems, fmt.Sprintf("%s on peer '%s' would skip releases from %s to %s, upgrade to each release in between first", serverType, info.PeerID, version, info.TargetVersion))
+			default:
+				jumps[from.String()+" to "+to.String()] = true
+			}
+		}
+	}
+	if !check.Passed {
+		sort.Strings(problems)
+		check.Message = strings.Join(problems, ", ")
+		return check
+	}
+	var msgs []string
+	for jump := range jumps {
+		msgs = append(msgs, jump)
+	}
+	sort.Strings(msgs)
+	if unknown {
+		msgs = append(msgs, "the version to upgrade to is unknown for some peers, set target-version to check it")
+	}
+	check.Message = strings.Join(msgs, ", ")
+	return check
+}
+
+// checkRemovedOptions verifies that no options are passed to the servers that are removed in the release
+// the servers are upgraded to. Options that are only deprecated are reported, but do not fail the check.
+func checkRemovedOptions(infos []UpgradeInfo) UpgradeCheck {
+	check := UpgradeCheck{Name: UpgradeCheckDeprecatedOptions, Passed: true}
+	var removed, deprecated []string
+	for _, info := range infos {
+		to, ok := parseRelease(info.TargetVersion)
+		if !ok {
+			continue
+		}
+		for _, option := range info.Options {
+			parts := strings.SplitN(option, ".", 2)
+			if len(parts) != 2 {
+				continue
+			}
+			for _, o := range removedServerOptions {
+				if !o.matches(parts[1]) {
+					continue
+				}
+				if !to.less(o.removedIn) {
+					removed = append(removed, fmt.Sprintf("--%s on peer '%s' has been removed in %s, %s", option, info.PeerID, o.removedIn, o.hint))
+				} else if !to.less(o.deprecatedIn) {
+					deprecated = append(deprecated, fmt.Sprintf("--%s on peer '%s' is deprecated in %s", option, info.PeerID, o.deprecatedIn))
+				}
+			}
+		}
+	}
+	switch {
+	case len(removed) > 0:
+		check.Passed = false
+		check.Message = strings.Join(append(removed, deprecated...), ", ")
+	case len(deprecated) > 0:
+		check.Message = strings.Join(deprecated, ", ")
+	default:
+		check.Message = "no deprecated options"
+	}
+	return check
+}
+
+// checkStorageEngine verifies that the storage engine of the deployment is supported by the release
+// the servers are upgraded to. The MMFiles storage engine has been removed in 3.7.
+func checkStorageEngine(engine string, infos []UpgradeInfo) UpgradeCheck {
+	check := UpgradeCheck{Name: UpgradeCheckStorageEngine, Passed: true}
+	if engine == "" {
+		// The default engine of arangod is mmfiles before 3.4
+		for _, info := range infos {
+			for _, version := range info.Versions {
+				if r, ok := parseRelease(version); ok && r.less(release{3, 4}) {
+					engine = "mmfiles"
+				}
+			}
+		}
+	}
+	if engine == "" {
+		engine = "rocksdb"
+	}
+	to, ok := highestTargetRelease(infos)
+	switch {
+	case !ok:
+		check.Message = fmt.Sprintf("storage engine %s, the version to upgrade to is unknown", engine)
+	case engine == "mmfiles" && !to.less(release{3, 7}):
+		check.Passed = false
+		check.Message = fmt.Sprintf("the mmfiles storage engine is no longer supported by %s, migrate the deployment to rocksdb first", to)
+	default:
+		check.Message = fmt.Sprintf("storage engine %s is supported by %s", engine, to)
+	}
+	return check
+}
+
+// checkReplicationFactor verifies that every shard of the cluster has enough replicas
+// to stay available while its dbserver is restarted by the rolling upgrade.
+func (s *Service) checkReplicationFactor() UpgradeCheck {
+	check := UpgradeCheck{Name: UpgradeCheckReplicationFactor, Passed: true}
+	ctx, cancel := context.WithTimeout(s.ctx, time.Second*30)
+	defer cancel()
+	collections, err := s.underReplicatedCollections(ctx)
+	if err != nil {
+		check.Passed = false
+		check.Message = fmt.Sprintf("cannot read the collections of the cluster: %v", err)
+		return check
+	}
+	if len(collections) == 0 {
+		check.Message = fmt.Sprintf("all shards have at least %d replicas", minUpgradeReplicaCount)
+		return check
+	}
+	check.Passed = false
+	more := ""
+	if len(collections) > maxReportedCollections {
+		more = fmt.Sprintf(" and %d more", len(collections)-maxReportedCollections)
+		collections = collections[:maxReportedCollections]
+	}
+	check.Message = fmt.Sprintf("shards of %s%s have less than %d replicas and are unavailable while their dbserver restarts",
+		strings.Join(collections, ", "), more, minUpgradeReplicaCount)
+	return check
+}
+
+// underReplicatedCollections returns the names (as `<database>/<collection>`) of all collections that
+// have a shard with less than minUpgradeReplicaCount planned servers.
+func (s *Service) underReplicatedCollections(ctx context.Context) ([]string, error) {
+	content, err := s.clusterRequest(ctx, ServerTypeAgent, "POST", "/_api/agency/read", []byte(`[["/arango/Plan/Collections"]]`))
+	if err != nil {
+		return nil, maskAny(err)
+	}
+	var resp []struct {
+		Arango struct {
+			Plan struct {
+				Collections map[string]map[string]struct {
+					Name   string              `json:"name"`
+					Shards map[string][]string `json:"shards"`
+				} `json:"Collections"`
+			} `json:"Plan"`
+		} `json:"arango"`
+	}
+	if err := json.Unmarshal(content, &resp); err != nil {
+		return nil, maskAny(err)
+	}
+	if len(resp) == 0 {
+		return nil, maskAny(fmt.Errorf("Empty agency response"))
+	}
+	var result []string
+	for db, collections := range resp[0].Arango.Plan.Collections {
+		for _, collection := range collections {
+			for _, servers := range collection.Shards {
+				if len(servers) < minUpgradeReplicaCount {
+					result = append(result, db+"/"+collection.Name)
+					break
+				}
+			}
+		}
+	}
+	sort.Strings(result)
+	return result, nil
+}
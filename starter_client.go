@@ -0,0 +1,83 @@
+//
+// DISCLAIMER
+//
+// Copyright 2017 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+// Author Ewout Prangsma
+//
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"strings"
+
+	"github.com/arangodb-helper/arangodb/client"
+	"github.com/arangodb-helper/arangodb/service"
+	"github.com/spf13/pflag"
+)
+
+var (
+	defaultStarterEndpoint = fmt.Sprintf("http://localhost:%d", service.DefaultMasterPort)
+)
+
+// addStarterEndpointFlag adds a `--starter.endpoint` flag to the given flag set,
+// used by commands that talk to a running starter.
+// It also adds a `--auth.jwt-secret` flag, needed for requests that the starter only accepts with a JWT.
+func addStarterEndpointFlag(f *pflag.FlagSet, endpoint *string) {
+	f.StringVar(endpoint, "starter.endpoint", defaultStarterEndpoint, "Endpoint (URL) of the starter to connect to (e.g. http://localhost:8528 or unix:///run/arangodb/starter.sock). Give several comma separated endpoints to fail over to the next starter when one cannot be reached")
+	f.StringVar(&jwtSecretFile, "auth.jwt-secret", "", "name of a plain text file containing the JWT secret of the deployment, used to authenticate requests to the starter")
+}
+
+// mustCreateStarterClient creates a client for the starter at the given endpoint.
+// Several (comma separated) endpoints can be given, the client then fails over
+// to the next starter when one cannot be reached.
+// Errors are fatal.
+func mustCreateStarterClient(endpoint string) client.API {
+	var endpoints []url.URL
+	for _, x := range strings.Split(endpoint, ",") {
+		ep, err := url.Parse(strings.TrimSpace(x))
+		if err != nil {
+			log.Fatalf("Invalid starter endpoint '%s': %v", x, err)
+		}
+		if ep.Scheme == "" || (ep.Host == "" && ep.Scheme != "unix") {
+			log.Fatalf("Invalid starter endpoint '%s': expected a URL like %s", x, defaultStarterEndpoint)
+		}
+		endpoints = append(endpoints, *ep)
+	}
+	var options []client.ClientOption
+	if jwtSecretFile != "" {
+		content, err := ioutil.ReadFile(mustExpand(jwtSecretFile))
+		if err != nil {
+			log.Fatalf("Failed to read JWT secret file '%s': %v", jwtSecretFile, err)
+		}
+		options = append(options, client.WithJWTSecret(strings.TrimSpace(string(content))))
+	}
+	var c client.API
+	var err error
+	if len(endpoints) == 1 {
+		c, err = client.NewArangoStarterClient(endpoints[0], options...)
+	} else {
+		c, err = client.NewArangoStarterClientWithFailover(endpoints, options...)
+	}
+	if err != nil {
+		log.Fatalf("Failed to create starter client: %v", err)
+	}
+	return c
+}
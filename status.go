@@ -0,0 +1,143 @@
+//
+// DISCLAIMER
+//
+// Copyright 2017 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+// Author Ewout Prangsma
+//
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/arangodb-helper/arangodb/client"
+	"github.com/spf13/cobra"
+)
+
+var (
+	cmdStatus = &cobra.Command{
+		Use:   "status",
+		Short: "Show the status of a running starter, its servers and its peers",
+		Run:   cmdStatusRun,
+	}
+	statusOptions struct {
+		endpoint string
+		output   string
+	}
+)
+
+func init() {
+	f := cmdStatus.Flags()
+	addStarterEndpointFlag(f, &statusOptions.endpoint)
+	f.StringVar(&statusOptions.output, "output", "text", "Output format (text|json)")
+	cmdMain.AddCommand(cmdStatus)
+}
+
+func cmdStatusRun(cmd *cobra.Command, args []string) {
+	if statusOptions.output != "text" && statusOptions.output != "json" {
+		log.Fatalf("Unsupported output format '%s', expected text or json", statusOptions.output)
+	}
+	c := mustCreateStarterClient(statusOptions.endpoint)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
+	defer cancel()
+	status, err := c.Status(ctx)
+	if err != nil {
+		log.Fatalf("Failed to get status of starter at %s: %v", statusOptions.endpoint, err)
+	}
+
+	if statusOptions.output == "json" {
+		encoded, err := json.MarshalIndent(status, "", "  ")
+		if err != nil {
+			log.Fatalf("Failed to encode status: %v", err)
+		}
+		fmt.Println(string(encoded))
+		return
+	}
+	printStatus(status)
+}
+
+// printStatus writes a human readable form of the given status to stdout.
+func printStatus(status client.StatusInfo) {
+	fmt.Printf("Starter %s (%s mode), version %s, build %s\n", status.ID, status.Mode, status.Version, status.Build)
+
+	fmt.Println()
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	if len(status.Servers) == 0 {
+		fmt.Fprintln(w, "No servers started")
+	} else {
+		fmt.Fprintln(w, "SERVER\tADDRESS\tPROCESS\tHEALTH\tVERSION")
+	}
+	for _, s := range status.Servers {
+		process := strconv.Itoa(s.ProcessID)
+		if s.ContainerID != "" {
+			process = s.ContainerID
+			if len(process) > 12 {
+				process = process[:12]
+			}
+		}
+		health := "down"
+		if s.Up {
+			health = "up"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", s.Type, net.JoinHostPort(s.IP, strconv.Itoa(s.Port)), process, health, s.Version)
+	}
+	w.Flush()
+
+	fmt.Println()
+	w = tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "PEER\tADDRESS\tROLES")
+	for _, p := range status.Peers {
+		var roles []string
+		if p.IsMaster {
+			roles = append(roles, "master")
+		}
+		if p.HasAgent {
+			roles = append(roles, "agent")
+		}
+		if p.IsStandby {
+			roles = append(roles, "standby")
+		}
+		if p.IsPassive {
+			roles = append(roles, "passive")
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", p.ID, net.JoinHostPort(p.Address, strconv.Itoa(p.Port)), strings.Join(roles, ","))
+	}
+	w.Flush()
+
+	if len(status.Startup) > 0 {
+		fmt.Println()
+		w = tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "PHASE\tDURATION\tELAPSED")
+		for _, p := range status.Startup {
+			duration, elapsed := p.Duration, p.Elapsed
+			if !p.Done {
+				duration, elapsed = "pending", "-"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\n", p.Name, duration, elapsed)
+		}
+		w.Flush()
+	}
+}
@@ -0,0 +1,141 @@
+//
+// DISCLAIMER
+//
+// Copyright 2017 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+// Author Ewout Prangsma
+//
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/arangodb-helper/arangodb/client"
+	"github.com/spf13/cobra"
+)
+
+var (
+	cmdStop = &cobra.Command{
+		Use:   "stop",
+		Short: "Stop a running starter and all servers started by it",
+		Run:   cmdStopRun,
+	}
+	stopOptions struct {
+		endpoint   string
+		goodbye    bool
+		removeData bool
+		cluster    bool
+		timeout    time.Duration
+	}
+)
+
+func init() {
+	f := cmdStop.Flags()
+	addStarterEndpointFlag(f, &stopOptions.endpoint)
+	f.BoolVar(&stopOptions.goodbye, "goodbye", false, "If set, the starter will remove its peer slot at the master")
+	f.BoolVar(&stopOptions.removeData, "remove-data", false, "If set, all data of the starter(s) is removed after the servers have stopped")
+	f.BoolVar(&stopOptions.cluster, "cluster", false, "If set, all starters of the cluster are stopped")
+	f.DurationVar(&stopOptions.timeout, "timeout", time.Minute*5, "Time to wait for the starter(s) to stop")
+	cmdMain.AddCommand(cmdStop)
+}
+
+func cmdStopRun(cmd *cobra.Command, args []string) {
+	if stopOptions.cluster && stopOptions.goodbye {
+		log.Fatal("Cannot use --goodbye together with --cluster")
+	}
+
+	endpoints := []string{stopOptions.endpoint}
+	if stopOptions.cluster {
+		endpoints = clusterStarterEndpoints(stopOptions.endpoint)
+	}
+
+	options := client.ShutdownOptions{
+		Goodbye:    stopOptions.goodbye,
+		RemoveData: stopOptions.removeData,
+	}
+	for _, ep := range endpoints {
+		c := mustCreateStarterClient(ep)
+		log.Infof("Stopping starter at %s", ep)
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
+		err := c.ShutdownWithOptions(ctx, options)
+		cancel()
+		if err != nil {
+			log.Fatalf("Failed to stop starter at %s: %v", ep, err)
+		}
+		if err := waitUntilStarterGone(c, stopOptions.timeout); err != nil {
+			log.Fatalf("Starter at %s did not stop: %v", ep, err)
+		}
+		log.Infof("Starter at %s has stopped", ep)
+	}
+}
+
+// clusterStarterEndpoints returns the endpoints of all starters in the cluster of the
+// starter at the given endpoint.
+// The master is returned last, so slaves can say goodbye while it is still available.
+func clusterStarterEndpoints(endpoint string) []string {
+	c := mustCreateStarterClient(endpoint)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
+	defer cancel()
+	status, err := c.Status(ctx)
+	if err != nil {
+		log.Fatalf("Failed to get status of starter at %s: %v", endpoint, err)
+	}
+	var result []string
+	var master string
+	for _, p := range status.Peers {
+		scheme := "http"
+		if p.IsSecure {
+			scheme = "https"
+		}
+		ep := fmt.Sprintf("%s://%s", scheme, net.JoinHostPort(p.Address, strconv.Itoa(p.Port)))
+		if p.IsMaster {
+			master = ep
+		} else {
+			result = append(result, ep)
+		}
+	}
+	if master != "" {
+		result = append(result, master)
+	}
+	if len(result) == 0 {
+		result = append(result, endpoint)
+	}
+	return result
+}
+
+// waitUntilStarterGone waits until the starter accessed by the given client
+// no longer responds to requests.
+func waitUntilStarterGone(c client.API, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+		_, err := c.Version(ctx)
+		cancel()
+		if err != nil {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return maskAny(fmt.Errorf("still running after %s", timeout))
+		}
+		time.Sleep(time.Second)
+	}
+}
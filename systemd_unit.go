@@ -0,0 +1,226 @@
+//
+// DISCLAIMER
+//
+// Copyright 2017 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+// Author Ewout Prangsma
+//
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+const (
+	systemdUnitDir = "/etc/systemd/system"
+)
+
+var (
+	cmdCreate = &cobra.Command{
+		Use:   "create",
+		Short: "Create files needed to run the starter",
+	}
+	cmdCreateSystemdUnit = &cobra.Command{
+		Use:   "systemd-unit",
+		Short: "Create a systemd unit file that runs the starter with the given options",
+		Run:   cmdCreateSystemdUnitRun,
+	}
+	systemdUnitOptions struct {
+		name            string
+		user            string
+		group           string
+		environmentFile string
+		restart         string
+		limitNoFile     uint64
+		timeoutStart    string
+		watchdog        time.Duration
+		output          string
+		install         bool
+		start           bool
+	}
+)
+
+func init() {
+	// The unit accepts all options of the starter itself, which are passed on to ExecStart.
+	// This relies on the flags of cmdMain being defined (in main.go) before this init function runs.
+	f := cmdCreateSystemdUnit.Flags()
+	f.AddFlagSet(cmdMain.Flags())
+	f.SetNormalizeFunc(normalizeOptionNames)
+	f.StringVar(&systemdUnitOptions.name, "unit-name", projectName, "Name of the unit (without .service)")
+	f.StringVar(&systemdUnitOptions.user, "user", "", "User to run the starter as (default root)")
+	f.StringVar(&systemdUnitOptions.group, "group", "", "Group to run the starter as")
+	f.StringVar(&systemdUnitOptions.environmentFile, "environment-file", "", "Environment file loaded by the unit, if it exists (default /etc/default/<unit-name>)")
+	f.StringVar(&systemdUnitOptions.restart, "restart", "on-failure", "Restart policy of the unit (no|on-failure|always)")
+	f.Uint64Var(&systemdUnitOptions.limitNoFile, "limit-nofile", 131072, "Maximum number of open files of the starter and its servers")
+	f.StringVar(&systemdUnitOptions.timeoutStart, "timeout-start", "infinity", "Time systemd waits for all servers to be up (the starter may wait for its peers first)")
+	f.DurationVar(&systemdUnitOptions.watchdog, "watchdog", 0, "If set, systemd restarts the starter when it does not respond for this long")
+	f.StringVar(&systemdUnitOptions.output, "output", "", "Path of the unit file to create (default standard output, or /etc/systemd/system/<unit-name>.service with --install)")
+	f.BoolVar(&systemdUnitOptions.install, "install", false, "If set, the unit file is installed and enabled")
+	f.BoolVar(&systemdUnitOptions.start, "start", false, "If set, the installed unit is started as well")
+	cmdCreate.AddCommand(cmdCreateSystemdUnit)
+	cmdMain.AddCommand(cmdCreate)
+}
+
+func cmdCreateSystemdUnitRun(cmd *cobra.Command, args []string) {
+	if len(args) > 0 {
+		log.Fatalf("Expected no arguments, got %q", args)
+	}
+	switch systemdUnitOptions.restart {
+	case "no", "on-failure", "always":
+	default:
+		log.Fatalf("Unknown restart policy '%s', expected no, on-failure or always", systemdUnitOptions.restart)
+	}
+	if systemdUnitOptions.start && !systemdUnitOptions.install {
+		log.Fatal("--start requires --install")
+	}
+
+	// Only the passthrough options of the command line are passed on, the configuration file is read by the starter itself
+	cmdLinePassthroughOptions := passthroughOptions
+	if err := loadConfigFile(cmd.Flags()); err != nil {
+		log.Fatal(err.Error())
+	}
+	for _, p := range checkOptions() {
+		if p.Severity == severityError {
+			log.Fatalf("--%s: %s", p.Option, p.Message)
+		}
+	}
+
+	executable, err := starterExecutable()
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+	workDir, err := os.Getwd()
+	if err != nil {
+		log.Fatalf("Cannot get working directory: %v", err)
+	}
+	starterArgs, err := starterArguments(cmdLinePassthroughOptions)
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+	unit := createSystemdUnit(append([]string{executable}, starterArgs...), workDir)
+
+	output := systemdUnitOptions.output
+	if output == "" && systemdUnitOptions.install {
+		output = filepath.Join(systemdUnitDir, systemdUnitOptions.name+".service")
+	}
+	if output == "" {
+		fmt.Print(unit)
+		return
+	}
+	if err := ioutil.WriteFile(output, []byte(unit), 0644); err != nil {
+		log.Fatalf("Failed to write %s: %v", output, err)
+	}
+	log.Infof("Unit file written to %s", output)
+	if !systemdUnitOptions.install {
+		return
+	}
+	enableArgs := []string{"enable"}
+	if systemdUnitOptions.start {
+		enableArgs = append(enableArgs, "--now", "--no-block")
+	}
+	for _, systemctlArgs := range [][]string{{"daemon-reload"}, append(enableArgs, systemdUnitOptions.name+".service")} {
+		c := exec.Command("systemctl", systemctlArgs...)
+		c.Stdout = os.Stdout
+		c.Stderr = os.Stderr
+		if err := c.Run(); err != nil {
+			log.Fatalf("systemctl %s failed: %v", strings.Join(systemctlArgs, " "), err)
+		}
+	}
+	if systemdUnitOptions.start {
+		log.Infof("Unit %s has been enabled and is starting, use `systemctl status %s` to follow it", systemdUnitOptions.name, systemdUnitOptions.name)
+	} else {
+		log.Infof("Unit %s has been enabled, use `systemctl start %s` to start it", systemdUnitOptions.name, systemdUnitOptions.name)
+	}
+}
+
+// createSystemdUnit returns the content of a unit file that runs the given command line.
+func createSystemdUnit(execStart []string, workDir string) string {
+	o := systemdUnitOptions
+	environmentFile := o.environmentFile
+	if environmentFile == "" {
+		environmentFile = "/etc/default/" + o.name
+	}
+	quoted := make([]string, 0, len(execStart))
+	for _, arg := range execStart {
+		quoted = append(quoted, systemdQuote(arg))
+	}
+
+	b := &bytes.Buffer{}
+	fmt.Fprintf(b, "# Created by `%s create systemd-unit` (version %s)\n", projectName, projectVersion)
+	fmt.Fprintln(b, "[Unit]")
+	fmt.Fprintln(b, "Description=ArangoDB starter")
+	fmt.Fprintln(b, "Documentation=https://github.com/arangodb-helper/arangodb")
+	fmt.Fprintln(b, "After=network-online.target")
+	fmt.Fprintln(b, "Wants=network-online.target")
+	if dockerImage != "" {
+		fmt.Fprintln(b, "After=docker.service")
+		fmt.Fprintln(b, "Requires=docker.service")
+	}
+	fmt.Fprintln(b)
+	fmt.Fprintln(b, "[Service]")
+	// The starter notifies systemd once all its servers are up
+	fmt.Fprintln(b, "Type=notify")
+	if o.user != "" {
+		fmt.Fprintf(b, "User=%s\n", o.user)
+	}
+	if o.group != "" {
+		fmt.Fprintf(b, "Group=%s\n", o.group)
+	}
+	fmt.Fprintf(b, "EnvironmentFile=-%s\n", environmentFile)
+	fmt.Fprintf(b, "WorkingDirectory=%s\n", strings.Replace(workDir, "%", "%%", -1))
+	fmt.Fprintf(b, "ExecStart=%s\n", strings.Join(quoted, " \\\n    "))
+	fmt.Fprintf(b, "Restart=%s\n", o.restart)
+	fmt.Fprintln(b, "RestartSec=5s")
+	fmt.Fprintf(b, "TimeoutStartSec=%s\n", o.timeoutStart)
+	// Give the servers time to shut down cleanly
+	fmt.Fprintln(b, "TimeoutStopSec=5min")
+	fmt.Fprintln(b, "KillMode=mixed")
+	if o.watchdog > 0 {
+		fmt.Fprintf(b, "WatchdogSec=%d\n", int64(o.watchdog/time.Second))
+	}
+	fmt.Fprintf(b, "LimitNOFILE=%d\n", o.limitNoFile)
+	if maxProcesses > 0 {
+		fmt.Fprintf(b, "LimitNPROC=%d\n", maxProcesses)
+	}
+	fmt.Fprintln(b, "LimitCORE=infinity")
+	fmt.Fprintln(b)
+	fmt.Fprintln(b, "[Install]")
+	fmt.Fprintln(b, "WantedBy=multi-user.target")
+	return b.String()
+}
+
+// systemdQuote quotes the given argument for use in a command line of a systemd unit.
+// Specifiers (%) and variables ($) are escaped, so the argument is passed on literally.
+func systemdQuote(arg string) string {
+	arg = strings.Replace(arg, "%", "%%", -1)
+	arg = strings.Replace(arg, "$", "$$", -1)
+	if arg != "" && !strings.ContainsAny(arg, " \t\n\"'\\;") {
+		return arg
+	}
+	return strconv.Quote(arg)
+}
@@ -23,7 +23,10 @@
 package test
 
 import (
+	"io/ioutil"
 	"os"
+	"path/filepath"
+	"regexp"
 	"testing"
 	"time"
 )
@@ -52,6 +55,35 @@ func TestProcessClusterLocal(t *testing.T) {
 	SendIntrAndWait(t, child)
 }
 
+// TestProcessClusterLocalUpgrade runs `arangodb --starter.local`, followed by `arangodb upgrade`.
+func TestProcessClusterLocalUpgrade(t *testing.T) {
+	needTestMode(t, testModeProcess)
+	dataDir := SetUniqueDataDir(t)
+	defer os.RemoveAll(dataDir)
+
+	child := Spawn(t, "${STARTER} --starter.local")
+	defer child.Close()
+
+	if ok := WaitUntilStarterReady(t, whatCluster, child); ok {
+		start := time.Now()
+		upgrade := Spawn(t, "${STARTER} upgrade")
+		defer upgrade.Close()
+		if err := upgrade.WaitTimeout(time.Minute * 10); err != nil {
+			t.Errorf("Upgrade command failed: %s", describe(err))
+		} else {
+			t.Logf("Upgrade took %s", time.Since(start))
+		}
+		testCluster(t, insecureStarterEndpoint(0), false)
+		testCluster(t, insecureStarterEndpoint(5), false)
+		testCluster(t, insecureStarterEndpoint(10), false)
+	}
+
+	if isVerbose {
+		t.Log("Waiting for termination")
+	}
+	SendIntrAndWait(t, child)
+}
+
 // TestProcessClusterLocal runs `arangodb --starter.local`, stopping it through the `/shutdown` API.
 func TestProcessClusterLocalShutdownViaAPI(t *testing.T) {
 	needTestMode(t, testModeProcess)
@@ -99,3 +131,26 @@ func TestOldProcessClusterLocal(t *testing.T) {
 	}
 	SendIntrAndWait(t, child)
 }
+
+// TestProcessClusterLocalDryRunServerDirs runs `arangodb --starter.local --starter.dry-run --dbservers.dir=...`
+func TestProcessClusterLocalDryRunServerDirs(t *testing.T) {
+	needTestMode(t, testModeProcess)
+	dataDir := SetUniqueDataDir(t)
+	defer os.RemoveAll(dataDir)
+	dbserversDir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(describe(err))
+	}
+	defer os.RemoveAll(dbserversDir)
+
+	child := Spawn(t, "${STARTER} --starter.local --starter.dry-run --dbservers.dir="+dbserversDir)
+	defer child.Close()
+
+	expected := `"data-dir": "` + regexp.QuoteMeta(filepath.Join(dbserversDir, "dbserver8530")) + `"`
+	if _, err := child.ExpectTimeout(time.Second*10, regexp.MustCompile(expected)); err != nil {
+		t.Errorf("Expected dbserver in %s in dry run plan: %s", dbserversDir, describe(err))
+	}
+	if err := child.WaitTimeout(time.Second * 10); err != nil {
+		t.Errorf("Dry run failed: %s", describe(err))
+	}
+}
@@ -24,6 +24,8 @@ package test
 
 import (
 	"os"
+	"path/filepath"
+	"regexp"
 	"testing"
 	"time"
 )
@@ -72,6 +74,98 @@ func TestProcessSingleShutdownViaAPI(t *testing.T) {
 	ShutdownStarter(t, insecureStarterEndpoint(0))
 }
 
+// TestProcessSingleStopCommand runs `arangodb --starter.mode=single`, stopping it using `arangodb stop --remove-data`.
+func TestProcessSingleStopCommand(t *testing.T) {
+	needTestMode(t, testModeProcess)
+	dataDir := SetUniqueDataDir(t)
+	defer os.RemoveAll(dataDir)
+
+	child := Spawn(t, "${STARTER} --starter.mode=single")
+	defer child.Close()
+
+	if ok := WaitUntilStarterReady(t, whatSingle, child); ok {
+		testSingle(t, insecureStarterEndpoint(0), false)
+	}
+
+	stop := Spawn(t, "${STARTER} stop --remove-data")
+	defer stop.Close()
+	if err := stop.WaitTimeout(time.Minute); err != nil {
+		t.Errorf("Stop command failed: %s", describe(err))
+	}
+	if err := child.WaitTimeout(time.Second * 30); err != nil {
+		t.Errorf("Starter is not stopped in time: %s", describe(err))
+	}
+	if _, err := os.Stat(filepath.Join(dataDir, "setup.json")); !os.IsNotExist(err) {
+		t.Errorf("Expected setup.json to be removed, got %s", describe(err))
+	}
+}
+
+// TestProcessSingleDryRun runs `arangodb --starter.mode=single --starter.dry-run`
+func TestProcessSingleDryRun(t *testing.T) {
+	needTestMode(t, testModeProcess)
+	dataDir := SetUniqueDataDir(t)
+	defer os.RemoveAll(dataDir)
+
+	child := Spawn(t, "${STARTER} --starter.mode=single --starter.dry-run")
+	defer child.Close()
+
+	if _, err := child.ExpectTimeout(time.Second*10, regexp.MustCompile(`"type": "single"`)); err != nil {
+		t.Errorf("Expected single server in dry run plan: %s", describe(err))
+	}
+	if err := child.WaitTimeout(time.Second * 10); err != nil {
+		t.Errorf("Dry run failed: %s", describe(err))
+	}
+	if _, err := os.Stat(filepath.Join(dataDir, "setup.json")); !os.IsNotExist(err) {
+		t.Errorf("Expected no setup.json after dry run, got %s", describe(err))
+	}
+}
+
+// TestProcessSingleValidate runs `arangodb validate` with valid & invalid options.
+func TestProcessSingleValidate(t *testing.T) {
+	needTestMode(t, testModeProcess)
+	dataDir := SetUniqueDataDir(t)
+	defer os.RemoveAll(dataDir)
+
+	child := Spawn(t, "${STARTER} validate --starter.mode=single")
+	defer child.Close()
+	if _, err := child.ExpectTimeout(time.Second*10, regexp.MustCompile(`Configuration is valid`)); err != nil {
+		t.Errorf("Expected valid configuration: %s", describe(err))
+	}
+	if err := child.WaitTimeout(time.Second * 10); err != nil {
+		t.Errorf("Validate failed: %s", describe(err))
+	}
+
+	invalid := Spawn(t, "${STARTER} validate --cluster.agency-size=2 --output=json")
+	defer invalid.Close()
+	if _, err := invalid.ExpectTimeout(time.Second*10, regexp.MustCompile(`"option": "cluster.agency-size"`)); err != nil {
+		t.Errorf("Expected agency size problem: %s", describe(err))
+	}
+	if err := invalid.WaitTimeout(time.Second * 10); err == nil {
+		t.Error("Expected validate to fail for invalid agency size")
+	}
+}
+
+// TestProcessSingleJSONLogFormat runs `arangodb --starter.mode=single --log.format=json`
+func TestProcessSingleJSONLogFormat(t *testing.T) {
+	needTestMode(t, testModeProcess)
+	dataDir := SetUniqueDataDir(t)
+	defer os.RemoveAll(dataDir)
+
+	child := Spawn(t, "${STARTER} --starter.mode=single --log.format=json")
+	defer child.Close()
+
+	if _, err := child.ExpectTimeout(time.Minute, regexp.MustCompile(`"event":"ready"`)); err != nil {
+		t.Errorf("Expected ready event: %s", describe(err))
+	} else {
+		testSingle(t, insecureStarterEndpoint(0), false)
+	}
+
+	if isVerbose {
+		t.Log("Waiting for termination")
+	}
+	SendIntrAndWait(t, child)
+}
+
 // TestProcessSingleAutoKeyFile runs `arangodb --starter.mode=single --ssl.auto-key`
 func TestProcessSingleAutoKeyFile(t *testing.T) {
 	needTestMode(t, testModeProcess)
@@ -28,6 +28,7 @@ import (
 	"fmt"
 	"net"
 	"net/http"
+	"strconv"
 	"testing"
 	"time"
 
@@ -170,6 +171,33 @@ func testProcesses(t *testing.T, c client.API, mode, starterEndpoint string, isS
 	} else if mode == "single" {
 		t.Errorf("No single found in %s", starterEndpoint)
 	}
+
+	// Check status
+	status, err := c.Status(ctx)
+	if err != nil {
+		t.Fatalf("Failed to get starter status: %s", describe(err))
+	}
+	if status.Mode != mode {
+		t.Errorf("Invalid mode in status of %s. Expected %s, got %s", starterEndpoint, mode, status.Mode)
+	}
+	if len(status.Servers) != len(processes.Servers) {
+		t.Errorf("Invalid number of servers in status of %s. Expected %d, got %d", starterEndpoint, len(processes.Servers), len(status.Servers))
+	}
+	for _, ss := range status.Servers {
+		if sp, ok := processes.ServerByType(ss.Type); !ok {
+			t.Errorf("Unexpected %s in status of %s", ss.Type, starterEndpoint)
+		} else if sp.Port != ss.Port {
+			t.Errorf("Invalid port of %s in status of %s. Expected %d, got %d", ss.Type, starterEndpoint, sp.Port, ss.Port)
+		}
+	}
+	if len(status.Peers) == 0 {
+		t.Errorf("No peers in status of %s", starterEndpoint)
+	}
+	for _, p := range status.Startup {
+		if p.Done {
+			t.Logf("Startup phase %s of %s took %s (%s since start)", p.Name, starterEndpoint, p.Duration, p.Elapsed)
+		}
+	}
 }
 
 // testArangodReachable tries to call some HTTP API methods of the given server process to make sure
@@ -179,9 +207,10 @@ func testArangodReachable(t *testing.T, sp client.ServerProcess) {
 	if sp.IsSecure {
 		scheme = "https"
 	}
-	url := fmt.Sprintf("%s://%s:%d/_api/version", scheme, sp.IP, sp.Port)
+	addr := net.JoinHostPort(sp.IP, strconv.Itoa(sp.Port))
+	url := fmt.Sprintf("%s://%s/_api/version", scheme, addr)
 	_, err := httpClient.Get(url)
 	if err != nil {
-		t.Errorf("Failed to reach arangod at %s:%d", sp.IP, sp.Port)
+		t.Errorf("Failed to reach arangod at %s", addr)
 	}
 }
@@ -0,0 +1,174 @@
+//
+// DISCLAIMER
+//
+// Copyright 2017 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+// Author Ewout Prangsma
+//
+
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/arangodb-helper/arangodb/client"
+	"github.com/spf13/cobra"
+)
+
+var (
+	cmdUpgrade = &cobra.Command{
+		Use:   "upgrade",
+		Short: "Perform a rolling upgrade of all servers of a deployment",
+		Run:   cmdUpgradeRun,
+	}
+	upgradeOptions struct {
+		endpoint      string
+		timeout       time.Duration
+		canary        bool
+		resume        bool
+		abort         bool
+		dockerImage   string
+		arangodPath   string
+		jsPath        string
+		targetVersion string
+		force         bool
+	}
+)
+
+func init() {
+	f := cmdUpgrade.Flags()
+	addStarterEndpointFlag(f, &upgradeOptions.endpoint)
+	f.DurationVar(&upgradeOptions.timeout, "timeout", time.Hour, "Time to wait for the upgrade to finish")
+	f.BoolVar(&upgradeOptions.canary, "canary", false, "If set, upgrade one dbserver & one coordinator first, check the deployment and wait for confirmation (--resume) before upgrading the other servers")
+	f.BoolVar(&upgradeOptions.resume, "resume", false, "Proceed with a canary upgrade that waits for confirmation")
+	f.BoolVar(&upgradeOptions.abort, "abort", false, "Stop a canary upgrade that waits for confirmation")
+	f.StringVar(&upgradeOptions.dockerImage, "docker.image", "", "Docker image to upgrade the servers to. If not set, the servers are upgraded with the image they already use")
+	f.StringVar(&upgradeOptions.arangodPath, "server.arangod", "", "Path of the arangod executable to upgrade the servers to. If not set, the servers are upgraded with the executable they already use")
+	f.StringVar(&upgradeOptions.jsPath, "server.js-dir", "", "Path of the JS startup directory of the arangod executable given by --server.arangod")
+	f.StringVar(&upgradeOptions.targetVersion, "target-version", "", "Version the servers are upgraded to, used by the checks before the upgrade. If not set, it is detected from the binary (or docker image tag)")
+	f.BoolVar(&upgradeOptions.force, "force", false, "If set, start the upgrade even when the checks before the upgrade fail")
+	cmdMain.AddCommand(cmdUpgrade)
+}
+
+func cmdUpgradeRun(cmd *cobra.Command, args []string) {
+	if upgradeOptions.resume && upgradeOptions.abort {
+		log.Fatal("Cannot use both --resume and --abort")
+	}
+	c := mustCreateStarterClient(upgradeOptions.endpoint)
+	// Starting an upgrade runs the checks before the upgrade, which can take a while
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute*2)
+	var status client.UpgradeStatus
+	var err error
+	switch {
+	case upgradeOptions.abort:
+		status, err = c.AbortUpgrade(ctx)
+		cancel()
+		if err != nil {
+			log.Fatalf("Failed to abort upgrade using starter at %s: %v", upgradeOptions.endpoint, err)
+		}
+		log.Info("Upgrade has been aborted")
+		return
+	case upgradeOptions.resume:
+		status, err = c.ResumeUpgrade(ctx)
+		cancel()
+		if err != nil {
+			log.Fatalf("Failed to resume upgrade using starter at %s: %v", upgradeOptions.endpoint, err)
+		}
+		log.Info("Upgrade has been resumed")
+	default:
+		status, err = c.StartUpgradeWithOptions(ctx, client.UpgradeOptions{
+			Canary:        upgradeOptions.canary,
+			DockerImage:   upgradeOptions.dockerImage,
+			ArangodPath:   upgradeOptions.arangodPath,
+			JSPath:        upgradeOptions.jsPath,
+			TargetVersion: upgradeOptions.targetVersion,
+			Force:         upgradeOptions.force,
+		})
+		cancel()
+		if err != nil {
+			log.Fatalf("Failed to start upgrade using starter at %s: %v", upgradeOptions.endpoint, err)
+		}
+		log.Infof("Upgrade of %d servers has started", len(status.Steps))
+	}
+
+	// Show progress until finished
+	deadline := time.Now().Add(upgradeOptions.timeout)
+	reported := make(map[int]string)
+	reportedChecks := 0
+	for {
+		showUpgradeChecks(status.Checks[reportedChecks:])
+		reportedChecks = len(status.Checks)
+		for i, step := range status.Steps {
+			if reported[i] == step.State || step.State == "pending" {
+				continue
+			}
+			reported[i] = step.State
+			showUpgradeStep(step)
+		}
+		if status.Failed {
+			log.Fatalf("Upgrade failed: %s", status.Reason)
+		}
+		if status.Paused {
+			log.Info("Canary servers have been upgraded. Run `arangodb upgrade --resume` to upgrade the other servers or `arangodb upgrade --abort` to stop the upgrade")
+			return
+		}
+		if status.Ready {
+			log.Info("Upgrade has finished")
+			return
+		}
+		if time.Now().After(deadline) {
+			log.Fatalf("Upgrade has not finished after %s", upgradeOptions.timeout)
+		}
+		time.Sleep(time.Second * 2)
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
+		if s, err := c.UpgradeStatus(ctx); err != nil {
+			log.Warningf("Failed to get upgrade status: %v", err)
+		} else {
+			status = s
+		}
+		cancel()
+	}
+}
+
+// showUpgradeStep logs the state of the given upgrade step.
+func showUpgradeStep(step client.UpgradeStep) {
+	switch step.State {
+	case "running":
+		log.Infof("Upgrading %s on peer '%s'...", step.ServerType, step.PeerID)
+	case "done":
+		log.Infof("Upgraded %s on peer '%s'", step.ServerType, step.PeerID)
+	case "skipped":
+		log.Infof("Skipped %s on peer '%s': %s", step.ServerType, step.PeerID, step.Message)
+	case "failed", "rolled-back":
+		log.Errorf("Failed to upgrade %s on peer '%s': %s", step.ServerType, step.PeerID, step.Message)
+		for _, line := range step.Logs {
+			log.Errorf("\t%s", line)
+		}
+	}
+}
+
+// showUpgradeChecks logs the results of the checks run before an upgrade and after its canary phase.
+func showUpgradeChecks(checks []client.UpgradeCheck) {
+	for _, check := range checks {
+		if check.Passed {
+			log.Infof("Check %s passed: %s", check.Name, check.Message)
+		} else {
+			log.Errorf("Check %s failed: %s", check.Name, check.Message)
+		}
+	}
+}
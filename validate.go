@@ -0,0 +1,848 @@
+//
+// DISCLAIMER
+//
+// Copyright 2017 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+// Author Ewout Prangsma
+//
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"time"
+
+	service "github.com/arangodb-helper/arangodb/service"
+	docker "github.com/fsouza/go-dockerclient"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+const (
+	severityError   = "error"
+	severityWarning = "warning"
+)
+
+var (
+	cmdValidate = &cobra.Command{
+		Use:   "validate",
+		Short: "Validate the options & configuration file of the starter, without starting anything",
+		Run:   cmdValidateRun,
+	}
+	validateOptions struct {
+		output string
+	}
+	// dockerOnlyOptions holds the options that only have an effect when using docker.
+	dockerOnlyOptions = []string{"docker.user", "docker.gc-delay", "docker.net-host", "docker.net-mode", "docker.privileged"}
+	// cpusetPattern matches a list of CPUs and CPU ranges, as accepted by taskset -c & docker.
+	cpusetPattern = regexp.MustCompile(`^\d+(-\d+)?(,\d+(-\d+)?)*$`)
+)
+
+// validationProblem describes a single problem found in the options of the starter.
+type validationProblem struct {
+	Severity string `json:"severity"`         // error | warning
+	Option   string `json:"option,omitempty"` // Name of the option causing the problem (if any)
+	Message  string `json:"message"`
+}
+
+// validationResult is the JSON output of `arangodb validate`.
+type validationResult struct {
+	Valid    bool                `json:"valid"`
+	Problems []validationProblem `json:"problems,omitempty"`
+}
+
+func init() {
+	// Validate accepts all options of the starter itself.
+	// This relies on the flags of cmdMain being defined (in main.go) before this init function runs.
+	f := cmdValidate.Flags()
+	f.AddFlagSet(cmdMain.Flags())
+	f.SetNormalizeFunc(normalizeOptionNames)
+	f.StringVar(&validateOptions.output, "output", "text", "Output format (text|json)")
+	cmdMain.AddCommand(cmdValidate)
+}
+
+func cmdValidateRun(cmd *cobra.Command, args []string) {
+	if validateOptions.output != "text" && validateOptions.output != "json" {
+		log.Fatalf("Unsupported output format '%s', expected text or json", validateOptions.output)
+	}
+
+	var problems []validationProblem
+	if len(args) > 0 {
+		problems = append(problems, validationProblem{Severity: severityError, Message: fmt.Sprintf("Expected no arguments, got %q", args)})
+	}
+	if err := loadConfigFile(cmd.Flags()); err != nil {
+		problems = append(problems, validationProblem{Severity: severityError, Option: "configuration", Message: err.Error()})
+	} else {
+		problems = append(problems, checkOptions()...)
+		problems = append(problems, checkEnvironment()...)
+	}
+
+	result := validationResult{Valid: true, Problems: problems}
+	for _, p := range problems {
+		if p.Severity == severityError {
+			result.Valid = false
+		}
+	}
+
+	if validateOptions.output == "json" {
+		encoded, err := json.MarshalIndent(result, "", "  ")
+		if err != nil {
+			log.Fatalf("Failed to encode result: %v", err)
+		}
+		fmt.Println(string(encoded))
+	} else {
+		for _, p := range problems {
+			if p.Option != "" {
+				fmt.Printf("%s: --%s: %s\n", p.Severity, p.Option, p.Message)
+			} else {
+				fmt.Printf("%s: %s\n", p.Severity, p.Message)
+			}
+		}
+		if result.Valid {
+			fmt.Println("Configuration is valid")
+		}
+	}
+	if !result.Valid {
+		os.Exit(1)
+	}
+}
+
+// checkOptions checks the given options for invalid values and conflicts.
+// These checks are done by the starter on every start.
+func checkOptions() []validationProblem {
+	var problems []validationProblem
+	addError := func(option, message string) {
+		problems = append(problems, validationProblem{Severity: severityError, Option: option, Message: message})
+	}
+	addWarning := func(option, message string) {
+		problems = append(problems, validationProblem{Severity: severityWarning, Option: option, Message: message})
+	}
+
+	if mode != "cluster" && mode != "single" {
+		addWarning("starter.mode", fmt.Sprintf("has unknown value '%s', cluster is used", mode))
+	}
+	if serverStorageEngine != "mmfiles" && serverStorageEngine != "rocksdb" {
+		addError("server.storage-engine", fmt.Sprintf("Unknown storage engine '%s', expected mmfiles or rocksdb", serverStorageEngine))
+	}
+	if err := service.ValidateRocksDBPreset(rocksdbPreset); err != nil {
+		addError("rocksdb.preset", err.Error())
+	} else if rocksdbPreset != "" && serverStorageEngine != "rocksdb" {
+		addError("rocksdb.preset", "rocksdb.preset requires --server.storage-engine=rocksdb")
+	}
+	if logFormat != service.LogFormatText && logFormat != service.LogFormatJSON {
+		addError("log.format", fmt.Sprintf("Unknown log format '%s', expected text or json", logFormat))
+	}
+	if _, err := parseLogLevels(logLevels); err != nil {
+		addError("log.level", err.Error())
+	}
+	if _, err := parseByteSize(logRotateSize); err != nil {
+		addError("log.rotate-size", err.Error())
+	}
+	if logForward != "" {
+		if err := service.ValidateLogForward(logForward); err != nil {
+			addError("log.forward", err.Error())
+		}
+	}
+	if tracingEndpoint != "" {
+		if u, err := url.Parse(tracingEndpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			addError("tracing.endpoint", fmt.Sprintf("Invalid URL '%s', expected http(s)://host:port", tracingEndpoint))
+		}
+	}
+	if coreLogLines < 0 {
+		addError("core.log-lines", "core.log-lines cannot be negative.")
+	}
+	if logRotateFiles < 0 {
+		addError("log.rotate-files", "log.rotate-files cannot be negative.")
+	}
+	if backupSchedule != "" {
+		if _, err := service.ParseCronSchedule(backupSchedule); err != nil {
+			addError("backup.schedule", err.Error())
+		}
+	} else {
+		for _, name := range []string{"backup.dir", "backup.keep"} {
+			if isOptionSet(name) {
+				addWarning(name, "has no effect without --backup.schedule")
+			}
+		}
+	}
+	if backupKeep < 0 {
+		addError("backup.keep", "backup.keep cannot be negative.")
+	}
+	if recoveryFromBackup != "" {
+		if masterAddress != "" {
+			addWarning("recovery.from-backup", "is ignored together with --starter.join, only the master recovers the deployment.")
+		} else if err := service.ValidateRecoveryBackup(mustExpand(recoveryFromBackup)); err != nil {
+			addError("recovery.from-backup", err.Error())
+		}
+	} else if recoveryRemoteConfig != "" {
+		addWarning("recovery.remote-config", "has no effect without --recovery.from-backup")
+	}
+	if bootstrapManifest != "" {
+		if _, _, err := service.ReadBootstrapManifest(mustExpand(bootstrapManifest)); err != nil {
+			addError("bootstrap.manifest", err.Error())
+		}
+	}
+	for _, script := range bootstrapScripts {
+		if err := service.ValidateBootstrapScript(mustExpand(script)); err != nil {
+			addError("bootstrap.js-script", err.Error())
+		}
+	}
+	for _, spec := range bootstrapFoxx {
+		if f, err := service.ParseFoxxService(spec); err != nil {
+			addError("bootstrap.foxx", err.Error())
+		} else if err := service.ValidateFoxxService(service.FoxxService{Source: mustExpand(f.Source), Mount: f.Mount}); err != nil {
+			addError("bootstrap.foxx", err.Error())
+		}
+	}
+	if bootstrapImportDir != "" {
+		if _, err := service.ReadImportSpec(mustExpand(bootstrapImportDir)); err != nil {
+			addError("bootstrap.import-dir", err.Error())
+		}
+	}
+	if bootstrapImportJobs < 1 {
+		addError("bootstrap.import-parallelism", "bootstrap.import-parallelism must be at least 1.")
+	}
+	if !service.IsValidRestartPolicy(restartPolicy) {
+		addError("server.restart-policy", fmt.Sprintf("Unknown restart policy '%s', expected always, on-failure or never", restartPolicy))
+	}
+	if restartMaxRetries < 0 {
+		addError("server.restart-max-retries", "server.restart-max-retries cannot be negative.")
+	}
+	if restartBackoffInitial < 0 {
+		addError("server.restart-backoff", "server.restart-backoff cannot be negative.")
+	}
+	if restartBackoffMax < restartBackoffInitial {
+		addError("server.restart-backoff-max", "server.restart-backoff-max cannot be less than server.restart-backoff.")
+	}
+	if crashLoopRestarts < 0 {
+		addError("server.crash-loop-restarts", "server.crash-loop-restarts cannot be negative.")
+	}
+	if crashLoopWindow <= 0 {
+		addError("server.crash-loop-window", "server.crash-loop-window must be positive.")
+	}
+	if crashLoopWebhook != "" {
+		if u, err := url.Parse(crashLoopWebhook); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			addError("server.crash-loop-webhook", "server.crash-loop-webhook must be an http(s) URL.")
+		} else if crashLoopRestarts == 0 {
+			addWarning("server.crash-loop-webhook", "has no effect with --server.crash-loop-restarts=0")
+		}
+	}
+	if notifyWebhookURL != "" {
+		if u, err := url.Parse(notifyWebhookURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			addError("notify.webhook-url", "notify.webhook-url must be an http(s) URL.")
+		}
+	}
+	if notifyWebhookSecretFile != "" {
+		if notifyWebhookURL == "" {
+			addWarning("notify.webhook-secret-file", "has no effect without --notify.webhook-url")
+		} else if content, err := ioutil.ReadFile(mustExpand(notifyWebhookSecretFile)); err != nil {
+			addError("notify.webhook-secret-file", fmt.Sprintf("Cannot read webhook secret file: %v", err))
+		} else if strings.TrimSpace(string(content)) == "" {
+			addError("notify.webhook-secret-file", "notify.webhook-secret-file must not be empty.")
+		}
+	}
+	if apiRateLimit < 0 {
+		addError("starter.api.rate-limit", "starter.api.rate-limit cannot be negative.")
+	}
+	if apiRateBurst < 1 {
+		addError("starter.api.rate-burst", "starter.api.rate-burst must be at least 1.")
+	}
+	if _, err := parseByteSize(apiMaxBodySize); err != nil {
+		addError("starter.api.max-body-size", "starter.api.max-body-size must be a size (e.g. 1MiB).")
+	}
+	for _, origin := range corsAllowedOrigins() {
+		if err := service.ValidateCORSOrigin(origin); err != nil {
+			addError("starter.api.cors-allowed-origins", err.Error())
+		} else if origin == "*" {
+			addWarning("starter.api.cors-allowed-origins", "* lets every web page, opened in a browser that can reach the starter, call its API")
+		}
+	}
+	if metricsStatsd != "" {
+		if _, port, err := net.SplitHostPort(metricsStatsd); err != nil || port == "" {
+			addError("metrics.statsd", "metrics.statsd must be host:port (e.g. localhost:8125).")
+		}
+		if metricsStatsdInterval < time.Second {
+			addError("metrics.statsd-interval", "metrics.statsd-interval must be at least 1s.")
+		}
+		if strings.Trim(metricsStatsdPrefix, ".") == "" || strings.ContainsAny(metricsStatsdPrefix, ":|# ") {
+			addError("metrics.statsd-prefix", "metrics.statsd-prefix must not be empty or contain ':', '|', '#' or spaces.")
+		}
+	}
+	if livenessInterval < 0 {
+		addError("server.liveness-interval", "server.liveness-interval cannot be negative.")
+	} else if livenessInterval > 0 {
+		if livenessTimeout <= 0 {
+			addError("server.liveness-timeout", "server.liveness-timeout must be positive.")
+		}
+		if livenessFailures <= 0 {
+			addError("server.liveness-failures", "server.liveness-failures must be positive.")
+		}
+	}
+	for option, timeout := range map[string]time.Duration{
+		"cluster.agent-startup-timeout":       agentStartupTimeout,
+		"cluster.dbserver-startup-timeout":    dbserverStartupTimeout,
+		"cluster.coordinator-startup-timeout": coordinatorStartupTimeout,
+		"server.single-startup-timeout":       singleStartupTimeout,
+	} {
+		if timeout <= 0 {
+			addError(option, fmt.Sprintf("%s must be positive.", option))
+		}
+	}
+	for _, option := range []string{"server.max-open-files", "server.max-processes"} {
+		if isOptionSet(option) && dockerImage != "" {
+			addWarning(option, "has no effect with --docker.image, configure the limits of the docker daemon instead")
+		}
+	}
+	for option, nice := range map[string]int{
+		"server.nice":       serverNice,
+		"agents.nice":       agentsNice,
+		"dbservers.nice":    dbserversNice,
+		"coordinators.nice": coordinatorsNice,
+	} {
+		if nice < -20 || nice > 19 {
+			addError(option, fmt.Sprintf("%s must be between -20 and 19.", option))
+		} else if nice != 0 && (runtime.GOOS != "linux" || dockerImage != "") {
+			addWarning(option, "is only supported for servers started as process on Linux")
+		}
+	}
+	for option, ionice := range map[string]string{
+		"server.ionice":       serverIONice,
+		"agents.ionice":       agentsIONice,
+		"dbservers.ionice":    dbserversIONice,
+		"coordinators.ionice": coordinatorsIONice,
+	} {
+		if ionice == "" {
+			continue
+		}
+		if !service.IsValidIONice(ionice) {
+			addError(option, fmt.Sprintf("%s must be realtime, best-effort or idle, optionally followed by ':' and a level 0-7.", option))
+		} else if runtime.GOOS != "linux" || dockerImage != "" {
+			addWarning(option, "is only supported for servers started as process on Linux")
+		}
+	}
+	if dbserversCpuset != "" && !cpusetPattern.MatchString(dbserversCpuset) {
+		addError("dbservers.cpuset", "dbservers.cpuset must be a list of CPUs and CPU ranges (e.g. 0-7,16-23).")
+	}
+	if dbserversNumactl != "" || dbserversCpuset != "" {
+		option, tool := "dbservers.cpuset", "taskset"
+		if dbserversNumactl != "" {
+			option, tool = "dbservers.numactl", "numactl"
+		}
+		if mode == "single" {
+			addWarning(option, "has no effect in single server mode")
+		} else if dockerImage != "" {
+			if dbserversNumactl != "" {
+				addWarning("dbservers.numactl", "has no effect with --docker.image, use --dbservers.cpuset instead")
+			}
+		} else if runtime.GOOS != "linux" {
+			addError(option, fmt.Sprintf("%s is only supported on Linux.", option))
+		} else if _, err := exec.LookPath(tool); err != nil {
+			addError(option, fmt.Sprintf("%s requires %s, which cannot be found.", option, tool))
+		}
+	}
+	if err := service.ValidateServerDirTemplate(serverDirTemplate); err != nil {
+		addError("server.dir-template", err.Error())
+	}
+	for option, dir := range map[string]string{
+		"agents.dir":       agentsDir,
+		"dbservers.dir":    dbserversDir,
+		"coordinators.dir": coordinatorsDir,
+	} {
+		if dir == "" {
+			continue
+		}
+		if mode == "single" {
+			addWarning(option, "has no effect in single server mode")
+		} else if info, err := os.Stat(mustExpand(dir)); err == nil && !info.IsDir() {
+			addError(option, fmt.Sprintf("%s must be a directory.", option))
+		}
+	}
+	if hooksDir != "" {
+		if info, err := os.Stat(mustExpand(hooksDir)); err != nil || !info.IsDir() {
+			addError("hooks.dir", "hooks.dir must be an existing directory.")
+		}
+	}
+	for option, script := range map[string]string{
+		"hooks.pre-start":    hooksPreStart,
+		"hooks.post-ready":   hooksPostReady,
+		"hooks.pre-stop":     hooksPreStop,
+		"hooks.canary-check": hooksCanaryCheck,
+	} {
+		if script != "" {
+			if err := service.ValidateHookScript(mustExpand(script)); err != nil {
+				addError(option, err.Error())
+			}
+		}
+	}
+	if hooksTimeout <= 0 {
+		addError("hooks.timeout", "hooks.timeout must be positive.")
+	}
+	if size, err := parseByteSize(diskMinFree); err != nil {
+		addError("disk.min-free", "disk.min-free must be a size (e.g. 1GiB).")
+	} else if size > 0 && runtime.GOOS != "linux" && runtime.GOOS != "darwin" {
+		addWarning("disk.min-free", "has no effect on this platform, the free disk space cannot be detected")
+	}
+	if diskCheckInterval < 0 {
+		addError("disk.check-interval", "disk.check-interval cannot be negative.")
+	}
+	if memoryTotal != "auto" {
+		if _, err := parseByteSize(memoryTotal); err != nil {
+			addError("memory.total", "memory.total must be auto or a size (e.g. 64GiB).")
+		}
+	}
+	if starterListen != "" && !service.IsUnixSocketEndpoint(starterListen) {
+		addError("starter.listen", "starter.listen must be a unix socket (e.g. unix:///run/arangodb/starter.sock).")
+	} else if starterListen != "" && mode != "single" {
+		addWarning("starter.listen", "the starter API is served on its TCP port as well, since the peers need to reach it")
+	}
+	if serverListen != "" {
+		if !service.IsUnixSocketEndpoint(serverListen) {
+			addError("server.listen", "server.listen must be a unix socket (e.g. unix:///run/arangodb/arangod.sock).")
+		}
+		if mode != "single" {
+			addError("server.listen", "--server.listen is only possible in single server mode.")
+		}
+		if dockerImage != "" {
+			addError("server.listen", "using --docker.image and --server.listen is not possible.")
+		}
+		if sslKeyFile != "" || sslAutoKeyFile {
+			addWarning("server.listen", "the single server does not use SSL on a unix socket")
+		}
+	}
+	for _, x := range []struct {
+		serverType service.ServerType
+		port       int
+		portRange  string
+	}{
+		{service.ServerTypeAgent, agentPort, agentPortRange},
+		{service.ServerTypeDBServer, dbserverPort, dbserverPortRange},
+		{service.ServerTypeCoordinator, coordinatorPort, coordinatorPortRange},
+	} {
+		portOption := fmt.Sprintf("cluster.%s-port", x.serverType)
+		rangeOption := portOption + "-range"
+		if x.port != 0 && x.portRange != "" {
+			addError(rangeOption, fmt.Sprintf("using --%s and --%s is not possible.", portOption, rangeOption))
+			continue
+		}
+		option, value := portOption, serverPortRangeOptions()[x.serverType]
+		if x.portRange != "" {
+			option = rangeOption
+		}
+		if value == "" {
+			continue
+		}
+		r, err := service.ParsePortRange(value)
+		if err != nil {
+			addError(option, err.Error())
+			continue
+		}
+		if mode == "single" {
+			addWarning(option, "has no effect in single server mode")
+			continue
+		}
+		if (startLocalSlaves || allPortOffsetsUnique) && r.Max-r.Min+1 < agencySize {
+			addWarning(option, fmt.Sprintf("contains fewer ports than peers on this machine (%d)", agencySize))
+		}
+	}
+	if proxyPort != 0 {
+		if proxyPort < 0 || proxyPort > 65535 {
+			addError("proxy.port", "proxy.port must be a port between 1 and 65535.")
+		}
+		if mode == "single" {
+			addWarning("proxy.port", "has no effect in single server mode")
+		}
+		if proxyHealthInterval <= 0 {
+			addError("proxy.health-interval", "proxy.health-interval must be positive.")
+		}
+	}
+	if agencySize%2 == 0 || agencySize <= 0 {
+		addError("cluster.agency-size", "cluster.agency-size needs to be a positive, odd number.")
+	}
+	if strings.Contains(ownAddress, ":") && net.ParseIP(strings.Trim(ownAddress, "[]")) == nil {
+		addError("starter.address", "starter.address must be a host name or IP address (without port).")
+	}
+	if strings.Count(masterAddress, ":") > 1 && !strings.HasPrefix(masterAddress, "[") && net.ParseIP(masterAddress) == nil {
+		addError("starter.join", "starter.join must enclose IPv6 addresses in brackets when giving a port (e.g. [fd00::1]:8528).")
+	}
+	if strings.HasPrefix(ownAddress, "auto-") && !service.IsCloudAddress(ownAddress) {
+		addError("starter.address", "starter.address must be auto-aws, auto-gcp or auto-azure (optionally followed by -public) to detect the address using the instance metadata service.")
+	}
+	if starterInterface != "" {
+		if ownAddress != "" {
+			addWarning("starter.interface", "is ignored together with --starter.address.")
+		} else if preferHostnames {
+			addWarning("starter.interface", "is ignored together with --starter.prefer-hostnames.")
+		} else if _, err := service.InterfaceAddress(starterInterface); err != nil {
+			addError("starter.interface", err.Error())
+		}
+	}
+	if bindAddress != "" && net.ParseIP(strings.Trim(bindAddress, "[]")) == nil {
+		addError("starter.bind-address", "starter.bind-address must be an IP address (e.g. 0.0.0.0).")
+	}
+	if preferHostnames && (net.ParseIP(strings.Trim(ownAddress, "[]")) != nil || service.IsCloudAddress(ownAddress)) {
+		addWarning("starter.prefer-hostnames", "this starter advertises an IP address, since --starter.address is not a host name.")
+	}
+	if agencySize == 1 && ownAddress == "" && starterInterface == "" && !preferHostnames {
+		addError("starter.address", "if cluster.agency-size==1, starter.address must be given.")
+	}
+	if discovery != "" {
+		if _, err := service.ParseDiscoveryURI(discovery); err != nil {
+			addError("starter.discovery", err.Error())
+		}
+		if masterAddress != "" {
+			addWarning("starter.discovery", "is ignored together with --starter.join.")
+		} else if ownAddress == "" && starterInterface == "" && !preferHostnames {
+			addError("starter.address", "--starter.discovery requires --starter.address (or --starter.interface), which is advertised as address of the master.")
+		}
+		if mode == "single" {
+			addWarning("starter.discovery", "has no effect in single server mode")
+		}
+	}
+	if startLocalSlaves && masterAddress != "" {
+		addWarning("starter.local", "is ignored together with --starter.join.")
+	}
+	if startLocalSlaves {
+		if localPortOffset < service.MinPortOffsetIncrement {
+			addError("starter.local.port-offset", fmt.Sprintf("starter.local.port-offset must be at least %d, so the ports of the master and the first local slave do not overlap.", service.MinPortOffsetIncrement))
+		}
+		if localPortIncrement < service.MinPortOffsetIncrement {
+			addError("starter.local.port-increment", fmt.Sprintf("starter.local.port-increment must be at least %d, so the ports of local slaves do not overlap.", service.MinPortOffsetIncrement))
+		}
+		if localAgents < 0 || (localAgents > 0 && localAgents%2 == 0) {
+			addError("starter.local.agents", "starter.local.agents needs to be a positive, odd number.")
+		}
+		if localDBServers < 0 {
+			addError("starter.local.dbservers", "starter.local.dbservers cannot be negative.")
+		}
+		if localCoordinators < 0 {
+			addError("starter.local.coordinators", "starter.local.coordinators cannot be negative.")
+		}
+		if size := localClusterSize(); size.IsSet() && mode == "single" {
+			addWarning("starter.local.agents", "has no effect in single server mode")
+		}
+	} else if localAgents != 0 || localDBServers != 0 || localCoordinators != 0 {
+		addWarning("starter.local.agents", "& --starter.local.dbservers & --starter.local.coordinators have no effect without --starter.local")
+	} else if localPortOffset != service.DefaultPortLayout.FirstOffset || localPortIncrement != service.DefaultPortLayout.Increment {
+		addWarning("starter.local.port-increment", "has no effect without --starter.local")
+	}
+	if standby && masterAddress == "" {
+		addError("starter.standby", "--starter.standby requires --starter.join.")
+	}
+	if standby && mode == "single" {
+		addError("starter.standby", "--starter.standby is not possible in single server mode.")
+	}
+	if mode != "single" && masterAddress == "" && !startLocalSlaves && joinToken == "" && jwtSecretFile == "" {
+		addWarning("starter.join-token", "is not set (nor --auth.jwt-secret), any starter that can reach this starter can join the cluster")
+	}
+	if apiReadOnly && mode != "single" && jwtSecretFile == "" {
+		addError("starter.api.read-only", "requires --auth.jwt-secret in a cluster, since the starters use it to authenticate their requests to each other")
+	}
+	if passive && masterAddress == "" {
+		addError("starter.passive", "--starter.passive requires --starter.join.")
+	}
+	if passive && mode == "single" {
+		addError("starter.passive", "--starter.passive is not possible in single server mode.")
+	}
+	if passive && standby {
+		addError("starter.passive", "using --starter.passive and --starter.standby is not possible.")
+	}
+	if agentFailoverDelay > 0 && mode == "single" {
+		addWarning("cluster.agent-failover-delay", "has no effect in single server mode")
+	}
+	if masterFailoverDelay != 0 && masterFailoverDelay < service.MinMasterFailoverDelay {
+		addError("starter.master-failover-delay", fmt.Sprintf("starter.master-failover-delay must be 0 (disabled) or at least %s.", service.MinMasterFailoverDelay))
+	}
+	if clockSkewWarning < 0 || clockSkewLimit < 0 {
+		addError("starter.clock-skew-warning", "starter.clock-skew-warning and starter.clock-skew-limit cannot be negative.")
+	}
+	if dockerImage != "" && rrPath != "" {
+		addError("server.rr", "using --docker.image and --server.rr is not possible.")
+	}
+	if serverDownload {
+		if serverVersion == "" {
+			addError("server.version", "--server.download requires --server.version.")
+		} else if err := service.ValidateDownloadVersion(serverVersion); err != nil {
+			addError("server.version", err.Error())
+		}
+		if dockerImage != "" {
+			addError("server.download", "using --docker.image and --server.download is not possible.")
+		}
+		for _, name := range []string{"server.arangod", "server.js-dir"} {
+			if isOptionSet(name) {
+				addWarning(name, "is ignored when using --server.download")
+			}
+		}
+	} else {
+		for _, name := range []string{"server.version", "server.download-url", "server.download-sha256", "server.download-dir"} {
+			if isOptionSet(name) {
+				addWarning(name, "has no effect without --server.download")
+			}
+		}
+	}
+	if licenseFile != "" && licenseEnv != "" {
+		addError("server.license-env", "cannot set --server.license-file and --server.license-env at the same time")
+	}
+	if dockerNetHost && dockerNetworkMode != "" && dockerNetworkMode != "host" {
+		addError("docker.net-mode", "cannot set --docker.net-host and --docker.net-mode at the same time")
+	}
+	if sslAutoKeyFile && sslKeyFile != "" {
+		addError("ssl.auto-key", "Cannot specify both --ssl.auto-key and --ssl.keyfile")
+	}
+	if dockerImage == "" {
+		for _, name := range dockerOnlyOptions {
+			if isOptionSet(name) {
+				addWarning(name, "has no effect without --docker.image")
+			}
+		}
+	} else {
+		for _, name := range []string{"server.arangod", "server.js-dir"} {
+			if isOptionSet(name) {
+				addWarning(name, "is ignored when using --docker.image")
+			}
+		}
+	}
+	for _, o := range passthroughOptions {
+		option := o.Prefix + "." + o.Name
+		switch o.Name {
+		case "server.endpoint":
+			addWarning(option, "conflicts with the endpoint configured by the starter")
+		case service.ServerBinaryOptionVersion:
+			if dockerImage == "" && !hasPassthroughOption(o.Prefix, service.ServerBinaryOptionArangod) {
+				if err := service.ValidateDownloadVersion(o.Value); err != nil {
+					addError(option, err.Error())
+				}
+			}
+		case service.ServerBinaryOptionArangod, service.ServerBinaryOptionJSDir:
+			if dockerImage != "" {
+				addWarning(option, "is ignored when using --docker.image")
+			}
+		case service.ServerBinaryOptionImage:
+			if dockerImage == "" {
+				addWarning(option, "has no effect without --docker.image")
+			}
+		}
+	}
+	return problems
+}
+
+// checkEnvironment checks that everything the starter needs at runtime is available.
+func checkEnvironment() []validationProblem {
+	var problems []validationProblem
+	addError := func(option, message string) {
+		problems = append(problems, validationProblem{Severity: severityError, Option: option, Message: message})
+	}
+	addWarning := func(option, message string) {
+		problems = append(problems, validationProblem{Severity: severityWarning, Option: option, Message: message})
+	}
+
+	// Data directory must be writable
+	dir, _ := filepath.Abs(mustExpand(dataDir))
+	for {
+		if _, err := os.Stat(dir); err == nil {
+			break
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			break
+		}
+		dir = parent
+	}
+	if f, err := ioutil.TempFile(dir, ".arangodb-validate-"); err != nil {
+		addError("data.dir", fmt.Sprintf("Directory %s is not writable: %v", dir, err))
+	} else {
+		f.Close()
+		os.Remove(f.Name())
+	}
+
+	// Executables or docker image must exist
+	if dockerImage == "" {
+		checkExecutable := func(option, path string) {
+			path = mustExpand(path)
+			if info, err := os.Stat(path); err != nil {
+				addError(option, fmt.Sprintf("Cannot find %s: %v", path, err))
+			} else if info.IsDir() || (runtime.GOOS != "windows" && info.Mode()&0111 == 0) {
+				addError(option, fmt.Sprintf("%s is not an executable", path))
+			}
+		}
+		if rrPath != "" {
+			checkExecutable("server.rr", rrPath)
+		}
+		if serverDownload {
+			options := service.DownloadArangodOptions{Version: serverVersion, CacheDir: mustExpand(serverDownloadDir)}
+			if _, _, found := service.FindDownloadedArangod(options); found {
+				// Nothing to download
+			} else if offline {
+				addError("starter.offline", service.OfflineDownloadError(options).Error())
+			} else {
+				addWarning("server.version", fmt.Sprintf("ArangoDB %s has not been downloaded yet, it will be downloaded at startup", serverVersion))
+			}
+		} else {
+			checkExecutable("server.arangod", arangodPath)
+			if info, err := os.Stat(mustExpand(arangodJSPath)); err != nil || !info.IsDir() {
+				addError("server.js-dir", fmt.Sprintf("Cannot find directory %s", mustExpand(arangodJSPath)))
+			}
+		}
+		for _, o := range passthroughOptions {
+			if o.Name == service.ServerBinaryOptionArangod {
+				checkExecutable(o.Prefix+"."+o.Name, o.Value)
+			}
+		}
+	} else {
+		if client, err := docker.NewClient(dockerEndpoint); err != nil {
+			addError("docker.endpoint", fmt.Sprintf("Cannot create docker client: %v", err))
+		} else if err := client.Ping(); err != nil {
+			addError("docker.endpoint", fmt.Sprintf("Cannot reach docker daemon at %s: %v", dockerEndpoint, err))
+		} else if _, err := client.InspectImage(dockerImage); err == docker.ErrNoSuchImage && offline {
+			addError("starter.offline", service.OfflineImageError(dockerImage).Error())
+		} else if err == docker.ErrNoSuchImage {
+			addWarning("docker.image", fmt.Sprintf("Image %s is not available locally, it will be pulled at startup", dockerImage))
+		} else if err != nil {
+			addError("docker.image", fmt.Sprintf("Cannot inspect image %s: %v", dockerImage, err))
+		}
+	}
+
+	// Secrets & certificates must be readable
+	if jwtSecretFile != "" {
+		if content, err := ioutil.ReadFile(mustExpand(jwtSecretFile)); err != nil {
+			addError("auth.jwt-secret", fmt.Sprintf("Cannot read JWT secret file: %v", err))
+		} else if strings.TrimSpace(string(content)) == "" {
+			addError("auth.jwt-secret", "JWT secret file is empty")
+		}
+	}
+	if rootPassword != "" && rootPasswordFile != "" {
+		addError("auth.root-password", "Cannot use both --auth.root-password and --auth.root-password-file.")
+	} else if rootPassword != "" {
+		addWarning("auth.root-password", "The password is visible in the process list, use --auth.root-password-file instead.")
+	} else if rootPasswordFile != "" {
+		if content, err := ioutil.ReadFile(mustExpand(rootPasswordFile)); err != nil {
+			addError("auth.root-password-file", fmt.Sprintf("Cannot read root password file: %v", err))
+		} else if strings.TrimRight(string(content), "\r\n") == "" {
+			addError("auth.root-password-file", "Root password file is empty")
+		}
+	}
+	if (rootPassword != "" || rootPasswordFile != "") && jwtSecretFile == "" {
+		addWarning("auth.root-password", "Servers run without authentication (see --auth.jwt-secret), the root password is not checked.")
+	}
+	licenseOption := "server.license-file"
+	if licenseFile == "" {
+		licenseOption = "server.license-env"
+	}
+	if licenseKey, err := readLicenseKey(); err != nil {
+		addError(licenseOption, err.Error())
+	} else if licenseKey != "" {
+		license, _ := service.ParseLicenseKey(licenseKey)
+		switch license.Status(time.Now()) {
+		case service.LicenseExpired:
+			addWarning(licenseOption, fmt.Sprintf("License key has expired at %s", license.Expires.Format(time.RFC3339)))
+		case service.LicenseExpiring:
+			addWarning(licenseOption, fmt.Sprintf("License key expires at %s", license.Expires.Format(time.RFC3339)))
+		}
+	}
+	if sslKeyFile != "" {
+		if _, err := service.LoadKeyFile(mustExpand(sslKeyFile)); err != nil {
+			addError("ssl.keyfile", fmt.Sprintf("Cannot load keyfile: %v", err))
+		}
+	}
+	if sslCAFile != "" {
+		if _, err := ioutil.ReadFile(mustExpand(sslCAFile)); err != nil {
+			addError("ssl.cafile", fmt.Sprintf("Cannot read CA file: %v", err))
+		}
+	}
+
+	// Compare with existing setup
+	if cfg, _, path, err := service.ReadSetupFile(mustExpand(dataDir)); err != nil {
+		if !os.IsNotExist(errors.Cause(err)) {
+			addError("data.dir", fmt.Sprintf("Existing setup.json cannot be read and has no valid backup: %v", err))
+		}
+	} else {
+		if filepath.Base(path) != "setup.json" {
+			addWarning("data.dir", fmt.Sprintf("Existing setup.json is missing or invalid, its backup %s will be used", path))
+		}
+		if err := service.CheckSetupVersion(cfg.Version); err != nil {
+			addError("data.dir", err.Error())
+		} else {
+			if cfg.Version != service.SetupConfigVersion {
+				addWarning("data.dir", fmt.Sprintf("Existing setup.json (version %s) will be migrated to version %s", cfg.Version, service.SetupConfigVersion))
+			}
+			if mode == "cluster" && cfg.Peers.AgencySize != agencySize {
+				addWarning("cluster.agency-size", fmt.Sprintf("Existing setup.json uses an agency size of %d, which takes precedence", cfg.Peers.AgencySize))
+			}
+			if engine := cfg.Peers.StorageEngine; engine != "" && engine != serverStorageEngine {
+				addError("server.storage-engine", fmt.Sprintf("Existing setup.json uses the %s storage engine, storage engines cannot be mixed", engine))
+			}
+			if masterAddress != "" {
+				addWarning("starter.join", "Existing setup.json takes precedence, the master is not contacted")
+			}
+		}
+	}
+
+	// Ports must be free
+	serverTypes := []service.ServerType{service.ServerTypeAgent, service.ServerTypeCoordinator, service.ServerTypeDBServer}
+	if mode == "single" {
+		serverTypes = []service.ServerType{service.ServerTypeSingle}
+	}
+	var ports []int
+	if mode != "single" || starterListen == "" {
+		ports = append(ports, masterPort)
+	}
+	for _, t := range serverTypes {
+		if t == service.ServerTypeSingle && serverListen != "" {
+			continue
+		}
+		if r, err := service.ParsePortRange(serverPortRangeOptions()[t]); err == nil && mode != "single" {
+			if !anyPortOpen(strings.Trim(bindAddress, "[]"), r) {
+				option := fmt.Sprintf("cluster.%s-port-range", t)
+				if r.Min == r.Max {
+					option = fmt.Sprintf("cluster.%s-port", t)
+				}
+				addError(option, fmt.Sprintf("All ports in %s are already in use", r))
+			}
+			continue
+		}
+		ports = append(ports, masterPort+t.PortOffset())
+	}
+	for _, port := range ports {
+		if !service.IsPortOpen(strings.Trim(bindAddress, "[]"), port) {
+			addError("starter.port", fmt.Sprintf("Port %d is already in use", port))
+		}
+	}
+	if proxyPort > 0 && mode != "single" && !service.IsPortOpen(strings.Trim(bindAddress, "[]"), proxyPort) {
+		addError("proxy.port", fmt.Sprintf("Port %d is already in use", proxyPort))
+	}
+
+	return problems
+}
+
+// anyPortOpen returns true if at least one port of the given range is free to listen on.
+func anyPortOpen(host string, r service.PortRange) bool {
+	for port := r.Min; port <= r.Max; port++ {
+		if service.IsPortOpen(host, port) {
+			return true
+		}
+	}
+	return false
+}
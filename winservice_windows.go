@@ -0,0 +1,311 @@
+//
+// DISCLAIMER
+//
+// Copyright 2017 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+// Author Ewout Prangsma
+//
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	logging "github.com/op/go-logging"
+	"github.com/spf13/cobra"
+	"golang.org/x/sys/windows/svc"
+	"golang.org/x/sys/windows/svc/eventlog"
+	"golang.org/x/sys/windows/svc/mgr"
+)
+
+var (
+	cmdService = &cobra.Command{
+		Use:   "service",
+		Short: "Manage the starter as Windows service",
+	}
+	cmdServiceInstall = &cobra.Command{
+		Use:   "install",
+		Short: "Register the starter (with the given options) as Windows service",
+		Run:   cmdServiceInstallRun,
+	}
+	cmdServiceStart = &cobra.Command{
+		Use:   "start",
+		Short: "Start the Windows service of the starter",
+		Run:   cmdServiceStartRun,
+	}
+	cmdServiceStop = &cobra.Command{
+		Use:   "stop",
+		Short: "Stop the Windows service of the starter and all servers started by it",
+		Run:   cmdServiceStopRun,
+	}
+	cmdServiceUninstall = &cobra.Command{
+		Use:   "uninstall",
+		Short: "Remove the Windows service of the starter",
+		Run:   cmdServiceUninstallRun,
+	}
+	cmdServiceRun = &cobra.Command{
+		Use:    "run",
+		Short:  "Run the starter as Windows service (used by the service control manager)",
+		Hidden: true,
+		Run:    cmdServiceRunRun,
+	}
+	winServiceOptions struct {
+		name        string
+		displayName string
+		manualStart bool
+		eventLog    bool
+		timeout     time.Duration
+	}
+)
+
+func init() {
+	for _, c := range []*cobra.Command{cmdServiceInstall, cmdServiceStart, cmdServiceStop, cmdServiceUninstall, cmdServiceRun} {
+		c.Flags().StringVar(&winServiceOptions.name, "service-name", projectName, "Name of the Windows service")
+		cmdService.AddCommand(c)
+	}
+	// Install & run accept all options of the starter itself, which are passed on to the service.
+	// This relies on the flags of cmdMain being defined (in main.go) before this init function runs.
+	for _, c := range []*cobra.Command{cmdServiceInstall, cmdServiceRun} {
+		c.Flags().AddFlagSet(cmdMain.Flags())
+		c.Flags().SetNormalizeFunc(normalizeOptionNames)
+		c.Flags().BoolVar(&winServiceOptions.eventLog, "eventlog", false, "If set, the starter logs to the Windows event log")
+	}
+	f := cmdServiceInstall.Flags()
+	f.StringVar(&winServiceOptions.displayName, "display-name", "ArangoDB starter", "Name of the service shown by the service control manager")
+	f.BoolVar(&winServiceOptions.manualStart, "manual-start", false, "If set, the service is not started automatically when Windows boots")
+	cmdServiceStop.Flags().DurationVar(&winServiceOptions.timeout, "timeout", time.Minute*5, "Time to wait for the service to stop")
+	cmdMain.AddCommand(cmdService)
+}
+
+func cmdServiceInstallRun(cmd *cobra.Command, args []string) {
+	if len(args) > 0 {
+		log.Fatalf("Expected no arguments, got %q", args)
+	}
+	// Only the passthrough options of the command line are passed on, the configuration file is read by the starter itself
+	cmdLinePassthroughOptions := passthroughOptions
+	if err := loadConfigFile(cmd.Flags()); err != nil {
+		log.Fatal(err.Error())
+	}
+	for _, p := range checkOptions() {
+		if p.Severity == severityError {
+			log.Fatalf("--%s: %s", p.Option, p.Message)
+		}
+	}
+	executable, err := starterExecutable()
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+	starterArgs, err := starterArguments(cmdLinePassthroughOptions)
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+	serviceArgs := []string{"service", "run", "--service-name=" + winServiceOptions.name}
+	if winServiceOptions.eventLog {
+		serviceArgs = append(serviceArgs, "--eventlog")
+	}
+	serviceArgs = append(serviceArgs, starterArgs...)
+
+	m, err := mgr.Connect()
+	if err != nil {
+		log.Fatalf("Cannot connect to service control manager: %v", err)
+	}
+	defer m.Disconnect()
+	if s, err := m.OpenService(winServiceOptions.name); err == nil {
+		s.Close()
+		log.Fatalf("Service %s already exists, uninstall it first", winServiceOptions.name)
+	}
+	config := mgr.Config{
+		DisplayName: winServiceOptions.displayName,
+		Description: "Starts & supervises the ArangoDB servers of this machine",
+		StartType:   mgr.StartAutomatic,
+	}
+	if winServiceOptions.manualStart {
+		config.StartType = mgr.StartManual
+	}
+	s, err := m.CreateService(winServiceOptions.name, executable, config, serviceArgs...)
+	if err != nil {
+		log.Fatalf("Failed to create service %s: %v", winServiceOptions.name, err)
+	}
+	defer s.Close()
+	if winServiceOptions.eventLog {
+		if err := eventlog.InstallAsEventCreate(winServiceOptions.name, eventlog.Error|eventlog.Warning|eventlog.Info); err != nil {
+			s.Delete()
+			log.Fatalf("Failed to register event log source %s: %v", winServiceOptions.name, err)
+		}
+	}
+	log.Infof("Service %s has been installed, use `%s service start` to start it", winServiceOptions.name, projectName)
+}
+
+func cmdServiceStartRun(cmd *cobra.Command, args []string) {
+	m, s := mustOpenWinService()
+	defer m.Disconnect()
+	defer s.Close()
+	if err := s.Start(); err != nil {
+		log.Fatalf("Failed to start service %s: %v", winServiceOptions.name, err)
+	}
+	log.Infof("Service %s is starting", winServiceOptions.name)
+}
+
+func cmdServiceStopRun(cmd *cobra.Command, args []string) {
+	m, s := mustOpenWinService()
+	defer m.Disconnect()
+	defer s.Close()
+	if err := stopWinService(s, winServiceOptions.timeout); err != nil {
+		log.Fatalf("Failed to stop service %s: %v", winServiceOptions.name, err)
+	}
+	log.Infof("Service %s has stopped", winServiceOptions.name)
+}
+
+func cmdServiceUninstallRun(cmd *cobra.Command, args []string) {
+	m, s := mustOpenWinService()
+	defer m.Disconnect()
+	defer s.Close()
+	if status, err := s.Query(); err == nil && status.State != svc.Stopped {
+		log.Infof("Stopping service %s", winServiceOptions.name)
+		if err := stopWinService(s, time.Minute*5); err != nil {
+			log.Fatalf("Failed to stop service %s: %v", winServiceOptions.name, err)
+		}
+	}
+	if err := s.Delete(); err != nil {
+		log.Fatalf("Failed to remove service %s: %v", winServiceOptions.name, err)
+	}
+	// The event log source only exists when installed with --eventlog
+	eventlog.Remove(winServiceOptions.name)
+	log.Infof("Service %s has been removed", winServiceOptions.name)
+}
+
+// mustOpenWinService connects to the service control manager and opens the service of the starter.
+func mustOpenWinService() (*mgr.Mgr, *mgr.Service) {
+	m, err := mgr.Connect()
+	if err != nil {
+		log.Fatalf("Cannot connect to service control manager: %v", err)
+	}
+	s, err := m.OpenService(winServiceOptions.name)
+	if err != nil {
+		m.Disconnect()
+		log.Fatalf("Cannot open service %s: %v", winServiceOptions.name, err)
+	}
+	return m, s
+}
+
+// stopWinService requests the given service to stop and waits until it has stopped.
+func stopWinService(s *mgr.Service, timeout time.Duration) error {
+	status, err := s.Control(svc.Stop)
+	if err != nil {
+		return maskAny(err)
+	}
+	deadline := time.Now().Add(timeout)
+	for status.State != svc.Stopped {
+		if time.Now().After(deadline) {
+			return maskAny(fmt.Errorf("Service has not stopped within %s", timeout))
+		}
+		time.Sleep(time.Second)
+		if status, err = s.Query(); err != nil {
+			return maskAny(err)
+		}
+	}
+	return nil
+}
+
+func cmdServiceRunRun(cmd *cobra.Command, args []string) {
+	if winServiceOptions.eventLog {
+		elog, err := eventlog.Open(winServiceOptions.name)
+		if err != nil {
+			log.Fatalf("Cannot open event log: %v", err)
+		}
+		defer elog.Close()
+		logging.SetBackend(logging.NewBackendFormatter(&eventLogBackend{elog: elog}, logging.MustStringFormatter("%{module}: %{message}")))
+	}
+	if err := svc.Run(winServiceOptions.name, &winService{cmd: cmd, args: args}); err != nil {
+		log.Fatalf("Failed to run as service %s: %v", winServiceOptions.name, err)
+	}
+}
+
+// winService runs the starter under control of the Windows service control manager.
+type winService struct {
+	cmd  *cobra.Command
+	args []string
+}
+
+// Execute implements svc.Handler.
+// The starter is stopped (gracefully, including all its servers) when the service is stopped or Windows shuts down.
+func (ws *winService) Execute(args []string, requests <-chan svc.ChangeRequest, status chan<- svc.Status) (bool, uint32) {
+	status <- svc.Status{State: svc.StartPending}
+	ctx, cancel := context.WithCancel(context.Background())
+	runContext = ctx
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		cmdMainRun(ws.cmd, ws.args)
+	}()
+	accepted := svc.AcceptStop | svc.AcceptShutdown
+	status <- svc.Status{State: svc.Running, Accepts: accepted}
+
+	checkPoint := uint32(0)
+	stopping := false
+	for {
+		select {
+		case <-done:
+			status <- svc.Status{State: svc.Stopped}
+			if !stopping {
+				// The starter has stopped by itself (e.g. using `arangodb stop`)
+				log.Info("Starter has stopped")
+			}
+			cancel()
+			return false, 0
+		case r := <-requests:
+			switch r.Cmd {
+			case svc.Interrogate:
+				status <- r.CurrentStatus
+			case svc.Stop, svc.Shutdown:
+				if !stopping {
+					log.Info("Stopping the starter on request of the service control manager")
+					stopping = true
+					cancel()
+				}
+				checkPoint++
+				status <- svc.Status{State: svc.StopPending, CheckPoint: checkPoint, WaitHint: 30000}
+			}
+		case <-time.After(time.Second * 10):
+			if stopping {
+				// Tell the service control manager that the servers are still shutting down
+				checkPoint++
+				status <- svc.Status{State: svc.StopPending, CheckPoint: checkPoint, WaitHint: 30000}
+			}
+		}
+	}
+}
+
+// eventLogBackend writes log records of the starter to the Windows event log.
+type eventLogBackend struct {
+	elog *eventlog.Log
+}
+
+// Log implements logging.Backend.
+func (b *eventLogBackend) Log(level logging.Level, calldepth int, rec *logging.Record) error {
+	msg := rec.Formatted(calldepth + 1)
+	switch {
+	case level <= logging.ERROR:
+		return b.elog.Error(1, msg)
+	case level == logging.WARNING:
+		return b.elog.Warning(1, msg)
+	default:
+		return b.elog.Info(1, msg)
+	}
+}